@@ -30,6 +30,7 @@ func buildImage(args []string, logger log.Logger) error {
 		StreamName:     args[0],
 		ExpiresIn:      *expiresIn,
 		MaxSourceAge:   *maxSourceAge,
+		Priority:       *priority,
 		StreamBuildLog: true,
 	}
 	if len(args) > 1 {
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/imagebuilder/client"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func cancelBuildSubcommand(args []string, logger log.DebugLogger) {
+	if err := cancelBuild(args[0], logger); err != nil {
+		fmt.Fprintf(os.Stderr, "Error cancelling build: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func cancelBuild(streamName string, logger log.Logger) error {
+	numCancelled, err := client.CancelBuild(getImaginatorClient(), streamName)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Cancelled %d builds\n", numCancelled)
+	return nil
+}
@@ -35,6 +35,8 @@ var (
 		"Port number of image server")
 	maxSourceAge = flag.Duration("maxSourceAge", time.Hour,
 		"Maximum age of a source image before it is rebuilt")
+	priority = flag.Int("priority", 0,
+		"Build priority (higher runs sooner, positive requires admin)")
 	rawSize flagutil.Size
 
 	minimumExpiration = 5 * time.Minute
@@ -56,6 +58,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  build-image stream-name [git-branch]")
 	fmt.Fprintln(os.Stderr, "  build-raw-from-manifest manifestDir rawFile")
 	fmt.Fprintln(os.Stderr, "  build-tree-from-manifest manifestDir")
+	fmt.Fprintln(os.Stderr, "  cancel-build stream-name")
 	fmt.Fprintln(os.Stderr, "  process-manifest manifestDir rootDir")
 }
 
@@ -73,6 +76,7 @@ var subcommands = []subcommand{
 	{"build-image", 1, 2, buildImageSubcommand},
 	{"build-raw-from-manifest", 2, 2, buildRawFromManifestSubcommand},
 	{"build-tree-from-manifest", 1, 1, buildTreeFromManifestSubcommand},
+	{"cancel-build", 1, 1, cancelBuildSubcommand},
 	{"process-manifest", 2, 2, processManifestSubcommand},
 }
 
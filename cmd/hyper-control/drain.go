@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func drainSubcommand(args []string, logger log.DebugLogger) error {
+	if err := drain(args[0], logger); err != nil {
+		return fmt.Errorf("Error draining Hypervisor: %s", err)
+	}
+	return nil
+}
+
+func drain(hostname string, logger log.DebugLogger) error {
+	clientName := fmt.Sprintf("%s:%d", *fleetManagerHostname,
+		*fleetManagerPortNum)
+	client, err := srpc.DialHTTPWithDialer("tcp", clientName, rrDialer)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	conn, err := client.Call("FleetManager.DrainHypervisor")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	request := proto.DrainHypervisorRequest{
+		Hostname: hostname,
+		Location: *location,
+	}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var reply proto.DrainHypervisorResponse
+		if err := conn.Decode(&reply); err != nil {
+			return err
+		}
+		if err := errors.New(reply.Error); err != nil {
+			return err
+		}
+		if reply.ProgressMessage != "" {
+			logger.Println(reply.ProgressMessage)
+		}
+		if reply.Final {
+			if len(reply.VmFailures) < 1 {
+				return nil
+			}
+			ipAddrs := make([]string, 0, len(reply.VmFailures))
+			for ipAddr := range reply.VmFailures {
+				ipAddrs = append(ipAddrs, ipAddr)
+			}
+			sort.Strings(ipAddrs)
+			for _, ipAddr := range ipAddrs {
+				logger.Printf("%s: %s\n", ipAddr, reply.VmFailures[ipAddr])
+			}
+			return fmt.Errorf("failed to migrate %d VMs", len(ipAddrs))
+		}
+	}
+}
@@ -116,7 +116,8 @@ func rolloutImage(imageName string, logger log.DebugLogger) error {
 	imageServerClientResource := srpc.NewClientResource("tcp",
 		fmt.Sprintf("%s:%d", *imageServerHostname, *imageServerPortNum))
 	defer imageServerClientResource.ScheduleClose()
-	expiresAt, err := checkImage(imageServerClientResource, imageName)
+	imageName, expiresAt, err := checkImage(imageServerClientResource,
+		imageName, logger)
 	if err != nil {
 		return err
 	}
@@ -242,21 +243,32 @@ func rolloutImage(imageName string, logger log.DebugLogger) error {
 	return nil
 }
 
+// checkImage will resolve the image name (pinning the image an alias refers
+// to) and will ensure the image does not expire during the rollout.
 func checkImage(imageServerClientResource *srpc.ClientResource,
-	imageName string) (time.Time, error) {
+	imageName string, logger log.DebugLogger) (string, time.Time, error) {
 	client, err := imageServerClientResource.GetHTTP(nil, 0)
 	if err != nil {
-		return time.Time{}, err
+		return "", time.Time{}, err
 	}
 	defer client.Put()
+	resolvedName, err := imageclient.ResolveImageName(client, imageName)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if resolvedName != imageName {
+		logger.Printf("alias: %s resolves to image: %s\n",
+			imageName, resolvedName)
+		imageName = resolvedName
+	}
 	expiresAt, err := imageclient.GetImageExpiration(client, imageName)
 	if err != nil {
-		return time.Time{}, err
+		return "", time.Time{}, err
 	}
 	if expiresAt.IsZero() {
-		return expiresAt, nil
+		return imageName, expiresAt, nil
 	}
-	return expiresAt,
+	return imageName, expiresAt,
 		imageclient.ChangeImageExpiration(client, imageName, expiresAt)
 }
 
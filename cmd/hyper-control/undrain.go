@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func undrainSubcommand(args []string, logger log.DebugLogger) error {
+	if err := undrain(args[0], logger); err != nil {
+		return fmt.Errorf("Error undraining Hypervisor: %s", err)
+	}
+	return nil
+}
+
+func undrain(hostname string, logger log.DebugLogger) error {
+	clientName := fmt.Sprintf("%s:%d", *fleetManagerHostname,
+		*fleetManagerPortNum)
+	client, err := srpc.DialHTTPWithDialer("tcp", clientName, rrDialer)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	request := proto.UndrainHypervisorRequest{Hostname: hostname}
+	var reply proto.UndrainHypervisorResponse
+	err = client.RequestReply("FleetManager.UndrainHypervisor", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
@@ -27,6 +27,11 @@ const (
 )
 
 var (
+	backupServerHostname = flag.String("backupServerHostname", "",
+		"Hostname of object server for VM backups (default image server)")
+	backupServerPortNum = flag.Uint("backupServerPortNum",
+		constants.ImageServerPortNumber,
+		"Port number of object server for VM backups")
 	dhcpServerOnBridgesOnly = flag.Bool("dhcpServerOnBridgesOnly", false,
 		"If true, run the DHCP server on bridge interfaces only")
 	imageServerHostname = flag.String("imageServerHostname", "localhost",
@@ -148,16 +153,22 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Cannot start tftpboot server: %s\n", err)
 	}
+	var backupServerAddress string
+	if *backupServerHostname != "" {
+		backupServerAddress = fmt.Sprintf("%s:%d",
+			*backupServerHostname, *backupServerPortNum)
+	}
 	managerObj, err := manager.New(manager.StartOptions{
-		ImageServerAddress: imageServerAddress,
-		DhcpServer:         dhcpServer,
-		Logger:             logger,
-		ObjectCacheBytes:   uint64(objectCacheSize),
-		ShowVgaConsole:     *showVGA,
-		StateDir:           *stateDir,
-		Username:           *username,
-		VlanIdToBridge:     vlanIdToBridge,
-		VolumeDirectories:  volumeDirectories,
+		BackupServerAddress: backupServerAddress,
+		ImageServerAddress:  imageServerAddress,
+		DhcpServer:          dhcpServer,
+		Logger:              logger,
+		ObjectCacheBytes:    uint64(objectCacheSize),
+		ShowVgaConsole:      *showVGA,
+		StateDir:            *stateDir,
+		Username:            *username,
+		VlanIdToBridge:      vlanIdToBridge,
+		VolumeDirectories:   volumeDirectories,
 	})
 	if err != nil {
 		logger.Fatalf("Cannot start hypervisor: %s\n", err)
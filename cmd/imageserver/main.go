@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Symantec/Dominator/imageserver/audit"
 	"github.com/Symantec/Dominator/imageserver/httpd"
+	"github.com/Symantec/Dominator/imageserver/retention"
 	imageserverRpcd "github.com/Symantec/Dominator/imageserver/rpcd"
 	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/imageserver/scrubber"
+	"github.com/Symantec/Dominator/imageserver/search"
 	"github.com/Symantec/Dominator/lib/constants"
 	"github.com/Symantec/Dominator/lib/flags/loadflags"
 	"github.com/Symantec/Dominator/lib/log/serverlogger"
@@ -68,24 +72,51 @@ func main() {
 		imageServerAddress = fmt.Sprintf("%s:%d", *imageServerHostname,
 			*imageServerPortNum)
 	}
+	auditLog, err := audit.Open(*imageDir, imageServerAddress != "", logger)
+	if err != nil {
+		logger.Fatalf("Cannot open audit log: %s\n", err)
+	}
 	imdb, err := scanner.LoadImageDataBase(*imageDir, objSrv,
-		imageServerAddress, logger)
+		imageServerAddress, auditLog, logger)
 	if err != nil {
 		logger.Fatalf("Cannot load image database: %s\n", err)
 	}
 	tricorder.RegisterMetric("/image-count",
 		func() uint { return imdb.CountImages() },
 		units.None, "number of images")
-	imgSrvRpcHtmlWriter, err := imageserverRpcd.Setup(imdb, imageServerAddress,
-		objSrv, logger)
+	searchIndex := search.New(imdb, logger)
+	imgSrvRpcHtmlWriter, err := imageserverRpcd.Setup(imdb, searchIndex,
+		auditLog, imageServerAddress, objSrv, logger)
+	if err != nil {
+		logger.Fatalln(err)
+	}
+	retentionManager, err := retention.Setup(imdb, auditLog,
+		imageServerAddress, logger)
 	if err != nil {
 		logger.Fatalln(err)
 	}
+	var objectSources []string
+	if imageServerAddress != "" {
+		objectSources = append(objectSources, imageServerAddress)
+	}
+	objectSources = append(objectSources, imageserverRpcd.ReplicationPeers()...)
+	objectScrubber := scrubber.New(imdb, objSrv, objectSources, auditLog,
+		logger)
 	objSrvRpcHtmlWriter := objectserverRpcd.Setup(objSrv, imageServerAddress,
 		logger)
 	httpd.AddHtmlWriter(imdb)
 	httpd.AddHtmlWriter(&imageObjectServersType{imdb, objSrv})
 	httpd.AddHtmlWriter(imgSrvRpcHtmlWriter)
+	if searchIndex != nil {
+		httpd.AddHtmlWriter(searchIndex)
+	}
+	httpd.AddHtmlWriter(auditLog)
+	if retentionManager != nil {
+		httpd.AddHtmlWriter(retentionManager)
+	}
+	if objectScrubber != nil {
+		httpd.AddHtmlWriter(objectScrubber)
+	}
 	httpd.AddHtmlWriter(objSrvRpcHtmlWriter)
 	httpd.AddHtmlWriter(logger)
 	if err = httpd.StartServer(*portNum, imdb, objSrv, false); err != nil {
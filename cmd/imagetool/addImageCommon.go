@@ -50,6 +50,30 @@ func addImage(imageSClient *srpc.Client, name string, img *image.Image) error {
 	return nil
 }
 
+// createUploadLease will create a lease which protects the objects uploaded
+// for a new image from the garbage collector. The caller should release the
+// lease once the image has been added.
+func createUploadLease(imageSClient *srpc.Client) (string, error) {
+	leaseId, _, err := client.CreateUploadLease(imageSClient, nil,
+		*uploadLeaseTimeout)
+	if err != nil {
+		return "", errors.New("error creating upload lease: " + err.Error())
+	}
+	return leaseId, nil
+}
+
+// protectObjects will add existing objects which the new image will use to the
+// upload lease.
+func protectObjects(imageSClient *srpc.Client, leaseId string,
+	objects []hash.Hash) error {
+	_, err := client.ExtendUploadLease(imageSClient, leaseId, objects,
+		*uploadLeaseTimeout)
+	if err != nil {
+		return errors.New("error extending upload lease: " + err.Error())
+	}
+	return nil
+}
+
 func (h *hasher) Hash(reader io.Reader, length uint64) (
 	hash.Hash, error) {
 	hash, err := h.objQ.Add(reader, length)
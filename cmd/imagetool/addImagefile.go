@@ -33,6 +33,11 @@ func addImagefile(imageSClient *srpc.Client,
 	if imageExists {
 		return errors.New("image exists")
 	}
+	leaseId, err := createUploadLease(imageSClient)
+	if err != nil {
+		return err
+	}
+	defer client.ReleaseUploadLease(imageSClient, leaseId)
 	newImage := new(image.Image)
 	if err := loadImageFiles(newImage, objectClient, filterFilename,
 		triggersFilename); err != nil {
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -32,6 +33,11 @@ func addImageimage(imageSClient *srpc.Client,
 	if imageExists {
 		return errors.New("image exists")
 	}
+	leaseId, err := createUploadLease(imageSClient)
+	if err != nil {
+		return err
+	}
+	defer client.ReleaseUploadLease(imageSClient, leaseId)
 	newImage := new(image.Image)
 	if err := loadImageFiles(newImage, objectClient, filterFilename,
 		triggersFilename); err != nil {
@@ -41,6 +47,13 @@ func addImageimage(imageSClient *srpc.Client,
 	if err != nil {
 		return err
 	}
+	objects := make([]hash.Hash, 0, len(fs.InodeTable))
+	for hashVal := range fs.GetObjects() {
+		objects = append(objects, hashVal)
+	}
+	if err := protectObjects(imageSClient, leaseId, objects); err != nil {
+		return err
+	}
 	if err := spliceComputedFiles(fs); err != nil {
 		return err
 	}
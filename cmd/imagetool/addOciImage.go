@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/filesystem/oci"
+	"github.com/Symantec/Dominator/lib/image"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func addOciImageSubcommand(args []string) {
+	imageSClient, objectClient := getClients()
+	err := addOciImage(imageSClient, objectClient, args[0], args[1], args[2],
+		args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error adding image: \"%s\": %s\n", args[0], err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func addOciImage(imageSClient *srpc.Client,
+	objectClient *objectclient.ObjectClient,
+	name, archiveFilename, filterFilename, triggersFilename string) error {
+	imageExists, err := client.CheckImage(imageSClient, name)
+	if err != nil {
+		return errors.New("error checking for image existence: " + err.Error())
+	}
+	if imageExists {
+		return errors.New("image exists")
+	}
+	leaseId, err := createUploadLease(imageSClient)
+	if err != nil {
+		return err
+	}
+	defer client.ReleaseUploadLease(imageSClient, leaseId)
+	newImage := new(image.Image)
+	if err := loadImageFiles(newImage, objectClient, filterFilename,
+		triggersFilename); err != nil {
+		return err
+	}
+	archiveFile, err := os.Open(archiveFilename)
+	if err != nil {
+		return err
+	}
+	defer archiveFile.Close()
+	var archiveReader io.Reader = bufio.NewReader(archiveFile)
+	if strings.HasSuffix(archiveFilename, ".gz") ||
+		strings.HasSuffix(archiveFilename, ".tgz") {
+		gzipReader, err := gzip.NewReader(archiveReader)
+		if err != nil {
+			return errors.New("error creating gzip reader: " + err.Error())
+		}
+		defer gzipReader.Close()
+		archiveReader = gzipReader
+	}
+	var h hasher
+	h.objQ, err = objectclient.NewObjectAdderQueue(imageSClient)
+	if err != nil {
+		return err
+	}
+	newImage.FileSystem, err = oci.Decode(archiveReader, &h, newImage.Filter,
+		"")
+	if err != nil {
+		h.objQ.Close()
+		return errors.New("error importing image: " + err.Error())
+	}
+	if err := h.objQ.Close(); err != nil {
+		return err
+	}
+	if err := spliceComputedFiles(newImage.FileSystem); err != nil {
+		return err
+	}
+	if err := copyMtimes(imageSClient, newImage, *copyMtimesFrom); err != nil {
+		return err
+	}
+	return addImage(imageSClient, name, newImage)
+}
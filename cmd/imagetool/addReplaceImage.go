@@ -52,6 +52,15 @@ func addReplaceImage(imageSClient *srpc.Client,
 		fmt.Fprintf(os.Stderr, "Skipping expiring image: %s\n", baseImageName)
 		return nil
 	}
+	leaseId, err := createUploadLease(imageSClient)
+	if err != nil {
+		return err
+	}
+	defer client.ReleaseUploadLease(imageSClient, leaseId)
+	err = protectObjects(imageSClient, leaseId, newImage.ListObjects())
+	if err != nil {
+		return err
+	}
 	for _, layerImageName := range layerImageNames {
 		fs, err := buildImage(imageSClient, newImage.Filter, layerImageName)
 		if err != nil {
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/verstr"
+)
+
+func listAliasesSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := listAliases(imageSClient); err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing aliases: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func listAliases(imageSClient *srpc.Client) error {
+	aliases, err := client.ListAliases(imageSClient)
+	if err != nil {
+		return err
+	}
+	sort.Slice(aliases, func(left, right int) bool {
+		return verstr.Less(aliases[left].Name, aliases[right].Name)
+	})
+	maxNameWidth := 0
+	for _, alias := range aliases {
+		if len(alias.Name) > maxNameWidth {
+			maxNameWidth = len(alias.Name)
+		}
+	}
+	for _, alias := range aliases {
+		fmt.Printf("%-*s  %s\n", maxNameWidth, alias.Name, alias.ImageName)
+	}
+	return nil
+}
+
+func setAliasSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	var ownerGroup string
+	if len(args) > 2 {
+		ownerGroup = args[2]
+	}
+	err := client.SetImageAlias(imageSClient, args[0], args[1], ownerGroup)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting alias: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func showAliasSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := showAlias(imageSClient, args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error showing alias: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func showAlias(imageSClient *srpc.Client, aliasName string) error {
+	alias, err := client.GetImageAlias(imageSClient, aliasName)
+	if err != nil {
+		return err
+	}
+	if alias == nil {
+		return errors.New("alias: " + aliasName + " does not exist")
+	}
+	fmt.Printf("Image:       %s\n", alias.ImageName)
+	fmt.Printf("Owner group: %s\n", alias.OwnerGroup)
+	fmt.Println("History:")
+	for index := len(alias.History) - 1; index >= 0; index-- {
+		change := alias.History[index]
+		fmt.Printf("  %s  %-16s %s\n",
+			change.MovedAt.In(time.Local).Format(time.RFC3339),
+			change.MovedBy, change.ImageName)
+	}
+	return nil
+}
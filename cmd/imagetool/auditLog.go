@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func showAuditLogSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := showAuditLog(imageSClient, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error showing audit log: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func showAuditLog(imageSClient *srpc.Client, terms []string) error {
+	var request imageserver.GetAuditLogRequest
+	for _, term := range terms {
+		splitTerm := strings.SplitN(term, "=", 2)
+		if len(splitTerm) != 2 {
+			return errors.New("bad filter: " + term)
+		}
+		switch splitTerm[0] {
+		case "name":
+			request.Name = splitTerm[1]
+		case "op":
+			request.Operation = splitTerm[1]
+		case "start":
+			start, err := strconv.ParseUint(splitTerm[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			request.StartSequence = start
+		case "user":
+			request.Username = splitTerm[1]
+		default:
+			return errors.New("unknown filter key: " + splitTerm[0])
+		}
+	}
+	entries, err := client.GetAuditLog(imageSClient, request)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		outcome := "OK"
+		if entry.Error != "" {
+			outcome = "error: " + entry.Error
+		}
+		fmt.Printf("%6d %s %s@%s %s(%s)", entry.Sequence,
+			entry.Time.In(time.Local).Format(time.RFC3339), entry.Username,
+			entry.Address, entry.Operation, entry.Name)
+		if entry.Detail != "" {
+			fmt.Printf(" %s", entry.Detail)
+		}
+		fmt.Printf(": %s\n", outcome)
+	}
+	return nil
+}
@@ -30,5 +30,11 @@ func copyImage(imageSClient *srpc.Client, name, oldImageName string) error {
 	if err != nil {
 		return err
 	}
+	leaseId, _, err := client.CreateUploadLease(imageSClient,
+		image.ListObjects(), *uploadLeaseTimeout)
+	if err != nil {
+		return errors.New("error creating upload lease: " + err.Error())
+	}
+	defer client.ReleaseUploadLease(imageSClient, leaseId)
 	return addImage(imageSClient, name, image)
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func diffImagesOnServerSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := diffImagesOnServer(imageSClient, args[0], args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error diffing images: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func diffImagesOnServer(imageSClient *srpc.Client, leftName,
+	rightName string) error {
+	result, err := client.DiffImages(imageSClient, leftName, rightName)
+	if err != nil {
+		return err
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", result)
+}
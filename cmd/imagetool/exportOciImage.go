@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/filesystem/oci"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+)
+
+func exportOciImageSubcommand(args []string) {
+	_, objectClient := getClients()
+	reference := ""
+	if len(args) > 2 {
+		reference = args[2]
+	}
+	err := exportOciImage(objectClient, args[0], args[1], reference)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting image: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func exportOciImage(objectClient *objectclient.ObjectClient, imageName,
+	outputFilename, reference string) error {
+	fs, objectsGetter, err := getImageForUnpack(objectClient, imageName)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(outputFilename)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	err = oci.Encode(writer, fs, objectsGetter, reference, "")
+	if err == nil {
+		err = writer.Flush()
+	}
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(outputFilename)
+	}
+	return err
+}
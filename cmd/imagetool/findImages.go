@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func findImagesSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := findImages(imageSClient, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error finding images: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func findImages(imageSClient *srpc.Client, terms []string) error {
+	request, err := parseFindTerms(terms)
+	if err != nil {
+		return err
+	}
+	images, err := client.FindImages(imageSClient, request)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		fmt.Println(image.Name)
+		for _, pkg := range image.Packages {
+			fmt.Printf("  package: %s %s\n", pkg.Name, pkg.Version)
+		}
+		for _, path := range image.Paths {
+			fmt.Printf("  path:    %s\n", path)
+		}
+	}
+	return nil
+}
+
+func parseFindTerms(terms []string) (imageserver.FindImagesRequest, error) {
+	var request imageserver.FindImagesRequest
+	for _, term := range terms {
+		splitTerm := strings.SplitN(term, "=", 2)
+		if len(splitTerm) != 2 {
+			return request, errors.New("bad search term: " + term)
+		}
+		value := splitTerm[1]
+		var err error
+		switch splitTerm[0] {
+		case "after":
+			request.CreatedAfter, err = parseFindTime(value)
+		case "before":
+			request.CreatedBefore, err = parseFindTime(value)
+		case "createdBy":
+			request.CreatedBy = value
+		case "hash":
+			var hashVal hash.Hash
+			hashVal, err = objectcache.FilenameToHash(value)
+			request.ObjectHash = &hashVal
+		case "package":
+			request.PackageName = value
+		case "path":
+			request.PathPattern = value
+		case "version":
+			request.PackageVersion = value
+		default:
+			return request, errors.New("unknown search key: " + splitTerm[0])
+		}
+		if err != nil {
+			return request, err
+		}
+	}
+	return request, nil
+}
+
+func parseFindTime(value string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/image"
 	"github.com/Symantec/Dominator/lib/srpc"
 )
@@ -31,12 +32,24 @@ func listDirectories(imageSClient *srpc.Client) error {
 		}
 	}
 	for _, directory := range directories {
-		if directory.Metadata.OwnerGroup == "" {
+		metadata := directory.Metadata
+		if metadata.OwnerGroup == "" && metadata.SoftQuotaBytes < 1 &&
+			metadata.HardQuotaBytes < 1 {
 			fmt.Println(directory.Name)
 			continue
 		}
-		fmt.Printf("%-*s  ", maxDirnameWidth, directory.Name)
-		fmt.Printf("OwnerGroup=%s", directory.Metadata.OwnerGroup)
+		fmt.Printf("%-*s ", maxDirnameWidth, directory.Name)
+		if metadata.OwnerGroup != "" {
+			fmt.Printf(" OwnerGroup=%s", metadata.OwnerGroup)
+		}
+		if metadata.SoftQuotaBytes > 0 {
+			fmt.Printf(" SoftQuota=%s",
+				format.FormatBytes(metadata.SoftQuotaBytes))
+		}
+		if metadata.HardQuotaBytes > 0 {
+			fmt.Printf(" HardQuota=%s",
+				format.FormatBytes(metadata.HardQuotaBytes))
+		}
 		fmt.Println()
 	}
 	return nil
@@ -60,6 +60,8 @@ var (
 	tableType mbr.TableType = mbr.TABLE_TYPE_MSDOS
 	timeout                 = flag.Duration("timeout", 0,
 		"Timeout for get subcommand")
+	uploadLeaseTimeout = flag.Duration("uploadLeaseTimeout", time.Hour,
+		"Time to protect uploaded objects from garbage collection")
 
 	logger            log.DebugLogger
 	minimumExpiration = 15 * time.Minute
@@ -78,6 +80,7 @@ func printUsage() {
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr, "Commands:")
 	fmt.Fprintln(os.Stderr, "  add    name imagefile filterfile triggerfile")
+	fmt.Fprintln(os.Stderr, "  add-oci name archivefile filterfile triggerfile")
 	fmt.Fprintln(os.Stderr, "  addi   name imagename filterfile triggerfile")
 	fmt.Fprintln(os.Stderr, "  addrep name baseimage layerimage...")
 	fmt.Fprintln(os.Stderr, "  adds   name subname filterfile triggerfile")
@@ -97,13 +100,19 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "           i: name of an image on the imageserver")
 	fmt.Fprintln(os.Stderr, "           l: name of file containing an Image")
 	fmt.Fprintln(os.Stderr, "           s: name of sub to poll")
+	fmt.Fprintln(os.Stderr, "  diff-on-server      left right")
 	fmt.Fprintln(os.Stderr, "  estimate-usage      name")
+	fmt.Fprintln(os.Stderr, "  export-oci          name file [reference]")
+	fmt.Fprintln(os.Stderr, "  find                key=value...")
+	fmt.Fprintln(os.Stderr,
+		"         keys: after, before, createdBy, hash, package, path, version")
 	fmt.Fprintln(os.Stderr, "  find-latest-image   directory")
 	fmt.Fprintln(os.Stderr, "  get                 name directory")
 	fmt.Fprintln(os.Stderr, "  get-archive-data    name outfile")
 	fmt.Fprintln(os.Stderr, "  get-file-in-image   name imageFile [outfile]")
 	fmt.Fprintln(os.Stderr, "  get-image-expiration name")
 	fmt.Fprintln(os.Stderr, "  list")
+	fmt.Fprintln(os.Stderr, "  listaliases")
 	fmt.Fprintln(os.Stderr, "  listdirs")
 	fmt.Fprintln(os.Stderr, "  listunrefobj")
 	fmt.Fprintln(os.Stderr, "  make-raw-image      name rawfile")
@@ -111,10 +120,17 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  merge-filters       filter-file...")
 	fmt.Fprintln(os.Stderr, "  merge-triggers      triggers-file...")
 	fmt.Fprintln(os.Stderr, "  mkdir               name")
+	fmt.Fprintln(os.Stderr, "  set-alias           alias name [ownerGroup]")
+	fmt.Fprintln(os.Stderr, "  set-quota           dirname softQuota hardQuota")
 	fmt.Fprintln(os.Stderr, "  show                name")
+	fmt.Fprintln(os.Stderr, "  show-alias          alias")
+	fmt.Fprintln(os.Stderr, "  show-audit-log      [key=value...]")
+	fmt.Fprintln(os.Stderr, "         keys: name, start, user")
+	fmt.Fprintln(os.Stderr, "  show-usage")
 	fmt.Fprintln(os.Stderr, "  showunrefobj")
 	fmt.Fprintln(os.Stderr, "  tar                 name [file]")
 	fmt.Fprintln(os.Stderr, "  test-download-speed name")
+	fmt.Fprintln(os.Stderr, "  verify-objects      [name...]")
 	fmt.Fprintln(os.Stderr, "Fields:")
 	fmt.Fprintln(os.Stderr, "  m: mode")
 	fmt.Fprintln(os.Stderr, "  l: number of hardlinks")
@@ -137,6 +153,7 @@ type subcommand struct {
 
 var subcommands = []subcommand{
 	{"add", 4, 4, addImagefileSubcommand},
+	{"add-oci", 4, 4, addOciImageSubcommand},
 	{"addi", 4, 4, addImageimageSubcommand},
 	{"addrep", 3, -1, addReplaceImageSubcommand},
 	{"adds", 4, 4, addImagesubSubcommand},
@@ -149,13 +166,17 @@ var subcommands = []subcommand{
 	{"delete", 1, 1, deleteImageSubcommand},
 	{"delunrefobj", 2, 2, deleteUnreferencedObjectsSubcommand},
 	{"diff", 3, 3, diffSubcommand},
+	{"diff-on-server", 2, 2, diffImagesOnServerSubcommand},
 	{"estimate-usage", 1, 1, estimateImageUsageSubcommand},
+	{"export-oci", 2, 3, exportOciImageSubcommand},
+	{"find", 1, -1, findImagesSubcommand},
 	{"find-latest-image", 1, 1, findLatestImageSubcommand},
 	{"get", 2, 2, getImageSubcommand},
 	{"get-archive-data", 2, 2, getImageArchiveDataSubcommand},
 	{"get-file-in-image", 2, 3, getFileInImageSubcommand},
 	{"get-image-expiration", 1, 1, getImageExpirationSubcommand},
 	{"list", 0, 0, listImagesSubcommand},
+	{"listaliases", 0, 0, listAliasesSubcommand},
 	{"listdirs", 0, 0, listDirectoriesSubcommand},
 	{"listunrefobj", 0, 0, listUnreferencedObjectsSubcommand},
 	{"make-raw-image", 2, 2, makeRawImageSubcommand},
@@ -163,10 +184,16 @@ var subcommands = []subcommand{
 	{"merge-filters", 1, -1, mergeFiltersSubcommand},
 	{"merge-triggers", 1, -1, mergeTriggersSubcommand},
 	{"mkdir", 1, 1, makeDirectorySubcommand},
+	{"set-alias", 2, 3, setAliasSubcommand},
+	{"set-quota", 3, 3, setQuotaSubcommand},
 	{"show", 1, 1, showImageSubcommand},
+	{"show-alias", 1, 1, showAliasSubcommand},
+	{"show-audit-log", 0, -1, showAuditLogSubcommand},
+	{"show-usage", 0, 0, showUsageSubcommand},
 	{"showunrefobj", 0, 0, showUnreferencedObjectsSubcommand},
 	{"tar", 1, 2, tarImageSubcommand},
 	{"test-download-speed", 1, 1, testDownloadSpeedSubcommand},
+	{"verify-objects", 0, -1, verifyObjectsSubcommand},
 }
 
 var imageSrpcClient *srpc.Client
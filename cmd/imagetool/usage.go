@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/flagutil"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func setQuotaSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := setQuota(imageSClient, args[0], args[1], args[2]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting quota: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func setQuota(imageSClient *srpc.Client, dirname, softQuota,
+	hardQuota string) error {
+	var softQuotaBytes, hardQuotaBytes flagutil.Size
+	if err := softQuotaBytes.Set(softQuota); err != nil {
+		return err
+	}
+	if err := hardQuotaBytes.Set(hardQuota); err != nil {
+		return err
+	}
+	return client.SetDirectoryQuota(imageSClient, dirname,
+		uint64(softQuotaBytes), uint64(hardQuotaBytes))
+}
+
+func showUsageSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := showUsage(imageSClient); err != nil {
+		fmt.Fprintf(os.Stderr, "Error showing usage: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func showUsage(imageSClient *srpc.Client) error {
+	usage, err := client.GetUsage(imageSClient)
+	if err != nil {
+		return err
+	}
+	maxNameWidth := len("Directory")
+	for _, directory := range usage.Directories {
+		if len(directory.Name) > maxNameWidth {
+			maxNameWidth = len(directory.Name)
+		}
+	}
+	for _, group := range usage.Groups {
+		if len(group.Name) > maxNameWidth {
+			maxNameWidth = len(group.Name)
+		}
+	}
+	writeUsageHeader(maxNameWidth, "Directory")
+	for _, directory := range usage.Directories {
+		writeUsage(maxNameWidth, directory.Name, directory.Usage)
+		if directory.SoftQuotaBytes > 0 || directory.HardQuotaBytes > 0 {
+			fmt.Printf("  soft=%s hard=%s",
+				formatQuota(directory.SoftQuotaBytes),
+				formatQuota(directory.HardQuotaBytes))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	writeUsageHeader(maxNameWidth, "Group")
+	for _, group := range usage.Groups {
+		name := group.Name
+		if name == "" {
+			name = "(none)"
+		}
+		writeUsage(maxNameWidth, name, group.Usage)
+		fmt.Println()
+	}
+	return nil
+}
+
+func formatQuota(quota uint64) string {
+	if quota < 1 {
+		return "none"
+	}
+	return format.FormatBytes(quota)
+}
+
+func writeUsageHeader(nameWidth int, name string) {
+	fmt.Printf("%-*s  %6s  %10s  %10s  %10s\n", nameWidth, name,
+		"Images", "FairShare", "Unique", "Shared")
+}
+
+func writeUsage(nameWidth int, name string, usage imageserver.Usage) {
+	fmt.Printf("%-*s  %6d  %10s  %10s  %10s", nameWidth, name,
+		usage.NumImages, format.FormatBytes(usage.FairShareBytes),
+		format.FormatBytes(usage.UniqueBytes),
+		format.FormatBytes(usage.SharedBytes))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func verifyObjectsSubcommand(args []string) {
+	imageSClient, _ := getClients()
+	if err := verifyObjects(imageSClient, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error verifying objects: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func verifyObjects(imageSClient *srpc.Client, imageNames []string) error {
+	result, err := client.VerifyImageObjects(imageSClient, imageNames)
+	if err != nil {
+		return err
+	}
+	for _, image := range result.Images {
+		fmt.Printf("%s: %d missing objects\n",
+			image.ImageName, len(image.MissingObjects))
+		for _, hashVal := range image.MissingObjects {
+			fmt.Printf("  %x\n", hashVal)
+		}
+	}
+	if len(result.Images) > 0 {
+		return fmt.Errorf("%d of %d images have missing objects",
+			len(result.Images), result.NumImages)
+	}
+	fmt.Fprintf(os.Stderr, "Verified %d objects in %d images\n",
+		result.NumObjects, result.NumImages)
+	return nil
+}
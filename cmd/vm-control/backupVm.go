@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func backupVmSubcommand(args []string, logger log.DebugLogger) error {
+	if err := backupVm(args[0], logger); err != nil {
+		return fmt.Errorf("Error backing up VM: %s", err)
+	}
+	return nil
+}
+
+func backupVm(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return backupVmOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func backupVmOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.BackupVmRequest{
+		ForceIfNotStopped:   *forceIfNotStopped,
+		ImageName:           *backupImageName,
+		IpAddress:           ipAddr,
+		ObjectServerAddress: *backupServer,
+		SnapshotName:        *snapshotName,
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.BackupVmResponse
+	err = client.RequestReply("Hypervisor.BackupVm", request, &reply)
+	if err != nil {
+		return err
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	fmt.Printf("%x\n", reply.Hash)
+	return nil
+}
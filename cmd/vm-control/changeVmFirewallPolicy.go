@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func changeVmFirewallPolicySubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := changeVmFirewallPolicy(args[0], logger); err != nil {
+		return fmt.Errorf("Error changing VM firewall policy: %s", err)
+	}
+	return nil
+}
+
+func changeVmFirewallPolicy(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return changeVmFirewallPolicyOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func changeVmFirewallPolicyOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.ChangeVmFirewallPolicyRequest{
+		DryRun:    *dryRun,
+		IpAddress: ipAddr,
+	}
+	if policy := makeFirewallPolicyFromFlags(); policy != nil {
+		request.FirewallPolicy = *policy
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ChangeVmFirewallPolicyResponse
+	err = client.RequestReply("Hypervisor.ChangeVmFirewallPolicy", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return err
+	}
+	if *dryRun {
+		printFirewallRuleset(reply.Ruleset)
+	}
+	return nil
+}
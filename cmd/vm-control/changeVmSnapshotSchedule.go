@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func changeVmSnapshotScheduleSubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := changeVmSnapshotSchedule(args[0], logger); err != nil {
+		return fmt.Errorf("Error changing VM snapshot schedule: %s", err)
+	}
+	return nil
+}
+
+func changeVmSnapshotSchedule(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return changeVmSnapshotScheduleOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func changeVmSnapshotScheduleOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.ChangeVmSnapshotScheduleRequest{
+		IpAddress: ipAddr,
+		SnapshotSchedule: proto.SnapshotSchedule{
+			Backup:               *backupSnapshots,
+			BackupImageDirectory: *backupImageDirectory,
+			Interval:             *snapshotInterval,
+			NumToRetain:          *snapshotsToRetain,
+		},
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ChangeVmSnapshotScheduleResponse
+	err = client.RequestReply("Hypervisor.ChangeVmSnapshotSchedule", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
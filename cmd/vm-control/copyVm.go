@@ -59,10 +59,6 @@ func callCopyVm(client *srpc.Client, request hyper_proto.CopyVmRequest,
 
 func copyVmFromHypervisor(sourceHypervisorAddress string, vmIP net.IP,
 	logger log.DebugLogger) error {
-	destHypervisorAddress, err := getHypervisorAddress()
-	if err != nil {
-		return err
-	}
 	sourceHypervisor, err := dialHypervisor(sourceHypervisorAddress)
 	if err != nil {
 		return err
@@ -73,9 +69,15 @@ func copyVmFromHypervisor(sourceHypervisorAddress string, vmIP net.IP,
 		return err
 	}
 	vmInfo := createVmInfoFromFlags()
+	if vmInfo.CloudInitDatasource == hyper_proto.CloudInitDatasourceNone {
+		vmInfo.CloudInitDatasource = sourceVmInfo.CloudInitDatasource
+	}
 	vmInfo.ConsoleType = sourceVmInfo.ConsoleType
 	vmInfo.DestroyProtection = vmInfo.DestroyProtection ||
 		sourceVmInfo.DestroyProtection
+	if vmInfo.FirewallPolicy == nil {
+		vmInfo.FirewallPolicy = sourceVmInfo.FirewallPolicy
+	}
 	if vmInfo.Hostname == "" {
 		vmInfo.Hostname = sourceVmInfo.Hostname
 	}
@@ -94,12 +96,24 @@ func copyVmFromHypervisor(sourceHypervisorAddress string, vmIP net.IP,
 	if len(vmInfo.Tags) < 1 {
 		vmInfo.Tags = sourceVmInfo.Tags
 	}
+	if len(vmInfo.PlacementGroups) < 1 {
+		vmInfo.PlacementGroups = sourceVmInfo.PlacementGroups
+	}
 	if len(vmInfo.SecondarySubnetIDs) < 1 {
 		vmInfo.SecondarySubnetIDs = sourceVmInfo.SecondarySubnetIDs
 	}
+	vmInfo.SshPublicKeys = sourceVmInfo.SshPublicKeys
 	if vmInfo.SubnetId == "" {
 		vmInfo.SubnetId = sourceVmInfo.SubnetId
 	}
+	placementVmInfo := vmInfo
+	placementVmInfo.SpreadVolumes = sourceVmInfo.SpreadVolumes
+	placementVmInfo.Volumes = sourceVmInfo.Volumes
+	destHypervisorAddress, err := getHypervisorAddress(placementVmInfo,
+		logger)
+	if err != nil {
+		return err
+	}
 	accessToken, err := getVmAccessTokenClient(sourceHypervisor, vmIP)
 	if err != nil {
 		return err
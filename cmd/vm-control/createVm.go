@@ -12,6 +12,7 @@ import (
 
 	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
 	"github.com/Symantec/Dominator/lib/flagutil"
+	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/tags"
@@ -90,7 +91,19 @@ func createVm(logger log.DebugLogger) error {
 			vmTags["Name"] = *vmHostname
 		}
 	}
-	if hypervisor, err := getHypervisorAddress(); err != nil {
+	vmInfo := createVmInfoFromFlags()
+	if vmInfo.MemoryInMiB < 1 {
+		vmInfo.MemoryInMiB = 1024
+	}
+	if vmInfo.MilliCPUs < 1 {
+		vmInfo.MilliCPUs = 250
+	}
+	if sizes, err := parseSizes(secondaryVolumeSizes); err != nil {
+		return err
+	} else {
+		vmInfo.Volumes = append([]hyper_proto.Volume{{}}, sizes...)
+	}
+	if hypervisor, err := getHypervisorAddress(vmInfo, logger); err != nil {
 		return err
 	} else {
 		logger.Debugf(0, "creating VM on %s\n", hypervisor)
@@ -100,17 +113,20 @@ func createVm(logger log.DebugLogger) error {
 
 func createVmInfoFromFlags() hyper_proto.VmInfo {
 	return hyper_proto.VmInfo{
-		ConsoleType:        consoleType,
-		DestroyProtection:  *destroyProtection,
-		DisableVirtIO:      *disableVirtIO,
-		Hostname:           *vmHostname,
-		MemoryInMiB:        uint64(memory >> 20),
-		MilliCPUs:          *milliCPUs,
-		OwnerGroups:        ownerGroups,
-		OwnerUsers:         ownerUsers,
-		Tags:               vmTags,
-		SecondarySubnetIDs: secondarySubnetIDs,
-		SubnetId:           *subnetId,
+		CloudInitDatasource: cloudInitDatasource,
+		ConsoleType:         consoleType,
+		DestroyProtection:   *destroyProtection,
+		DisableVirtIO:       *disableVirtIO,
+		FirewallPolicy:      makeFirewallPolicyFromFlags(),
+		Hostname:            *vmHostname,
+		MemoryInMiB:         uint64(memory >> 20),
+		MilliCPUs:           *milliCPUs,
+		OwnerGroups:         ownerGroups,
+		OwnerUsers:          ownerUsers,
+		PlacementGroups:     placementGroups,
+		Tags:                vmTags,
+		SecondarySubnetIDs:  secondarySubnetIDs,
+		SubnetId:            *subnetId,
 	}
 }
 
@@ -149,6 +165,13 @@ func createVmOnHypervisor(hypervisor string, logger log.DebugLogger) error {
 				IpAddress: ipAddr}
 		}
 	}
+	if *sshPublicKeysFile != "" {
+		keys, err := fsutil.LoadLines(*sshPublicKeysFile)
+		if err != nil {
+			return err
+		}
+		request.SshPublicKeys = keys
+	}
 	if sizes, err := parseSizes(secondaryVolumeSizes); err != nil {
 		return err
 	} else {
@@ -207,10 +230,30 @@ func createVmOnHypervisor(hypervisor string, logger log.DebugLogger) error {
 	return maybeWatchVm(client, hypervisor, reply.IpAddress, logger)
 }
 
-func getHypervisorAddress() (string, error) {
+// getHypervisorAddress will return the address of the Hypervisor to place
+// the VM on. Unless a Hypervisor or an adjacent VM was specified, the Fleet
+// Manager is asked to select the best Hypervisor for the VM. Otherwise, if the
+// VM is in placement groups, the Fleet Manager (if specified) is asked to check
+// that the placement does not violate a strict group.
+func getHypervisorAddress(vmInfo hyper_proto.VmInfo,
+	logger log.DebugLogger) (string, error) {
 	if *hypervisorHostname != "" {
-		return fmt.Sprintf("%s:%d", *hypervisorHostname, *hypervisorPortNum),
-			nil
+		address := fmt.Sprintf("%s:%d", *hypervisorHostname,
+			*hypervisorPortNum)
+		if len(vmInfo.PlacementGroups) < 1 || *fleetManagerHostname == "" {
+			return address, nil
+		}
+		client, err := dialFleetManager(fmt.Sprintf("%s:%d",
+			*fleetManagerHostname, *fleetManagerPortNum))
+		if err != nil {
+			return "", err
+		}
+		defer client.Close()
+		err = checkVmPlacement(client, *hypervisorHostname, vmInfo, logger)
+		if err != nil {
+			return "", err
+		}
+		return address, nil
 	}
 	client, err := dialFleetManager(fmt.Sprintf("%s:%d",
 		*fleetManagerHostname, *fleetManagerPortNum))
@@ -219,18 +262,32 @@ func getHypervisorAddress() (string, error) {
 	}
 	defer client.Close()
 	if *adjacentVM != "" {
-		if adjacentVmIpAddr, err := lookupIP(*adjacentVM); err != nil {
+		adjacentVmIpAddr, err := lookupIP(*adjacentVM)
+		if err != nil {
+			return "", err
+		}
+		address, err := findHypervisorClient(client, adjacentVmIpAddr)
+		if err != nil {
 			return "", err
-		} else {
-			return findHypervisorClient(client, adjacentVmIpAddr)
 		}
+		hostname, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return "", err
+		}
+		err = checkVmPlacement(client, hostname, vmInfo, logger)
+		if err != nil {
+			return "", err
+		}
+		return address, nil
 	}
-	request := fm_proto.ListHypervisorsInLocationRequest{
-		Location: *location,
-		SubnetId: *subnetId,
+	request := fm_proto.SelectHypervisorsForVMRequest{
+		AntiAffinityTags: antiAffinityTags,
+		Location:         *location,
+		SpreadTags:       spreadTags,
+		VmInfo:           vmInfo,
 	}
-	var reply fm_proto.ListHypervisorsInLocationResponse
-	err = client.RequestReply("FleetManager.ListHypervisorsInLocation",
+	var reply fm_proto.SelectHypervisorsForVMResponse
+	err = client.RequestReply("FleetManager.SelectHypervisorsForVM",
 		request, &reply)
 	if err != nil {
 		return "", err
@@ -238,13 +295,17 @@ func getHypervisorAddress() (string, error) {
 	if reply.Error != "" {
 		return "", errors.New(reply.Error)
 	}
-	if numHyper := len(reply.HypervisorAddresses); numHyper < 1 {
-		return "", errors.New("no active Hypervisors in location")
-	} else if numHyper < 2 {
-		return reply.HypervisorAddresses[0], nil
-	} else {
-		return reply.HypervisorAddresses[rand.Intn(numHyper-1)], nil
+	if len(reply.HypervisorAddresses) < 1 {
+		for address, reason := range reply.Rejected {
+			logger.Printf("%s: %s\n", address, reason)
+		}
+		return "", errors.New("no suitable Hypervisors in location")
+	}
+	address := reply.HypervisorAddresses[0]
+	if warning := reply.Warnings[address]; warning != "" {
+		logger.Printf("warning: %s: %s\n", address, warning)
 	}
+	return address, nil
 }
 
 func getReader(filename string) (io.ReadCloser, int64, error) {
@@ -26,7 +26,7 @@ func discardVmSnapshot(vmHostname string, logger log.DebugLogger) error {
 
 func discardVmSnapshotOnHypervisor(hypervisor string, ipAddr net.IP,
 	logger log.DebugLogger) error {
-	request := proto.DiscardVmSnapshotRequest{ipAddr}
+	request := proto.DiscardVmSnapshotRequest{ipAddr, *snapshotName}
 	client, err := dialHypervisor(hypervisor)
 	if err != nil {
 		return err
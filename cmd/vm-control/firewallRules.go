@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// firewallRuleList implements the flag.Value interface. Each rule is
+// specified as protocol[:port[-port]][@network] and rules are separated by
+// commas. The protocol may be empty to match all protocols.
+type firewallRuleList []hyper_proto.FirewallRule
+
+func (list *firewallRuleList) String() string {
+	rules := make([]string, 0, len(*list))
+	for _, rule := range *list {
+		text := rule.Protocol
+		if rule.FromPort != 0 {
+			text += fmt.Sprintf(":%d", rule.FromPort)
+			if rule.ToPort > rule.FromPort {
+				text += fmt.Sprintf("-%d", rule.ToPort)
+			}
+		}
+		if rule.RemoteNetwork != "" {
+			text += "@" + rule.RemoteNetwork
+		}
+		rules = append(rules, text)
+	}
+	return strings.Join(rules, ",")
+}
+
+func (list *firewallRuleList) Set(value string) error {
+	var rules firewallRuleList
+	for _, text := range strings.Split(value, ",") {
+		if text == "" {
+			continue
+		}
+		var rule hyper_proto.FirewallRule
+		if index := strings.IndexByte(text, '@'); index >= 0 {
+			rule.RemoteNetwork = text[index+1:]
+			text = text[:index]
+		}
+		fields := strings.Split(text, ":")
+		if len(fields) > 2 {
+			return fmt.Errorf("invalid firewall rule: %s", text)
+		}
+		rule.Protocol = fields[0]
+		if len(fields) > 1 {
+			ports := strings.Split(fields[1], "-")
+			if len(ports) > 2 {
+				return fmt.Errorf("invalid port range: %s", fields[1])
+			}
+			fromPort, err := strconv.ParseUint(ports[0], 10, 16)
+			if err != nil {
+				return err
+			}
+			rule.FromPort = uint16(fromPort)
+			if len(ports) > 1 {
+				toPort, err := strconv.ParseUint(ports[1], 10, 16)
+				if err != nil {
+					return err
+				}
+				rule.ToPort = uint16(toPort)
+			}
+		}
+		if err := rule.CheckValid(); err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+	*list = rules
+	return nil
+}
+
+func makeFirewallPolicyFromFlags() *hyper_proto.FirewallPolicy {
+	if !*filterEgress && !*filterIngress &&
+		len(firewallEgressRules) < 1 && len(firewallIngressRules) < 1 {
+		return nil
+	}
+	return &hyper_proto.FirewallPolicy{
+		EgressRules:   firewallEgressRules,
+		FilterEgress:  *filterEgress,
+		FilterIngress: *filterIngress,
+		IngressRules:  firewallIngressRules,
+	}
+}
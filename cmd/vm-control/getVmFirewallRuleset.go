@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func getVmFirewallRulesetSubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := getVmFirewallRuleset(args[0], logger); err != nil {
+		return fmt.Errorf("Error getting VM firewall ruleset: %s", err)
+	}
+	return nil
+}
+
+func getVmFirewallRuleset(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return getVmFirewallRulesetOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func getVmFirewallRulesetOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	request := proto.GetVmFirewallRulesetRequest{IpAddress: ipAddr}
+	var reply proto.GetVmFirewallRulesetResponse
+	err = client.RequestReply("Hypervisor.GetVmFirewallRuleset", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return err
+	}
+	printFirewallRuleset(reply.Ruleset)
+	return nil
+}
+
+func printFirewallRuleset(ruleset string) {
+	if ruleset == "" {
+		fmt.Println("No filtering")
+	} else {
+		fmt.Print(ruleset)
+	}
+}
@@ -21,13 +21,33 @@ import (
 var (
 	adjacentVM = flag.String("adjacentVM", "",
 		"IP address of VM adjacent (same Hypervisor) to VM being created")
-	consoleType       hyper_proto.ConsoleType
-	destroyProtection = flag.Bool("destroyProtection", false,
+	antiAffinityTags     flagutil.StringList
+	backupImageDirectory = flag.String("backupImageDirectory", "",
+		"Image directory to add scheduled backups to")
+	backupImageName = flag.String("backupImageName", "",
+		"Name of image to add backup to")
+	backupServer = flag.String("backupServer", "",
+		"Address of object server to back up to (default from Hypervisor)")
+	backupSnapshots = flag.Bool("backupSnapshots", false,
+		"If true, back up scheduled snapshots")
+	cloudInitDatasource hyper_proto.CloudInitDatasource
+	consoleType         hyper_proto.ConsoleType
+	destroyProtection   = flag.Bool("destroyProtection", false,
 		"If true, do not destroy running VM")
 	disableVirtIO = flag.Bool("disableVirtIO", false,
 		"If true, disable virtio drivers, reducing I/O performance")
 	dhcpTimeout = flag.Duration("dhcpTimeout", time.Minute,
 		"Time to wait before timing out on DHCP request from VM")
+	enableMemoryBalloon = flag.Bool("enableMemoryBalloon", false,
+		"If true, add the memory balloon device when starting an older VM")
+	dryRun = flag.Bool("dryRun", false,
+		"If true, show the effective firewall ruleset without changing it")
+	filterEgress = flag.Bool("filterEgress", false,
+		"If true, drop egress traffic not permitted by a firewall rule")
+	filterIngress = flag.Bool("filterIngress", false,
+		"If true, drop ingress traffic not permitted by a firewall rule")
+	firewallEgressRules  firewallRuleList
+	firewallIngressRules firewallRuleList
 	fleetManagerHostname = flag.String("fleetManagerHostname", "",
 		"Hostname of Fleet Manager")
 	fleetManagerPortNum = flag.Uint("fleetManagerPortNum",
@@ -54,13 +74,23 @@ var (
 		"Command to destroy local VM when exporting. The VM name is given as the argument")
 	location = flag.String("location", "",
 		"Location to search for hypervisors")
-	memory       flagutil.Size
-	milliCPUs    = flag.Uint("milliCPUs", 0, "milli CPUs (default 250)")
-	minFreeBytes = flagutil.Size(256 << 20)
-	ownerGroups  flagutil.StringList
-	ownerUsers   flagutil.StringList
-	probePortNum = flag.Uint("probePortNum", 0, "Port number on VM to probe")
-	probeTimeout = flag.Duration("probeTimeout", time.Minute*5,
+	maxIdentityAge = flag.Duration("maxIdentityAge", 5*time.Minute,
+		"Maximum age of a signed VM identity document (0: no limit)")
+	memory                   flagutil.Size
+	migrationMaximumDowntime = flag.Duration("migrationMaximumDowntime",
+		300*time.Millisecond,
+		"Maximum time a VM may be paused at the end of a live migration")
+	migrationTimeout = flag.Duration("migrationTimeout", 10*time.Minute,
+		"Time to wait for VM memory to converge during a live migration")
+	milliCPUs        = flag.Uint("milliCPUs", 0, "milli CPUs (default 250)")
+	minFreeBytes     = flagutil.Size(256 << 20)
+	offlineMigration = flag.Bool("offlineMigration", false,
+		"If true, stop a running VM while migrating rather than live migrate")
+	ownerGroups     flagutil.StringList
+	ownerUsers      flagutil.StringList
+	placementGroups placementGroupList
+	probePortNum    = flag.Uint("probePortNum", 0, "Port number on VM to probe")
+	probeTimeout    = flag.Duration("probeTimeout", time.Minute*5,
 		"Time to wait before timing out on probing VM port")
 	secondarySubnetIDs   flagutil.StringList
 	secondaryVolumeSizes flagutil.StringList
@@ -68,13 +98,22 @@ var (
 		"Serial port number on VM")
 	skipBootloader = flag.Bool("skipBootloader", false,
 		"If true, directly boot into the kernel")
+	spreadTags        flagutil.StringList
+	sshPublicKeysFile = flag.String("sshPublicKeysFile", "",
+		"Name of file containing SSH public keys for the VM (one per line)")
 	subnetId = flag.String("subnetId", "",
 		"Subnet ID to launch VM in")
 	requestIPs   flagutil.StringList
 	roundupPower = flag.Uint64("roundupPower", 28,
 		"power of 2 to round up root volume size")
+	snapshotInterval = flag.Duration("snapshotInterval", 0,
+		"Interval between scheduled snapshots (0: disable)")
+	snapshotName = flag.String("snapshotName", "",
+		"Name of snapshot (default snapshot if empty)")
 	snapshotRootOnly = flag.Bool("snapshotRootOnly", false,
 		"If true, snapshot only the root volume")
+	snapshotsToRetain = flag.Uint("snapshotsToRetain", 7,
+		"Number of scheduled snapshots to retain")
 	traceMetadata = flag.Bool("traceMetadata", false,
 		"If true, trace metadata calls until interrupted")
 	userDataFile = flag.String("userDataFile", "",
@@ -86,25 +125,39 @@ var (
 	volumeFilename = flag.String("volumeFilename", "",
 		"Name of file to write volume data to")
 	volumeIndex = flag.Uint("volumeIndex", 0,
-		"Index of volume to get or delete")
+		"Index of volume to get, delete or grow")
+	volumeSize flagutil.Size
 
 	logger   log.DebugLogger
 	rrDialer *rrdialer.Dialer
 )
 
 func init() {
+	flag.Var(&antiAffinityTags, "antiAffinityTags",
+		"Do not place VM on a Hypervisor with a VM with the same tag values")
+	flag.Var(&cloudInitDatasource, "cloudInitDatasource",
+		"cloud-init seed to generate: NoCloud or ConfigDrive (default none)")
 	flag.Var(&consoleType, "consoleType",
 		"type of graphical console (default none)")
+	flag.Var(&firewallEgressRules, "firewallEgressRules",
+		"Permitted egress traffic (protocol[:port[-port]][@network],...)")
+	flag.Var(&firewallIngressRules, "firewallIngressRules",
+		"Permitted ingress traffic (protocol[:port[-port]][@network],...)")
 	flag.Var(&memory, "memory", "memory (default 1GiB)")
 	flag.Var(&minFreeBytes, "minFreeBytes",
 		"minimum number of free bytes in root volume")
 	flag.Var(&ownerGroups, "ownerGroups", "Groups who own the VM")
 	flag.Var(&ownerUsers, "ownerUsers", "Extra users who own the VM")
+	flag.Var(&placementGroups, "placementGroups",
+		"Placement groups for VM (name:policy[:strict],...)")
 	flag.Var(&requestIPs, "requestIPs", "Request specific IPs, if available")
 	flag.Var(&secondarySubnetIDs, "secondarySubnetIDs", "Secondary Subnet IDs")
 	flag.Var(&secondaryVolumeSizes, "secondaryVolumeSizes",
 		"Sizes for secondary volumes")
+	flag.Var(&spreadTags, "spreadTags",
+		"Prefer Hypervisors with fewer VMs with the same tag values")
 	flag.Var(&vmTags, "vmTags", "Tags to apply to VM")
+	flag.Var(&volumeSize, "volumeSize", "New size of volume to grow")
 }
 
 func printUsage() {
@@ -113,10 +166,14 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "Common flags:")
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  backup-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  become-primary-vm-owner IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-console-type IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-destroy-protection IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-firewall-policy IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-owner-users IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-size IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-snapshot-schedule IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-tags IPaddr")
 	fmt.Fprintln(os.Stderr, "  connect-to-vm-console IPaddr")
 	fmt.Fprintln(os.Stderr, "  connect-to-vm-serial-port IPaddr")
@@ -129,9 +186,11 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  discard-vm-snapshot IPaddr")
 	fmt.Fprintln(os.Stderr, "  export-local-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  export-virsh-vm IPaddr")
+	fmt.Fprintln(os.Stderr, "  get-vm-firewall-ruleset IPaddr")
 	fmt.Fprintln(os.Stderr, "  get-vm-info IPaddr")
 	fmt.Fprintln(os.Stderr, "  get-vm-user-data IPaddr")
 	fmt.Fprintln(os.Stderr, "  get-vm-volume IPaddr")
+	fmt.Fprintln(os.Stderr, "  grow-vm-volume IPaddr")
 	fmt.Fprintln(os.Stderr, "  import-local-vm info-file root-volume")
 	fmt.Fprintln(os.Stderr, "  import-virsh-vm MACaddr domain [[MAC IP]...]")
 	fmt.Fprintln(os.Stderr, "  list-hypervisors")
@@ -142,6 +201,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  probe-vm-port IPaddr")
 	fmt.Fprintln(os.Stderr, "  replace-vm-image IPaddr")
 	fmt.Fprintln(os.Stderr, "  replace-vm-user-data IPaddr")
+	fmt.Fprintln(os.Stderr, "  restore-vm-from-backup hash|image")
 	fmt.Fprintln(os.Stderr, "  restore-vm-from-snapshot IPaddr")
 	fmt.Fprintln(os.Stderr, "  restore-vm-image IPaddr")
 	fmt.Fprintln(os.Stderr, "  restore-vm-user-data IPaddr")
@@ -151,6 +211,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  stop-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  trace-vm-metadata IPaddr")
 	fmt.Fprintln(os.Stderr, "  unset-vm-migrating IPaddr")
+	fmt.Fprintln(os.Stderr, "  verify-vm-identity [signed-document-file]")
 }
 
 type commandFunc func([]string, log.DebugLogger) error
@@ -163,10 +224,14 @@ type subcommand struct {
 }
 
 var subcommands = []subcommand{
+	{"backup-vm", 1, 1, backupVmSubcommand},
 	{"become-primary-vm-owner", 1, 1, becomePrimaryVmOwnerSubcommand},
 	{"change-vm-console-type", 1, 1, changeVmConsoleTypeSubcommand},
 	{"change-vm-destroy-protection", 1, 1, changeVmDestroyProtectionSubcommand},
+	{"change-vm-firewall-policy", 1, 1, changeVmFirewallPolicySubcommand},
 	{"change-vm-owner-users", 1, 1, changeVmOwnerUsersSubcommand},
+	{"change-vm-size", 1, 1, changeVmSizeSubcommand},
+	{"change-vm-snapshot-schedule", 1, 1, changeVmSnapshotScheduleSubcommand},
 	{"change-vm-tags", 1, 1, changeVmTagsSubcommand},
 	{"connect-to-vm-console", 1, 1, connectToVmConsoleSubcommand},
 	{"connect-to-vm-serial-port", 1, 1, connectToVmSerialPortSubcommand},
@@ -179,9 +244,11 @@ var subcommands = []subcommand{
 	{"discard-vm-snapshot", 1, 1, discardVmSnapshotSubcommand},
 	{"export-local-vm", 1, 1, exportLocalVmSubcommand},
 	{"export-virsh-vm", 1, 1, exportVirshVmSubcommand},
+	{"get-vm-firewall-ruleset", 1, 1, getVmFirewallRulesetSubcommand},
 	{"get-vm-info", 1, 1, getVmInfoSubcommand},
 	{"get-vm-user-data", 1, 1, getVmUserDataSubcommand},
 	{"get-vm-volume", 1, 1, getVmVolumeSubcommand},
+	{"grow-vm-volume", 1, 1, growVmVolumeSubcommand},
 	{"import-local-vm", 2, 2, importLocalVmSubcommand},
 	{"import-virsh-vm", 2, -1, importVirshVmSubcommand},
 	{"list-hypervisors", 0, 0, listHypervisorsSubcommand},
@@ -192,6 +259,7 @@ var subcommands = []subcommand{
 	{"probe-vm-port", 1, 1, probeVmPortSubcommand},
 	{"replace-vm-image", 1, 1, replaceVmImageSubcommand},
 	{"replace-vm-user-data", 1, 1, replaceVmUserDataSubcommand},
+	{"restore-vm-from-backup", 1, 1, restoreVmFromBackupSubcommand},
 	{"restore-vm-from-snapshot", 1, 1, restoreVmFromSnapshotSubcommand},
 	{"restore-vm-image", 1, 1, restoreVmImageSubcommand},
 	{"restore-vm-user-data", 1, 1, restoreVmUserDataSubcommand},
@@ -201,6 +269,7 @@ var subcommands = []subcommand{
 	{"stop-vm", 1, 1, stopVmSubcommand},
 	{"trace-vm-metadata", 1, 1, traceVmMetadataSubcommand},
 	{"unset-vm-migrating", 1, 1, unsetVmMigratingSubcommand},
+	{"verify-vm-identity", 0, 1, verifyVmIdentitySubcommand},
 }
 
 func doMain() int {
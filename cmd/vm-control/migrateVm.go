@@ -5,6 +5,7 @@ import (
 	"net"
 	"time"
 
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
 	"github.com/Symantec/Dominator/lib/errors"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -49,15 +50,19 @@ func migrateVm(vmHostname string, logger log.DebugLogger) error {
 
 func migrateVmFromHypervisor(sourceHypervisorAddress string, vmIP net.IP,
 	logger log.DebugLogger) error {
-	destHypervisorAddress, err := getHypervisorAddress()
+	sourceHypervisor, err := dialHypervisor(sourceHypervisorAddress)
 	if err != nil {
 		return err
 	}
-	sourceHypervisor, err := dialHypervisor(sourceHypervisorAddress)
+	defer sourceHypervisor.Close()
+	vmInfo, err := hyperclient.GetVmInfo(sourceHypervisor, vmIP)
+	if err != nil {
+		return err
+	}
+	destHypervisorAddress, err := getHypervisorAddress(vmInfo, logger)
 	if err != nil {
 		return err
 	}
-	defer sourceHypervisor.Close()
 	accessToken, err := getVmAccessTokenClient(sourceHypervisor, vmIP)
 	if err != nil {
 		return err
@@ -77,6 +82,9 @@ func migrateVmFromHypervisor(sourceHypervisorAddress string, vmIP net.IP,
 	request := hyper_proto.MigrateVmRequest{
 		AccessToken:      accessToken,
 		IpAddress:        vmIP,
+		MaximumDowntime:  *migrationMaximumDowntime,
+		MigrationTimeout: *migrationTimeout,
+		Offline:          *offlineMigration,
 		SourceHypervisor: sourceHypervisorAddress,
 	}
 	if err := conn.Encode(request); err != nil {
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// placementGroupList implements the flag.Value interface. Each group is
+// specified as name:policy or name:policy:strict and groups are separated
+// by commas.
+type placementGroupList []hyper_proto.PlacementGroup
+
+func (list *placementGroupList) String() string {
+	groups := make([]string, 0, len(*list))
+	for _, group := range *list {
+		text := group.Name + ":" + group.Policy.String()
+		if group.Strict {
+			text += ":strict"
+		}
+		groups = append(groups, text)
+	}
+	return strings.Join(groups, ",")
+}
+
+func (list *placementGroupList) Set(value string) error {
+	var groups placementGroupList
+	for _, text := range strings.Split(value, ",") {
+		if text == "" {
+			continue
+		}
+		fields := strings.Split(text, ":")
+		if len(fields) < 2 || len(fields) > 3 || fields[0] == "" {
+			return fmt.Errorf("invalid placement group: %s", text)
+		}
+		group := hyper_proto.PlacementGroup{Name: fields[0]}
+		if err := group.Policy.Set(fields[1]); err != nil {
+			return err
+		}
+		if len(fields) > 2 {
+			if fields[2] != "strict" {
+				return fmt.Errorf("invalid placement group option: %s",
+					fields[2])
+			}
+			group.Strict = true
+		}
+		groups = append(groups, group)
+	}
+	*list = groups
+	return nil
+}
+
+// checkVmPlacement will ask the Fleet Manager if placing the VM on the
+// Hypervisor violates the placement groups of the VM. An error is returned if
+// a strict group is violated, other violations are logged as warnings.
+func checkVmPlacement(client *srpc.Client, hypervisorHostname string,
+	vmInfo hyper_proto.VmInfo, logger log.DebugLogger) error {
+	request := fm_proto.CheckVMPlacementRequest{
+		HypervisorHostname: hypervisorHostname,
+		VmInfo:             vmInfo,
+	}
+	var reply fm_proto.CheckVMPlacementResponse
+	err := client.RequestReply("FleetManager.CheckVMPlacement", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	for _, warning := range reply.Warnings {
+		logger.Printf("warning: %s: %s\n", hypervisorHostname, warning)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func restoreVmFromBackupSubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := restoreVmFromBackup(args[0], logger); err != nil {
+		return fmt.Errorf("Error restoring VM from backup: %s", err)
+	}
+	return nil
+}
+
+func callRestoreVmFromBackup(client *srpc.Client,
+	request hyper_proto.RestoreVmFromBackupRequest,
+	reply *hyper_proto.RestoreVmFromBackupResponse,
+	logger log.DebugLogger) error {
+	conn, err := client.Call("Hypervisor.RestoreVmFromBackup")
+	if err != nil {
+		return fmt.Errorf("error calling Hypervisor.RestoreVmFromBackup: %s",
+			err)
+	}
+	defer conn.Close()
+	if err := conn.Encode(request); err != nil {
+		return fmt.Errorf("error encoding RestoreVmFromBackup request: %s",
+			err)
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("error flushing RestoreVmFromBackup request: %s",
+			err)
+	}
+	for {
+		var response hyper_proto.RestoreVmFromBackupResponse
+		if err := conn.Decode(&response); err != nil {
+			return fmt.Errorf("error decoding RestoreVmFromBackup response: %s",
+				err)
+		}
+		if response.Error != "" {
+			return errors.New(response.Error)
+		}
+		if response.ProgressMessage != "" {
+			logger.Debugln(0, response.ProgressMessage)
+		}
+		if response.Final {
+			*reply = response
+			return nil
+		}
+	}
+}
+
+// restoreVmFromBackup will create a VM from a backup. The backup is either the
+// hash of the backup manifest or the name of an image (or image directory)
+// that the backup was added to.
+func restoreVmFromBackup(backup string, logger log.DebugLogger) error {
+	request := hyper_proto.RestoreVmFromBackupRequest{
+		ObjectServerAddress: *backupServer,
+		VmInfo:              createVmInfoFromFlags(),
+	}
+	if err := request.Hash.UnmarshalText([]byte(backup)); err != nil {
+		request.ImageName = backup
+	}
+	hypervisor, err := getHypervisorAddress(request.VmInfo, logger)
+	if err != nil {
+		return err
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply hyper_proto.RestoreVmFromBackupResponse
+	logger.Debugf(0, "restoring VM on %s\n", hypervisor)
+	err = callRestoreVmFromBackup(client, request, &reply, logger)
+	if err != nil {
+		return err
+	}
+	err = hyperclient.AcknowledgeVm(client, reply.IpAddress)
+	if err != nil {
+		return fmt.Errorf("error acknowledging VM: %s", err)
+	}
+	fmt.Println(reply.IpAddress)
+	return nil
+}
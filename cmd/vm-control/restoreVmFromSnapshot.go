@@ -27,7 +27,8 @@ func restoreVmFromSnapshot(vmHostname string, logger log.DebugLogger) error {
 
 func restoreVmFromSnapshotOnHypervisor(hypervisor string, ipAddr net.IP,
 	logger log.DebugLogger) error {
-	request := proto.RestoreVmFromSnapshotRequest{ipAddr, *forceIfNotStopped}
+	request := proto.RestoreVmFromSnapshotRequest{ipAddr, *forceIfNotStopped,
+		*snapshotName}
 	client, err := dialHypervisor(hypervisor)
 	if err != nil {
 		return err
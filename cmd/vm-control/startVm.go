@@ -27,8 +27,9 @@ func startVm(vmHostname string, logger log.DebugLogger) error {
 func startVmOnHypervisor(hypervisor string, ipAddr net.IP,
 	logger log.DebugLogger) error {
 	request := proto.StartVmRequest{
-		DhcpTimeout: *dhcpTimeout,
-		IpAddress:   ipAddr,
+		DhcpTimeout:         *dhcpTimeout,
+		EnableMemoryBalloon: *enableMemoryBalloon,
+		IpAddress:           ipAddr,
 	}
 	client, err := dialHypervisor(hypervisor)
 	if err != nil {
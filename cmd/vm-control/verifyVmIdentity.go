@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	libjson "github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/vmidentity"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func verifyVmIdentitySubcommand(args []string, logger log.DebugLogger) error {
+	filename := "-"
+	if len(args) > 0 {
+		filename = args[0]
+	}
+	if err := verifyVmIdentity(filename, logger); err != nil {
+		return fmt.Errorf("Error verifying VM identity: %s", err)
+	}
+	return nil
+}
+
+func verifyVmIdentity(filename string, logger log.DebugLogger) error {
+	if *fleetManagerHostname == "" {
+		return errors.New("no Fleet Manager specified")
+	}
+	var reader io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+	var signed proto.SignedIdentityDocument
+	if err := json.NewDecoder(reader).Decode(&signed); err != nil {
+		return err
+	}
+	client, err := dialFleetManager(fmt.Sprintf("%s:%d",
+		*fleetManagerHostname, *fleetManagerPortNum))
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	trustedKeys, err := vmidentity.GetTrustedKeys(client)
+	if err != nil {
+		return err
+	}
+	document, err := vmidentity.Verify(signed, trustedKeys, *maxIdentityAge)
+	if err != nil {
+		return err
+	}
+	return libjson.WriteWithIndent(os.Stdout, "    ", document)
+}
@@ -124,7 +124,7 @@ func (herd *Herd) showImage(writer io.Writer, name string, showDefault bool) {
 	} else if image != nil {
 		fmt.Fprintf(writer,
 			"    <td><a href=\"http://%s/showImage?%s\">%s</a></td>\n",
-			herd.imageManager, name, name)
+			herd.imageManager, herd.imageManager.ResolveName(name), name)
 	} else {
 		fmt.Fprintf(writer, "    <td><font color=\"grey\">%s</font></td>\n",
 			name)
@@ -194,6 +194,9 @@ func (sub *Sub) loadConfiguration() {
 	if newRequiredImageName == "" {
 		newRequiredImageName = sub.herd.defaultImageName
 	}
+	// Pin the image that an alias currently refers to.
+	newRequiredImageName = sub.herd.imageManager.ResolveName(
+		newRequiredImageName)
 	if newRequiredImageName != sub.requiredImageName {
 		sub.computedInodes = nil
 	}
@@ -201,7 +204,8 @@ func (sub *Sub) loadConfiguration() {
 	defer sub.herd.cpuSharer.GrabCpu()
 	sub.requiredImageName = newRequiredImageName
 	sub.requiredImage = sub.herd.imageManager.GetNoError(sub.requiredImageName)
-	sub.plannedImageName = sub.mdb.PlannedImage
+	sub.plannedImageName = sub.herd.imageManager.ResolveName(
+		sub.mdb.PlannedImage)
 	sub.plannedImage = sub.herd.imageManager.GetNoError(sub.plannedImageName)
 }
 
@@ -1,6 +1,7 @@
 package images
 
 import (
+	"flag"
 	"sync"
 
 	"github.com/Symantec/Dominator/lib/image"
@@ -8,6 +9,11 @@ import (
 	"github.com/Symantec/Dominator/lib/stringutil"
 )
 
+var (
+	followImageAliases = flag.Bool("followImageAliases", false,
+		"If true, load the new image when an image alias is moved")
+)
+
 type Manager struct {
 	imageServerAddress string
 	logger             log.Logger
@@ -15,6 +21,7 @@ type Manager struct {
 	sync.RWMutex
 	deduper *stringutil.StringDeduplicator
 	// Protected by lock.
+	aliases              map[string]string // Key: alias, value: image name.
 	imageInterestChannel chan<- map[string]struct{}
 	imageRequestChannel  chan<- string
 	imageExpireChannel   chan<- string
@@ -44,6 +51,13 @@ func (m *Manager) GetNoError(name string) *image.Image {
 	return img
 }
 
+// ResolveName will return the name of the image that name currently refers to.
+// If name is an alias for an image, the name of the image is returned, else
+// name is returned.
+func (m *Manager) ResolveName(name string) string {
+	return m.resolveName(name)
+}
+
 func (m *Manager) SetImageInterestList(images map[string]struct{}, wait bool) {
 	m.setImageInterestList(images, wait)
 }
@@ -10,6 +10,8 @@ import (
 	"github.com/Symantec/Dominator/lib/stringutil"
 )
 
+const aliasCheckInterval = time.Second * 30
+
 func newManager(imageServerAddress string, logger log.Logger) *Manager {
 	imageInterestChannel := make(chan map[string]struct{})
 	imageRequestChannel := make(chan string)
@@ -17,6 +19,7 @@ func newManager(imageServerAddress string, logger log.Logger) *Manager {
 	m := &Manager{
 		imageServerAddress:   imageServerAddress,
 		logger:               logger,
+		aliases:              make(map[string]string),
 		deduper:              stringutil.NewStringDeduplicator(false),
 		imageInterestChannel: imageInterestChannel,
 		imageRequestChannel:  imageRequestChannel,
@@ -31,6 +34,11 @@ func newManager(imageServerAddress string, logger log.Logger) *Manager {
 func (m *Manager) getNoWait(name string) (*image.Image, error) {
 	m.RLock()
 	defer m.RUnlock()
+	if imageName, ok := m.aliases[name]; ok {
+		if image := m.imagesByName[imageName]; image != nil {
+			return image, nil
+		}
+	}
 	if image := m.imagesByName[name]; image != nil {
 		return image, nil
 	}
@@ -51,6 +59,15 @@ func (m *Manager) getWait(name string) (*image.Image, error) {
 	return m.getNoWait(name)
 }
 
+func (m *Manager) resolveName(name string) string {
+	m.RLock()
+	defer m.RUnlock()
+	if imageName, ok := m.aliases[name]; ok {
+		return imageName
+	}
+	return name
+}
+
 func (m *Manager) setImageInterestList(images map[string]struct{}, wait bool) {
 	delete(images, "")
 	m.imageInterestChannel <- images
@@ -64,6 +81,12 @@ func (m *Manager) manager(imageInterestChannel <-chan map[string]struct{},
 	imageExpireChannel <-chan string) {
 	var imageClient *srpc.Client
 	timer := time.NewTimer(time.Second)
+	// Aliases are pinned to the image they first resolved to, unless following
+	// is enabled.
+	var aliasTickerChannel <-chan time.Time
+	if *followImageAliases {
+		aliasTickerChannel = time.NewTicker(aliasCheckInterval).C
+	}
 	for {
 		select {
 		case imageList := <-imageInterestChannel:
@@ -88,6 +111,8 @@ func (m *Manager) manager(imageInterestChannel <-chan map[string]struct{},
 			for name := range missingImages {
 				imageClient = m.requestImage(imageClient, name)
 			}
+		case <-aliasTickerChannel:
+			imageClient = m.checkAliases(imageClient)
 		}
 		if len(m.missingImages) > 0 {
 			timer.Reset(time.Second)
@@ -101,9 +126,22 @@ func (m *Manager) setInterest(imageClient *srpc.Client,
 		imageClient = m.requestImage(imageClient, name)
 	}
 	deletedSome := false
-	// Clean up unreferenced images.
+	// Clean up unreferenced aliases and images.
+	imagesToKeep := make(map[string]struct{}, len(imageList))
+	for name := range imageList {
+		imagesToKeep[name] = struct{}{}
+	}
+	for name, imageName := range m.aliases {
+		if _, ok := imageList[name]; ok {
+			imagesToKeep[imageName] = struct{}{}
+		} else {
+			m.Lock()
+			delete(m.aliases, name)
+			m.Unlock()
+		}
+	}
 	for name := range m.imagesByName {
-		if _, ok := imageList[name]; !ok {
+		if _, ok := imagesToKeep[name]; !ok {
 			m.Lock()
 			delete(m.imagesByName, name)
 			m.Unlock()
@@ -125,12 +163,31 @@ func (m *Manager) setInterest(imageClient *srpc.Client,
 
 func (m *Manager) requestImage(imageClient *srpc.Client,
 	name string) *srpc.Client {
+	if imageName, ok := m.aliases[name]; ok {
+		return m.requestAliasedImage(imageClient, name, imageName)
+	}
 	if _, ok := m.imagesByName[name]; ok {
 		return imageClient
 	}
 	var img *image.Image
 	var err error
 	imageClient, img, err = m.loadImage(imageClient, name)
+	if img == nil && err == nil && imageClient != nil {
+		var alias *image.Alias
+		alias, err = client.GetImageAlias(imageClient, name)
+		if err != nil {
+			m.logger.Printf("Error resolving alias: %s: %s\n", name, err)
+			imageClient.Close()
+			imageClient = nil
+		} else if alias != nil {
+			m.logger.Printf("Alias: %s resolves to: %s\n",
+				name, alias.ImageName)
+			m.Lock()
+			m.aliases[name] = alias.ImageName
+			m.Unlock()
+			return m.requestAliasedImage(imageClient, name, alias.ImageName)
+		}
+	}
 	m.Lock()
 	defer m.Unlock()
 	if img != nil && err == nil {
@@ -143,6 +200,67 @@ func (m *Manager) requestImage(imageClient *srpc.Client,
 	return imageClient
 }
 
+// requestAliasedImage will load the image an alias refers to. The alias is
+// recorded as missing until the image is loaded.
+func (m *Manager) requestAliasedImage(imageClient *srpc.Client,
+	aliasName, imageName string) *srpc.Client {
+	if _, ok := m.imagesByName[imageName]; ok {
+		m.Lock()
+		delete(m.missingImages, aliasName)
+		m.Unlock()
+		return imageClient
+	}
+	var img *image.Image
+	var err error
+	imageClient, img, err = m.loadImage(imageClient, imageName)
+	m.Lock()
+	defer m.Unlock()
+	if img != nil && err == nil {
+		delete(m.missingImages, aliasName)
+		m.imagesByName[imageName] = img
+		return imageClient
+	}
+	m.missingImages[aliasName] = err
+	return imageClient
+}
+
+// checkAliases will check if any aliases were moved and will load the images
+// they now refer to.
+func (m *Manager) checkAliases(imageClient *srpc.Client) *srpc.Client {
+	if len(m.aliases) < 1 {
+		return imageClient
+	}
+	if imageClient == nil {
+		var err error
+		imageClient, err = srpc.DialHTTP("tcp", m.imageServerAddress, 0)
+		if err != nil {
+			return nil
+		}
+	}
+	for name, imageName := range m.aliases {
+		alias, err := client.GetImageAlias(imageClient, name)
+		if err != nil {
+			m.logger.Printf("Error resolving alias: %s: %s\n", name, err)
+			imageClient.Close()
+			return nil
+		}
+		if alias == nil || alias.ImageName == imageName {
+			continue
+		}
+		m.logger.Printf("Alias: %s moved from: %s to: %s\n",
+			name, imageName, alias.ImageName)
+		m.Lock()
+		m.aliases[name] = alias.ImageName
+		m.Unlock()
+		imageClient = m.requestAliasedImage(imageClient, name,
+			alias.ImageName)
+		if imageClient == nil {
+			return nil
+		}
+	}
+	return imageClient
+}
+
 func (m *Manager) loadImage(imageClient *srpc.Client, name string) (
 	*srpc.Client, *image.Image, error) {
 	if imageClient == nil {
@@ -1,6 +1,7 @@
 package hypervisors
 
 import (
+	"crypto/ed25519"
 	"io"
 	"net"
 	"sync"
@@ -32,7 +33,9 @@ type hypervisorType struct {
 	cachedSerialNumber string
 	conn               *srpc.Conn
 	deleteScheduled    bool
+	drainStatus        string // Non-empty while draining.
 	healthStatus       string
+	identityPublicKey  ed25519.PublicKey
 	lastIpmiProbe      time.Time
 	localTags          tags.Tags
 	location           string
@@ -40,8 +43,10 @@ type hypervisorType struct {
 	migratingVms       map[string]*vmInfoType // Key: VM IP address.
 	ownerUsers         map[string]struct{}
 	probeStatus        probeStatus
+	resources          *hyper_proto.Resources
 	serialNumber       string
 	subnets            []hyper_proto.Subnet
+	unschedulable      bool
 	vms                map[string]*vmInfoType // Key: VM IP address.
 }
 
@@ -76,6 +81,11 @@ type Manager struct {
 
 type probeStatus uint
 
+type schedulingStorer interface {
+	ReadMachineUnschedulable(hypervisor net.IP) (bool, error)
+	WriteMachineUnschedulable(hypervisor net.IP, unschedulable bool) error
+}
+
 type serialStorer interface {
 	ReadMachineSerialNumber(hypervisor net.IP) (string, error)
 	WriteMachineSerialNumber(hypervisor net.IP, serialNumber string) error
@@ -90,6 +100,7 @@ type StartOptions struct {
 
 type Storer interface {
 	ipStorer
+	schedulingStorer
 	serialStorer
 	tagsStorer
 	vmStorer
@@ -129,14 +140,38 @@ func (m *Manager) ChangeMachineTags(hostname string,
 	return m.changeMachineTags(hostname, authInfo, tgs)
 }
 
+// CheckVmPlacement will check if placing the VM on the Hypervisor would violate
+// the policies of the placement groups of the VM. Violations of strict groups
+// are returned as an error, other violations are returned as warnings.
+// Hypervisors which are not known are not checked.
+func (m *Manager) CheckVmPlacement(hostname string,
+	vmInfo hyper_proto.VmInfo) ([]string, error) {
+	return m.checkVmPlacement(hostname, vmInfo)
+}
+
 func (m *Manager) CloseUpdateChannel(channel <-chan fm_proto.Update) {
 	m.closeUpdateChannel(channel)
 }
 
+// DrainHypervisor will mark a Hypervisor as unschedulable and migrate all of
+// its VMs to other Hypervisors. Progress messages are sent to progressFunc.
+// The reasons VMs could not be migrated are returned (key: IP address).
+func (m *Manager) DrainHypervisor(request fm_proto.DrainHypervisorRequest,
+	authInfo *srpc.AuthInformation,
+	progressFunc func(string)) (map[string]string, error) {
+	return m.drainHypervisor(request, authInfo, progressFunc)
+}
+
 func (m *Manager) GetHypervisorForVm(ipAddr net.IP) (string, error) {
 	return m.getHypervisorForVm(ipAddr)
 }
 
+// GetIdentityKeys will return the public keys that the Hypervisors sign VM
+// identity documents with.
+func (m *Manager) GetIdentityKeys() []fm_proto.IdentityKey {
+	return m.getIdentityKeys()
+}
+
 func (m *Manager) GetMachineInfo(hostname string) (fm_proto.Machine, error) {
 	return m.getMachineInfo(hostname)
 }
@@ -166,6 +201,21 @@ func (m *Manager) MoveIpAddresses(hostname string, ipAddresses []net.IP) error {
 	return m.moveIpAddresses(hostname, ipAddresses)
 }
 
+// SelectHypervisorsForVm will return the Hypervisors in a location which can
+// host the VM, best first, the reasons the other Hypervisors were rejected
+// and any placement group warnings.
+func (m *Manager) SelectHypervisorsForVm(
+	request fm_proto.SelectHypervisorsForVMRequest) (
+	fm_proto.SelectHypervisorsForVMResponse, error) {
+	return m.selectHypervisorsForVm(request)
+}
+
+// UndrainHypervisor will mark a Hypervisor as schedulable.
+func (m *Manager) UndrainHypervisor(hostname string,
+	authInfo *srpc.AuthInformation) error {
+	return m.undrainHypervisor(hostname, authInfo)
+}
+
 func (m *Manager) WriteHtml(writer io.Writer) {
 	m.writeHtml(writer)
 }
@@ -45,6 +45,8 @@ func (m *Manager) writeHtml(writer io.Writer) {
 		"listHypervisors?state=OK", numOK)
 	writeCountLinksHTJ(writer, "Number of VMs known",
 		"listVMs?", numVMs)
+	m.writePlacementViolationsHtml(writer)
+	m.writeIdentityKeysHtml(writer)
 	fmt.Fprintln(writer, `Hypervisor <a href="listLocations">locations</a><br>`)
 }
 
@@ -0,0 +1,220 @@
+package hypervisors
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/verstr"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func migrateVm(sourceAddress, destinationAddress string, ipAddr net.IP,
+	offline bool, progressFunc func(string)) error {
+	source, err := srpc.DialHTTP("tcp", sourceAddress, time.Second*15)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	var tokenReply hyper_proto.GetVmAccessTokenResponse
+	err = source.RequestReply("Hypervisor.GetVmAccessToken",
+		hyper_proto.GetVmAccessTokenRequest{
+			IpAddress: ipAddr,
+			Lifetime:  time.Hour,
+		}, &tokenReply)
+	if err != nil {
+		return err
+	}
+	if tokenReply.Error != "" {
+		return errors.New(tokenReply.Error)
+	}
+	defer func() {
+		var reply hyper_proto.DiscardVmAccessTokenResponse
+		source.RequestReply("Hypervisor.DiscardVmAccessToken",
+			hyper_proto.DiscardVmAccessTokenRequest{
+				AccessToken: tokenReply.Token,
+				IpAddress:   ipAddr,
+			}, &reply)
+	}()
+	destination, err := srpc.DialHTTP("tcp", destinationAddress,
+		time.Second*15)
+	if err != nil {
+		return err
+	}
+	defer destination.Close()
+	conn, err := destination.Call("Hypervisor.MigrateVm")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	request := hyper_proto.MigrateVmRequest{
+		AccessToken:      tokenReply.Token,
+		IpAddress:        ipAddr,
+		Offline:          offline,
+		SourceHypervisor: sourceAddress,
+	}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var reply hyper_proto.MigrateVmResponse
+		if err := conn.Decode(&reply); err != nil {
+			return err
+		}
+		if reply.Error != "" {
+			return errors.New(reply.Error)
+		}
+		if reply.ProgressMessage != "" {
+			progressFunc(fmt.Sprintf("%s: %s", ipAddr, reply.ProgressMessage))
+		}
+		if reply.RequestCommit {
+			err := conn.Encode(hyper_proto.MigrateVmResponseResponse{
+				Commit: true})
+			if err != nil {
+				return err
+			}
+			if err := conn.Flush(); err != nil {
+				return err
+			}
+		}
+		if reply.Final {
+			return nil
+		}
+	}
+}
+
+func (m *Manager) drainHypervisor(request fm_proto.DrainHypervisorRequest,
+	authInfo *srpc.AuthInformation,
+	progressFunc func(string)) (map[string]string, error) {
+	if !*manageHypervisors {
+		return nil, errors.New("this is a read-only Fleet Manager")
+	}
+	h, err := m.getLockedHypervisor(request.Hostname, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkAuth(authInfo); err != nil {
+		h.mutex.Unlock()
+		return nil, err
+	}
+	if h.drainStatus != "" {
+		h.mutex.Unlock()
+		return nil, errors.New("drain already in progress")
+	}
+	err = m.storer.WriteMachineUnschedulable(h.machine.HostIpAddress, true)
+	if err != nil {
+		h.mutex.Unlock()
+		return nil, err
+	}
+	h.unschedulable = true
+	h.drainStatus = "starting"
+	ipAddrs := make([]string, 0, len(h.vms))
+	for ipAddr := range h.vms {
+		ipAddrs = append(ipAddrs, ipAddr)
+	}
+	h.mutex.Unlock()
+	defer h.setDrainStatus("")
+	verstr.Sort(ipAddrs)
+	failures := make(map[string]string)
+	for index, ipAddr := range ipAddrs {
+		status := fmt.Sprintf("migrating VM: %s (%d of %d)",
+			ipAddr, index+1, len(ipAddrs))
+		h.setDrainStatus(status)
+		progressFunc(status)
+		err := m.evacuateVm(h, ipAddr, request.Location, progressFunc)
+		if err != nil {
+			failures[ipAddr] = err.Error()
+			progressFunc(fmt.Sprintf("failed to migrate VM: %s: %s",
+				ipAddr, err))
+		}
+	}
+	return failures, nil
+}
+
+// evacuateVm will migrate a VM from the Hypervisor to the best Hypervisor
+// selected for it. Running VMs are live migrated. If live migration fails the
+// VM is migrated offline, unless it has destroy protection, in which case it
+// is not stopped.
+func (m *Manager) evacuateVm(h *hypervisorType, ipAddr, location string,
+	progressFunc func(string)) error {
+	h.mutex.RLock()
+	vm, ok := h.vms[ipAddr]
+	var vmInfo hyper_proto.VmInfo
+	if ok {
+		vmInfo = vm.VmInfo
+	}
+	sourceAddress := fmt.Sprintf("%s:%d", h.machine.Hostname,
+		constants.HypervisorPortNumber)
+	h.mutex.RUnlock()
+	if !ok {
+		return nil // VM was moved or destroyed since the drain started.
+	}
+	response, err := m.selectHypervisorsForVm(
+		fm_proto.SelectHypervisorsForVMRequest{
+			Location: location,
+			VmInfo:   vmInfo,
+		})
+	if err != nil {
+		return err
+	}
+	if len(response.HypervisorAddresses) < 1 {
+		return fmt.Errorf("no suitable Hypervisors, %d rejected",
+			len(response.Rejected))
+	}
+	destinationAddress := response.HypervisorAddresses[0]
+	if warning := response.Warnings[destinationAddress]; warning != "" {
+		progressFunc(fmt.Sprintf("%s: warning: %s", ipAddr, warning))
+	}
+	progressFunc(fmt.Sprintf("%s: migrating to: %s",
+		ipAddr, destinationAddress))
+	err = migrateVm(sourceAddress, destinationAddress,
+		vmInfo.Address.IpAddress, false, progressFunc)
+	if err == nil || vmInfo.State != hyper_proto.StateRunning {
+		return err
+	}
+	if vmInfo.DestroyProtection {
+		return fmt.Errorf(
+			"live migration failed, not stopping protected VM: %s", err)
+	}
+	progressFunc(fmt.Sprintf(
+		"%s: live migration failed: %s, migrating offline", ipAddr, err))
+	return migrateVm(sourceAddress, destinationAddress,
+		vmInfo.Address.IpAddress, true, progressFunc)
+}
+
+func (h *hypervisorType) setDrainStatus(status string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.drainStatus = status
+}
+
+func (m *Manager) undrainHypervisor(hostname string,
+	authInfo *srpc.AuthInformation) error {
+	if !*manageHypervisors {
+		return errors.New("this is a read-only Fleet Manager")
+	}
+	h, err := m.getLockedHypervisor(hostname, true)
+	if err != nil {
+		return err
+	}
+	defer h.mutex.Unlock()
+	if err := h.checkAuth(authInfo); err != nil {
+		return err
+	}
+	if h.drainStatus != "" {
+		return errors.New("drain in progress")
+	}
+	err = m.storer.WriteMachineUnschedulable(h.machine.HostIpAddress, false)
+	if err != nil {
+		return err
+	}
+	h.unschedulable = false
+	return nil
+}
@@ -65,6 +65,10 @@ func (s *Storer) ReadMachineTags(hypervisor net.IP) (tags.Tags, error) {
 	return s.readMachineTags(hypervisor)
 }
 
+func (s *Storer) ReadMachineUnschedulable(hypervisor net.IP) (bool, error) {
+	return s.readMachineUnschedulable(hypervisor)
+}
+
 func (s *Storer) ReadVm(hypervisor net.IP,
 	ipAddr string) (*proto.VmInfo, error) {
 	return s.readVm(hypervisor, ipAddr)
@@ -88,6 +92,11 @@ func (s *Storer) WriteMachineTags(hypervisor net.IP, tgs tags.Tags) error {
 	return s.writeMachineTags(hypervisor, tgs)
 }
 
+func (s *Storer) WriteMachineUnschedulable(hypervisor net.IP,
+	unschedulable bool) error {
+	return s.writeMachineUnschedulable(hypervisor, unschedulable)
+}
+
 func (s *Storer) WriteVm(hypervisor net.IP, ipAddr string,
 	vmInfo proto.VmInfo) error {
 	return s.writeVm(hypervisor, ipAddr, vmInfo)
@@ -147,3 +147,19 @@ func (s *Storer) readMachineTags(hypervisor net.IP) (tags.Tags, error) {
 		return tgs, nil
 	}
 }
+
+func (s *Storer) readMachineUnschedulable(hypervisor net.IP) (bool, error) {
+	hypervisorIP, err := netIpToIp(hypervisor)
+	if err != nil {
+		return false, err
+	}
+	dirname := s.getHypervisorDirectory(hypervisorIP)
+	filename := filepath.Join(dirname, "unschedulable")
+	if _, err := os.Stat(filename); err != nil {
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
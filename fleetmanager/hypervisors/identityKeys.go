@@ -0,0 +1,85 @@
+package hypervisors
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/url"
+	"github.com/Symantec/Dominator/lib/vmidentity"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+// getIdentityKeys will return the keys that the Hypervisors sign VM identity
+// documents with. Keys are retained after a Hypervisor disconnects, since its
+// VMs may still be running.
+func (m *Manager) getIdentityKeys() []fm_proto.IdentityKey {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	keys := make([]fm_proto.IdentityKey, 0, len(m.hypervisors))
+	for _, h := range m.hypervisors {
+		h.mutex.RLock()
+		if len(h.identityPublicKey) == ed25519.PublicKeySize {
+			keys = append(keys, fm_proto.IdentityKey{
+				Hypervisor: h.machine.Hostname,
+				KeyId:      vmidentity.KeyId(h.identityPublicKey),
+				PublicKey:  h.identityPublicKey,
+			})
+		}
+		h.mutex.RUnlock()
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Hypervisor < keys[j].Hypervisor
+	})
+	return keys
+}
+
+func (m *Manager) listIdentityKeysHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	parsedQuery := url.ParseQuery(req.URL)
+	keys := m.getIdentityKeys()
+	switch parsedQuery.OutputType() {
+	case url.OutputTypeJson:
+		json.WriteWithIndent(writer, "    ", keys)
+		return
+	case url.OutputTypeText:
+		for _, key := range keys {
+			fmt.Fprintf(writer, "%s %s %s\n", key.Hypervisor, key.KeyId,
+				base64.StdEncoding.EncodeToString(key.PublicKey))
+		}
+		return
+	}
+	fmt.Fprintf(writer, "<title>Hypervisor identity keys</title>\n")
+	writer.WriteString(commonStyleSheet)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Hypervisor</th>")
+	fmt.Fprintln(writer, "    <th>Key ID</th>")
+	fmt.Fprintln(writer, "    <th>Public Key</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, key := range keys {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer,
+			"    <td><a href=\"showHypervisor?%s\">%s</a></td>\n",
+			key.Hypervisor, key.Hypervisor)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", key.KeyId)
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			base64.StdEncoding.EncodeToString(key.PublicKey))
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
+
+func (m *Manager) writeIdentityKeysHtml(writer io.Writer) {
+	writeCountLinksHTJ(writer, "Number of Hypervisor identity keys",
+		"listIdentityKeys?", uint(len(m.getIdentityKeys())))
+}
@@ -0,0 +1,295 @@
+package hypervisors
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/url"
+	"github.com/Symantec/Dominator/lib/verstr"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type placementGroupKey struct {
+	name   string
+	policy hyper_proto.PlacementPolicy
+}
+
+type placementMemberType struct {
+	hypervisor *hypervisorType
+	ipAddr     string
+	location   string
+	strict     bool
+}
+
+type placementViolationType struct {
+	group       hyper_proto.PlacementGroup
+	description string
+	members     []placementMemberType
+}
+
+// checkPlacementGroup will return a description of how placing a VM on the
+// Hypervisor would violate the policy of the placement group, or the empty
+// string if it would not. The caller must hold the Hypervisor lock.
+func (h *hypervisorType) checkPlacementGroup(group hyper_proto.PlacementGroup,
+	members []placementMemberType) string {
+	switch group.Policy {
+	case hyper_proto.PlacementPolicySpreadHypervisors:
+		for _, member := range members {
+			if member.hypervisor == h {
+				return fmt.Sprintf("placement group: %s: VM: %s on Hypervisor",
+					group.Name, member.ipAddr)
+			}
+		}
+	case hyper_proto.PlacementPolicySpreadRacks:
+		for _, member := range members {
+			if member.location == h.location {
+				return fmt.Sprintf("placement group: %s: VM: %s in rack: %s",
+					group.Name, member.ipAddr, h.location)
+			}
+		}
+	case hyper_proto.PlacementPolicyPack:
+		if len(members) < 1 {
+			return ""
+		}
+		for _, member := range members {
+			if member.hypervisor == h {
+				return ""
+			}
+		}
+		return fmt.Sprintf("placement group: %s: VMs on Hypervisor: %s",
+			group.Name, members[0].hypervisor.machine.Hostname)
+	}
+	return ""
+}
+
+// checkPlacementGroups will return the first violation of a strict placement
+// group (or the empty string) and the violations of the other groups if the VM
+// was placed on the Hypervisor. The caller must hold the Hypervisor lock.
+func (h *hypervisorType) checkPlacementGroups(
+	groups []hyper_proto.PlacementGroup,
+	groupMembers map[placementGroupKey][]placementMemberType) (
+	string, []string) {
+	var warnings []string
+	for _, group := range groups {
+		members := groupMembers[placementGroupKey{group.Name, group.Policy}]
+		if violation := h.checkPlacementGroup(group, members); violation == "" {
+			continue
+		} else if group.Strict {
+			return violation, nil
+		} else {
+			warnings = append(warnings, violation)
+		}
+	}
+	return "", warnings
+}
+
+func (m *Manager) checkVmPlacement(hostname string,
+	vmInfo hyper_proto.VmInfo) ([]string, error) {
+	if len(vmInfo.PlacementGroups) < 1 {
+		return nil, nil
+	}
+	m.mutex.RLock()
+	h := m.hypervisors[hostname]
+	m.mutex.RUnlock()
+	if h == nil {
+		return nil, nil
+	}
+	var excludeIpAddr string
+	if vmInfo.Address.IpAddress != nil {
+		excludeIpAddr = vmInfo.Address.IpAddress.String()
+	}
+	groupMembers := m.getPlacementGroupMembers(vmInfo.PlacementGroups,
+		excludeIpAddr)
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	violation, warnings := h.checkPlacementGroups(vmInfo.PlacementGroups,
+		groupMembers)
+	if violation != "" {
+		return nil, errors.New(violation)
+	}
+	return warnings, nil
+}
+
+// getPlacementGroupMembers will return the VMs in each placement group,
+// excluding the VM with the specified IP address.
+func (m *Manager) getPlacementGroupMembers(
+	groups []hyper_proto.PlacementGroup,
+	excludeIpAddr string) map[placementGroupKey][]placementMemberType {
+	if len(groups) < 1 {
+		return nil
+	}
+	groupMembers := make(map[placementGroupKey][]placementMemberType,
+		len(groups))
+	for _, group := range groups {
+		groupMembers[placementGroupKey{group.Name, group.Policy}] = nil
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for ipAddr, vm := range m.vms {
+		if ipAddr == excludeIpAddr {
+			continue
+		}
+		for _, group := range vm.PlacementGroups {
+			key := placementGroupKey{group.Name, group.Policy}
+			if members, ok := groupMembers[key]; ok {
+				groupMembers[key] = append(members,
+					vm.makePlacementMember(group.Strict))
+			}
+		}
+	}
+	return groupMembers
+}
+
+// getPlacementViolations will return the placement groups whose policies are
+// violated by the current placement of their VMs.
+func (m *Manager) getPlacementViolations() []placementViolationType {
+	groupMembers := make(map[placementGroupKey][]placementMemberType)
+	m.mutex.RLock()
+	for _, vm := range m.vms {
+		for _, group := range vm.PlacementGroups {
+			key := placementGroupKey{group.Name, group.Policy}
+			groupMembers[key] = append(groupMembers[key],
+				vm.makePlacementMember(group.Strict))
+		}
+	}
+	m.mutex.RUnlock()
+	var violations []placementViolationType
+	for key, members := range groupMembers {
+		if len(members) < 2 {
+			continue
+		}
+		group := hyper_proto.PlacementGroup{Name: key.name, Policy: key.policy}
+		for _, member := range members {
+			if member.strict {
+				group.Strict = true
+			}
+		}
+		byHypervisor := make(map[string][]placementMemberType)
+		byLocation := make(map[string][]placementMemberType)
+		for _, member := range members {
+			hostname := member.hypervisor.machine.Hostname
+			byHypervisor[hostname] = append(byHypervisor[hostname], member)
+			byLocation[member.location] = append(byLocation[member.location],
+				member)
+		}
+		switch key.policy {
+		case hyper_proto.PlacementPolicySpreadHypervisors:
+			for hostname, members := range byHypervisor {
+				if len(members) > 1 {
+					violations = append(violations, placementViolationType{
+						group:       group,
+						description: "Hypervisor: " + hostname,
+						members:     members,
+					})
+				}
+			}
+		case hyper_proto.PlacementPolicySpreadRacks:
+			for location, members := range byLocation {
+				if len(members) > 1 {
+					violations = append(violations, placementViolationType{
+						group:       group,
+						description: "rack: " + location,
+						members:     members,
+					})
+				}
+			}
+		case hyper_proto.PlacementPolicyPack:
+			if len(byHypervisor) > 1 {
+				violations = append(violations, placementViolationType{
+					group: group,
+					description: fmt.Sprintf("on %d Hypervisors",
+						len(byHypervisor)),
+					members: members,
+				})
+			}
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].group.Name != violations[j].group.Name {
+			return violations[i].group.Name < violations[j].group.Name
+		}
+		return violations[i].description < violations[j].description
+	})
+	for _, violation := range violations {
+		sort.Slice(violation.members, func(i, j int) bool {
+			return verstr.Less(violation.members[i].ipAddr,
+				violation.members[j].ipAddr)
+		})
+	}
+	return violations
+}
+
+func (m *Manager) listPlacementViolationsHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	parsedQuery := url.ParseQuery(req.URL)
+	violations := m.getPlacementViolations()
+	if parsedQuery.OutputType() == url.OutputTypeText {
+		for _, violation := range violations {
+			ipAddrs := make([]string, 0, len(violation.members))
+			for _, member := range violation.members {
+				ipAddrs = append(ipAddrs, member.ipAddr)
+			}
+			fmt.Fprintf(writer, "%s %s %s: %s\n",
+				violation.group.Name, violation.group.Policy,
+				violation.description, strings.Join(ipAddrs, " "))
+		}
+		return
+	}
+	fmt.Fprintf(writer, "<title>Placement group violations</title>\n")
+	writer.WriteString(commonStyleSheet)
+	fmt.Fprintln(writer, "<body>")
+	if len(violations) < 1 {
+		fmt.Fprintln(writer, "No placement group violations")
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Group</th>")
+	fmt.Fprintln(writer, "    <th>Policy</th>")
+	fmt.Fprintln(writer, "    <th>Strict</th>")
+	fmt.Fprintln(writer, "    <th>Violation</th>")
+	fmt.Fprintln(writer, "    <th>VMs</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, violation := range violations {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", violation.group.Name)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", violation.group.Policy)
+		fmt.Fprintf(writer, "    <td>%t</td>\n", violation.group.Strict)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", violation.description)
+		fmt.Fprintln(writer, "    <td>")
+		for _, member := range violation.members {
+			fmt.Fprintf(writer,
+				"      <a href=\"http://%s:%d/showVM?%s\">%s</a>\n",
+				member.hypervisor.machine.Hostname,
+				constants.HypervisorPortNumber, member.ipAddr, member.ipAddr)
+		}
+		fmt.Fprintln(writer, "    </td>")
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
+
+func (vm *vmInfoType) makePlacementMember(strict bool) placementMemberType {
+	return placementMemberType{
+		hypervisor: vm.hypervisor,
+		ipAddr:     vm.ipAddr,
+		location:   vm.hypervisor.location,
+		strict:     strict,
+	}
+}
+
+func (m *Manager) writePlacementViolationsHtml(writer io.Writer) {
+	violations := m.getPlacementViolations()
+	writeCountLinksHT(writer, "Number of placement group violations",
+		"listPlacementViolations?", uint(len(violations)))
+}
@@ -0,0 +1,108 @@
+package hypervisors
+
+import (
+	"testing"
+
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func makeTestHypervisor(hostname, location string) *hypervisorType {
+	return &hypervisorType{
+		location: location,
+		machine: &fm_proto.Machine{
+			NetworkEntry: fm_proto.NetworkEntry{Hostname: hostname},
+		},
+	}
+}
+
+func TestCheckPlacementGroup(t *testing.T) {
+	hyperA := makeTestHypervisor("a", "rack1")
+	hyperB := makeTestHypervisor("b", "rack1")
+	hyperC := makeTestHypervisor("c", "rack2")
+	memberA := placementMemberType{hyperA, "10.0.0.1", "rack1", false}
+	memberB := placementMemberType{hyperB, "10.0.0.2", "rack1", false}
+	var tests = []struct {
+		name          string
+		policy        hyper_proto.PlacementPolicy
+		hypervisor    *hypervisorType
+		members       []placementMemberType
+		wantViolation bool
+	}{
+		{"spread hypervisors: no members",
+			hyper_proto.PlacementPolicySpreadHypervisors, hyperA, nil, false},
+		{"spread hypervisors: other hypervisor",
+			hyper_proto.PlacementPolicySpreadHypervisors, hyperB,
+			[]placementMemberType{memberA}, false},
+		{"spread hypervisors: same hypervisor",
+			hyper_proto.PlacementPolicySpreadHypervisors, hyperA,
+			[]placementMemberType{memberB, memberA}, true},
+		{"spread racks: other rack",
+			hyper_proto.PlacementPolicySpreadRacks, hyperC,
+			[]placementMemberType{memberA, memberB}, false},
+		{"spread racks: same rack",
+			hyper_proto.PlacementPolicySpreadRacks, hyperB,
+			[]placementMemberType{memberA}, true},
+		{"pack: no members",
+			hyper_proto.PlacementPolicyPack, hyperC, nil, false},
+		{"pack: same hypervisor",
+			hyper_proto.PlacementPolicyPack, hyperA,
+			[]placementMemberType{memberA}, false},
+		{"pack: other hypervisor",
+			hyper_proto.PlacementPolicyPack, hyperB,
+			[]placementMemberType{memberA}, true},
+	}
+	for _, test := range tests {
+		group := hyper_proto.PlacementGroup{Name: "g", Policy: test.policy}
+		violation := test.hypervisor.checkPlacementGroup(group, test.members)
+		if (violation != "") != test.wantViolation {
+			t.Errorf("%s: violation: %q, want violation: %t",
+				test.name, violation, test.wantViolation)
+		}
+	}
+}
+
+func TestCheckPlacementGroups(t *testing.T) {
+	hyperA := makeTestHypervisor("a", "rack1")
+	hyperB := makeTestHypervisor("b", "rack1")
+	memberA := placementMemberType{hyperA, "10.0.0.1", "rack1", false}
+	spread := hyper_proto.PlacementGroup{Name: "spread",
+		Policy: hyper_proto.PlacementPolicySpreadHypervisors}
+	strictSpread := spread
+	strictSpread.Strict = true
+	pack := hyper_proto.PlacementGroup{Name: "pack",
+		Policy: hyper_proto.PlacementPolicyPack}
+	groupMembers := map[placementGroupKey][]placementMemberType{
+		{spread.Name, spread.Policy}: {memberA},
+		{pack.Name, pack.Policy}:     {memberA},
+	}
+	var tests = []struct {
+		name          string
+		hypervisor    *hypervisorType
+		groups        []hyper_proto.PlacementGroup
+		wantViolation bool
+		wantWarnings  int
+	}{
+		{"no groups", hyperA, nil, false, 0},
+		{"non-strict violation", hyperA,
+			[]hyper_proto.PlacementGroup{spread}, false, 1},
+		{"strict violation", hyperA,
+			[]hyper_proto.PlacementGroup{pack, strictSpread}, true, 0},
+		{"strict satisfied", hyperB,
+			[]hyper_proto.PlacementGroup{strictSpread}, false, 0},
+		{"non-strict pack violation", hyperB,
+			[]hyper_proto.PlacementGroup{strictSpread, pack}, false, 1},
+	}
+	for _, test := range tests {
+		violation, warnings := test.hypervisor.checkPlacementGroups(
+			test.groups, groupMembers)
+		if (violation != "") != test.wantViolation {
+			t.Errorf("%s: violation: %q, want violation: %t",
+				test.name, violation, test.wantViolation)
+		}
+		if len(warnings) != test.wantWarnings {
+			t.Errorf("%s: warnings: %v, want %d",
+				test.name, warnings, test.wantWarnings)
+		}
+	}
+}
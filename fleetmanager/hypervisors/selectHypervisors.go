@@ -0,0 +1,250 @@
+package hypervisors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Symantec/Dominator/fleetmanager/topology"
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/format"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type candidateType struct {
+	address            string
+	availableMemory    uint64
+	availableMilliCPUs uint
+	numSpreadMatches   uint
+	resourcesUnknown   bool     // Hypervisor does not report resources.
+	warnings           []string // Non-strict placement group violations.
+}
+
+type candidateList []candidateType
+
+// checkVolumeSpace will check if the volumes fit in the free space of the
+// volume directories, allocating space the same way the Hypervisor does.
+func checkVolumeSpace(freeVolumeSpace []uint64, volumes []hyper_proto.Volume,
+	spreadVolumes bool) bool {
+	if len(volumes) < 1 {
+		return true
+	}
+	if len(freeVolumeSpace) < 1 {
+		return false
+	}
+	freeSpace := make([]uint64, len(freeVolumeSpace))
+	copy(freeSpace, freeVolumeSpace)
+	position := 0
+	for index, volume := range volumes {
+		if index > 0 && volume.Size < 1 {
+			continue
+		}
+		if position >= len(freeSpace) {
+			position = 0
+		}
+		startingPosition := position
+		for volume.Size >= freeSpace[position] {
+			position++
+			if position >= len(freeSpace) {
+				position = 0
+			}
+			if position == startingPosition {
+				return false
+			}
+		}
+		freeSpace[position] -= volume.Size
+		if spreadVolumes {
+			position++
+		}
+	}
+	return true
+}
+
+// checkSubnets will return the reason the machine cannot host the VM due to
+// missing subnets, or the empty string if it has all the subnets.
+func checkSubnets(t *topology.Topology, hostname string,
+	vmInfo hyper_proto.VmInfo) string {
+	subnetIDs := make([]string, 0, len(vmInfo.SecondarySubnetIDs)+1)
+	if vmInfo.SubnetId != "" {
+		subnetIDs = append(subnetIDs, vmInfo.SubnetId)
+	}
+	subnetIDs = append(subnetIDs, vmInfo.SecondarySubnetIDs...)
+	for _, subnetId := range subnetIDs {
+		ok, err := t.CheckIfMachineHasSubnet(hostname, subnetId)
+		if err != nil {
+			return err.Error()
+		} else if !ok {
+			return "no subnet: " + subnetId
+		}
+	}
+	return ""
+}
+
+// checkHypervisor will return the reason the Hypervisor cannot host the VM,
+// or the empty string and the ranking information if it can.
+func (h *hypervisorType) checkHypervisor(
+	request fm_proto.SelectHypervisorsForVMRequest,
+	groupMembers map[placementGroupKey][]placementMemberType) (
+	string, candidateType) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.probeStatus != probeStatusConnected {
+		return "not connected: " + h.probeStatus.String(), candidateType{}
+	}
+	if h.unschedulable {
+		return "unschedulable", candidateType{}
+	}
+	if h.healthStatus != "" && h.healthStatus != "healthy" {
+		return "unhealthy: " + h.healthStatus, candidateType{}
+	}
+	vmInfo := request.VmInfo
+	if reason := checkResources(h.resources, vmInfo); reason != "" {
+		return reason, candidateType{}
+	}
+	var numSpreadMatches uint
+	for ipAddr, vm := range h.vms {
+		if vmInfo.Address.IpAddress != nil &&
+			ipAddr == vmInfo.Address.IpAddress.String() {
+			return "already has VM", candidateType{}
+		}
+		for _, key := range request.AntiAffinityTags {
+			value := vmInfo.Tags[key]
+			if value != "" && vm.Tags[key] == value {
+				return fmt.Sprintf("anti-affinity: VM: %s has %s=%s",
+					ipAddr, key, value), candidateType{}
+			}
+		}
+		for _, key := range request.SpreadTags {
+			value := vmInfo.Tags[key]
+			if value != "" && vm.Tags[key] == value {
+				numSpreadMatches++
+				break
+			}
+		}
+	}
+	violation, warnings := h.checkPlacementGroups(vmInfo.PlacementGroups,
+		groupMembers)
+	if violation != "" {
+		return violation, candidateType{}
+	}
+	candidate := candidateType{
+		numSpreadMatches: numSpreadMatches,
+		resourcesUnknown: h.resources == nil,
+		warnings:         warnings,
+	}
+	if h.resources != nil {
+		candidate.availableMemory = h.resources.AvailableMemoryInMiB
+		candidate.availableMilliCPUs = h.resources.AvailableMilliCPUs
+	}
+	return "", candidate
+}
+
+// checkResources will return the reason the Hypervisor does not have the
+// resources for the VM, or the empty string if it does. Older Hypervisors do
+// not report their resources, so they are not checked and the Hypervisor will
+// reject the VM if it does not fit.
+func checkResources(resources *hyper_proto.Resources,
+	vmInfo hyper_proto.VmInfo) string {
+	if resources == nil {
+		return ""
+	}
+	if vmInfo.MemoryInMiB > resources.AvailableMemoryInMiB {
+		return fmt.Sprintf("insufficient memory: %s available",
+			format.FormatBytes(resources.AvailableMemoryInMiB<<20))
+	}
+	if vmInfo.MilliCPUs > resources.AvailableMilliCPUs {
+		return fmt.Sprintf("insufficient CPU: %d milliCPUs available",
+			resources.AvailableMilliCPUs)
+	}
+	if !checkVolumeSpace(resources.FreeVolumeSpace, vmInfo.Volumes,
+		vmInfo.SpreadVolumes) {
+		return "insufficient volume space"
+	}
+	return ""
+}
+
+func (m *Manager) selectHypervisorsForVm(
+	request fm_proto.SelectHypervisorsForVMRequest) (
+	fm_proto.SelectHypervisorsForVMResponse, error) {
+	var response fm_proto.SelectHypervisorsForVMResponse
+	t, err := m.getTopology()
+	if err != nil {
+		return response, err
+	}
+	hypervisors, err := m.listHypervisors(request.Location, showAll, "")
+	if err != nil {
+		return response, err
+	}
+	var excludeIpAddr string
+	if request.VmInfo.Address.IpAddress != nil {
+		excludeIpAddr = request.VmInfo.Address.IpAddress.String()
+	}
+	groupMembers := m.getPlacementGroupMembers(request.VmInfo.PlacementGroups,
+		excludeIpAddr)
+	candidates := make(candidateList, 0, len(hypervisors))
+	rejected := make(map[string]string)
+	for _, hypervisor := range hypervisors {
+		hostname := hypervisor.machine.Hostname
+		address := fmt.Sprintf("%s:%d", hostname,
+			constants.HypervisorPortNumber)
+		if reason := checkSubnets(t, hostname, request.VmInfo); reason != "" {
+			rejected[address] = reason
+			continue
+		}
+		reason, candidate := hypervisor.checkHypervisor(request, groupMembers)
+		if reason != "" {
+			rejected[address] = reason
+			continue
+		}
+		candidate.address = address
+		candidates = append(candidates, candidate)
+	}
+	sort.Sort(candidates)
+	response.HypervisorAddresses = make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		response.HypervisorAddresses = append(response.HypervisorAddresses,
+			candidate.address)
+		if len(candidate.warnings) > 0 {
+			if response.Warnings == nil {
+				response.Warnings = make(map[string]string)
+			}
+			response.Warnings[candidate.address] = strings.Join(
+				candidate.warnings, ", ")
+		}
+	}
+	if len(rejected) > 0 {
+		response.Rejected = rejected
+	}
+	return response, nil
+}
+
+func (list candidateList) Len() int {
+	return len(list)
+}
+
+// Less ranks Hypervisors with fewer placement group violations first, then
+// those with fewer VMs matching the spread tags, then those which report their
+// resources, then those with the most available memory and CPU.
+func (list candidateList) Less(i, j int) bool {
+	if len(list[i].warnings) != len(list[j].warnings) {
+		return len(list[i].warnings) < len(list[j].warnings)
+	}
+	if list[i].numSpreadMatches != list[j].numSpreadMatches {
+		return list[i].numSpreadMatches < list[j].numSpreadMatches
+	}
+	if list[i].resourcesUnknown != list[j].resourcesUnknown {
+		return !list[i].resourcesUnknown
+	}
+	if list[i].availableMemory != list[j].availableMemory {
+		return list[i].availableMemory > list[j].availableMemory
+	}
+	if list[i].availableMilliCPUs != list[j].availableMilliCPUs {
+		return list[i].availableMilliCPUs > list[j].availableMilliCPUs
+	}
+	return list[i].address < list[j].address
+}
+
+func (list candidateList) Swap(i, j int) {
+	list[i], list[j] = list[j], list[i]
+}
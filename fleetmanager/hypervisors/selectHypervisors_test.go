@@ -0,0 +1,118 @@
+package hypervisors
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func makeTestVolumes(sizes ...uint64) []hyper_proto.Volume {
+	volumes := make([]hyper_proto.Volume, 0, len(sizes))
+	for _, size := range sizes {
+		volumes = append(volumes, hyper_proto.Volume{Size: size})
+	}
+	return volumes
+}
+
+func TestCheckVolumeSpace(t *testing.T) {
+	var tests = []struct {
+		name            string
+		freeVolumeSpace []uint64
+		volumes         []uint64
+		spreadVolumes   bool
+		want            bool
+	}{
+		{"no volumes", nil, nil, false, true},
+		{"no directories", nil, []uint64{1}, false, false},
+		{"fits", []uint64{100}, []uint64{50, 40}, false, true},
+		{"exactly full", []uint64{100}, []uint64{100}, false, false},
+		{"too big", []uint64{100}, []uint64{60, 50}, false, false},
+		{"overflows to second directory", []uint64{100, 100},
+			[]uint64{60, 50}, false, true},
+		{"too big for any directory", []uint64{100, 100}, []uint64{150},
+			false, false},
+		{"spread", []uint64{100, 100}, []uint64{60, 60}, true, true},
+		{"spread wraps", []uint64{100, 100}, []uint64{40, 40, 40}, true,
+			true},
+		{"spread too big", []uint64{100, 100}, []uint64{60, 60, 60}, true,
+			false},
+		{"empty secondary volume skipped", []uint64{100},
+			[]uint64{50, 0, 40}, false, true},
+	}
+	for _, test := range tests {
+		got := checkVolumeSpace(test.freeVolumeSpace,
+			makeTestVolumes(test.volumes...), test.spreadVolumes)
+		if got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCandidateListLess(t *testing.T) {
+	var tests = []struct {
+		name       string
+		candidates candidateList
+		want       []string
+	}{
+		{
+			name: "warnings first",
+			candidates: candidateList{
+				{address: "a", availableMemory: 100,
+					warnings: []string{"group"}},
+				{address: "b", availableMemory: 10},
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "spread matches before memory",
+			candidates: candidateList{
+				{address: "a", availableMemory: 100, numSpreadMatches: 2},
+				{address: "b", availableMemory: 10, numSpreadMatches: 1},
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "unknown resources last",
+			candidates: candidateList{
+				{address: "a", resourcesUnknown: true},
+				{address: "b", availableMemory: 10},
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "most memory first",
+			candidates: candidateList{
+				{address: "a", availableMemory: 10, availableMilliCPUs: 8000},
+				{address: "b", availableMemory: 100},
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "most CPU first",
+			candidates: candidateList{
+				{address: "a", availableMemory: 10, availableMilliCPUs: 1000},
+				{address: "b", availableMemory: 10, availableMilliCPUs: 2000},
+			},
+			want: []string{"b", "a"},
+		},
+		{
+			name: "address breaks ties",
+			candidates: candidateList{
+				{address: "c"}, {address: "a"}, {address: "b"},
+			},
+			want: []string{"a", "b", "c"},
+		},
+	}
+	for _, test := range tests {
+		sort.Sort(test.candidates)
+		got := make([]string, 0, len(test.candidates))
+		for _, candidate := range test.candidates {
+			got = append(got, candidate.address)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 
 	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/url"
 )
@@ -79,6 +80,29 @@ func (m *Manager) showHypervisorHandler(w http.ResponseWriter,
 		fmt.Fprintln(writer, "</table>")
 	}
 	fmt.Fprintf(writer, "Status: %s<br>\n", h.getHealthStatus())
+	h.mutex.RLock()
+	drainStatus := h.drainStatus
+	resources := h.resources
+	unschedulable := h.unschedulable
+	h.mutex.RUnlock()
+	if drainStatus != "" {
+		fmt.Fprintf(writer, "Draining: %s<br>\n", drainStatus)
+	} else if unschedulable {
+		fmt.Fprintln(writer, "Unschedulable (drained)<br>")
+	}
+	if resources != nil {
+		fmt.Fprintf(writer, "Available memory: %s of %s<br>\n",
+			format.FormatBytes(resources.AvailableMemoryInMiB<<20),
+			format.FormatBytes(resources.TotalMemoryInMiB<<20))
+		fmt.Fprintf(writer, "Available CPU: %d of %d milliCPUs<br>\n",
+			resources.AvailableMilliCPUs, resources.TotalMilliCPUs)
+		var freeVolumeSpace uint64
+		for _, freeSpace := range resources.FreeVolumeSpace {
+			freeVolumeSpace += freeSpace
+		}
+		fmt.Fprintf(writer, "Free volume space: %s<br>\n",
+			format.FormatBytes(freeVolumeSpace))
+	}
 	fmt.Fprintf(writer,
 		"Number of VMs known: <a href=\"http://%s:%d/listVMs\">%d</a>\n",
 		hostname, constants.HypervisorPortNumber, len(h.vms))
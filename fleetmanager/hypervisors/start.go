@@ -29,8 +29,11 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		vms:              make(map[string]*vmInfoType),
 	}
 	manager.initInvertTable()
+	html.HandleFunc("/listIdentityKeys", manager.listIdentityKeysHandler)
 	html.HandleFunc("/listHypervisors", manager.listHypervisorsHandler)
 	html.HandleFunc("/listLocations", manager.listLocationsHandler)
+	html.HandleFunc("/listPlacementViolations",
+		manager.listPlacementViolationsHandler)
 	html.HandleFunc("/listVMs", manager.listVMsHandler)
 	html.HandleFunc("/showHypervisor", manager.showHypervisorHandler)
 	go manager.notifierLoop()
@@ -375,6 +375,13 @@ func (m *Manager) manageHypervisorLoop(h *hypervisorType, hostname string) {
 		h.logger.Printf("error reading tags, not managing hypervisor: %s", err)
 		return
 	}
+	h.unschedulable, err = m.storer.ReadMachineUnschedulable(
+		h.machine.HostIpAddress)
+	if err != nil {
+		h.logger.Printf(
+			"error reading schedulability, not managing hypervisor: %s", err)
+		return
+	}
 	for _, vmIpAddr := range vmList {
 		pVmInfo, err := m.storer.ReadVm(h.machine.HostIpAddress, vmIpAddr)
 		if err != nil {
@@ -600,6 +607,12 @@ func (m *Manager) processHypervisorUpdate(h *hypervisorType,
 	if update.HaveSerialNumber && update.SerialNumber != "" {
 		h.serialNumber = update.SerialNumber
 	}
+	if len(update.IdentityPublicKey) > 0 {
+		h.identityPublicKey = update.IdentityPublicKey
+	}
+	if update.Resources != nil {
+		h.resources = update.Resources
+	}
 	h.mutex.Unlock()
 	if !firstUpdate && update.HealthStatus != oldHealthStatus {
 		h.logger.Printf("health status changed from: \"%s\" to: \"%s\"\n",
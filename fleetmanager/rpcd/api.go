@@ -36,12 +36,17 @@ func Setup(hypervisorsManager *hypervisors.Manager, logger log.DebugLogger) (
 		srpc.ReceiverOptions{
 			PublicMethods: []string{
 				"ChangeMachineTags",
+				"CheckVMPlacement",
+				"DrainHypervisor",
 				"GetHypervisorForVM",
+				"GetIdentityKeys",
 				"GetMachineInfo",
 				"GetUpdates",
 				"ListHypervisorLocations",
 				"ListHypervisorsInLocation",
 				"ListVMsInLocation",
+				"SelectHypervisorsForVM",
+				"UndrainHypervisor",
 			}})
 	return (*htmlWriter)(srpcObj), nil
 }
@@ -0,0 +1,19 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) CheckVMPlacement(conn *srpc.Conn,
+	request proto.CheckVMPlacementRequest,
+	reply *proto.CheckVMPlacementResponse) error {
+	warnings, err := t.hypervisorsManager.CheckVmPlacement(
+		request.HypervisorHostname, request.VmInfo)
+	*reply = proto.CheckVMPlacementResponse{
+		Error:    errors.ErrorToString(err),
+		Warnings: warnings,
+	}
+	return nil
+}
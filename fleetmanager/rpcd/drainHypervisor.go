@@ -0,0 +1,26 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) DrainHypervisor(conn *srpc.Conn) error {
+	var request proto.DrainHypervisorRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	// Keep draining if the client goes away: ignore send errors.
+	progressFunc := func(message string) {
+		conn.Encode(proto.DrainHypervisorResponse{ProgressMessage: message})
+		conn.Flush()
+	}
+	failures, err := t.hypervisorsManager.DrainHypervisor(request,
+		conn.GetAuthInformation(), progressFunc)
+	return conn.Encode(proto.DrainHypervisorResponse{
+		Error:      errors.ErrorToString(err),
+		Final:      err == nil,
+		VmFailures: failures,
+	})
+}
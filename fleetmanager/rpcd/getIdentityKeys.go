@@ -0,0 +1,15 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) GetIdentityKeys(conn *srpc.Conn,
+	request proto.GetIdentityKeysRequest,
+	reply *proto.GetIdentityKeysResponse) error {
+	*reply = proto.GetIdentityKeysResponse{
+		Keys: t.hypervisorsManager.GetIdentityKeys(),
+	}
+	return nil
+}
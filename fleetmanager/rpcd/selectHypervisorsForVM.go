@@ -0,0 +1,16 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) SelectHypervisorsForVM(conn *srpc.Conn,
+	request proto.SelectHypervisorsForVMRequest,
+	reply *proto.SelectHypervisorsForVMResponse) error {
+	response, err := t.hypervisorsManager.SelectHypervisorsForVm(request)
+	response.Error = errors.ErrorToString(err)
+	*reply = response
+	return nil
+}
@@ -0,0 +1,17 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) UndrainHypervisor(conn *srpc.Conn,
+	request proto.UndrainHypervisorRequest,
+	reply *proto.UndrainHypervisorResponse) error {
+	*reply = proto.UndrainHypervisorResponse{
+		Error: errors.ErrorToString(t.hypervisorsManager.UndrainHypervisor(
+			request.Hostname, conn.GetAuthInformation())),
+	}
+	return nil
+}
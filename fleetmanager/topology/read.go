@@ -116,6 +116,9 @@ func loadSubnets(filename string) ([]*Subnet, error) {
 		} else {
 			gatewayIPs[gatewayIp] = struct{}{}
 		}
+		if _, err := subnet.GetIpv6Network(); err != nil {
+			return nil, err
+		}
 		subnet.reservedIpAddrs = make(map[string]struct{})
 		for _, ipAddr := range subnet.ReservedIPs {
 			subnet.reservedIpAddrs[ipAddr.String()] = struct{}{}
@@ -195,6 +198,9 @@ func (state *commonStateType) addNetworkEntry(entry proto.NetworkEntry) error {
 	if err := state.addIpAddress(entry.HostIpAddress); err != nil {
 		return err
 	}
+	if err := state.addIpAddress(entry.HostIpv6Address); err != nil {
+		return err
+	}
 	if err := state.addMacAddress(entry.HostMacAddress); err != nil {
 		return err
 	}
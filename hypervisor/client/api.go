@@ -1,6 +1,7 @@
 package client
 
 import (
+	"io"
 	"net"
 
 	"github.com/Symantec/Dominator/lib/log"
@@ -12,11 +13,23 @@ func AcknowledgeVm(client *srpc.Client, ipAddress net.IP) error {
 	return acknowledgeVm(client, ipAddress)
 }
 
+func ChangeVmSize(client *srpc.Client, ipAddr net.IP, memoryInMiB uint64,
+	milliCPUs uint) error {
+	return changeVmSize(client, ipAddr, memoryInMiB, milliCPUs)
+}
+
 func CreateVm(client *srpc.Client, request proto.CreateVmRequest,
 	reply *proto.CreateVmResponse, logger log.DebugLogger) error {
 	return createVm(client, request, reply, logger)
 }
 
+func CreateVmWithData(client *srpc.Client, request proto.CreateVmRequest,
+	reply *proto.CreateVmResponse, imageReader, userDataReader io.Reader,
+	logger log.DebugLogger) error {
+	return createVmWithData(client, request, reply, imageReader,
+		userDataReader, logger)
+}
+
 func DeleteVmVolume(client *srpc.Client, ipAddr net.IP, accessToken []byte,
 	volumeIndex uint) error {
 	return deleteVmVolume(client, ipAddr, accessToken, volumeIndex)
@@ -35,6 +48,11 @@ func GetVmInfo(client *srpc.Client, ipAddr net.IP) (proto.VmInfo, error) {
 	return getVmInfo(client, ipAddr)
 }
 
+func GrowVmVolume(client *srpc.Client, ipAddr net.IP, volumeIndex uint,
+	size uint64) error {
+	return growVmVolume(client, ipAddr, volumeIndex, size)
+}
+
 func PrepareVmForMigration(client *srpc.Client, ipAddr net.IP,
 	accessToken []byte, enable bool) error {
 	return prepareVmForMigration(client, ipAddr, accessToken, enable)
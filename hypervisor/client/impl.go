@@ -2,6 +2,7 @@ package client
 
 import (
 	"fmt"
+	"io"
 	"net"
 
 	"github.com/Symantec/Dominator/lib/errors"
@@ -16,8 +17,29 @@ func acknowledgeVm(client *srpc.Client, ipAddress net.IP) error {
 	return client.RequestReply("Hypervisor.AcknowledgeVm", request, &reply)
 }
 
+func changeVmSize(client *srpc.Client, ipAddr net.IP, memoryInMiB uint64,
+	milliCPUs uint) error {
+	request := proto.ChangeVmSizeRequest{
+		IpAddress:   ipAddr,
+		MemoryInMiB: memoryInMiB,
+		MilliCPUs:   milliCPUs,
+	}
+	var reply proto.ChangeVmSizeResponse
+	err := client.RequestReply("Hypervisor.ChangeVmSize", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
 func createVm(client *srpc.Client, request proto.CreateVmRequest,
 	reply *proto.CreateVmResponse, logger log.DebugLogger) error {
+	return createVmWithData(client, request, reply, nil, nil, logger)
+}
+
+func createVmWithData(client *srpc.Client, request proto.CreateVmRequest,
+	reply *proto.CreateVmResponse, imageReader, userDataReader io.Reader,
+	logger log.DebugLogger) error {
 	if conn, err := client.Call("Hypervisor.CreateVm"); err != nil {
 		return err
 	} else {
@@ -25,6 +47,19 @@ func createVm(client *srpc.Client, request proto.CreateVmRequest,
 		if err := conn.Encode(request); err != nil {
 			return err
 		}
+		// Stream any required data.
+		if imageReader != nil {
+			logger.Debugln(0, "uploading image")
+			if _, err := io.Copy(conn, imageReader); err != nil {
+				return fmt.Errorf("error uploading image: %s", err)
+			}
+		}
+		if userDataReader != nil {
+			logger.Debugln(0, "uploading user data")
+			if _, err := io.Copy(conn, userDataReader); err != nil {
+				return fmt.Errorf("error uploading user data: %s", err)
+			}
+		}
 		if err := conn.Flush(); err != nil {
 			return err
 		}
@@ -105,6 +140,21 @@ func getVmInfo(client *srpc.Client, ipAddr net.IP) (proto.VmInfo, error) {
 	return reply.VmInfo, nil
 }
 
+func growVmVolume(client *srpc.Client, ipAddr net.IP, volumeIndex uint,
+	size uint64) error {
+	request := proto.GrowVmVolumeRequest{
+		IpAddress:   ipAddr,
+		Size:        size,
+		VolumeIndex: volumeIndex,
+	}
+	var reply proto.GrowVmVolumeResponse
+	err := client.RequestReply("Hypervisor.GrowVmVolume", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
 func prepareVmForMigration(client *srpc.Client, ipAddr net.IP,
 	accessToken []byte, enable bool) error {
 	request := proto.PrepareVmForMigrationRequest{
@@ -0,0 +1,90 @@
+package cloudinit
+
+import (
+	"io"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// MetaData is the OpenStack meta_data.json document.
+type MetaData struct {
+	Hostname   string            `json:"hostname"`
+	Meta       map[string]string `json:"meta,omitempty"`
+	Name       string            `json:"name"`
+	PublicKeys map[string]string `json:"public_keys,omitempty"`
+	Uuid       string            `json:"uuid"`
+}
+
+// NetworkData is the OpenStack network_data.json document.
+type NetworkData struct {
+	Links    []NetworkLink    `json:"links"`
+	Networks []Network        `json:"networks"`
+	Services []NetworkService `json:"services"`
+}
+
+type Network struct {
+	Id        string         `json:"id"`
+	IpAddress string         `json:"ip_address,omitempty"`
+	Link      string         `json:"link"`
+	Netmask   string         `json:"netmask,omitempty"`
+	NetworkId string         `json:"network_id"`
+	Routes    []NetworkRoute `json:"routes,omitempty"`
+	Type      string         `json:"type"`
+}
+
+type NetworkLink struct {
+	EthernetMacAddress string `json:"ethernet_mac_address"`
+	Id                 string `json:"id"`
+	Type               string `json:"type"`
+}
+
+type NetworkRoute struct {
+	Gateway string `json:"gateway"`
+	Netmask string `json:"netmask"`
+	Network string `json:"network"`
+}
+
+type NetworkService struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+}
+
+// Hostname returns the hostname for the VM. If the VM does not have a
+// hostname, one is derived from its IP address.
+func Hostname(vmInfo proto.VmInfo) string {
+	return hostname(vmInfo)
+}
+
+// InstanceId returns the instance ID for the VM. It is derived from the MAC
+// address, so it does not change when the VM is migrated but a copy of the VM
+// has a different instance ID.
+func InstanceId(vmInfo proto.VmInfo) string {
+	return instanceId(vmInfo)
+}
+
+// InterfaceName returns the name of the network interface with the specified
+// index (0 is the primary interface).
+func InterfaceName(index int) string {
+	return interfaceName(index)
+}
+
+// MakeMetaData will make the OpenStack meta_data.json document for the VM.
+func MakeMetaData(vmInfo proto.VmInfo) *MetaData {
+	return makeMetaData(vmInfo)
+}
+
+// MakeNetworkData will make the OpenStack network_data.json document for the
+// VM. The subnets are keyed by subnet ID.
+func MakeNetworkData(vmInfo proto.VmInfo,
+	subnets map[string]proto.Subnet) (*NetworkData, error) {
+	return makeNetworkData(vmInfo, subnets)
+}
+
+// WriteSeed will write an ISO9660 image to filename containing the cloud-init
+// seed for the VM, using the datasource specified in the VM information. The
+// subnets are keyed by subnet ID. The genisoimage utility is used to create
+// the image.
+func WriteSeed(filename string, vmInfo proto.VmInfo,
+	subnets map[string]proto.Subnet, userData io.Reader) error {
+	return writeSeed(filename, vmInfo, subnets, userData)
+}
@@ -0,0 +1,49 @@
+package cloudinit
+
+import (
+	"fmt"
+	"strings"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func hostname(vmInfo proto.VmInfo) string {
+	if vmInfo.Hostname != "" {
+		return vmInfo.Hostname
+	}
+	if ipAddr := vmInfo.Address.IpAddress.To4(); ipAddr != nil {
+		return fmt.Sprintf("ip-%d-%d-%d-%d",
+			ipAddr[0], ipAddr[1], ipAddr[2], ipAddr[3])
+	}
+	return instanceId(vmInfo)
+}
+
+func instanceId(vmInfo proto.VmInfo) string {
+	return "i-" + strings.Replace(vmInfo.Address.MacAddress, ":", "", -1)
+}
+
+func interfaceName(index int) string {
+	return fmt.Sprintf("eth%d", index)
+}
+
+func makeMetaData(vmInfo proto.VmInfo) *MetaData {
+	metaData := &MetaData{
+		Hostname: hostname(vmInfo),
+		Name:     hostname(vmInfo),
+		Uuid:     instanceId(vmInfo),
+	}
+	if len(vmInfo.Tags) > 0 {
+		metaData.Meta = make(map[string]string, len(vmInfo.Tags))
+		for key, value := range vmInfo.Tags {
+			metaData.Meta[key] = value
+		}
+	}
+	if len(vmInfo.SshPublicKeys) > 0 {
+		metaData.PublicKeys = make(map[string]string,
+			len(vmInfo.SshPublicKeys))
+		for index, key := range vmInfo.SshPublicKeys {
+			metaData.PublicKeys[fmt.Sprintf("key-%d", index)] = key
+		}
+	}
+	return metaData
+}
@@ -0,0 +1,119 @@
+package cloudinit
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/tags"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestMakeMetaData(t *testing.T) {
+	var tests = []struct {
+		name   string
+		vmInfo proto.VmInfo
+		want   *MetaData
+	}{
+		{
+			name: "hostname",
+			vmInfo: proto.VmInfo{
+				Address:  primaryAddress,
+				Hostname: "test.example.com",
+			},
+			want: &MetaData{
+				Hostname: "test.example.com",
+				Name:     "test.example.com",
+				Uuid:     "i-525400000001",
+			},
+		},
+		{
+			name:   "hostname from IPv4 address",
+			vmInfo: proto.VmInfo{Address: primaryAddress},
+			want: &MetaData{
+				Hostname: "ip-10-0-0-10",
+				Name:     "ip-10-0-0-10",
+				Uuid:     "i-525400000001",
+			},
+		},
+		{
+			name: "hostname from instance ID",
+			vmInfo: proto.VmInfo{
+				Address: proto.Address{MacAddress: "52:54:00:00:00:01"},
+			},
+			want: &MetaData{
+				Hostname: "i-525400000001",
+				Name:     "i-525400000001",
+				Uuid:     "i-525400000001",
+			},
+		},
+		{
+			name: "hostname from IPv6 only address",
+			vmInfo: proto.VmInfo{
+				Address: proto.Address{
+					IpAddress:  net.ParseIP("fd00::10"),
+					MacAddress: "52:54:00:00:00:01",
+				},
+			},
+			want: &MetaData{
+				Hostname: "i-525400000001",
+				Name:     "i-525400000001",
+				Uuid:     "i-525400000001",
+			},
+		},
+		{
+			name: "tags and keys",
+			vmInfo: proto.VmInfo{
+				Address:       primaryAddress,
+				SshPublicKeys: []string{"ssh-rsa AAAA1", "ssh-rsa AAAA2"},
+				Tags:          tags.Tags{"Name": "test", "Owner": "team"},
+			},
+			want: &MetaData{
+				Hostname: "ip-10-0-0-10",
+				Meta:     map[string]string{"Name": "test", "Owner": "team"},
+				Name:     "ip-10-0-0-10",
+				PublicKeys: map[string]string{
+					"key-0": "ssh-rsa AAAA1",
+					"key-1": "ssh-rsa AAAA2",
+				},
+				Uuid: "i-525400000001",
+			},
+		},
+		{
+			name: "multiple interfaces",
+			vmInfo: proto.VmInfo{
+				Address:            primaryAddress,
+				SecondaryAddresses: []proto.Address{secondaryAddress},
+				SecondarySubnetIDs: []string{"secondary"},
+			},
+			want: &MetaData{
+				Hostname: "ip-10-0-0-10",
+				Name:     "ip-10-0-0-10",
+				Uuid:     "i-525400000001",
+			},
+		},
+	}
+	for _, test := range tests {
+		metaData := makeMetaData(test.vmInfo)
+		if !reflect.DeepEqual(metaData, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, metaData, test.want)
+		}
+	}
+}
+
+func TestInterfaceName(t *testing.T) {
+	var tests = []struct {
+		index int
+		want  string
+	}{
+		{0, "eth0"},
+		{1, "eth1"},
+		{10, "eth10"},
+	}
+	for _, test := range tests {
+		if got := interfaceName(test.index); got != test.want {
+			t.Errorf("interfaceName(%d): got %s, want %s",
+				test.index, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package cloudinit
+
+import (
+	"fmt"
+	"net"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// networkConfig is the cloud-init version 1 network configuration.
+type networkConfig struct {
+	Config  []networkConfigEntry `json:"config"`
+	Version uint                 `json:"version"`
+}
+
+type networkConfigEntry struct {
+	Address    string                `json:"address,omitempty"`
+	MacAddress string                `json:"mac_address,omitempty"`
+	Name       string                `json:"name,omitempty"`
+	Search     []string              `json:"search,omitempty"`
+	Subnets    []networkConfigSubnet `json:"subnets,omitempty"`
+	Type       string                `json:"type"`
+}
+
+type networkConfigSubnet struct {
+	Address string `json:"address,omitempty"`
+	Gateway string `json:"gateway,omitempty"`
+	Netmask string `json:"netmask,omitempty"`
+	Type    string `json:"type"`
+}
+
+// getAddressesAndSubnets returns the addresses of the VM and the subnets they
+// are in. The primary address is first.
+func getAddressesAndSubnets(vmInfo proto.VmInfo,
+	subnets map[string]proto.Subnet) ([]proto.Address, []proto.Subnet, error) {
+	addresses := make([]proto.Address, 1, len(vmInfo.SecondaryAddresses)+1)
+	addresses[0] = vmInfo.Address
+	addresses = append(addresses, vmInfo.SecondaryAddresses...)
+	subnetIDs := make([]string, 1, len(vmInfo.SecondarySubnetIDs)+1)
+	subnetIDs[0] = vmInfo.SubnetId
+	subnetIDs = append(subnetIDs, vmInfo.SecondarySubnetIDs...)
+	if len(addresses) != len(subnetIDs) {
+		return nil, nil, fmt.Errorf("%d addresses but %d subnets",
+			len(addresses), len(subnetIDs))
+	}
+	vmSubnets := make([]proto.Subnet, 0, len(subnetIDs))
+	for _, subnetId := range subnetIDs {
+		if subnet, ok := subnets[subnetId]; !ok {
+			return nil, nil, fmt.Errorf("subnet: %s not found", subnetId)
+		} else {
+			vmSubnets = append(vmSubnets, subnet)
+		}
+	}
+	return addresses, vmSubnets, nil
+}
+
+// hasIpv6 returns true if the address has an IPv6 address on the subnet.
+func hasIpv6(address proto.Address, subnet proto.Subnet) bool {
+	return len(address.Ipv6Address) > 0 && len(subnet.Ipv6Gateway) > 0 &&
+		subnet.Ipv6PrefixLength > 0
+}
+
+func makeNetworkConfig(vmInfo proto.VmInfo,
+	subnets map[string]proto.Subnet) (*networkConfig, error) {
+	addresses, vmSubnets, err := getAddressesAndSubnets(vmInfo, subnets)
+	if err != nil {
+		return nil, err
+	}
+	config := &networkConfig{Version: 1}
+	for index, address := range addresses {
+		subnet := vmSubnets[index]
+		configSubnet := networkConfigSubnet{Type: "dhcp"}
+		if len(address.IpAddress) > 0 {
+			configSubnet = networkConfigSubnet{
+				Address: address.IpAddress.String(),
+				Netmask: subnet.IpMask.String(),
+				Type:    "static",
+			}
+			if index == 0 && len(subnet.IpGateway) > 0 {
+				configSubnet.Gateway = subnet.IpGateway.String()
+			}
+		}
+		configSubnets := []networkConfigSubnet{configSubnet}
+		if hasIpv6(address, subnet) {
+			configSubnet := networkConfigSubnet{
+				Address: fmt.Sprintf("%s/%d",
+					address.Ipv6Address, subnet.Ipv6PrefixLength),
+				Type: "static6",
+			}
+			if index == 0 {
+				configSubnet.Gateway = subnet.Ipv6Gateway.String()
+			}
+			configSubnets = append(configSubnets, configSubnet)
+		}
+		config.Config = append(config.Config, networkConfigEntry{
+			MacAddress: address.MacAddress,
+			Name:       interfaceName(index),
+			Subnets:    configSubnets,
+			Type:       "physical",
+		})
+	}
+	if len(addresses[0].IpAddress) > 0 {
+		primarySubnet := vmSubnets[0]
+		var search []string
+		if primarySubnet.DomainName != "" {
+			search = []string{primarySubnet.DomainName}
+		}
+		for _, nameServer := range primarySubnet.DomainNameServers {
+			config.Config = append(config.Config, networkConfigEntry{
+				Address: nameServer.String(),
+				Search:  search,
+				Type:    "nameserver",
+			})
+		}
+	}
+	return config, nil
+}
+
+func makeNetworkData(vmInfo proto.VmInfo,
+	subnets map[string]proto.Subnet) (*NetworkData, error) {
+	addresses, vmSubnets, err := getAddressesAndSubnets(vmInfo, subnets)
+	if err != nil {
+		return nil, err
+	}
+	networkData := &NetworkData{
+		Links:    make([]NetworkLink, 0, len(addresses)),
+		Networks: make([]Network, 0, len(addresses)),
+		Services: make([]NetworkService, 0),
+	}
+	for index, address := range addresses {
+		subnet := vmSubnets[index]
+		linkId := interfaceName(index)
+		networkData.Links = append(networkData.Links, NetworkLink{
+			EthernetMacAddress: address.MacAddress,
+			Id:                 linkId,
+			Type:               "phy",
+		})
+		network := Network{
+			Id:        fmt.Sprintf("network%d", index),
+			Link:      linkId,
+			NetworkId: subnet.Id,
+			Type:      "ipv4_dhcp",
+		}
+		if len(address.IpAddress) > 0 {
+			network.IpAddress = address.IpAddress.String()
+			network.Netmask = subnet.IpMask.String()
+			network.Type = "ipv4"
+			if index == 0 && len(subnet.IpGateway) > 0 {
+				network.Routes = []NetworkRoute{{
+					Gateway: subnet.IpGateway.String(),
+					Netmask: "0.0.0.0",
+					Network: "0.0.0.0",
+				}}
+			}
+		}
+		networkData.Networks = append(networkData.Networks, network)
+		if hasIpv6(address, subnet) {
+			netmask := net.CIDRMask(int(subnet.Ipv6PrefixLength),
+				8*net.IPv6len)
+			network := Network{
+				Id:        fmt.Sprintf("network%d-ipv6", index),
+				IpAddress: address.Ipv6Address.String(),
+				Link:      linkId,
+				Netmask:   net.IP(netmask).String(),
+				NetworkId: subnet.Id,
+				Type:      "ipv6",
+			}
+			if index == 0 {
+				network.Routes = []NetworkRoute{{
+					Gateway: subnet.Ipv6Gateway.String(),
+					Netmask: "::",
+					Network: "::",
+				}}
+			}
+			networkData.Networks = append(networkData.Networks, network)
+		}
+	}
+	for _, nameServer := range vmSubnets[0].DomainNameServers {
+		networkData.Services = append(networkData.Services, NetworkService{
+			Address: nameServer.String(),
+			Type:    "dns",
+		})
+	}
+	return networkData, nil
+}
@@ -0,0 +1,477 @@
+package cloudinit
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+var testSubnets = map[string]proto.Subnet{
+	"primary": {
+		Id:                "primary",
+		IpGateway:         net.ParseIP("10.0.0.1"),
+		IpMask:            net.ParseIP("255.255.255.0"),
+		DomainName:        "example.com",
+		DomainNameServers: []net.IP{net.ParseIP("10.0.0.2")},
+		Ipv6Gateway:       net.ParseIP("fd00::1"),
+		Ipv6PrefixLength:  64,
+	},
+	"secondary": {
+		Id:                "secondary",
+		IpGateway:         net.ParseIP("10.1.0.1"),
+		IpMask:            net.ParseIP("255.255.0.0"),
+		DomainNameServers: []net.IP{net.ParseIP("10.1.0.2")},
+		Ipv6Gateway:       net.ParseIP("fd01::1"),
+		Ipv6PrefixLength:  64,
+	},
+	"noDomain": {
+		Id:     "noDomain",
+		IpMask: net.ParseIP("255.255.255.0"),
+		DomainNameServers: []net.IP{
+			net.ParseIP("10.2.0.2"),
+			net.ParseIP("10.2.0.3"),
+		},
+	},
+}
+
+var (
+	primaryAddress = proto.Address{
+		IpAddress:  net.ParseIP("10.0.0.10"),
+		MacAddress: "52:54:00:00:00:01",
+	}
+	primaryDualStackAddress = proto.Address{
+		IpAddress:   net.ParseIP("10.0.0.10"),
+		Ipv6Address: net.ParseIP("fd00::10"),
+		MacAddress:  "52:54:00:00:00:01",
+	}
+	secondaryAddress = proto.Address{
+		IpAddress:  net.ParseIP("10.1.0.20"),
+		MacAddress: "52:54:00:00:00:02",
+	}
+	secondaryDualStackAddress = proto.Address{
+		IpAddress:   net.ParseIP("10.1.0.20"),
+		Ipv6Address: net.ParseIP("fd01::20"),
+		MacAddress:  "52:54:00:00:00:02",
+	}
+)
+
+func TestMakeNetworkConfig(t *testing.T) {
+	var tests = []struct {
+		name    string
+		vmInfo  proto.VmInfo
+		want    []networkConfigEntry
+		wantErr bool
+	}{
+		{
+			name: "static",
+			vmInfo: proto.VmInfo{
+				Address:  primaryAddress,
+				SubnetId: "primary",
+			},
+			want: []networkConfigEntry{
+				{
+					MacAddress: "52:54:00:00:00:01",
+					Name:       "eth0",
+					Subnets: []networkConfigSubnet{{
+						Address: "10.0.0.10",
+						Gateway: "10.0.0.1",
+						Netmask: "255.255.255.0",
+						Type:    "static",
+					}},
+					Type: "physical",
+				},
+				{
+					Address: "10.0.0.2",
+					Search:  []string{"example.com"},
+					Type:    "nameserver",
+				},
+			},
+		},
+		{
+			name: "DHCP",
+			vmInfo: proto.VmInfo{
+				Address:  proto.Address{MacAddress: "52:54:00:00:00:01"},
+				SubnetId: "primary",
+			},
+			want: []networkConfigEntry{{
+				MacAddress: "52:54:00:00:00:01",
+				Name:       "eth0",
+				Subnets:    []networkConfigSubnet{{Type: "dhcp"}},
+				Type:       "physical",
+			}},
+		},
+		{
+			name: "no gateway or domain",
+			vmInfo: proto.VmInfo{
+				Address:  primaryAddress,
+				SubnetId: "noDomain",
+			},
+			want: []networkConfigEntry{
+				{
+					MacAddress: "52:54:00:00:00:01",
+					Name:       "eth0",
+					Subnets: []networkConfigSubnet{{
+						Address: "10.0.0.10",
+						Netmask: "255.255.255.0",
+						Type:    "static",
+					}},
+					Type: "physical",
+				},
+				{Address: "10.2.0.2", Type: "nameserver"},
+				{Address: "10.2.0.3", Type: "nameserver"},
+			},
+		},
+		{
+			name: "IPv6",
+			vmInfo: proto.VmInfo{
+				Address:  primaryDualStackAddress,
+				SubnetId: "primary",
+			},
+			want: []networkConfigEntry{
+				{
+					MacAddress: "52:54:00:00:00:01",
+					Name:       "eth0",
+					Subnets: []networkConfigSubnet{
+						{
+							Address: "10.0.0.10",
+							Gateway: "10.0.0.1",
+							Netmask: "255.255.255.0",
+							Type:    "static",
+						},
+						{
+							Address: "fd00::10/64",
+							Gateway: "fd00::1",
+							Type:    "static6",
+						},
+					},
+					Type: "physical",
+				},
+				{
+					Address: "10.0.0.2",
+					Search:  []string{"example.com"},
+					Type:    "nameserver",
+				},
+			},
+		},
+		{
+			name: "multiple interfaces",
+			vmInfo: proto.VmInfo{
+				Address:            primaryDualStackAddress,
+				SecondaryAddresses: []proto.Address{secondaryDualStackAddress},
+				SecondarySubnetIDs: []string{"secondary"},
+				SubnetId:           "primary",
+			},
+			want: []networkConfigEntry{
+				{
+					MacAddress: "52:54:00:00:00:01",
+					Name:       "eth0",
+					Subnets: []networkConfigSubnet{
+						{
+							Address: "10.0.0.10",
+							Gateway: "10.0.0.1",
+							Netmask: "255.255.255.0",
+							Type:    "static",
+						},
+						{
+							Address: "fd00::10/64",
+							Gateway: "fd00::1",
+							Type:    "static6",
+						},
+					},
+					Type: "physical",
+				},
+				{
+					MacAddress: "52:54:00:00:00:02",
+					Name:       "eth1",
+					Subnets: []networkConfigSubnet{
+						{
+							Address: "10.1.0.20",
+							Netmask: "255.255.0.0",
+							Type:    "static",
+						},
+						{
+							Address: "fd01::20/64",
+							Type:    "static6",
+						},
+					},
+					Type: "physical",
+				},
+				{
+					Address: "10.0.0.2",
+					Search:  []string{"example.com"},
+					Type:    "nameserver",
+				},
+			},
+		},
+		{
+			name: "multiple interfaces with DHCP secondary",
+			vmInfo: proto.VmInfo{
+				Address: primaryAddress,
+				SecondaryAddresses: []proto.Address{
+					{MacAddress: "52:54:00:00:00:02"},
+					{MacAddress: "52:54:00:00:00:03"},
+				},
+				SecondarySubnetIDs: []string{"secondary", "noDomain"},
+				SubnetId:           "primary",
+			},
+			want: []networkConfigEntry{
+				{
+					MacAddress: "52:54:00:00:00:01",
+					Name:       "eth0",
+					Subnets: []networkConfigSubnet{{
+						Address: "10.0.0.10",
+						Gateway: "10.0.0.1",
+						Netmask: "255.255.255.0",
+						Type:    "static",
+					}},
+					Type: "physical",
+				},
+				{
+					MacAddress: "52:54:00:00:00:02",
+					Name:       "eth1",
+					Subnets:    []networkConfigSubnet{{Type: "dhcp"}},
+					Type:       "physical",
+				},
+				{
+					MacAddress: "52:54:00:00:00:03",
+					Name:       "eth2",
+					Subnets:    []networkConfigSubnet{{Type: "dhcp"}},
+					Type:       "physical",
+				},
+				{
+					Address: "10.0.0.2",
+					Search:  []string{"example.com"},
+					Type:    "nameserver",
+				},
+			},
+		},
+		{
+			name: "unknown subnet",
+			vmInfo: proto.VmInfo{
+				Address:  primaryAddress,
+				SubnetId: "missing",
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown secondary subnet",
+			vmInfo: proto.VmInfo{
+				Address:            primaryAddress,
+				SecondaryAddresses: []proto.Address{secondaryAddress},
+				SecondarySubnetIDs: []string{"missing"},
+				SubnetId:           "primary",
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing secondary subnet",
+			vmInfo: proto.VmInfo{
+				Address:            primaryAddress,
+				SecondaryAddresses: []proto.Address{secondaryAddress},
+				SubnetId:           "primary",
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		config, err := makeNetworkConfig(test.vmInfo, testSubnets)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if config.Version != 1 {
+			t.Errorf("%s: version: %d, want 1", test.name, config.Version)
+		}
+		if !reflect.DeepEqual(config.Config, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, config.Config,
+				test.want)
+		}
+	}
+}
+
+func TestMakeNetworkData(t *testing.T) {
+	defaultRoute := []NetworkRoute{{
+		Gateway: "10.0.0.1",
+		Netmask: "0.0.0.0",
+		Network: "0.0.0.0",
+	}}
+	var tests = []struct {
+		name    string
+		vmInfo  proto.VmInfo
+		want    *NetworkData
+		wantErr bool
+	}{
+		{
+			name: "static",
+			vmInfo: proto.VmInfo{
+				Address:  primaryAddress,
+				SubnetId: "primary",
+			},
+			want: &NetworkData{
+				Links: []NetworkLink{{
+					EthernetMacAddress: "52:54:00:00:00:01",
+					Id:                 "eth0",
+					Type:               "phy",
+				}},
+				Networks: []Network{{
+					Id:        "network0",
+					IpAddress: "10.0.0.10",
+					Link:      "eth0",
+					Netmask:   "255.255.255.0",
+					NetworkId: "primary",
+					Routes:    defaultRoute,
+					Type:      "ipv4",
+				}},
+				Services: []NetworkService{
+					{Address: "10.0.0.2", Type: "dns"},
+				},
+			},
+		},
+		{
+			name: "DHCP",
+			vmInfo: proto.VmInfo{
+				Address:  proto.Address{MacAddress: "52:54:00:00:00:01"},
+				SubnetId: "noDomain",
+			},
+			want: &NetworkData{
+				Links: []NetworkLink{{
+					EthernetMacAddress: "52:54:00:00:00:01",
+					Id:                 "eth0",
+					Type:               "phy",
+				}},
+				Networks: []Network{{
+					Id:        "network0",
+					Link:      "eth0",
+					NetworkId: "noDomain",
+					Type:      "ipv4_dhcp",
+				}},
+				Services: []NetworkService{
+					{Address: "10.2.0.2", Type: "dns"},
+					{Address: "10.2.0.3", Type: "dns"},
+				},
+			},
+		},
+		{
+			name: "multiple interfaces",
+			vmInfo: proto.VmInfo{
+				Address: primaryDualStackAddress,
+				SecondaryAddresses: []proto.Address{
+					secondaryDualStackAddress,
+					{MacAddress: "52:54:00:00:00:03"},
+				},
+				SecondarySubnetIDs: []string{"secondary", "noDomain"},
+				SubnetId:           "primary",
+			},
+			want: &NetworkData{
+				Links: []NetworkLink{
+					{
+						EthernetMacAddress: "52:54:00:00:00:01",
+						Id:                 "eth0",
+						Type:               "phy",
+					},
+					{
+						EthernetMacAddress: "52:54:00:00:00:02",
+						Id:                 "eth1",
+						Type:               "phy",
+					},
+					{
+						EthernetMacAddress: "52:54:00:00:00:03",
+						Id:                 "eth2",
+						Type:               "phy",
+					},
+				},
+				Networks: []Network{
+					{
+						Id:        "network0",
+						IpAddress: "10.0.0.10",
+						Link:      "eth0",
+						Netmask:   "255.255.255.0",
+						NetworkId: "primary",
+						Routes:    defaultRoute,
+						Type:      "ipv4",
+					},
+					{
+						Id:        "network0-ipv6",
+						IpAddress: "fd00::10",
+						Link:      "eth0",
+						Netmask:   "ffff:ffff:ffff:ffff::",
+						NetworkId: "primary",
+						Routes: []NetworkRoute{{
+							Gateway: "fd00::1",
+							Netmask: "::",
+							Network: "::",
+						}},
+						Type: "ipv6",
+					},
+					{
+						Id:        "network1",
+						IpAddress: "10.1.0.20",
+						Link:      "eth1",
+						Netmask:   "255.255.0.0",
+						NetworkId: "secondary",
+						Type:      "ipv4",
+					},
+					{
+						Id:        "network1-ipv6",
+						IpAddress: "fd01::20",
+						Link:      "eth1",
+						Netmask:   "ffff:ffff:ffff:ffff::",
+						NetworkId: "secondary",
+						Type:      "ipv6",
+					},
+					{
+						Id:        "network2",
+						Link:      "eth2",
+						NetworkId: "noDomain",
+						Type:      "ipv4_dhcp",
+					},
+				},
+				Services: []NetworkService{
+					{Address: "10.0.0.2", Type: "dns"},
+				},
+			},
+		},
+		{
+			name: "unknown subnet",
+			vmInfo: proto.VmInfo{
+				Address:  primaryAddress,
+				SubnetId: "missing",
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing secondary subnet",
+			vmInfo: proto.VmInfo{
+				Address:            primaryAddress,
+				SecondaryAddresses: []proto.Address{secondaryAddress},
+				SubnetId:           "primary",
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		networkData, err := makeNetworkData(test.vmInfo, testSubnets)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(networkData, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, networkData,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cloudinit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/json"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	dirPerms  = syscall.S_IRWXU
+	filePerms = syscall.S_IRUSR | syscall.S_IWUSR
+)
+
+type noCloudMetaData struct {
+	InstanceId    string   `json:"instance-id"`
+	LocalHostname string   `json:"local-hostname"`
+	PublicKeys    []string `json:"public-keys,omitempty"`
+}
+
+// writeConfigDrive will write the files for the OpenStack ConfigDrive
+// datasource into topDir.
+func writeConfigDrive(topDir string, vmInfo proto.VmInfo,
+	subnets map[string]proto.Subnet, userData io.Reader) error {
+	dirname := filepath.Join(topDir, "openstack", "latest")
+	if err := os.MkdirAll(dirname, dirPerms); err != nil {
+		return err
+	}
+	err := json.WriteToFile(filepath.Join(dirname, "meta_data.json"),
+		filePerms, "    ", makeMetaData(vmInfo))
+	if err != nil {
+		return err
+	}
+	networkData, err := makeNetworkData(vmInfo, subnets)
+	if err != nil {
+		return err
+	}
+	err = json.WriteToFile(filepath.Join(dirname, "network_data.json"),
+		filePerms, "    ", networkData)
+	if err != nil {
+		return err
+	}
+	if userData == nil {
+		return nil
+	}
+	return fsutil.CopyToFile(filepath.Join(dirname, "user_data"), filePerms,
+		userData, 0)
+}
+
+// writeNoCloud will write the files for the NoCloud datasource into topDir.
+// The meta-data and network-config files are written as JSON, which is a
+// subset of YAML.
+func writeNoCloud(topDir string, vmInfo proto.VmInfo,
+	subnets map[string]proto.Subnet, userData io.Reader) error {
+	err := json.WriteToFile(filepath.Join(topDir, "meta-data"), filePerms,
+		"    ", noCloudMetaData{
+			InstanceId:    instanceId(vmInfo),
+			LocalHostname: hostname(vmInfo),
+			PublicKeys:    vmInfo.SshPublicKeys,
+		})
+	if err != nil {
+		return err
+	}
+	config, err := makeNetworkConfig(vmInfo, subnets)
+	if err != nil {
+		return err
+	}
+	err = json.WriteToFile(filepath.Join(topDir, "network-config"), filePerms,
+		"    ", config)
+	if err != nil {
+		return err
+	}
+	if userData == nil {
+		userData = &bytes.Buffer{} // The user-data file must exist.
+	}
+	return fsutil.CopyToFile(filepath.Join(topDir, "user-data"), filePerms,
+		userData, 0)
+}
+
+func writeSeed(filename string, vmInfo proto.VmInfo,
+	subnets map[string]proto.Subnet, userData io.Reader) error {
+	topDir, err := ioutil.TempDir("", "cloud-init.")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(topDir)
+	var volumeId string
+	switch vmInfo.CloudInitDatasource {
+	case proto.CloudInitDatasourceNoCloud:
+		volumeId = "cidata"
+		err = writeNoCloud(topDir, vmInfo, subnets, userData)
+	case proto.CloudInitDatasourceConfigDrive:
+		volumeId = "config-2"
+		err = writeConfigDrive(topDir, vmInfo, subnets, userData)
+	default:
+		return errors.New("no cloud-init datasource")
+	}
+	if err != nil {
+		return err
+	}
+	tmpFilename := filename + "~"
+	defer os.Remove(tmpFilename)
+	cmd := exec.Command("genisoimage", "-output", tmpFilename,
+		"-volid", volumeId, "-joliet", "-rock", "-quiet", topDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running genisoimage: %s: %s", err, output)
+	}
+	return os.Rename(tmpFilename, filename)
+}
@@ -9,12 +9,15 @@ import (
 )
 
 type DhcpServer struct {
+	ifIndices        map[int]struct{}
 	logger           log.DebugLogger
 	myIP             net.IP
 	networkBootImage []byte
+	serverDuid       []byte
 	mutex            sync.RWMutex             // Protect everything below.
 	ackChannels      map[string]chan struct{} // Key: IPaddr.
 	ipAddrToMacAddr  map[string]string        // Key: IPaddr, V: MACaddr.
+	ipv6Interfaces   map[string]int           // Key: MACaddr, V: ifIndex.
 	leases           map[string]leaseType     // Key: MACaddr.
 	requestChannels  map[string]chan net.IP   // Key: MACaddr.
 	subnets          []proto.Subnet
@@ -0,0 +1,360 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Symantec/Dominator/lib/net/util"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+	"golang.org/x/net/bpf"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	dhcpv6Solicit            = 1
+	dhcpv6Advertise          = 2
+	dhcpv6Request            = 3
+	dhcpv6Confirm            = 4
+	dhcpv6Renew              = 5
+	dhcpv6Rebind             = 6
+	dhcpv6Reply              = 7
+	dhcpv6Release            = 8
+	dhcpv6Decline            = 9
+	dhcpv6InformationRequest = 11
+
+	dhcpv6OptionClientId    = 1
+	dhcpv6OptionServerId    = 2
+	dhcpv6OptionIaNa        = 3
+	dhcpv6OptionIaAddr      = 5
+	dhcpv6OptionStatusCode  = 13
+	dhcpv6OptionRapidCommit = 14
+	dhcpv6OptionDnsServers  = 23
+	dhcpv6OptionDomainList  = 24
+
+	dhcpv6ServerPort = 547
+
+	duidTypeLinkLayer    = 3
+	ethernetTypeIpv6     = 0x86dd
+	hardwareTypeEthernet = 1
+	ipv6HeaderLength     = 40
+	protocolUdp          = 17
+	udpHeaderLength      = 8
+)
+
+var allDhcpv6Servers = net.ParseIP("ff02::1:2")
+
+// encodeDomainName returns a domain name in DNS wire format.
+func encodeDomainName(name string) []byte {
+	buffer := &bytes.Buffer{}
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		buffer.WriteByte(byte(len(label)))
+		buffer.WriteString(label)
+	}
+	buffer.WriteByte(0)
+	return buffer.Bytes()
+}
+
+// getLeaseIpv6Address returns the IPv6 address for a lease. If the lease does
+// not have one it is derived from the subnet and MAC address.
+func getLeaseIpv6Address(lease *leaseType, subnet *proto.Subnet) net.IP {
+	if len(lease.Ipv6Address) > 0 {
+		return lease.Ipv6Address
+	}
+	network, err := subnet.GetIpv6Network()
+	if err != nil || network == nil {
+		return nil
+	}
+	macAddr, err := net.ParseMAC(lease.MacAddress)
+	if err != nil {
+		return nil
+	}
+	ipAddr, _ := util.GetEui64Address(network.IP, macAddr)
+	return ipAddr
+}
+
+// parseDhcpv6Packet returns the source address and the DHCPv6 message of an
+// IPv6 packet sent to the DHCPv6 server port.
+func parseDhcpv6Packet(packet []byte) (*net.UDPAddr, []byte, error) {
+	if len(packet) < ipv6HeaderLength+udpHeaderLength {
+		return nil, nil, errors.New("short packet")
+	}
+	if packet[0]>>4 != 6 {
+		return nil, nil, errors.New("not an IPv6 packet")
+	}
+	if packet[6] != protocolUdp {
+		return nil, nil, errors.New("not a UDP packet")
+	}
+	payloadLength := int(binary.BigEndian.Uint16(packet[4:]))
+	if len(packet) < ipv6HeaderLength+payloadLength {
+		return nil, nil, errors.New("truncated packet")
+	}
+	udp := packet[ipv6HeaderLength : ipv6HeaderLength+payloadLength]
+	if len(udp) < udpHeaderLength ||
+		binary.BigEndian.Uint16(udp[2:]) != dhcpv6ServerPort {
+		return nil, nil, errors.New("not a DHCPv6 server packet")
+	}
+	udpLength := int(binary.BigEndian.Uint16(udp[4:]))
+	if udpLength < udpHeaderLength || udpLength > len(udp) {
+		return nil, nil, errors.New("bad UDP length")
+	}
+	srcAddr := &net.UDPAddr{
+		IP:   net.IP(append([]byte(nil), packet[8:24]...)),
+		Port: int(binary.BigEndian.Uint16(udp)),
+	}
+	return srcAddr, udp[udpHeaderLength:udpLength], nil
+}
+
+func parseDhcpv6Options(data []byte) (map[uint16][]byte, error) {
+	options := make(map[uint16][]byte)
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errors.New("truncated DHCPv6 option header")
+		}
+		code := binary.BigEndian.Uint16(data)
+		length := int(binary.BigEndian.Uint16(data[2:]))
+		if len(data) < 4+length {
+			return nil, errors.New("truncated DHCPv6 option")
+		}
+		if _, ok := options[code]; !ok {
+			options[code] = data[4 : 4+length]
+		}
+		data = data[4+length:]
+	}
+	return options, nil
+}
+
+func writeDhcpv6Option(buffer *bytes.Buffer, code uint16, data []byte) {
+	binary.Write(buffer, binary.BigEndian, code)
+	binary.Write(buffer, binary.BigEndian, uint16(len(data)))
+	buffer.Write(data)
+}
+
+func writeDhcpv6Status(buffer *bytes.Buffer, status uint16) {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, status)
+	writeDhcpv6Option(buffer, dhcpv6OptionStatusCode, data)
+}
+
+func (s *DhcpServer) startDhcpv6(interfaces []net.Interface) error {
+	for _, iface := range interfaces {
+		if len(iface.HardwareAddr) == 6 {
+			s.serverDuid = make([]byte, 4, 10)
+			binary.BigEndian.PutUint16(s.serverDuid, duidTypeLinkLayer)
+			binary.BigEndian.PutUint16(s.serverDuid[2:], hardwareTypeEthernet)
+			s.serverDuid = append(s.serverDuid, iface.HardwareAddr...)
+			break
+		}
+	}
+	if len(s.serverDuid) < 1 {
+		return errors.New("no Ethernet interface for DHCPv6 server DUID")
+	}
+	listener, err := net.ListenPacket("udp6", "[::]:547")
+	if err != nil {
+		return err
+	}
+	conn := ipv6.NewPacketConn(listener)
+	for _, iface := range interfaces {
+		iface := iface
+		err := conn.JoinGroup(&iface, &net.UDPAddr{IP: allDhcpv6Servers})
+		if err != nil {
+			listener.Close()
+			return err
+		}
+	}
+	packetSocket, err := openDhcpv6PacketSocket()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	go discardPackets(conn)
+	go s.serveDhcpv6(packetSocket, conn)
+	return nil
+}
+
+// openDhcpv6PacketSocket returns a packet socket which receives the packets
+// sent to the DHCPv6 server port, along with the link-layer source address.
+// Clients are identified by their link-layer address, since neither the DUID
+// nor the link-local address of a client need contain its MAC address.
+func openDhcpv6PacketSocket() (int, error) {
+	filter, err := bpf.Assemble([]bpf.Instruction{
+		bpf.LoadAbsolute{Off: 6, Size: 1}, // IPv6 next header.
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: protocolUdp, SkipTrue: 3},
+		bpf.LoadAbsolute{Off: ipv6HeaderLength + 2, Size: 2}, // UDP dport.
+		bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: dhcpv6ServerPort,
+			SkipTrue: 1},
+		bpf.RetConstant{Val: 0xffff},
+		bpf.RetConstant{Val: 0},
+	})
+	if err != nil {
+		return -1, err
+	}
+	sockFilters := make([]syscall.SockFilter, 0, len(filter))
+	for _, instruction := range filter {
+		sockFilters = append(sockFilters, syscall.SockFilter{
+			Code: instruction.Op,
+			Jt:   instruction.Jt,
+			Jf:   instruction.Jf,
+			K:    instruction.K,
+		})
+	}
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_DGRAM,
+		int(htons(ethernetTypeIpv6)))
+	if err != nil {
+		return -1, err
+	}
+	if err := syscall.AttachLsf(fd, sockFilters); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+func htons(value uint16) uint16 {
+	return value<<8 | value>>8
+}
+
+// discardPackets will read and discard the packets received on a connection
+// which is only used for sending.
+func discardPackets(conn *ipv6.PacketConn) {
+	buffer := make([]byte, 1500)
+	for {
+		if _, _, _, err := conn.ReadFrom(buffer); err != nil {
+			return
+		}
+	}
+}
+
+// makeDhcpv6Reply returns the reply to a DHCPv6 request from the specified
+// MAC address, or nil if no reply should be sent.
+func (s *DhcpServer) makeDhcpv6Reply(request []byte,
+	macAddr net.HardwareAddr) []byte {
+	if len(request) < 4 {
+		return nil
+	}
+	msgType := request[0]
+	options, err := parseDhcpv6Options(request[4:])
+	if err != nil {
+		s.logger.Debugln(0, err)
+		return nil
+	}
+	clientId, ok := options[dhcpv6OptionClientId]
+	if !ok {
+		return nil
+	}
+	if serverId, ok := options[dhcpv6OptionServerId]; ok {
+		if !bytes.Equal(serverId, s.serverDuid) {
+			return nil // Message not for this DHCPv6 server.
+		}
+	}
+	lease, subnet := s.findLease(macAddr.String())
+	if lease == nil || subnet == nil {
+		return nil
+	}
+	ipAddr := getLeaseIpv6Address(lease, subnet)
+	if ipAddr == nil {
+		return nil
+	}
+	replyType := byte(dhcpv6Reply)
+	buffer := &bytes.Buffer{}
+	writeDhcpv6Option(buffer, dhcpv6OptionClientId, clientId)
+	writeDhcpv6Option(buffer, dhcpv6OptionServerId, s.serverDuid)
+	switch msgType {
+	case dhcpv6Solicit:
+		if _, ok := options[dhcpv6OptionRapidCommit]; ok {
+			writeDhcpv6Option(buffer, dhcpv6OptionRapidCommit, nil)
+		} else {
+			replyType = dhcpv6Advertise
+		}
+		fallthrough
+	case dhcpv6Request, dhcpv6Renew, dhcpv6Rebind:
+		iaNa, ok := options[dhcpv6OptionIaNa]
+		if !ok || len(iaNa) < 12 {
+			writeDhcpv6Status(buffer, 2) // NoAddrsAvail.
+			break
+		}
+		s.logger.Debugf(0, "DHCPv6 reply: %s for: %s\n", ipAddr, macAddr)
+		writeDhcpv6Option(buffer, dhcpv6OptionIaNa,
+			makeIaNa(iaNa[:4], ipAddr))
+	case dhcpv6Confirm, dhcpv6Release, dhcpv6Decline:
+		writeDhcpv6Status(buffer, 0) // Success.
+	case dhcpv6InformationRequest:
+	default:
+		s.logger.Debugf(0, "Unsupported DHCPv6 message type: %d\n", msgType)
+		return nil
+	}
+	var dnsServers []byte
+	for _, dnsServer := range subnet.DomainNameServers {
+		if dnsServer.To4() == nil && len(dnsServer) == net.IPv6len {
+			dnsServers = append(dnsServers, dnsServer...)
+		}
+	}
+	if len(dnsServers) > 0 {
+		writeDhcpv6Option(buffer, dhcpv6OptionDnsServers, dnsServers)
+	}
+	if subnet.DomainName != "" {
+		writeDhcpv6Option(buffer, dhcpv6OptionDomainList,
+			encodeDomainName(subnet.DomainName))
+	}
+	header := []byte{replyType, request[1], request[2], request[3]}
+	return append(header, buffer.Bytes()...)
+}
+
+// makeIaNa returns the contents of an Identity Association for Non-temporary
+// Addresses option which assigns the specified address.
+func makeIaNa(iaId []byte, ipAddr net.IP) []byte {
+	lifetime := uint32(leaseTime.Seconds())
+	buffer := &bytes.Buffer{}
+	buffer.Write(iaId)
+	binary.Write(buffer, binary.BigEndian, lifetime/2)   // T1.
+	binary.Write(buffer, binary.BigEndian, lifetime/5*4) // T2.
+	iaAddr := make([]byte, 24)
+	copy(iaAddr, ipAddr.To16())
+	binary.BigEndian.PutUint32(iaAddr[16:], lifetime) // Preferred lifetime.
+	binary.BigEndian.PutUint32(iaAddr[20:], lifetime) // Valid lifetime.
+	writeDhcpv6Option(buffer, dhcpv6OptionIaAddr, iaAddr)
+	return buffer.Bytes()
+}
+
+func (s *DhcpServer) serveDhcpv6(packetSocket int, conn *ipv6.PacketConn) {
+	buffer := make([]byte, 1500)
+	for {
+		nRead, from, err := syscall.Recvfrom(packetSocket, buffer, 0)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			s.logger.Println(err)
+			return
+		}
+		sockaddr, ok := from.(*syscall.SockaddrLinklayer)
+		if !ok || sockaddr.Halen != 6 ||
+			sockaddr.Pkttype == syscall.PACKET_OUTGOING {
+			continue
+		}
+		if _, ok := s.ifIndices[sockaddr.Ifindex]; !ok {
+			continue
+		}
+		srcAddr, request, err := parseDhcpv6Packet(buffer[:nRead])
+		if err != nil {
+			s.logger.Debugln(1, err)
+			continue
+		}
+		macAddr := net.HardwareAddr(append([]byte(nil),
+			sockaddr.Addr[:sockaddr.Halen]...))
+		reply := s.makeDhcpv6Reply(request, macAddr)
+		if reply == nil {
+			continue
+		}
+		srcAddr.Zone = strconv.Itoa(sockaddr.Ifindex)
+		_, err = conn.WriteTo(reply,
+			&ipv6.ControlMessage{IfIndex: sockaddr.Ifindex}, srcAddr)
+		if err != nil {
+			s.logger.Println(err)
+		}
+	}
+}
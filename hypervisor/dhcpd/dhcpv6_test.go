@@ -0,0 +1,274 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+var (
+	testClientId   = []byte{0, 4, 1, 2, 3, 4, 5, 6, 7, 8} // DUID-UUID.
+	testMacAddress = net.HardwareAddr{0x52, 0x54, 0, 0x12, 0x34, 0x56}
+	testServerDuid = []byte{0, duidTypeLinkLayer, 0, hardwareTypeEthernet,
+		0x52, 0x54, 0, 0xab, 0xcd, 0xef}
+)
+
+func makeDhcpv6Option(code uint16, data []byte) []byte {
+	buffer := &bytes.Buffer{}
+	writeDhcpv6Option(buffer, code, data)
+	return buffer.Bytes()
+}
+
+func makeDhcpv6Request(msgType byte, options ...[]byte) []byte {
+	request := []byte{msgType, 0x12, 0x34, 0x56}
+	for _, option := range options {
+		request = append(request, option...)
+	}
+	return request
+}
+
+// makeUdp6Packet returns an IPv6 packet containing a UDP datagram.
+func makeUdp6Packet(src net.IP, srcPort, destPort uint16,
+	payload []byte) []byte {
+	packet := make([]byte, ipv6HeaderLength+udpHeaderLength, 1500)
+	packet[0] = 6 << 4
+	binary.BigEndian.PutUint16(packet[4:],
+		uint16(udpHeaderLength+len(payload)))
+	packet[6] = protocolUdp
+	packet[7] = 1 // Hop limit.
+	copy(packet[8:], src.To16())
+	copy(packet[24:], allDhcpv6Servers)
+	binary.BigEndian.PutUint16(packet[40:], srcPort)
+	binary.BigEndian.PutUint16(packet[42:], destPort)
+	binary.BigEndian.PutUint16(packet[44:],
+		uint16(udpHeaderLength+len(payload)))
+	return append(packet, payload...)
+}
+
+func newTestServer(t *testing.T) *DhcpServer {
+	subnet := &proto.Subnet{
+		DomainName: "example.com",
+		DomainNameServers: []net.IP{
+			net.ParseIP("10.0.0.2").To4(),
+			net.ParseIP("2001:db8::53"),
+		},
+		IpGateway:        net.ParseIP("10.0.0.1").To4(),
+		IpMask:           net.IP{255, 255, 255, 0},
+		Ipv6Gateway:      net.ParseIP("2001:db8::1"),
+		Ipv6PrefixLength: 64,
+	}
+	return &DhcpServer{
+		logger: testlogger.New(t),
+		leases: map[string]leaseType{
+			testMacAddress.String(): {
+				Address: proto.Address{
+					IpAddress:  net.ParseIP("10.0.0.10").To4(),
+					MacAddress: testMacAddress.String(),
+				},
+				subnet: subnet,
+			},
+		},
+		serverDuid: testServerDuid,
+	}
+}
+
+func TestParseDhcpv6Options(t *testing.T) {
+	var tests = []struct {
+		name    string
+		data    []byte
+		want    map[uint16][]byte
+		wantErr bool
+	}{
+		{"empty", nil, map[uint16][]byte{}, false},
+		{"one", []byte{0, 1, 0, 2, 0xaa, 0xbb},
+			map[uint16][]byte{1: {0xaa, 0xbb}}, false},
+		{"two", []byte{0, 1, 0, 1, 0xaa, 0, 14, 0, 0},
+			map[uint16][]byte{1: {0xaa}, 14: {}}, false},
+		{"duplicate", []byte{0, 1, 0, 1, 0xaa, 0, 1, 0, 1, 0xbb},
+			map[uint16][]byte{1: {0xaa}}, false},
+		{"truncated header", []byte{0, 1, 0}, nil, true},
+		{"truncated data", []byte{0, 1, 0, 4, 0xaa}, nil, true},
+	}
+	for _, test := range tests {
+		options, err := parseDhcpv6Options(test.data)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if len(options) != len(test.want) {
+			t.Errorf("%s: got %d options, want %d",
+				test.name, len(options), len(test.want))
+		}
+		for code, data := range test.want {
+			if got, ok := options[code]; !ok || !bytes.Equal(got, data) {
+				t.Errorf("%s: option %d: %v, want %v",
+					test.name, code, got, data)
+			}
+		}
+	}
+}
+
+func TestParseDhcpv6Packet(t *testing.T) {
+	// A stable-privacy link-local address does not contain the MAC address.
+	src := net.ParseIP("fe80::1c3f:9a2b:7d4e:5f60")
+	payload := makeDhcpv6Request(dhcpv6Solicit,
+		makeDhcpv6Option(dhcpv6OptionClientId, testClientId))
+	packet := makeUdp6Packet(src, 546, dhcpv6ServerPort, payload)
+	srcAddr, message, err := parseDhcpv6Packet(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !srcAddr.IP.Equal(src) || srcAddr.Port != 546 {
+		t.Errorf("source: %s, want [%s]:546", srcAddr, src)
+	}
+	if !bytes.Equal(message, payload) {
+		t.Errorf("message: %v, want %v", message, payload)
+	}
+	// Trailing link-layer padding must be ignored.
+	_, message, err = parseDhcpv6Packet(append(packet, 0, 0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(message, payload) {
+		t.Errorf("padded message: %v, want %v", message, payload)
+	}
+	var tests = []struct {
+		name   string
+		packet []byte
+	}{
+		{"short", packet[:ipv6HeaderLength]},
+		{"IPv4", append([]byte{0x45}, packet[1:]...)},
+		{"TCP", append(append([]byte(nil), packet[:6]...),
+			append([]byte{6}, packet[7:]...)...)},
+		{"truncated", packet[:len(packet)-1]},
+		{"wrong port", makeUdp6Packet(src, 546, 546, payload)},
+	}
+	for _, test := range tests {
+		if _, _, err := parseDhcpv6Packet(test.packet); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestMakeDhcpv6Reply(t *testing.T) {
+	clientIdOption := makeDhcpv6Option(dhcpv6OptionClientId, testClientId)
+	iaNaOption := makeDhcpv6Option(dhcpv6OptionIaNa,
+		[]byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0})
+	var tests = []struct {
+		name       string
+		request    []byte
+		macAddr    net.HardwareAddr
+		wantType   byte // Zero if no reply.
+		wantIaNa   bool
+		wantStatus int // -1 if no status.
+	}{
+		{"solicit", makeDhcpv6Request(dhcpv6Solicit, clientIdOption,
+			iaNaOption), testMacAddress, dhcpv6Advertise, true, -1},
+		{"rapid commit", makeDhcpv6Request(dhcpv6Solicit, clientIdOption,
+			iaNaOption, makeDhcpv6Option(dhcpv6OptionRapidCommit, nil)),
+			testMacAddress, dhcpv6Reply, true, -1},
+		{"request", makeDhcpv6Request(dhcpv6Request, clientIdOption,
+			makeDhcpv6Option(dhcpv6OptionServerId, testServerDuid),
+			iaNaOption), testMacAddress, dhcpv6Reply, true, -1},
+		{"no IA_NA", makeDhcpv6Request(dhcpv6Request, clientIdOption),
+			testMacAddress, dhcpv6Reply, false, 2},
+		{"confirm", makeDhcpv6Request(dhcpv6Confirm, clientIdOption),
+			testMacAddress, dhcpv6Reply, false, 0},
+		{"information request",
+			makeDhcpv6Request(dhcpv6InformationRequest, clientIdOption),
+			testMacAddress, dhcpv6Reply, false, -1},
+		{"unknown MAC", makeDhcpv6Request(dhcpv6Solicit, clientIdOption,
+			iaNaOption), net.HardwareAddr{0x52, 0x54, 0, 0, 0, 1}, 0,
+			false, -1},
+		{"other server", makeDhcpv6Request(dhcpv6Request, clientIdOption,
+			makeDhcpv6Option(dhcpv6OptionServerId, []byte{0, 1}),
+			iaNaOption), testMacAddress, 0, false, -1},
+		{"no client id", makeDhcpv6Request(dhcpv6Solicit, iaNaOption),
+			testMacAddress, 0, false, -1},
+		{"unsupported", makeDhcpv6Request(12, clientIdOption),
+			testMacAddress, 0, false, -1},
+		{"short", []byte{dhcpv6Solicit}, testMacAddress, 0, false, -1},
+	}
+	server := newTestServer(t)
+	wantAddress := net.ParseIP("2001:db8::5054:ff:fe12:3456")
+	for _, test := range tests {
+		reply := server.makeDhcpv6Reply(test.request, test.macAddr)
+		if test.wantType == 0 {
+			if reply != nil {
+				t.Errorf("%s: unexpected reply: %v", test.name, reply)
+			}
+			continue
+		}
+		if len(reply) < 4 {
+			t.Errorf("%s: no reply", test.name)
+			continue
+		}
+		if reply[0] != test.wantType {
+			t.Errorf("%s: type: %d, want %d",
+				test.name, reply[0], test.wantType)
+		}
+		if !bytes.Equal(reply[1:4], test.request[1:4]) {
+			t.Errorf("%s: transaction ID not copied", test.name)
+		}
+		options, err := parseDhcpv6Options(reply[4:])
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !bytes.Equal(options[dhcpv6OptionClientId], testClientId) {
+			t.Errorf("%s: bad client ID", test.name)
+		}
+		if !bytes.Equal(options[dhcpv6OptionServerId], testServerDuid) {
+			t.Errorf("%s: bad server ID", test.name)
+		}
+		iaNa, ok := options[dhcpv6OptionIaNa]
+		if ok != test.wantIaNa {
+			t.Errorf("%s: IA_NA present: %t, want %t",
+				test.name, ok, test.wantIaNa)
+		}
+		if ok {
+			if !bytes.Equal(iaNa[:4], []byte{0, 0, 0, 1}) {
+				t.Errorf("%s: IAID not copied", test.name)
+			}
+			iaOptions, err := parseDhcpv6Options(iaNa[12:])
+			iaAddr := iaOptions[dhcpv6OptionIaAddr]
+			if err != nil {
+				t.Errorf("%s: %s", test.name, err)
+			} else if len(iaAddr) != 24 ||
+				!net.IP(iaAddr[:16]).Equal(wantAddress) {
+				t.Errorf("%s: IA address: %v, want %s",
+					test.name, iaAddr, wantAddress)
+			}
+		}
+		status, ok := options[dhcpv6OptionStatusCode]
+		if test.wantStatus < 0 {
+			if ok {
+				t.Errorf("%s: unexpected status: %v", test.name, status)
+			}
+		} else if !ok || len(status) < 2 ||
+			int(binary.BigEndian.Uint16(status)) != test.wantStatus {
+			t.Errorf("%s: status: %v, want %d",
+				test.name, status, test.wantStatus)
+		}
+		wantDns := net.ParseIP("2001:db8::53")
+		if !bytes.Equal(options[dhcpv6OptionDnsServers], wantDns) {
+			t.Errorf("%s: DNS servers: %v, want %s",
+				test.name, options[dhcpv6OptionDnsServers], wantDns)
+		}
+		wantDomain := []byte("\x07example\x03com\x00")
+		if !bytes.Equal(options[dhcpv6OptionDomainList], wantDomain) {
+			t.Errorf("%s: domain list: %q, want %q",
+				test.name, options[dhcpv6OptionDomainList], wantDomain)
+		}
+	}
+}
@@ -29,6 +29,7 @@ func newServer(interfaceNames []string, logger log.DebugLogger) (
 		logger:          logger,
 		ackChannels:     make(map[string]chan struct{}),
 		ipAddrToMacAddr: make(map[string]string),
+		ipv6Interfaces:  make(map[string]int),
 		leases:          make(map[string]leaseType),
 		requestChannels: make(map[string]chan net.IP),
 	}
@@ -55,13 +56,16 @@ func newServer(interfaceNames []string, logger log.DebugLogger) (
 	serveConn := &serveIfConn{
 		ifIndices: make(map[int]struct{}, len(interfaceNames)),
 	}
+	interfaces := make([]net.Interface, 0, len(interfaceNames))
 	for _, interfaceName := range interfaceNames {
 		if iface, err := net.InterfaceByName(interfaceName); err != nil {
 			return nil, err
 		} else {
+			interfaces = append(interfaces, *iface)
 			serveConn.ifIndices[iface.Index] = struct{}{}
 		}
 	}
+	dhcpServer.ifIndices = serveConn.ifIndices
 	listener, err := net.ListenPacket("udp4", ":67")
 	if err != nil {
 		return nil, err
@@ -77,6 +81,13 @@ func newServer(interfaceNames []string, logger log.DebugLogger) (
 			logger.Println(err)
 		}
 	}()
+	// IPv6 may not be available, so failures to start are not fatal.
+	if err := dhcpServer.startDhcpv6(interfaces); err != nil {
+		logger.Printf("Error starting DHCPv6 server: %s\n", err)
+	}
+	if err := dhcpServer.startRouterAdvertiser(interfaces); err != nil {
+		logger.Printf("Error starting Router Advertiser: %s\n", err)
+	}
 	return dhcpServer, nil
 }
 
@@ -267,7 +278,7 @@ func (s *DhcpServer) ServeDHCP(req dhcp.Packet, msgType dhcp.MessageType,
 		}
 		reqIP = util.ShrinkIP(reqIP)
 		macAddr := req.CHAddr().String()
-		s.notifyRequest(proto.Address{reqIP, macAddr})
+		s.notifyRequest(proto.Address{IpAddress: reqIP, MacAddress: macAddr})
 		server, ok := options[dhcp.OptionServerIdentifier]
 		if ok {
 			serverIP := net.IP(server)
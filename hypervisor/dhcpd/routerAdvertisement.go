@@ -0,0 +1,225 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/net/util"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	routerAdvertisementInterval = time.Minute * 10
+
+	ndOptionSourceLinkLayerAddress = 1
+	ndOptionPrefixInformation      = 3
+	ndOptionRecursiveDnsServer     = 25
+)
+
+var (
+	allNodes   = net.ParseIP("ff02::1")
+	allRouters = net.ParseIP("ff02::2")
+	linkLocal  = net.ParseIP("fe80::")
+)
+
+// getSolicitationMacAddress returns the MAC address of the sender of a Router
+// Solicitation, from the source link-layer address option or else from the
+// link-local source address.
+func getSolicitationMacAddress(options []byte, src net.Addr) net.HardwareAddr {
+	for len(options) >= 8 {
+		length := int(options[1]) * 8
+		if length < 1 || length > len(options) {
+			break
+		}
+		if options[0] == ndOptionSourceLinkLayerAddress && length == 8 {
+			return net.HardwareAddr(options[2:8])
+		}
+		options = options[length:]
+	}
+	if ipAddr, ok := src.(*net.IPAddr); ok {
+		return util.GetEui64MacAddress(ipAddr.IP)
+	}
+	return nil
+}
+
+// makeRouterAdvertisement returns the body of a Router Advertisement for a
+// subnet. The Hypervisor is not a router, so the router lifetime is zero and
+// the default route must come from the subnet gateway. Addresses are assigned
+// with DHCPv6 (the M flag), so autonomous address configuration is disabled
+// and VMs only use the addresses in their VM information.
+func makeRouterAdvertisement(network *net.IPNet, dnsServers []net.IP,
+	hardwareAddr net.HardwareAddr) []byte {
+	lifetime := uint32(leaseTime.Seconds())
+	buffer := &bytes.Buffer{}
+	// Hop limit, M and O flags, router lifetime, reachable and retrans times.
+	buffer.Write([]byte{64, 0xc0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	if len(hardwareAddr) == 6 {
+		buffer.Write([]byte{ndOptionSourceLinkLayerAddress, 1})
+		buffer.Write(hardwareAddr)
+	}
+	prefixLength, _ := network.Mask.Size()
+	buffer.Write([]byte{ndOptionPrefixInformation, 4, byte(prefixLength),
+		0x80}) // On-link.
+	binary.Write(buffer, binary.BigEndian, lifetime) // Valid lifetime.
+	binary.Write(buffer, binary.BigEndian, lifetime) // Preferred lifetime.
+	buffer.Write([]byte{0, 0, 0, 0})
+	buffer.Write(network.IP.To16())
+	var ipv6DnsServers []net.IP
+	for _, dnsServer := range dnsServers {
+		if dnsServer.To4() == nil && len(dnsServer) == net.IPv6len {
+			ipv6DnsServers = append(ipv6DnsServers, dnsServer)
+		}
+	}
+	if len(ipv6DnsServers) > 0 {
+		buffer.Write([]byte{ndOptionRecursiveDnsServer,
+			byte(1 + 2*len(ipv6DnsServers)), 0, 0})
+		binary.Write(buffer, binary.BigEndian, lifetime)
+		for _, dnsServer := range ipv6DnsServers {
+			buffer.Write(dnsServer)
+		}
+	}
+	return buffer.Bytes()
+}
+
+func (s *DhcpServer) loopSendRouterAdvertisements(conn *ipv6.PacketConn) {
+	for range time.Tick(routerAdvertisementInterval) {
+		s.mutex.RLock()
+		ipv6Interfaces := make(map[string]int, len(s.ipv6Interfaces))
+		for macAddr, ifIndex := range s.ipv6Interfaces {
+			ipv6Interfaces[macAddr] = ifIndex
+		}
+		s.mutex.RUnlock()
+		for macAddr, ifIndex := range ipv6Interfaces {
+			if !s.sendRouterAdvertisement(conn, macAddr, ifIndex, nil) {
+				s.mutex.Lock()
+				delete(s.ipv6Interfaces, macAddr)
+				s.mutex.Unlock()
+			}
+		}
+	}
+}
+
+// sendRouterAdvertisement will send a Router Advertisement for the subnet of
+// the lease for a MAC address. If dest is nil, the advertisement is sent to
+// the link-local address of the MAC address. It returns false if there is no
+// IPv6 lease for the MAC address.
+func (s *DhcpServer) sendRouterAdvertisement(conn *ipv6.PacketConn,
+	macAddr string, ifIndex int, dest net.Addr) bool {
+	lease, subnet := s.findLease(macAddr)
+	if lease == nil || subnet == nil {
+		return false
+	}
+	network, err := subnet.GetIpv6Network()
+	if err != nil || network == nil {
+		return false
+	}
+	iface, err := net.InterfaceByIndex(ifIndex)
+	if err != nil {
+		s.logger.Println(err)
+		return true
+	}
+	if dest == nil {
+		hardwareAddr, err := net.ParseMAC(macAddr)
+		if err != nil {
+			return false
+		}
+		ipAddr, err := util.GetEui64Address(linkLocal, hardwareAddr)
+		if err != nil {
+			return false
+		}
+		dest = &net.IPAddr{IP: ipAddr, Zone: iface.Name}
+	}
+	message := icmp.Message{
+		Type: ipv6.ICMPTypeRouterAdvertisement,
+		Body: &icmp.RawBody{Data: makeRouterAdvertisement(network,
+			subnet.DomainNameServers, iface.HardwareAddr)},
+	}
+	packet, err := message.Marshal(nil) // Kernel computes the checksum.
+	if err != nil {
+		s.logger.Println(err)
+		return true
+	}
+	_, err = conn.WriteTo(packet, &ipv6.ControlMessage{IfIndex: ifIndex},
+		dest)
+	if err != nil {
+		s.logger.Println(err)
+	} else {
+		s.logger.Debugf(1, "Router Advertisement: %s to: %s\n",
+			network, macAddr)
+	}
+	return true
+}
+
+func (s *DhcpServer) serveRouterSolicitations(conn *ipv6.PacketConn) {
+	buffer := make([]byte, 1500)
+	for {
+		nRead, cm, src, err := conn.ReadFrom(buffer)
+		if err != nil {
+			s.logger.Println(err)
+			return
+		}
+		if cm == nil || nRead < 8 ||
+			buffer[0] != byte(ipv6.ICMPTypeRouterSolicitation) {
+			continue
+		}
+		if _, ok := s.ifIndices[cm.IfIndex]; !ok {
+			continue
+		}
+		macAddr := getSolicitationMacAddress(buffer[8:nRead], src)
+		if macAddr == nil {
+			continue
+		}
+		var dest net.Addr = src
+		if ipAddr, ok := src.(*net.IPAddr); ok && ipAddr.IP.IsUnspecified() {
+			dest = &net.IPAddr{IP: allNodes}
+		}
+		if s.sendRouterAdvertisement(conn, macAddr.String(), cm.IfIndex,
+			dest) {
+			s.mutex.Lock()
+			s.ipv6Interfaces[macAddr.String()] = cm.IfIndex
+			s.mutex.Unlock()
+		}
+	}
+}
+
+func (s *DhcpServer) startRouterAdvertiser(interfaces []net.Interface) error {
+	listener, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return err
+	}
+	conn := listener.IPv6PacketConn()
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeRouterSolicitation)
+	if err := conn.SetICMPFilter(&filter); err != nil {
+		listener.Close()
+		return err
+	}
+	if err := conn.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		listener.Close()
+		return err
+	}
+	// Neighbor Discovery messages must be sent with a hop limit of 255.
+	if err := conn.SetHopLimit(255); err != nil {
+		listener.Close()
+		return err
+	}
+	if err := conn.SetMulticastHopLimit(255); err != nil {
+		listener.Close()
+		return err
+	}
+	for _, iface := range interfaces {
+		iface := iface
+		err := conn.JoinGroup(&iface, &net.IPAddr{IP: allRouters})
+		if err != nil {
+			listener.Close()
+			return err
+		}
+	}
+	go s.serveRouterSolicitations(conn)
+	go s.loopSendRouterAdvertisements(conn)
+	return nil
+}
@@ -0,0 +1,85 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestMakeRouterAdvertisement(t *testing.T) {
+	_, network, _ := net.ParseCIDR("2001:db8::/64")
+	hardwareAddr := net.HardwareAddr{0x52, 0x54, 0, 0xab, 0xcd, 0xef}
+	dnsServer := net.ParseIP("2001:db8::53")
+	lifetime := make([]byte, 4)
+	binary.BigEndian.PutUint32(lifetime, uint32(leaseTime.Seconds()))
+	header := []byte{64, 0xc0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	sourceLinkLayer := append([]byte{ndOptionSourceLinkLayerAddress, 1},
+		hardwareAddr...)
+	prefix := []byte{ndOptionPrefixInformation, 4, 64, 0x80}
+	prefix = append(prefix, lifetime...)
+	prefix = append(prefix, lifetime...)
+	prefix = append(prefix, 0, 0, 0, 0)
+	prefix = append(prefix, network.IP...)
+	rdnss := []byte{ndOptionRecursiveDnsServer, 3, 0, 0}
+	rdnss = append(rdnss, lifetime...)
+	rdnss = append(rdnss, dnsServer...)
+	var tests = []struct {
+		name         string
+		dnsServers   []net.IP
+		hardwareAddr net.HardwareAddr
+		want         [][]byte
+	}{
+		{"minimal", nil, nil, [][]byte{header, prefix}},
+		{"link-layer address", nil, hardwareAddr,
+			[][]byte{header, sourceLinkLayer, prefix}},
+		{"DNS", []net.IP{net.ParseIP("10.0.0.2").To4(), dnsServer},
+			hardwareAddr, [][]byte{header, sourceLinkLayer, prefix, rdnss}},
+	}
+	for _, test := range tests {
+		got := makeRouterAdvertisement(network, test.dnsServers,
+			test.hardwareAddr)
+		want := bytes.Join(test.want, nil)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: got:\n%v\nwant:\n%v", test.name, got, want)
+		}
+		// Addresses come from DHCPv6, so SLAAC must be disabled.
+		if got[1]&0xc0 != 0xc0 {
+			t.Errorf("%s: M and O flags not set", test.name)
+		}
+		prefixOffset := len(header)
+		if len(test.hardwareAddr) > 0 {
+			prefixOffset += len(sourceLinkLayer)
+		}
+		if got[prefixOffset+3]&0x40 != 0 {
+			t.Errorf("%s: autonomous flag set", test.name)
+		}
+	}
+}
+
+func TestGetSolicitationMacAddress(t *testing.T) {
+	macAddr := net.HardwareAddr{0x52, 0x54, 0, 0x12, 0x34, 0x56}
+	eui64Addr := &net.IPAddr{IP: net.ParseIP("fe80::5054:ff:fe12:3456")}
+	var tests = []struct {
+		name    string
+		options []byte
+		src     net.Addr
+		want    net.HardwareAddr
+	}{
+		{"option", append([]byte{ndOptionSourceLinkLayerAddress, 1},
+			macAddr...), &net.IPAddr{IP: net.ParseIP("fe80::1")}, macAddr},
+		{"EUI-64", nil, eui64Addr, macAddr},
+		{"other option", []byte{ndOptionPrefixInformation, 1, 0, 0, 0, 0, 0,
+			0}, eui64Addr, macAddr},
+		{"bad option length", []byte{ndOptionSourceLinkLayerAddress, 0, 0, 0,
+			0, 0, 0, 0}, eui64Addr, macAddr},
+		{"stable-privacy", nil,
+			&net.IPAddr{IP: net.ParseIP("fe80::1c3f:9a2b:7d4e:5f60")}, nil},
+	}
+	for _, test := range tests {
+		got := getSolicitationMacAddress(test.options, test.src)
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
@@ -32,6 +32,7 @@ func StartServer(portNum uint, managerObj *manager.Manager, daemon bool) error {
 	html.HandleFunc("/listSubnets", myState.listSubnetsHandler)
 	html.HandleFunc("/listVMs", myState.listVMsHandler)
 	html.HandleFunc("/showVmBootLog", myState.showBootLogHandler)
+	html.HandleFunc("/showVmFirewall", myState.showVmFirewallHandler)
 	html.HandleFunc("/showVM", myState.showVMHandler)
 	if daemon {
 		go http.Serve(listener, nil)
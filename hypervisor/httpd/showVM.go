@@ -12,6 +12,7 @@ import (
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/url"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
 
 func (s state) showVMHandler(w http.ResponseWriter, req *http.Request) {
@@ -67,6 +68,10 @@ func (s state) showVMHandler(w http.ResponseWriter, req *http.Request) {
 			writeString(writer, "Hostname", vm.Hostname)
 		}
 		writeString(writer, "MAC Address", vm.Address.MacAddress)
+		if len(vm.Address.Ipv6Address) > 0 {
+			writeString(writer, "IPv6 Address",
+				vm.Address.Ipv6Address.String())
+		}
 		if vm.ImageName != "" {
 			image := fmt.Sprintf("<a href=\"http://%s/showImage?%s\">%s</a>",
 				s.manager.GetImageServerAddress(), vm.ImageName, vm.ImageName)
@@ -76,14 +81,76 @@ func (s state) showVMHandler(w http.ResponseWriter, req *http.Request) {
 		} else {
 			writeString(writer, "Boot image", "was streamed in")
 		}
+		if vm.CloudInitDatasource != proto.CloudInitDatasourceNone {
+			writeString(writer, "cloud-init datasource",
+				vm.CloudInitDatasource.String())
+		}
 		writeString(writer, "State", vm.State.String())
 		writeString(writer, "RAM", format.FormatBytes(vm.MemoryInMiB<<20))
+		writeBool(writer, "Memory balloon", vm.MemoryBalloon)
 		writeFloat(writer, "CPU", float64(vm.MilliCPUs)*1e-3)
 		writeStrings(writer, "Volume sizes", volumeSizes)
 		writeString(writer, "Total storage", format.FormatBytes(storage))
 		writeStrings(writer, "Owner users", vm.OwnerGroups)
 		writeStrings(writer, "Owner users", vm.OwnerUsers)
+		if len(vm.PlacementGroups) > 0 {
+			groups := make([]string, 0, len(vm.PlacementGroups))
+			for _, group := range vm.PlacementGroups {
+				text := group.Name + " (" + group.Policy.String()
+				if group.Strict {
+					text += ", strict"
+				}
+				groups = append(groups, text+")")
+			}
+			writeStrings(writer, "Placement groups", groups)
+		}
 		writeBool(writer, "Spread volumes", vm.SpreadVolumes)
+		if len(vm.Snapshots) > 0 {
+			snapshots := make([]string, 0, len(vm.Snapshots))
+			for _, snapshot := range vm.Snapshots {
+				name := snapshot.Name
+				if name == "" {
+					name = "(default)"
+				}
+				text := name + " (" +
+					snapshot.Time.Format(format.TimeFormatSeconds)
+				if snapshot.RootOnly {
+					text += ", root only"
+				}
+				snapshots = append(snapshots, text+")")
+			}
+			writeStrings(writer, "Snapshots", snapshots)
+		}
+		if schedule := vm.SnapshotSchedule; schedule != nil {
+			text := fmt.Sprintf("every %s, retain %d",
+				format.Duration(schedule.Interval), schedule.NumToRetain)
+			if schedule.Backup {
+				text += ", backed up"
+			}
+			writeString(writer, "Snapshot schedule", text)
+		}
+		if backup := vm.Backup; backup != nil {
+			var text string
+			if backup.InProgress {
+				text = "in progress"
+			} else if backup.Error != "" {
+				text = "failed: " + backup.Error
+			}
+			if !backup.Time.IsZero() {
+				if text != "" {
+					text += ", "
+				}
+				text += fmt.Sprintf("last: %s (%s) to %s",
+					backup.Time.Format(format.TimeFormatSeconds),
+					backup.SnapshotName, backup.ObjectServer)
+				if backup.ImageName != "" {
+					text += ", image: " + backup.ImageName
+				}
+			}
+			writeString(writer, "Backup", text)
+		}
+		writeString(writer, "Firewall",
+			fmt.Sprintf("<a href=\"showVmFirewall?%s\">ruleset</a>", ipAddr))
 		writeString(writer, "Latest boot",
 			fmt.Sprintf("<a href=\"showVmBootLog?%s\">log</a>", ipAddr))
 		if ok, _ := s.manager.CheckVmHasHealthAgent(netIpAddr); ok {
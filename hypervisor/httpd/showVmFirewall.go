@@ -0,0 +1,34 @@
+package httpd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/Symantec/Dominator/lib/url"
+)
+
+func (s state) showVmFirewallHandler(w http.ResponseWriter,
+	req *http.Request) {
+	parsedQuery := url.ParseQuery(req.URL)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(parsedQuery.Flags) != 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var ipAddr string
+	for name := range parsedQuery.Flags {
+		ipAddr = name
+	}
+	ruleset, err := s.manager.GetVmFirewallRuleset(net.ParseIP(ipAddr))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintln(w, err)
+		return
+	}
+	if ruleset == "" {
+		fmt.Fprintln(w, "No filtering")
+		return
+	}
+	fmt.Fprint(w, ruleset)
+}
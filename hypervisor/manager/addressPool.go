@@ -205,6 +205,7 @@ func (m *Manager) releaseAddressInPool(address proto.Address) error {
 }
 
 func (m *Manager) releaseAddressInPoolWithLock(address proto.Address) error {
+	address.Ipv6Address = nil // The pool only has IPv4 and MAC addresses.
 	m.addressPool.Free = append(m.addressPool.Free, address)
 	return m.writeAddressPoolWithLock(m.addressPool, false)
 }
@@ -290,6 +291,7 @@ func (m *Manager) removeExcessAddressesFromPool(maxFree map[string]uint) error {
 
 func (m *Manager) unregisterAddress(address proto.Address, lock bool) error {
 	found := false
+	address.Ipv6Address = nil // The pool only has IPv4 and MAC addresses.
 	if lock {
 		m.mutex.Lock()
 		defer m.mutex.Unlock()
@@ -1,11 +1,13 @@
 package manager
 
 import (
+	"crypto/ed25519"
 	"io"
 	"net"
 	"sync"
 	"time"
 
+	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/objectserver/cachingreader"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -29,6 +31,8 @@ type DhcpServer interface {
 
 type Manager struct {
 	StartOptions
+	hostname          string
+	identityKey       ed25519.PrivateKey
 	rootCookie        []byte
 	memTotalInMiB     uint64
 	numCPU            int
@@ -36,7 +40,9 @@ type Manager struct {
 	volumeDirectories []string
 	mutex             sync.RWMutex // Lock everything below (those can change).
 	addressPool       addressPoolType
+	freeVolumeSpace   []uint64 // Refreshed periodically.
 	healthStatus      string
+	memAvailableInMiB uint64 // Refreshed periodically.
 	notifiers         map[<-chan proto.Update]chan<- proto.Update
 	objectCache       *cachingreader.ObjectServer
 	ownerGroups       map[string]struct{}
@@ -47,35 +53,45 @@ type Manager struct {
 }
 
 type StartOptions struct {
-	DhcpServer         DhcpServer
-	ImageServerAddress string
-	Logger             log.DebugLogger
-	ObjectCacheBytes   uint64
-	ShowVgaConsole     bool
-	StateDir           string
-	Username           string
-	VlanIdToBridge     map[uint]string // Key: VLAN ID, value: bridge interface.
-	VolumeDirectories  []string
+	BackupServerAddress string
+	DhcpServer          DhcpServer
+	ImageServerAddress  string
+	Logger              log.DebugLogger
+	ObjectCacheBytes    uint64
+	ShowVgaConsole      bool
+	StateDir            string
+	Username            string
+	VlanIdToBridge      map[uint]string // Key: VLAN ID, value: bridge interface.
+	VolumeDirectories   []string
 }
 
 type vmInfoType struct {
-	mutex                      sync.RWMutex
-	accessToken                []byte
-	accessTokenCleanupNotifier chan<- struct{}
-	commandChannel             chan<- string
-	destroyTimer               *time.Timer
-	dirname                    string
-	doNotWriteOrSend           bool
-	hasHealthAgent             bool
-	ipAddress                  string
-	logger                     log.DebugLogger
-	manager                    *Manager
-	metadataChannels           map[chan<- string]struct{}
-	monitorSockname            string
-	ownerUsers                 map[string]struct{}
-	serialInput                io.Writer
-	serialOutput               chan<- byte
-	stoppedNotifier            chan<- struct{}
+	mutex                        sync.RWMutex
+	accessToken                  []byte
+	accessTokenCleanupNotifier   chan<- struct{}
+	commandChannel               chan<- string
+	destroyTimer                 *time.Timer
+	dirname                      string
+	doNotWriteOrSend             bool
+	hasHealthAgent               bool
+	incomingMigration            bool // If true, start QEMU for live migration.
+	ipAddress                    string
+	lastScheduledSnapshotAttempt time.Time
+	liveMigration                *liveMigrationType
+	logger                       log.DebugLogger
+	manager                      *Manager
+	metadataChannels             map[chan<- string]struct{}
+	monitorLock                  sync.Mutex // Protect the monitor fields below.
+	monitorCommandId             uint64
+	monitorConn                  net.Conn // Set once capabilities are sent.
+	monitorPending               map[uint64]chan<- qmpMessage
+	monitorSockname              string
+	ownerUsers                   map[string]struct{}
+	scheduledSnapshotRunning     bool
+	serialInput                  io.Writer
+	serialOutput                 chan<- byte
+	snapshotInProgress           bool
+	stoppedNotifier              chan<- struct{}
 	proto.LocalVmInfo
 }
 
@@ -92,6 +108,11 @@ func (m *Manager) AddAddressesToPool(addresses []proto.Address) error {
 	return m.addAddressesToPool(addresses)
 }
 
+func (m *Manager) BackupVm(authInfo *srpc.AuthInformation,
+	request proto.BackupVmRequest) (hash.Hash, error) {
+	return m.backupVm(authInfo, request)
+}
+
 func (m *Manager) BecomePrimaryVmOwner(ipAddr net.IP,
 	authInfo *srpc.AuthInformation) error {
 	return m.becomePrimaryVmOwner(ipAddr, authInfo)
@@ -111,11 +132,29 @@ func (m *Manager) ChangeVmDestroyProtection(ipAddr net.IP,
 	return m.changeVmDestroyProtection(ipAddr, authInfo, destroyProtection)
 }
 
+// ChangeVmFirewallPolicy will change the firewall policy for a VM and return
+// the effective ruleset. If dryRun is true, the policy is not changed.
+func (m *Manager) ChangeVmFirewallPolicy(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, policy proto.FirewallPolicy,
+	dryRun bool) (string, error) {
+	return m.changeVmFirewallPolicy(ipAddr, authInfo, policy, dryRun)
+}
+
 func (m *Manager) ChangeVmOwnerUsers(ipAddr net.IP,
 	authInfo *srpc.AuthInformation, extraUsers []string) error {
 	return m.changeVmOwnerUsers(ipAddr, authInfo, extraUsers)
 }
 
+func (m *Manager) ChangeVmSize(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	memoryInMiB uint64, milliCPUs uint) error {
+	return m.changeVmSize(ipAddr, authInfo, memoryInMiB, milliCPUs)
+}
+
+func (m *Manager) ChangeVmSnapshotSchedule(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, schedule proto.SnapshotSchedule) error {
+	return m.changeVmSnapshotSchedule(ipAddr, authInfo, schedule)
+}
+
 func (m *Manager) ChangeVmTags(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	tgs tags.Tags) error {
 	return m.changeVmTags(ipAddr, authInfo, tgs)
@@ -143,6 +182,10 @@ func (m *Manager) ConnectToVmConsole(ipAddr net.IP,
 	return m.connectToVmConsole(ipAddr, authInfo)
 }
 
+func (m *Manager) ConnectToVmMigrationStream(conn *srpc.Conn) error {
+	return m.connectToVmMigrationStream(conn)
+}
+
 func (m *Manager) ConnectToVmSerialPort(ipAddr net.IP,
 	authInfo *srpc.AuthInformation,
 	portNumber uint) (chan<- byte, <-chan byte, error) {
@@ -183,8 +226,8 @@ func (m *Manager) DiscardVmOldUserData(ipAddr net.IP,
 }
 
 func (m *Manager) DiscardVmSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation) error {
-	return m.discardVmSnapshot(ipAddr, authInfo)
+	authInfo *srpc.AuthInformation, snapshotName string) error {
+	return m.discardVmSnapshot(ipAddr, authInfo, snapshotName)
 }
 
 func (m *Manager) ExportLocalVm(authInfo *srpc.AuthInformation,
@@ -213,10 +256,24 @@ func (m *Manager) GetVmAccessToken(ipAddr net.IP,
 	return m.getVmAccessToken(ipAddr, authInfo, lifetime)
 }
 
+// GetVmFirewallRuleset will return the effective nftables ruleset for a VM,
+// combining the VM and subnet firewall policies. The empty string is returned
+// if no filtering is required.
+func (m *Manager) GetVmFirewallRuleset(ipAddr net.IP) (string, error) {
+	return m.getVmFirewallRuleset(ipAddr)
+}
+
 func (m *Manager) GetVmInfo(ipAddr net.IP) (proto.VmInfo, error) {
 	return m.getVmInfo(ipAddr)
 }
 
+// GetSignedVmIdentity will return the identity document for a VM, signed
+// with the identity key of the Hypervisor.
+func (m *Manager) GetSignedVmIdentity(ipAddr net.IP) (
+	*proto.SignedIdentityDocument, error) {
+	return m.getSignedVmIdentity(ipAddr)
+}
+
 func (m *Manager) GetVmUserData(ipAddr net.IP) (io.ReadCloser, error) {
 	rc, _, err := m.getVmUserData(ipAddr,
 		&srpc.AuthInformation{HaveMethodAccess: true},
@@ -234,6 +291,11 @@ func (m *Manager) GetVmVolume(conn *srpc.Conn) error {
 	return m.getVmVolume(conn)
 }
 
+func (m *Manager) GrowVmVolume(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	volumeIndex uint, size uint64) error {
+	return m.growVmVolume(ipAddr, authInfo, volumeIndex, size)
+}
+
 func (m *Manager) ImportLocalVm(authInfo *srpc.AuthInformation,
 	request proto.ImportLocalVmRequest) error {
 	return m.importLocalVm(authInfo, request)
@@ -304,9 +366,15 @@ func (m *Manager) ReplaceVmUserData(ipAddr net.IP, reader io.Reader,
 	return m.replaceVmUserData(ipAddr, reader, size, authInfo)
 }
 
+func (m *Manager) RestoreVmFromBackup(conn *srpc.Conn) error {
+	return m.restoreVmFromBackup(conn)
+}
+
 func (m *Manager) RestoreVmFromSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation, forceIfNotStopped bool) error {
-	return m.restoreVmFromSnapshot(ipAddr, authInfo, forceIfNotStopped)
+	authInfo *srpc.AuthInformation, forceIfNotStopped bool,
+	snapshotName string) error {
+	return m.restoreVmFromSnapshot(ipAddr, authInfo, forceIfNotStopped,
+		snapshotName)
 }
 
 func (m *Manager) RestoreVmImage(ipAddr net.IP,
@@ -319,19 +387,27 @@ func (m *Manager) RestoreVmUserData(ipAddr net.IP,
 	return m.restoreVmUserData(ipAddr, authInfo)
 }
 
+func (m *Manager) SendVmLiveMigration(conn *srpc.Conn) error {
+	return m.sendVmLiveMigration(conn)
+}
+
 func (m *Manager) ShutdownVMsAndExit() {
 	m.shutdownVMsAndExit()
 }
 
 func (m *Manager) SnapshotVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
-	forceIfNotStopped, snapshotRootOnly bool) error {
-	return m.snapshotVm(ipAddr, authInfo, forceIfNotStopped, snapshotRootOnly)
+	forceIfNotStopped, snapshotRootOnly bool, snapshotName string) error {
+	return m.snapshotVm(ipAddr, authInfo, forceIfNotStopped, snapshotRootOnly,
+		snapshotName)
 }
 
+// StartVm will start a stopped VM. If enableMemoryBalloon is true and the VM
+// was created without the memory balloon device, the device is added.
 func (m *Manager) StartVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
-	accessToken []byte, dhcpTimeout time.Duration) (
-	bool, error) {
-	return m.startVm(ipAddr, authInfo, accessToken, dhcpTimeout)
+	accessToken []byte, dhcpTimeout time.Duration,
+	enableMemoryBalloon bool) (bool, error) {
+	return m.startVm(ipAddr, authInfo, accessToken, dhcpTimeout,
+		enableMemoryBalloon)
 }
 
 func (m *Manager) StopVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
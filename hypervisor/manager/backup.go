@@ -0,0 +1,684 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	imclient "github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver"
+	objclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/rsync"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	backupCheckBatchSize = 16
+	backupLeaseTimeout   = time.Hour
+	backupManifestName   = "manifest"
+	minBackupBlockOrder  = 20 // Limit the number of objects for large volumes.
+)
+
+type backupJob struct {
+	imageName        string
+	leaseExpiration  time.Time
+	leaseId          string
+	logger           log.DebugLogger
+	objectServer     string
+	objectSizes      map[hash.Hash]uint64
+	previous         *hash.Hash
+	sentObjects      map[hash.Hash]struct{}
+	snapshotName     string
+	userDataFilename string
+	vmBackup         proto.VmBackup
+	volumeFilenames  []string
+}
+
+type backupBlock struct {
+	data    []byte
+	hashVal hash.Hash
+}
+
+func getBackupBlockOrder(size uint64) uint8 {
+	blockOrder := rsync.ComputeBlockOrder(size)
+	if blockOrder < minBackupBlockOrder {
+		return minBackupBlockOrder
+	}
+	return blockOrder
+}
+
+// checkBackupAccess will return an error if the user may not restore a VM from
+// a backup of the VM described by vmInfo.
+func checkBackupAccess(authInfo *srpc.AuthInformation,
+	vmInfo proto.VmInfo) error {
+	if authInfo.HaveMethodAccess {
+		return nil
+	}
+	for _, username := range vmInfo.OwnerUsers {
+		if username == authInfo.Username {
+			return nil
+		}
+	}
+	for _, ownerGroup := range vmInfo.OwnerGroups {
+		if _, ok := authInfo.GroupList[ownerGroup]; ok {
+			return nil
+		}
+	}
+	return errorNoAccessToResource
+}
+
+func readVmBackup(objectsGetter objectserver.ObjectsGetter,
+	hashVal hash.Hash) (*proto.VmBackup, error) {
+	_, reader, err := objectserver.GetObject(objectsGetter, hashVal)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var vmBackup proto.VmBackup
+	if err := gob.NewDecoder(reader).Decode(&vmBackup); err != nil {
+		return nil, err
+	}
+	return &vmBackup, nil
+}
+
+// resolveBackupImage will return the hash of the VmBackup object for a backup
+// image. If imageName is a directory, the latest image in it is used.
+func resolveBackupImage(client *srpc.Client, imageName string) (
+	hash.Hash, error) {
+	if isDir, err := imclient.CheckDirectory(client, imageName); err != nil {
+		return hash.Hash{}, err
+	} else if isDir {
+		latestImage, err := imclient.FindLatestImage(client, imageName, false)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		if latestImage == "" {
+			return hash.Hash{},
+				errors.New("no images in directory: " + imageName)
+		}
+		imageName = latestImage
+	}
+	img, err := imclient.GetImage(client, imageName)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if img == nil {
+		return hash.Hash{}, errors.New("image: " + imageName + " not found")
+	}
+	img.FileSystem.RebuildInodePointers()
+	for _, dirent := range img.FileSystem.EntryList {
+		if dirent.Name != backupManifestName {
+			continue
+		}
+		if inode, ok := dirent.Inode().(*filesystem.RegularInode); ok {
+			return inode.Hash, nil
+		}
+	}
+	return hash.Hash{}, errors.New("image: " + imageName + " is not a backup")
+}
+
+func (m *Manager) backupVm(authInfo *srpc.AuthInformation,
+	request proto.BackupVmRequest) (hash.Hash, error) {
+	snapshotName := request.SnapshotName
+	if snapshotName == "" {
+		snapshotName = "backup"
+		err := m.snapshotVm(request.IpAddress, authInfo,
+			request.ForceIfNotStopped, false, snapshotName)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+	}
+	vm, err := m.getVmLockAndAuth(request.IpAddress, true, authInfo, nil)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	job, err := vm.prepareBackup(snapshotName, request.ObjectServerAddress,
+		request.ImageName)
+	vm.mutex.Unlock()
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	return vm.runBackup(job)
+}
+
+func (m *Manager) getBackupServerAddress(address string) string {
+	if address != "" {
+		return address
+	}
+	if m.BackupServerAddress != "" {
+		return m.BackupServerAddress
+	}
+	return m.ImageServerAddress
+}
+
+// checkBackupImageName will return an error if a backup to the imageserver
+// would not be added to an image, since the imageserver garbage collects
+// objects which are not referenced by an image.
+func (m *Manager) checkBackupImageName(objectServer, imageName string) error {
+	if imageName == "" &&
+		m.getBackupServerAddress(objectServer) == m.ImageServerAddress {
+		return errors.New("image name required for backup to imageserver")
+	}
+	return nil
+}
+
+func (m *Manager) restoreVmFromBackup(conn *srpc.Conn) error {
+
+	sendError := func(conn *srpc.Conn, err error) error {
+		return conn.Encode(proto.RestoreVmFromBackupResponse{
+			Error: err.Error()})
+	}
+
+	sendUpdate := func(conn *srpc.Conn, message string) error {
+		response := proto.RestoreVmFromBackupResponse{
+			ProgressMessage: message}
+		if err := conn.Encode(response); err != nil {
+			return err
+		}
+		return conn.Flush()
+	}
+
+	m.Logger.Debugf(1, "RestoreVmFromBackup(%s) starting\n", conn.Username())
+	var request proto.RestoreVmFromBackupRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	ownerUsers := make([]string, 1, len(request.OwnerUsers)+1)
+	ownerUsers[0] = conn.Username()
+	if ownerUsers[0] == "" {
+		return sendError(conn, errors.New("no authentication data"))
+	}
+	ownerUsers = append(ownerUsers, request.OwnerUsers...)
+	objectServer := m.getBackupServerAddress(request.ObjectServerAddress)
+	client, err := srpc.DialHTTP("tcp", objectServer, 0)
+	if err != nil {
+		return sendError(conn,
+			fmt.Errorf("error connecting to: %s: %s", objectServer, err))
+	}
+	defer client.Close()
+	backupHash := request.Hash
+	if request.ImageName != "" {
+		backupHash, err = resolveBackupImage(client, request.ImageName)
+		if err != nil {
+			return sendError(conn, err)
+		}
+	}
+	objClient := objclient.AttachObjectClient(client)
+	vmBackup, err := readVmBackup(objClient, backupHash)
+	if err != nil {
+		return sendError(conn, fmt.Errorf("error reading backup: %s", err))
+	}
+	err = checkBackupAccess(conn.GetAuthInformation(), vmBackup.VmInfo)
+	if err != nil {
+		return sendError(conn, err)
+	}
+	if len(vmBackup.Volumes) < 1 {
+		return sendError(conn, errors.New("no volumes in backup"))
+	}
+	vmInfo := request.VmInfo
+	vmInfo.Volumes = make([]proto.Volume, 0, len(vmBackup.Volumes))
+	for index, volume := range vmBackup.Volumes {
+		var volumeFormat proto.VolumeFormat
+		if index < len(vmBackup.VmInfo.Volumes) {
+			volumeFormat = vmBackup.VmInfo.Volumes[index].Format
+		}
+		vmInfo.Volumes = append(vmInfo.Volumes, proto.Volume{
+			Format: volumeFormat,
+			Size:   volume.Size,
+		})
+	}
+	vm, err := m.allocateVm(proto.CreateVmRequest{VmInfo: vmInfo},
+		conn.GetAuthInformation())
+	if err != nil {
+		return sendError(conn, err)
+	}
+	defer func() {
+		vm.cleanup() // Evaluate vm at return time, not defer time.
+	}()
+	vm.OwnerUsers = ownerUsers
+	vm.ownerUsers = make(map[string]struct{}, len(ownerUsers))
+	for _, username := range ownerUsers {
+		vm.ownerUsers[username] = struct{}{}
+	}
+	vm.Volumes = vmInfo.Volumes
+	if err := <-tryAllocateMemory(vmInfo.MemoryInMiB); err != nil {
+		return sendError(conn, err)
+	}
+	err = vm.setupVolumes(vmInfo.Volumes[0].Size, vmInfo.Volumes[1:],
+		vmInfo.SpreadVolumes)
+	if err != nil {
+		return sendError(conn, err)
+	}
+	if err := os.MkdirAll(vm.dirname, dirPerms); err != nil {
+		return sendError(conn, err)
+	}
+	for index, volume := range vmBackup.Volumes {
+		err := sendUpdate(conn, fmt.Sprintf("restoring volume: %d", index))
+		if err != nil {
+			return err
+		}
+		err = restoreVolume(objClient, vm.VolumeLocations[index].Filename,
+			volume)
+		if err != nil {
+			return sendError(conn, err)
+		}
+	}
+	if vmBackup.UserData != nil {
+		if err := sendUpdate(conn, "restoring user data"); err != nil {
+			return err
+		}
+		err := objectserver.CopyObject(
+			filepath.Join(vm.dirname, "user-data.raw"), objClient,
+			*vmBackup.UserData)
+		if err != nil {
+			return sendError(conn, err)
+		}
+	}
+	vm.setState(proto.StateStopped)
+	vm.destroyTimer = time.AfterFunc(time.Second*15, vm.autoDestroy)
+	response := proto.RestoreVmFromBackupResponse{
+		Final:     true,
+		IpAddress: vm.Address.IpAddress,
+	}
+	if err := conn.Encode(response); err != nil {
+		return err
+	}
+	vm = nil // Cancel cleanup.
+	m.Logger.Debugln(1, "RestoreVmFromBackup() finished")
+	return nil
+}
+
+// restoreVolume will write the blocks of a volume backup to a new file.
+// Blocks are fetched once and written to every offset they appear at.
+func restoreVolume(objectsGetter objectserver.ObjectsGetter, filename string,
+	volume proto.VolumeBackup) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY,
+		privateFilePerms)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Truncate(int64(volume.Size)); err != nil {
+		return err
+	}
+	blockSize := uint64(1) << volume.BlockOrder
+	zeroHash := sha512.Sum512(make([]byte, blockSize))
+	offsets := make(map[hash.Hash][]int64)
+	var hashes []hash.Hash
+	for index, hashVal := range volume.Blocks {
+		if hashVal == zeroHash {
+			continue // The file is sparse.
+		}
+		if _, ok := offsets[hashVal]; !ok {
+			hashes = append(hashes, hashVal)
+		}
+		offsets[hashVal] = append(offsets[hashVal],
+			int64(uint64(index)<<volume.BlockOrder))
+	}
+	if len(hashes) < 1 {
+		return nil
+	}
+	objectsReader, err := objectsGetter.GetObjects(hashes)
+	if err != nil {
+		return err
+	}
+	defer objectsReader.Close()
+	for _, hashVal := range hashes {
+		size, reader, err := objectsReader.NextObject()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+		if uint64(len(data)) != size {
+			return fmt.Errorf("short object: %x", hashVal)
+		}
+		for _, offset := range offsets[hashVal] {
+			if _, err := file.WriteAt(data, offset); err != nil {
+				return err
+			}
+		}
+	}
+	return file.Close()
+}
+
+func (job *backupJob) addBlocks(client *srpc.Client,
+	objClient *objclient.ObjectClient, queue *objclient.ObjectAdderQueue,
+	blocks []backupBlock) error {
+	hashes := make([]hash.Hash, 0, len(blocks))
+	for _, block := range blocks {
+		hashes = append(hashes, block.hashVal)
+	}
+	sizes, err := objClient.CheckObjects(hashes)
+	if err != nil {
+		return err
+	}
+	var existingObjects []hash.Hash
+	for index, block := range blocks {
+		if sizes[index] > 0 {
+			existingObjects = append(existingObjects, block.hashVal)
+			continue
+		}
+		if err := queue.AddData(block.data, block.hashVal); err != nil {
+			return err
+		}
+	}
+	return job.extendLease(client, existingObjects)
+}
+
+func (job *backupJob) addImage(client *srpc.Client,
+	manifestHash hash.Hash, manifestSize uint64) error {
+	fs := &filesystem.FileSystem{
+		InodeTable: filesystem.InodeTable{
+			1: &filesystem.DirectoryInode{
+				Mode: syscall.S_IFDIR | syscall.S_IRWXU,
+			},
+			2: &filesystem.RegularInode{
+				Mode: syscall.S_IFREG | syscall.S_IRUSR,
+				Size: manifestSize,
+				Hash: manifestHash,
+			},
+		},
+		DirectoryInode: filesystem.DirectoryInode{
+			Mode: syscall.S_IFDIR | syscall.S_IRWXU,
+		},
+	}
+	blocksDirectory := fs.InodeTable[1].(*filesystem.DirectoryInode)
+	fs.EntryList = []*filesystem.DirectoryEntry{
+		{Name: "blocks", InodeNumber: 1},
+		{Name: backupManifestName, InodeNumber: 2},
+	}
+	inodeNumber := uint64(3)
+	for hashVal, size := range job.objectSizes {
+		fs.InodeTable[inodeNumber] = &filesystem.RegularInode{
+			Mode: syscall.S_IFREG | syscall.S_IRUSR,
+			Size: size,
+			Hash: hashVal,
+		}
+		blocksDirectory.EntryList = append(blocksDirectory.EntryList,
+			&filesystem.DirectoryEntry{
+				Name:        fmt.Sprintf("%08d", inodeNumber-3),
+				InodeNumber: inodeNumber,
+			})
+		inodeNumber++
+	}
+	fs.DirectoryCount = 2
+	if err := fs.RebuildInodePointers(); err != nil {
+		return err
+	}
+	fs.ComputeTotalDataBytes()
+	return imclient.AddImage(client, job.imageName, &image.Image{
+		FileSystem: fs,
+	})
+}
+
+func (job *backupJob) backupVolume(client *srpc.Client,
+	objClient *objclient.ObjectClient, queue *objclient.ObjectAdderQueue,
+	filename string, previous *proto.VolumeBackup) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	volume, numChanged, err := job.backupBlocks(file, uint64(fi.Size()),
+		previous, func(blocks []backupBlock) error {
+			return job.addBlocks(client, objClient, queue, blocks)
+		})
+	if err != nil {
+		return err
+	}
+	job.logger.Debugf(0, "backed up: %s, %d of %d blocks changed\n",
+		filename, numChanged, len(volume.Blocks))
+	job.vmBackup.Volumes = append(job.vmBackup.Volumes, volume)
+	return nil
+}
+
+// backupBlocks will split a volume into blocks and will pass each block not
+// already passed in this job to addBlocks, which sends the blocks missing from
+// the server. Blocks which are unchanged since the previous backup are passed
+// too, since the server may have garbage collected them. The number of blocks
+// which changed since the previous backup is returned.
+func (job *backupJob) backupBlocks(reader io.Reader, size uint64,
+	previous *proto.VolumeBackup,
+	addBlocks func(blocks []backupBlock) error) (
+	proto.VolumeBackup, uint64, error) {
+	volume := proto.VolumeBackup{
+		BlockOrder: getBackupBlockOrder(size),
+		Size:       size,
+	}
+	if previous != nil && previous.BlockOrder != volume.BlockOrder {
+		previous = nil
+	}
+	blockSize := uint64(1) << volume.BlockOrder
+	var blocks []backupBlock
+	var numChanged uint64
+	for offset := uint64(0); offset < volume.Size; offset += blockSize {
+		length := blockSize
+		if offset+length > volume.Size {
+			length = volume.Size - offset
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return proto.VolumeBackup{}, 0, err
+		}
+		hashVal := hash.Hash(sha512.Sum512(data))
+		index := len(volume.Blocks)
+		volume.Blocks = append(volume.Blocks, hashVal)
+		job.objectSizes[hashVal] = length
+		if previous == nil || index >= len(previous.Blocks) ||
+			previous.Blocks[index] != hashVal {
+			numChanged++
+		}
+		if _, ok := job.sentObjects[hashVal]; ok {
+			continue
+		}
+		job.sentObjects[hashVal] = struct{}{}
+		blocks = append(blocks, backupBlock{data, hashVal})
+		if len(blocks) >= backupCheckBatchSize {
+			if err := addBlocks(blocks); err != nil {
+				return proto.VolumeBackup{}, 0, err
+			}
+			blocks = nil
+		}
+	}
+	if len(blocks) > 0 {
+		if err := addBlocks(blocks); err != nil {
+			return proto.VolumeBackup{}, 0, err
+		}
+	}
+	return volume, numChanged, nil
+}
+
+// extendLease will add existing objects to the upload lease (if there is one)
+// and extend it if it is getting close to expiring.
+func (job *backupJob) extendLease(client *srpc.Client,
+	objects []hash.Hash) error {
+	if job.leaseId == "" {
+		return nil
+	}
+	if len(objects) < 1 &&
+		time.Until(job.leaseExpiration) > backupLeaseTimeout>>1 {
+		return nil
+	}
+	expiration, err := imclient.ExtendUploadLease(client, job.leaseId,
+		objects, backupLeaseTimeout)
+	if err != nil {
+		return err
+	}
+	job.leaseExpiration = expiration
+	return nil
+}
+
+func (job *backupJob) run() (hash.Hash, error) {
+	client, err := srpc.DialHTTP("tcp", job.objectServer, time.Second*15)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	defer client.Close()
+	objClient := objclient.AttachObjectClient(client)
+	if job.imageName != "" {
+		job.leaseId, job.leaseExpiration, err = imclient.CreateUploadLease(
+			client, nil, backupLeaseTimeout)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		defer imclient.ReleaseUploadLease(client, job.leaseId)
+	}
+	var previous *proto.VmBackup
+	if job.previous != nil {
+		previous, err = readVmBackup(objClient, *job.previous)
+		if err != nil {
+			job.logger.Printf("error reading previous backup: %s\n", err)
+		}
+	}
+	queue, err := objclient.NewObjectAdderQueue(client)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	for index, filename := range job.volumeFilenames {
+		var previousVolume *proto.VolumeBackup
+		if previous != nil && index < len(previous.Volumes) {
+			previousVolume = &previous.Volumes[index]
+		}
+		err := job.backupVolume(client, objClient, queue, filename,
+			previousVolume)
+		if err != nil {
+			queue.Close()
+			return hash.Hash{}, err
+		}
+	}
+	if data, err := ioutil.ReadFile(job.userDataFilename); err != nil {
+		if !os.IsNotExist(err) {
+			queue.Close()
+			return hash.Hash{}, err
+		}
+	} else {
+		hashVal := hash.Hash(sha512.Sum512(data))
+		if err := queue.AddData(data, hashVal); err != nil {
+			queue.Close()
+			return hash.Hash{}, err
+		}
+		job.objectSizes[hashVal] = uint64(len(data))
+		job.vmBackup.UserData = &hashVal
+	}
+	if err := queue.Close(); err != nil {
+		return hash.Hash{}, err
+	}
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(job.vmBackup); err != nil {
+		return hash.Hash{}, err
+	}
+	manifestSize := uint64(buffer.Len())
+	manifestHash, _, err := objClient.AddObject(buffer, manifestSize, nil)
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	if job.imageName != "" {
+		if err := job.addImage(client, manifestHash, manifestSize); err != nil {
+			return hash.Hash{}, err
+		}
+	}
+	return manifestHash, nil
+}
+
+// prepareBackup will check that a snapshot may be backed up and will record
+// that a backup is in progress. The VM lock must be held.
+func (vm *vmInfoType) prepareBackup(snapshotName, objectServer,
+	imageName string) (*backupJob, error) {
+	var snapshot *proto.Snapshot
+	for index := range vm.Snapshots {
+		if vm.Snapshots[index].Name == snapshotName {
+			snapshot = &vm.Snapshots[index]
+			break
+		}
+	}
+	if snapshot == nil {
+		return nil, errors.New("unknown snapshot: " + snapshotName)
+	}
+	if snapshot.RootOnly && len(vm.VolumeLocations) > 1 {
+		return nil, errors.New("cannot back up root-only snapshot")
+	}
+	if vm.Backup != nil && vm.Backup.InProgress {
+		return nil, errors.New("backup already in progress")
+	}
+	err := vm.manager.checkBackupImageName(objectServer, imageName)
+	if err != nil {
+		return nil, err
+	}
+	job := &backupJob{
+		imageName:        imageName,
+		logger:           vm.logger,
+		objectServer:     vm.manager.getBackupServerAddress(objectServer),
+		objectSizes:      make(map[hash.Hash]uint64),
+		sentObjects:      make(map[hash.Hash]struct{}),
+		snapshotName:     snapshotName,
+		userDataFilename: filepath.Join(vm.dirname, "user-data.raw"),
+	}
+	job.vmBackup.Time = snapshot.Time
+	job.vmBackup.VmInfo = vm.VmInfo
+	job.vmBackup.VmInfo.Backup = nil
+	job.vmBackup.VmInfo.Snapshots = nil
+	for _, volume := range vm.VolumeLocations {
+		job.volumeFilenames = append(job.volumeFilenames,
+			getSnapshotFilename(volume.Filename, snapshotName))
+	}
+	var status proto.BackupStatus
+	if vm.Backup != nil {
+		status = *vm.Backup
+		// The previous backup on the same server is only used to count the
+		// changed blocks. The server is checked for every block.
+		if status.Error == "" && !status.Time.IsZero() &&
+			status.ObjectServer == job.objectServer {
+			previous := status.Hash
+			job.previous = &previous
+		}
+	}
+	status.InProgress = true
+	vm.Backup = &status
+	vm.writeAndSendInfo()
+	return job, nil
+}
+
+// runBackup will run a backup job and record the result.
+func (vm *vmInfoType) runBackup(job *backupJob) (hash.Hash, error) {
+	hashVal, err := job.run()
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	var status proto.BackupStatus
+	if err != nil {
+		if vm.Backup != nil {
+			status = *vm.Backup
+		}
+		status.Error = err.Error()
+	} else {
+		status.Hash = hashVal
+		status.ImageName = job.imageName
+		status.ObjectServer = job.objectServer
+		status.SnapshotName = job.snapshotName
+		status.Time = job.vmBackup.Time
+	}
+	status.InProgress = false
+	vm.Backup = &status
+	vm.writeAndSendInfo()
+	return hashVal, err
+}
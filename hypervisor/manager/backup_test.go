@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectserver/memory"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const testBlockSize = 1 << minBackupBlockOrder
+
+// makeTestVolume returns a volume with blocks filled with the given values.
+// A zero value gives a block of zeros.
+func makeTestVolume(values ...byte) []byte {
+	data := make([]byte, 0, len(values)*testBlockSize)
+	for _, value := range values {
+		data = append(data, bytes.Repeat([]byte{value}, testBlockSize)...)
+	}
+	return data
+}
+
+func newTestBackupJob() *backupJob {
+	return &backupJob{
+		objectSizes: make(map[hash.Hash]uint64),
+		sentObjects: make(map[hash.Hash]struct{}),
+	}
+}
+
+// backupTestVolume backs up data, adding the blocks passed to addBlocks to
+// objSrv. It returns the volume backup, the number of changed blocks and the
+// number of blocks passed to addBlocks.
+func backupTestVolume(t *testing.T, job *backupJob, data []byte,
+	previous *proto.VolumeBackup, objSrv *memory.ObjectServer) (
+	proto.VolumeBackup, uint64, int) {
+	var numAdded int
+	volume, numChanged, err := job.backupBlocks(bytes.NewReader(data),
+		uint64(len(data)), previous,
+		func(blocks []backupBlock) error {
+			for _, block := range blocks {
+				_, _, err := objSrv.AddObject(bytes.NewReader(block.data),
+					uint64(len(block.data)), &block.hashVal)
+				if err != nil {
+					return err
+				}
+				numAdded++
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return volume, numChanged, numAdded
+}
+
+func TestBackupBlocks(t *testing.T) {
+	previousData := makeTestVolume(1, 2, 3, 0)
+	previous, _, _ := backupTestVolume(t, newTestBackupJob(), previousData,
+		nil, memory.NewObjectServer())
+	var tests = []struct {
+		name        string
+		data        []byte
+		previous    *proto.VolumeBackup
+		wantChanged uint64
+		wantAdded   int
+	}{
+		{"full", makeTestVolume(1, 2, 3, 0), nil, 4, 4},
+		{"unchanged", makeTestVolume(1, 2, 3, 0), &previous, 0, 4},
+		{"one changed", makeTestVolume(1, 4, 3, 0), &previous, 1, 4},
+		{"duplicate blocks", makeTestVolume(5, 5, 5, 5), nil, 4, 1},
+		{"grown", makeTestVolume(1, 2, 3, 0, 6), &previous, 1, 5},
+		{"partial block", append(makeTestVolume(1), 7), &previous, 1, 2},
+	}
+	for _, test := range tests {
+		job := newTestBackupJob()
+		volume, numChanged, numAdded := backupTestVolume(t, job, test.data,
+			test.previous, memory.NewObjectServer())
+		if numChanged != test.wantChanged {
+			t.Errorf("%s: %d blocks changed, want %d",
+				test.name, numChanged, test.wantChanged)
+		}
+		// Unchanged blocks must still be checked, since the server may have
+		// garbage collected them.
+		if numAdded != test.wantAdded {
+			t.Errorf("%s: %d blocks added, want %d",
+				test.name, numAdded, test.wantAdded)
+		}
+		if volume.Size != uint64(len(test.data)) {
+			t.Errorf("%s: size: %d, want %d",
+				test.name, volume.Size, len(test.data))
+		}
+		numBlocks := (len(test.data) + testBlockSize - 1) / testBlockSize
+		if len(volume.Blocks) != numBlocks {
+			t.Errorf("%s: %d blocks, want %d",
+				test.name, len(volume.Blocks), numBlocks)
+		}
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "backup-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	var tests = []struct {
+		name string
+		data []byte
+	}{
+		{"simple", makeTestVolume(1, 2, 3)},
+		{"sparse", makeTestVolume(0, 1, 0, 0, 2, 0)},
+		{"duplicate blocks", makeTestVolume(3, 4, 3, 4, 3)},
+		{"partial block", append(makeTestVolume(1, 0), 8, 9)},
+		{"empty", nil},
+	}
+	for _, test := range tests {
+		objSrv := memory.NewObjectServer()
+		volume, _, _ := backupTestVolume(t, newTestBackupJob(), test.data, nil,
+			objSrv)
+		filename := filepath.Join(dirname, test.name)
+		if err := restoreVolume(objSrv, filename, volume); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		restored, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(restored, test.data) {
+			t.Errorf("%s: restored volume differs", test.name)
+		}
+	}
+}
+
+func TestCheckBackupAccess(t *testing.T) {
+	vmInfo := proto.VmInfo{
+		OwnerGroups: []string{"team"},
+		OwnerUsers:  []string{"alice"},
+	}
+	var tests = []struct {
+		authInfo srpc.AuthInformation
+		want     bool
+	}{
+		{srpc.AuthInformation{Username: "alice"}, true},
+		{srpc.AuthInformation{Username: "bob"}, false},
+		{srpc.AuthInformation{
+			Username:  "bob",
+			GroupList: map[string]struct{}{"team": {}},
+		}, true},
+		{srpc.AuthInformation{
+			Username:  "bob",
+			GroupList: map[string]struct{}{"other": {}},
+		}, false},
+		{srpc.AuthInformation{Username: "admin", HaveMethodAccess: true}, true},
+		{srpc.AuthInformation{}, false},
+	}
+	for _, test := range tests {
+		err := checkBackupAccess(&test.authInfo, vmInfo)
+		if got := err == nil; got != test.want {
+			t.Errorf("checkBackupAccess(%s, %v): %v, want access: %t",
+				test.authInfo.Username, test.authInfo.GroupList, err,
+				test.want)
+		}
+	}
+}
+
+func TestCheckBackupImageName(t *testing.T) {
+	m := &Manager{StartOptions: StartOptions{
+		ImageServerAddress: "imageserver:6971",
+	}}
+	if err := m.checkBackupImageName("", ""); err == nil {
+		t.Error("unnamed backup to imageserver accepted")
+	}
+	if err := m.checkBackupImageName("imageserver:6971", ""); err == nil {
+		t.Error("unnamed backup to explicit imageserver accepted")
+	}
+	if err := m.checkBackupImageName("", "backups/vm"); err != nil {
+		t.Error(err)
+	}
+	if err := m.checkBackupImageName("objectserver:6980", ""); err != nil {
+		t.Error(err)
+	}
+	m.BackupServerAddress = "objectserver:6980"
+	if err := m.checkBackupImageName("", ""); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Symantec/Dominator/hypervisor/cloudinit"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const cloudInitSeedFilename = "cloud-init.iso"
+
+// writeCloudInitSeed will write the cloud-init seed image for the VM and
+// returns the filename. The seed is regenerated each time the VM is started so
+// that it reflects the current user data. The VM lock must be held.
+func (vm *vmInfoType) writeCloudInitSeed(haveManagerLock bool) (
+	string, error) {
+	subnets := make(map[string]proto.Subnet, len(vm.SecondarySubnetIDs)+1)
+	if !haveManagerLock {
+		vm.manager.mutex.RLock()
+	}
+	for _, subnetId := range append([]string{vm.SubnetId},
+		vm.SecondarySubnetIDs...) {
+		if subnet, ok := vm.manager.subnets[subnetId]; ok {
+			subnets[subnetId] = subnet
+		}
+	}
+	if !haveManagerLock {
+		vm.manager.mutex.RUnlock()
+	}
+	var userData io.Reader
+	file, err := os.Open(filepath.Join(vm.dirname, "user-data.raw"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	} else {
+		defer file.Close()
+		userData = file
+	}
+	filename := filepath.Join(vm.dirname, cloudInitSeedFilename)
+	err = cloudinit.WriteSeed(filename, vm.VmInfo, subnets, userData)
+	if err != nil {
+		return "", err
+	}
+	return filename, nil
+}
@@ -9,6 +9,18 @@ var (
 		"insufficient unallocated CPU")
 )
 
+// getNumCPUs returns the number of virtual CPUs to give a VM.
+func getNumCPUs(milliCPUs uint) uint {
+	nCpus := milliCPUs / 1000
+	if nCpus < 1 {
+		nCpus = 1
+	}
+	if nCpus*1000 < milliCPUs {
+		nCpus++
+	}
+	return nCpus
+}
+
 func (m *Manager) checkSufficientCPUWithLock(milliCPU uint) error {
 	if milliCPU > m.getAvailableMilliCPUWithLock() {
 		return errorInsufficientUnallocatedCPU
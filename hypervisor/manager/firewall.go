@@ -0,0 +1,249 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type firewallInterface struct {
+	policy  proto.FirewallPolicy
+	tapName string
+}
+
+// getTapDeviceName returns the name of the tap device for a VM interface. The
+// name is derived from the MAC address so that it is stable across Hypervisor
+// restarts and firewall rules can be applied before the VM is started.
+func getTapDeviceName(macAddress string) string {
+	return "t" + strings.Replace(macAddress, ":", "", -1)
+}
+
+// mergeFirewallPolicies returns the effective policy for an interface: the
+// rules of the VM and the subnet are combined and filtering is enabled for a
+// direction if either enables it.
+func mergeFirewallPolicies(vmPolicy,
+	subnetPolicy *proto.FirewallPolicy) proto.FirewallPolicy {
+	var policy proto.FirewallPolicy
+	for _, p := range []*proto.FirewallPolicy{vmPolicy, subnetPolicy} {
+		if p == nil {
+			continue
+		}
+		policy.EgressRules = append(policy.EgressRules, p.EgressRules...)
+		policy.FilterEgress = policy.FilterEgress || p.FilterEgress
+		policy.FilterIngress = policy.FilterIngress || p.FilterIngress
+		policy.IngressRules = append(policy.IngressRules, p.IngressRules...)
+	}
+	return policy
+}
+
+func writeFirewallChain(writer io.Writer, name, direction string,
+	rules []proto.FirewallRule) {
+	fmt.Fprintf(writer, "\tchain %s {\n", name)
+	fmt.Fprintln(writer, "\t\tct state established,related accept")
+	fmt.Fprintln(writer, "\t\tether type arp accept")
+	if direction == "ingress" {
+		fmt.Fprintln(writer, "\t\ticmpv6 type { nd-neighbor-solicit,"+
+			" nd-neighbor-advert, nd-router-solicit, nd-router-advert }"+
+			" accept")
+		fmt.Fprintln(writer, "\t\tudp sport 67 udp dport 68 accept")
+		fmt.Fprintln(writer, "\t\tudp sport 547 udp dport 546 accept")
+	} else {
+		// The VM must not send Router Advertisements.
+		fmt.Fprintln(writer, "\t\ticmpv6 type { nd-neighbor-solicit,"+
+			" nd-neighbor-advert, nd-router-solicit } accept")
+		fmt.Fprintln(writer, "\t\tudp sport 68 udp dport 67 accept")
+		fmt.Fprintln(writer, "\t\tudp sport 546 udp dport 547 accept")
+	}
+	for _, rule := range rules {
+		fmt.Fprintf(writer, "\t\t%s\n", formatFirewallRule(direction, rule))
+	}
+	fmt.Fprintln(writer, "\t\tdrop")
+	fmt.Fprintln(writer, "\t}")
+}
+
+// formatFirewallRule returns the nftables statement which accepts the traffic
+// matching a rule.
+// The "icmp" protocol matches ICMPv6 for IPv6 networks and both ICMP and
+// ICMPv6 if there is no remote network.
+func formatFirewallRule(direction string, rule proto.FirewallRule) string {
+	var matches []string
+	family := ""
+	if rule.RemoteNetwork != "" {
+		family = "ip"
+		if ipAddr, _, _ := net.ParseCIDR(rule.RemoteNetwork); ipAddr != nil &&
+			ipAddr.To4() == nil {
+			family = "ip6"
+		}
+		addressType := "saddr"
+		if direction == "egress" {
+			addressType = "daddr"
+		}
+		matches = append(matches,
+			family+" "+addressType+" "+rule.RemoteNetwork)
+	}
+	if rule.FromPort != 0 {
+		ports := fmt.Sprintf("%d", rule.FromPort)
+		if rule.ToPort > rule.FromPort {
+			ports += fmt.Sprintf("-%d", rule.ToPort)
+		}
+		matches = append(matches, rule.Protocol+" dport "+ports)
+	} else if rule.Protocol == "icmp" && family == "ip6" {
+		matches = append(matches, "meta l4proto icmpv6")
+	} else if rule.Protocol == "icmp" && family == "" {
+		matches = append(matches, "meta l4proto { icmp, icmpv6 }")
+	} else if rule.Protocol != "" {
+		matches = append(matches, "meta l4proto "+rule.Protocol)
+	}
+	return strings.Join(append(matches, "accept"), " ")
+}
+
+// makeFirewallRuleset returns the nftables table which implements the
+// firewall policies for the interfaces of a VM. If no filtering is required,
+// the empty string is returned.
+func makeFirewallRuleset(tableName string,
+	interfaces []firewallInterface) string {
+	buffer := &bytes.Buffer{}
+	var jumps []string
+	for index, iface := range interfaces {
+		if iface.policy.FilterIngress {
+			chain := fmt.Sprintf("ingress%d", index)
+			jumps = append(jumps,
+				fmt.Sprintf("oifname \"%s\" jump %s", iface.tapName, chain))
+			writeFirewallChain(buffer, chain, "ingress",
+				iface.policy.IngressRules)
+		}
+		if iface.policy.FilterEgress {
+			chain := fmt.Sprintf("egress%d", index)
+			jumps = append(jumps,
+				fmt.Sprintf("iifname \"%s\" jump %s", iface.tapName, chain))
+			writeFirewallChain(buffer, chain, "egress",
+				iface.policy.EgressRules)
+		}
+	}
+	if len(jumps) < 1 {
+		return ""
+	}
+	return fmt.Sprintf(
+		"table bridge %s {\n\tchain forward {\n"+
+			"\t\ttype filter hook forward priority 0; policy accept;\n"+
+			"\t\t%s\n\t}\n%s}\n",
+		tableName, strings.Join(jumps, "\n\t\t"), buffer.String())
+}
+
+func (m *Manager) changeVmFirewallPolicy(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, policy proto.FirewallPolicy,
+	dryRun bool) (string, error) {
+	if err := policy.CheckValid(); err != nil {
+		return "", err
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, !dryRun, authInfo, nil)
+	if err != nil {
+		return "", err
+	}
+	if dryRun {
+		defer vm.mutex.RUnlock()
+	} else {
+		defer vm.mutex.Unlock()
+	}
+	var newPolicy *proto.FirewallPolicy
+	if policy.FilterEgress || policy.FilterIngress ||
+		len(policy.EgressRules) > 0 || len(policy.IngressRules) > 0 {
+		newPolicy = &policy
+	}
+	ruleset, err := vm.makeFirewallRuleset(newPolicy, false)
+	if err != nil || dryRun {
+		return ruleset, err
+	}
+	if vm.State == proto.StateMigrating {
+		return "", errors.New("VM is migrating")
+	}
+	vm.FirewallPolicy = newPolicy
+	if vm.State == proto.StateRunning {
+		if err := vm.updateFirewall(false); err != nil {
+			return "", err
+		}
+	}
+	vm.writeAndSendInfo()
+	return ruleset, nil
+}
+
+func (m *Manager) getVmFirewallRuleset(ipAddr net.IP) (string, error) {
+	vm, err := m.getVmAndLock(ipAddr, false)
+	if err != nil {
+		return "", err
+	}
+	defer vm.mutex.RUnlock()
+	return vm.makeFirewallRuleset(vm.FirewallPolicy, false)
+}
+
+func (vm *vmInfoType) getFirewallTableName() string {
+	return "vm_" + strings.Replace(vm.Address.MacAddress, ":", "", -1)
+}
+
+// makeFirewallRuleset returns the effective ruleset for the VM with the
+// specified VM firewall policy, combined with the policies of its subnets.
+func (vm *vmInfoType) makeFirewallRuleset(policy *proto.FirewallPolicy,
+	haveManagerLock bool) (string, error) {
+	if !haveManagerLock {
+		vm.manager.mutex.RLock()
+		defer vm.manager.mutex.RUnlock()
+	}
+	addresses := vm.getInterfaceAddresses()
+	subnetIDs := vm.getInterfaceSubnetIDs()
+	interfaces := make([]firewallInterface, 0, len(addresses))
+	for index, address := range addresses {
+		subnet, ok := vm.manager.subnets[subnetIDs[index]]
+		if !ok {
+			return "", fmt.Errorf("subnet: %s not found", subnetIDs[index])
+		}
+		interfaces = append(interfaces, firewallInterface{
+			policy:  mergeFirewallPolicies(policy, subnet.FirewallPolicy),
+			tapName: getTapDeviceName(address.MacAddress),
+		})
+	}
+	return makeFirewallRuleset(vm.getFirewallTableName(), interfaces), nil
+}
+
+// removeFirewall will remove the firewall table for the VM, if present.
+func (vm *vmInfoType) removeFirewall() {
+	if err := vm.loadFirewallRuleset(""); err != nil {
+		vm.logger.Println(err)
+	}
+}
+
+// updateFirewall will replace the firewall table for the VM with the effective
+// ruleset. The VM lock must be held.
+func (vm *vmInfoType) updateFirewall(haveManagerLock bool) error {
+	ruleset, err := vm.makeFirewallRuleset(vm.FirewallPolicy, haveManagerLock)
+	if err != nil {
+		return err
+	}
+	return vm.loadFirewallRuleset(ruleset)
+}
+
+// loadFirewallRuleset will atomically replace the firewall table for the VM
+// with the specified ruleset. If the ruleset is empty the table is removed.
+func (vm *vmInfoType) loadFirewallRuleset(ruleset string) error {
+	if _, err := exec.LookPath("nft"); err != nil {
+		if ruleset == "" {
+			return nil // Nothing to remove if nftables is not available.
+		}
+		return errors.New("cannot apply firewall policy: nft not found")
+	}
+	tableName := vm.getFirewallTableName()
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf(
+		"add table bridge %s\ndelete table bridge %s\n%s",
+		tableName, tableName, ruleset))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error loading firewall rules: %s: %s", err, output)
+	}
+	return nil
+}
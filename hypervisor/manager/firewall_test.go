@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestFormatFirewallRule(t *testing.T) {
+	var tests = []struct {
+		direction string
+		rule      proto.FirewallRule
+		want      string
+	}{
+		{"ingress", proto.FirewallRule{}, "accept"},
+		{"ingress", proto.FirewallRule{Protocol: "tcp", FromPort: 22},
+			"tcp dport 22 accept"},
+		{"ingress",
+			proto.FirewallRule{Protocol: "udp", FromPort: 1000, ToPort: 2000},
+			"udp dport 1000-2000 accept"},
+		{"ingress",
+			proto.FirewallRule{Protocol: "tcp", FromPort: 80, ToPort: 80},
+			"tcp dport 80 accept"},
+		{"ingress", proto.FirewallRule{RemoteNetwork: "10.0.0.0/8"},
+			"ip saddr 10.0.0.0/8 accept"},
+		{"egress", proto.FirewallRule{RemoteNetwork: "10.0.0.0/8"},
+			"ip daddr 10.0.0.0/8 accept"},
+		{"ingress", proto.FirewallRule{
+			Protocol:      "tcp",
+			FromPort:      443,
+			RemoteNetwork: "2001:db8::/32",
+		}, "ip6 saddr 2001:db8::/32 tcp dport 443 accept"},
+		{"ingress", proto.FirewallRule{Protocol: "icmp"},
+			"meta l4proto { icmp, icmpv6 } accept"},
+		{"ingress",
+			proto.FirewallRule{Protocol: "icmp", RemoteNetwork: "10.0.0.0/8"},
+			"ip saddr 10.0.0.0/8 meta l4proto icmp accept"},
+		{"egress",
+			proto.FirewallRule{Protocol: "icmp", RemoteNetwork: "fd00::/8"},
+			"ip6 daddr fd00::/8 meta l4proto icmpv6 accept"},
+		{"egress", proto.FirewallRule{Protocol: "udp"},
+			"meta l4proto udp accept"},
+	}
+	for _, test := range tests {
+		got := formatFirewallRule(test.direction, test.rule)
+		if got != test.want {
+			t.Errorf("formatFirewallRule(%s, %+v) = %q, want %q",
+				test.direction, test.rule, got, test.want)
+		}
+	}
+}
+
+func TestMakeFirewallRuleset(t *testing.T) {
+	interfaces := []firewallInterface{
+		{
+			policy: proto.FirewallPolicy{
+				FilterIngress: true,
+				IngressRules: []proto.FirewallRule{
+					{Protocol: "tcp", FromPort: 22},
+				},
+			},
+			tapName: "t525400123456",
+		},
+		{
+			policy: proto.FirewallPolicy{
+				FilterEgress: true,
+				EgressRules: []proto.FirewallRule{
+					{RemoteNetwork: "10.0.0.0/8"},
+				},
+			},
+			tapName: "t525400123457",
+		},
+	}
+	want := `table bridge vm_test {
+	chain forward {
+		type filter hook forward priority 0; policy accept;
+		oifname "t525400123456" jump ingress0
+		iifname "t525400123457" jump egress1
+	}
+	chain ingress0 {
+		ct state established,related accept
+		ether type arp accept
+		icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-solicit, nd-router-advert } accept
+		udp sport 67 udp dport 68 accept
+		udp sport 547 udp dport 546 accept
+		tcp dport 22 accept
+		drop
+	}
+	chain egress1 {
+		ct state established,related accept
+		ether type arp accept
+		icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-solicit } accept
+		udp sport 68 udp dport 67 accept
+		udp sport 546 udp dport 547 accept
+		ip daddr 10.0.0.0/8 accept
+		drop
+	}
+}
+`
+	if got := makeFirewallRuleset("vm_test", interfaces); got != want {
+		t.Errorf("makeFirewallRuleset() = %s\nwant: %s", got, want)
+	}
+	// Rules without filtering enabled give no ruleset.
+	for index := range interfaces {
+		interfaces[index].policy.FilterEgress = false
+		interfaces[index].policy.FilterIngress = false
+	}
+	if got := makeFirewallRuleset("vm_test", interfaces); got != "" {
+		t.Errorf("makeFirewallRuleset() without filtering = %s", got)
+	}
+}
+
+func TestMergeFirewallPolicies(t *testing.T) {
+	vmPolicy := &proto.FirewallPolicy{
+		FilterIngress: true,
+		IngressRules:  []proto.FirewallRule{{Protocol: "tcp", FromPort: 22}},
+	}
+	subnetPolicy := &proto.FirewallPolicy{
+		EgressRules:  []proto.FirewallRule{{Protocol: "icmp"}},
+		FilterEgress: true,
+		IngressRules: []proto.FirewallRule{{Protocol: "icmp"}},
+	}
+	policy := mergeFirewallPolicies(vmPolicy, subnetPolicy)
+	if !policy.FilterIngress || !policy.FilterEgress {
+		t.Errorf("filtering not merged: %+v", policy)
+	}
+	if len(policy.IngressRules) != 2 || len(policy.EgressRules) != 1 {
+		t.Errorf("rules not merged: %+v", policy)
+	}
+	if policy := mergeFirewallPolicies(nil, nil); policy.FilterEgress ||
+		policy.FilterIngress || len(policy.IngressRules) > 0 {
+		t.Errorf("merging nil policies gave: %+v", policy)
+	}
+	ruleset := makeFirewallRuleset("vm_test", []firewallInterface{
+		{policy: mergeFirewallPolicies(nil, subnetPolicy), tapName: "t0"},
+	})
+	egress := ruleset[strings.Index(ruleset, "chain egress0"):]
+	if strings.Contains(egress, "nd-router-advert") {
+		t.Error("egress chain permits Router Advertisements")
+	}
+}
@@ -0,0 +1,27 @@
+package manager
+
+import (
+	"net"
+
+	"github.com/Symantec/Dominator/lib/vmidentity"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (m *Manager) getSignedVmIdentity(ipAddr net.IP) (
+	*proto.SignedIdentityDocument, error) {
+	vm, err := m.getVmAndLock(ipAddr, false)
+	if err != nil {
+		return nil, err
+	}
+	document := proto.IdentityDocument{
+		Hostname:    vm.Hostname,
+		Hypervisor:  m.hostname,
+		ImageName:   vm.ImageName,
+		IpAddress:   vm.Address.IpAddress,
+		OwnerGroups: vm.OwnerGroups,
+		OwnerUsers:  vm.OwnerUsers,
+		Tags:        vm.Tags,
+	}
+	vm.mutex.RUnlock()
+	return vmidentity.Sign(document, m.identityKey)
+}
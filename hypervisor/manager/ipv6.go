@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"net"
+
+	"github.com/Symantec/Dominator/lib/net/util"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// getIpv6Address returns the IPv6 address for an interface on a subnet, which
+// is the address the VM will configure with SLAAC. If the subnet does not have
+// IPv6, nil is returned.
+func getIpv6Address(subnet proto.Subnet, macAddress string) (net.IP, error) {
+	network, err := subnet.GetIpv6Network()
+	if err != nil || network == nil {
+		return nil, err
+	}
+	macAddr, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return nil, err
+	}
+	return util.GetEui64Address(network.IP, macAddr)
+}
+
+// updateIpv6Addresses will set the IPv6 addresses of the VM interfaces from
+// their subnets. It returns true if any address was changed.
+func (vm *vmInfoType) updateIpv6Addresses(haveManagerLock bool) bool {
+	if !haveManagerLock {
+		vm.manager.mutex.RLock()
+		defer vm.manager.mutex.RUnlock()
+	}
+	addresses := vm.getInterfaceAddresses()
+	subnetIDs := vm.getInterfaceSubnetIDs()
+	changed := false
+	for index := range addresses {
+		address := &addresses[index]
+		ipAddr, err := getIpv6Address(vm.manager.subnets[subnetIDs[index]],
+			address.MacAddress)
+		if err != nil {
+			vm.logger.Println(err)
+			continue
+		}
+		if !ipAddr.Equal(address.Ipv6Address) {
+			address.Ipv6Address = ipAddr
+			changed = true
+		}
+	}
+	if changed {
+		vm.Address = addresses[0]
+		if len(addresses) > 1 {
+			vm.SecondaryAddresses = addresses[1:]
+		}
+	}
+	return changed
+}
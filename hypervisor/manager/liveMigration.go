@@ -0,0 +1,742 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// Live migration streams the memory and volumes of a running VM from the
+// source QEMU to the destination QEMU. QEMU is chrooted, so each side talks to
+// its hypervisor over loopback TCP and the hypervisors carry each stream over
+// a ConnectToVmMigrationStream connection. Volumes are mirrored using NBD.
+const (
+	defaultMaximumDowntime    = 300 * time.Millisecond
+	defaultMigrationTimeout   = 10 * time.Minute
+	memoryStreamName          = "memory"
+	migrationPollInterval     = 250 * time.Millisecond
+	migrationProgressInterval = 5 * time.Second
+	migrationStreamTimeout    = time.Minute
+)
+
+// liveMigrationType holds the state of an outgoing live migration. There is
+// one listener per stream, which accepts a connection from QEMU.
+type liveMigrationType struct {
+	claimed   chan struct{} // Signalled when a stream is claimed.
+	listeners map[string]net.Listener
+	unclaimed map[string]struct{}
+}
+
+func getLoopbackPort(listener net.Listener) int {
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// getFreeLoopbackPort will return a loopback TCP port which is not in use.
+func getFreeLoopbackPort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return getLoopbackPort(listener), nil
+}
+
+func makeVolumeStreamName(index int) string {
+	return fmt.Sprintf("volume%d", index)
+}
+
+func sendVmLiveMigrationMessage(conn *srpc.Conn, message string) error {
+	request := proto.SendVmLiveMigrationResponse{ProgressMessage: message}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	return conn.Flush()
+}
+
+// spliceStreams will copy data in both directions between conn and sock until
+// either side is closed. The socket is closed before returning.
+func spliceStreams(conn *srpc.Conn, sock net.Conn) error {
+	errorChannel := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(sock, conn)
+		errorChannel <- err
+	}()
+	go func() {
+		buffer := make([]byte, 64<<10)
+		for {
+			nRead, err := sock.Read(buffer)
+			if nRead > 0 {
+				if _, err := conn.Write(buffer[:nRead]); err != nil {
+					errorChannel <- err
+					return
+				}
+				if err := conn.Flush(); err != nil {
+					errorChannel <- err
+					return
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				errorChannel <- err
+				return
+			}
+		}
+	}()
+	err := <-errorChannel
+	sock.Close()
+	return err
+}
+
+func (m *Manager) claimVmMigrationStream(
+	request proto.ConnectToVmMigrationStreamRequest,
+	authInfo *srpc.AuthInformation) (net.Listener, error) {
+	vm, err := m.getVmLockAndAuth(request.IpAddress, true, authInfo,
+		request.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	defer vm.mutex.Unlock()
+	if vm.liveMigration == nil {
+		return nil, errors.New("VM is not being live migrated")
+	}
+	if _, ok := vm.liveMigration.unclaimed[request.StreamName]; !ok {
+		return nil, errors.New("unknown or claimed stream: " +
+			request.StreamName)
+	}
+	delete(vm.liveMigration.unclaimed, request.StreamName)
+	vm.liveMigration.claimed <- struct{}{}
+	return vm.liveMigration.listeners[request.StreamName], nil
+}
+
+func (m *Manager) commitVmLiveMigration(vm *vmInfoType) error {
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	if vm.State != proto.StateRunning {
+		return errors.New("VM is not running")
+	}
+	// Block reallocation of addresses until VM is destroyed, then release
+	// claims on addresses.
+	vm.Uncommitted = true
+	vm.setState(proto.StateMigrating)
+	if err := m.unregisterAddress(vm.Address, true); err != nil {
+		vm.Uncommitted = false
+		vm.setState(proto.StateRunning)
+		return err
+	}
+	for _, address := range vm.SecondaryAddresses {
+		if err := m.unregisterAddress(address, true); err != nil {
+			vm.logger.Printf("error unregistering address: %s\n",
+				address.IpAddress)
+			vm.Uncommitted = false
+			vm.setState(proto.StateRunning)
+			return err
+		}
+	}
+	vm.commandChannel <- "quit"
+	return nil
+}
+
+// This is run on the source hypervisor.
+func (m *Manager) connectToVmMigrationStream(conn *srpc.Conn) error {
+	var request proto.ConnectToVmMigrationStreamRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	listener, err := m.claimVmMigrationStream(request,
+		conn.GetAuthInformation())
+	e := conn.Encode(proto.ConnectToVmMigrationStreamResponse{
+		Error: errors.ErrorToString(err)})
+	if e != nil {
+		return e
+	}
+	if e := conn.Flush(); e != nil {
+		return e
+	}
+	if err != nil {
+		return err
+	}
+	// The listener is closed when the migration finishes, which unblocks this
+	// if QEMU never connects.
+	sock, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	listener.Close()
+	if err := spliceStreams(conn, sock); err != nil {
+		return err
+	}
+	return srpc.ErrorCloseClient
+}
+
+// This is run on the destination hypervisor.
+func (m *Manager) migrateVmLive(conn *srpc.Conn, hypervisor *srpc.Client,
+	vm *vmInfoType, request proto.MigrateVmRequest) error {
+	for index, volume := range vm.VolumeLocations {
+		file, err := os.OpenFile(volume.Filename,
+			os.O_WRONLY|os.O_CREATE|os.O_EXCL, privateFilePerms)
+		if err != nil {
+			return err
+		}
+		file.Close()
+		if err := setVolumeSize(volume.Filename,
+			vm.Volumes[index].Size); err != nil {
+			return err
+		}
+	}
+	err := migratevmUserData(hypervisor,
+		filepath.Join(vm.dirname, "user-data.raw"),
+		request.IpAddress, request.AccessToken)
+	if err != nil {
+		return err
+	}
+	err = sendVmMigrationMessage(conn, "starting VM for incoming migration")
+	if err != nil {
+		return err
+	}
+	vm.State = proto.StateStarting
+	vm.incomingMigration = true
+	m.mutex.Lock()
+	m.vms[vm.ipAddress] = vm
+	m.mutex.Unlock()
+	_, err = vm.startManaging(0, false)
+	vm.incomingMigration = false
+	if err != nil {
+		return err
+	}
+	nbdPort, memoryPort, err := vm.prepareIncomingMigration()
+	if err != nil {
+		return err
+	}
+	controlConn, err := hypervisor.Call("Hypervisor.SendVmLiveMigration")
+	if err != nil {
+		return err
+	}
+	defer controlConn.Close()
+	err = controlConn.Encode(proto.SendVmLiveMigrationRequest{
+		AccessToken:      request.AccessToken,
+		IpAddress:        request.IpAddress,
+		MaximumDowntime:  request.MaximumDowntime,
+		MigrationTimeout: request.MigrationTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	if err := controlConn.Flush(); err != nil {
+		return err
+	}
+	// The first response indicates that the source is ready for the streams.
+	var reply proto.SendVmLiveMigrationResponse
+	if err := controlConn.Decode(&reply); err != nil {
+		return err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return err
+	}
+	streamClients, err := vm.connectMigrationStreams(request, nbdPort,
+		memoryPort)
+	for _, client := range streamClients {
+		defer client.Close()
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		var reply proto.SendVmLiveMigrationResponse
+		if err := controlConn.Decode(&reply); err != nil {
+			return err
+		}
+		if err := errors.New(reply.Error); err != nil {
+			return err
+		}
+		if reply.ProgressMessage != "" {
+			err := sendVmMigrationMessage(conn, reply.ProgressMessage)
+			if err != nil {
+				return err
+			}
+		}
+		if reply.Paused {
+			break
+		}
+		if reply.Final {
+			return errors.New("source ended migration early")
+		}
+	}
+	if err := vm.finishIncomingMigration(); err != nil {
+		return err
+	}
+	if err := sendVmMigrationMessage(conn, "VM resumed"); err != nil {
+		return err
+	}
+	commit, err := requestVmMigrationCommit(conn)
+	if err != nil {
+		return err
+	}
+	err = controlConn.Encode(
+		proto.SendVmLiveMigrationResponseResponse{Commit: commit})
+	if err != nil {
+		return err
+	}
+	if err := controlConn.Flush(); err != nil {
+		return err
+	}
+	if !commit {
+		return errors.New("VM migration abandoned")
+	}
+	// Wait for the source to confirm it has given up the VM. The VM has been
+	// resumed here, so if the confirmation is lost the VM must be kept: the
+	// source copy is destroyed once the migration is recorded.
+	if err := controlConn.Decode(&reply); err != nil {
+		vm.logger.Printf(
+			"error reading commit confirmation from source, keeping VM: %s\n",
+			err)
+		sendVmMigrationMessage(conn,
+			"source did not confirm commit: "+err.Error())
+		return nil
+	}
+	return errors.New(reply.Error)
+}
+
+// This is run on the source hypervisor.
+func (m *Manager) sendVmLiveMigration(conn *srpc.Conn) error {
+	var request proto.SendVmLiveMigrationRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	if request.MaximumDowntime < 1 {
+		request.MaximumDowntime = defaultMaximumDowntime
+	}
+	if request.MigrationTimeout < 1 {
+		request.MigrationTimeout = defaultMigrationTimeout
+	}
+	vm, migration, err := m.startVmLiveMigration(request,
+		conn.GetAuthInformation())
+	if err != nil {
+		return err
+	}
+	defer vm.finishLiveMigration(migration)
+	err = sendVmLiveMigrationMessage(conn, "ready for streams")
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(migrationStreamTimeout)
+	for range migration.listeners {
+		select {
+		case <-migration.claimed:
+		case <-timer.C:
+			return errors.New("timed out waiting for migration streams")
+		}
+	}
+	timer.Stop()
+	var committed bool
+	var devices []string
+	defer func() {
+		if !committed {
+			vm.cancelLiveMigration(devices)
+		}
+	}()
+	if devices, err = vm.getBlockDevices(); err != nil {
+		return err
+	}
+	vm.logger.Println("starting live migration")
+	if err := vm.mirrorVolumes(conn, devices, migration); err != nil {
+		return err
+	}
+	err = vm.migrateMemory(conn, request,
+		migration.listeners[memoryStreamName])
+	if err != nil {
+		return err
+	}
+	if err := vm.completeVolumeMirrors(devices); err != nil {
+		return err
+	}
+	err = conn.Encode(proto.SendVmLiveMigrationResponse{Paused: true})
+	if err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var reply proto.SendVmLiveMigrationResponseResponse
+	if err := conn.Decode(&reply); err != nil {
+		return err
+	}
+	if !reply.Commit {
+		return nil
+	}
+	if err := m.commitVmLiveMigration(vm); err != nil {
+		return err
+	}
+	committed = true
+	vm.logger.Println("live migration committed")
+	return nil
+}
+
+func (m *Manager) startVmLiveMigration(
+	request proto.SendVmLiveMigrationRequest,
+	authInfo *srpc.AuthInformation) (*vmInfoType, *liveMigrationType, error) {
+	vm, err := m.getVmLockAndAuth(request.IpAddress, true, authInfo,
+		request.AccessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer vm.mutex.Unlock()
+	if vm.Uncommitted {
+		return nil, nil, errors.New("VM is uncommitted")
+	}
+	if vm.State != proto.StateRunning {
+		return nil, nil, errors.New("VM is not running")
+	}
+	if vm.snapshotInProgress {
+		return nil, nil, errors.New("snapshot in progress")
+	}
+	if vm.liveMigration != nil {
+		return nil, nil, errors.New("VM is already being live migrated")
+	}
+	if vm.getActiveKernelPath() != "" {
+		return nil, nil,
+			errors.New("cannot live migrate VM with separate kernel")
+	}
+	for _, volume := range vm.Volumes {
+		if volume.Format != proto.VolumeFormatRaw {
+			return nil, nil,
+				errors.New("cannot live migrate non-raw volume")
+		}
+	}
+	// The destination starts QEMU with the current memory size, which must
+	// match the memory the source was started with.
+	var summary qmpMemorySizeSummary
+	err = vm.qmpCommand("query-memory-size-summary", nil, &summary)
+	if err == nil && summary.BaseMemory != vm.MemoryInMiB<<20 {
+		return nil, nil, errors.New(
+			"cannot live migrate VM resized while running, restart VM first")
+	}
+	names := []string{memoryStreamName}
+	for index := range vm.VolumeLocations {
+		names = append(names, makeVolumeStreamName(index))
+	}
+	migration := &liveMigrationType{
+		claimed:   make(chan struct{}, len(names)),
+		listeners: make(map[string]net.Listener, len(names)),
+		unclaimed: make(map[string]struct{}, len(names)),
+	}
+	for _, name := range names {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			for _, listener := range migration.listeners {
+				listener.Close()
+			}
+			return nil, nil, err
+		}
+		migration.listeners[name] = listener
+		migration.unclaimed[name] = struct{}{}
+	}
+	vm.liveMigration = migration
+	return vm, migration, nil
+}
+
+// cancelLiveMigration will cancel the memory migration and volume mirrors
+// and resume the VM if it was paused. Errors are ignored since some of these
+// may not have been started.
+func (vm *vmInfoType) cancelLiveMigration(devices []string) {
+	vm.qmpCommand("migrate_cancel", nil, nil)
+	for _, device := range devices {
+		vm.qmpCommand("block-job-cancel",
+			map[string]interface{}{"device": device, "force": true}, nil)
+	}
+	var status qmpStatus
+	if err := vm.qmpCommand("query-status", nil, &status); err != nil {
+		vm.logger.Printf("error querying status: %s\n", err)
+	} else if !status.Running {
+		if err := vm.qmpCommand("cont", nil, nil); err != nil {
+			vm.logger.Printf("error resuming VM: %s\n", err)
+		}
+	}
+	vm.logger.Println("live migration cancelled")
+}
+
+// completeVolumeMirrors will complete the volume mirrors once the VM is
+// paused, leaving the destination volumes consistent with the source.
+func (vm *vmInfoType) completeVolumeMirrors(devices []string) error {
+	for _, device := range devices {
+		err := vm.qmpCommand("block-job-cancel",
+			map[string]interface{}{"device": device}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	stopTime := time.Now().Add(migrationStreamTimeout)
+	for ; time.Until(stopTime) > 0; time.Sleep(migrationPollInterval) {
+		var jobs []qmpBlockJob
+		if err := vm.qmpCommand("query-block-jobs", nil, &jobs); err != nil {
+			return err
+		}
+		if len(jobs) < 1 {
+			return nil
+		}
+	}
+	return errors.New("timed out completing volume mirrors")
+}
+
+func (vm *vmInfoType) connectMigrationStream(request proto.MigrateVmRequest,
+	streamName string, port int) (*srpc.Client, error) {
+	client, err := srpc.DialHTTP("tcp", request.SourceHypervisor, 0)
+	if err != nil {
+		return nil, err
+	}
+	sock, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	conn, err := client.Call("Hypervisor.ConnectToVmMigrationStream")
+	if err == nil {
+		err = conn.Encode(proto.ConnectToVmMigrationStreamRequest{
+			AccessToken: request.AccessToken,
+			IpAddress:   request.IpAddress,
+			StreamName:  streamName,
+		})
+	}
+	if err == nil {
+		err = conn.Flush()
+	}
+	var reply proto.ConnectToVmMigrationStreamResponse
+	if err == nil {
+		err = conn.Decode(&reply)
+	}
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	if err != nil {
+		sock.Close()
+		client.Close()
+		return nil, err
+	}
+	go func() {
+		if err := spliceStreams(conn, sock); err != nil {
+			vm.logger.Printf("error copying migration stream: %s: %s\n",
+				streamName, err)
+		}
+	}()
+	return client, nil
+}
+
+// connectMigrationStreams will connect the memory and volume streams from the
+// source hypervisor to the local QEMU. The clients for the streams are
+// returned, even if there is an error.
+func (vm *vmInfoType) connectMigrationStreams(request proto.MigrateVmRequest,
+	nbdPort, memoryPort int) ([]*srpc.Client, error) {
+	var clients []*srpc.Client
+	for index := range vm.VolumeLocations {
+		client, err := vm.connectMigrationStream(request,
+			makeVolumeStreamName(index), nbdPort)
+		if err != nil {
+			return clients, err
+		}
+		clients = append(clients, client)
+	}
+	client, err := vm.connectMigrationStream(request, memoryStreamName,
+		memoryPort)
+	if err != nil {
+		return clients, err
+	}
+	return append(clients, client), nil
+}
+
+func (vm *vmInfoType) finishIncomingMigration() error {
+	stopTime := time.Now().Add(migrationStreamTimeout)
+	for ; ; time.Sleep(migrationPollInterval) {
+		var status qmpStatus
+		if err := vm.qmpCommand("query-status", nil, &status); err != nil {
+			return err
+		}
+		if status.Status != "inmigrate" {
+			break
+		}
+		if time.Until(stopTime) <= 0 {
+			return errors.New("timed out waiting for incoming migration")
+		}
+	}
+	if err := vm.qmpCommand("nbd-server-stop", nil, nil); err != nil {
+		return err
+	}
+	return vm.qmpCommand("cont", nil, nil)
+}
+
+func (vm *vmInfoType) finishLiveMigration(migration *liveMigrationType) {
+	for _, listener := range migration.listeners {
+		listener.Close()
+	}
+	vm.mutex.Lock()
+	vm.liveMigration = nil
+	vm.mutex.Unlock()
+}
+
+// migrateMemory will migrate the memory of the VM, waiting until the
+// migration completes (leaving the VM paused), fails or does not converge
+// within the timeout.
+func (vm *vmInfoType) migrateMemory(conn *srpc.Conn,
+	request proto.SendVmLiveMigrationRequest, listener net.Listener) error {
+	err := vm.qmpCommand("migrate-set-parameters",
+		map[string]interface{}{
+			"downtime-limit": uint64(
+				request.MaximumDowntime / time.Millisecond),
+		}, nil)
+	if err != nil {
+		return err
+	}
+	err = vm.qmpCommand("migrate", map[string]interface{}{
+		"uri": fmt.Sprintf("tcp:127.0.0.1:%d", getLoopbackPort(listener)),
+	}, nil)
+	if err != nil {
+		return err
+	}
+	startTime := time.Now()
+	lastProgressTime := startTime
+	for ; ; time.Sleep(migrationPollInterval) {
+		var status qmpMigrationStatus
+		if err := vm.qmpCommand("query-migrate", nil, &status); err != nil {
+			return err
+		}
+		switch status.Status {
+		case "completed":
+			vm.logger.Printf("memory migrated in: %s\n",
+				format.Duration(time.Since(startTime)))
+			return nil
+		case "failed":
+			return errors.New("memory migration failed: " +
+				status.ErrorDescription)
+		case "cancelled":
+			return errors.New("memory migration cancelled")
+		}
+		if time.Since(startTime) >= request.MigrationTimeout {
+			return fmt.Errorf("memory migration did not converge in: %s",
+				format.Duration(request.MigrationTimeout))
+		}
+		if status.Ram == nil ||
+			time.Since(lastProgressTime) < migrationProgressInterval {
+			continue
+		}
+		pageSize := status.Ram.PageSize
+		if pageSize < 1 {
+			pageSize = 4096
+		}
+		err := sendVmLiveMigrationMessage(conn, fmt.Sprintf(
+			"memory: %s remaining, dirtying: %s/s, expected downtime: %dms",
+			format.FormatBytes(status.Ram.Remaining),
+			format.FormatBytes(status.Ram.DirtyPagesRate*pageSize),
+			status.ExpectedDowntime))
+		if err != nil {
+			return err
+		}
+		lastProgressTime = time.Now()
+	}
+}
+
+// mirrorVolumes will start mirroring the volumes of the VM to the destination
+// and wait until the mirrors are synchronised. Writes by the VM continue to be
+// mirrored until the mirrors are completed.
+func (vm *vmInfoType) mirrorVolumes(conn *srpc.Conn, devices []string,
+	migration *liveMigrationType) error {
+	for index, device := range devices {
+		streamName := makeVolumeStreamName(index)
+		err := vm.qmpCommand("drive-mirror", map[string]interface{}{
+			"device": device,
+			"format": "raw",
+			"mode":   "existing",
+			"sync":   "full",
+			"target": fmt.Sprintf("nbd:127.0.0.1:%d:exportname=%s",
+				getLoopbackPort(migration.listeners[streamName]),
+				streamName),
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	lastProgressTime := time.Now()
+	for ; ; time.Sleep(migrationPollInterval) {
+		var jobs []qmpBlockJob
+		if err := vm.qmpCommand("query-block-jobs", nil, &jobs); err != nil {
+			return err
+		}
+		if len(jobs) < len(devices) {
+			return errors.New("volume mirror failed")
+		}
+		var length, numReady, offset uint64
+		for _, job := range jobs {
+			length += job.Length
+			offset += job.Offset
+			if job.Ready {
+				numReady++
+			}
+		}
+		if numReady >= uint64(len(devices)) {
+			return sendVmLiveMigrationMessage(conn, "volumes synchronised")
+		}
+		if time.Since(lastProgressTime) < migrationProgressInterval {
+			continue
+		}
+		err := sendVmLiveMigrationMessage(conn, fmt.Sprintf(
+			"volumes: %s of %s copied",
+			format.FormatBytes(offset), format.FormatBytes(length)))
+		if err != nil {
+			return err
+		}
+		lastProgressTime = time.Now()
+	}
+}
+
+// prepareIncomingMigration will start the NBD server for the volumes and
+// start listening for the memory stream. The ports are returned.
+func (vm *vmInfoType) prepareIncomingMigration() (int, int, error) {
+	devices, err := vm.getBlockDevices()
+	if err != nil {
+		return 0, 0, err
+	}
+	nbdPort, err := getFreeLoopbackPort()
+	if err != nil {
+		return 0, 0, err
+	}
+	err = vm.qmpCommand("nbd-server-start", map[string]interface{}{
+		"addr": map[string]interface{}{
+			"type": "inet",
+			"data": map[string]string{
+				"host": "127.0.0.1",
+				"port": fmt.Sprintf("%d", nbdPort),
+			},
+		},
+	}, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	for index, device := range devices {
+		err := vm.qmpCommand("nbd-server-add", map[string]interface{}{
+			"device":   device,
+			"name":     makeVolumeStreamName(index),
+			"writable": true,
+		}, nil)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	memoryPort, err := getFreeLoopbackPort()
+	if err != nil {
+		return 0, 0, err
+	}
+	err = vm.qmpCommand("migrate-incoming", map[string]interface{}{
+		"uri": fmt.Sprintf("tcp:127.0.0.1:%d", memoryPort),
+	}, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	return nbdPort, memoryPort, nil
+}
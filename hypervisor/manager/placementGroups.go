@@ -0,0 +1,33 @@
+package manager
+
+import (
+	"fmt"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// checkPlacementGroupsWithLock will return an error if placing a VM in the
+// specified placement groups on this Hypervisor would violate a strict
+// spreadHypervisors group, i.e. another VM here is in the same group. The
+// other policies depend on the VMs on other Hypervisors and are checked by the
+// Fleet Manager.
+func (m *Manager) checkPlacementGroupsWithLock(
+	groups []proto.PlacementGroup) error {
+	for _, group := range groups {
+		if !group.Strict ||
+			group.Policy != proto.PlacementPolicySpreadHypervisors {
+			continue
+		}
+		for ipAddr, vm := range m.vms {
+			for _, vmGroup := range vm.PlacementGroups {
+				if vmGroup.Name == group.Name &&
+					vmGroup.Policy == group.Policy {
+					return fmt.Errorf(
+						"placement group: %s: VM: %s on Hypervisor",
+						group.Name, ipAddr)
+				}
+			}
+		}
+	}
+	return nil
+}
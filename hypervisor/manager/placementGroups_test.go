@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"testing"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestCheckPlacementGroups(t *testing.T) {
+	spread := proto.PlacementGroup{Name: "web",
+		Policy: proto.PlacementPolicySpreadHypervisors}
+	strictSpread := spread
+	strictSpread.Strict = true
+	strictRacks := proto.PlacementGroup{Name: "web",
+		Policy: proto.PlacementPolicySpreadRacks, Strict: true}
+	m := &Manager{vms: map[string]*vmInfoType{
+		"10.0.0.1": {
+			LocalVmInfo: proto.LocalVmInfo{
+				VmInfo: proto.VmInfo{
+					PlacementGroups: []proto.PlacementGroup{spread},
+				},
+			},
+		},
+	}}
+	var tests = []struct {
+		name    string
+		groups  []proto.PlacementGroup
+		wantErr bool
+	}{
+		{"no groups", nil, false},
+		{"non-strict", []proto.PlacementGroup{spread}, false},
+		{"strict", []proto.PlacementGroup{strictSpread}, true},
+		{"strict other group",
+			[]proto.PlacementGroup{{Name: "db",
+				Policy: proto.PlacementPolicySpreadHypervisors,
+				Strict: true}}, false},
+		{"strict other policy", []proto.PlacementGroup{strictRacks}, false},
+	}
+	for _, test := range tests {
+		err := m.checkPlacementGroupsWithLock(test.groups)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error: %v, want error: %t",
+				test.name, err, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+)
+
+const qmpCommandTimeout = time.Minute
+
+type qmpBlockDevice struct {
+	Device   string `json:"device"`
+	Inserted *struct {
+		File string `json:"file"`
+	} `json:"inserted"`
+}
+
+type qmpBlockJob struct {
+	Device string `json:"device"` // The job ID if one was specified.
+	Error  string `json:"error"`
+	Length uint64 `json:"len"`
+	Offset uint64 `json:"offset"`
+	Ready  bool   `json:"ready"`
+	Status string `json:"status"`
+}
+
+type qmpCommandMessage struct {
+	Arguments interface{} `json:"arguments,omitempty"`
+	Execute   string      `json:"execute"`
+	Id        uint64      `json:"id"`
+}
+
+type qmpError struct {
+	Class       string `json:"class"`
+	Description string `json:"desc"`
+}
+
+type qmpMemorySizeSummary struct {
+	BaseMemory uint64 `json:"base-memory"`
+}
+
+type qmpMessage struct {
+	Error  *qmpError       `json:"error"`
+	Id     uint64          `json:"id"`
+	Return json.RawMessage `json:"return"`
+}
+
+type qmpMigrationStatus struct {
+	ErrorDescription string `json:"error-desc"`
+	ExpectedDowntime uint64 `json:"expected-downtime"` // Milliseconds.
+	Ram              *struct {
+		DirtyPagesRate uint64 `json:"dirty-pages-rate"`
+		PageSize       uint64 `json:"page-size"`
+		Remaining      uint64 `json:"remaining"`
+		Total          uint64 `json:"total"`
+		Transferred    uint64 `json:"transferred"`
+	} `json:"ram"`
+	Status string `json:"status"`
+}
+
+type qmpStatus struct {
+	Running bool   `json:"running"`
+	Status  string `json:"status"`
+}
+
+// getBlockDevices will return the QEMU block device names for the volumes of
+// the VM, in volume order.
+func (vm *vmInfoType) getBlockDevices() ([]string, error) {
+	var blockDevices []qmpBlockDevice
+	if err := vm.qmpCommand("query-block", nil, &blockDevices); err != nil {
+		return nil, err
+	}
+	devices := make([]string, 0, len(vm.VolumeLocations))
+	for _, volume := range vm.VolumeLocations {
+		var device string
+		for _, blockDevice := range blockDevices {
+			if blockDevice.Inserted != nil &&
+				blockDevice.Inserted.File == volume.Filename {
+				device = blockDevice.Device
+				break
+			}
+		}
+		if device == "" {
+			return nil, fmt.Errorf("no block device for: %s", volume.Filename)
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// qmpCommand will send a command to the QEMU monitor and wait for the
+// response. If result is not nil the returned value is decoded into it.
+func (vm *vmInfoType) qmpCommand(command string, arguments interface{},
+	result interface{}) error {
+	responseChannel := make(chan qmpMessage, 1)
+	vm.monitorLock.Lock()
+	if vm.monitorConn == nil {
+		vm.monitorLock.Unlock()
+		return errors.New("no connection to QEMU monitor")
+	}
+	vm.monitorCommandId++
+	id := vm.monitorCommandId
+	buffer, err := json.Marshal(qmpCommandMessage{
+		Arguments: arguments,
+		Execute:   command,
+		Id:        id,
+	})
+	if err == nil {
+		vm.monitorPending[id] = responseChannel
+		if _, err = vm.monitorConn.Write(buffer); err != nil {
+			delete(vm.monitorPending, id)
+		}
+	}
+	vm.monitorLock.Unlock()
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(qmpCommandTimeout)
+	select {
+	case message, ok := <-responseChannel:
+		timer.Stop()
+		if !ok {
+			return errors.New("QEMU monitor connection closed")
+		}
+		if message.Error != nil {
+			return fmt.Errorf("%s: %s", command, message.Error.Description)
+		}
+		if result == nil || len(message.Return) < 1 {
+			return nil
+		}
+		return json.Unmarshal(message.Return, result)
+	case <-timer.C:
+		vm.monitorLock.Lock()
+		delete(vm.monitorPending, id)
+		vm.monitorLock.Unlock()
+		return fmt.Errorf("timed out waiting for response to: %s", command)
+	}
+}
+
+// readMonitorResponses will read responses from the QEMU monitor until the
+// connection is closed, passing responses to commands sent by qmpCommand to
+// the waiting callers. Other responses and events are dropped.
+func (vm *vmInfoType) readMonitorResponses(monitorSock net.Conn) {
+	decoder := json.NewDecoder(monitorSock)
+	for {
+		var message qmpMessage
+		if err := decoder.Decode(&message); err != nil {
+			break
+		}
+		if message.Id < 1 {
+			continue
+		}
+		vm.monitorLock.Lock()
+		responseChannel := vm.monitorPending[message.Id]
+		delete(vm.monitorPending, message.Id)
+		vm.monitorLock.Unlock()
+		if responseChannel != nil {
+			responseChannel <- message
+		}
+	}
+	vm.monitorLock.Lock()
+	vm.monitorConn = nil
+	for id, responseChannel := range vm.monitorPending {
+		close(responseChannel)
+		delete(vm.monitorPending, id)
+	}
+	vm.monitorLock.Unlock()
+	io.Copy(ioutil.Discard, monitorSock) // Read all and drop.
+}
+
+// setBalloonSize will change the memory available to the VM using the
+// balloon device. The memory may not exceed what the VM was started with.
+func (vm *vmInfoType) setBalloonSize(memoryInMiB uint64) error {
+	var summary qmpMemorySizeSummary
+	err := vm.qmpCommand("query-memory-size-summary", nil, &summary)
+	if err != nil {
+		return err
+	}
+	if memoryInMiB<<20 > summary.BaseMemory {
+		return fmt.Errorf(
+			"cannot grow memory of running VM beyond %s, stop VM first",
+			format.FormatBytes(summary.BaseMemory))
+	}
+	return vm.qmpCommand("balloon",
+		map[string]interface{}{"value": memoryInMiB << 20}, nil)
+}
+
+// startMonitor will negotiate capabilities with the QEMU monitor and then
+// start processing commands and responses.
+func (vm *vmInfoType) startMonitor(monitorSock net.Conn) {
+	commandChannel := make(chan string, 1)
+	vm.commandChannel = commandChannel
+	vm.monitorLock.Lock()
+	_, err := io.WriteString(monitorSock, `{"execute":"qmp_capabilities"}`)
+	if err != nil {
+		vm.logger.Println(err)
+	}
+	vm.monitorConn = monitorSock
+	vm.monitorPending = make(map[uint64]chan<- qmpMessage)
+	vm.monitorLock.Unlock()
+	go vm.monitor(monitorSock, commandChannel)
+}
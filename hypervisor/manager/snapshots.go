@@ -0,0 +1,334 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const scheduledSnapshotPrefix = "scheduled-"
+
+func checkSnapshotName(snapshotName string) error {
+	if strings.ContainsRune(snapshotName, '/') {
+		return errors.New("snapshot name may not contain '/'")
+	}
+	if strings.HasPrefix(snapshotName, ".") {
+		return errors.New("snapshot name may not start with '.'")
+	}
+	return nil
+}
+
+// getSnapshotFilename returns the name of the snapshot file for a volume. The
+// empty snapshot name is the default snapshot.
+func getSnapshotFilename(volumeFilename, snapshotName string) string {
+	if snapshotName == "" {
+		return volumeFilename + ".snapshot"
+	}
+	return volumeFilename + ".snapshot." + snapshotName
+}
+
+func checkSnapshotSchedule(schedule *proto.SnapshotSchedule) error {
+	if schedule == nil || schedule.Interval <= 0 {
+		return nil
+	}
+	if schedule.Interval < time.Minute {
+		return errors.New("snapshot interval must be at least 1m")
+	}
+	if schedule.NumToRetain < 1 {
+		return errors.New("must retain at least one snapshot")
+	}
+	return nil
+}
+
+func (m *Manager) changeVmSnapshotSchedule(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, schedule proto.SnapshotSchedule) error {
+	if err := checkSnapshotSchedule(&schedule); err != nil {
+		return err
+	}
+	if schedule.Interval > 0 && schedule.Backup {
+		imageDirectory := schedule.BackupImageDirectory
+		if err := m.checkBackupImageName("", imageDirectory); err != nil {
+			return err
+		}
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	if schedule.Interval > 0 {
+		vm.SnapshotSchedule = &schedule
+	} else {
+		vm.SnapshotSchedule = nil
+	}
+	vm.writeAndSendInfo()
+	return nil
+}
+
+func (m *Manager) loopSnapshotSchedules() {
+	for ; ; time.Sleep(time.Minute) {
+		m.mutex.RLock()
+		vms := make([]*vmInfoType, 0, len(m.vms))
+		for _, vm := range m.vms {
+			vms = append(vms, vm)
+		}
+		m.mutex.RUnlock()
+		for _, vm := range vms {
+			vm.mutex.Lock()
+			if vm.scheduledSnapshotRunning {
+				vm.mutex.Unlock()
+				continue
+			}
+			vm.scheduledSnapshotRunning = true
+			vm.mutex.Unlock()
+			go func(vm *vmInfoType) {
+				vm.checkSnapshotSchedule()
+				vm.mutex.Lock()
+				vm.scheduledSnapshotRunning = false
+				vm.mutex.Unlock()
+			}(vm)
+		}
+	}
+}
+
+// backupVolumes will copy the volumes of a running VM to the snapshot files
+// using QEMU drive-backup block jobs, which make a point-in-time copy while
+// the VM continues to run. The VM lock must not be held.
+func (vm *vmInfoType) backupVolumes(devices, filenames,
+	formats []string) error {
+	jobIds := make(map[string]struct{}, len(devices))
+	defer func() {
+		for jobId := range jobIds {
+			vm.qmpCommand("block-job-cancel",
+				map[string]interface{}{"device": jobId, "force": true}, nil)
+			vm.qmpCommand("block-job-dismiss",
+				map[string]interface{}{"id": jobId}, nil)
+		}
+	}()
+	for index, device := range devices {
+		jobId := "snapshot-" + device
+		err := vm.qmpCommand("drive-backup", map[string]interface{}{
+			"auto-dismiss": false,
+			"device":       device,
+			"format":       formats[index],
+			"job-id":       jobId,
+			"sync":         "full",
+			"target":       filenames[index],
+		}, nil)
+		if err != nil {
+			return err
+		}
+		jobIds[jobId] = struct{}{}
+	}
+	for ; len(jobIds) > 0; time.Sleep(migrationPollInterval) {
+		var jobs []qmpBlockJob
+		if err := vm.qmpCommand("query-block-jobs", nil, &jobs); err != nil {
+			return err
+		}
+		numFound := 0
+		for _, job := range jobs {
+			if _, ok := jobIds[job.Device]; !ok {
+				continue
+			}
+			numFound++
+			if job.Status != "concluded" {
+				continue
+			}
+			if job.Error != "" {
+				return fmt.Errorf("error copying: %s: %s", job.Device,
+					job.Error)
+			}
+			err := vm.qmpCommand("block-job-dismiss",
+				map[string]interface{}{"id": job.Device}, nil)
+			if err != nil {
+				return err
+			}
+			delete(jobIds, job.Device)
+		}
+		if numFound < len(jobIds) {
+			return errors.New("snapshot block job disappeared")
+		}
+	}
+	return nil
+}
+
+// checkSnapshotSchedule will take a snapshot of the VM if one is due, discard
+// the oldest scheduled snapshots and back up the new snapshot if required.
+func (vm *vmInfoType) checkSnapshotSchedule() {
+	vm.mutex.Lock()
+	doUnlock := true
+	defer func() {
+		if doUnlock {
+			vm.mutex.Unlock()
+		}
+	}()
+	schedule := vm.SnapshotSchedule
+	if schedule == nil || schedule.Interval <= 0 {
+		return
+	}
+	switch vm.State {
+	case proto.StateStopped, proto.StateRunning:
+	default:
+		return
+	}
+	lastTime := vm.lastScheduledSnapshotAttempt
+	for _, snapshot := range vm.Snapshots {
+		if strings.HasPrefix(snapshot.Name, scheduledSnapshotPrefix) &&
+			snapshot.Time.After(lastTime) {
+			lastTime = snapshot.Time
+		}
+	}
+	if time.Since(lastTime) < schedule.Interval {
+		return
+	}
+	vm.lastScheduledSnapshotAttempt = time.Now()
+	snapshotName := scheduledSnapshotPrefix +
+		time.Now().UTC().Format("20060102-150405")
+	if err := vm.snapshot(false, snapshotName); err != nil {
+		vm.logger.Printf("error taking scheduled snapshot: %s\n", err)
+		return
+	}
+	vm.pruneScheduledSnapshots(schedule.NumToRetain)
+	if !schedule.Backup {
+		return
+	}
+	var imageName string
+	if schedule.BackupImageDirectory != "" {
+		imageName = path.Join(schedule.BackupImageDirectory, snapshotName)
+	}
+	job, err := vm.prepareBackup(snapshotName, "", imageName)
+	if err != nil {
+		vm.logger.Printf("error backing up scheduled snapshot: %s\n", err)
+		return
+	}
+	vm.mutex.Unlock()
+	doUnlock = false
+	if _, err := vm.runBackup(job); err != nil {
+		vm.logger.Printf("error backing up scheduled snapshot: %s\n", err)
+	}
+}
+
+// pruneScheduledSnapshots will discard the oldest scheduled snapshots so that
+// no more than numToRetain remain. The VM lock must be held.
+func (vm *vmInfoType) pruneScheduledSnapshots(numToRetain uint) {
+	var scheduled []proto.Snapshot
+	for _, snapshot := range vm.Snapshots {
+		if strings.HasPrefix(snapshot.Name, scheduledSnapshotPrefix) {
+			scheduled = append(scheduled, snapshot)
+		}
+	}
+	if uint(len(scheduled)) <= numToRetain {
+		return
+	}
+	sort.Slice(scheduled, func(i, j int) bool {
+		return scheduled[i].Time.Before(scheduled[j].Time)
+	})
+	for _, snapshot := range scheduled[:uint(len(scheduled))-numToRetain] {
+		if err := vm.discardSnapshot(snapshot.Name); err != nil {
+			vm.logger.Printf("error discarding snapshot: %s: %s\n",
+				snapshot.Name, err)
+		}
+	}
+}
+
+func (vm *vmInfoType) removeSnapshotFromList(snapshotName string) {
+	for index, snapshot := range vm.Snapshots {
+		if snapshot.Name == snapshotName {
+			snapshots := make([]proto.Snapshot, 0, len(vm.Snapshots)-1)
+			snapshots = append(snapshots, vm.Snapshots[:index]...)
+			vm.Snapshots = append(snapshots, vm.Snapshots[index+1:]...)
+			vm.writeAndSendInfo()
+			return
+		}
+	}
+}
+
+// snapshot will copy the volumes of the VM to a snapshot, replacing any
+// existing snapshot with the same name. The volumes of a running VM are copied
+// with QEMU block jobs and the VM lock is released while copying. The VM lock
+// must be held.
+func (vm *vmInfoType) snapshot(rootOnly bool, snapshotName string) error {
+	if vm.snapshotInProgress {
+		return errors.New("snapshot in progress")
+	}
+	if vm.liveMigration != nil {
+		return errors.New("VM is being live migrated")
+	}
+	if err := vm.discardSnapshot(snapshotName); err != nil {
+		return err
+	}
+	freeSpaceTable := make(map[string]uint64)
+	var filenames, formats []string
+	for index, volume := range vm.VolumeLocations {
+		if index > 0 && rootOnly {
+			break
+		}
+		dirname := filepath.Dir(volume.Filename)
+		freeSpace, err := getFreeSpace(dirname, freeSpaceTable)
+		if err != nil {
+			return err
+		}
+		size := vm.Volumes[index].Size
+		if size >= freeSpace {
+			return fmt.Errorf("not enough free space to snapshot volume: %d",
+				index)
+		}
+		freeSpaceTable[dirname] = freeSpace - size
+		filenames = append(filenames,
+			getSnapshotFilename(volume.Filename, snapshotName))
+		formats = append(formats, vm.Volumes[index].Format.String())
+	}
+	doCleanup := true
+	defer func() {
+		if doCleanup {
+			vm.discardSnapshot(snapshotName)
+		}
+	}()
+	snapshotTime := time.Now()
+	if vm.State == proto.StateRunning {
+		devices, err := vm.getBlockDevices()
+		if err != nil {
+			return err
+		}
+		vm.snapshotInProgress = true
+		vm.mutex.Unlock()
+		err = vm.backupVolumes(devices[:len(filenames)], filenames, formats)
+		vm.mutex.Lock()
+		vm.snapshotInProgress = false
+		if err != nil {
+			return err
+		}
+		switch vm.State {
+		case proto.StateStopped, proto.StateRunning:
+		default:
+			return errors.New("VM state changed during snapshot: " +
+				vm.State.String())
+		}
+	} else {
+		for index, filename := range filenames {
+			err := fsutil.CopyFile(filename,
+				vm.VolumeLocations[index].Filename, privateFilePerms)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	doCleanup = false
+	snapshots := make([]proto.Snapshot, 0, len(vm.Snapshots)+1)
+	vm.Snapshots = append(append(snapshots, vm.Snapshots...), proto.Snapshot{
+		Name:     snapshotName,
+		RootOnly: rootOnly,
+		Time:     snapshotTime,
+	})
+	vm.writeAndSendInfo()
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/Symantec/Dominator/lib/meminfo"
 	"github.com/Symantec/Dominator/lib/objectserver/cachingreader"
 	"github.com/Symantec/Dominator/lib/rpcclientpool"
+	"github.com/Symantec/Dominator/lib/vmidentity"
 	proto "github.com/Symantec/Dominator/proto/hypervisor"
 	"github.com/Symantec/tricorder/go/tricorder/messages"
 	trimsg "github.com/Symantec/tricorder/go/tricorder/messages"
@@ -44,8 +45,19 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 	if err != nil {
 		return nil, err
 	}
+	identityKey, err := vmidentity.LoadOrGenerateKey(
+		filepath.Join(startOptions.StateDir, "identity-key.pem"))
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
 	manager := &Manager{
 		StartOptions:      startOptions,
+		hostname:          hostname,
+		identityKey:       identityKey,
 		rootCookie:        rootCookie,
 		memTotalInMiB:     memInfo.Total >> 20,
 		notifiers:         make(map[<-chan proto.Update]chan<- proto.Update),
@@ -159,7 +171,10 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		}
 		manager.objectCache = objSrv
 	}
+	manager.updateResources()
 	go manager.loopCheckHealthStatus()
+	go manager.loopSnapshotSchedules()
+	go manager.loopUpdateResources()
 	return manager, nil
 }
 
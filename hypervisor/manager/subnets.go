@@ -196,12 +196,18 @@ func (m *Manager) updateSubnets(request proto.UpdateSubnetsRequest) error {
 		if subnet.Id == "hypervisor" {
 			return fmt.Errorf("cannot add hypervisor subnet")
 		}
+		if _, err := subnet.GetIpv6Network(); err != nil {
+			return err
+		}
 		request.Add[index].Shrink()
 	}
 	for index, subnet := range request.Change {
 		if subnet.Id == "hypervisor" {
 			return fmt.Errorf("cannot change hypervisor subnet")
 		}
+		if _, err := subnet.GetIpv6Network(); err != nil {
+			return err
+		}
 		request.Change[index].Shrink()
 	}
 	for _, subnetId := range request.Delete {
@@ -218,11 +224,14 @@ func (m *Manager) updateSubnets(request proto.UpdateSubnetsRequest) error {
 			ch <- subnet
 		}
 	}
+	changedSubnetIDs := make(map[string]struct{}, len(request.Change))
 	for _, subnet := range request.Change {
 		m.DhcpServer.RemoveSubnet(subnet.Id)
 		m.DhcpServer.AddSubnet(subnet)
+		changedSubnetIDs[subnet.Id] = struct{}{}
 		// TOOO(rgooch): Design a clean way to send updates to the channels.
 	}
+	m.updateSubnetVMs(changedSubnetIDs)
 	for _, subnetId := range request.Delete {
 		m.DhcpServer.RemoveSubnet(subnetId)
 		// TOOO(rgooch): Design a clean way to send deletes to the channels.
@@ -230,6 +239,43 @@ func (m *Manager) updateSubnets(request proto.UpdateSubnetsRequest) error {
 	return nil
 }
 
+// updateSubnetVMs will update the IPv6 addresses, DHCP leases and firewalls of
+// the VMs which have an interface on one of the specified subnets.
+func (m *Manager) updateSubnetVMs(subnetIDs map[string]struct{}) {
+	if len(subnetIDs) < 1 {
+		return
+	}
+	m.mutex.RLock()
+	vms := make([]*vmInfoType, 0)
+	for _, vm := range m.vms {
+		for _, subnetId := range vm.getInterfaceSubnetIDs() {
+			if _, ok := subnetIDs[subnetId]; ok {
+				vms = append(vms, vm)
+				break
+			}
+		}
+	}
+	m.mutex.RUnlock()
+	for _, vm := range vms {
+		vm.mutex.Lock()
+		running := vm.State == proto.StateRunning
+		if vm.updateIpv6Addresses(false) {
+			vm.writeAndSendInfo()
+			if running {
+				for _, address := range vm.getInterfaceAddresses() {
+					m.DhcpServer.AddLease(address, vm.Hostname)
+				}
+			}
+		}
+		if running {
+			if err := vm.updateFirewall(false); err != nil {
+				vm.logger.Println(err)
+			}
+		}
+		vm.mutex.Unlock()
+	}
+}
+
 func (m *Manager) updateSubnetsLocked(
 	request proto.UpdateSubnetsRequest) error {
 	m.mutex.Lock()
@@ -1,9 +1,20 @@
 package manager
 
 import (
+	"crypto/ed25519"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/meminfo"
 	proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
 
+const (
+	minimumResourcesUpdateInterval = time.Minute
+	resourcesUpdateInterval        = time.Second * 10
+	resourceChangeThresholdInMiB   = 1024
+	resourceChangeThresholdPercent = 5
+)
+
 func (m *Manager) closeUpdateChannel(channel <-chan proto.Update) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -16,6 +27,108 @@ func (m *Manager) getHealthStatus() string {
 	return m.healthStatus
 }
 
+// getResourcesWithLock will return the capacity of the Hypervisor and how
+// much is available for new VMs. The host memory and free volume space are
+// refreshed periodically by loopUpdateResources.
+func (m *Manager) getResourcesWithLock() *proto.Resources {
+	resources := &proto.Resources{
+		AvailableMemoryInMiB: m.getUnallocatedMemoryInMiBWithLock(),
+		AvailableMilliCPUs:   m.getAvailableMilliCPUWithLock(),
+		FreeVolumeSpace:      m.freeVolumeSpace,
+		TotalMemoryInMiB:     m.memTotalInMiB,
+		TotalMilliCPUs:       uint(m.numCPU) * 1000,
+	}
+	if m.memAvailableInMiB < resources.AvailableMemoryInMiB {
+		resources.AvailableMemoryInMiB = m.memAvailableInMiB
+	}
+	return resources
+}
+
+// isSignificantChange returns true if the change from oldValue to newValue (in
+// MiB) is at least resourceChangeThresholdInMiB or at least
+// resourceChangeThresholdPercent of oldValue.
+func isSignificantChange(oldValue, newValue uint64) bool {
+	var delta uint64
+	if newValue > oldValue {
+		delta = newValue - oldValue
+	} else {
+		delta = oldValue - newValue
+	}
+	if delta < 1 {
+		return false
+	}
+	return delta >= resourceChangeThresholdInMiB ||
+		delta*100 >= oldValue*resourceChangeThresholdPercent
+}
+
+// isSignificantResourcesChange returns true if the available memory (in MiB)
+// or the free space (in bytes) of any volume directory changed significantly.
+func isSignificantResourcesChange(oldMemory, newMemory uint64,
+	oldFreeSpace, newFreeSpace []uint64) bool {
+	if len(oldFreeSpace) != len(newFreeSpace) {
+		return true
+	}
+	if isSignificantChange(oldMemory, newMemory) {
+		return true
+	}
+	for index, freeSpace := range newFreeSpace {
+		if isSignificantChange(oldFreeSpace[index]>>20, freeSpace>>20) {
+			return true
+		}
+	}
+	return false
+}
+
+// loopUpdateResources will periodically refresh the resources and send an
+// update if they changed significantly since the last update was sent. Updates
+// are sent at most once every minimumResourcesUpdateInterval.
+func (m *Manager) loopUpdateResources() {
+	m.mutex.RLock()
+	sentMemory := m.memAvailableInMiB
+	sentFreeSpace := m.freeVolumeSpace
+	m.mutex.RUnlock()
+	var lastSentTime time.Time
+	for ; ; time.Sleep(resourcesUpdateInterval) {
+		memAvailableInMiB, freeVolumeSpace := m.updateResources()
+		if time.Since(lastSentTime) < minimumResourcesUpdateInterval {
+			continue
+		}
+		if !isSignificantResourcesChange(sentMemory, memAvailableInMiB,
+			sentFreeSpace, freeVolumeSpace) {
+			continue
+		}
+		m.sendUpdate(proto.Update{})
+		lastSentTime = time.Now()
+		sentMemory = memAvailableInMiB
+		sentFreeSpace = freeVolumeSpace
+	}
+}
+
+// updateResources will read the available host memory and the free space in
+// the volume directories. The new values are returned.
+func (m *Manager) updateResources() (uint64, []uint64) {
+	memAvailableInMiB := m.memTotalInMiB
+	if memInfo, err := meminfo.GetMemInfo(); err != nil {
+		m.Logger.Println(err)
+	} else {
+		memAvailableInMiB = memInfo.Available >> 20
+	}
+	freeSpaceTable := make(map[string]uint64, len(m.volumeDirectories))
+	freeVolumeSpace := make([]uint64, 0, len(m.volumeDirectories))
+	for _, dirname := range m.volumeDirectories {
+		freeSpace, err := getFreeSpace(dirname, freeSpaceTable)
+		if err != nil {
+			m.Logger.Println(err)
+		}
+		freeVolumeSpace = append(freeVolumeSpace, freeSpace)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.memAvailableInMiB = memAvailableInMiB
+	m.freeVolumeSpace = freeVolumeSpace // Replaced, never modified.
+	return memAvailableInMiB, freeVolumeSpace
+}
+
 func (m *Manager) makeUpdateChannel() <-chan proto.Update {
 	channel := make(chan proto.Update, 16)
 	m.mutex.Lock()
@@ -37,16 +150,18 @@ func (m *Manager) makeUpdateChannel() <-chan proto.Update {
 	}
 	// Initial update: give everything.
 	channel <- proto.Update{
-		HaveAddressPool:  true,
-		AddressPool:      m.addressPool.Registered,
-		NumFreeAddresses: numFreeAddresses,
-		HealthStatus:     m.healthStatus,
-		HaveSerialNumber: true,
-		SerialNumber:     m.serialNumber,
-		HaveSubnets:      true,
-		Subnets:          subnets,
-		HaveVMs:          true,
-		VMs:              vms,
+		HaveAddressPool:   true,
+		AddressPool:       m.addressPool.Registered,
+		NumFreeAddresses:  numFreeAddresses,
+		HealthStatus:      m.healthStatus,
+		IdentityPublicKey: m.identityKey.Public().(ed25519.PublicKey),
+		HaveSerialNumber:  true,
+		SerialNumber:      m.serialNumber,
+		HaveSubnets:       true,
+		Subnets:           subnets,
+		HaveVMs:           true,
+		VMs:               vms,
+		Resources:         m.getResourcesWithLock(),
 	}
 	return channel
 }
@@ -59,6 +174,7 @@ func (m *Manager) sendUpdate(update proto.Update) {
 
 func (m *Manager) sendUpdateWithLock(update proto.Update) {
 	update.HealthStatus = m.healthStatus
+	update.Resources = m.getResourcesWithLock()
 	for readChannel, writeChannel := range m.notifiers {
 		select {
 		case writeChannel <- update:
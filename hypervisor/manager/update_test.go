@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"testing"
+)
+
+func TestIsSignificantResourcesChange(t *testing.T) {
+	var tests = []struct {
+		name                       string
+		oldMemory, newMemory       uint64 // MiB.
+		oldFreeSpace, newFreeSpace []uint64
+		want                       bool
+	}{
+		{"unchanged", 65536, 65536, []uint64{1 << 40}, []uint64{1 << 40},
+			false},
+		{"small memory change", 65536, 65535, nil, nil, false},
+		{"memory change above MiB threshold", 65536, 64512, nil, nil, true},
+		{"memory increase above MiB threshold", 64512, 65536, nil, nil,
+			true},
+		{"memory change above percentage", 1000, 949, nil, nil, true},
+		{"memory change below percentage", 1000, 951, nil, nil, false},
+		{"small free space change", 100, 100, []uint64{1 << 40},
+			[]uint64{1<<40 - 1<<20}, false},
+		{"free space change above MiB threshold", 100, 100,
+			[]uint64{1 << 40}, []uint64{1<<40 - 1<<30}, true},
+		{"second directory changed", 100, 100, []uint64{1 << 40, 1 << 30},
+			[]uint64{1 << 40, 1 << 29}, true},
+		{"directory added", 100, 100, []uint64{1 << 40},
+			[]uint64{1 << 40, 1 << 40}, true},
+	}
+	for _, test := range tests {
+		got := isSignificantResourcesChange(test.oldMemory, test.newMemory,
+			test.oldFreeSpace, test.newFreeSpace)
+		if got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+}
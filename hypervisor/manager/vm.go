@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -82,7 +83,7 @@ func copyData(filename string, reader io.Reader, length uint64,
 	return err
 }
 
-func createTapDevice(bridge string) (*os.File, error) {
+func createTapDevice(bridge, name string) (*os.File, error) {
 	tapFile, tapName, err := libnet.CreateTapDevice()
 	if err != nil {
 		return nil, fmt.Errorf("error creating tap device: %s", err)
@@ -93,7 +94,12 @@ func createTapDevice(bridge string) (*os.File, error) {
 			tapFile.Close()
 		}
 	}()
-	cmd := exec.Command("ip", "link", "set", tapName, "up")
+	cmd := exec.Command("ip", "link", "set", tapName, "name", name)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("error renaming: %s: %s", err, output)
+	}
+	tapName = name
+	cmd = exec.Command("ip", "link", "set", tapName, "up")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		return nil, fmt.Errorf("error upping: %s: %s", err, output)
 	}
@@ -212,15 +218,26 @@ func (m *Manager) acknowledgeVm(ipAddr net.IP,
 
 func (m *Manager) allocateVm(req proto.CreateVmRequest,
 	authInfo *srpc.AuthInformation) (*vmInfoType, error) {
+	if err := req.CloudInitDatasource.CheckValid(); err != nil {
+		return nil, err
+	}
 	if err := req.ConsoleType.CheckValid(); err != nil {
 		return nil, err
 	}
+	if req.FirewallPolicy != nil {
+		if err := req.FirewallPolicy.CheckValid(); err != nil {
+			return nil, err
+		}
+	}
 	if req.MemoryInMiB < 1 {
 		return nil, errors.New("no memory specified")
 	}
 	if req.MilliCPUs < 1 {
 		return nil, errors.New("no CPUs specified")
 	}
+	if err := checkSnapshotSchedule(req.SnapshotSchedule); err != nil {
+		return nil, err
+	}
 	subnetIDs := map[string]struct{}{req.SubnetId: {}}
 	for _, subnetId := range req.SecondarySubnetIDs {
 		if subnetId == "" {
@@ -269,6 +286,9 @@ func (m *Manager) allocateVm(req proto.CreateVmRequest,
 	if err := m.checkSufficientMemoryWithLock(req.MemoryInMiB); err != nil {
 		return nil, err
 	}
+	if err := m.checkPlacementGroupsWithLock(req.PlacementGroups); err != nil {
+		return nil, err
+	}
 	var ipAddress string
 	if len(address.IpAddress) < 1 {
 		ipAddress = "0.0.0.0"
@@ -278,22 +298,28 @@ func (m *Manager) allocateVm(req proto.CreateVmRequest,
 	vm := &vmInfoType{
 		LocalVmInfo: proto.LocalVmInfo{
 			VmInfo: proto.VmInfo{
-				Address:            address,
-				ConsoleType:        req.ConsoleType,
-				DestroyProtection:  req.DestroyProtection,
-				DisableVirtIO:      req.DisableVirtIO,
-				Hostname:           req.Hostname,
-				ImageName:          req.ImageName,
-				ImageURL:           req.ImageURL,
-				MemoryInMiB:        req.MemoryInMiB,
-				MilliCPUs:          req.MilliCPUs,
-				OwnerGroups:        req.OwnerGroups,
-				SpreadVolumes:      req.SpreadVolumes,
-				SecondaryAddresses: secondaryAddresses,
-				SecondarySubnetIDs: req.SecondarySubnetIDs,
-				State:              proto.StateStarting,
-				SubnetId:           subnetId,
-				Tags:               req.Tags,
+				Address:             address,
+				CloudInitDatasource: req.CloudInitDatasource,
+				ConsoleType:         req.ConsoleType,
+				DestroyProtection:   req.DestroyProtection,
+				DisableVirtIO:       req.DisableVirtIO,
+				FirewallPolicy:      req.FirewallPolicy,
+				Hostname:            req.Hostname,
+				ImageName:           req.ImageName,
+				ImageURL:            req.ImageURL,
+				MemoryBalloon:       !req.DisableVirtIO,
+				MemoryInMiB:         req.MemoryInMiB,
+				MilliCPUs:           req.MilliCPUs,
+				OwnerGroups:         req.OwnerGroups,
+				PlacementGroups:     req.PlacementGroups,
+				SpreadVolumes:       req.SpreadVolumes,
+				SecondaryAddresses:  secondaryAddresses,
+				SecondarySubnetIDs:  req.SecondarySubnetIDs,
+				SnapshotSchedule:    req.SnapshotSchedule,
+				SshPublicKeys:       req.SshPublicKeys,
+				State:               proto.StateStarting,
+				SubnetId:            subnetId,
+				Tags:                req.Tags,
 			},
 		},
 		manager:          m,
@@ -384,6 +410,63 @@ func (m *Manager) changeVmOwnerUsers(ipAddr net.IP,
 	return nil
 }
 
+func (m *Manager) changeVmSize(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	memoryInMiB uint64, milliCPUs uint) error {
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	if memoryInMiB < 1 {
+		memoryInMiB = vm.MemoryInMiB
+	}
+	if milliCPUs < 1 {
+		milliCPUs = vm.MilliCPUs
+	}
+	if memoryInMiB == vm.MemoryInMiB && milliCPUs == vm.MilliCPUs {
+		return nil
+	}
+	switch vm.State {
+	case proto.StateStopped:
+	case proto.StateRunning:
+		if getNumCPUs(milliCPUs) != getNumCPUs(vm.MilliCPUs) {
+			return errors.New(
+				"cannot change number of CPUs of running VM, stop VM first")
+		}
+	default:
+		return errors.New("VM is not running or stopped")
+	}
+	m.mutex.RLock()
+	if milliCPUs > vm.MilliCPUs {
+		err = m.checkSufficientCPUWithLock(milliCPUs - vm.MilliCPUs)
+	}
+	if err == nil && memoryInMiB > vm.MemoryInMiB {
+		increase := memoryInMiB - vm.MemoryInMiB
+		if vm.State == proto.StateRunning {
+			err = m.checkSufficientMemoryWithLock(increase)
+		} else if increase > m.getUnallocatedMemoryInMiBWithLock() {
+			err = errorInsufficientUnallocatedMemory
+		}
+	}
+	m.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	if vm.State == proto.StateRunning && memoryInMiB != vm.MemoryInMiB {
+		if !vm.MemoryBalloon {
+			return errors.New(
+				"VM has no memory balloon device, stop VM first")
+		}
+		if err := vm.setBalloonSize(memoryInMiB); err != nil {
+			return err
+		}
+	}
+	vm.MemoryInMiB = memoryInMiB
+	vm.MilliCPUs = milliCPUs
+	vm.writeAndSendInfo()
+	return nil
+}
+
 func (m *Manager) changeVmTags(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	tgs tags.Tags) error {
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
@@ -887,13 +970,16 @@ func (m *Manager) discardVmOldUserData(ipAddr net.IP,
 }
 
 func (m *Manager) discardVmSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation) error {
+	authInfo *srpc.AuthInformation, snapshotName string) error {
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
 	}
 	defer vm.mutex.Unlock()
-	return vm.discardSnapshot()
+	if vm.snapshotInProgress {
+		return errors.New("snapshot in progress")
+	}
+	return vm.discardSnapshot(snapshotName)
 }
 
 func (m *Manager) exportLocalVm(authInfo *srpc.AuthInformation,
@@ -954,6 +1040,20 @@ func (m *Manager) getImage(searchName string, imageTimeout time.Duration) (
 		doClose = false
 		return client, img, imageName, nil
 	}
+	if exists, err := imclient.CheckImage(client, searchName); err != nil {
+		return nil, nil, "", err
+	} else if !exists {
+		// Pin the image that an alias currently refers to.
+		alias, err := imclient.GetImageAlias(client, searchName)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if alias != nil {
+			m.Logger.Printf("alias: %s resolves to image: %s\n",
+				searchName, alias.ImageName)
+			searchName = alias.ImageName
+		}
+	}
 	img, err := imclient.GetImageWithTimeout(client, searchName, imageTimeout)
 	if err != nil {
 		return nil, nil, "", err
@@ -1126,6 +1226,71 @@ func (m *Manager) getVmVolume(conn *srpc.Conn) error {
 		vm.Volumes[request.VolumeIndex].Size)
 }
 
+func (m *Manager) growVmVolume(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	volumeIndex uint, size uint64) error {
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	if volumeIndex >= uint(len(vm.VolumeLocations)) ||
+		volumeIndex >= uint(len(vm.Volumes)) {
+		return errors.New("invalid volume index")
+	}
+	volume := &vm.Volumes[volumeIndex]
+	if size <= volume.Size {
+		return fmt.Errorf("volume size: %s is not smaller than: %s",
+			format.FormatBytes(volume.Size), format.FormatBytes(size))
+	}
+	switch vm.State {
+	case proto.StateStopped:
+	case proto.StateRunning:
+	default:
+		return errors.New("VM is not running or stopped")
+	}
+	filename := vm.VolumeLocations[volumeIndex].Filename
+	freeSpace, err := getFreeSpace(filepath.Dir(filename),
+		make(map[string]uint64))
+	if err != nil {
+		return err
+	}
+	if size-volume.Size >= freeSpace {
+		return fmt.Errorf("not enough free space to grow volume by %s",
+			format.FormatBytes(size-volume.Size))
+	}
+	if vm.State == proto.StateRunning {
+		if volume.Format == proto.VolumeFormatRaw {
+			if err := fsutil.Fallocate(filename, size); err != nil {
+				return err
+			}
+		}
+		devices, err := vm.getBlockDevices()
+		if err != nil {
+			return err
+		}
+		err = vm.qmpCommand("block_resize", map[string]interface{}{
+			"device": devices[volumeIndex],
+			"size":   size,
+		}, nil)
+		if err != nil {
+			return err
+		}
+	} else if volume.Format == proto.VolumeFormatRaw {
+		if err := setVolumeSize(filename, size); err != nil {
+			return err
+		}
+	} else {
+		cmd := exec.Command("qemu-img", "resize", "-f", volume.Format.String(),
+			filename, strconv.FormatUint(size, 10))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("error resizing volume: %s: %s", err, output)
+		}
+	}
+	volume.Size = size
+	vm.writeAndSendInfo()
+	return nil
+}
+
 func (m *Manager) importLocalVm(authInfo *srpc.AuthInformation,
 	request proto.ImportLocalVmRequest) error {
 	requestedIpAddrs := make(map[string]struct{},
@@ -1354,6 +1519,7 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 		logger:           prefixlogger.New(ipAddress+": ", m.Logger),
 		metadataChannels: make(map[chan<- string]struct{}),
 	}
+	vm.Snapshots = nil // Snapshots are not migrated.
 	vm.Uncommitted = true
 	defer func() { // Evaluate vm at return time, not defer time.
 		if vm == nil {
@@ -1390,7 +1556,37 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 			Filename:           filename,
 		})
 	}
-	if vmInfo.State == proto.StateStopped {
+	if vmInfo.State == proto.StateRunning && !request.Offline {
+		err = m.migrateVmLive(conn, hypervisor, vm, request)
+	} else {
+		err = m.migrateVmOffline(conn, hypervisor, vm, request)
+	}
+	if err != nil {
+		return err
+	}
+	if err := m.registerAddress(vm.Address); err != nil {
+		return err
+	}
+	for _, address := range vm.SecondaryAddresses {
+		if err := m.registerAddress(address); err != nil {
+			return err
+		}
+	}
+	vm.doNotWriteOrSend = false
+	vm.Uncommitted = false
+	vm.writeAndSendInfo()
+	err = hyperclient.DestroyVm(hypervisor, request.IpAddress, accessToken)
+	if err != nil {
+		m.Logger.Printf("error cleaning up old migrated VM: %s: %s\n",
+			ipAddress, err)
+	}
+	vm = nil // Cancel cleanup.
+	return nil
+}
+
+func (m *Manager) migrateVmOffline(conn *srpc.Conn, hypervisor *srpc.Client,
+	vm *vmInfoType, request proto.MigrateVmRequest) error {
+	if vm.State == proto.StateStopped {
 		err := hyperclient.PrepareVmForMigration(hypervisor, request.IpAddress,
 			request.AccessToken, true)
 		if err != nil {
@@ -1398,15 +1594,16 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 		}
 	}
 	// Begin copying over the volumes.
-	err = sendVmMigrationMessage(conn, "initial volume(s) copy")
+	err := sendVmMigrationMessage(conn, "initial volume(s) copy")
 	if err != nil {
 		return err
 	}
-	err = vm.migrateVmVolumes(hypervisor, vm.Address.IpAddress, accessToken)
+	err = vm.migrateVmVolumes(hypervisor, vm.Address.IpAddress,
+		request.AccessToken)
 	if err != nil {
 		return err
 	}
-	if vmInfo.State != proto.StateStopped {
+	if vm.State != proto.StateStopped {
 		err = sendVmMigrationMessage(conn, "stopping VM")
 		if err != nil {
 			return err
@@ -1425,14 +1622,15 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 		if err != nil {
 			return err
 		}
-		err = vm.migrateVmVolumes(hypervisor, vm.Address.IpAddress, accessToken)
+		err = vm.migrateVmVolumes(hypervisor, vm.Address.IpAddress,
+			request.AccessToken)
 		if err != nil {
 			return err
 		}
 	}
 	err = migratevmUserData(hypervisor,
 		filepath.Join(vm.dirname, "user-data.raw"),
-		request.IpAddress, accessToken)
+		request.IpAddress, request.AccessToken)
 	if err != nil {
 		return err
 	}
@@ -1441,7 +1639,7 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	}
 	vm.State = proto.StateStarting
 	m.mutex.Lock()
-	m.vms[ipAddress] = vm
+	m.vms[vm.ipAddress] = vm
 	m.mutex.Unlock()
 	dhcpTimedOut, err := vm.startManaging(request.DhcpTimeout, false)
 	if err != nil {
@@ -1450,36 +1648,11 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	if dhcpTimedOut {
 		return fmt.Errorf("DHCP timed out")
 	}
-	err = conn.Encode(proto.MigrateVmResponse{RequestCommit: true})
-	if err != nil {
+	if commit, err := requestVmMigrationCommit(conn); err != nil {
 		return err
-	}
-	if err := conn.Flush(); err != nil {
-		return err
-	}
-	var reply proto.MigrateVmResponseResponse
-	if err := conn.Decode(&reply); err != nil {
-		return err
-	}
-	if !reply.Commit {
+	} else if !commit {
 		return fmt.Errorf("VM migration abandoned")
 	}
-	if err := m.registerAddress(vm.Address); err != nil {
-		return err
-	}
-	for _, address := range vm.SecondaryAddresses {
-		if err := m.registerAddress(address); err != nil {
-			return err
-		}
-	}
-	vm.doNotWriteOrSend = false
-	vm.Uncommitted = false
-	vm.writeAndSendInfo()
-	err = hyperclient.DestroyVm(hypervisor, request.IpAddress, accessToken)
-	if err != nil {
-		m.Logger.Printf("error cleaning up old migrated VM: %s\n", ipAddress)
-	}
-	vm = nil // Cancel cleanup.
 	return nil
 }
 
@@ -1532,6 +1705,10 @@ func (m *Manager) migrateVmChecks(vmInfo proto.VmInfo) error {
 	if err := m.checkSufficientMemoryWithLock(vmInfo.MemoryInMiB); err != nil {
 		return err
 	}
+	err := m.checkPlacementGroupsWithLock(vmInfo.PlacementGroups)
+	if err != nil {
+		return err
+	}
 	if err := <-tryAllocateMemory(vmInfo.MemoryInMiB); err != nil {
 		return err
 	}
@@ -1647,6 +1824,23 @@ func migrateVmVolume(hypervisor *srpc.Client, filename string,
 	return &stats, err
 }
 
+// requestVmMigrationCommit will ask the client whether to commit the migrated
+// VM.
+func requestVmMigrationCommit(conn *srpc.Conn) (bool, error) {
+	err := conn.Encode(proto.MigrateVmResponse{RequestCommit: true})
+	if err != nil {
+		return false, err
+	}
+	if err := conn.Flush(); err != nil {
+		return false, err
+	}
+	var reply proto.MigrateVmResponseResponse
+	if err := conn.Decode(&reply); err != nil {
+		return false, err
+	}
+	return reply.Commit, nil
+}
+
 func (m *Manager) notifyVmMetadataRequest(ipAddr net.IP, path string) {
 	addr := ipAddr.String()
 	m.mutex.RLock()
@@ -2066,7 +2260,11 @@ func (m *Manager) replaceVmUserData(ipAddr net.IP, reader io.Reader,
 }
 
 func (m *Manager) restoreVmFromSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation, forceIfNotStopped bool) error {
+	authInfo *srpc.AuthInformation, forceIfNotStopped bool,
+	snapshotName string) error {
+	if err := checkSnapshotName(snapshotName); err != nil {
+		return err
+	}
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
@@ -2078,13 +2276,14 @@ func (m *Manager) restoreVmFromSnapshot(ipAddr net.IP,
 		}
 	}
 	for _, volume := range vm.VolumeLocations {
-		snapshotFilename := volume.Filename + ".snapshot"
+		snapshotFilename := getSnapshotFilename(volume.Filename, snapshotName)
 		if err := os.Rename(snapshotFilename, volume.Filename); err != nil {
 			if !os.IsNotExist(err) {
 				return err
 			}
 		}
 	}
+	vm.removeSnapshotFromList(snapshotName)
 	return nil
 }
 
@@ -2141,43 +2340,26 @@ func (m *Manager) sendVmInfo(ipAddress string, vm *proto.VmInfo) {
 }
 
 func (m *Manager) snapshotVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
-	forceIfNotStopped, snapshotRootOnly bool) error {
+	forceIfNotStopped, snapshotRootOnly bool, snapshotName string) error {
+	if err := checkSnapshotName(snapshotName); err != nil {
+		return err
+	}
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
 	}
 	defer vm.mutex.Unlock()
-	// TODO(rgooch): First check for sufficient free space.
 	if vm.State != proto.StateStopped {
 		if !forceIfNotStopped {
 			return errors.New("VM is not stopped")
 		}
 	}
-	if err := vm.discardSnapshot(); err != nil {
-		return err
-	}
-	doCleanup := true
-	defer func() {
-		if doCleanup {
-			vm.discardSnapshot()
-		}
-	}()
-	for index, volume := range vm.VolumeLocations {
-		snapshotFilename := volume.Filename + ".snapshot"
-		if index == 0 || !snapshotRootOnly {
-			err := fsutil.CopyFile(snapshotFilename, volume.Filename,
-				privateFilePerms)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	doCleanup = false
-	return nil
+	return vm.snapshot(snapshotRootOnly, snapshotName)
 }
 
 func (m *Manager) startVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
-	accessToken []byte, dhcpTimeout time.Duration) (bool, error) {
+	accessToken []byte, dhcpTimeout time.Duration,
+	enableMemoryBalloon bool) (bool, error) {
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, accessToken)
 	if err != nil {
 		return false, err
@@ -2199,6 +2381,14 @@ func (m *Manager) startVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	case proto.StateStopping:
 		return false, errors.New("VM is stopping")
 	case proto.StateStopped, proto.StateFailedToStart, proto.StateExporting:
+		if enableMemoryBalloon && !vm.MemoryBalloon {
+			if vm.DisableVirtIO {
+				return false, errors.New(
+					"cannot add memory balloon to VM without VirtIO")
+			}
+			vm.MemoryBalloon = true
+			vm.logger.Println("memory balloon device enabled")
+		}
 		vm.setState(proto.StateStarting)
 		vm.mutex.Unlock()
 		doUnlock = false
@@ -2409,6 +2599,7 @@ func (vm *vmInfoType) copyRootVolume(request proto.CreateVmRequest,
 }
 
 func (vm *vmInfoType) delete() {
+	vm.removeFirewall()
 	select {
 	case vm.accessTokenCleanupNotifier <- struct{}{}:
 	default:
@@ -2462,14 +2653,19 @@ func (vm *vmInfoType) destroy() {
 	vm.delete()
 }
 
-func (vm *vmInfoType) discardSnapshot() error {
+func (vm *vmInfoType) discardSnapshot(snapshotName string) error {
+	if err := checkSnapshotName(snapshotName); err != nil {
+		return err
+	}
 	for _, volume := range vm.VolumeLocations {
-		if err := os.Remove(volume.Filename + ".snapshot"); err != nil {
+		err := os.Remove(getSnapshotFilename(volume.Filename, snapshotName))
+		if err != nil {
 			if !os.IsNotExist(err) {
 				return err
 			}
 		}
 	}
+	vm.removeSnapshotFromList(snapshotName)
 	return nil
 }
 
@@ -2514,7 +2710,9 @@ func (vm *vmInfoType) monitor(monitorSock net.Conn,
 	go vm.probeHealthAgent(cancelChannel)
 	go vm.serialManager()
 	for command := range commandChannel {
+		vm.monitorLock.Lock()
 		_, err := fmt.Fprintf(monitorSock, `{"execute":"%s"}`, command)
+		vm.monitorLock.Unlock()
 		if err != nil {
 			vm.logger.Println(err)
 		} else {
@@ -2550,7 +2748,7 @@ func (vm *vmInfoType) probeHealthAgent(cancel <-chan struct{}) {
 }
 
 func (vm *vmInfoType) processMonitorResponses(monitorSock net.Conn) {
-	io.Copy(ioutil.Discard, monitorSock) // Read all and drop.
+	vm.readMonitorResponses(monitorSock)
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
 	close(vm.commandChannel)
@@ -2694,10 +2892,7 @@ func (vm *vmInfoType) startManaging(dhcpTimeout time.Duration,
 	case proto.StateStopping:
 		monitorSock, err := net.Dial("unix", vm.monitorSockname)
 		if err == nil {
-			commandChannel := make(chan string, 1)
-			vm.commandChannel = commandChannel
-			go vm.monitor(monitorSock, commandChannel)
-			commandChannel <- "qmp_capabilities"
+			vm.startMonitor(monitorSock)
 			vm.kill()
 		}
 		return false, nil
@@ -2712,6 +2907,9 @@ func (vm *vmInfoType) startManaging(dhcpTimeout time.Duration,
 		vm.logger.Println("unknown state: " + vm.State.String())
 		return false, nil
 	}
+	if vm.updateIpv6Addresses(haveManagerLock) {
+		vm.writeAndSendInfo()
+	}
 	vm.manager.DhcpServer.AddLease(vm.Address, vm.Hostname)
 	for _, address := range vm.SecondaryAddresses {
 		vm.manager.DhcpServer.AddLease(address, vm.Hostname)
@@ -2726,16 +2924,15 @@ func (vm *vmInfoType) startManaging(dhcpTimeout time.Duration,
 			return false, err
 		}
 		monitorSock, err = net.Dial("unix", vm.monitorSockname)
+	} else if err := vm.updateFirewall(haveManagerLock); err != nil {
+		vm.logger.Println(err)
 	}
 	if err != nil {
 		vm.logger.Println(err)
 		vm.setState(proto.StateFailedToStart)
 		return false, err
 	}
-	commandChannel := make(chan string, 1)
-	vm.commandChannel = commandChannel
-	go vm.monitor(monitorSock, commandChannel)
-	commandChannel <- "qmp_capabilities"
+	vm.startMonitor(monitorSock)
 	vm.setState(proto.StateRunning)
 	if len(vm.Address.IpAddress) < 1 {
 		// Must wait to see what IP address is given by external DHCP server.
@@ -2766,20 +2963,33 @@ func (vm *vmInfoType) startManaging(dhcpTimeout time.Duration,
 	return false, nil
 }
 
+// getInterfaceAddresses returns the addresses of the network interfaces of the
+// VM, starting with the primary interface.
+func (vm *vmInfoType) getInterfaceAddresses() []proto.Address {
+	addresses := make([]proto.Address, 1, len(vm.SecondarySubnetIDs)+1)
+	addresses[0] = vm.Address
+	for index := range vm.SecondarySubnetIDs {
+		addresses = append(addresses, vm.SecondaryAddresses[index])
+	}
+	return addresses
+}
+
+// getInterfaceSubnetIDs returns the subnet IDs of the network interfaces of
+// the VM, starting with the primary interface.
+func (vm *vmInfoType) getInterfaceSubnetIDs() []string {
+	subnetIDs := make([]string, 1, len(vm.SecondarySubnetIDs)+1)
+	subnetIDs[0] = vm.SubnetId
+	return append(subnetIDs, vm.SecondarySubnetIDs...)
+}
+
 func (vm *vmInfoType) getBridgesAndOptions(haveManagerLock bool) (
 	[]string, []string, error) {
 	if !haveManagerLock {
 		vm.manager.mutex.RLock()
 		defer vm.manager.mutex.RUnlock()
 	}
-	addresses := make([]proto.Address, 1, len(vm.SecondarySubnetIDs)+1)
-	addresses[0] = vm.Address
-	subnetIDs := make([]string, 1, len(vm.SecondarySubnetIDs)+1)
-	subnetIDs[0] = vm.SubnetId
-	for index, subnetId := range vm.SecondarySubnetIDs {
-		addresses = append(addresses, vm.SecondaryAddresses[index])
-		subnetIDs = append(subnetIDs, subnetId)
-	}
+	addresses := vm.getInterfaceAddresses()
+	subnetIDs := vm.getInterfaceSubnetIDs()
 	var bridges, options []string
 	deviceDriver := "virtio-net-pci"
 	if vm.DisableVirtIO {
@@ -2815,20 +3025,19 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 	if err := checkAvailableMemory(vm.MemoryInMiB); err != nil {
 		return err
 	}
-	nCpus := vm.MilliCPUs / 1000
-	if nCpus < 1 {
-		nCpus = 1
-	}
-	if nCpus*1000 < vm.MilliCPUs {
-		nCpus++
-	}
+	nCpus := getNumCPUs(vm.MilliCPUs)
 	bridges, netOptions, err := vm.getBridgesAndOptions(haveManagerLock)
 	if err != nil {
 		return err
 	}
+	if err := vm.updateFirewall(haveManagerLock); err != nil {
+		return err
+	}
+	addresses := vm.getInterfaceAddresses()
 	var tapFiles []*os.File
-	for _, bridge := range bridges {
-		tapFile, err := createTapDevice(bridge)
+	for index, bridge := range bridges {
+		tapFile, err := createTapDevice(bridge,
+			getTapDeviceName(addresses[index].MacAddress))
 		if err != nil {
 			return fmt.Errorf("error creating tap device: %s", err)
 		}
@@ -2847,6 +3056,10 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 		"-runas", vm.manager.Username,
 		"-qmp", "unix:"+vm.monitorSockname+",server,nowait",
 		"-daemonize")
+	if vm.incomingMigration {
+		// Wait for the migration stream and do not run until told to.
+		cmd.Args = append(cmd.Args, "-incoming", "defer", "-S")
+	}
 	if kernelPath := vm.getActiveKernelPath(); kernelPath != "" {
 		cmd.Args = append(cmd.Args, "-kernel", kernelPath)
 		if initrdPath := vm.getActiveInitrdPath(); initrdPath != "" {
@@ -2889,6 +3102,18 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 			"-drive", "file="+volume.Filename+",format="+volumeFormat.String()+
 				interfaceDriver)
 	}
+	if vm.CloudInitDatasource != proto.CloudInitDatasourceNone {
+		filename, err := vm.writeCloudInitSeed(haveManagerLock)
+		if err != nil {
+			return fmt.Errorf("error writing cloud-init seed: %s", err)
+		}
+		cmd.Args = append(cmd.Args,
+			"-drive", "file="+filename+",format=raw,media=cdrom,readonly")
+	}
+	if vm.MemoryBalloon && !vm.DisableVirtIO {
+		// Last, so that existing devices keep their PCI slots.
+		cmd.Args = append(cmd.Args, "-device", "virtio-balloon-pci")
+	}
 	os.Remove(filepath.Join(vm.dirname, "bootlog"))
 	cmd.ExtraFiles = tapFiles // Start at fd=3 for QEMU.
 	if output, err := cmd.CombinedOutput(); err != nil {
@@ -34,11 +34,21 @@ func StartServer(hypervisorPortNum uint, bridges []net.Interface,
 	s.infoHandlers = map[string]metadataWriter{
 		"/latest/dynamic/epoch-time":                 s.showTime,
 		"/latest/dynamic/instance-identity/document": s.showVM,
+		"/latest/meta-data/hostname":                 s.showHostname,
+		"/latest/meta-data/instance-id":              s.showInstanceId,
+		"/latest/meta-data/local-hostname":           s.showHostname,
+		"/latest/meta-data/local-ipv4":               s.showLocalIpv4,
+		"/latest/meta-data/mac":                      s.showMac,
+		"/openstack/latest/meta_data.json":           s.showMetaData,
+		"/openstack/latest/network_data.json":        s.showNetworkData,
 	}
 	s.rawHandlers = map[string]rawHandlerFunc{
-		"/datasource/SmallStack": s.showSmallStack,
-		"/latest/user-data":      s.showUserData,
+		"/datasource/SmallStack":      s.showSmallStack,
+		"/latest/user-data":           s.showUserData,
+		"/openstack/latest/user_data": s.showUserData,
 	}
+	s.rawHandlers["/latest/dynamic/instance-identity/signed-document"] =
+		s.showSignedIdentity
 	s.computePaths()
 	return s.startServer()
 }
@@ -2,14 +2,17 @@ package metadatad
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Symantec/Dominator/hypervisor/cloudinit"
 	"github.com/Symantec/Dominator/lib/json"
 	proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
@@ -42,9 +45,14 @@ func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		rawHandler(w, ipAddr)
 		return
 	}
+	vmHandlers := s.makeVmHandlers(vmInfo)
 	writer := bufio.NewWriter(w)
 	defer writer.Flush()
-	if infoHandler, ok := s.infoHandlers[req.URL.Path]; ok {
+	infoHandler, ok := s.infoHandlers[req.URL.Path]
+	if !ok {
+		infoHandler, ok = vmHandlers[req.URL.Path]
+	}
+	if ok {
 		if err := infoHandler(writer, vmInfo); err != nil {
 			fmt.Fprintln(writer, err)
 		}
@@ -52,7 +60,7 @@ func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 	paths := make([]string, 0)
 	pathsSet := make(map[string]struct{})
-	for path := range s.paths {
+	addPath := func(path string) {
 		if strings.HasPrefix(path, req.URL.Path) {
 			splitPath := strings.Split(path[len(req.URL.Path):], "/")
 			result := splitPath[0]
@@ -65,6 +73,12 @@ func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			}
 		}
 	}
+	for path := range s.paths {
+		addPath(path)
+	}
+	for path := range vmHandlers {
+		addPath(path)
+	}
 	if len(paths) < 1 {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusNotFound)
@@ -76,6 +90,95 @@ func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// makeVmHandlers returns the handlers for the metadata paths which depend on
+// the VM: the SSH public keys, the tags and the network interfaces.
+func (s *server) makeVmHandlers(
+	vmInfo proto.VmInfo) map[string]metadataWriter {
+	handlers := make(map[string]metadataWriter)
+	for index, key := range vmInfo.SshPublicKeys {
+		path := fmt.Sprintf("/latest/meta-data/public-keys/%d/openssh-key",
+			index)
+		handlers[path] = makeStringWriter(key)
+	}
+	for key, value := range vmInfo.Tags {
+		if key != "" && !strings.ContainsRune(key, '/') {
+			handlers["/latest/meta-data/tags/instance/"+key] =
+				makeStringWriter(value)
+		}
+	}
+	addresses := append([]proto.Address{vmInfo.Address},
+		vmInfo.SecondaryAddresses...)
+	subnetIDs := append([]string{vmInfo.SubnetId},
+		vmInfo.SecondarySubnetIDs...)
+	for index, address := range addresses {
+		dirname := "/latest/meta-data/network/interfaces/macs/" +
+			address.MacAddress + "/"
+		handlers[dirname+"device-number"] =
+			makeStringWriter(strconv.Itoa(index))
+		if len(address.IpAddress) > 0 {
+			handlers[dirname+"local-ipv4s"] =
+				makeStringWriter(address.IpAddress.String())
+		}
+		if len(address.Ipv6Address) > 0 {
+			handlers[dirname+"ipv6s"] =
+				makeStringWriter(address.Ipv6Address.String())
+		}
+		handlers[dirname+"mac"] = makeStringWriter(address.MacAddress)
+		if index < len(subnetIDs) {
+			handlers[dirname+"subnet-id"] = makeStringWriter(subnetIDs[index])
+		}
+	}
+	return handlers
+}
+
+func makeStringWriter(value string) metadataWriter {
+	return func(writer io.Writer, vmInfo proto.VmInfo) error {
+		_, err := fmt.Fprintln(writer, value)
+		return err
+	}
+}
+
+func (s *server) showHostname(writer io.Writer, vmInfo proto.VmInfo) error {
+	_, err := fmt.Fprintln(writer, cloudinit.Hostname(vmInfo))
+	return err
+}
+
+func (s *server) showInstanceId(writer io.Writer, vmInfo proto.VmInfo) error {
+	_, err := fmt.Fprintln(writer, cloudinit.InstanceId(vmInfo))
+	return err
+}
+
+func (s *server) showLocalIpv4(writer io.Writer, vmInfo proto.VmInfo) error {
+	if len(vmInfo.Address.IpAddress) < 1 {
+		return errors.New("no IP address")
+	}
+	_, err := fmt.Fprintln(writer, vmInfo.Address.IpAddress)
+	return err
+}
+
+func (s *server) showMac(writer io.Writer, vmInfo proto.VmInfo) error {
+	_, err := fmt.Fprintln(writer, vmInfo.Address.MacAddress)
+	return err
+}
+
+func (s *server) showMetaData(writer io.Writer, vmInfo proto.VmInfo) error {
+	return json.WriteWithIndent(writer, "    ",
+		cloudinit.MakeMetaData(vmInfo))
+}
+
+func (s *server) showNetworkData(writer io.Writer,
+	vmInfo proto.VmInfo) error {
+	subnets := make(map[string]proto.Subnet)
+	for _, subnet := range s.manager.ListSubnets(false) {
+		subnets[subnet.Id] = subnet
+	}
+	networkData, err := cloudinit.MakeNetworkData(vmInfo, subnets)
+	if err != nil {
+		return err
+	}
+	return json.WriteWithIndent(writer, "    ", networkData)
+}
+
 func (s *server) showTime(writer io.Writer, vmInfo proto.VmInfo) error {
 	now := time.Now()
 	nano := now.UnixNano() - now.Unix()*1000000000
@@ -87,6 +190,19 @@ func (s *server) showVM(writer io.Writer, vmInfo proto.VmInfo) error {
 	return json.WriteWithIndent(writer, "    ", vmInfo)
 }
 
+func (s *server) showSignedIdentity(w http.ResponseWriter, ipAddr net.IP) {
+	signed, err := s.manager.GetSignedVmIdentity(ipAddr)
+	if err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintln(w, err)
+		return
+	}
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	json.WriteWithIndent(writer, "    ", signed)
+}
+
 func (s *server) showSmallStack(w http.ResponseWriter, ipAddr net.IP) {
 	w.Write([]byte("true\n"))
 }
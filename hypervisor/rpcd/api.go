@@ -51,13 +51,18 @@ func Setup(manager *manager.Manager, dhcpServer DhcpServer,
 	srpc.RegisterNameWithOptions("Hypervisor", srpcObj, srpc.ReceiverOptions{
 		PublicMethods: []string{
 			"AcknowledgeVm",
+			"BackupVm",
 			"BecomePrimaryVmOwner",
 			"ChangeVmConsoleType",
 			"ChangeVmDestroyProtection",
+			"ChangeVmFirewallPolicy",
 			"ChangeVmOwnerUsers",
+			"ChangeVmSize",
+			"ChangeVmSnapshotSchedule",
 			"ChangeVmTags",
 			"CommitImportedVm",
 			"ConnectToVmConsole",
+			"ConnectToVmMigrationStream",
 			"ConnectToVmSerialPort",
 			"CopyVm",
 			"CreateVm",
@@ -70,9 +75,11 @@ func Setup(manager *manager.Manager, dhcpServer DhcpServer,
 			"ExportLocalVm",
 			"GetUpdates",
 			"GetVmAccessToken",
+			"GetVmFirewallRuleset",
 			"GetVmInfo",
 			"GetVmUserData",
 			"GetVmVolume",
+			"GrowVmVolume",
 			"ImportLocalVm",
 			"ListVMs",
 			"ListVolumeDirectories",
@@ -81,9 +88,11 @@ func Setup(manager *manager.Manager, dhcpServer DhcpServer,
 			"ProbeVmPort",
 			"ReplaceVmImage",
 			"ReplaceVmUserData",
+			"RestoreVmFromBackup",
 			"RestoreVmFromSnapshot",
 			"RestoreVmImage",
 			"RestoreVmUserData",
+			"SendVmLiveMigration",
 			"SnapshotVm",
 			"StartVm",
 			"StopVm",
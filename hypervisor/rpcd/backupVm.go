@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) BackupVm(conn *srpc.Conn,
+	request hypervisor.BackupVmRequest,
+	reply *hypervisor.BackupVmResponse) error {
+	hashVal, err := t.manager.BackupVm(conn.GetAuthInformation(), request)
+	*reply = hypervisor.BackupVmResponse{
+		Error: errors.ErrorToString(err),
+		Hash:  hashVal,
+	}
+	return nil
+}
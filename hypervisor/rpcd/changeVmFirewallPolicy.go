@@ -0,0 +1,19 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ChangeVmFirewallPolicy(conn *srpc.Conn,
+	request hypervisor.ChangeVmFirewallPolicyRequest,
+	reply *hypervisor.ChangeVmFirewallPolicyResponse) error {
+	ruleset, err := t.manager.ChangeVmFirewallPolicy(request.IpAddress,
+		conn.GetAuthInformation(), request.FirewallPolicy, request.DryRun)
+	*reply = hypervisor.ChangeVmFirewallPolicyResponse{
+		Error:   errors.ErrorToString(err),
+		Ruleset: ruleset,
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ChangeVmSnapshotSchedule(conn *srpc.Conn,
+	request hypervisor.ChangeVmSnapshotScheduleRequest,
+	reply *hypervisor.ChangeVmSnapshotScheduleResponse) error {
+	response := hypervisor.ChangeVmSnapshotScheduleResponse{
+		errors.ErrorToString(t.manager.ChangeVmSnapshotSchedule(
+			request.IpAddress, conn.GetAuthInformation(),
+			request.SnapshotSchedule))}
+	*reply = response
+	return nil
+}
@@ -0,0 +1,19 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) GetVmFirewallRuleset(conn *srpc.Conn,
+	request hypervisor.GetVmFirewallRulesetRequest,
+	reply *hypervisor.GetVmFirewallRulesetResponse) error {
+	ruleset, err := t.manager.GetVmFirewallRuleset(request.IpAddress)
+	response := hypervisor.GetVmFirewallRulesetResponse{
+		Error:   errors.ErrorToString(err),
+		Ruleset: ruleset,
+	}
+	*reply = response
+	return nil
+}
@@ -0,0 +1,14 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) SendVmLiveMigration(conn *srpc.Conn) error {
+	if err := t.manager.SendVmLiveMigration(conn); err != nil {
+		return conn.Encode(
+			hypervisor.SendVmLiveMigrationResponse{Error: err.Error()})
+	}
+	return conn.Encode(hypervisor.SendVmLiveMigrationResponse{Final: true})
+}
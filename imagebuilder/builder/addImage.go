@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
 	"sort"
+	"syscall"
 	"time"
 
 	imageclient "github.com/Symantec/Dominator/imageserver/client"
@@ -19,13 +21,18 @@ import (
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/json"
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/triggers"
 	proto "github.com/Symantec/Dominator/proto/imaginator"
 )
 
-const timeFormat = "2006-01-02:15:04:05"
+const (
+	defaultTestTimeout = time.Second * 10
+	testConfigFilename = "tests.json"
+	timeFormat         = "2006-01-02:15:04:05"
+)
 
 var errorTestTimedOut = errors.New("test timed out")
 
@@ -34,6 +41,7 @@ type hasher struct {
 }
 
 type testResultType struct {
+	advisory bool
 	buffer   chan byte
 	duration time.Duration
 	err      error
@@ -150,7 +158,8 @@ func listPackages(rootDir string) ([]image.Package, error) {
 func packImage(client *srpc.Client, request proto.BuildImageRequest,
 	dirname string, scanFilter *filter.Filter,
 	computedFilesList []util.ComputedFile, imageFilter *filter.Filter,
-	trig *triggers.Triggers, buildLog buildLogger) (*image.Image, error) {
+	trig *triggers.Triggers, vmTests *vmTestsType, buildLog buildLogger) (
+	*image.Image, error) {
 	packages, err := listPackages(dirname)
 	if err != nil {
 		return nil, fmt.Errorf("error listing packages: %s", err)
@@ -179,10 +188,34 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 		fmt.Fprintf(buildLog, "Copied mtimes in %s\n",
 			format.Duration(time.Since(patchStartTime)))
 	}
-	if err := runTests(dirname, buildLog); err != nil {
+	testResults, err := runTests(dirname, buildLog)
+	if err != nil {
 		return nil, err
 	}
+	if vmTests != nil {
+		vmTestResults, err := vmTests.run(client, fs, request.StreamName,
+			buildLog)
+		if err != nil {
+			return nil, err
+		}
+		testResults = append(testResults, vmTestResults...)
+	}
 	objClient := objectclient.AttachObjectClient(client)
+	var testResultsAnnotation *image.Annotation
+	if len(testResults) > 0 {
+		buffer := &bytes.Buffer{}
+		err := json.WriteWithIndent(buffer, "    ",
+			proto.TestResults{Tests: testResults})
+		if err != nil {
+			return nil, err
+		}
+		hashVal, _, err := objClient.AddObject(buffer, uint64(buffer.Len()),
+			nil)
+		if err != nil {
+			return nil, err
+		}
+		testResultsAnnotation = &image.Annotation{Object: &hashVal}
+	}
 	// Make a copy of the build log because AddObject() drains the buffer.
 	logReader := bytes.NewBuffer(buildLog.Bytes())
 	hashVal, _, err := objClient.AddObject(logReader, uint64(logReader.Len()),
@@ -194,11 +227,12 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 		return nil, err
 	}
 	img := &image.Image{
-		BuildLog:   &image.Annotation{Object: &hashVal},
-		FileSystem: fs,
-		Filter:     imageFilter,
-		Triggers:   trig,
-		Packages:   packages,
+		BuildLog:    &image.Annotation{Object: &hashVal},
+		FileSystem:  fs,
+		Filter:      imageFilter,
+		Triggers:    trig,
+		TestResults: testResultsAnnotation,
+		Packages:    packages,
 	}
 	if err := img.Verify(); err != nil {
 		return nil, err
@@ -206,67 +240,158 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 	return img, nil
 }
 
-func runTests(rootDir string, buildLog buildLogger) error {
+// findTests will walk the testsDir tree and return the pathnames of all the
+// test programmes found and the test configurations, keyed by directory.
+func findTests(testsDir string) ([]string, map[string]*testConfigType,
+	error) {
 	var testProgrammes []string
-	err := filepath.Walk(filepath.Join(rootDir, "tests"),
+	testConfigs := make(map[string]*testConfigType)
+	err := filepath.Walk(testsDir,
 		func(path string, fi os.FileInfo, err error) error {
-			if fi == nil || !fi.Mode().IsRegular() || fi.Mode()&0100 == 0 {
+			if fi == nil || !fi.Mode().IsRegular() {
 				return nil
 			}
-			testProgrammes = append(testProgrammes, path[len(rootDir):])
+			if fi.Name() == testConfigFilename {
+				var testConfig testConfigType
+				if err := json.ReadFromFile(path, &testConfig); err != nil {
+					return fmt.Errorf("error reading: %s: %s", path, err)
+				}
+				testConfigs[filepath.Dir(path)] = &testConfig
+				return nil
+			}
+			if fi.Mode()&0100 == 0 {
+				return nil
+			}
+			testProgrammes = append(testProgrammes, path)
 			return nil
 		})
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	return testProgrammes, testConfigs, nil
+}
+
+// getTestConfig will return the configuration for the test programme prog,
+// using the nearest test configuration file at or above its directory.
+func getTestConfig(testConfigs map[string]*testConfigType, testsDir string,
+	prog string) testProgrammeConfigType {
+	for dir := filepath.Dir(prog); len(dir) >= len(testsDir); {
+		if testConfig, ok := testConfigs[dir]; ok {
+			progConfig := testConfig.Tests[prog[len(dir)+1:]]
+			if progConfig.Timeout < 1 {
+				progConfig.Timeout = testConfig.DefaultTimeout
+			}
+			return progConfig
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		} else {
+			dir = parent
+		}
+	}
+	return testProgrammeConfigType{}
+}
+
+func runTests(rootDir string, buildLog buildLogger) (
+	[]proto.TestResult, error) {
+	testsDir := filepath.Join(rootDir, "tests")
+	testProgrammes, testConfigs, err := findTests(testsDir)
+	if err != nil {
+		return nil, err
 	}
 	if len(testProgrammes) < 1 {
-		return nil
+		return nil, nil
 	}
 	fmt.Fprintf(buildLog, "Running %d tests\n", len(testProgrammes))
 	results := make(chan testResultType, 1)
 	for _, prog := range testProgrammes {
 		go func(prog string) {
-			results <- runTest(rootDir, prog)
+			results <- runTest(prog[len(rootDir):],
+				getTestConfig(testConfigs, testsDir, prog),
+				func(writer io.Writer) *exec.Cmd {
+					return makeTargetCommand(nil, writer, rootDir,
+						packagerPathname, "run", prog[len(rootDir):])
+				})
 		}(prog)
 	}
+	return collectTestResults(results, len(testProgrammes), false, buildLog)
+}
+
+func collectTestResults(results <-chan testResultType, numTests int, vm bool,
+	buildLog buildLogger) ([]proto.TestResult, error) {
+	testResults := make([]proto.TestResult, 0, numTests)
 	numFailures := 0
-	for range testProgrammes {
+	for count := 0; count < numTests; count++ {
 		result := <-results
-		io.Copy(buildLog, &result)
+		output := &bytes.Buffer{}
+		io.Copy(io.MultiWriter(buildLog, output), &result)
+		testResult := proto.TestResult{
+			Advisory: result.advisory,
+			Duration: result.duration,
+			Name:     result.prog,
+			Output:   output.String(),
+			TimedOut: result.err == errorTestTimedOut,
+			VM:       vm,
+		}
 		if result.err != nil {
-			fmt.Fprintf(buildLog, "error running: %s: %s\n",
-				result.prog, result.err)
-			numFailures++
+			testResult.Error = result.err.Error()
+			if result.advisory {
+				fmt.Fprintf(buildLog, "error running advisory test: %s: %s\n",
+					result.prog, result.err)
+			} else {
+				fmt.Fprintf(buildLog, "error running: %s: %s\n",
+					result.prog, result.err)
+				numFailures++
+			}
 		} else {
 			fmt.Fprintf(buildLog, "%s passed in %s\n",
 				result.prog, format.Duration(result.duration))
 		}
 		fmt.Fprintln(buildLog)
+		testResults = append(testResults, testResult)
 	}
+	sort.Slice(testResults, func(left, right int) bool {
+		return testResults[left].Name < testResults[right].Name
+	})
 	if numFailures > 0 {
-		return fmt.Errorf("%d tests failed", numFailures)
+		return testResults, fmt.Errorf("%d tests failed", numFailures)
 	}
-	return nil
+	return testResults, nil
 }
 
-func runTest(rootDir, prog string) testResultType {
+// runTest will run the command returned by makeCmd. The command must run in
+// its own process group, which is killed if the test times out.
+func runTest(prog string, config testProgrammeConfigType,
+	makeCmd func(writer io.Writer) *exec.Cmd) testResultType {
 	startTime := time.Now()
 	result := testResultType{
-		buffer: make(chan byte, 4096),
-		prog:   prog,
+		advisory: config.Advisory,
+		buffer:   make(chan byte, 4096),
+		prog:     prog,
+	}
+	timeout := defaultTestTimeout
+	if config.Timeout > 0 {
+		timeout = time.Second * time.Duration(config.Timeout)
+	}
+	cmd := makeCmd(&result)
+	if err := cmd.Start(); err != nil {
+		result.err = err
+		return result
 	}
 	errChannel := make(chan error, 1)
-	timer := time.NewTimer(time.Second * 10)
+	timer := time.NewTimer(timeout)
 	go func() {
-		errChannel <- runInTarget(nil, &result, rootDir, packagerPathname,
-			"run", prog)
+		errChannel <- cmd.Wait()
 	}()
 	select {
 	case result.err = <-errChannel:
-		result.duration = time.Since(startTime)
+		timer.Stop()
 	case <-timer.C:
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-errChannel
 		result.err = errorTestTimedOut
 	}
+	result.duration = time.Since(startTime)
 	return result
 }
 
@@ -0,0 +1,51 @@
+package builder
+
+import (
+	"io"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunTest(t *testing.T) {
+	var tests = []struct {
+		name     string
+		script   string
+		wantErr  bool
+		timedOut bool
+	}{
+		{"pass", "echo hello", false, false},
+		{"fail", "exit 1", true, false},
+		{"timeout", "sleep 60", true, true},
+		{"timeout with child", "sleep 60 & sleep 60", true, true},
+	}
+	for _, test := range tests {
+		var cmd *exec.Cmd
+		startTime := time.Now()
+		result := runTest(test.name, testProgrammeConfigType{Timeout: 1},
+			func(writer io.Writer) *exec.Cmd {
+				cmd = exec.Command("/bin/sh", "-c", test.script)
+				cmd.Stdout = writer
+				cmd.Stderr = writer
+				cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+				return cmd
+			})
+		if (result.err != nil) != test.wantErr {
+			t.Errorf("%s: error: %v, want error: %t",
+				test.name, result.err, test.wantErr)
+		}
+		if timedOut := result.err == errorTestTimedOut; timedOut !=
+			test.timedOut {
+			t.Errorf("%s: timed out: %t, want %t",
+				test.name, timedOut, test.timedOut)
+		}
+		// The test process must have been killed and waited for.
+		if cmd.ProcessState == nil {
+			t.Errorf("%s: test process not waited for", test.name)
+		}
+		if duration := time.Since(startTime); duration > time.Second*10 {
+			t.Errorf("%s: took %s", test.name, duration)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/Symantec/Dominator/lib/filter"
 	"github.com/Symantec/Dominator/lib/image"
 	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/slavedriver"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/triggers"
@@ -33,6 +34,11 @@ type bootstrapStream struct {
 	PackagerType string
 }
 
+type buildLogBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
 type buildResultType struct {
 	imageName  string
 	startTime  time.Time
@@ -41,13 +47,24 @@ type buildResultType struct {
 	error      error
 }
 
+type buildQueueConfigurationType struct {
+	MaximumConcurrentBuilds         uint            `json:",omitempty"`
+	MaximumConcurrentBuildsPerGroup map[string]uint `json:",omitempty"`
+	MaximumConcurrentBuildsPerUser  uint            `json:",omitempty"`
+	MaximumQueuedBuildsPerUser      uint            `json:",omitempty"`
+}
+
 type masterConfigurationType struct {
 	BindMounts                []string                    `json:",omitempty"`
 	BootstrapStreams          map[string]*bootstrapStream `json:",omitempty"`
+	BuildQueue                buildQueueConfigurationType `json:",omitempty"`
 	ImageStreamsCheckInterval uint                        `json:",omitempty"`
 	ImageStreamsToAutoRebuild []string                    `json:",omitempty"`
 	ImageStreamsUrl           string                      `json:",omitempty"`
+	MaximumBuildsPerSlave     uint                        `json:",omitempty"`
+	ObjectCacheBytes          uint64                      `json:",omitempty"`
 	PackagerTypes             map[string]packagerType     `json:",omitempty"`
+	TestVm                    *testVmConfigurationType    `json:",omitempty"`
 }
 
 type manifestConfigType struct {
@@ -63,13 +80,18 @@ type manifestType struct {
 type imageStreamType struct {
 	builder           *Builder
 	name              string
+	parameters        map[string]string // Matrix parameters for templates.
+	template          *streamTemplateType
+	templateName      string
 	BuilderGroups     []string
 	ManifestUrl       string
 	ManifestDirectory string
+	OciImageUrl       string
 }
 
 type imageStreamsConfigurationType struct {
-	Streams map[string]*imageStreamType `json:",omitempty"`
+	Streams         map[string]*imageStreamType    `json:",omitempty"`
+	StreamTemplates map[string]*streamTemplateType `json:",omitempty"`
 }
 
 type argList []string
@@ -89,11 +111,53 @@ type packagerType struct {
 	Verbatim       []string
 }
 
+type slaveStateType struct {
+	numBuilds uint
+	images    map[string]struct{} // Source images and streams built on slave.
+}
+
 type sourceImageInfoType struct {
 	filter   *filter.Filter
 	triggers *triggers.Triggers
 }
 
+type streamParameterType struct {
+	Default  string   `json:",omitempty"`
+	Required bool     `json:",omitempty"`
+	Type     string   `json:",omitempty"` // boolean, integer or string.
+	Values   []string `json:",omitempty"` // Matrix values.
+}
+
+type streamTemplateType struct {
+	AutoRebuild          bool                           `json:",omitempty"`
+	AutoRebuildInstances map[string]bool                `json:",omitempty"`
+	BuilderGroups        []string                       `json:",omitempty"`
+	ManifestUrl          string                         `json:",omitempty"`
+	ManifestDirectory    string                         `json:",omitempty"`
+	OciImageUrl          string                         `json:",omitempty"`
+	Parameters           map[string]streamParameterType `json:",omitempty"`
+}
+
+type testConfigType struct {
+	DefaultTimeout uint                               `json:",omitempty"`
+	Tests          map[string]testProgrammeConfigType `json:",omitempty"`
+}
+
+type testProgrammeConfigType struct {
+	Advisory bool `json:",omitempty"`
+	Timeout  uint `json:",omitempty"` // Seconds.
+}
+
+type testVmConfigurationType struct {
+	BootTimeout       uint   `json:",omitempty"` // Seconds.
+	HypervisorAddress string `json:",omitempty"`
+	MemoryInMiB       uint64 `json:",omitempty"`
+	MilliCPUs         uint   `json:",omitempty"`
+	MinimumFreeBytes  uint64 `json:",omitempty"`
+	RoundupPower      uint64 `json:",omitempty"`
+	SubnetId          string `json:",omitempty"`
+}
+
 type Builder struct {
 	bindMounts                []string
 	stateDir                  string
@@ -104,11 +168,19 @@ type Builder struct {
 	bootstrapStreams          map[string]*bootstrapStream
 	imageStreams              map[string]*imageStreamType
 	imageStreamsToAutoRebuild []string
+	templateStreamsToRebuild  []string
 	slaveDriver               *slavedriver.SlaveDriver
+	maxBuildsPerSlave         uint
+	objectCache               objectserver.ObjectsGetter
+	slaveStatesLock           sync.Mutex
+	slaveStates               map[string]*slaveStateType // Key: slave ID.
+	sourceImages              map[string]string          // Key: stream name.
+	buildQueue                *buildQueueType
 	buildResultsLock          sync.RWMutex
-	currentBuildLogs          map[string]*bytes.Buffer   // Key: stream name.
+	currentBuildLogs          map[string]*buildLogBuffer // Key: stream name.
 	lastBuildResults          map[string]buildResultType // Key: stream name.
 	packagerTypes             map[string]packagerType
+	testVm                    *testVmConfigurationType
 	variables                 map[string]string
 }
 
@@ -125,6 +197,11 @@ func (b *Builder) BuildImage(request proto.BuildImageRequest,
 	return b.buildImage(request, authInfo, logWriter)
 }
 
+func (b *Builder) CancelBuild(streamName string,
+	authInfo *srpc.AuthInformation) (uint, error) {
+	return b.cancelBuild(streamName, authInfo)
+}
+
 func (b *Builder) GetCurrentBuildLog(streamName string) ([]byte, error) {
 	return b.getCurrentBuildLog(streamName)
 }
@@ -171,7 +248,7 @@ func ProcessManifest(manifestDir, rootDir string, bindMounts []string,
 
 func UnpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
 	rootDir string, bindMounts []string, buildLog io.Writer) error {
-	_, err := unpackImageAndProcessManifest(client, manifestDir, rootDir,
+	_, err := unpackImageAndProcessManifest(client, nil, manifestDir, rootDir,
 		bindMounts, true, buildLog)
 	return err
 }
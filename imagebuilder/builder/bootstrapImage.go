@@ -106,7 +106,7 @@ func (stream *bootstrapStream) build(b *Builder, client *srpc.Client,
 			return nil, err
 		}
 		return packImage(client, request, rootDir,
-			stream.Filter, nil, &filter.Filter{}, nil, buildLog)
+			stream.Filter, nil, &filter.Filter{}, nil, nil, buildLog)
 	}
 }
 
@@ -181,6 +181,13 @@ func clearResolvConf(writer io.Writer, rootDir string) error {
 
 func runInTarget(input io.Reader, output io.Writer, rootDir, prog string,
 	args ...string) error {
+	return makeTargetCommand(input, output, rootDir, prog, args...).Run()
+}
+
+// makeTargetCommand returns a command which will run prog in the rootDir
+// chroot, in new mount and PID namespaces.
+func makeTargetCommand(input io.Reader, output io.Writer, rootDir, prog string,
+	args ...string) *exec.Cmd {
 	cmd := exec.Command(prog, args...)
 	cmd.Env = stripVariables(os.Environ(), environmentToCopy)
 	cmd.Dir = "/"
@@ -192,7 +199,7 @@ func runInTarget(input io.Reader, output io.Writer, rootDir, prog string,
 		Setsid:     true,
 		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID,
 	}
-	return cmd.Run()
+	return cmd
 }
 
 func runInTargetWithBindMounts(input io.Reader, output io.Writer,
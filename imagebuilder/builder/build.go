@@ -5,24 +5,36 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
 	"strings"
 	"time"
 
 	buildclient "github.com/Symantec/Dominator/imagebuilder/client"
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/slavedriver"
 	"github.com/Symantec/Dominator/lib/srpc"
 	proto "github.com/Symantec/Dominator/proto/imaginator"
 )
 
 const errNoSourceImage = "no source image: "
 const errTooOldSourceImage = "too old source image: "
+const maxSlaveBuildAttempts = 3
+const sourceImageLogPrefix = "Source image: "
 
 type dualBuildLogger struct {
-	buffer *bytes.Buffer
+	buffer *buildLogBuffer
 	writer io.Writer
 }
 
+// sourceImageRecorder passes through a build log streamed from a slave and
+// records the source image which the slave reports.
+type sourceImageRecorder struct {
+	writer      io.Writer
+	partial     []byte
+	sourceImage string // Image name.
+}
+
 func checkPermission(builder imageBuilder, request proto.BuildImageRequest,
 	authInfo *srpc.AuthInformation) error {
 	if authInfo == nil || authInfo.HaveMethodAccess {
@@ -52,6 +64,36 @@ func needSourceImage(err error) (bool, string) {
 	return false, ""
 }
 
+func (r *sourceImageRecorder) Write(p []byte) (int, error) {
+	r.partial = append(r.partial, p...)
+	for {
+		index := bytes.IndexByte(r.partial, '\n')
+		if index < 0 {
+			break
+		}
+		line := string(r.partial[:index])
+		if strings.HasPrefix(line, sourceImageLogPrefix) {
+			r.sourceImage = line[len(sourceImageLogPrefix):]
+		}
+		r.partial = r.partial[index+1:]
+	}
+	return r.writer.Write(p)
+}
+
+func (lb *buildLogBuffer) Bytes() []byte {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	retval := make([]byte, lb.buffer.Len())
+	copy(retval, lb.buffer.Bytes())
+	return retval
+}
+
+func (lb *buildLogBuffer) Write(p []byte) (int, error) {
+	lb.mutex.Lock()
+	defer lb.mutex.Unlock()
+	return lb.buffer.Write(p)
+}
+
 func (bl *dualBuildLogger) Bytes() []byte {
 	return bl.buffer.Bytes()
 }
@@ -67,13 +109,8 @@ func (b *Builder) rebuildImages(minInterval time.Duration) {
 	var sleepUntil time.Time
 	for ; ; time.Sleep(time.Until(sleepUntil)) {
 		sleepUntil = time.Now().Add(minInterval)
-		client, err := srpc.DialHTTP("tcp", b.imageServerAddress, 0)
-		if err != nil {
-			b.logger.Println(err)
-			continue
-		}
 		for _, streamName := range b.listStreamsToAutoRebuild() {
-			_, _, err := b.build(client, proto.BuildImageRequest{
+			_, _, err := b.buildQueued(proto.BuildImageRequest{
 				StreamName: streamName,
 				ExpiresIn:  minInterval * 2,
 			},
@@ -83,7 +120,6 @@ func (b *Builder) rebuildImages(minInterval time.Duration) {
 					streamName, err)
 			}
 		}
-		client.Close()
 	}
 }
 
@@ -93,21 +129,17 @@ func (b *Builder) buildImage(request proto.BuildImageRequest,
 	if request.ExpiresIn < time.Minute*15 {
 		return nil, "", errors.New("minimum expiration time is 15 minutes")
 	}
-	client, err := srpc.DialHTTP("tcp", b.imageServerAddress, 0)
-	if err != nil {
-		return nil, "", err
-	}
-	defer client.Close()
-	img, name, err := b.build(client, request, authInfo, logWriter)
+	img, name, err := b.buildQueued(request, authInfo, logWriter)
 	if request.ReturnImage {
 		return img, "", err
 	}
 	return nil, name, err
 }
 
-func (b *Builder) build(client *srpc.Client, request proto.BuildImageRequest,
-	authInfo *srpc.AuthInformation,
-	logWriter io.Writer) (*image.Image, string, error) {
+func (b *Builder) build(client *srpc.Client,
+	entry *buildRequestType) (*image.Image, string, error) {
+	request := entry.request
+	authInfo := entry.authInfo
 	startTime := time.Now()
 	builder := b.getImageBuilderWithReload(request.StreamName)
 	if builder == nil {
@@ -116,21 +148,16 @@ func (b *Builder) build(client *srpc.Client, request proto.BuildImageRequest,
 	if err := checkPermission(builder, request, authInfo); err != nil {
 		return nil, "", err
 	}
-	buildLogBuffer := &bytes.Buffer{}
+	buildLogBuffer := &buildLogBuffer{}
 	b.buildResultsLock.Lock()
 	b.currentBuildLogs[request.StreamName] = buildLogBuffer
 	b.buildResultsLock.Unlock()
-	var buildLog buildLogger
-	if logWriter == nil {
-		buildLog = buildLogBuffer
-	} else {
-		buildLog = &dualBuildLogger{
-			buffer: buildLogBuffer,
-			writer: io.MultiWriter(buildLogBuffer, logWriter),
-		}
+	buildLog := &dualBuildLogger{
+		buffer: buildLogBuffer,
+		writer: io.MultiWriter(buildLogBuffer, entry.logWriter),
 	}
-	img, name, err := b.buildWithLogger(builder, client, request, authInfo,
-		startTime, buildLog)
+	img, name, err := b.buildWithLogger(builder, client, entry, startTime,
+		buildLog)
 	finishTime := time.Now()
 	b.buildResultsLock.Lock()
 	defer b.buildResultsLock.Unlock()
@@ -166,8 +193,10 @@ func (b *Builder) buildSomewhere(builder imageBuilder, client *srpc.Client,
 }
 
 func (b *Builder) buildWithLogger(builder imageBuilder, client *srpc.Client,
-	request proto.BuildImageRequest, authInfo *srpc.AuthInformation,
-	startTime time.Time, buildLog buildLogger) (*image.Image, string, error) {
+	entry *buildRequestType, startTime time.Time,
+	buildLog buildLogger) (*image.Image, string, error) {
+	request := entry.request
+	authInfo := entry.authInfo
 	img, err := b.buildSomewhere(builder, client, request, authInfo, buildLog)
 	if err != nil {
 		if needSource, sourceImage := needSourceImage(err); needSource {
@@ -185,7 +214,7 @@ func (b *Builder) buildWithLogger(builder imageBuilder, client *srpc.Client,
 				MaxSourceAge: request.MaxSourceAge,
 				Variables:    request.Variables,
 			}
-			if _, _, e := b.build(client, sourceReq, nil, buildLog); e != nil {
+			if e := b.buildSourceImage(entry, sourceReq, buildLog); e != nil {
 				return nil, "", e
 			}
 			img, err = b.buildSomewhere(builder, client, request, authInfo,
@@ -195,6 +224,10 @@ func (b *Builder) buildWithLogger(builder imageBuilder, client *srpc.Client,
 	if err != nil {
 		return nil, "", err
 	}
+	if b.isBuildCancelled(entry) {
+		fmt.Fprintln(buildLog, "Build cancelled, not uploading image")
+		return nil, "", errorBuildCancelled
+	}
 	if request.ReturnImage {
 		return img, "", nil
 	}
@@ -231,18 +264,31 @@ func (b *Builder) buildOnSlave(client *srpc.Client,
 		}
 		request.Variables = variables
 	}
-	slave, err := b.slaveDriver.GetSlave()
+	for attempt := 1; ; attempt++ {
+		img, slaveDied, err := b.buildOnOneSlave(request, authInfo, buildLog)
+		if !slaveDied || attempt >= maxSlaveBuildAttempts {
+			return img, err
+		}
+		fmt.Fprintf(buildLog, "Slave died: %s, retrying on another slave\n",
+			err)
+		b.logger.Printf("Slave died building: %s: %s, retrying\n",
+			request.StreamName, err)
+	}
+}
+
+// buildOnOneSlave will build an image on a single slave. If the slave died
+// during the build, the slave is destroyed and slaveDied is true.
+func (b *Builder) buildOnOneSlave(request proto.BuildImageRequest,
+	authInfo *srpc.AuthInformation,
+	buildLog buildLogger) (*image.Image, bool, error) {
+	sourceImage := b.getSourceImage(request.StreamName)
+	slave, err := b.slaveDriver.GetSlaveWithAffinity(
+		func(slave *slavedriver.Slave) uint {
+			return b.getSlaveAffinity(slave, sourceImage)
+		})
 	if err != nil {
-		return nil, fmt.Errorf("error getting slave: %s", err)
+		return nil, false, fmt.Errorf("error getting slave: %s", err)
 	}
-	keepSlave := false
-	defer func() {
-		if keepSlave {
-			slave.Release()
-		} else {
-			slave.Destroy()
-		}
-	}()
 	if authInfo == nil {
 		b.logger.Printf("Auto building image on %s for stream: %s\n",
 			slave, request.StreamName)
@@ -256,14 +302,88 @@ func (b *Builder) buildOnSlave(client *srpc.Client,
 			authInfo.Username, slave, request.StreamName)
 	}
 	var reply proto.BuildImageResponse
-	err = buildclient.BuildImage(slave.GetClient(), request, &reply, buildLog)
+	recorder := &sourceImageRecorder{writer: buildLog}
+	err = buildclient.BuildImage(slave.GetClient(), request, &reply, recorder)
 	if err != nil {
-		if needSource, _ := needSourceImage(err); needSource {
-			keepSlave = true
+		if needSource, sourceImage := needSourceImage(err); needSource {
+			b.setSourceImage(request.StreamName, sourceImage)
+			slave.Release()
+			return nil, false, err
 		}
-		return nil, err
+		slaveDied := slave.GetClient().Ping() != nil
+		b.destroySlave(slave)
+		return nil, slaveDied, err
+	}
+	if recorder.sourceImage != "" {
+		sourceImage = path.Dir(recorder.sourceImage)
+		b.setSourceImage(request.StreamName, sourceImage)
 	}
-	return reply.Image, nil
+	if b.recordSlaveBuild(slave, sourceImage, request.StreamName) {
+		slave.Release()
+	} else {
+		b.destroySlave(slave)
+	}
+	return reply.Image, false, nil
+}
+
+func (b *Builder) destroySlave(slave *slavedriver.Slave) {
+	b.slaveStatesLock.Lock()
+	delete(b.slaveStates, slave.String())
+	b.slaveStatesLock.Unlock()
+	slave.Destroy()
+}
+
+// getSourceImage returns the name of the source image stream reported by the
+// last slave build of a stream. If it is not known (for example, for streams
+// which have not been built yet or bootstrap streams), the empty string is
+// returned. Manifests are not fetched by the master, so this is cheap.
+func (b *Builder) getSourceImage(streamName string) string {
+	b.slaveStatesLock.Lock()
+	defer b.slaveStatesLock.Unlock()
+	return b.sourceImages[streamName]
+}
+
+func (b *Builder) setSourceImage(streamName, sourceImage string) {
+	b.slaveStatesLock.Lock()
+	defer b.slaveStatesLock.Unlock()
+	b.sourceImages[streamName] = sourceImage
+}
+
+// getSlaveAffinity returns a placement score for building from sourceImage on
+// slave. Slaves which previously built from or built the source image are
+// preferred, since they have its objects in their object cache.
+func (b *Builder) getSlaveAffinity(slave *slavedriver.Slave,
+	sourceImage string) uint {
+	if sourceImage == "" {
+		return 0
+	}
+	b.slaveStatesLock.Lock()
+	defer b.slaveStatesLock.Unlock()
+	if slaveState, ok := b.slaveStates[slave.String()]; ok {
+		if _, ok := slaveState.images[sourceImage]; ok {
+			return 1
+		}
+	}
+	return 0
+}
+
+// recordSlaveBuild records a successful build of streamName from sourceImage
+// on slave and returns true if the slave may be re-used for another build.
+func (b *Builder) recordSlaveBuild(slave *slavedriver.Slave,
+	sourceImage, streamName string) bool {
+	b.slaveStatesLock.Lock()
+	defer b.slaveStatesLock.Unlock()
+	slaveState, ok := b.slaveStates[slave.String()]
+	if !ok {
+		slaveState = &slaveStateType{images: make(map[string]struct{})}
+		b.slaveStates[slave.String()] = slaveState
+	}
+	slaveState.numBuilds++
+	if sourceImage != "" {
+		slaveState.images[sourceImage] = struct{}{}
+	}
+	slaveState.images[streamName] = struct{}{}
+	return slaveState.numBuilds < b.maxBuildsPerSlave
 }
 
 func (b *Builder) getImageBuilder(streamName string) imageBuilder {
@@ -294,9 +414,7 @@ func (b *Builder) getCurrentBuildLog(streamName string) ([]byte, error) {
 	if result, ok := b.currentBuildLogs[streamName]; !ok {
 		return nil, errors.New("unknown image: " + streamName)
 	} else {
-		log := make([]byte, result.Len())
-		copy(log, result.Bytes())
-		return log, nil
+		return result.Bytes(), nil
 	}
 }
 
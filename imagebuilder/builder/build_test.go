@@ -0,0 +1,43 @@
+package builder
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSourceImageRecorder(t *testing.T) {
+	var tests = []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"none", []string{"Building\n", "Done\n"}, ""},
+		{"line", []string{"Building\n", "Source image: base/2026\n"},
+			"base/2026"},
+		{"split line", []string{"Building\nSource ima", "ge: base/1\nDone\n"},
+			"base/1"},
+		{"incomplete line", []string{"Source image: base/1"}, ""},
+		{"not at start", []string{"Old Source image: base/1\n"}, ""},
+		{"last wins",
+			[]string{"Source image: base/1\n", "Source image: base/2\n"},
+			"base/2"},
+	}
+	for _, test := range tests {
+		output := &bytes.Buffer{}
+		recorder := &sourceImageRecorder{writer: output}
+		var written string
+		for _, write := range test.writes {
+			if _, err := recorder.Write([]byte(write)); err != nil {
+				t.Fatal(err)
+			}
+			written += write
+		}
+		if recorder.sourceImage != test.want {
+			t.Errorf("%s: source image: %q, want %q",
+				test.name, recorder.sourceImage, test.want)
+		}
+		if output.String() != written {
+			t.Errorf("%s: log not passed through", test.name)
+		}
+	}
+}
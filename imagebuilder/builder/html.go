@@ -61,6 +61,8 @@ func (b *Builder) showImageStreams(writer io.Writer) {
 	fmt.Fprintln(writer, "    <th>Image Stream</th>")
 	fmt.Fprintln(writer, "    <th>ManifestUrl</th>")
 	fmt.Fprintln(writer, "    <th>ManifestDirectory</th>")
+	fmt.Fprintln(writer, "    <th>Template</th>")
+	fmt.Fprintln(writer, "    <th>Parameters</th>")
 	fmt.Fprintln(writer, "  </tr>")
 	for _, streamName := range streamNames {
 		fmt.Fprintf(writer, "  <tr>\n")
@@ -70,10 +72,15 @@ func (b *Builder) showImageStreams(writer io.Writer) {
 		if imageStream := b.getNormalStream(streamName); imageStream == nil {
 			fmt.Fprintln(writer, "    <td></td>")
 			fmt.Fprintln(writer, "    <td></td>")
+			fmt.Fprintln(writer, "    <td></td>")
+			fmt.Fprintln(writer, "    <td></td>")
 		} else {
 			fmt.Fprintf(writer, "    <td>%s</td>\n", imageStream.ManifestUrl)
 			fmt.Fprintf(writer, "    <td>%s</td>\n",
 				imageStream.ManifestDirectory)
+			fmt.Fprintf(writer, "    <td>%s</td>\n", imageStream.templateName)
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				formatParameters(imageStream.parameters))
 		}
 		fmt.Fprintf(writer, "  </tr>\n")
 	}
@@ -84,6 +91,7 @@ func (b *Builder) writeHtml(writer io.Writer) {
 	fmt.Fprintf(writer,
 		"Number of image streams: <a href=\"showImageStreams\">%d</a><p>\n",
 		b.getNumNormalStreams())
+	b.writeQueueHtml(writer)
 	currentBuilds := make([]string, 0)
 	goodBuilds := make(map[string]buildResultType)
 	failedBuilds := make(map[string]buildResultType)
@@ -180,11 +188,37 @@ func (b *Builder) writeHtml(writer io.Writer) {
 	}
 }
 
+func formatParameters(parameters map[string]string) string {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+parameters[name])
+	}
+	return strings.Join(pairs, " ")
+}
+
 func (stream *imageStreamType) WriteHtml(writer io.Writer) {
+	if stream.template != nil {
+		fmt.Fprintf(writer, "Template: <code>%s</code><br>\n",
+			stream.templateName)
+		fmt.Fprintf(writer, "Parameters: <code>%s</code><br>\n",
+			formatParameters(stream.parameters))
+		fmt.Fprintf(writer, "Auto rebuild: %t<br>\n",
+			stream.template.getAutoRebuild(stream.name))
+	}
 	if len(stream.BuilderGroups) > 0 {
 		fmt.Fprintf(writer, "BuilderGroups: %s<br>\n",
 			strings.Join(stream.BuilderGroups, ", "))
 	}
+	if stream.OciImageUrl != "" {
+		fmt.Fprintf(writer, "OCI image URL: <code>%s</code><br>\n",
+			stream.OciImageUrl)
+		return
+	}
 	fmt.Fprintf(writer, "Manifest URL: <code>%s</code><br>\n",
 		stream.ManifestUrl)
 	fmt.Fprintf(writer, "Manifest Directory: <code>%s</code><br>\n",
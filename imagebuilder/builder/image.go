@@ -19,6 +19,7 @@ import (
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/objectserver"
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/triggers"
@@ -28,14 +29,17 @@ import (
 func (stream *imageStreamType) build(b *Builder, client *srpc.Client,
 	request proto.BuildImageRequest, buildLog buildLogger) (
 	*image.Image, error) {
+	if stream.OciImageUrl != "" {
+		return stream.importOciImage(b, client, request, buildLog)
+	}
 	manifestDirectory, err := stream.getManifest(b, request.StreamName,
 		request.GitBranch, request.Variables, buildLog)
 	if err != nil {
 		return nil, err
 	}
 	defer os.RemoveAll(manifestDirectory)
-	img, err := buildImageFromManifest(client, manifestDirectory, request,
-		b.bindMounts, buildLog)
+	img, err := buildImageFromManifest(client, b.objectCache,
+		manifestDirectory, request, b.bindMounts, b.testVm, buildLog)
 	if err != nil {
 		return nil, err
 	}
@@ -45,13 +49,32 @@ func (stream *imageStreamType) build(b *Builder, client *srpc.Client,
 func (stream *imageStreamType) getManifest(b *Builder, streamName string,
 	gitBranch string, variables map[string]string,
 	buildLog io.Writer) (string, error) {
-	if gitBranch == "" {
-		gitBranch = "master"
+	variables, err := stream.getVariables(variables)
+	if err != nil {
+		return "", err
 	}
 	variableFunc := b.getVariableFunc(map[string]string{
 		"IMAGE_STREAM": streamName,
 	},
 		variables)
+	manifestRoot, err := stream.fetchManifest(streamName, gitBranch,
+		variableFunc, buildLog)
+	if err != nil {
+		return "", err
+	}
+	if err := stream.expandManifest(manifestRoot, variableFunc); err != nil {
+		os.RemoveAll(manifestRoot)
+		return "", err
+	}
+	return manifestRoot, nil
+}
+
+func (stream *imageStreamType) fetchManifest(streamName string,
+	gitBranch string, variableFunc func(string) string,
+	buildLog io.Writer) (string, error) {
+	if gitBranch == "" {
+		gitBranch = "master"
+	}
 	manifestRoot, err := makeTempDirectory("",
 		strings.Replace(streamName, "/", "_", -1)+".manifest")
 	if err != nil {
@@ -211,9 +234,11 @@ func runCommand(buildLog io.Writer, cwd string, args ...string) error {
 	return cmd.Run()
 }
 
-func buildImageFromManifest(client *srpc.Client, manifestDir string,
+func buildImageFromManifest(client *srpc.Client,
+	objectsGetter objectserver.ObjectsGetter, manifestDir string,
 	request proto.BuildImageRequest, bindMounts []string,
-	buildLog buildLogger) (*image.Image, error) {
+	testVm *testVmConfigurationType, buildLog buildLogger) (
+	*image.Image, error) {
 	// First load all the various manifest files (fail early on error).
 	computedFilesList, err := util.LoadComputedFiles(
 		path.Join(manifestDir, "computed-files.json"))
@@ -232,6 +257,10 @@ func buildImageFromManifest(client *srpc.Client, manifestDir string,
 	if err != nil {
 		return nil, err
 	}
+	vmTests, err := loadVmTests(manifestDir, testVm)
+	if err != nil {
+		return nil, err
+	}
 	rootDir, err := makeTempDirectory("",
 		strings.Replace(request.StreamName, "/", "_", -1)+".root")
 	if err != nil {
@@ -239,8 +268,8 @@ func buildImageFromManifest(client *srpc.Client, manifestDir string,
 	}
 	defer os.RemoveAll(rootDir)
 	fmt.Fprintf(buildLog, "Created image working directory: %s\n", rootDir)
-	manifest, err := unpackImageAndProcessManifest(client, manifestDir,
-		rootDir, bindMounts, false, buildLog)
+	manifest, err := unpackImageAndProcessManifest(client, objectsGetter,
+		manifestDir, rootDir, bindMounts, false, buildLog)
 	if err != nil {
 		return nil, err
 	}
@@ -269,14 +298,14 @@ func buildImageFromManifest(client *srpc.Client, manifestDir string,
 		imageTriggers = mergeableTriggers.ExportTriggers()
 	}
 	return packImage(client, request, rootDir, manifest.filter,
-		computedFilesList, imageFilter, imageTriggers, buildLog)
+		computedFilesList, imageFilter, imageTriggers, vmTests, buildLog)
 }
 
 func buildImageFromManifestAndUpload(client *srpc.Client, manifestDir string,
 	request proto.BuildImageRequest, bindMounts []string,
 	buildLog buildLogger) (*image.Image, string, error) {
-	img, err := buildImageFromManifest(client, manifestDir, request, bindMounts,
-		buildLog)
+	img, err := buildImageFromManifest(client, nil, manifestDir, request,
+		bindMounts, nil, buildLog)
 	if err != nil {
 		return nil, "", err
 	}
@@ -293,7 +322,7 @@ func buildTreeFromManifest(client *srpc.Client, manifestDir string,
 	if err != nil {
 		return "", err
 	}
-	_, err = unpackImageAndProcessManifest(client, manifestDir, rootDir,
+	_, err = unpackImageAndProcessManifest(client, nil, manifestDir, rootDir,
 		bindMounts, true, buildLog)
 	if err != nil {
 		os.RemoveAll(rootDir)
@@ -344,8 +373,10 @@ func loadTriggers(manifestDir string) (*triggers.Triggers, bool, error) {
 	}
 }
 
-func unpackImage(client *srpc.Client, streamName string,
-	maxSourceAge, expiresIn time.Duration, rootDir string,
+// unpackImage will unpack the latest image for streamName into rootDir. If
+// objectsGetter is nil, objects are fetched directly from the image server.
+func unpackImage(client *srpc.Client, objectsGetter objectserver.ObjectsGetter,
+	streamName string, maxSourceAge, expiresIn time.Duration, rootDir string,
 	buildLog io.Writer) (*sourceImageInfoType, error) {
 	imageName, sourceImage, err := getLatestImage(client, streamName, buildLog)
 	if err != nil {
@@ -357,13 +388,16 @@ func unpackImage(client *srpc.Client, streamName string,
 	if maxSourceAge > 0 && time.Since(sourceImage.CreatedOn) > maxSourceAge {
 		return nil, errors.New(errNoSourceImage + streamName)
 	}
-	objClient := objectclient.AttachObjectClient(client)
-	defer objClient.Close()
-	err = util.Unpack(sourceImage.FileSystem, objClient, rootDir,
+	if objectsGetter == nil {
+		objClient := objectclient.AttachObjectClient(client)
+		defer objClient.Close()
+		objectsGetter = objClient
+	}
+	err = util.Unpack(sourceImage.FileSystem, objectsGetter, rootDir,
 		stdlog.New(buildLog, "", 0))
 	if err != nil {
 		return nil, err
 	}
-	fmt.Fprintf(buildLog, "Source image: %s\n", imageName)
+	fmt.Fprintf(buildLog, "%s%s\n", sourceImageLogPrefix, imageName)
 	return &sourceImageInfoType{sourceImage.Filter, sourceImage.Triggers}, nil
 }
@@ -0,0 +1,73 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem/oci"
+	"github.com/Symantec/Dominator/lib/filter"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/image"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/url/urlutil"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+// importOciImage will build an image by importing the OCI image layout or
+// Docker archive at the stream OciImageUrl, rather than from a manifest.
+func (stream *imageStreamType) importOciImage(b *Builder,
+	client *srpc.Client, request proto.BuildImageRequest,
+	buildLog buildLogger) (*image.Image, error) {
+	startTime := time.Now()
+	variables, err := stream.getVariables(request.Variables)
+	if err != nil {
+		return nil, err
+	}
+	variableFunc := b.getVariableFunc(map[string]string{
+		"IMAGE_STREAM": request.StreamName,
+	},
+		variables)
+	fmt.Fprintf(buildLog, "Importing OCI image: %s\n", stream.OciImageUrl)
+	reader, err := urlutil.Open(os.Expand(stream.OciImageUrl, variableFunc))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	var h hasher
+	h.objQ, err = objectclient.NewObjectAdderQueue(client)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := oci.Decode(bufio.NewReader(reader), &h, nil, "")
+	if err != nil {
+		h.objQ.Close()
+		return nil, fmt.Errorf("error importing OCI image: %s", err)
+	}
+	if err := h.objQ.Close(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(buildLog, "Imported OCI image in %s, %d inodes, %s\n",
+		format.Duration(time.Since(startTime)), len(fs.InodeTable),
+		format.FormatBytes(fs.TotalDataBytes))
+	objClient := objectclient.AttachObjectClient(client)
+	defer objClient.Close()
+	logReader := bytes.NewBuffer(buildLog.Bytes())
+	hashVal, _, err := objClient.AddObject(logReader, uint64(logReader.Len()),
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	img := &image.Image{
+		BuildLog:   &image.Annotation{Object: &hashVal},
+		FileSystem: fs,
+		Filter:     &filter.Filter{},
+	}
+	if err := img.Verify(); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
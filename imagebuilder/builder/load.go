@@ -2,7 +2,6 @@ package builder
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,6 +15,8 @@ import (
 	"github.com/Symantec/Dominator/lib/configwatch"
 	libjson "github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver"
+	"github.com/Symantec/Dominator/lib/objectserver/cachingreader"
 	"github.com/Symantec/Dominator/lib/slavedriver"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/url/urlutil"
@@ -58,6 +59,20 @@ func load(confUrl, variablesFile, stateDir, imageServerAddress string,
 	if variables == nil {
 		variables = make(map[string]string)
 	}
+	var objectCache objectserver.ObjectsGetter
+	if masterConfiguration.ObjectCacheBytes > 0 {
+		objSrv, err := cachingreader.NewObjectServer(
+			filepath.Join(stateDir, "object-cache"),
+			masterConfiguration.ObjectCacheBytes, imageServerAddress, logger)
+		if err != nil {
+			return nil, fmt.Errorf("error creating object cache: %s", err)
+		}
+		objectCache = objSrv
+	}
+	maxBuildsPerSlave := masterConfiguration.MaximumBuildsPerSlave
+	if maxBuildsPerSlave < 1 {
+		maxBuildsPerSlave = 1
+	}
 	b := &Builder{
 		bindMounts:                masterConfiguration.BindMounts,
 		stateDir:                  stateDir,
@@ -65,11 +80,17 @@ func load(confUrl, variablesFile, stateDir, imageServerAddress string,
 		logger:                    logger,
 		imageStreamsUrl:           masterConfiguration.ImageStreamsUrl,
 		bootstrapStreams:          masterConfiguration.BootstrapStreams,
+		buildQueue:                newBuildQueue(masterConfiguration.BuildQueue),
 		imageStreamsToAutoRebuild: imageStreamsToAutoRebuild,
 		slaveDriver:               slaveDriver,
-		currentBuildLogs:          make(map[string]*bytes.Buffer),
+		maxBuildsPerSlave:         maxBuildsPerSlave,
+		objectCache:               objectCache,
+		slaveStates:               make(map[string]*slaveStateType),
+		sourceImages:              make(map[string]string),
+		currentBuildLogs:          make(map[string]*buildLogBuffer),
 		lastBuildResults:          make(map[string]buildResultType),
 		packagerTypes:             masterConfiguration.PackagerTypes,
+		testVm:                    masterConfiguration.TestVm,
 		variables:                 variables,
 	}
 	for name, stream := range b.bootstrapStreams {
@@ -198,8 +219,11 @@ func (b *Builder) updateImageStreams(
 		stream.builder = b
 		stream.name = name
 	}
+	templateStreamsToRebuild := b.expandStreamTemplates(
+		imageStreamsConfiguration)
 	b.streamsLock.Lock()
 	b.imageStreams = imageStreamsConfiguration.Streams
+	b.templateStreamsToRebuild = templateStreamsToRebuild
 	b.streamsLock.Unlock()
 	return b.makeRequiredDirectories()
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/verstr"
 )
@@ -88,7 +89,8 @@ func makeMountPoints(rootDir string, bindMounts []string,
 	return retval, nil
 }
 
-func unpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
+func unpackImageAndProcessManifest(client *srpc.Client,
+	objectsGetter objectserver.ObjectsGetter, manifestDir string,
 	rootDir string, bindMounts []string, applyFilter bool,
 	buildLog io.Writer) (manifestType, error) {
 	manifestFile := filepath.Join(manifestDir, "manifest")
@@ -97,8 +99,8 @@ func unpackImageAndProcessManifest(client *srpc.Client, manifestDir string,
 		return manifestType{},
 			errors.New("error reading manifest file: " + err.Error())
 	}
-	sourceImageInfo, err := unpackImage(client, manifestConfig.SourceImage,
-		0, 0, rootDir, buildLog)
+	sourceImageInfo, err := unpackImage(client, objectsGetter,
+		manifestConfig.SourceImage, 0, 0, rootDir, buildLog)
 	if err != nil {
 		return manifestType{},
 			errors.New("error unpacking image: " + err.Error())
@@ -0,0 +1,585 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+const (
+	autoRebuildPriority              = -10
+	defaultMaxConcurrentBuildsByUser = 1
+	defaultMaxQueuedBuildsByUser     = 10
+)
+
+var errorBuildCancelled = errors.New("build cancelled")
+
+type buildRequestType struct {
+	builderGroup string
+	doneChannel  chan struct{}
+	enqueuedAt   time.Time
+	id           uint64
+	internal     bool // Queued by the Builder for a source image.
+	logWriter    *fanoutWriter
+	request      proto.BuildImageRequest
+	authInfo     *srpc.AuthInformation
+	username     string
+	// The following fields are protected by the queue lock.
+	cancelled        bool
+	priority         int
+	startedAt        time.Time
+	waiters          []*buildWaiterType
+	waitingForSource bool
+	// The following fields are valid once doneChannel is closed.
+	image     *image.Image
+	imageName string
+	err       error
+}
+
+type buildWaiterType struct {
+	cancelChannel chan struct{} // Closed if this wait is cancelled.
+	internal      bool          // Waiting for a source image.
+	logWriter     io.Writer
+	username      string
+}
+
+type fanoutWriter struct {
+	mutex   sync.Mutex
+	writers []io.Writer
+}
+
+type buildQueueType struct {
+	config          buildQueueConfigurationType
+	mutex           sync.Mutex // Protect everything below.
+	nextId          uint64
+	numRunning      uint // Excludes builds waiting for a source build.
+	queued          []*buildRequestType
+	runningBuilds   map[string]*buildRequestType // Key: stream name.
+	runningPerGroup map[string]uint
+	runningPerUser  map[string]uint
+}
+
+func newBuildQueue(config buildQueueConfigurationType) *buildQueueType {
+	return &buildQueueType{
+		config:          config,
+		runningBuilds:   make(map[string]*buildRequestType),
+		runningPerGroup: make(map[string]uint),
+		runningPerUser:  make(map[string]uint),
+	}
+}
+
+func (fw *fanoutWriter) addWriter(writer io.Writer) {
+	if writer == nil {
+		return
+	}
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+	fw.writers = append(fw.writers, writer)
+}
+
+func (fw *fanoutWriter) removeWriter(writer io.Writer) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+	for index, w := range fw.writers {
+		if w == writer {
+			fw.writers = append(fw.writers[:index], fw.writers[index+1:]...)
+			return
+		}
+	}
+}
+
+func (fw *fanoutWriter) Write(p []byte) (int, error) {
+	fw.mutex.Lock()
+	defer fw.mutex.Unlock()
+	for _, writer := range fw.writers {
+		writer.Write(p)
+	}
+	return len(p), nil
+}
+
+func (waiter *buildWaiterType) Write(p []byte) (int, error) {
+	if waiter.logWriter == nil {
+		return len(p), nil
+	}
+	return waiter.logWriter.Write(p)
+}
+
+func getBuilderGroup(builder imageBuilder,
+	authInfo *srpc.AuthInformation) string {
+	if authInfo == nil {
+		return ""
+	}
+	if builder, ok := builder.(*imageStreamType); ok {
+		for _, group := range builder.BuilderGroups {
+			if _, ok := authInfo.GroupList[group]; ok {
+				return group
+			}
+		}
+	}
+	return ""
+}
+
+func requestsEqual(left, right proto.BuildImageRequest) bool {
+	if left.DisableRecursiveBuild != right.DisableRecursiveBuild {
+		return false
+	}
+	if left.ExpiresIn != right.ExpiresIn {
+		return false
+	}
+	if left.GitBranch != right.GitBranch {
+		return false
+	}
+	if left.MaxSourceAge != right.MaxSourceAge {
+		return false
+	}
+	if left.ReturnImage != right.ReturnImage {
+		return false
+	}
+	if left.StreamName != right.StreamName {
+		return false
+	}
+	if len(left.Variables) != len(right.Variables) {
+		return false
+	}
+	for key, value := range left.Variables {
+		if rightValue, ok := right.Variables[key]; !ok || value != rightValue {
+			return false
+		}
+	}
+	return true
+}
+
+// better returns true if left should be scheduled before right.
+func (queue *buildQueueType) better(left, right *buildRequestType) bool {
+	if left.priority != right.priority {
+		return left.priority > right.priority
+	}
+	leftRunning := queue.runningPerGroup[left.builderGroup]
+	rightRunning := queue.runningPerGroup[right.builderGroup]
+	if leftRunning != rightRunning {
+		return leftRunning < rightRunning
+	}
+	return left.enqueuedAt.Before(right.enqueuedAt)
+}
+
+// addRunningCounts will count a running build against the concurrency limits.
+// The queue lock must be held.
+func (queue *buildQueueType) addRunningCounts(entry *buildRequestType) {
+	queue.numRunning++
+	queue.runningPerGroup[entry.builderGroup]++
+	queue.runningPerUser[entry.username]++
+}
+
+// removeRunningCounts will stop counting a build against the concurrency
+// limits. The queue lock must be held.
+func (queue *buildQueueType) removeRunningCounts(entry *buildRequestType) {
+	queue.numRunning--
+	if count := queue.runningPerGroup[entry.builderGroup]; count <= 1 {
+		delete(queue.runningPerGroup, entry.builderGroup)
+	} else {
+		queue.runningPerGroup[entry.builderGroup] = count - 1
+	}
+	if count := queue.runningPerUser[entry.username]; count <= 1 {
+		delete(queue.runningPerUser, entry.username)
+	} else {
+		queue.runningPerUser[entry.username] = count - 1
+	}
+}
+
+// canStart returns true if the concurrency limits allow entry to start. The
+// queue lock must be held.
+func (queue *buildQueueType) canStart(entry *buildRequestType) bool {
+	if _, ok := queue.runningBuilds[entry.request.StreamName]; ok {
+		return false
+	}
+	if entry.username != "" {
+		limit := queue.config.MaximumConcurrentBuildsPerUser
+		if limit < 1 {
+			limit = defaultMaxConcurrentBuildsByUser
+		}
+		if queue.runningPerUser[entry.username] >= limit &&
+			(entry.authInfo == nil || !entry.authInfo.HaveMethodAccess) {
+			return false
+		}
+	}
+	if entry.builderGroup != "" {
+		group := entry.builderGroup
+		limit := queue.config.MaximumConcurrentBuildsPerGroup[group]
+		if limit > 0 && queue.runningPerGroup[group] >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+// buildQueued will add a build request to the queue (or join an identical
+// queued request) and wait for the build to complete.
+func (b *Builder) buildQueued(request proto.BuildImageRequest,
+	authInfo *srpc.AuthInformation,
+	logWriter io.Writer) (*image.Image, string, error) {
+	builder := b.getImageBuilderWithReload(request.StreamName)
+	if builder == nil {
+		return nil, "", errors.New("unknown stream: " + request.StreamName)
+	}
+	if err := checkPermission(builder, request, authInfo); err != nil {
+		return nil, "", err
+	}
+	priority := request.Priority
+	var username string
+	if authInfo == nil {
+		priority = autoRebuildPriority
+	} else {
+		username = authInfo.Username
+		if priority > 0 && !authInfo.HaveMethodAccess {
+			return nil, "", errors.New("no permission to raise priority")
+		}
+	}
+	entry, waiter, position, err := b.enqueueBuild(request, authInfo,
+		getBuilderGroup(builder, authInfo), username, priority, false,
+		logWriter)
+	if err != nil {
+		return nil, "", err
+	}
+	if position > 0 && logWriter != nil {
+		fmt.Fprintf(logWriter,
+			"Queued build for stream: %s, %d builds ahead in queue\n",
+			request.StreamName, position)
+	}
+	select {
+	case <-entry.doneChannel:
+		return entry.image, entry.imageName, entry.err
+	case <-waiter.cancelChannel:
+		return nil, "", errorBuildCancelled
+	}
+}
+
+// enqueueBuild will add a build request to the queue, or join an identical
+// queued build. Internal requests (source builds for a running build) do not
+// count against the per-user queue limit.
+func (b *Builder) enqueueBuild(request proto.BuildImageRequest,
+	authInfo *srpc.AuthInformation, builderGroup, username string,
+	priority int, internal bool, logWriter io.Writer) (
+	*buildRequestType, *buildWaiterType, int, error) {
+	queue := b.buildQueue
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	waiter := &buildWaiterType{
+		cancelChannel: make(chan struct{}),
+		internal:      internal,
+		logWriter:     logWriter,
+		username:      username,
+	}
+	numQueuedByUser := uint(0)
+	for index, entry := range queue.queued {
+		if username != "" && entry.username == username && !entry.internal {
+			numQueuedByUser++
+		}
+		if requestsEqual(entry.request, request) {
+			entry.logWriter.addWriter(waiter)
+			entry.waiters = append(entry.waiters, waiter)
+			if priority > entry.priority {
+				entry.priority = priority
+			}
+			if logWriter != nil {
+				fmt.Fprintf(logWriter,
+					"Joined identical queued build: %d for stream: %s\n",
+					entry.id, request.StreamName)
+			}
+			return entry, waiter, index, nil
+		}
+	}
+	if !internal && username != "" &&
+		(authInfo == nil || !authInfo.HaveMethodAccess) {
+		limit := queue.config.MaximumQueuedBuildsPerUser
+		if limit < 1 {
+			limit = defaultMaxQueuedBuildsByUser
+		}
+		if numQueuedByUser >= limit {
+			return nil, nil, 0,
+				fmt.Errorf("%s reached limit of %d queued builds",
+					username, limit)
+		}
+	}
+	queue.nextId++
+	entry := &buildRequestType{
+		authInfo:     authInfo,
+		builderGroup: builderGroup,
+		doneChannel:  make(chan struct{}),
+		enqueuedAt:   time.Now(),
+		id:           queue.nextId,
+		internal:     internal,
+		logWriter:    &fanoutWriter{},
+		priority:     priority,
+		request:      request,
+		username:     username,
+		waiters:      []*buildWaiterType{waiter},
+	}
+	entry.logWriter.addWriter(waiter)
+	queue.queued = append(queue.queued, entry)
+	position := len(queue.queued) - 1
+	b.scheduleBuilds()
+	return entry, waiter, position, nil
+}
+
+// scheduleBuilds will start as many queued builds as the concurrency limits
+// allow. The queue lock must be held.
+func (b *Builder) scheduleBuilds() {
+	queue := b.buildQueue
+	for len(queue.queued) > 0 {
+		if max := queue.config.MaximumConcurrentBuilds; max > 0 &&
+			queue.numRunning >= max {
+			return
+		}
+		bestIndex := -1
+		for index, entry := range queue.queued {
+			if !queue.canStart(entry) {
+				continue
+			}
+			if bestIndex < 0 || queue.better(entry, queue.queued[bestIndex]) {
+				bestIndex = index
+			}
+		}
+		if bestIndex < 0 {
+			return
+		}
+		entry := queue.queued[bestIndex]
+		queue.queued = append(queue.queued[:bestIndex],
+			queue.queued[bestIndex+1:]...)
+		entry.startedAt = time.Now()
+		queue.runningBuilds[entry.request.StreamName] = entry
+		queue.addRunningCounts(entry)
+		go b.runQueuedBuild(entry)
+	}
+}
+
+func (b *Builder) runQueuedBuild(entry *buildRequestType) {
+	client, err := srpc.DialHTTP("tcp", b.imageServerAddress, 0)
+	if err != nil {
+		entry.err = err
+	} else {
+		entry.image, entry.imageName, entry.err = b.build(client, entry)
+		client.Close()
+	}
+	queue := b.buildQueue
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	delete(queue.runningBuilds, entry.request.StreamName)
+	queue.removeRunningCounts(entry)
+	close(entry.doneChannel)
+	b.scheduleBuilds()
+}
+
+// cancelWaits will cancel the waits by the user in authInfo for a build. It
+// returns true if any waits were cancelled and true if the build itself
+// should be cancelled. Administrators cancel the build for all waiters,
+// otherwise the build is only cancelled once no waiters remain. Waits for
+// source images are not cancelled by users. The queue lock must be held.
+func (entry *buildRequestType) cancelWaits(
+	authInfo *srpc.AuthInformation) (bool, bool) {
+	if authInfo == nil || authInfo.HaveMethodAccess {
+		return true, true
+	}
+	waiters := make([]*buildWaiterType, 0, len(entry.waiters))
+	for _, waiter := range entry.waiters {
+		if waiter.internal || waiter.username != authInfo.Username {
+			waiters = append(waiters, waiter)
+			continue
+		}
+		close(waiter.cancelChannel)
+		entry.logWriter.removeWriter(waiter)
+		fmt.Fprintln(waiter, "Build cancelled")
+	}
+	if len(waiters) == len(entry.waiters) {
+		return false, false
+	}
+	entry.waiters = waiters
+	return true, len(waiters) < 1
+}
+
+func (b *Builder) cancelBuild(streamName string,
+	authInfo *srpc.AuthInformation) (uint, error) {
+	queue := b.buildQueue
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	var numCancelled, numDenied uint
+	queued := make([]*buildRequestType, 0, len(queue.queued))
+	for _, entry := range queue.queued {
+		if entry.request.StreamName != streamName {
+			queued = append(queued, entry)
+			continue
+		}
+		if cancelled, cancelBuild := entry.cancelWaits(authInfo); !cancelled {
+			queued = append(queued, entry)
+			numDenied++
+		} else if !cancelBuild {
+			queued = append(queued, entry)
+			numCancelled++
+		} else {
+			entry.err = errorBuildCancelled
+			close(entry.doneChannel)
+			numCancelled++
+		}
+	}
+	queue.queued = queued
+	if entry := queue.runningBuilds[streamName]; entry != nil {
+		if cancelled, cancelBuild := entry.cancelWaits(authInfo); !cancelled {
+			numDenied++
+		} else if !cancelBuild {
+			numCancelled++
+		} else if !entry.cancelled {
+			entry.cancelled = true
+			fmt.Fprintf(entry.logWriter, "Build cancelled\n")
+			numCancelled++
+		}
+	}
+	if numCancelled > 0 {
+		b.logger.Printf("Cancelled %d builds for stream: %s\n",
+			numCancelled, streamName)
+		b.scheduleBuilds()
+		return numCancelled, nil
+	}
+	if numDenied > 0 {
+		return 0, errors.New("no permission to cancel build for: " +
+			streamName)
+	}
+	return 0, errors.New("no builds queued or running for: " + streamName)
+}
+
+// isBuildCancelled returns true if the running queued build was cancelled.
+// Cancelled builds run to completion but are not uploaded.
+func (b *Builder) isBuildCancelled(entry *buildRequestType) bool {
+	queue := b.buildQueue
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return entry.cancelled
+}
+
+// buildSourceImage will queue a build of the source image needed by a running
+// build and wait for it to complete. The source build has the priority, user
+// and builder group of the running build, but does not count against the
+// per-user queue limit. While waiting, the running build does not count
+// against the concurrency limits, so that the source build can be scheduled.
+func (b *Builder) buildSourceImage(parent *buildRequestType,
+	request proto.BuildImageRequest, logWriter io.Writer) error {
+	if b.getImageBuilderWithReload(request.StreamName) == nil {
+		return errors.New("unknown stream: " + request.StreamName)
+	}
+	queue := b.buildQueue
+	queue.mutex.Lock()
+	parent.waitingForSource = true
+	queue.removeRunningCounts(parent)
+	priority := parent.priority
+	queue.mutex.Unlock()
+	defer func() {
+		queue.mutex.Lock()
+		parent.waitingForSource = false
+		queue.addRunningCounts(parent)
+		queue.mutex.Unlock()
+	}()
+	// The source build is made on behalf of the Builder, so there is no
+	// authentication information and the stream permissions do not apply.
+	entry, _, position, err := b.enqueueBuild(request, nil,
+		parent.builderGroup, parent.username, priority, true, logWriter)
+	if err != nil {
+		return err
+	}
+	if position > 0 {
+		fmt.Fprintf(logWriter,
+			"Queued build for source stream: %s, %d builds ahead in queue\n",
+			request.StreamName, position)
+	}
+	<-entry.doneChannel
+	return entry.err
+}
+
+func (b *Builder) writeQueueHtml(writer io.Writer) {
+	queue := b.buildQueue
+	queue.mutex.Lock()
+	queued := make([]buildRequestType, 0, len(queue.queued))
+	numWaiters := make([]int, 0, len(queue.queued))
+	for _, entry := range queue.queued {
+		queued = append(queued, *entry)
+		numWaiters = append(numWaiters, len(entry.waiters))
+	}
+	running := make([]buildRequestType, 0, len(queue.runningBuilds))
+	for _, entry := range queue.runningBuilds {
+		running = append(running, *entry)
+	}
+	queue.mutex.Unlock()
+	currentTime := time.Now()
+	writeUser := func(entry buildRequestType) {
+		if entry.username == "" {
+			fmt.Fprintln(writer, "    <td>(auto rebuild)</td>")
+		} else {
+			fmt.Fprintf(writer, "    <td>%s</td>\n", entry.username)
+		}
+		fmt.Fprintf(writer, "    <td>%s</td>\n", entry.builderGroup)
+	}
+	if len(running) > 0 {
+		fmt.Fprintln(writer, "Running queued builds:<br>")
+		fmt.Fprintln(writer, `<table border="1">`)
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintln(writer, "    <th>Id</th>")
+		fmt.Fprintln(writer, "    <th>Image Stream</th>")
+		fmt.Fprintln(writer, "    <th>Priority</th>")
+		fmt.Fprintln(writer, "    <th>User</th>")
+		fmt.Fprintln(writer, "    <th>Builder Group</th>")
+		fmt.Fprintln(writer, "    <th>Waited</th>")
+		fmt.Fprintln(writer, "    <th>Running</th>")
+		fmt.Fprintln(writer, "  </tr>")
+		for _, entry := range running {
+			fmt.Fprintf(writer, "  <tr>\n")
+			fmt.Fprintf(writer, "    <td>%d</td>\n", entry.id)
+			if entry.cancelled {
+				fmt.Fprintf(writer, "    <td>%s (cancelled)</td>\n",
+					entry.request.StreamName)
+			} else if entry.waitingForSource {
+				fmt.Fprintf(writer, "    <td>%s (waiting for source)</td>\n",
+					entry.request.StreamName)
+			} else {
+				fmt.Fprintf(writer, "    <td>%s</td>\n",
+					entry.request.StreamName)
+			}
+			fmt.Fprintf(writer, "    <td>%d</td>\n", entry.priority)
+			writeUser(entry)
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				format.Duration(entry.startedAt.Sub(entry.enqueuedAt)))
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				format.Duration(currentTime.Sub(entry.startedAt)))
+			fmt.Fprintf(writer, "  </tr>\n")
+		}
+		fmt.Fprintln(writer, "</table><br>")
+	}
+	if len(queued) > 0 {
+		fmt.Fprintln(writer, "Queued builds:<br>")
+		fmt.Fprintln(writer, `<table border="1">`)
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintln(writer, "    <th>Id</th>")
+		fmt.Fprintln(writer, "    <th>Image Stream</th>")
+		fmt.Fprintln(writer, "    <th>Priority</th>")
+		fmt.Fprintln(writer, "    <th>User</th>")
+		fmt.Fprintln(writer, "    <th>Builder Group</th>")
+		fmt.Fprintln(writer, "    <th>Waiters</th>")
+		fmt.Fprintln(writer, "    <th>Waiting</th>")
+		fmt.Fprintln(writer, "  </tr>")
+		for index, entry := range queued {
+			fmt.Fprintf(writer, "  <tr>\n")
+			fmt.Fprintf(writer, "    <td>%d</td>\n", entry.id)
+			fmt.Fprintf(writer, "    <td>%s</td>\n", entry.request.StreamName)
+			fmt.Fprintf(writer, "    <td>%d</td>\n", entry.priority)
+			writeUser(entry)
+			fmt.Fprintf(writer, "    <td>%d</td>\n", numWaiters[index])
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				format.Duration(currentTime.Sub(entry.enqueuedAt)))
+			fmt.Fprintf(writer, "  </tr>\n")
+		}
+		fmt.Fprintln(writer, "</table><br>")
+	}
+}
@@ -0,0 +1,268 @@
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+func newTestBuilder(config buildQueueConfigurationType,
+	numRunning uint) *Builder {
+	queue := newBuildQueue(config)
+	// Pretend builds are running so that nothing is started.
+	queue.numRunning = numRunning
+	return &Builder{buildQueue: queue}
+}
+
+func TestBetter(t *testing.T) {
+	now := time.Now()
+	queue := newBuildQueue(buildQueueConfigurationType{})
+	queue.runningPerGroup["busy"] = 2
+	var tests = []struct {
+		name        string
+		left, right buildRequestType
+		want        bool
+	}{
+		{"higher priority",
+			buildRequestType{priority: 1, enqueuedAt: now},
+			buildRequestType{priority: 0, enqueuedAt: now.Add(-time.Hour)},
+			true},
+		{"lower priority",
+			buildRequestType{priority: -10, enqueuedAt: now.Add(-time.Hour)},
+			buildRequestType{priority: 0, enqueuedAt: now},
+			false},
+		{"fewer running in group",
+			buildRequestType{builderGroup: "idle", enqueuedAt: now},
+			buildRequestType{builderGroup: "busy",
+				enqueuedAt: now.Add(-time.Hour)},
+			true},
+		{"enqueued earlier",
+			buildRequestType{enqueuedAt: now.Add(-time.Minute)},
+			buildRequestType{enqueuedAt: now},
+			true},
+		{"enqueued later",
+			buildRequestType{enqueuedAt: now},
+			buildRequestType{enqueuedAt: now.Add(-time.Minute)},
+			false},
+	}
+	for _, test := range tests {
+		if got := queue.better(&test.left, &test.right); got != test.want {
+			t.Errorf("%s: better() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCanStart(t *testing.T) {
+	queue := newBuildQueue(buildQueueConfigurationType{
+		MaximumConcurrentBuildsPerGroup: map[string]uint{"group": 1},
+		MaximumConcurrentBuildsPerUser:  2,
+	})
+	queue.runningBuilds["running"] = &buildRequestType{}
+	queue.runningPerGroup["group"] = 1
+	queue.runningPerUser["busy"] = 2
+	user := &srpc.AuthInformation{Username: "user"}
+	admin := &srpc.AuthInformation{HaveMethodAccess: true, Username: "busy"}
+	var tests = []struct {
+		name         string
+		streamName   string
+		username     string
+		builderGroup string
+		authInfo     *srpc.AuthInformation
+		want         bool
+	}{
+		{"idle user", "s", "user", "", user, true},
+		{"stream running", "running", "user", "", user, false},
+		{"user limit", "s", "busy", "", user, false},
+		{"user limit without auth", "s", "busy", "", nil, false},
+		{"user limit for admin", "s", "busy", "", admin, true},
+		{"group limit", "s", "user", "group", user, false},
+		{"other group", "s", "user", "other", user, true},
+		{"auto rebuild", "s", "", "", nil, true},
+	}
+	for _, test := range tests {
+		entry := &buildRequestType{
+			authInfo:     test.authInfo,
+			builderGroup: test.builderGroup,
+			request:      proto.BuildImageRequest{StreamName: test.streamName},
+			username:     test.username,
+		}
+		if got := queue.canStart(entry); got != test.want {
+			t.Errorf("%s: canStart() = %v, want %v",
+				test.name, got, test.want)
+		}
+	}
+}
+
+func TestEnqueueBuild(t *testing.T) {
+	b := newTestBuilder(buildQueueConfigurationType{
+		MaximumConcurrentBuilds:    1,
+		MaximumQueuedBuildsPerUser: 2,
+	}, 1)
+	request := proto.BuildImageRequest{StreamName: "a", ExpiresIn: time.Hour}
+	user := &srpc.AuthInformation{Username: "user"}
+	first, _, position, err := b.enqueueBuild(request, user, "", "user", 0,
+		false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position != 0 {
+		t.Errorf("first position = %d, want 0", position)
+	}
+	// An identical request joins the queued build and raises its priority.
+	other := &srpc.AuthInformation{HaveMethodAccess: true, Username: "other"}
+	joined, _, _, err := b.enqueueBuild(request, other, "", "other", 5,
+		false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined != first {
+		t.Error("identical request was not joined")
+	}
+	if first.priority != 5 {
+		t.Errorf("priority = %d, want 5", first.priority)
+	}
+	if len(first.waiters) != 2 || first.waiters[1].username != "other" {
+		t.Error("joining user is not a waiter")
+	}
+	// A different request is queued separately.
+	request.ExpiresIn = time.Hour * 2
+	second, _, position, err := b.enqueueBuild(request, user, "", "user", 0,
+		false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first || position != 1 {
+		t.Errorf("second request: joined=%v position=%d, want new at 1",
+			second == first, position)
+	}
+	// The per-user queue limit applies.
+	request.StreamName = "b"
+	if _, _, _, err := b.enqueueBuild(request, user, "", "user", 0,
+		false, nil); err == nil {
+		t.Error("per-user queue limit not enforced")
+	}
+	// Source builds queued by the Builder are not limited or counted.
+	if _, _, _, err := b.enqueueBuild(request, nil, "", "user", 0,
+		true, nil); err != nil {
+		t.Errorf("internal build limited: %s", err)
+	}
+	request.StreamName = "c"
+	if _, _, _, err := b.enqueueBuild(request, nil, "", "user", 0,
+		true, nil); err != nil {
+		t.Errorf("internal build limited: %s", err)
+	}
+	if len(b.buildQueue.queued) != 4 {
+		t.Errorf("queue length = %d, want 4", len(b.buildQueue.queued))
+	}
+}
+
+func TestCancelBuild(t *testing.T) {
+	admin := &srpc.AuthInformation{HaveMethodAccess: true, Username: "admin"}
+	alice := &srpc.AuthInformation{Username: "alice"}
+	bob := &srpc.AuthInformation{Username: "bob"}
+	eve := &srpc.AuthInformation{Username: "eve"}
+	type waitType struct {
+		authInfo *srpc.AuthInformation
+		internal bool
+	}
+	var tests = []struct {
+		name          string
+		waits         []waitType
+		cancels       []*srpc.AuthInformation
+		wantErr       bool
+		wantCancelled []bool // Per wait.
+		wantBuildDone bool
+	}{
+		{"only waiter", []waitType{{alice, false}},
+			[]*srpc.AuthInformation{alice}, false, []bool{true}, true},
+		{"other waiter remains",
+			[]waitType{{alice, false}, {bob, false}},
+			[]*srpc.AuthInformation{alice}, false, []bool{true, false},
+			false},
+		{"last waiter leaves",
+			[]waitType{{alice, false}, {bob, false}},
+			[]*srpc.AuthInformation{alice, bob}, false,
+			[]bool{true, true}, true},
+		{"not a waiter", []waitType{{alice, false}},
+			[]*srpc.AuthInformation{eve}, true, []bool{false}, false},
+		{"administrator", []waitType{{alice, false}, {bob, false}},
+			[]*srpc.AuthInformation{admin}, false, []bool{false, false},
+			true},
+		{"source build", []waitType{{alice, true}},
+			[]*srpc.AuthInformation{alice}, true, []bool{false}, false},
+	}
+	for _, test := range tests {
+		b := newTestBuilder(buildQueueConfigurationType{
+			MaximumConcurrentBuilds: 1,
+		}, 1)
+		b.logger = testlogger.New(t)
+		request := proto.BuildImageRequest{StreamName: "stream"}
+		var entry *buildRequestType
+		var waiters []*buildWaiterType
+		for _, wait := range test.waits {
+			var authInfo *srpc.AuthInformation
+			if !wait.internal {
+				authInfo = wait.authInfo
+			}
+			e, waiter, _, err := b.enqueueBuild(request, authInfo, "",
+				wait.authInfo.Username, 0, wait.internal, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry = e
+			waiters = append(waiters, waiter)
+		}
+		var err error
+		for _, authInfo := range test.cancels {
+			_, err = b.cancelBuild(request.StreamName, authInfo)
+		}
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error: %v, want error: %t",
+				test.name, err, test.wantErr)
+		}
+		for index, waiter := range waiters {
+			if got := isClosed(waiter.cancelChannel); got !=
+				test.wantCancelled[index] {
+				t.Errorf("%s: wait %d cancelled: %t, want %t",
+					test.name, index, got, test.wantCancelled[index])
+			}
+		}
+		if got := isClosed(entry.doneChannel); got != test.wantBuildDone {
+			t.Errorf("%s: build cancelled: %t, want %t",
+				test.name, got, test.wantBuildDone)
+		}
+		if test.wantBuildDone && entry.err != errorBuildCancelled {
+			t.Errorf("%s: build error: %v", test.name, entry.err)
+		}
+	}
+}
+
+func isClosed(channel <-chan struct{}) bool {
+	select {
+	case <-channel:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestRunningCounts(t *testing.T) {
+	queue := newBuildQueue(buildQueueConfigurationType{})
+	entry := &buildRequestType{builderGroup: "group", username: "user"}
+	queue.addRunningCounts(entry)
+	queue.addRunningCounts(entry)
+	queue.removeRunningCounts(entry)
+	if queue.numRunning != 1 || queue.runningPerGroup["group"] != 1 ||
+		queue.runningPerUser["user"] != 1 {
+		t.Errorf("counts: %d %d %d, want 1 1 1", queue.numRunning,
+			queue.runningPerGroup["group"], queue.runningPerUser["user"])
+	}
+	queue.removeRunningCounts(entry)
+	if queue.numRunning != 0 || len(queue.runningPerGroup) != 0 ||
+		len(queue.runningPerUser) != 0 {
+		t.Error("counts not removed")
+	}
+}
@@ -55,7 +55,9 @@ func (b *Builder) listNormalStreamNames() []string {
 func (b *Builder) listStreamsToAutoRebuild() []string {
 	b.streamsLock.RLock()
 	defer b.streamsLock.RUnlock()
-	imageStreamNames := make([]string, len(b.imageStreamsToAutoRebuild))
-	copy(imageStreamNames, b.imageStreamsToAutoRebuild)
-	return imageStreamNames
+	imageStreamNames := make([]string, 0,
+		len(b.imageStreamsToAutoRebuild)+len(b.templateStreamsToRebuild))
+	imageStreamNames = append(imageStreamNames,
+		b.imageStreamsToAutoRebuild...)
+	return append(imageStreamNames, b.templateStreamsToRebuild...)
 }
@@ -0,0 +1,216 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Symantec/Dominator/lib/json"
+)
+
+const (
+	parameterTypeBoolean = "boolean"
+	parameterTypeInteger = "integer"
+	parameterTypeString  = "string"
+)
+
+func validateParameterValue(parameter streamParameterType, value string) error {
+	switch parameter.Type {
+	case "", parameterTypeString:
+		return nil
+	case parameterTypeBoolean:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid boolean value: \"%s\"", value)
+		}
+		return nil
+	case parameterTypeInteger:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("invalid integer value: \"%s\"", value)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown parameter type: \"%s\"", parameter.Type)
+}
+
+func (template *streamTemplateType) validate() error {
+	for name, parameter := range template.Parameters {
+		switch parameter.Type {
+		case "", parameterTypeBoolean, parameterTypeInteger,
+			parameterTypeString:
+		default:
+			return fmt.Errorf("parameter: %s: unknown type: \"%s\"",
+				name, parameter.Type)
+		}
+		for _, value := range parameter.Values {
+			if err := validateParameterValue(parameter, value); err != nil {
+				return fmt.Errorf("parameter: %s: %s", name, err)
+			}
+		}
+		if parameter.Default != "" {
+			err := validateParameterValue(parameter, parameter.Default)
+			if err != nil {
+				return fmt.Errorf("parameter: %s: default: %s", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// expand will return the image streams for every combination of the template
+// matrix parameters, keyed by the expanded stream name.
+func (template *streamTemplateType) expand(b *Builder,
+	templateName string) (map[string]*imageStreamType, error) {
+	if err := template.validate(); err != nil {
+		return nil, err
+	}
+	matrixNames := make([]string, 0)
+	for name, parameter := range template.Parameters {
+		if len(parameter.Values) > 0 {
+			matrixNames = append(matrixNames, name)
+		}
+	}
+	sort.Strings(matrixNames)
+	combinations := []map[string]string{make(map[string]string)}
+	for _, name := range matrixNames {
+		var newCombinations []map[string]string
+		for _, combination := range combinations {
+			for _, value := range template.Parameters[name].Values {
+				newCombination := make(map[string]string, len(combination)+1)
+				for key, value := range combination {
+					newCombination[key] = value
+				}
+				newCombination[name] = value
+				newCombinations = append(newCombinations, newCombination)
+			}
+		}
+		combinations = newCombinations
+	}
+	streams := make(map[string]*imageStreamType, len(combinations))
+	for _, combination := range combinations {
+		streamName := os.Expand(templateName, func(name string) string {
+			return combination[name]
+		})
+		if _, ok := streams[streamName]; ok {
+			return nil, fmt.Errorf("duplicate expanded stream name: %s",
+				streamName)
+		}
+		streams[streamName] = &imageStreamType{
+			builder:           b,
+			name:              streamName,
+			BuilderGroups:     template.BuilderGroups,
+			ManifestUrl:       template.ManifestUrl,
+			ManifestDirectory: template.ManifestDirectory,
+			OciImageUrl:       template.OciImageUrl,
+			parameters:        combination,
+			template:          template,
+			templateName:      templateName,
+		}
+	}
+	return streams, nil
+}
+
+func (template *streamTemplateType) getAutoRebuild(streamName string) bool {
+	if autoRebuild, ok := template.AutoRebuildInstances[streamName]; ok {
+		return autoRebuild
+	}
+	return template.AutoRebuild
+}
+
+// expandStreamTemplates will add the streams expanded from the templates to
+// the configuration and return the names of the expanded streams which should
+// be rebuilt automatically. Templates which fail to expand are logged and
+// skipped.
+func (b *Builder) expandStreamTemplates(
+	imageStreamsConfiguration *imageStreamsConfigurationType) []string {
+	if imageStreamsConfiguration.Streams == nil {
+		imageStreamsConfiguration.Streams = make(map[string]*imageStreamType)
+	}
+	streamsToAutoRebuild := make([]string, 0)
+	for templateName, template := range imageStreamsConfiguration.StreamTemplates {
+		streams, err := template.expand(b, templateName)
+		if err == nil {
+			for streamName := range streams {
+				if _, ok := imageStreamsConfiguration.Streams[streamName]; ok {
+					err = errors.New("conflicts with stream: " + streamName)
+					break
+				}
+			}
+		}
+		if err != nil {
+			b.logger.Printf("Error expanding stream template: %s: %s\n",
+				templateName, err)
+			continue
+		}
+		for streamName, stream := range streams {
+			imageStreamsConfiguration.Streams[streamName] = stream
+			if template.getAutoRebuild(streamName) {
+				streamsToAutoRebuild = append(streamsToAutoRebuild, streamName)
+			}
+		}
+	}
+	sort.Strings(streamsToAutoRebuild)
+	return streamsToAutoRebuild
+}
+
+// getVariables will return the variables for a build, merging the template
+// parameters for the stream with the variables in the request. Variables for
+// template parameters are validated.
+func (stream *imageStreamType) getVariables(
+	variables map[string]string) (map[string]string, error) {
+	if stream.template == nil {
+		return variables, nil
+	}
+	retval := make(map[string]string,
+		len(variables)+len(stream.template.Parameters))
+	for key, value := range variables {
+		retval[key] = value
+	}
+	for name, parameter := range stream.template.Parameters {
+		if value, ok := stream.parameters[name]; ok {
+			if reqValue, ok := variables[name]; ok && reqValue != value {
+				return nil, fmt.Errorf(
+					"cannot override matrix parameter: %s for stream: %s",
+					name, stream.name)
+			}
+			retval[name] = value
+			continue
+		}
+		if value, ok := variables[name]; ok {
+			if err := validateParameterValue(parameter, value); err != nil {
+				return nil, fmt.Errorf("parameter: %s: %s", name, err)
+			}
+			continue
+		}
+		if parameter.Default == "" && parameter.Required {
+			return nil, fmt.Errorf("missing required parameter: %s", name)
+		}
+		retval[name] = parameter.Default
+	}
+	return retval, nil
+}
+
+// expandManifest will expand the template parameters in the SourceImage field
+// of the manifest file.
+func (stream *imageStreamType) expandManifest(manifestDir string,
+	variableFunc func(string) string) error {
+	if stream.template == nil {
+		return nil
+	}
+	manifestFile := filepath.Join(manifestDir, "manifest")
+	var manifestConfig manifestConfigType
+	if err := json.ReadFromFile(manifestFile, &manifestConfig); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	sourceImage := os.Expand(manifestConfig.SourceImage, variableFunc)
+	if sourceImage == manifestConfig.SourceImage {
+		return nil
+	}
+	manifestConfig.SourceImage = sourceImage
+	return json.WriteToFile(manifestFile, 0644, "    ", manifestConfig)
+}
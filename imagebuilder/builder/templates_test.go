@@ -0,0 +1,287 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+)
+
+func TestValidateParameterValue(t *testing.T) {
+	var tests = []struct {
+		parameterType string
+		value         string
+		wantErr       bool
+	}{
+		{"", "anything", false},
+		{parameterTypeString, "", false},
+		{parameterTypeBoolean, "true", false},
+		{parameterTypeBoolean, "0", false},
+		{parameterTypeBoolean, "yes", true},
+		{parameterTypeInteger, "-42", false},
+		{parameterTypeInteger, "4.2", true},
+		{parameterTypeInteger, "", true},
+		{"float", "1.0", true},
+	}
+	for _, test := range tests {
+		err := validateParameterValue(
+			streamParameterType{Type: test.parameterType}, test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("validateParameterValue(%s, %q): %v, want error: %t",
+				test.parameterType, test.value, err, test.wantErr)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	var tests = []struct {
+		name       string
+		parameters map[string]streamParameterType
+		wantErr    bool
+	}{
+		{"empty", nil, false},
+		{"valid", map[string]streamParameterType{
+			"ARCH":  {Values: []string{"amd64", "arm64"}},
+			"DEBUG": {Type: parameterTypeBoolean, Default: "false"},
+			"SIZE":  {Type: parameterTypeInteger, Values: []string{"1", "2"}},
+		}, false},
+		{"unknown type", map[string]streamParameterType{
+			"X": {Type: "float"},
+		}, true},
+		{"bad value", map[string]streamParameterType{
+			"SIZE": {Type: parameterTypeInteger, Values: []string{"big"}},
+		}, true},
+		{"bad default", map[string]streamParameterType{
+			"DEBUG": {Type: parameterTypeBoolean, Default: "maybe"},
+		}, true},
+	}
+	for _, test := range tests {
+		template := &streamTemplateType{Parameters: test.parameters}
+		if err := template.validate(); (err != nil) != test.wantErr {
+			t.Errorf("%s: %v, want error: %t", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	var tests = []struct {
+		name         string
+		templateName string
+		parameters   map[string]streamParameterType
+		want         map[string]map[string]string // Stream: parameters.
+		wantErr      bool
+	}{
+		{"no matrix", "app/base", map[string]streamParameterType{
+			"DEBUG": {Default: "false"},
+		}, map[string]map[string]string{"app/base": {}}, false},
+		{"one dimension", "app/${ARCH}", map[string]streamParameterType{
+			"ARCH": {Values: []string{"amd64", "arm64"}},
+		}, map[string]map[string]string{
+			"app/amd64": {"ARCH": "amd64"},
+			"app/arm64": {"ARCH": "arm64"},
+		}, false},
+		{"two dimensions", "app/${OS}/${ARCH}",
+			map[string]streamParameterType{
+				"ARCH": {Values: []string{"amd64", "arm64"}},
+				"OS":   {Values: []string{"focal", "jammy"}},
+			}, map[string]map[string]string{
+				"app/focal/amd64": {"ARCH": "amd64", "OS": "focal"},
+				"app/focal/arm64": {"ARCH": "arm64", "OS": "focal"},
+				"app/jammy/amd64": {"ARCH": "amd64", "OS": "jammy"},
+				"app/jammy/arm64": {"ARCH": "arm64", "OS": "jammy"},
+			}, false},
+		{"duplicate name", "app/${ARCH}", map[string]streamParameterType{
+			"ARCH": {Values: []string{"amd64"}},
+			"OS":   {Values: []string{"focal", "jammy"}},
+		}, nil, true},
+		{"invalid", "app/${SIZE}", map[string]streamParameterType{
+			"SIZE": {Type: parameterTypeInteger, Values: []string{"big"}},
+		}, nil, true},
+	}
+	for _, test := range tests {
+		template := &streamTemplateType{
+			BuilderGroups: []string{"builders"},
+			ManifestUrl:   "git@example.com:manifests.git",
+			Parameters:    test.parameters,
+		}
+		streams, err := template.expand(nil, test.templateName)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: %v, want error: %t", test.name, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(streams) != len(test.want) {
+			t.Errorf("%s: got %d streams, want %d",
+				test.name, len(streams), len(test.want))
+		}
+		for streamName, parameters := range test.want {
+			stream, ok := streams[streamName]
+			if !ok {
+				t.Errorf("%s: missing stream: %s", test.name, streamName)
+				continue
+			}
+			if !reflect.DeepEqual(stream.parameters, parameters) {
+				t.Errorf("%s: %s: parameters: %v, want %v",
+					test.name, streamName, stream.parameters, parameters)
+			}
+			if stream.name != streamName ||
+				stream.templateName != test.templateName ||
+				stream.template != template ||
+				stream.ManifestUrl != template.ManifestUrl ||
+				!reflect.DeepEqual(stream.BuilderGroups,
+					template.BuilderGroups) {
+				t.Errorf("%s: %s: stream not set up from template",
+					test.name, streamName)
+			}
+		}
+	}
+}
+
+func TestExpandStreamTemplates(t *testing.T) {
+	b := &Builder{logger: testlogger.New(t)}
+	config := &imageStreamsConfigurationType{
+		Streams: map[string]*imageStreamType{"app/fixed": {}},
+		StreamTemplates: map[string]*streamTemplateType{
+			"app/${ARCH}": {
+				AutoRebuild:          true,
+				AutoRebuildInstances: map[string]bool{"app/arm64": false},
+				Parameters: map[string]streamParameterType{
+					"ARCH": {Values: []string{"amd64", "arm64"}},
+				},
+			},
+			"db/${VERSION}": {
+				AutoRebuildInstances: map[string]bool{"db/13": true},
+				Parameters: map[string]streamParameterType{
+					"VERSION": {Values: []string{"12", "13"}},
+				},
+			},
+			// Conflicts with a configured stream, so is skipped.
+			"app/${NAME}": {
+				AutoRebuild: true,
+				Parameters: map[string]streamParameterType{
+					"NAME": {Values: []string{"fixed", "other"}},
+				},
+			},
+		},
+	}
+	autoRebuild := b.expandStreamTemplates(config)
+	wantAutoRebuild := []string{"app/amd64", "db/13"}
+	if !reflect.DeepEqual(autoRebuild, wantAutoRebuild) {
+		t.Errorf("auto rebuild: %v, want %v", autoRebuild, wantAutoRebuild)
+	}
+	var streamNames []string
+	for streamName := range config.Streams {
+		streamNames = append(streamNames, streamName)
+	}
+	sort.Strings(streamNames)
+	wantStreamNames := []string{"app/amd64", "app/arm64", "app/fixed",
+		"db/12", "db/13"}
+	if !reflect.DeepEqual(streamNames, wantStreamNames) {
+		t.Errorf("streams: %v, want %v", streamNames, wantStreamNames)
+	}
+}
+
+func TestGetVariables(t *testing.T) {
+	template := &streamTemplateType{
+		Parameters: map[string]streamParameterType{
+			"ARCH":  {Values: []string{"amd64", "arm64"}},
+			"DEBUG": {Type: parameterTypeBoolean, Default: "false"},
+			"OWNER": {Required: true},
+		},
+	}
+	stream := &imageStreamType{
+		name:       "app/amd64",
+		parameters: map[string]string{"ARCH": "amd64"},
+		template:   template,
+	}
+	var tests = []struct {
+		name      string
+		variables map[string]string
+		want      map[string]string
+		wantErr   bool
+	}{
+		{"defaults", map[string]string{"OWNER": "me"},
+			map[string]string{"ARCH": "amd64", "DEBUG": "false",
+				"OWNER": "me"}, false},
+		{"override default",
+			map[string]string{"DEBUG": "true", "OWNER": "me", "X": "y"},
+			map[string]string{"ARCH": "amd64", "DEBUG": "true",
+				"OWNER": "me", "X": "y"}, false},
+		{"same matrix value",
+			map[string]string{"ARCH": "amd64", "OWNER": "me"},
+			map[string]string{"ARCH": "amd64", "DEBUG": "false",
+				"OWNER": "me"}, false},
+		{"override matrix",
+			map[string]string{"ARCH": "arm64", "OWNER": "me"}, nil, true},
+		{"invalid value",
+			map[string]string{"DEBUG": "maybe", "OWNER": "me"}, nil, true},
+		{"missing required", nil, nil, true},
+	}
+	for _, test := range tests {
+		variables, err := stream.getVariables(test.variables)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: %v, want error: %t", test.name, err, test.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(variables, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, variables, test.want)
+		}
+	}
+	// Streams without a template pass the variables through.
+	variables := map[string]string{"X": "y"}
+	got, err := (&imageStreamType{}).getVariables(variables)
+	if err != nil || !reflect.DeepEqual(got, variables) {
+		t.Errorf("no template: %v, %v", got, err)
+	}
+}
+
+func TestExpandManifest(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "templates-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	variableFunc := func(name string) string {
+		return map[string]string{"ARCH": "arm64"}[name]
+	}
+	var tests = []struct {
+		sourceImage string
+		want        string
+	}{
+		{"base/${ARCH}", "base/arm64"},
+		{"base/$ARCH/image", "base/arm64/image"},
+		{"base/amd64", "base/amd64"},
+	}
+	stream := &imageStreamType{template: &streamTemplateType{}}
+	manifestFile := filepath.Join(dirname, "manifest")
+	for _, test := range tests {
+		err := json.WriteToFile(manifestFile, 0644, "    ",
+			manifestConfigType{SourceImage: test.sourceImage})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.expandManifest(dirname, variableFunc); err != nil {
+			t.Fatal(err)
+		}
+		var manifestConfig manifestConfigType
+		if err := json.ReadFromFile(manifestFile, &manifestConfig); err != nil {
+			t.Fatal(err)
+		}
+		if manifestConfig.SourceImage != test.want {
+			t.Errorf("%s: expanded to: %s, want %s",
+				test.sourceImage, manifestConfig.SourceImage, test.want)
+		}
+	}
+	// A missing manifest file is not an error.
+	if err := stream.expandManifest(filepath.Join(dirname, "missing"),
+		variableFunc); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,196 @@
+package builder
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	stdlog "log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/filesystem/util"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/log/debuglogger"
+	"github.com/Symantec/Dominator/lib/mbr"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/tags"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+const vmTestsDirname = "vm-tests"
+
+var defaultTestVmHypervisorAddress = fmt.Sprintf("169.254.169.254:%d",
+	constants.HypervisorPortNumber)
+
+type vmTestsType struct {
+	configuration testVmConfigurationType
+	testsDir      string
+}
+
+// loadVmTests will return a vmTestsType if the manifest has a vm-tests
+// directory, else nil.
+func loadVmTests(manifestDir string, testVm *testVmConfigurationType) (
+	*vmTestsType, error) {
+	testsDir := filepath.Join(manifestDir, vmTestsDirname)
+	if fi, err := os.Stat(testsDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", vmTestsDirname)
+	}
+	if testVm == nil {
+		return nil,
+			errors.New("manifest has VM tests but no test VM configured")
+	}
+	vmTests := &vmTestsType{configuration: *testVm, testsDir: testsDir}
+	config := &vmTests.configuration
+	if config.BootTimeout < 1 {
+		config.BootTimeout = 120
+	}
+	if config.HypervisorAddress == "" {
+		config.HypervisorAddress = defaultTestVmHypervisorAddress
+	}
+	if config.MemoryInMiB < 1 {
+		config.MemoryInMiB = 1024
+	}
+	if config.MilliCPUs < 1 {
+		config.MilliCPUs = 1000
+	}
+	if config.MinimumFreeBytes < 1 {
+		config.MinimumFreeBytes = 256 << 20
+	}
+	if config.RoundupPower < 1 {
+		config.RoundupPower = 26
+	}
+	return vmTests, nil
+}
+
+func (vmTests *vmTestsType) createVm(client *srpc.Client,
+	fs *filesystem.FileSystem, streamName string, logger log.DebugLogger) (
+	*srpc.Client, net.IP, error) {
+	config := vmTests.configuration
+	file, err := ioutil.TempFile("",
+		strings.Replace(streamName, "/", "_", -1)+".raw")
+	if err != nil {
+		return nil, nil, err
+	}
+	rawFilename := file.Name()
+	file.Close()
+	defer os.Remove(rawFilename)
+	objClient := objectclient.AttachObjectClient(client)
+	err = util.WriteRaw(fs, objClient, rawFilename, 0600, mbr.TABLE_TYPE_MSDOS,
+		config.MinimumFreeBytes, config.RoundupPower, true, false, logger)
+	objClient.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error writing RAW image: %s", err)
+	}
+	if file, err = os.Open(rawFilename); err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	hyperClient, err := srpc.DialHTTP("tcp", config.HypervisorAddress,
+		time.Second*5)
+	if err != nil {
+		return nil, nil, err
+	}
+	hostname := "imaginator-test-" + strings.Replace(streamName, "/", "-", -1)
+	request := hyper_proto.CreateVmRequest{
+		DhcpTimeout:   time.Second * time.Duration(config.BootTimeout),
+		ImageDataSize: uint64(fi.Size()),
+		VmInfo: hyper_proto.VmInfo{
+			Hostname:    hostname,
+			MemoryInMiB: config.MemoryInMiB,
+			MilliCPUs:   config.MilliCPUs,
+			SubnetId:    config.SubnetId,
+			Tags:        tags.Tags{"Name": hostname},
+		},
+	}
+	var reply hyper_proto.CreateVmResponse
+	err = hyperclient.CreateVmWithData(hyperClient, request, &reply,
+		bufio.NewReader(io.LimitReader(file, fi.Size())), nil, logger)
+	if err != nil {
+		hyperClient.Close()
+		return nil, nil, err
+	}
+	err = hyperclient.AcknowledgeVm(hyperClient, reply.IpAddress)
+	if err != nil {
+		hyperclient.DestroyVm(hyperClient, reply.IpAddress, nil)
+		hyperClient.Close()
+		return nil, nil, fmt.Errorf("error acknowledging VM: %s", err)
+	}
+	if reply.DhcpTimedOut {
+		hyperclient.DestroyVm(hyperClient, reply.IpAddress, nil)
+		hyperClient.Close()
+		return nil, nil, fmt.Errorf("DHCP timeout for: %s", reply.IpAddress)
+	}
+	return hyperClient, reply.IpAddress, nil
+}
+
+// run will boot the image file-system in a throwaway VM and run the VM tests
+// (on the builder) against the running VM. The VM is destroyed afterwards.
+func (vmTests *vmTestsType) run(client *srpc.Client,
+	fs *filesystem.FileSystem, streamName string, buildLog buildLogger) (
+	[]proto.TestResult, error) {
+	testProgrammes, testConfigs, err := findTests(vmTests.testsDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(testProgrammes) < 1 {
+		return nil, nil
+	}
+	fmt.Fprintln(buildLog, "\nBooting test VM")
+	startTime := time.Now()
+	hyperClient, ipAddr, err := vmTests.createVm(client, fs, streamName,
+		debuglogger.New(stdlog.New(buildLog, "", 0)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating test VM: %s", err)
+	}
+	defer hyperClient.Close()
+	defer func() {
+		if err := hyperclient.DestroyVm(hyperClient, ipAddr, nil); err != nil {
+			fmt.Fprintf(buildLog, "Error destroying test VM: %s: %s\n",
+				ipAddr, err)
+		}
+	}()
+	fmt.Fprintf(buildLog, "Test VM: %s booted in %s\n",
+		ipAddr, format.Duration(time.Since(startTime)))
+	fmt.Fprintf(buildLog, "Running %d VM tests\n", len(testProgrammes))
+	prefixLength := len(filepath.Dir(vmTests.testsDir)) + 1
+	results := make(chan testResultType, 1)
+	for _, prog := range testProgrammes {
+		go func(prog string) {
+			results <- runTest(prog[prefixLength:],
+				getTestConfig(testConfigs, vmTests.testsDir, prog),
+				func(writer io.Writer) *exec.Cmd {
+					cmd := exec.Command(prog)
+					cmd.Dir = vmTests.testsDir
+					cmd.Env = append(os.Environ(),
+						"VM_IP_ADDRESS="+ipAddr.String())
+					cmd.Stdout = writer
+					cmd.Stderr = writer
+					cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+					return cmd
+				})
+		}(prog)
+	}
+	return collectTestResults(results, len(testProgrammes), true, buildLog)
+}
@@ -11,3 +11,7 @@ func BuildImage(client *srpc.Client, request proto.BuildImageRequest,
 	response *proto.BuildImageResponse, logWriter io.Writer) error {
 	return buildImage(client, request, response, logWriter)
 }
+
+func CancelBuild(client *srpc.Client, streamName string) (uint, error) {
+	return cancelBuild(client, streamName)
+}
@@ -45,3 +45,13 @@ func buildImage(client *srpc.Client, request proto.BuildImageRequest,
 		}
 	}
 }
+
+func cancelBuild(client *srpc.Client, streamName string) (uint, error) {
+	request := proto.CancelBuildRequest{StreamName: streamName}
+	var reply proto.CancelBuildResponse
+	err := client.RequestReply("Imaginator.CancelBuild", request, &reply)
+	if err != nil {
+		return 0, err
+	}
+	return reply.NumCancelled, errors.New(reply.Error)
+}
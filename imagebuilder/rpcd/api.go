@@ -6,13 +6,11 @@ import (
 	"github.com/Symantec/Dominator/imagebuilder/builder"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/srpc"
-	"github.com/Symantec/Dominator/lib/srpc/serverutil"
 )
 
 type srpcType struct {
 	builder *builder.Builder
 	logger  log.Logger
-	*serverutil.PerUserMethodLimiter
 }
 
 type htmlWriter srpcType
@@ -25,15 +23,12 @@ func Setup(builder *builder.Builder, logger log.Logger) (*htmlWriter, error) {
 	srpcObj := &srpcType{
 		builder: builder,
 		logger:  logger,
-		PerUserMethodLimiter: serverutil.NewPerUserMethodLimiter(
-			map[string]uint{
-				"BuildImage": 1,
-			}),
 	}
 	srpc.RegisterNameWithOptions("Imaginator", srpcObj,
 		srpc.ReceiverOptions{
 			PublicMethods: []string{
 				"BuildImage",
+				"CancelBuild",
 			}})
 	return (*htmlWriter)(srpcObj), nil
 }
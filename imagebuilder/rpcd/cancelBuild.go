@@ -0,0 +1,19 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/imaginator"
+)
+
+func (t *srpcType) CancelBuild(conn *srpc.Conn,
+	request proto.CancelBuildRequest,
+	reply *proto.CancelBuildResponse) error {
+	numCancelled, err := t.builder.CancelBuild(request.StreamName,
+		conn.GetAuthInformation())
+	*reply = proto.CancelBuildResponse{
+		NumCancelled: numCancelled,
+		Error:        errors.ErrorToString(err),
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"io"
+	"sync"
+
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+type notifiers map[<-chan imageserver.AuditEntry]chan<- imageserver.AuditEntry
+
+// Log is an append-only, hash-chained log of mutations. Entries are stored as
+// JSON lines and are also kept in memory.
+type Log struct {
+	filename    string
+	logger      log.DebugLogger
+	replica     bool
+	lock        sync.RWMutex // Protect everything below.
+	entries     []imageserver.AuditEntry
+	notifiers   notifiers
+	verifyError error
+}
+
+// Open will open the audit log in the image directory and verify the hash
+// chain. A broken chain is logged and reported by Verify, it is not treated as
+// an error. If replica is true the log mirrors the log of the replication
+// master and Record does nothing.
+func Open(imageDir string, replica bool, logger log.DebugLogger) (*Log, error) {
+	return openLog(imageDir, replica, logger)
+}
+
+// Append will append an entry replicated from another Log. The entry must
+// follow on from the last entry in the log.
+func (l *Log) Append(entry imageserver.AuditEntry) error {
+	return l.append(entry)
+}
+
+// Get will return the entries matching the request.
+func (l *Log) Get(
+	request imageserver.GetAuditLogRequest) []imageserver.AuditEntry {
+	return l.get(request)
+}
+
+// LastSequence returns the sequence number of the last entry, or 0 if the log
+// is empty.
+func (l *Log) LastSequence() uint64 {
+	return l.lastSequence()
+}
+
+// Record will fill in the sequence number, hashes and time (if not set) and
+// append the entry to the log. Nothing is recorded on a replica.
+func (l *Log) Record(entry imageserver.AuditEntry) error {
+	return l.record(entry)
+}
+
+// RegisterNotifier returns a channel which receives new entries.
+func (l *Log) RegisterNotifier() <-chan imageserver.AuditEntry {
+	return l.registerNotifier()
+}
+
+func (l *Log) UnregisterNotifier(channel <-chan imageserver.AuditEntry) {
+	l.unregisterNotifier(channel)
+}
+
+// Verify returns an error if the hash chain was found to be broken when the
+// log was loaded.
+func (l *Log) Verify() error {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.verifyError
+}
+
+func (l *Log) WriteHtml(writer io.Writer) {
+	l.writeHtml(writer)
+}
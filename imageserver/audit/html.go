@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+const (
+	maxHtmlEntries = 1000
+	timeFormat     = "02 Jan 2006 15:04:05 MST"
+)
+
+func (l *Log) writeHtml(writer io.Writer) {
+	l.lock.RLock()
+	numEntries := len(l.entries)
+	verifyError := l.verifyError
+	l.lock.RUnlock()
+	fmt.Fprintf(writer,
+		"Audit log: <a href=\"showAuditLog\">%d entries</a>", numEntries)
+	if verifyError != nil {
+		fmt.Fprintf(writer,
+			", <font color=\"red\">verification failed: %s</font>",
+			html.EscapeString(verifyError.Error()))
+	}
+	fmt.Fprintln(writer, "<br>")
+}
+
+func (l *Log) showAuditLogHandler(w http.ResponseWriter, req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	values := req.URL.Query()
+	request := imageserver.GetAuditLogRequest{
+		Name:      values.Get("name"),
+		Operation: values.Get("op"),
+		Username:  values.Get("user"),
+	}
+	if value := values.Get("start"); value != "" {
+		if start, err := strconv.ParseUint(value, 10, 64); err == nil {
+			request.StartSequence = start
+		}
+	}
+	entries := l.get(request)
+	fmt.Fprintln(writer, "<title>imageserver audit log</title>")
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>Audit log (newest first)</h3>")
+	if err := l.Verify(); err != nil {
+		fmt.Fprintf(writer,
+			"<font color=\"red\">Verification failed: %s</font><br>\n",
+			html.EscapeString(err.Error()))
+	}
+	if len(entries) > maxHtmlEntries {
+		fmt.Fprintf(writer, "Showing the last %d of %d entries<br>\n",
+			maxHtmlEntries, len(entries))
+		entries = entries[len(entries)-maxHtmlEntries:]
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Seq</th>")
+	fmt.Fprintln(writer, "    <th>Time</th>")
+	fmt.Fprintln(writer, "    <th>User</th>")
+	fmt.Fprintln(writer, "    <th>Address</th>")
+	fmt.Fprintln(writer, "    <th>Operation</th>")
+	fmt.Fprintln(writer, "    <th>Name</th>")
+	fmt.Fprintln(writer, "    <th>Detail</th>")
+	fmt.Fprintln(writer, "    <th>Outcome</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for index := len(entries) - 1; index >= 0; index-- {
+		entry := entries[index]
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%d</td>\n", entry.Sequence)
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			entry.Time.In(time.Local).Format(timeFormat))
+		fmt.Fprintf(writer,
+			"    <td><a href=\"showAuditLog?user=%s\">%s</a></td>\n",
+			url.QueryEscape(entry.Username), html.EscapeString(entry.Username))
+		fmt.Fprintf(writer, "    <td>%s</td>\n", entry.Address)
+		fmt.Fprintf(writer,
+			"    <td><a href=\"showAuditLog?op=%s\">%s</a></td>\n",
+			url.QueryEscape(entry.Operation),
+			html.EscapeString(entry.Operation))
+		fmt.Fprintf(writer,
+			"    <td><a href=\"showAuditLog?name=%s\">%s</a></td>\n",
+			url.QueryEscape(entry.Name), html.EscapeString(entry.Name))
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			html.EscapeString(entry.Detail))
+		if entry.Error == "" {
+			fmt.Fprintln(writer, "    <td>OK</td>")
+		} else {
+			fmt.Fprintf(writer,
+				"    <td><font color=\"red\">%s</font></td>\n",
+				html.EscapeString(entry.Error))
+		}
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
@@ -0,0 +1,213 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/html"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+const (
+	auditLogFile = ".audit-log"
+	filePerms    = 0644
+)
+
+func computeHash(entry imageserver.AuditEntry) string {
+	hasher := sha512.New()
+	fmt.Fprintf(hasher, "%d\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s",
+		entry.Sequence, entry.Time.UTC().Format(time.RFC3339Nano),
+		entry.Username, entry.Address, entry.Operation, entry.Name,
+		entry.Detail, entry.Error, entry.PreviousHash)
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// checkEntry will return an error if the entry does not follow on from the
+// previous entry or if the hash is wrong.
+func checkEntry(previous *imageserver.AuditEntry,
+	entry imageserver.AuditEntry) error {
+	var nextSequence uint64 = 1
+	var previousHash string
+	if previous != nil {
+		nextSequence = previous.Sequence + 1
+		previousHash = previous.Hash
+	}
+	if entry.Sequence != nextSequence {
+		return fmt.Errorf("sequence: %d, expected: %d",
+			entry.Sequence, nextSequence)
+	}
+	if entry.PreviousHash != previousHash {
+		return fmt.Errorf("entry: %d: previous hash mismatch", entry.Sequence)
+	}
+	if entry.Hash != computeHash(entry) {
+		return fmt.Errorf("entry: %d: hash mismatch", entry.Sequence)
+	}
+	return nil
+}
+
+func openLog(imageDir string, replica bool, logger log.DebugLogger) (
+	*Log, error) {
+	l := &Log{
+		filename:  filepath.Join(imageDir, auditLogFile),
+		logger:    logger,
+		notifiers: make(notifiers),
+		replica:   replica,
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	html.HandleFunc("/showAuditLog", l.showAuditLogHandler)
+	return l, nil
+}
+
+func (l *Log) load() error {
+	file, err := os.Open(l.filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for decoder.More() {
+		var entry imageserver.AuditEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return fmt.Errorf("error decoding: %s: %s", l.filename, err)
+		}
+		if l.verifyError == nil {
+			if err := checkEntry(l.lastEntry(), entry); err != nil {
+				l.verifyError = err
+				l.logger.Printf("Audit log: %s verification failed: %s\n",
+					l.filename, err)
+			}
+		}
+		l.entries = append(l.entries, entry)
+	}
+	return nil
+}
+
+// This must be called with the lock held.
+func (l *Log) lastEntry() *imageserver.AuditEntry {
+	if len(l.entries) < 1 {
+		return nil
+	}
+	return &l.entries[len(l.entries)-1]
+}
+
+func (l *Log) lastSequence() uint64 {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	if entry := l.lastEntry(); entry != nil {
+		return entry.Sequence
+	}
+	return 0
+}
+
+func (l *Log) record(entry imageserver.AuditEntry) error {
+	if l.replica {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	entry.Sequence = 1
+	entry.PreviousHash = ""
+	if previous := l.lastEntry(); previous != nil {
+		entry.Sequence = previous.Sequence + 1
+		entry.PreviousHash = previous.Hash
+	}
+	entry.Hash = computeHash(entry)
+	return l.write(entry)
+}
+
+func (l *Log) append(entry imageserver.AuditEntry) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := checkEntry(l.lastEntry(), entry); err != nil {
+		return err
+	}
+	return l.write(entry)
+}
+
+// This must be called with the lock held.
+func (l *Log) write(entry imageserver.AuditEntry) error {
+	file, err := os.OpenFile(l.filename,
+		os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePerms)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		file.Close()
+		return err
+	}
+	if err := fsutil.FsyncFile(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	l.entries = append(l.entries, entry)
+	for _, sendChannel := range l.notifiers {
+		go func(channel chan<- imageserver.AuditEntry) {
+			channel <- entry
+		}(sendChannel)
+	}
+	return nil
+}
+
+func matchEntry(request imageserver.GetAuditLogRequest,
+	entry imageserver.AuditEntry) bool {
+	if entry.Sequence < request.StartSequence {
+		return false
+	}
+	if request.Name != "" && entry.Name != request.Name {
+		return false
+	}
+	if request.Operation != "" && entry.Operation != request.Operation {
+		return false
+	}
+	if request.Username != "" && entry.Username != request.Username {
+		return false
+	}
+	return true
+}
+
+func (l *Log) get(
+	request imageserver.GetAuditLogRequest) []imageserver.AuditEntry {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	var entries []imageserver.AuditEntry
+	for _, entry := range l.entries {
+		if matchEntry(request, entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (l *Log) registerNotifier() <-chan imageserver.AuditEntry {
+	channel := make(chan imageserver.AuditEntry, 1)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.notifiers[channel] = channel
+	return channel
+}
+
+func (l *Log) unregisterNotifier(channel <-chan imageserver.AuditEntry) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.notifiers, channel)
+}
@@ -0,0 +1,251 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func makeTestLog(t *testing.T, dirname string, replica bool) *Log {
+	l := &Log{
+		filename:  filepath.Join(dirname, auditLogFile),
+		logger:    testlogger.New(t),
+		notifiers: make(notifiers),
+		replica:   replica,
+	}
+	if err := l.load(); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func makeTestEntries(t *testing.T, dirname string) []imageserver.AuditEntry {
+	l := makeTestLog(t, dirname, false)
+	for _, entry := range []imageserver.AuditEntry{
+		{Username: "alice", Operation: "AddImage", Name: "app/v1"},
+		{Username: "bob", Operation: "DeleteImage", Name: "app/v0",
+			Error: "permission denied"},
+		{Operation: imageserver.AuditOperationExpireImage, Name: "tmp/v1",
+			Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)},
+	} {
+		if err := l.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l.entries
+}
+
+func TestCheckEntry(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "audit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	entries := makeTestEntries(t, dirname)
+	var tests = []struct {
+		name     string
+		previous *imageserver.AuditEntry
+		modify   func(entry *imageserver.AuditEntry)
+		index    int
+		wantErr  string
+	}{
+		{"first", nil, nil, 0, ""},
+		{"second", &entries[0], nil, 1, ""},
+		{"not first", nil, nil, 1, "sequence: 2, expected: 1"},
+		{"skipped", &entries[0], nil, 2, "sequence: 3, expected: 2"},
+		{"previous hash", &entries[1], func(entry *imageserver.AuditEntry) {
+			entry.PreviousHash = entries[0].Hash
+		}, 2, "previous hash mismatch"},
+		{"tampered name", &entries[0], func(entry *imageserver.AuditEntry) {
+			entry.Name = "app/v1"
+		}, 1, "hash mismatch"},
+		{"tampered error", &entries[0], func(entry *imageserver.AuditEntry) {
+			entry.Error = ""
+		}, 1, "hash mismatch"},
+		{"tampered time", &entries[1], func(entry *imageserver.AuditEntry) {
+			entry.Time = entry.Time.Add(time.Second)
+		}, 2, "hash mismatch"},
+		{"rehashed", &entries[0], func(entry *imageserver.AuditEntry) {
+			entry.Username = "mallory"
+			entry.Hash = computeHash(*entry)
+		}, 1, ""}, // Detected by the following entry.
+	}
+	for _, test := range tests {
+		entry := entries[test.index]
+		if test.modify != nil {
+			test.modify(&entry)
+		}
+		err := checkEntry(test.previous, entry)
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: error: %v, want: %s", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestVerifyOnLoad(t *testing.T) {
+	var tests = []struct {
+		name    string
+		modify  func(entries []imageserver.AuditEntry) []imageserver.AuditEntry
+		wantErr string
+	}{
+		{"intact", nil, ""},
+		{"tampered record",
+			func(entries []imageserver.AuditEntry) []imageserver.AuditEntry {
+				entries[1].Username = "mallory"
+				return entries
+			}, "entry: 2: hash mismatch"},
+		{"rehashed record",
+			func(entries []imageserver.AuditEntry) []imageserver.AuditEntry {
+				entries[1].Error = ""
+				entries[1].Hash = computeHash(entries[1])
+				return entries
+			}, "entry: 3: previous hash mismatch"},
+		{"deleted record",
+			func(entries []imageserver.AuditEntry) []imageserver.AuditEntry {
+				return append(entries[:1], entries[2:]...)
+			}, "sequence: 3, expected: 2"},
+		{"truncated log",
+			func(entries []imageserver.AuditEntry) []imageserver.AuditEntry {
+				return entries[:2]
+			}, ""},
+	}
+	for _, test := range tests {
+		dirname, err := ioutil.TempDir("", "audit-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dirname)
+		entries := makeTestEntries(t, dirname)
+		if test.modify != nil {
+			entries = test.modify(entries)
+			writeTestEntries(t, filepath.Join(dirname, auditLogFile), entries)
+		}
+		l := makeTestLog(t, dirname, false)
+		err = l.Verify()
+		if test.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.name, err)
+			}
+		} else if err == nil || err.Error() != test.wantErr {
+			t.Errorf("%s: error: %v, want: %s", test.name, err, test.wantErr)
+		}
+		if len(l.entries) != len(entries) {
+			t.Errorf("%s: loaded %d entries, want %d",
+				test.name, len(l.entries), len(entries))
+		}
+	}
+}
+
+func writeTestEntries(t *testing.T, filename string,
+	entries []imageserver.AuditEntry) {
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "audit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	entries := makeTestEntries(t, dirname)
+	replicaDirname := filepath.Join(dirname, "replica")
+	if err := os.Mkdir(replicaDirname, 0755); err != nil {
+		t.Fatal(err)
+	}
+	replica := makeTestLog(t, replicaDirname, true)
+	// Replicas only mirror the master.
+	if err := replica.Record(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if replica.LastSequence() != 0 {
+		t.Fatal("entry recorded on replica")
+	}
+	if err := replica.Append(entries[1]); err == nil {
+		t.Error("no error appending out of sequence entry")
+	}
+	tampered := entries[0]
+	tampered.Name = "other"
+	if err := replica.Append(tampered); err == nil {
+		t.Error("no error appending tampered entry")
+	}
+	for _, entry := range entries {
+		if err := replica.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reloaded := makeTestLog(t, replicaDirname, true)
+	if err := reloaded.Verify(); err != nil {
+		t.Error(err)
+	}
+	if reloaded.LastSequence() != uint64(len(entries)) {
+		t.Errorf("last sequence: %d, want %d",
+			reloaded.LastSequence(), len(entries))
+	}
+}
+
+func TestGet(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "audit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	makeTestEntries(t, dirname)
+	l := makeTestLog(t, dirname, false)
+	var tests = []struct {
+		request imageserver.GetAuditLogRequest
+		want    []uint64
+	}{
+		{imageserver.GetAuditLogRequest{}, []uint64{1, 2, 3}},
+		{imageserver.GetAuditLogRequest{StartSequence: 2}, []uint64{2, 3}},
+		{imageserver.GetAuditLogRequest{Name: "app/v1"}, []uint64{1}},
+		{imageserver.GetAuditLogRequest{Username: "bob"}, []uint64{2}},
+		{imageserver.GetAuditLogRequest{
+			Operation: imageserver.AuditOperationExpireImage,
+		}, []uint64{3}},
+		{imageserver.GetAuditLogRequest{Name: "app/v1", Username: "bob"},
+			nil},
+	}
+	for _, test := range tests {
+		var sequences []uint64
+		for _, entry := range l.Get(test.request) {
+			sequences = append(sequences, entry.Sequence)
+		}
+		if len(sequences) != len(test.want) {
+			t.Errorf("%+v: %v, want %v", test.request, sequences, test.want)
+			continue
+		}
+		for index := range sequences {
+			if sequences[index] != test.want[index] {
+				t.Errorf("%+v: %v, want %v",
+					test.request, sequences, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func getImageAlias(client *srpc.Client, aliasName string) (*image.Alias,
+	error) {
+	request := imageserver.ResolveImageAliasRequest{AliasName: aliasName}
+	var reply imageserver.ResolveImageAliasResponse
+	err := client.RequestReply("ImageServer.ResolveImageAlias", request,
+		&reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Alias, nil
+}
+
+func resolveImageName(client *srpc.Client, name string) (string, error) {
+	if exists, err := checkImage(client, name); err != nil {
+		return "", err
+	} else if exists {
+		return name, nil
+	}
+	alias, err := getImageAlias(client, name)
+	if err != nil {
+		return "", err
+	}
+	if alias == nil {
+		return "", errors.New("image or alias: " + name + " does not exist")
+	}
+	return alias.ImageName, nil
+}
+
+func setImageAlias(client *srpc.Client, aliasName, imageName,
+	ownerGroup string) error {
+	request := imageserver.SetImageAliasRequest{
+		AliasName:  aliasName,
+		ImageName:  imageName,
+		OwnerGroup: ownerGroup,
+	}
+	var reply imageserver.SetImageAliasResponse
+	err := client.RequestReply("ImageServer.SetImageAlias", request, &reply)
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	return err
+}
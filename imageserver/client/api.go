@@ -5,7 +5,9 @@ import (
 
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/diff"
 	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
 )
 
 func AddImage(client *srpc.Client, name string, img *image.Image) error {
@@ -33,6 +35,15 @@ func ChownDirectory(client *srpc.Client, dirname, ownerGroup string) error {
 	return chownDirectory(client, dirname, ownerGroup)
 }
 
+// CreateUploadLease will create a lease which protects objects from the
+// garbage collector while an image is being uploaded. Objects added after the
+// lease is created are protected, as are the specified objects. The lease ID
+// and expiration time are returned.
+func CreateUploadLease(client *srpc.Client, objects []hash.Hash,
+	timeout time.Duration) (string, time.Time, error) {
+	return createUploadLease(client, objects, timeout)
+}
+
 func DeleteImage(client *srpc.Client, name string) error {
 	return deleteImage(client, name)
 }
@@ -42,15 +53,49 @@ func DeleteUnreferencedObjects(client *srpc.Client, percentage uint8,
 	return deleteUnreferencedObjects(client, percentage, bytes)
 }
 
+// DiffImages will compute the differences between two images on the server.
+func DiffImages(client *srpc.Client, leftName, rightName string) (
+	*diff.Diff, error) {
+	return diffImages(client, leftName, rightName)
+}
+
+// ExtendUploadLease will add objects to an upload lease and extend its
+// expiration time. The new expiration time is returned.
+func ExtendUploadLease(client *srpc.Client, leaseId string,
+	objects []hash.Hash, timeout time.Duration) (time.Time, error) {
+	return extendUploadLease(client, leaseId, objects, timeout)
+}
+
+// FindImages will return the images which match all the criteria in the
+// request.
+func FindImages(client *srpc.Client,
+	request imageserver.FindImagesRequest) ([]imageserver.FoundImage, error) {
+	return findImages(client, request)
+}
+
 func FindLatestImage(client *srpc.Client, dirname string,
 	ignoreExpiring bool) (string, error) {
 	return findLatestImage(client, dirname, ignoreExpiring)
 }
 
+// GetAuditLog will return the audit log entries matching the request. The
+// Follow field is ignored.
+func GetAuditLog(client *srpc.Client, request imageserver.GetAuditLogRequest) (
+	[]imageserver.AuditEntry, error) {
+	return getAuditLog(client, request)
+}
+
 func GetImage(client *srpc.Client, name string) (*image.Image, error) {
 	return getImage(client, name, 0)
 }
 
+// GetImageAlias will return the named alias, including its history. If the
+// alias does not exist nil is returned.
+func GetImageAlias(client *srpc.Client, aliasName string) (*image.Alias,
+	error) {
+	return getImageAlias(client, aliasName)
+}
+
 func GetImageExpiration(client *srpc.Client, name string) (time.Time, error) {
 	return getImageExpiration(client, name)
 }
@@ -60,6 +105,15 @@ func GetImageWithTimeout(client *srpc.Client, name string,
 	return getImage(client, name, timeout)
 }
 
+// GetUsage will return the storage usage per directory and per owner group.
+func GetUsage(client *srpc.Client) (imageserver.GetUsageResponse, error) {
+	return getUsage(client)
+}
+
+func ListAliases(client *srpc.Client) ([]image.Alias, error) {
+	return listAliases(client)
+}
+
 func ListDirectories(client *srpc.Client) ([]image.Directory, error) {
 	return listDirectories(client)
 }
@@ -76,3 +130,36 @@ func ListUnreferencedObjects(client *srpc.Client) (
 func MakeDirectory(client *srpc.Client, dirname string) error {
 	return makeDirectory(client, dirname)
 }
+
+// ResolveImageName will return the name of the image that name refers to. If
+// name is an image it is returned unchanged, else if it is an alias the name of
+// the image the alias currently refers to is returned.
+func ResolveImageName(client *srpc.Client, name string) (string, error) {
+	return resolveImageName(client, name)
+}
+
+// ReleaseUploadLease will release an upload lease.
+func ReleaseUploadLease(client *srpc.Client, leaseId string) error {
+	return releaseUploadLease(client, leaseId)
+}
+
+// SetDirectoryQuota will set the soft and hard quotas (in bytes) for a
+// directory and its subdirectories. A quota of zero means no quota.
+func SetDirectoryQuota(client *srpc.Client, dirname string,
+	softQuotaBytes, hardQuotaBytes uint64) error {
+	return setDirectoryQuota(client, dirname, softQuotaBytes, hardQuotaBytes)
+}
+
+// SetImageAlias will create or move an alias to an image. If ownerGroup is not
+// empty the owner group of the alias is changed.
+func SetImageAlias(client *srpc.Client, aliasName, imageName,
+	ownerGroup string) error {
+	return setImageAlias(client, aliasName, imageName, ownerGroup)
+}
+
+// VerifyImageObjects will check that the objects for the specified images (or
+// all images if none are specified) are present on the server.
+func VerifyImageObjects(client *srpc.Client, imageNames []string) (
+	imageserver.VerifyImageObjectsResponse, error) {
+	return verifyImageObjects(client, imageNames)
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func getAuditLog(client *srpc.Client,
+	request imageserver.GetAuditLogRequest) ([]imageserver.AuditEntry, error) {
+	request.Follow = false
+	conn, err := client.Call("ImageServer.GetAuditLog")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.Encode(request); err != nil {
+		return nil, err
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	var entries []imageserver.AuditEntry
+	for {
+		var entry imageserver.AuditEntry
+		if err := conn.Decode(&entry); err != nil {
+			return nil, err
+		}
+		if entry.Sequence < 1 {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
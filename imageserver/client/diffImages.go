@@ -0,0 +1,25 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/image/diff"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func diffImages(client *srpc.Client, leftName, rightName string) (
+	*diff.Diff, error) {
+	request := imageserver.DiffImagesRequest{
+		LeftImageName:  leftName,
+		RightImageName: rightName,
+	}
+	var reply imageserver.DiffImagesResponse
+	err := client.RequestReply("ImageServer.DiffImages", request, &reply)
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reply.Diff, nil
+}
@@ -0,0 +1,20 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func findImages(client *srpc.Client,
+	request imageserver.FindImagesRequest) ([]imageserver.FoundImage, error) {
+	var reply imageserver.FindImagesResponse
+	err := client.RequestReply("ImageServer.FindImages", request, &reply)
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reply.Images, nil
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func listAliases(client *srpc.Client) ([]image.Alias, error) {
+	conn, err := client.Call("ImageServer.ListAliases")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	aliases := make([]image.Alias, 0)
+	for {
+		var alias image.Alias
+		if err := conn.Decode(&alias); err != nil {
+			return nil, err
+		}
+		if alias.Name == "" {
+			break
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, nil
+}
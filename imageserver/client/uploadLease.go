@@ -0,0 +1,55 @@
+package client
+
+import (
+	"time"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func createUploadLease(client *srpc.Client, objects []hash.Hash,
+	timeout time.Duration) (string, time.Time, error) {
+	request := imageserver.CreateUploadLeaseRequest{
+		Objects: objects,
+		Timeout: timeout,
+	}
+	var reply imageserver.CreateUploadLeaseResponse
+	err := client.RequestReply("ImageServer.CreateUploadLease", request,
+		&reply)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return "", time.Time{}, err
+	}
+	return reply.LeaseId, reply.ExpiresAt, nil
+}
+
+func extendUploadLease(client *srpc.Client, leaseId string,
+	objects []hash.Hash, timeout time.Duration) (time.Time, error) {
+	request := imageserver.ExtendUploadLeaseRequest{
+		LeaseId: leaseId,
+		Objects: objects,
+		Timeout: timeout,
+	}
+	var reply imageserver.ExtendUploadLeaseResponse
+	err := client.RequestReply("ImageServer.ExtendUploadLease", request,
+		&reply)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return reply.ExpiresAt, errors.New(reply.Error)
+}
+
+func releaseUploadLease(client *srpc.Client, leaseId string) error {
+	request := imageserver.ReleaseUploadLeaseRequest{LeaseId: leaseId}
+	var reply imageserver.ReleaseUploadLeaseResponse
+	err := client.RequestReply("ImageServer.ReleaseUploadLease", request,
+		&reply)
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	return err
+}
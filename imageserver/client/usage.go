@@ -0,0 +1,30 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func getUsage(client *srpc.Client) (imageserver.GetUsageResponse, error) {
+	var reply imageserver.GetUsageResponse
+	err := client.RequestReply("ImageServer.GetUsage",
+		imageserver.GetUsageRequest{}, &reply)
+	return reply, err
+}
+
+func setDirectoryQuota(client *srpc.Client, dirname string,
+	softQuotaBytes, hardQuotaBytes uint64) error {
+	request := imageserver.SetDirectoryQuotaRequest{
+		DirectoryName:  dirname,
+		HardQuotaBytes: hardQuotaBytes,
+		SoftQuotaBytes: softQuotaBytes,
+	}
+	var reply imageserver.SetDirectoryQuotaResponse
+	err := client.RequestReply("ImageServer.SetDirectoryQuota", request,
+		&reply)
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	return err
+}
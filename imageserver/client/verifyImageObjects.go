@@ -0,0 +1,19 @@
+package client
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func verifyImageObjects(client *srpc.Client, imageNames []string) (
+	imageserver.VerifyImageObjectsResponse, error) {
+	request := imageserver.VerifyImageObjectsRequest{ImageNames: imageNames}
+	var reply imageserver.VerifyImageObjectsResponse
+	err := client.RequestReply("ImageServer.VerifyImageObjects", request,
+		&reply)
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	return reply, err
+}
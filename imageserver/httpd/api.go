@@ -30,6 +30,8 @@ func StartServer(portNum uint, imdb *scanner.ImageDataBase,
 	}
 	myState := state{imageDataBase: imdb, objectServer: objSrv}
 	html.HandleFunc("/", statusHandler)
+	html.HandleFunc("/diffImages", myState.diffImagesHandler)
+	html.HandleFunc("/listAliases", myState.listAliasesHandler)
 	html.HandleFunc("/listBuildLog", myState.listBuildLogHandler)
 	html.HandleFunc("/listComputedInodes", myState.listComputedInodesHandler)
 	html.HandleFunc("/listDirectories", myState.listDirectoriesHandler)
@@ -38,8 +40,11 @@ func StartServer(portNum uint, imdb *scanner.ImageDataBase,
 	html.HandleFunc("/listImages", myState.listImagesHandler)
 	html.HandleFunc("/listPackages", myState.listPackagesHandler)
 	html.HandleFunc("/listReleaseNotes", myState.listReleaseNotesHandler)
+	html.HandleFunc("/listTestResults", myState.listTestResultsHandler)
 	html.HandleFunc("/listTriggers", myState.listTriggersHandler)
+	html.HandleFunc("/showAlias", myState.showAliasHandler)
 	html.HandleFunc("/showImage", myState.showImageHandler)
+	html.HandleFunc("/showUsage", myState.showUsageHandler)
 	if daemon {
 		go http.Serve(listener, nil)
 	} else {
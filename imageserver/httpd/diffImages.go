@@ -0,0 +1,266 @@
+package httpd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/diff"
+	"github.com/Symantec/Dominator/lib/triggers"
+)
+
+func (s state) diffImagesHandler(w http.ResponseWriter, req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	values := req.URL.Query()
+	leftName := values.Get("left")
+	rightName := values.Get("right")
+	result, err := s.diffImages(leftName, rightName)
+	if values.Get("output") == "json" {
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintln(writer, err)
+			return
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "    ")
+		encoder.Encode(result)
+		return
+	}
+	fmt.Fprintf(writer, "<title>diff %s %s</title>\n", leftName, rightName)
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	fmt.Fprintf(writer,
+		"Changes from <a href=\"showImage?%s\">%s</a> "+
+			"to <a href=\"showImage?%s\">%s</a>\n",
+		leftName, leftName, rightName, rightName)
+	fmt.Fprintln(writer, "</h3>")
+	if err != nil {
+		fmt.Fprintf(writer, "<font color=\"red\">%s</font><br>\n",
+			html.EscapeString(err.Error()))
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	fmt.Fprintf(writer,
+		"<a href=\"diffImages?left=%s&right=%s&output=json\">JSON</a><br>\n",
+		leftName, rightName)
+	if result.IsEmpty() {
+		fmt.Fprintln(writer, "No differences<br>")
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	fmt.Fprintf(writer, "Paths added: %d, removed: %d, changed: %d<br>\n",
+		len(result.Added), len(result.Removed), len(result.Changed))
+	fmt.Fprintf(writer, "Data added: %s, removed: %s, new objects: %s<br>\n",
+		format.FormatBytes(result.AddedBytes),
+		format.FormatBytes(result.RemovedBytes),
+		format.FormatBytes(result.NewObjectBytes))
+	writePackageChanges(writer, result.Packages)
+	writeFilterChange(writer, result.Filter)
+	writeTriggerChanges(writer, result.Triggers)
+	writePaths(writer, "Added paths", result.Added)
+	writePaths(writer, "Removed paths", result.Removed)
+	writePathChanges(writer, result.Changed)
+	fmt.Fprintln(writer, "</body>")
+}
+
+func (s state) diffImages(leftName, rightName string) (*diff.Diff, error) {
+	if leftName == "" || rightName == "" {
+		return nil, errors.New("left and right images required")
+	}
+	leftName, err := s.imageDataBase.ResolveImageName(leftName)
+	if err != nil {
+		return nil, err
+	}
+	rightName, err = s.imageDataBase.ResolveImageName(rightName)
+	if err != nil {
+		return nil, err
+	}
+	left := s.imageDataBase.GetImage(leftName)
+	if left == nil {
+		return nil, errors.New("image: " + leftName + " does not exist")
+	}
+	right := s.imageDataBase.GetImage(rightName)
+	if right == nil {
+		return nil, errors.New("image: " + rightName + " does not exist")
+	}
+	return diff.Compute(left, right), nil
+}
+
+// findPreviousImage returns the latest image in the same directory which was
+// created before the specified image, or "" if there is none.
+func (s state) findPreviousImage(imageName string) string {
+	img := s.imageDataBase.GetImageMetadata(imageName)
+	if img == nil {
+		return ""
+	}
+	dirname := filepath.Dir(imageName)
+	var previousName string
+	var previous *image.Image
+	for _, name := range s.imageDataBase.ListImages() {
+		if name == imageName || filepath.Dir(name) != dirname {
+			continue
+		}
+		candidate := s.imageDataBase.GetImageMetadata(name)
+		if candidate == nil || !candidate.CreatedOn.Before(img.CreatedOn) {
+			continue
+		}
+		if previous == nil || candidate.CreatedOn.After(previous.CreatedOn) {
+			previousName = name
+			previous = candidate
+		}
+	}
+	return previousName
+}
+
+func writeFilterChange(writer io.Writer, change diff.FilterChange) {
+	if change.LeftIsNil != change.RightIsNil {
+		if change.RightIsNil {
+			fmt.Fprintln(writer, "<p>Filter removed: image is now sparse<br>")
+		} else {
+			fmt.Fprintln(writer,
+				"<p>Filter added: image is no longer sparse<br>")
+		}
+	}
+	if len(change.AddedLines) < 1 && len(change.RemovedLines) < 1 {
+		return
+	}
+	fmt.Fprintln(writer, "<h4>Filter changes</h4>")
+	fmt.Fprintln(writer, "<pre>")
+	for _, line := range change.RemovedLines {
+		fmt.Fprintf(writer, "- %s\n", html.EscapeString(line))
+	}
+	for _, line := range change.AddedLines {
+		fmt.Fprintf(writer, "+ %s\n", html.EscapeString(line))
+	}
+	fmt.Fprintln(writer, "</pre>")
+}
+
+func writePackageChanges(writer io.Writer, changes []diff.PackageChange) {
+	if len(changes) < 1 {
+		return
+	}
+	fmt.Fprintln(writer, "<h4>Package changes</h4>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Name</th>")
+	fmt.Fprintln(writer, "    <th>Old Version</th>")
+	fmt.Fprintln(writer, "    <th>New Version</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, change := range changes {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", change.Name)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", change.LeftVersion)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", change.RightVersion)
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+}
+
+func writePathChanges(writer io.Writer, changes []diff.PathChange) {
+	if len(changes) < 1 {
+		return
+	}
+	fmt.Fprintln(writer, "<h4>Changed paths</h4>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Path</th>")
+	fmt.Fprintln(writer, "    <th>Attribute</th>")
+	fmt.Fprintln(writer, "    <th>Old</th>")
+	fmt.Fprintln(writer, "    <th>New</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, change := range changes {
+		for index, attribute := range change.Changes {
+			fmt.Fprintln(writer, "  <tr>")
+			if index == 0 {
+				fmt.Fprintf(writer, "    <td rowspan=\"%d\">%s</td>\n",
+					len(change.Changes), html.EscapeString(change.Name))
+			}
+			fmt.Fprintf(writer, "    <td>%s</td>\n", attribute.Attribute)
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				html.EscapeString(attribute.Left))
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				html.EscapeString(attribute.Right))
+			fmt.Fprintln(writer, "  </tr>")
+		}
+	}
+	fmt.Fprintln(writer, "</table>")
+}
+
+func writePaths(writer io.Writer, title string, paths []diff.Path) {
+	if len(paths) < 1 {
+		return
+	}
+	fmt.Fprintf(writer, "<h4>%s</h4>\n", title)
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Path</th>")
+	fmt.Fprintln(writer, "    <th>Type</th>")
+	fmt.Fprintln(writer, "    <th>Size</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, path := range paths {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", html.EscapeString(path.Name))
+		fmt.Fprintf(writer, "    <td>%s</td>\n", path.Type)
+		if path.Type == "file" {
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				format.FormatBytes(path.Size))
+		} else {
+			fmt.Fprintln(writer, "    <td></td>")
+		}
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+}
+
+func formatTrigger(trigger *triggers.Trigger) string {
+	if trigger == nil {
+		return ""
+	}
+	lines := make([]string, 0, len(trigger.MatchLines))
+	for _, line := range trigger.MatchLines {
+		lines = append(lines, html.EscapeString(line))
+	}
+	text := strings.Join(lines, "<br>")
+	if trigger.DoReboot {
+		text += "<br>(reboot)"
+	}
+	if trigger.HighImpact {
+		text += "<br>(high impact)"
+	}
+	return text
+}
+
+func writeTriggerChanges(writer io.Writer, changes []diff.TriggerChange) {
+	if len(changes) < 1 {
+		return
+	}
+	fmt.Fprintln(writer, "<h4>Trigger changes</h4>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Service</th>")
+	fmt.Fprintln(writer, "    <th>Old</th>")
+	fmt.Fprintln(writer, "    <th>New</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, change := range changes {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", change.Service)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", formatTrigger(change.Left))
+		fmt.Fprintf(writer, "    <td>%s</td>\n", formatTrigger(change.Right))
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+}
@@ -0,0 +1,94 @@
+package httpd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/verstr"
+)
+
+func (s state) listAliasesHandler(w http.ResponseWriter, req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	aliases := s.imageDataBase.ListAliases()
+	sort.Slice(aliases, func(left, right int) bool {
+		return verstr.Less(aliases[left].Name, aliases[right].Name)
+	})
+	if req.URL.RawQuery == "output=text" {
+		for _, alias := range aliases {
+			fmt.Fprintf(writer, "%s %s\n", alias.Name, alias.ImageName)
+		}
+		return
+	}
+	fmt.Fprintln(writer, "<title>imageserver aliases</title>")
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Name</th>")
+	fmt.Fprintln(writer, "    <th>Image</th>")
+	fmt.Fprintln(writer, "    <th>Owner Group</th>")
+	fmt.Fprintln(writer, "    <th>Moves</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, alias := range aliases {
+		fmt.Fprintf(writer, "  <tr>\n")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", alias.Name)
+		fmt.Fprintf(writer, "    <td><a href=\"showImage?%s\">%s</a></td>\n",
+			alias.ImageName, alias.ImageName)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", alias.OwnerGroup)
+		fmt.Fprintf(writer,
+			"    <td><a href=\"showAlias?%s\">%d</a></td>\n",
+			alias.Name, len(alias.History))
+		fmt.Fprintf(writer, "  </tr>\n")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
+
+func (s state) showAliasHandler(w http.ResponseWriter, req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	aliasName := req.URL.RawQuery
+	fmt.Fprintf(writer, "<title>alias %s</title>\n", aliasName)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	alias, ok := s.imageDataBase.GetAlias(aliasName)
+	if !ok {
+		fmt.Fprintf(writer, "Alias: %s UNKNOWN!\n", aliasName)
+		return
+	}
+	fmt.Fprintf(writer, "History for alias: %s<br>\n", aliasName)
+	fmt.Fprintln(writer, "</h3>")
+	fmt.Fprintf(writer, "Owner group: %s<br>\n", alias.OwnerGroup)
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Image</th>")
+	fmt.Fprintln(writer, "    <th>Moved At</th>")
+	fmt.Fprintln(writer, "    <th>Moved By</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for index := len(alias.History) - 1; index >= 0; index-- {
+		showAliasChange(writer, alias.History[index])
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
+
+func showAliasChange(writer io.Writer, change image.AliasChange) {
+	fmt.Fprintf(writer, "  <tr>\n")
+	fmt.Fprintf(writer, "    <td><a href=\"showImage?%s\">%s</a></td>\n",
+		change.ImageName, change.ImageName)
+	fmt.Fprintf(writer, "    <td>%s</td>\n",
+		change.MovedAt.In(time.Local).Format(timeFormat))
+	fmt.Fprintf(writer, "    <td>%s</td>\n", change.MovedBy)
+	fmt.Fprintf(writer, "  </tr>\n")
+}
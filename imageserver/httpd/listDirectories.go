@@ -33,6 +33,8 @@ func (s state) listDirectoriesHandler(w http.ResponseWriter,
 	fmt.Fprintln(writer, "  <tr>")
 	fmt.Fprintln(writer, "    <th>Name</th>")
 	fmt.Fprintln(writer, "    <th>Owner Group</th>")
+	fmt.Fprintln(writer, "    <th>Soft Quota</th>")
+	fmt.Fprintln(writer, "    <th>Hard Quota</th>")
 	fmt.Fprintln(writer, "  </tr>")
 	for _, directory := range directories {
 		showDirectory(writer, directory)
@@ -45,5 +47,7 @@ func showDirectory(writer io.Writer, directory image.Directory) {
 	fmt.Fprintf(writer, "  <tr>\n")
 	fmt.Fprintf(writer, "    <td>%s</td>\n", directory.Name)
 	fmt.Fprintf(writer, "    <td>%s</td>\n", directory.Metadata.OwnerGroup)
+	writeQuota(writer, directory.Metadata.SoftQuotaBytes)
+	writeQuota(writer, directory.Metadata.HardQuotaBytes)
 	fmt.Fprintf(writer, "  </tr>\n")
 }
@@ -21,7 +21,7 @@ func (s state) listPackagesHandler(w http.ResponseWriter, req *http.Request) {
 	for name := range parsedQuery.Flags {
 		imageName = name
 	}
-	image := s.imageDataBase.GetImage(imageName)
+	image := s.imageDataBase.GetImageMetadata(imageName)
 	if image == nil {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(http.StatusNotFound)
@@ -14,7 +14,7 @@ func (s state) listReleaseNotesHandler(w http.ResponseWriter,
 	fmt.Fprintf(writer, "<title>image %s</title>\n", imageName)
 	fmt.Fprintln(writer, "<body>")
 	fmt.Fprintln(writer, "<h3>")
-	image := s.imageDataBase.GetImage(imageName)
+	image := s.imageDataBase.GetImageMetadata(imageName)
 	if image == nil {
 		fmt.Fprintf(writer, "Image: %s UNKNOWN!\n", imageName)
 		return
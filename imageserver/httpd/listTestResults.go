@@ -0,0 +1,34 @@
+package httpd
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+)
+
+func (s state) listTestResultsHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	imageName := req.URL.RawQuery
+	fmt.Fprintf(writer, "<title>image %s</title>\n", imageName)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	image := s.imageDataBase.GetImageMetadata(imageName)
+	if image == nil {
+		fmt.Fprintf(writer, "Image: %s UNKNOWN!\n", imageName)
+		return
+	}
+	if image.TestResults == nil {
+		fmt.Fprintf(writer, "No test results for image: %s\n", imageName)
+		return
+	}
+	if image.TestResults.Object == nil {
+		fmt.Fprintf(writer, "No test results data for image: %s\n", imageName)
+		return
+	}
+	fmt.Fprintf(writer, "Test results for image: %s<br>\n", imageName)
+	fmt.Fprintln(writer, "</h3>")
+	listObject(writer, s.objectServer, image.TestResults.Object)
+	fmt.Fprintln(writer, "</body>")
+}
@@ -15,7 +15,7 @@ func (s state) listTriggersHandler(w http.ResponseWriter, req *http.Request) {
 	fmt.Fprintf(writer, "<title>triggers %s</title>\n", imageName)
 	fmt.Fprintln(writer, "<body>")
 	fmt.Fprintln(writer, "<h3>")
-	image := s.imageDataBase.GetImage(imageName)
+	image := s.imageDataBase.GetImageMetadata(imageName)
 	if image == nil {
 		fmt.Fprintf(writer, "Image: %s UNKNOWN!\n", imageName)
 	} else if image.Triggers == nil {
@@ -62,6 +62,8 @@ func (s state) showImageHandler(w http.ResponseWriter, req *http.Request) {
 		"listReleaseNotes")
 	showAnnotation(writer, image.BuildLog, imageName, "Build log",
 		"listBuildLog")
+	showAnnotation(writer, image.TestResults, imageName, "Test results",
+		"listTestResults")
 	if image.CreatedBy != "" {
 		fmt.Fprintf(writer, "Created by: %s\n<br>", image.CreatedBy)
 	}
@@ -75,6 +77,18 @@ func (s state) showImageHandler(w http.ResponseWriter, req *http.Request) {
 			"Packages: <a href=\"listPackages?%s\">%d</a><br>\n",
 			imageName, len(image.Packages))
 	}
+	if previousName := s.findPreviousImage(imageName); previousName != "" {
+		fmt.Fprintf(writer,
+			"Changes since previous image: "+
+				"<a href=\"diffImages?left=%s&right=%s\">%s</a><br>\n",
+			previousName, imageName, previousName)
+	}
+	fmt.Fprintln(writer, `<form action="diffImages" method="get">`)
+	fmt.Fprintf(writer,
+		"<input type=\"hidden\" name=\"right\" value=\"%s\">\n", imageName)
+	fmt.Fprintln(writer, "Compare with image: "+
+		`<input type="text" name="left"> <input type="submit" value="Diff">`)
+	fmt.Fprintln(writer, "</form>")
 	fmt.Fprintln(writer, "</body>")
 }
 
@@ -0,0 +1,76 @@
+package httpd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (s state) showUsageHandler(w http.ResponseWriter, req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	directories, groups := s.imageDataBase.GetUsage()
+	fmt.Fprintln(writer, "<title>imageserver storage usage</title>")
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>Usage per directory</h3>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	writeUsageHeader(writer, "Directory")
+	fmt.Fprintln(writer, "    <th>Soft Quota</th>")
+	fmt.Fprintln(writer, "    <th>Hard Quota</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, directory := range directories {
+		writeUsage(writer, directory.Name, directory.Usage)
+		writeQuota(writer, directory.SoftQuotaBytes)
+		writeQuota(writer, directory.HardQuotaBytes)
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "<h3>Usage per owner group</h3>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	writeUsageHeader(writer, "Owner Group")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, group := range groups {
+		writeUsage(writer, group.Name, group.Usage)
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
+
+func writeQuota(writer io.Writer, quota uint64) {
+	if quota < 1 {
+		fmt.Fprintln(writer, "    <td></td>")
+	} else {
+		fmt.Fprintf(writer, "    <td>%s</td>\n", format.FormatBytes(quota))
+	}
+}
+
+func writeUsage(writer io.Writer, name string, usage imageserver.Usage) {
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintf(writer, "    <td>%s</td>\n", name)
+	fmt.Fprintf(writer, "    <td>%d</td>\n", usage.NumImages)
+	fmt.Fprintf(writer, "    <td>%s</td>\n",
+		format.FormatBytes(usage.FairShareBytes))
+	fmt.Fprintf(writer, "    <td>%s</td>\n",
+		format.FormatBytes(usage.UniqueBytes))
+	fmt.Fprintf(writer, "    <td>%s</td>\n",
+		format.FormatBytes(usage.SharedBytes))
+}
+
+func writeUsageHeader(writer io.Writer, name string) {
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintf(writer, "    <th>%s</th>\n", name)
+	fmt.Fprintln(writer, "    <th>Images</th>")
+	fmt.Fprintln(writer, "    <th>Fair Share</th>")
+	fmt.Fprintln(writer, "    <th>Unique</th>")
+	fmt.Fprintln(writer, "    <th>Shared</th>")
+}
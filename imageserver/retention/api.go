@@ -0,0 +1,84 @@
+package retention
+
+import (
+	"flag"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/audit"
+	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+var (
+	fleetManagerHostname = flag.String("fleetManagerHostname", "",
+		"Hostname of Fleet Manager to get VM image references from")
+	fleetManagerPortNum = flag.Uint("fleetManagerPortNum",
+		constants.FleetManagerPortNumber,
+		"Port number of Fleet Manager")
+	retentionCheckInterval = flag.Duration("retentionCheckInterval",
+		time.Hour, "Interval between enforcing image retention policies")
+	retentionMdbFile = flag.String("retentionMdbFile", "",
+		"File to read MDB data from to find referenced images")
+	retentionPolicyFile = flag.String("retentionPolicyFile", "",
+		"JSON file containing image retention policies per directory")
+)
+
+// Policy specifies which images in a directory (and its subdirectories which
+// do not have their own policy) to keep. An image is deleted only if it is not
+// kept by any of the specified rules. Images which aliases refer to are always
+// kept.
+type Policy struct {
+	KeepLatest     uint   `json:",omitempty"` // Latest images per directory.
+	KeepNewerThan  string `json:",omitempty"` // Duration, e.g. "720h".
+	KeepReferenced bool   `json:",omitempty"` // Referenced by MDB or VMs.
+}
+
+type Config struct {
+	Directories map[string]Policy
+}
+
+// Deletion records an image which would be deleted.
+type Deletion struct {
+	Directory string // Directory with the policy.
+	ImageName string
+	Reason    string
+}
+
+type policyType struct {
+	Policy
+	keepNewerThan time.Duration
+}
+
+type Manager struct {
+	auditLog      *audit.Log
+	enforcing     bool
+	imdb          *scanner.ImageDataBase
+	logger        log.DebugLogger
+	policies      map[string]policyType
+	mdbRefs       *referenceSource
+	vmRefs        *referenceSource
+	lastCheckLock sync.Mutex // Protect lastCheck.
+	lastCheck     time.Time
+}
+
+// Setup will load the retention policies and start enforcing them. If no
+// policy file is specified nil is returned. Policies are not enforced if
+// replicationMaster is not empty, since images are deleted by the master.
+// Deletions are recorded in auditLog.
+func Setup(imdb *scanner.ImageDataBase, auditLog *audit.Log,
+	replicationMaster string, logger log.DebugLogger) (*Manager, error) {
+	return setup(imdb, auditLog, replicationMaster, logger)
+}
+
+// Preview returns the images which would be deleted if the policies were
+// enforced now.
+func (m *Manager) Preview() []Deletion {
+	return m.computeDeletions(time.Now())
+}
+
+func (m *Manager) WriteHtml(writer io.Writer) {
+	m.writeHtml(writer)
+}
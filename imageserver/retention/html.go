@@ -0,0 +1,109 @@
+package retention
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+const timeFormat = "02 Jan 2006 15:04:05 MST"
+
+func (m *Manager) writeHtml(writer io.Writer) {
+	m.lastCheckLock.Lock()
+	lastCheck := m.lastCheck
+	m.lastCheckLock.Unlock()
+	numDeleted := len(m.auditLog.Get(imageserver.GetAuditLogRequest{
+		Operation: imageserver.AuditOperationRetentionDelete,
+	}))
+	fmt.Fprintf(writer,
+		"Retention policies for %d directories: "+
+			"<a href=\"showRetentionPreview\">preview</a>, "+
+			"<a href=\"showAuditLog?op=%s\">%d deleted</a>",
+		len(m.policies), imageserver.AuditOperationRetentionDelete,
+		numDeleted)
+	if !m.enforcing {
+		fmt.Fprint(writer, " (not enforced on replica)")
+	} else if !lastCheck.IsZero() {
+		fmt.Fprintf(writer, ", last enforced at: %s",
+			lastCheck.In(time.Local).Format(timeFormat))
+	}
+	fmt.Fprintln(writer, "<br>")
+}
+
+func (m *Manager) showPreviewHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	fmt.Fprintln(writer, "<title>imageserver retention preview</title>")
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	fmt.Fprintln(writer, "Images which would be deleted now (dry-run)")
+	fmt.Fprintln(writer, "</h3>")
+	for _, source := range []*referenceSource{m.mdbRefs, m.vmRefs} {
+		if source == nil {
+			continue
+		}
+		if _, ready := source.getReferences(); !ready {
+			fmt.Fprintf(writer,
+				"<font color=\"red\">%s references not available: "+
+					"referenced images are kept</font><br>\n",
+				source.name)
+		}
+	}
+	writeDeletions(writer, m.Preview())
+	fmt.Fprintln(writer, "<h3>Policies</h3>")
+	dirnames := make([]string, 0, len(m.policies))
+	for dirname := range m.policies {
+		dirnames = append(dirnames, dirname)
+	}
+	sort.Strings(dirnames)
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Directory</th>")
+	fmt.Fprintln(writer, "    <th>Keep Latest</th>")
+	fmt.Fprintln(writer, "    <th>Keep Newer Than</th>")
+	fmt.Fprintln(writer, "    <th>Keep Referenced</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, dirname := range dirnames {
+		policy := m.policies[dirname]
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", dirname)
+		if policy.KeepLatest > 0 {
+			fmt.Fprintf(writer, "    <td>%d</td>\n", policy.KeepLatest)
+		} else {
+			fmt.Fprintln(writer, "    <td></td>")
+		}
+		fmt.Fprintf(writer, "    <td>%s</td>\n", policy.KeepNewerThan)
+		fmt.Fprintf(writer, "    <td>%t</td>\n", policy.KeepReferenced)
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
+
+func writeDeletions(writer io.Writer, deletions []Deletion) {
+	if len(deletions) < 1 {
+		fmt.Fprintln(writer, "No images<br>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Image</th>")
+	fmt.Fprintln(writer, "    <th>Policy Directory</th>")
+	fmt.Fprintln(writer, "    <th>Reason</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, deletion := range deletions {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td><a href=\"showImage?%s\">%s</a></td>\n",
+			deletion.ImageName, deletion.ImageName)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", deletion.Directory)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", deletion.Reason)
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+}
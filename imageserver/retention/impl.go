@@ -0,0 +1,231 @@
+package retention
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/audit"
+	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/html"
+	libjson "github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+type imageInfo struct {
+	createdOn time.Time
+	name      string
+}
+
+func setup(imdb *scanner.ImageDataBase, auditLog *audit.Log,
+	replicationMaster string, logger log.DebugLogger) (*Manager, error) {
+	if *retentionPolicyFile == "" {
+		return nil, nil
+	}
+	var config Config
+	if err := libjson.ReadFromFile(*retentionPolicyFile, &config); err != nil {
+		return nil, fmt.Errorf("error reading retention policies: %s", err)
+	}
+	policies, err := config.compile()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		auditLog:  auditLog,
+		enforcing: replicationMaster == "",
+		imdb:      imdb,
+		logger:    logger,
+		policies:  policies,
+	}
+	needReferences := false
+	for _, policy := range policies {
+		if policy.KeepReferenced {
+			needReferences = true
+		}
+	}
+	if needReferences {
+		if *retentionMdbFile == "" && *fleetManagerHostname == "" {
+			return nil, errors.New("KeepReferenced policy requires " +
+				"-retentionMdbFile or -fleetManagerHostname")
+		}
+		if *retentionMdbFile != "" {
+			m.mdbRefs = startMdbReferences(*retentionMdbFile, logger)
+		}
+		if *fleetManagerHostname != "" {
+			m.vmRefs = startVmReferences(fmt.Sprintf("%s:%d",
+				*fleetManagerHostname, *fleetManagerPortNum), logger)
+		}
+	}
+	html.HandleFunc("/showRetentionPreview", m.showPreviewHandler)
+	go m.enforcer()
+	return m, nil
+}
+
+func (config Config) compile() (map[string]policyType, error) {
+	policies := make(map[string]policyType, len(config.Directories))
+	for dirname, policy := range config.Directories {
+		dirname = filepath.Clean(dirname)
+		compiledPolicy := policyType{Policy: policy}
+		if policy.KeepNewerThan != "" {
+			duration, err := time.ParseDuration(policy.KeepNewerThan)
+			if err != nil {
+				return nil, fmt.Errorf("directory: %s: %s", dirname, err)
+			}
+			compiledPolicy.keepNewerThan = duration
+		}
+		if policy.KeepLatest < 1 && compiledPolicy.keepNewerThan <= 0 {
+			return nil, fmt.Errorf(
+				"directory: %s: KeepLatest or KeepNewerThan required", dirname)
+		}
+		policies[dirname] = compiledPolicy
+	}
+	return policies, nil
+}
+
+// findPolicy will return the policy for the nearest enclosing directory which
+// has a policy.
+func (m *Manager) findPolicy(dirname string) (string, *policyType) {
+	for {
+		if policy, ok := m.policies[dirname]; ok {
+			return dirname, &policy
+		}
+		if dirname == "." || dirname == "/" {
+			return "", nil
+		}
+		dirname = filepath.Dir(dirname)
+	}
+}
+
+// getReferences will return the images referenced by all the sources. If any
+// source is not ready false is returned.
+func (m *Manager) getReferences() (map[string]uint, bool) {
+	references := make(map[string]uint)
+	for _, source := range []*referenceSource{m.mdbRefs, m.vmRefs} {
+		images, ready := source.getReferences()
+		if !ready {
+			return nil, false
+		}
+		for name, count := range images {
+			if resolvedName, err := m.imdb.ResolveImageName(name); err == nil {
+				name = resolvedName
+			}
+			references[name] += count
+		}
+	}
+	return references, true
+}
+
+func (m *Manager) computeDeletions(now time.Time) []Deletion {
+	references, referencesReady := m.getReferences()
+	aliasTargets := make(map[string]struct{})
+	for _, alias := range m.imdb.ListAliases() {
+		aliasTargets[alias.ImageName] = struct{}{}
+	}
+	imagesPerDirectory := make(map[string][]imageInfo)
+	for _, name := range m.imdb.ListImages() {
+		img := m.imdb.GetImageMetadata(name)
+		if img == nil {
+			continue
+		}
+		dirname := filepath.Dir(name)
+		imagesPerDirectory[dirname] = append(imagesPerDirectory[dirname],
+			imageInfo{createdOn: img.CreatedOn, name: name})
+	}
+	return m.selectDeletions(imagesPerDirectory, aliasTargets, references,
+		referencesReady, now)
+}
+
+// selectDeletions will return the images which are not kept by the policies,
+// sorted by name.
+func (m *Manager) selectDeletions(imagesPerDirectory map[string][]imageInfo,
+	aliasTargets map[string]struct{}, references map[string]uint,
+	referencesReady bool, now time.Time) []Deletion {
+	var deletions []Deletion
+	for dirname, images := range imagesPerDirectory {
+		policyDirname, policy := m.findPolicy(dirname)
+		if policy == nil {
+			continue
+		}
+		if policy.KeepReferenced && !referencesReady {
+			continue
+		}
+		sort.Slice(images, func(left, right int) bool {
+			return images[left].createdOn.After(images[right].createdOn)
+		})
+		for index, image := range images {
+			var reasons []string
+			if policy.KeepLatest > 0 {
+				if uint(index) < policy.KeepLatest {
+					continue
+				}
+				reasons = append(reasons,
+					fmt.Sprintf("not in latest %d", policy.KeepLatest))
+			}
+			if policy.keepNewerThan > 0 {
+				if now.Sub(image.createdOn) < policy.keepNewerThan {
+					continue
+				}
+				reasons = append(reasons,
+					"older than "+policy.KeepNewerThan)
+			}
+			if _, ok := aliasTargets[image.name]; ok {
+				continue
+			}
+			if policy.KeepReferenced {
+				if references[image.name] > 0 {
+					continue
+				}
+				reasons = append(reasons, "not referenced")
+			}
+			deletions = append(deletions, Deletion{
+				Directory: policyDirname,
+				ImageName: image.name,
+				Reason:    strings.Join(reasons, ", "),
+			})
+		}
+	}
+	sort.Slice(deletions, func(left, right int) bool {
+		return deletions[left].ImageName < deletions[right].ImageName
+	})
+	return deletions
+}
+
+func (m *Manager) enforcer() {
+	for {
+		time.Sleep(*retentionCheckInterval)
+		if m.enforcing {
+			m.enforce()
+		}
+	}
+}
+
+func (m *Manager) enforce() {
+	deletions := m.computeDeletions(time.Now())
+	m.lastCheckLock.Lock()
+	m.lastCheck = time.Now()
+	m.lastCheckLock.Unlock()
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	for _, deletion := range deletions {
+		m.logger.Printf("Retention policy for: %s deleting image: %s (%s)\n",
+			deletion.Directory, deletion.ImageName, deletion.Reason)
+		err := m.imdb.DeleteImage(deletion.ImageName, authInfo)
+		if err != nil {
+			m.logger.Println(err)
+		}
+		err = m.auditLog.Record(imageserver.AuditEntry{
+			Operation: imageserver.AuditOperationRetentionDelete,
+			Name:      deletion.ImageName,
+			Detail: fmt.Sprintf("policy for: %s: %s",
+				deletion.Directory, deletion.Reason),
+			Error: errors.ErrorToString(err),
+		})
+		if err != nil {
+			m.logger.Printf("Error recording retention deletion: %s\n", err)
+		}
+	}
+}
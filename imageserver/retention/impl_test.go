@@ -0,0 +1,150 @@
+package retention
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	var tests = []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"latest", Policy{KeepLatest: 2}, false},
+		{"newer", Policy{KeepNewerThan: "720h"}, false},
+		{"referenced only", Policy{KeepReferenced: true}, true},
+		{"empty", Policy{}, true},
+		{"bad duration", Policy{KeepNewerThan: "month"}, true},
+	}
+	for _, test := range tests {
+		config := Config{Directories: map[string]Policy{"app/": test.policy}}
+		policies, err := config.compile()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: %v, want error: %t", test.name, err, test.wantErr)
+			continue
+		}
+		if err == nil {
+			if _, ok := policies["app"]; !ok {
+				t.Errorf("%s: directory name not cleaned", test.name)
+			}
+		}
+	}
+}
+
+func TestSelectDeletions(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	// Images with their age in hours.
+	images := map[string]uint{
+		"app/v1":     4,
+		"app/v2":     3,
+		"app/v3":     2,
+		"app/v4":     1,
+		"app/sub/v1": 5,
+		"app/sub/v2": 1,
+		"base/v1":    100,
+		"base/v2":    50,
+		"db/v1":      10,
+	}
+	imagesPerDirectory := make(map[string][]imageInfo)
+	for name, age := range images {
+		dirname := filepath.Dir(name)
+		imagesPerDirectory[dirname] = append(imagesPerDirectory[dirname],
+			imageInfo{
+				createdOn: now.Add(-time.Duration(age) * time.Hour),
+				name:      name,
+			})
+	}
+	var tests = []struct {
+		name            string
+		policies        map[string]Policy
+		aliasTargets    []string
+		references      map[string]uint
+		referencesReady bool
+		want            []Deletion
+	}{
+		{"no policies", nil, nil, nil, true, nil},
+		{"keep latest", map[string]Policy{"app": {KeepLatest: 2}},
+			nil, nil, true, []Deletion{ // Per directory.
+				{Directory: "app", ImageName: "app/v1",
+					Reason: "not in latest 2"},
+				{Directory: "app", ImageName: "app/v2",
+					Reason: "not in latest 2"},
+			}},
+		{"keep newer", map[string]Policy{"app": {KeepNewerThan: "150m"}},
+			nil, nil, true, []Deletion{
+				{Directory: "app", ImageName: "app/sub/v1",
+					Reason: "older than 150m"},
+				{Directory: "app", ImageName: "app/v1",
+					Reason: "older than 150m"},
+				{Directory: "app", ImageName: "app/v2",
+					Reason: "older than 150m"},
+			}},
+		{"keep latest or newer", map[string]Policy{
+			"app": {KeepLatest: 1, KeepNewerThan: "150m"},
+		}, nil, nil, true, []Deletion{
+			{Directory: "app", ImageName: "app/sub/v1",
+				Reason: "not in latest 1, older than 150m"},
+			{Directory: "app", ImageName: "app/v1",
+				Reason: "not in latest 1, older than 150m"},
+			{Directory: "app", ImageName: "app/v2",
+				Reason: "not in latest 1, older than 150m"},
+		}},
+		{"subdirectory policy", map[string]Policy{
+			"app":     {KeepLatest: 3},
+			"app/sub": {KeepLatest: 1},
+			"base":    {KeepNewerThan: "72h"},
+		}, nil, nil, true, []Deletion{
+			{Directory: "app/sub", ImageName: "app/sub/v1",
+				Reason: "not in latest 1"},
+			{Directory: "app", ImageName: "app/v1",
+				Reason: "not in latest 3"},
+			{Directory: "base", ImageName: "base/v1",
+				Reason: "older than 72h"},
+		}},
+		{"root policy", map[string]Policy{".": {KeepNewerThan: "72h"}},
+			nil, nil, true, []Deletion{
+				{Directory: ".", ImageName: "base/v1",
+					Reason: "older than 72h"},
+			}},
+		{"alias targets", map[string]Policy{"app": {KeepLatest: 2}},
+			[]string{"app/v1", "app/sub/v1"}, nil, true, []Deletion{
+				{Directory: "app", ImageName: "app/v2",
+					Reason: "not in latest 2"},
+			}},
+		{"referenced", map[string]Policy{
+			"app": {KeepLatest: 1, KeepReferenced: true},
+		}, nil, map[string]uint{"app/v1": 2, "app/sub/v1": 1}, true,
+			[]Deletion{
+				{Directory: "app", ImageName: "app/v2",
+					Reason: "not in latest 1, not referenced"},
+				{Directory: "app", ImageName: "app/v3",
+					Reason: "not in latest 1, not referenced"},
+			}},
+		{"references not ready", map[string]Policy{
+			"app": {KeepLatest: 1, KeepReferenced: true},
+			"db":  {KeepNewerThan: "1h"},
+		}, nil, nil, false, []Deletion{
+			{Directory: "db", ImageName: "db/v1", Reason: "older than 1h"},
+		}},
+	}
+	for _, test := range tests {
+		policies, err := Config{Directories: test.policies}.compile()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		aliasTargets := make(map[string]struct{})
+		for _, name := range test.aliasTargets {
+			aliasTargets[name] = struct{}{}
+		}
+		m := &Manager{policies: policies}
+		deletions := m.selectDeletions(imagesPerDirectory, aliasTargets,
+			test.references, test.referencesReady, now)
+		if !reflect.DeepEqual(deletions, test.want) {
+			t.Errorf("%s: deletions:\n%v\nwant:\n%v",
+				test.name, deletions, test.want)
+		}
+	}
+}
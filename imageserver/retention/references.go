@@ -0,0 +1,126 @@
+package retention
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/mdb/mdbd"
+	"github.com/Symantec/Dominator/lib/srpc"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+// referenceSource tracks the images referenced by a source. Until the source
+// has provided the full list of references, no images may be deleted.
+type referenceSource struct {
+	name   string
+	lock   sync.RWMutex
+	images map[string]uint // Key: image name, value: reference count.
+	ready  bool
+}
+
+func newReferenceSource(name string) *referenceSource {
+	return &referenceSource{name: name, images: make(map[string]uint)}
+}
+
+func (rs *referenceSource) getReferences() (map[string]uint, bool) {
+	if rs == nil {
+		return nil, true
+	}
+	rs.lock.RLock()
+	defer rs.lock.RUnlock()
+	if !rs.ready {
+		return nil, false
+	}
+	images := make(map[string]uint, len(rs.images))
+	for name, count := range rs.images {
+		images[name] = count
+	}
+	return images, true
+}
+
+func (rs *referenceSource) setReferences(images map[string]uint, ready bool) {
+	rs.lock.Lock()
+	defer rs.lock.Unlock()
+	rs.images = images
+	rs.ready = ready
+}
+
+func startMdbReferences(mdbFileName string,
+	logger log.DebugLogger) *referenceSource {
+	rs := newReferenceSource("MDB")
+	mdbChannel := mdbd.StartMdbDaemon(mdbFileName, logger)
+	go func() {
+		for mdb := range mdbChannel {
+			images := make(map[string]uint)
+			for _, machine := range mdb.Machines {
+				if machine.RequiredImage != "" {
+					images[machine.RequiredImage]++
+				}
+				if machine.PlannedImage != "" {
+					images[machine.PlannedImage]++
+				}
+			}
+			rs.setReferences(images, true)
+		}
+	}()
+	return rs
+}
+
+func startVmReferences(fleetManagerAddress string,
+	logger log.DebugLogger) *referenceSource {
+	rs := newReferenceSource("VMs")
+	go func() {
+		for {
+			err := rs.watchFleetManager(fleetManagerAddress)
+			rs.setReferences(make(map[string]uint), false)
+			logger.Printf("Error getting VM updates from: %s: %s\n",
+				fleetManagerAddress, err)
+			time.Sleep(time.Second * 10)
+		}
+	}()
+	return rs
+}
+
+func (rs *referenceSource) watchFleetManager(address string) error {
+	client, err := srpc.DialHTTP("tcp", address, time.Second*15)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	conn, err := client.Call("FleetManager.GetUpdates")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.Encode(fm_proto.GetUpdatesRequest{}); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	vmImages := make(map[string]string) // Key: IP address.
+	for {
+		var update fm_proto.Update
+		if err := conn.Decode(&update); err != nil {
+			return err
+		}
+		if update.Error != "" {
+			return errors.New(update.Error)
+		}
+		for ipAddr, vm := range update.ChangedVMs {
+			vmImages[ipAddr] = vm.ImageName
+		}
+		for _, ipAddr := range update.DeletedVMs {
+			delete(vmImages, ipAddr)
+		}
+		images := make(map[string]uint)
+		for _, imageName := range vmImages {
+			if imageName != "" {
+				images[imageName]++
+			}
+		}
+		rs.setReferences(images, true)
+	}
+}
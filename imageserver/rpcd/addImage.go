@@ -19,7 +19,14 @@ func (t *srpcType) AddImage(conn *srpc.Conn,
 
 func (t *srpcType) AddImageTrusted(conn *srpc.Conn,
 	request imageserver.AddImageRequest,
-	reply *imageserver.AddImageResponse) error {
+	reply *imageserver.AddImageResponse) (err error) {
+	defer func() {
+		var detail string
+		if request.Image != nil && request.Image.CreatedBy != conn.Username() {
+			detail = "created by: " + request.Image.CreatedBy
+		}
+		t.recordAudit(conn, "AddImage", request.ImageName, detail, err)
+	}()
 	if t.imageDataBase.CheckImage(request.ImageName) {
 		return errors.New("image already exists")
 	}
@@ -29,7 +36,14 @@ func (t *srpcType) AddImageTrusted(conn *srpc.Conn,
 	if request.Image.FileSystem == nil {
 		return errors.New("nil file-system")
 	}
-	err := request.Image.VerifyObjects(t.imageDataBase.ObjectServer())
+	err = t.imageDataBase.CheckQuota(request.Image, request.ImageName)
+	if err != nil {
+		return err
+	}
+	// Pin the objects so that they cannot be collected between verification
+	// and the image being added.
+	defer t.imageDataBase.PinImageObjects(request.Image)()
+	err = request.Image.VerifyObjects(t.imageDataBase.ObjectServer())
 	if err != nil {
 		return err
 	}
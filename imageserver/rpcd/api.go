@@ -5,8 +5,12 @@ import (
 	"flag"
 	"io"
 	"sync"
+	"time"
 
+	"github.com/Symantec/Dominator/imageserver/audit"
 	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/imageserver/search"
+	"github.com/Symantec/Dominator/lib/flagutil"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -17,13 +21,45 @@ var (
 		"If true, replicate expiring images when in archive mode")
 	archiveMode = flag.Bool("archiveMode", false,
 		"If true, disable delete operations and require update server")
+	replicationConflictPolicy = flag.String("replicationConflictPolicy",
+		conflictPolicyFirstWriterWins,
+		"Policy for images added to multiple peers with the same name")
+	replicationExcludeDirectories flagutil.StringList
+	replicationIncludeDirectories flagutil.StringList
+	replicationPeers              flagutil.StringList
 )
 
+func init() {
+	flag.Var(&replicationExcludeDirectories, "replicationExcludeDirectories",
+		"Comma separated list of directories to not replicate images from")
+	flag.Var(&replicationIncludeDirectories, "replicationIncludeDirectories",
+		"Comma separated list of directories to replicate images from")
+	flag.Var(&replicationPeers, "replicationPeers",
+		"Comma separated list of imageservers to replicate with (multi-master)")
+}
+
+type replicationPeerType struct {
+	address     string
+	multiMaster bool
+	resource    *srpc.ClientResource
+	statusLock  sync.Mutex // Protect the following fields.
+	connected   bool
+	inSync      bool // True when the initial image list was replicated.
+	lastError   string
+	lastImage   string
+	lastLag     time.Duration
+	lastUpdate  time.Time
+	numAdded    uint64
+	numDeleted  uint64
+}
+
 type srpcType struct {
 	imageDataBase             *scanner.ImageDataBase
+	auditLog                  *audit.Log
+	searchIndex               *search.Index
 	finishedReplication       <-chan struct{} // Closed when finished.
 	replicationMaster         string
-	imageserverResource       *srpc.ClientResource
+	replicationPeers          []*replicationPeerType
 	objSrv                    objectserver.FullObjectServer
 	archiveMode               bool
 	logger                    log.Logger
@@ -42,18 +78,51 @@ func (hw *htmlWriter) WriteHtml(writer io.Writer) {
 var replicationMessage = "cannot make changes while under replication control" +
 	", go to master: "
 
-func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
+// ReplicationPeers will return the addresses of the multi-master replication
+// peers.
+func ReplicationPeers() []string {
+	return replicationPeers
+}
+
+func Setup(imdb *scanner.ImageDataBase, searchIndex *search.Index,
+	auditLog *audit.Log, replicationMaster string,
 	objSrv objectserver.FullObjectServer,
 	logger log.Logger) (*htmlWriter, error) {
 	if *archiveMode && replicationMaster == "" {
 		return nil, errors.New("replication master required in archive mode")
 	}
+	if replicationMaster != "" && len(replicationPeers) > 0 {
+		return nil, errors.New(
+			"cannot have replication master and replication peers")
+	}
+	switch *replicationConflictPolicy {
+	case conflictPolicyFirstWriterWins, conflictPolicyLastWriterWins:
+	default:
+		return nil, errors.New("unknown replication conflict policy: " +
+			*replicationConflictPolicy)
+	}
+	var peers []*replicationPeerType
+	if replicationMaster != "" {
+		peers = append(peers, &replicationPeerType{
+			address:  replicationMaster,
+			resource: srpc.NewClientResource("tcp", replicationMaster),
+		})
+	}
+	for _, address := range replicationPeers {
+		peers = append(peers, &replicationPeerType{
+			address:     address,
+			multiMaster: true,
+			resource:    srpc.NewClientResource("tcp", address),
+		})
+	}
 	finishedReplication := make(chan struct{})
 	srpcObj := &srpcType{
 		imageDataBase:       imdb,
+		auditLog:            auditLog,
+		searchIndex:         searchIndex,
 		finishedReplication: finishedReplication,
 		replicationMaster:   replicationMaster,
-		imageserverResource: srpc.NewClientResource("tcp", replicationMaster),
+		replicationPeers:    peers,
 		objSrv:              objSrv,
 		logger:              logger,
 		archiveMode:         *archiveMode,
@@ -66,16 +135,27 @@ func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
 			"CheckImage",
 			"ChownDirectory",
 			"DeleteImage",
+			"DiffImages",
+			"FindImages",
 			"FindLatestImage",
 			"GetImage",
 			"GetImageExpiration",
+			"GetUsage",
+			"ListAliases",
 			"ListDirectories",
 			"ListImages",
+			"ResolveImageAlias",
+			"SetImageAlias",
 		}})
 	if replicationMaster != "" {
-		go srpcObj.replicator(finishedReplication)
+		go srpcObj.replicator(peers[0], finishedReplication)
+		go srpcObj.auditReplicator()
 	} else {
+		// Peers must not wait for each other to finish replicating.
 		close(finishedReplication)
+		for _, peer := range peers {
+			go srpcObj.replicator(peer, nil)
+		}
 	}
 	return (*htmlWriter)(srpcObj), nil
 }
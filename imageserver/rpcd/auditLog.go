@@ -0,0 +1,141 @@
+package rpcd
+
+import (
+	"time"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+// recordAudit will record a mutation in the audit log. Replicas do not record
+// mutations, since their audit log is replicated from the master.
+func (t *srpcType) recordAudit(conn *srpc.Conn, operation, name,
+	detail string, err error) {
+	if t.auditLog == nil || t.replicationMaster != "" {
+		return
+	}
+	err = t.auditLog.Record(imageserver.AuditEntry{
+		Username:  conn.Username(),
+		Address:   conn.RemoteAddr(),
+		Operation: operation,
+		Name:      name,
+		Detail:    detail,
+		Error:     errors.ErrorToString(err),
+	})
+	if err != nil {
+		t.logger.Printf("Error recording %s(%s) in audit log: %s\n",
+			operation, name, err)
+	}
+}
+
+func (t *srpcType) GetAuditLog(conn *srpc.Conn) error {
+	var request imageserver.GetAuditLogRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	if t.auditLog == nil {
+		return errors.New("no audit log")
+	}
+	var channel <-chan imageserver.AuditEntry
+	if request.Follow {
+		channel = t.auditLog.RegisterNotifier()
+		defer t.auditLog.UnregisterNotifier(channel)
+	}
+	lastSequence, err := t.sendAuditEntries(conn, request)
+	if err != nil {
+		return err
+	}
+	if err := conn.Encode(imageserver.AuditEntry{}); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	if !request.Follow {
+		return nil
+	}
+	closeChannel := conn.GetCloseNotifier()
+	for {
+		select {
+		case <-channel:
+			// Notifications may arrive out of order, so send everything since
+			// the last entry sent.
+			if lastSequence >= request.StartSequence {
+				request.StartSequence = lastSequence + 1
+			}
+			sequence, err := t.sendAuditEntries(conn, request)
+			if err != nil {
+				return err
+			}
+			if sequence > lastSequence {
+				lastSequence = sequence
+			}
+			if err := conn.Flush(); err != nil {
+				return err
+			}
+		case err := <-closeChannel:
+			return err
+		}
+	}
+}
+
+// sendAuditEntries will send the matching entries and return the sequence
+// number of the last entry sent.
+func (t *srpcType) sendAuditEntries(conn *srpc.Conn,
+	request imageserver.GetAuditLogRequest) (uint64, error) {
+	var lastSequence uint64
+	for _, entry := range t.auditLog.Get(request) {
+		if err := conn.Encode(entry); err != nil {
+			return 0, err
+		}
+		lastSequence = entry.Sequence
+	}
+	return lastSequence, nil
+}
+
+// auditReplicator will mirror the audit log of the replication master.
+func (t *srpcType) auditReplicator() {
+	for {
+		if err := t.replicateAuditLog(); err != nil {
+			t.logger.Printf("Error replicating audit log from: %s: %s\n",
+				t.replicationMaster, err)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+func (t *srpcType) replicateAuditLog() error {
+	client, err := srpc.DialHTTP("tcp", t.replicationMaster, time.Second*15)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	conn, err := client.Call("ImageServer.GetAuditLog")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	request := imageserver.GetAuditLogRequest{
+		Follow:        true,
+		StartSequence: t.auditLog.LastSequence() + 1,
+	}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var entry imageserver.AuditEntry
+		if err := conn.Decode(&entry); err != nil {
+			return err
+		}
+		if entry.Sequence < 1 {
+			continue // End of initial entries.
+		}
+		if err := t.auditLog.Append(entry); err != nil {
+			return err
+		}
+	}
+}
@@ -10,13 +10,17 @@ import (
 
 func (t *srpcType) ChownDirectory(conn *srpc.Conn,
 	request imageserver.ChangeOwnerRequest,
-	reply *imageserver.ChangeOwnerResponse) error {
+	reply *imageserver.ChangeOwnerResponse) (err error) {
+	defer func() {
+		t.recordAudit(conn, "ChownDirectory", request.DirectoryName,
+			"owner group: "+request.OwnerGroup, err)
+	}()
 	username := conn.Username()
 	if username == "" {
 		return errors.New("no username: unauthenticated connection")
 	}
 	if request.OwnerGroup != "" {
-		if _, err := user.LookupGroup(request.OwnerGroup); err != nil {
+		if _, err = user.LookupGroup(request.OwnerGroup); err != nil {
 			return err
 		}
 	}
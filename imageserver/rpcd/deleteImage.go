@@ -9,7 +9,10 @@ import (
 
 func (t *srpcType) DeleteImage(conn *srpc.Conn,
 	request imageserver.DeleteImageRequest,
-	reply *imageserver.DeleteImageResponse) error {
+	reply *imageserver.DeleteImageResponse) (err error) {
+	defer func() {
+		t.recordAudit(conn, "DeleteImage", request.ImageName, "", err)
+	}()
 	username := conn.Username()
 	if err := t.checkMutability(); err != nil {
 		return err
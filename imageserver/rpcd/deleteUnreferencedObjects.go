@@ -1,6 +1,8 @@
 package rpcd
 
 import (
+	"fmt"
+
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/proto/imageserver"
@@ -8,7 +10,13 @@ import (
 
 func (t *srpcType) DeleteUnreferencedObjects(conn *srpc.Conn,
 	request imageserver.DeleteUnreferencedObjectsRequest,
-	reply *imageserver.DeleteUnreferencedObjectsResponse) error {
+	reply *imageserver.DeleteUnreferencedObjectsResponse) (err error) {
+	defer func() {
+		t.recordAudit(conn, "DeleteUnreferencedObjects", "",
+			fmt.Sprintf("%d%%, %s", request.Percentage,
+				format.FormatBytes(request.Bytes)),
+			err)
+	}()
 	username := conn.Username()
 	if username == "" {
 		t.logger.Printf("DeleteUnreferencedObjects(%d%%, %s)\n",
@@ -0,0 +1,41 @@
+package rpcd
+
+import (
+	"errors"
+
+	"github.com/Symantec/Dominator/lib/image/diff"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) DiffImages(conn *srpc.Conn,
+	request imageserver.DiffImagesRequest,
+	reply *imageserver.DiffImagesResponse) error {
+	result, err := t.diffImages(request.LeftImageName, request.RightImageName)
+	if err != nil {
+		reply.Error = err.Error()
+	} else {
+		reply.Diff = result
+	}
+	return nil
+}
+
+func (t *srpcType) diffImages(leftName, rightName string) (*diff.Diff, error) {
+	leftName, err := t.imageDataBase.ResolveImageName(leftName)
+	if err != nil {
+		return nil, err
+	}
+	rightName, err = t.imageDataBase.ResolveImageName(rightName)
+	if err != nil {
+		return nil, err
+	}
+	left := t.imageDataBase.GetImage(leftName)
+	if left == nil {
+		return nil, errors.New("image: " + leftName + " does not exist")
+	}
+	right := t.imageDataBase.GetImage(rightName)
+	if right == nil {
+		return nil, errors.New("image: " + rightName + " does not exist")
+	}
+	return diff.Compute(left, right), nil
+}
@@ -1,6 +1,8 @@
 package rpcd
 
 import (
+	"time"
+
 	"github.com/Symantec/Dominator/lib/errors"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/proto/imageserver"
@@ -9,6 +11,16 @@ import (
 func (t *srpcType) ChangeImageExpiration(conn *srpc.Conn,
 	request imageserver.ChangeImageExpirationRequest,
 	reply *imageserver.ChangeImageExpirationResponse) error {
+	defer func() {
+		var detail string
+		if request.ExpiresAt.IsZero() {
+			detail = "expires: never"
+		} else {
+			detail = "expires: " + request.ExpiresAt.Format(time.RFC3339)
+		}
+		t.recordAudit(conn, "ChangeImageExpiration", request.ImageName,
+			detail, errors.New(reply.Error))
+	}()
 	if err := t.checkMutability(); err != nil {
 		reply.Error = errors.ErrorToString(err)
 		return nil
@@ -22,7 +34,7 @@ func (t *srpcType) ChangeImageExpiration(conn *srpc.Conn,
 func (t *srpcType) GetImageExpiration(conn *srpc.Conn,
 	request imageserver.GetImageExpirationRequest,
 	reply *imageserver.GetImageExpirationResponse) error {
-	if img := t.imageDataBase.GetImage(request.ImageName); img == nil {
+	if img := t.imageDataBase.GetImageMetadata(request.ImageName); img == nil {
 		reply.Error = "image not found"
 	} else {
 		reply.ExpiresAt = img.ExpiresAt
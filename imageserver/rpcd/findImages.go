@@ -0,0 +1,20 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) FindImages(conn *srpc.Conn,
+	request imageserver.FindImagesRequest,
+	reply *imageserver.FindImagesResponse) error {
+	if t.searchIndex == nil {
+		reply.Error = "image search is disabled"
+		return nil
+	}
+	images, err := t.searchIndex.Find(request)
+	reply.Error = errors.ErrorToString(err)
+	reply.Images = images
+	return nil
+}
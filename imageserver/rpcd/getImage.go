@@ -46,16 +46,23 @@ func (t *srpcType) GetImage(conn *srpc.Conn,
 
 func (t *srpcType) getImageNow(
 	request imageserver.GetImageRequest) *image.Image {
-	originalImage := t.imageDataBase.GetImage(request.ImageName)
+	originalImage := t.imageDataBase.GetImageMetadata(request.ImageName)
 	if originalImage == nil {
 		return nil
 	}
-	img := *originalImage
-	if request.IgnoreFilesystem {
-		img.FileSystem = nil
-	} else if request.IgnoreFilesystemIfExpiring &&
-		!originalImage.ExpiresAt.IsZero() {
+	if request.IgnoreFilesystem || (request.IgnoreFilesystemIfExpiring &&
+		!originalImage.ExpiresAt.IsZero()) {
+		img := *originalImage
 		img.FileSystem = nil
+		return &img
+	}
+	if originalImage.FileSystem == nil {
+		// The file-system is not in memory: read it in.
+		originalImage = t.imageDataBase.GetImage(request.ImageName)
+		if originalImage == nil {
+			return nil
+		}
 	}
+	img := *originalImage
 	return &img
 }
@@ -1,6 +1,8 @@
 package rpcd
 
 import (
+	"time"
+
 	"github.com/Symantec/Dominator/lib/image"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/proto/imageserver"
@@ -23,9 +25,11 @@ func (t *srpcType) GetImageUpdates(conn *srpc.Conn) error {
 	t.incrementNumReplicationClients(true)
 	defer t.incrementNumReplicationClients(false)
 	addChannel := t.imageDataBase.RegisterAddNotifier()
+	aliasChannel := t.imageDataBase.RegisterAliasNotifier()
 	deleteChannel := t.imageDataBase.RegisterDeleteNotifier()
 	mkdirChannel := t.imageDataBase.RegisterMakeDirectoryNotifier()
 	defer t.imageDataBase.UnregisterAddNotifier(addChannel)
+	defer t.imageDataBase.UnregisterAliasNotifier(aliasChannel)
 	defer t.imageDataBase.UnregisterDeleteNotifier(deleteChannel)
 	defer t.imageDataBase.UnregisterMakeDirectoryNotifier(mkdirChannel)
 	directories := t.imageDataBase.ListDirectories()
@@ -41,8 +45,25 @@ func (t *srpcType) GetImageUpdates(conn *srpc.Conn) error {
 		}
 	}
 	for _, imageName := range t.imageDataBase.ListImages() {
-		imageUpdate := imageserver.ImageUpdate{Name: imageName}
-		if err := conn.Encode(imageUpdate); err != nil {
+		if err := t.sendAddImage(conn, imageName); err != nil {
+			t.logger.Println(err)
+			return err
+		}
+	}
+	if t.isMultiMaster() {
+		// Peers which were disconnected need to learn about deletions.
+		deletedImages := t.imageDataBase.ListDeletedImages()
+		for imageName, deletedOn := range deletedImages {
+			err := sendDeleteImage(conn, imageName, deletedOn,
+				imageserver.OperationDeletedImage)
+			if err != nil {
+				t.logger.Println(err)
+				return err
+			}
+		}
+	}
+	for _, alias := range t.imageDataBase.ListAliases() {
+		if err := sendSetAlias(conn, alias); err != nil {
 			t.logger.Println(err)
 			return err
 		}
@@ -62,13 +83,18 @@ func (t *srpcType) GetImageUpdates(conn *srpc.Conn) error {
 	for {
 		select {
 		case imageName := <-addChannel:
-			if err := sendUpdate(conn, imageName,
-				imageserver.OperationAddImage); err != nil {
+			if err := t.sendAddImage(conn, imageName); err != nil {
+				t.logger.Println(err)
+				return err
+			}
+		case alias := <-aliasChannel:
+			if err := sendSetAlias(conn, alias); err != nil {
 				t.logger.Println(err)
 				return err
 			}
 		case imageName := <-deleteChannel:
-			if err := sendUpdate(conn, imageName,
+			deletedOn, _ := t.imageDataBase.GetImageDeletionTime(imageName)
+			if err := sendDeleteImage(conn, imageName, deletedOn,
 				imageserver.OperationDeleteImage); err != nil {
 				t.logger.Println(err)
 				return err
@@ -104,8 +130,41 @@ func (t *srpcType) incrementNumReplicationClients(increment bool) {
 	}
 }
 
-func sendUpdate(encoder srpc.Encoder, name string, operation uint) error {
-	imageUpdate := imageserver.ImageUpdate{Name: name, Operation: operation}
+func (t *srpcType) sendAddImage(encoder srpc.Encoder, name string) error {
+	imageUpdate := imageserver.ImageUpdate{
+		Name:      name,
+		Operation: imageserver.OperationAddImage,
+	}
+	if img := t.imageDataBase.GetImageMetadata(name); img != nil {
+		imageUpdate.CreatedOn = img.CreatedOn
+	}
+	if t.isMultiMaster() {
+		digest, err := t.imageDataBase.GetObjectsDigest(name)
+		if err != nil {
+			t.logger.Println(err)
+		} else {
+			imageUpdate.ObjectsDigest = digest
+		}
+	}
+	return encoder.Encode(imageUpdate)
+}
+
+func sendDeleteImage(encoder srpc.Encoder, name string, deletedOn time.Time,
+	operation uint) error {
+	imageUpdate := imageserver.ImageUpdate{
+		Name:      name,
+		DeletedOn: deletedOn,
+		Operation: operation,
+	}
+	return encoder.Encode(imageUpdate)
+}
+
+func sendSetAlias(encoder srpc.Encoder, alias image.Alias) error {
+	imageUpdate := imageserver.ImageUpdate{
+		Name:      alias.Name,
+		Alias:     &alias,
+		Operation: imageserver.OperationSetAlias,
+	}
 	return encoder.Encode(imageUpdate)
 }
 
@@ -0,0 +1,13 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) GetUsage(conn *srpc.Conn,
+	request imageserver.GetUsageRequest,
+	reply *imageserver.GetUsageResponse) error {
+	reply.Directories, reply.Groups = t.imageDataBase.GetUsage()
+	return nil
+}
@@ -3,11 +3,42 @@ package rpcd
 import (
 	"fmt"
 	"io"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
 )
 
 func (hw *htmlWriter) writeHtml(writer io.Writer) {
 	fmt.Fprintf(writer, "Replication clients: %d<br>\n",
 		hw.getNumReplicationClients())
+	if len(hw.replicationPeers) < 1 {
+		return
+	}
+	if len(replicationIncludeDirectories) > 0 {
+		fmt.Fprintf(writer, "Replicating directories: %s<br>\n",
+			replicationIncludeDirectories)
+	}
+	if len(replicationExcludeDirectories) > 0 {
+		fmt.Fprintf(writer, "Not replicating directories: %s<br>\n",
+			replicationExcludeDirectories)
+	}
+	fmt.Fprintln(writer, "Replication peers:<br>")
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Peer</th>")
+	fmt.Fprintln(writer, "    <th>Mode</th>")
+	fmt.Fprintln(writer, "    <th>Status</th>")
+	fmt.Fprintln(writer, "    <th>Added</th>")
+	fmt.Fprintln(writer, "    <th>Deleted</th>")
+	fmt.Fprintln(writer, "    <th>Last Image</th>")
+	fmt.Fprintln(writer, "    <th>Last Update</th>")
+	fmt.Fprintln(writer, "    <th>Lag</th>")
+	fmt.Fprintln(writer, "    <th>Last Error</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, peer := range hw.replicationPeers {
+		peer.writeHtml(writer)
+	}
+	fmt.Fprintln(writer, "</table>")
 }
 
 func (hw *htmlWriter) getNumReplicationClients() uint {
@@ -15,3 +46,38 @@ func (hw *htmlWriter) getNumReplicationClients() uint {
 	defer hw.numReplicationClientsLock.RUnlock()
 	return hw.numReplicationClients
 }
+
+func (peer *replicationPeerType) writeHtml(writer io.Writer) {
+	peer.statusLock.Lock()
+	defer peer.statusLock.Unlock()
+	mode := "master"
+	if peer.multiMaster {
+		mode = "multi-master"
+	}
+	status := "disconnected"
+	if peer.inSync {
+		status = "in sync"
+	} else if peer.connected {
+		status = "syncing"
+	}
+	lastUpdate := ""
+	if !peer.lastUpdate.IsZero() {
+		lastUpdate = format.Duration(time.Since(peer.lastUpdate)) + " ago"
+	}
+	lag := ""
+	if peer.lastLag > 0 {
+		lag = format.Duration(peer.lastLag)
+	}
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintf(writer, "    <td><a href=\"http://%s/\">%s</a></td>\n",
+		peer.address, peer.address)
+	fmt.Fprintf(writer, "    <td>%s</td>\n", mode)
+	fmt.Fprintf(writer, "    <td>%s</td>\n", status)
+	fmt.Fprintf(writer, "    <td>%d</td>\n", peer.numAdded)
+	fmt.Fprintf(writer, "    <td>%d</td>\n", peer.numDeleted)
+	fmt.Fprintf(writer, "    <td>%s</td>\n", peer.lastImage)
+	fmt.Fprintf(writer, "    <td>%s</td>\n", lastUpdate)
+	fmt.Fprintf(writer, "    <td>%s</td>\n", lag)
+	fmt.Fprintf(writer, "    <td>%s</td>\n", peer.lastError)
+	fmt.Fprintln(writer, "  </tr>")
+}
@@ -0,0 +1,15 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func (t *srpcType) ListAliases(conn *srpc.Conn) error {
+	for _, alias := range t.imageDataBase.ListAliases() {
+		if err := conn.Encode(alias); err != nil {
+			return err
+		}
+	}
+	return conn.Encode(image.Alias{})
+}
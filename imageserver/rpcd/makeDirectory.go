@@ -7,7 +7,10 @@ import (
 
 func (t *srpcType) MakeDirectory(conn *srpc.Conn,
 	request imageserver.MakeDirectoryRequest,
-	reply *imageserver.MakeDirectoryResponse) error {
+	reply *imageserver.MakeDirectoryResponse) (err error) {
+	defer func() {
+		t.recordAudit(conn, "MakeDirectory", request.DirectoryName, "", err)
+	}()
 	username := conn.Username()
 	if err := t.checkMutability(); err != nil {
 		return err
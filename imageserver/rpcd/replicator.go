@@ -1,13 +1,16 @@
 package rpcd
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	imageclient "github.com/Symantec/Dominator/imageserver/client"
 	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/log/prefixlogger"
@@ -16,24 +19,132 @@ import (
 	"github.com/Symantec/Dominator/proto/imageserver"
 )
 
-func (t *srpcType) replicator(finishedReplication chan<- struct{}) {
+const (
+	conflictPolicyFirstWriterWins = "first-writer-wins"
+	conflictPolicyLastWriterWins  = "last-writer-wins"
+)
+
+// shouldReplicate returns true if the image should be replicated, according to
+// the replication include and exclude directories.
+func shouldReplicate(name string) bool {
+	if len(replicationIncludeDirectories) > 0 {
+		included := false
+		for _, directory := range replicationIncludeDirectories {
+			if isInDirectory(name, directory) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+	for _, directory := range replicationExcludeDirectories {
+		if isInDirectory(name, directory) {
+			return false
+		}
+	}
+	return true
+}
+
+func isInDirectory(name, directory string) bool {
+	directory = strings.TrimSuffix(directory, "/")
+	return name == directory || strings.HasPrefix(name, directory+"/")
+}
+
+// peerWins returns true if the image from a multi-master peer should replace
+// the local image with the same name. Different images with the same creation
+// time are ordered by their objects digests, so that all peers keep the same
+// image.
+func peerWins(localCreatedOn, peerCreatedOn time.Time,
+	localDigest, peerDigest hash.Hash) bool {
+	if peerCreatedOn.IsZero() {
+		return false
+	}
+	if peerCreatedOn.Equal(localCreatedOn) {
+		if localDigest == (hash.Hash{}) || peerDigest == (hash.Hash{}) {
+			return false
+		}
+		return bytes.Compare(peerDigest[:], localDigest[:]) < 0
+	}
+	if *replicationConflictPolicy == conflictPolicyLastWriterWins {
+		return peerCreatedOn.After(localCreatedOn)
+	}
+	return peerCreatedOn.Before(localCreatedOn)
+}
+
+// deletionWins returns true if an image deletion at deletedOn should remove
+// (or prevent adding) an image created at createdOn. An image created after
+// the deletion is a new image with the same name. A zero deletion time (from
+// a peer which does not send it) always wins.
+func deletionWins(deletedOn, createdOn time.Time) bool {
+	return deletedOn.IsZero() || !createdOn.After(deletedOn)
+}
+
+func (t *srpcType) isMultiMaster() bool {
+	return t.replicationMaster == "" && len(t.replicationPeers) > 0
+}
+
+func (peer *replicationPeerType) setConnected(connected bool, err error) {
+	peer.statusLock.Lock()
+	defer peer.statusLock.Unlock()
+	peer.connected = connected
+	if !connected {
+		peer.inSync = false
+	}
+	if err != nil {
+		peer.lastError = err.Error()
+	} else if connected {
+		peer.lastError = ""
+	}
+}
+
+func (peer *replicationPeerType) recordUpdate(name string, added bool,
+	createdOn time.Time, inSync bool) {
+	peer.statusLock.Lock()
+	defer peer.statusLock.Unlock()
+	peer.lastImage = name
+	peer.lastUpdate = time.Now()
+	if added {
+		peer.numAdded++
+		if inSync && !createdOn.IsZero() {
+			peer.lastLag = peer.lastUpdate.Sub(createdOn)
+		}
+	} else {
+		peer.numDeleted++
+	}
+}
+
+func (peer *replicationPeerType) setInSync() {
+	peer.statusLock.Lock()
+	defer peer.statusLock.Unlock()
+	peer.inSync = true
+}
+
+func (t *srpcType) replicator(peer *replicationPeerType,
+	finishedReplication chan<- struct{}) {
 	initialTimeout := time.Second * 15
 	timeout := initialTimeout
 	var nextSleepStopTime time.Time
 	for {
 		nextSleepStopTime = time.Now().Add(timeout)
-		if client, err := srpc.DialHTTP("tcp", t.replicationMaster,
+		if client, err := srpc.DialHTTP("tcp", peer.address,
 			timeout); err != nil {
-			t.logger.Printf("Error dialing: %s %s\n", t.replicationMaster, err)
+			t.logger.Printf("Error dialing: %s %s\n", peer.address, err)
+			peer.setConnected(false, err)
 		} else {
 			if conn, err := client.Call(
 				"ImageServer.GetImageUpdates"); err != nil {
 				t.logger.Println(err)
+				peer.setConnected(false, err)
 			} else {
-				if err := t.getUpdates(conn, &finishedReplication); err != nil {
+				peer.setConnected(true, nil)
+				err := t.getUpdates(peer, conn, &finishedReplication)
+				if err != nil {
 					if err == io.EOF {
-						t.logger.Println(
-							"Connection to image replicator closed")
+						t.logger.Printf(
+							"Connection to image replicator: %s closed\n",
+							peer.address)
 						if nextSleepStopTime.Sub(time.Now()) < 1 {
 							timeout = initialTimeout
 						}
@@ -41,6 +152,7 @@ func (t *srpcType) replicator(finishedReplication chan<- struct{}) {
 						t.logger.Println(err)
 					}
 				}
+				peer.setConnected(false, err)
 				conn.Close()
 			}
 			client.Close()
@@ -52,14 +164,17 @@ func (t *srpcType) replicator(finishedReplication chan<- struct{}) {
 	}
 }
 
-func (t *srpcType) getUpdates(conn *srpc.Conn,
+func (t *srpcType) getUpdates(peer *replicationPeerType, conn *srpc.Conn,
 	finishedReplication *chan<- struct{}) error {
-	t.logger.Printf("Image replicator: connected to: %s\n", t.replicationMaster)
+	t.logger.Printf("Image replicator: connected to: %s\n", peer.address)
 	replicationStartTime := time.Now()
 	initialImages := make(map[string]struct{})
-	if t.archiveMode {
+	if t.archiveMode || peer.multiMaster {
+		// Images missing from a peer may have been added locally. Images
+		// deleted on the peer are sent as OperationDeletedImage updates.
 		initialImages = nil
 	}
+	inSync := false
 	for {
 		var imageUpdate imageserver.ImageUpdate
 		if err := conn.Decode(&imageUpdate); err != nil {
@@ -79,27 +194,53 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 					close(*finishedReplication)
 					*finishedReplication = nil
 				}
-				t.logger.Printf("Replicated all current images in %s\n",
+				inSync = true
+				peer.setInSync()
+				t.logger.Printf("Replicated all current images from: %s in %s\n",
+					peer.address,
 					format.Duration(time.Since(replicationStartTime)))
 				continue
 			}
+			if !shouldReplicate(imageUpdate.Name) {
+				continue
+			}
 			if initialImages != nil {
 				initialImages[imageUpdate.Name] = struct{}{}
 			}
-			if err := t.addImage(imageUpdate.Name); err != nil {
+			added, err := t.addImage(peer, imageUpdate.Name,
+				imageUpdate.CreatedOn, imageUpdate.ObjectsDigest)
+			if err != nil {
 				return errors.New("error adding image: " + imageUpdate.Name +
 					": " + err.Error())
 			}
-		case imageserver.OperationDeleteImage:
-			if t.archiveMode {
+			if added {
+				peer.recordUpdate(imageUpdate.Name, true, imageUpdate.CreatedOn,
+					inSync)
+			}
+		case imageserver.OperationDeleteImage,
+			imageserver.OperationDeletedImage:
+			if t.archiveMode || !shouldReplicate(imageUpdate.Name) {
 				continue
 			}
+			if peer.multiMaster {
+				img := t.imageDataBase.GetImageMetadata(imageUpdate.Name)
+				if img == nil {
+					continue // Already deleted or notification echoed back.
+				}
+				if !deletionWins(imageUpdate.DeletedOn, img.CreatedOn) {
+					continue // Added again after the deletion.
+				}
+			} else if imageUpdate.Operation ==
+				imageserver.OperationDeletedImage {
+				continue // Missing images are deleted after initial list.
+			}
 			t.logger.Printf("Replicator(%s): delete image\n", imageUpdate.Name)
 			err := t.imageDataBase.DeleteImage(imageUpdate.Name,
 				&srpc.AuthInformation{HaveMethodAccess: true})
 			if err != nil {
 				return err
 			}
+			peer.recordUpdate(imageUpdate.Name, false, time.Time{}, inSync)
 		case imageserver.OperationMakeDirectory:
 			directory := imageUpdate.Directory
 			if directory == nil {
@@ -108,6 +249,22 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 			if err := t.imageDataBase.UpdateDirectory(*directory); err != nil {
 				return err
 			}
+		case imageserver.OperationSetAlias:
+			alias := imageUpdate.Alias
+			if alias == nil {
+				return errors.New("nil imageUpdate.Alias")
+			}
+			if !shouldReplicate(alias.Name) {
+				continue
+			}
+			changed, err := t.imageDataBase.UpdateAlias(*alias)
+			if err != nil {
+				return err
+			}
+			if changed {
+				t.logger.Printf("Replicator(%s): alias moved to: %s\n",
+					alias.Name, alias.ImageName)
+			}
 		}
 	}
 }
@@ -115,6 +272,9 @@ func (t *srpcType) getUpdates(conn *srpc.Conn,
 func (t *srpcType) deleteMissingImages(imagesToKeep map[string]struct{}) {
 	missingImages := make([]string, 0)
 	for _, imageName := range t.imageDataBase.ListImages() {
+		if !shouldReplicate(imageName) {
+			continue
+		}
 		if _, ok := imagesToKeep[imageName]; !ok {
 			missingImages = append(missingImages, imageName)
 		}
@@ -129,10 +289,10 @@ func (t *srpcType) deleteMissingImages(imagesToKeep map[string]struct{}) {
 	}
 }
 
-func (t *srpcType) extendImageExpiration(name string,
-	img *image.Image) (bool, error) {
+func (t *srpcType) extendImageExpiration(peer *replicationPeerType,
+	name string, img *image.Image) (bool, error) {
 	timeout := time.Second * 60
-	client, err := t.imageserverResource.GetHTTP(nil, timeout)
+	client, err := peer.resource.GetHTTP(nil, timeout)
 	if err != nil {
 		return false, err
 	}
@@ -148,27 +308,56 @@ func (t *srpcType) extendImageExpiration(name string,
 		&srpc.AuthInformation{HaveMethodAccess: true})
 }
 
-func (t *srpcType) addImage(name string) error {
+// addImage will replicate an image from peer. It returns true if the image
+// was added (or replaced).
+func (t *srpcType) addImage(peer *replicationPeerType, name string,
+	createdOn time.Time, digest hash.Hash) (bool, error) {
 	timeout := time.Second * 60
 	if t.checkImageBeingInjected(name) {
-		return nil
+		return false, nil
 	}
 	logger := prefixlogger.New(fmt.Sprintf("Replicator(%s): ", name), t.logger)
-	if img := t.imageDataBase.GetImage(name); img != nil {
-		if img.ExpiresAt.IsZero() {
-			return nil
+	if peer.multiMaster {
+		deletedOn, ok := t.imageDataBase.GetImageDeletionTime(name)
+		if ok && deletionWins(deletedOn, createdOn) {
+			logger.Printf("not adding image from: %s, deleted locally\n",
+				peer.address)
+			return false, nil
 		}
-		if changed, err := t.extendImageExpiration(name, img); err != nil {
-			logger.Println(err)
-		} else if changed {
-			logger.Println("extended expiration time")
+	}
+	replace := false
+	if img := t.imageDataBase.GetImageMetadata(name); img != nil {
+		var localDigest hash.Hash
+		if peer.multiMaster && createdOn.Equal(img.CreatedOn) &&
+			digest != (hash.Hash{}) {
+			var err error
+			localDigest, err = t.imageDataBase.GetObjectsDigest(name)
+			if err != nil {
+				logger.Println(err)
+			}
+		}
+		if peer.multiMaster &&
+			peerWins(img.CreatedOn, createdOn, localDigest, digest) {
+			logger.Printf("conflict with: %s, replacing local image\n",
+				peer.address)
+			replace = true
+		} else {
+			if img.ExpiresAt.IsZero() {
+				return false, nil
+			}
+			changed, err := t.extendImageExpiration(peer, name, img)
+			if err != nil {
+				logger.Println(err)
+			} else if changed {
+				logger.Println("extended expiration time")
+			}
+			return false, nil
 		}
-		return nil
 	}
 	logger.Println("add image")
-	client, err := t.imageserverResource.GetHTTP(nil, timeout)
+	client, err := peer.resource.GetHTTP(nil, timeout)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer client.Put()
 	request := imageserver.GetImageRequest{
@@ -182,16 +371,16 @@ func (t *srpcType) addImage(name string) error {
 	err = client.RequestReply("ImageServer.GetImage", request, &reply)
 	if err != nil {
 		client.Close()
-		return err
+		return false, err
 	}
 	img := reply.Image
 	if img == nil {
-		return errors.New(name + ": not found")
+		return false, errors.New(name + ": not found")
 	}
 	logger.Println("downloaded image")
 	if t.archiveMode && !img.ExpiresAt.IsZero() && !*archiveExpiringImages {
 		logger.Println("ignoring expiring image in archiver mode")
-		return nil
+		return false, nil
 	}
 	img.FileSystem.RebuildInodePointers()
 	err = t.imageDataBase.DoWithPendingImage(img, func() error {
@@ -199,18 +388,17 @@ func (t *srpcType) addImage(name string) error {
 			client.Close()
 			return err
 		}
-		err := t.imageDataBase.AddImage(img, name,
-			&srpc.AuthInformation{HaveMethodAccess: true})
-		if err != nil {
-			return err
+		authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+		if replace {
+			return t.imageDataBase.ReplaceImage(img, name, authInfo)
 		}
-		return nil
+		return t.imageDataBase.AddImage(img, name, authInfo)
 	})
 	if err != nil {
-		return err
+		return false, err
 	}
 	logger.Println("added image")
-	return nil
+	return true, nil
 }
 
 func (t *srpcType) checkImageBeingInjected(name string) bool {
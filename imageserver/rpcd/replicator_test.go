@@ -0,0 +1,107 @@
+package rpcd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/hash"
+)
+
+func TestShouldReplicate(t *testing.T) {
+	var tests = []struct {
+		include, exclude []string
+		name             string
+		want             bool
+	}{
+		{nil, nil, "app/v1", true},
+		{[]string{"app"}, nil, "app/v1", true},
+		{[]string{"app/"}, nil, "app/v1", true},
+		{[]string{"app"}, nil, "application/v1", false},
+		{[]string{"app"}, nil, "base/v1", false},
+		{[]string{"app", "base"}, nil, "base/v1", true},
+		{nil, []string{"app/test"}, "app/test/v1", false},
+		{nil, []string{"app/test"}, "app/testing/v1", true},
+		{[]string{"app"}, []string{"app/test"}, "app/test/v1", false},
+		{[]string{"app"}, []string{"app/test"}, "app/prod/v1", true},
+	}
+	defer func() {
+		replicationIncludeDirectories = nil
+		replicationExcludeDirectories = nil
+	}()
+	for _, test := range tests {
+		replicationIncludeDirectories = test.include
+		replicationExcludeDirectories = test.exclude
+		if got := shouldReplicate(test.name); got != test.want {
+			t.Errorf("shouldReplicate(%s) include=%v exclude=%v: %t, want %t",
+				test.name, test.include, test.exclude, got, test.want)
+		}
+	}
+}
+
+func TestPeerWins(t *testing.T) {
+	early := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := early.Add(time.Hour)
+	low := hash.Hash{1}
+	high := hash.Hash{2}
+	var tests = []struct {
+		policy                    string
+		localCreatedOn, createdOn time.Time
+		localDigest, digest       hash.Hash
+		want                      bool
+	}{
+		{conflictPolicyFirstWriterWins, late, early, low, high, true},
+		{conflictPolicyFirstWriterWins, early, late, high, low, false},
+		{conflictPolicyLastWriterWins, late, early, low, high, false},
+		{conflictPolicyLastWriterWins, early, late, high, low, true},
+		{conflictPolicyFirstWriterWins, early, time.Time{}, low, high, false},
+		{conflictPolicyLastWriterWins, early, time.Time{}, low, high, false},
+		// Ties are broken by digest, independent of policy.
+		{conflictPolicyFirstWriterWins, early, early, high, low, true},
+		{conflictPolicyFirstWriterWins, early, early, low, high, false},
+		{conflictPolicyLastWriterWins, early, early, high, low, true},
+		{conflictPolicyLastWriterWins, early, early, low, high, false},
+		{conflictPolicyFirstWriterWins, early, early, low, low, false},
+		{conflictPolicyFirstWriterWins, early, early, high, hash.Hash{},
+			false},
+		{conflictPolicyFirstWriterWins, early, early, hash.Hash{}, low,
+			false},
+	}
+	savedPolicy := *replicationConflictPolicy
+	defer func() { *replicationConflictPolicy = savedPolicy }()
+	for index, test := range tests {
+		*replicationConflictPolicy = test.policy
+		got := peerWins(test.localCreatedOn, test.createdOn, test.localDigest,
+			test.digest)
+		if got != test.want {
+			t.Errorf("test %d: peerWins(%s): %t, want %t",
+				index, test.policy, got, test.want)
+		}
+		// Both peers must agree on the winner of a conflict.
+		reverse := peerWins(test.createdOn, test.localCreatedOn, test.digest,
+			test.localDigest)
+		if got && reverse {
+			t.Errorf("test %d: both peers win", index)
+		}
+	}
+}
+
+func TestDeletionWins(t *testing.T) {
+	deletedOn := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		deletedOn, createdOn time.Time
+		want                 bool
+	}{
+		{deletedOn, deletedOn.Add(-time.Hour), true},
+		{deletedOn, deletedOn, true},
+		{deletedOn, deletedOn.Add(time.Hour), false},
+		{deletedOn, time.Time{}, true},
+		{time.Time{}, deletedOn, true},
+	}
+	for _, test := range tests {
+		got := deletionWins(test.deletedOn, test.createdOn)
+		if got != test.want {
+			t.Errorf("deletionWins(%s, %s): %t, want %t",
+				test.deletedOn, test.createdOn, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) ResolveImageAlias(conn *srpc.Conn,
+	request imageserver.ResolveImageAliasRequest,
+	reply *imageserver.ResolveImageAliasResponse) error {
+	if alias, ok := t.imageDataBase.GetAlias(request.AliasName); ok {
+		reply.Alias = &alias
+	}
+	return nil
+}
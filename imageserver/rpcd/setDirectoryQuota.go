@@ -0,0 +1,33 @@
+package rpcd
+
+import (
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) SetDirectoryQuota(conn *srpc.Conn,
+	request imageserver.SetDirectoryQuotaRequest,
+	reply *imageserver.SetDirectoryQuotaResponse) error {
+	defer func() {
+		t.recordAudit(conn, "SetDirectoryQuota", request.DirectoryName,
+			fmt.Sprintf("soft: %s, hard: %s",
+				format.FormatBytes(request.SoftQuotaBytes),
+				format.FormatBytes(request.HardQuotaBytes)),
+			errors.New(reply.Error))
+	}()
+	if err := t.checkMutability(); err != nil {
+		reply.Error = errors.ErrorToString(err)
+		return nil
+	}
+	t.logger.Printf("SetDirectoryQuota(%s) to: soft=%s hard=%s by %s\n",
+		request.DirectoryName, format.FormatBytes(request.SoftQuotaBytes),
+		format.FormatBytes(request.HardQuotaBytes), conn.Username())
+	err := t.imageDataBase.SetDirectoryQuota(request.DirectoryName,
+		request.SoftQuotaBytes, request.HardQuotaBytes)
+	reply.Error = errors.ErrorToString(err)
+	return nil
+}
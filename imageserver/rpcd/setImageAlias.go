@@ -0,0 +1,34 @@
+package rpcd
+
+import (
+	"os/user"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) SetImageAlias(conn *srpc.Conn,
+	request imageserver.SetImageAliasRequest,
+	reply *imageserver.SetImageAliasResponse) error {
+	defer func() {
+		t.recordAudit(conn, "SetImageAlias", request.AliasName,
+			"image: "+request.ImageName, errors.New(reply.Error))
+	}()
+	if err := t.checkMutability(); err != nil {
+		reply.Error = errors.ErrorToString(err)
+		return nil
+	}
+	if request.OwnerGroup != "" {
+		if _, err := user.LookupGroup(request.OwnerGroup); err != nil {
+			reply.Error = errors.ErrorToString(err)
+			return nil
+		}
+	}
+	t.logger.Printf("SetImageAlias(%s) to: %s by %s\n",
+		request.AliasName, request.ImageName, conn.Username())
+	err := t.imageDataBase.SetAlias(request.AliasName, request.ImageName,
+		request.OwnerGroup, conn.GetAuthInformation())
+	reply.Error = errors.ErrorToString(err)
+	return nil
+}
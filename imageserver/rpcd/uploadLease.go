@@ -0,0 +1,43 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) CreateUploadLease(conn *srpc.Conn,
+	request imageserver.CreateUploadLeaseRequest,
+	reply *imageserver.CreateUploadLeaseResponse) error {
+	leaseId, expiresAt, err := t.imageDataBase.CreateUploadLease(
+		request.Objects, request.Timeout, conn.Username())
+	if err != nil {
+		reply.Error = err.Error()
+		return nil
+	}
+	t.logger.Printf("CreateUploadLease(%s) by %s, expires at: %s\n",
+		leaseId, conn.Username(), expiresAt)
+	reply.ExpiresAt = expiresAt
+	reply.LeaseId = leaseId
+	return nil
+}
+
+func (t *srpcType) ExtendUploadLease(conn *srpc.Conn,
+	request imageserver.ExtendUploadLeaseRequest,
+	reply *imageserver.ExtendUploadLeaseResponse) error {
+	expiresAt, err := t.imageDataBase.ExtendUploadLease(request.LeaseId,
+		request.Objects, request.Timeout)
+	reply.Error = errors.ErrorToString(err)
+	reply.ExpiresAt = expiresAt
+	return nil
+}
+
+func (t *srpcType) ReleaseUploadLease(conn *srpc.Conn,
+	request imageserver.ReleaseUploadLeaseRequest,
+	reply *imageserver.ReleaseUploadLeaseResponse) error {
+	t.logger.Printf("ReleaseUploadLease(%s) by %s\n", request.LeaseId,
+		conn.Username())
+	err := t.imageDataBase.ReleaseUploadLease(request.LeaseId)
+	reply.Error = errors.ErrorToString(err)
+	return nil
+}
@@ -0,0 +1,23 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func (t *srpcType) VerifyImageObjects(conn *srpc.Conn,
+	request imageserver.VerifyImageObjectsRequest,
+	reply *imageserver.VerifyImageObjectsResponse) error {
+	images, numImages, numObjects, err := t.imageDataBase.VerifyImageObjects(
+		request.ImageNames)
+	reply.Error = errors.ErrorToString(err)
+	reply.Images = images
+	reply.NumImages = numImages
+	reply.NumObjects = numObjects
+	if len(images) > 0 {
+		t.logger.Printf("VerifyImageObjects: %d images have missing objects\n",
+			len(images))
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+const maxAliasHistory = 100
+
+func copyAlias(alias *image.Alias) image.Alias {
+	newAlias := *alias
+	newAlias.History = make([]image.AliasChange, len(alias.History))
+	copy(newAlias.History, alias.History)
+	return newAlias
+}
+
+func lastAliasChange(alias *image.Alias) image.AliasChange {
+	if len(alias.History) < 1 {
+		return image.AliasChange{ImageName: alias.ImageName}
+	}
+	return alias.History[len(alias.History)-1]
+}
+
+func (imdb *ImageDataBase) loadAliases() error {
+	file, err := os.Open(path.Join(imdb.baseDir, aliasesFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	reader := fsutil.NewChecksumReader(bufio.NewReader(file))
+	var aliases []image.Alias
+	if err := gob.NewDecoder(reader).Decode(&aliases); err != nil {
+		return err
+	}
+	if err := reader.VerifyChecksum(); err != nil {
+		return err
+	}
+	for index := range aliases {
+		alias := aliases[index]
+		imdb.aliasMap[alias.Name] = &alias
+	}
+	return nil
+}
+
+// This must be called with the lock held.
+func (imdb *ImageDataBase) checkAliasPermissions(alias *image.Alias,
+	ownerGroup string, authInfo *srpc.AuthInformation) error {
+	if authInfo == nil {
+		return errNoAuthInfo
+	}
+	if authInfo.HaveMethodAccess {
+		return nil
+	}
+	if alias != nil {
+		if alias.OwnerGroup == "" {
+			return errNoAccess
+		}
+		if _, ok := authInfo.GroupList[alias.OwnerGroup]; !ok {
+			return fmt.Errorf("no membership of %s group", alias.OwnerGroup)
+		}
+	} else if ownerGroup == "" {
+		return errors.New("owner group required for new alias")
+	}
+	if ownerGroup != "" {
+		if _, ok := authInfo.GroupList[ownerGroup]; !ok {
+			return fmt.Errorf("no membership of %s group", ownerGroup)
+		}
+	}
+	return nil
+}
+
+func (imdb *ImageDataBase) countAliases() uint {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	return uint(len(imdb.aliasMap))
+}
+
+// deleteAliasesForImage will delete the aliases which refer to an image which
+// is being deleted.
+// This must be called with the lock held.
+func (imdb *ImageDataBase) deleteAliasesForImage(imageName string) {
+	var aliasNames []string
+	for name, alias := range imdb.aliasMap {
+		if alias.ImageName == imageName {
+			aliasNames = append(aliasNames, name)
+			delete(imdb.aliasMap, name)
+		}
+	}
+	if len(aliasNames) < 1 {
+		return
+	}
+	for _, name := range aliasNames {
+		imdb.logger.Printf("Deleting alias: %s for deleted image: %s\n",
+			name, imageName)
+	}
+	if err := imdb.saveAliases(); err != nil {
+		imdb.logger.Printf("Error saving aliases: %s\n", err)
+	}
+}
+
+func (imdb *ImageDataBase) getAlias(name string) (image.Alias, bool) {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	if alias, ok := imdb.aliasMap[name]; ok {
+		return copyAlias(alias), true
+	}
+	return image.Alias{}, false
+}
+
+func (imdb *ImageDataBase) listAliases() []image.Alias {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	aliases := make([]image.Alias, 0, len(imdb.aliasMap))
+	for _, alias := range imdb.aliasMap {
+		aliases = append(aliases, copyAlias(alias))
+	}
+	return aliases
+}
+
+func (imdb *ImageDataBase) resolveImageName(name string) (string, error) {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	if _, ok := imdb.imageMap[name]; ok {
+		return name, nil
+	}
+	if alias, ok := imdb.aliasMap[name]; ok {
+		return alias.ImageName, nil
+	}
+	return "", errors.New("image or alias: " + name + " does not exist")
+}
+
+func (imdb *ImageDataBase) setAlias(aliasName, imageName, ownerGroup string,
+	authInfo *srpc.AuthInformation) error {
+	aliasName = filepath.Clean(aliasName)
+	if aliasName == "." || aliasName[0] == '.' || aliasName[0] == '/' {
+		return errors.New("bad alias name: " + aliasName)
+	}
+	imdb.Lock()
+	defer imdb.Unlock()
+	if _, ok := imdb.imageMap[aliasName]; ok {
+		return errors.New("alias: " + aliasName + " conflicts with image")
+	}
+	if _, ok := imdb.directoryMap[aliasName]; ok {
+		return errors.New("alias: " + aliasName + " conflicts with directory")
+	}
+	if _, ok := imdb.imageMap[imageName]; !ok {
+		return errors.New("image: " + imageName + " does not exist")
+	}
+	oldAlias := imdb.aliasMap[aliasName]
+	err := imdb.checkAliasPermissions(oldAlias, ownerGroup, authInfo)
+	if err != nil {
+		return err
+	}
+	var alias image.Alias
+	if oldAlias == nil {
+		alias.Name = aliasName
+	} else {
+		alias = copyAlias(oldAlias)
+	}
+	alias.ImageName = imageName
+	if ownerGroup != "" {
+		alias.OwnerGroup = ownerGroup
+	}
+	alias.History = append(alias.History, image.AliasChange{
+		ImageName: imageName,
+		MovedAt:   time.Now(),
+		MovedBy:   authInfo.Username,
+	})
+	if len(alias.History) > maxAliasHistory {
+		alias.History = alias.History[len(alias.History)-maxAliasHistory:]
+	}
+	return imdb.storeAlias(alias)
+}
+
+// This must be called with the lock held.
+func (imdb *ImageDataBase) storeAlias(alias image.Alias) error {
+	oldAlias := imdb.aliasMap[alias.Name]
+	imdb.aliasMap[alias.Name] = &alias
+	if err := imdb.saveAliases(); err != nil {
+		if oldAlias == nil {
+			delete(imdb.aliasMap, alias.Name)
+		} else {
+			imdb.aliasMap[alias.Name] = oldAlias
+		}
+		return err
+	}
+	imdb.aliasNotifiers.sendAlias(copyAlias(&alias), imdb.logger)
+	return nil
+}
+
+func (imdb *ImageDataBase) updateAlias(alias image.Alias) (bool, error) {
+	imdb.Lock()
+	defer imdb.Unlock()
+	if oldAlias, ok := imdb.aliasMap[alias.Name]; ok {
+		oldChange := lastAliasChange(oldAlias)
+		newChange := lastAliasChange(&alias)
+		if newChange.ImageName == oldChange.ImageName &&
+			newChange.MovedAt.Equal(oldChange.MovedAt) &&
+			alias.OwnerGroup == oldAlias.OwnerGroup {
+			return false, nil
+		}
+		if newChange.MovedAt.Before(oldChange.MovedAt) {
+			return false, nil
+		}
+	}
+	if err := imdb.storeAlias(alias); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (imdb *ImageDataBase) registerAliasNotifier() <-chan image.Alias {
+	channel := make(chan image.Alias, 1)
+	imdb.Lock()
+	defer imdb.Unlock()
+	imdb.aliasNotifiers[channel] = channel
+	return channel
+}
+
+func (imdb *ImageDataBase) unregisterAliasNotifier(channel <-chan image.Alias) {
+	imdb.Lock()
+	defer imdb.Unlock()
+	delete(imdb.aliasNotifiers, channel)
+}
+
+// This must be called with the lock held.
+func (imdb *ImageDataBase) saveAliases() error {
+	aliases := make([]image.Alias, 0, len(imdb.aliasMap))
+	for _, alias := range imdb.aliasMap {
+		aliases = append(aliases, *alias)
+	}
+	file, err := fsutil.CreateRenamingWriter(
+		path.Join(imdb.baseDir, aliasesFile), filePerms)
+	if err != nil {
+		return err
+	}
+	if err := writeAliases(file, aliases); err != nil {
+		file.Abort()
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeAliases(file io.Writer, aliases []image.Alias) error {
+	w := bufio.NewWriter(file)
+	writer := fsutil.NewChecksumWriter(w)
+	if err := gob.NewEncoder(writer).Encode(aliases); err != nil {
+		return err
+	}
+	if err := writer.WriteChecksum(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (n aliasNotifiers) sendAlias(alias image.Alias, logger log.Logger) {
+	if len(n) < 1 {
+		return
+	} else {
+		plural := "s"
+		if len(n) < 2 {
+			plural = ""
+		}
+		logger.Printf("Sending alias notification to: %d listener%s\n",
+			len(n), plural)
+	}
+	for _, sendChannel := range n {
+		go func(channel chan<- image.Alias) {
+			channel <- alias
+		}(sendChannel)
+	}
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func makeAliasTestDataBase(t *testing.T, dirname string) *ImageDataBase {
+	imdb := &ImageDataBase{
+		baseDir:        dirname,
+		aliasMap:       make(map[string]*image.Alias),
+		directoryMap:   make(map[string]image.DirectoryMetadata),
+		aliasNotifiers: make(aliasNotifiers),
+		logger:         testlogger.New(t),
+	}
+	for _, alias := range []image.Alias{
+		{Name: "prod/web", ImageName: "web/v1"},
+		{Name: "canary/web", ImageName: "web/v1"},
+		{Name: "prod/db", ImageName: "db/v1"},
+	} {
+		alias := alias
+		imdb.aliasMap[alias.Name] = &alias
+	}
+	return imdb
+}
+
+func TestDeleteAliasesForImage(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "scanner-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	var tests = []struct {
+		imageName string
+		want      []string
+	}{
+		{"web/v1", []string{"prod/db"}},
+		{"db/v1", []string{"canary/web", "prod/web"}},
+		{"other/v1", []string{"canary/web", "prod/db", "prod/web"}},
+	}
+	for _, test := range tests {
+		imdb := makeAliasTestDataBase(t, dirname)
+		imdb.deleteAliasesForImage(test.imageName)
+		var aliasNames []string
+		for name := range imdb.aliasMap {
+			aliasNames = append(aliasNames, name)
+		}
+		sort.Strings(aliasNames)
+		if !reflect.DeepEqual(aliasNames, test.want) {
+			t.Errorf("%s: aliases: %v, want %v",
+				test.imageName, aliasNames, test.want)
+		}
+	}
+	// The deletions must be saved.
+	imdb := makeAliasTestDataBase(t, dirname)
+	imdb.deleteAliasesForImage("web/v1")
+	imdb.aliasMap = make(map[string]*image.Alias)
+	if err := imdb.loadAliases(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := imdb.aliasMap["prod/web"]; ok || len(imdb.aliasMap) != 1 {
+		t.Errorf("saved aliases: %v", imdb.aliasMap)
+	}
+}
+
+func TestMakeDirectoryConflictsWithAlias(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "scanner-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	imdb := makeAliasTestDataBase(t, dirname)
+	authInfo := &srpc.AuthInformation{HaveMethodAccess: true}
+	for _, userRpc := range []bool{false, true} {
+		err := imdb.makeDirectory(image.Directory{Name: "prod/web"}, authInfo,
+			userRpc)
+		if err == nil {
+			t.Errorf("userRpc=%t: no error making directory with alias name",
+				userRpc)
+		}
+	}
+	_, err = os.Stat(filepath.Join(dirname, "prod/web"))
+	if !os.IsNotExist(err) {
+		t.Errorf("directory created: %v", err)
+	}
+}
@@ -6,17 +6,20 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Symantec/Dominator/imageserver/audit"
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/stringutil"
+	"github.com/Symantec/Dominator/proto/imageserver"
 )
 
 // TODO: the types should probably be moved into a separate package, leaving
 //       behind the scanner code.
 
+const aliasesFile = ".aliases"
 const metadataFile = ".metadata"
 const unreferencedObjectsFile = ".unreferenced-objects"
 
@@ -25,8 +28,15 @@ var (
 		"maximum number of bytes of unreferenced objects before cleaning")
 	imageServerMaxUnrefAge = flag.Duration("imageServerMaxUnrefAge", 0,
 		"maximum age of unreferenced objects before cleaning")
+	imageServerFileSystemCacheSize = flag.Uint(
+		"imageServerFileSystemCacheSize", 0,
+		"maximum number of image file-systems to keep in memory (0: all)")
+	imageServerDeletedImageLifetime = flag.Duration(
+		"imageServerDeletedImageLifetime", time.Hour*24*30,
+		"how long to remember deleted images for replication peers")
 )
 
+type aliasNotifiers map[<-chan image.Alias]chan<- image.Alias
 type notifiers map[<-chan string]chan<- string
 type makeDirectoryNotifiers map[<-chan image.Directory]chan<- image.Directory
 
@@ -34,26 +44,43 @@ type ImageDataBase struct {
 	sync.RWMutex
 	// Protected by main lock.
 	baseDir             string
+	aliasMap            map[string]*image.Alias
+	deletedImages       map[string]time.Time // Deletion time.
 	directoryMap        map[string]image.DirectoryMetadata
 	imageMap            map[string]*image.Image
 	addNotifiers        notifiers
+	aliasNotifiers      aliasNotifiers
 	deleteNotifiers     notifiers
 	mkdirNotifiers      makeDirectoryNotifiers
+	objectRefCounts     map[hash.Hash]uint   // Number of referencing images.
+	objectsDigests      map[string]hash.Hash // Cached, per image.
 	unreferencedObjects *unreferencedObjectsList
 	// Unprotected by main lock.
 	deduperLock      sync.Mutex
 	deduper          *stringutil.StringDeduplicator
 	pendingImageLock sync.Mutex
 	objectFetchLock  sync.Mutex
+	usageLock        sync.Mutex // Protect usage.
+	usage            *usageReport
+	leaseLock        sync.Mutex // Protect leases. Held while collecting.
+	leases           map[string]*uploadLease
+	pinnedObjects    map[hash.Hash]uint
+	fsCache          *fileSystemCache // nil: all file-systems in memory.
 	// Unprotected by any lock.
+	auditLog          *audit.Log
+	loadStats         loadStatistics // Unchanged after loading.
 	objectServer      objectserver.FullObjectServer
 	replicationMaster string
 	logger            log.DebugLogger
 }
 
+// LoadImageDataBase will load the image database from baseDir. Images which
+// expire are deleted and recorded in auditLog, which may be nil.
 func LoadImageDataBase(baseDir string, objSrv objectserver.FullObjectServer,
-	replicationMaster string, logger log.DebugLogger) (*ImageDataBase, error) {
-	return loadImageDataBase(baseDir, objSrv, replicationMaster, logger)
+	replicationMaster string, auditLog *audit.Log,
+	logger log.DebugLogger) (*ImageDataBase, error) {
+	return loadImageDataBase(baseDir, objSrv, replicationMaster, auditLog,
+		logger)
 }
 
 func (imdb *ImageDataBase) AddImage(image *image.Image, name string,
@@ -61,6 +88,14 @@ func (imdb *ImageDataBase) AddImage(image *image.Image, name string,
 	return imdb.addImage(image, name, authInfo)
 }
 
+// ReplaceImage will add an image, replacing any existing image with the same
+// name. This is used to resolve replication conflicts. Delete notifications are
+// not sent for the replaced image.
+func (imdb *ImageDataBase) ReplaceImage(image *image.Image, name string,
+	authInfo *srpc.AuthInformation) error {
+	return imdb.addOrReplaceImage(image, name, authInfo, true)
+}
+
 func (imdb *ImageDataBase) ChangeImageExpiration(name string,
 	expiresAt time.Time, authInfo *srpc.AuthInformation) (bool, error) {
 	return imdb.changeImageExpiration(name, expiresAt, authInfo)
@@ -74,11 +109,31 @@ func (imdb *ImageDataBase) CheckImage(name string) bool {
 	return imdb.checkImage(name)
 }
 
+// CheckQuota will check if adding the image would exceed the hard quota of
+// the enclosing directory with a quota. Exceeding the soft quota is logged.
+func (imdb *ImageDataBase) CheckQuota(image *image.Image, name string) error {
+	return imdb.checkQuota(image, name)
+}
+
 func (imdb *ImageDataBase) ChownDirectory(dirname, ownerGroup string,
 	authInfo *srpc.AuthInformation) error {
 	return imdb.chownDirectory(dirname, ownerGroup, authInfo)
 }
 
+// CreateUploadLease will create a lease which protects objects from the
+// garbage collector while an image is being uploaded. Objects added to the
+// object server after the lease is created are protected, as are the
+// specified objects. The lease expires after timeout (0 means the default),
+// unless extended or released. The lease ID and expiration time are returned.
+func (imdb *ImageDataBase) CreateUploadLease(objects []hash.Hash,
+	timeout time.Duration, username string) (string, time.Time, error) {
+	return imdb.createUploadLease(objects, timeout, username)
+}
+
+func (imdb *ImageDataBase) CountAliases() uint {
+	return imdb.countAliases()
+}
+
 func (imdb *ImageDataBase) CountDirectories() uint {
 	return imdb.countDirectories()
 }
@@ -94,9 +149,8 @@ func (imdb *ImageDataBase) DeleteImage(name string,
 
 // DeleteUnreferencedObjects will delete some or all unreferenced objects.
 // Objects are randomly selected for deletion, until both the percentage and
-// bytes thresholds are satisfied.
-// If an image upload/replication is in process this operation is unsafe as it
-// may delete objects that the new image will be using.
+// bytes thresholds are satisfied. Objects protected by upload leases or pinned
+// by pending images are not deleted.
 func (imdb *ImageDataBase) DeleteUnreferencedObjects(percentage uint8,
 	bytes uint64) error {
 	return imdb.deleteUnreferencedObjects(percentage, bytes)
@@ -107,23 +161,84 @@ func (imdb *ImageDataBase) DoWithPendingImage(image *image.Image,
 	return imdb.doWithPendingImage(image, doFunc)
 }
 
+// ExtendUploadLease will add objects to an upload lease and extend its
+// expiration time. The new expiration time is returned.
+func (imdb *ImageDataBase) ExtendUploadLease(leaseId string,
+	objects []hash.Hash, timeout time.Duration) (time.Time, error) {
+	return imdb.extendUploadLease(leaseId, objects, timeout)
+}
+
+// ForEachImageObject will call objectFunc once for each unique object in the
+// named image, including annotation objects (which have a size of zero). If
+// the file-system for the image is not in memory the objects are streamed from
+// disk. If objectFunc returns a non-nil error, processing stops and the error
+// is returned.
+func (imdb *ImageDataBase) ForEachImageObject(name string,
+	objectFunc func(hashVal hash.Hash, size uint64) error) error {
+	return imdb.forEachImageObjectByName(name, objectFunc)
+}
+
 func (imdb *ImageDataBase) FindLatestImage(dirame string,
 	ignoreExpiring bool) (string, error) {
 	return imdb.findLatestImage(dirame, ignoreExpiring)
 }
 
+// GetAlias will return the named alias and true if it exists.
+func (imdb *ImageDataBase) GetAlias(name string) (image.Alias, bool) {
+	return imdb.getAlias(name)
+}
+
+// GetImage will return the named image, including its file-system, or nil if
+// it does not exist. If the file-system is not in memory it is read from disk.
 func (imdb *ImageDataBase) GetImage(name string) *image.Image {
 	return imdb.getImage(name)
 }
 
+// GetImageDeletionTime will return the time the image was deleted and true,
+// or false if the image was not deleted or was added again since.
+func (imdb *ImageDataBase) GetImageDeletionTime(name string) (
+	time.Time, bool) {
+	return imdb.getImageDeletionTime(name)
+}
+
+// GetImageMetadata will return the named image, or nil if it does not exist.
+// The FileSystem field may be nil and should be ignored. No disk reads are
+// performed. The returned image must not be modified.
+func (imdb *ImageDataBase) GetImageMetadata(name string) *image.Image {
+	return imdb.getImageMetadata(name)
+}
+
+// GetObjectsDigest will return a digest of the objects in the named image,
+// which is independent of the order of the objects. The digest is cached until
+// the image is deleted or replaced.
+func (imdb *ImageDataBase) GetObjectsDigest(name string) (hash.Hash, error) {
+	return imdb.getObjectsDigest(name)
+}
+
 func (imdb *ImageDataBase) GetUnreferencedObjectsStatistics() (uint64, uint64) {
 	return imdb.getUnreferencedObjectsStatistics()
 }
 
+// GetUsage will return the storage usage per directory and per owner group.
+func (imdb *ImageDataBase) GetUsage() ([]imageserver.DirectoryUsage,
+	[]imageserver.GroupUsage) {
+	return imdb.getUsage()
+}
+
+func (imdb *ImageDataBase) ListAliases() []image.Alias {
+	return imdb.listAliases()
+}
+
 func (imdb *ImageDataBase) ListDirectories() []image.Directory {
 	return imdb.listDirectories()
 }
 
+// ListDeletedImages will return the deletion times of the images which were
+// deleted and not added again since.
+func (imdb *ImageDataBase) ListDeletedImages() map[string]time.Time {
+	return imdb.listDeletedImages()
+}
+
 func (imdb *ImageDataBase) ListImages() []string {
 	return imdb.listImages()
 }
@@ -137,6 +252,11 @@ func (imdb *ImageDataBase) ListUnreferencedObjects() map[hash.Hash]uint64 {
 	return imdb.listUnreferencedObjects()
 }
 
+// ListUploadLeases will return the list of unexpired upload leases.
+func (imdb *ImageDataBase) ListUploadLeases() []imageserver.UploadLease {
+	return imdb.listUploadLeases()
+}
+
 func (imdb *ImageDataBase) MakeDirectory(dirname string,
 	authInfo *srpc.AuthInformation) error {
 	return imdb.makeDirectory(image.Directory{Name: dirname}, authInfo, true)
@@ -146,10 +266,21 @@ func (imdb *ImageDataBase) ObjectServer() objectserver.ObjectServer {
 	return imdb.objectServer
 }
 
+// PinImageObjects will protect the objects for an image from the garbage
+// collector until the returned function is called. Objects which are present
+// when PinImageObjects returns will not be deleted while pinned.
+func (imdb *ImageDataBase) PinImageObjects(image *image.Image) func() {
+	return imdb.pinImageObjects(image)
+}
+
 func (imdb *ImageDataBase) RegisterAddNotifier() <-chan string {
 	return imdb.registerAddNotifier()
 }
 
+func (imdb *ImageDataBase) RegisterAliasNotifier() <-chan image.Alias {
+	return imdb.registerAliasNotifier()
+}
+
 func (imdb *ImageDataBase) RegisterDeleteNotifier() <-chan string {
 	return imdb.registerDeleteNotifier()
 }
@@ -158,10 +289,42 @@ func (imdb *ImageDataBase) RegisterMakeDirectoryNotifier() <-chan image.Director
 	return imdb.registerMakeDirectoryNotifier()
 }
 
+// ReleaseUploadLease will release an upload lease, typically after the image
+// has been added.
+func (imdb *ImageDataBase) ReleaseUploadLease(leaseId string) error {
+	return imdb.releaseUploadLease(leaseId)
+}
+
+// ResolveImageName will return the name of the image an alias refers to. If
+// name is the name of an image it is returned unchanged.
+func (imdb *ImageDataBase) ResolveImageName(name string) (string, error) {
+	return imdb.resolveImageName(name)
+}
+
+// SetDirectoryQuota will set the soft and hard quotas (in bytes) for a
+// directory and its subdirectories. A quota of zero means no quota.
+func (imdb *ImageDataBase) SetDirectoryQuota(dirname string,
+	softQuotaBytes, hardQuotaBytes uint64) error {
+	return imdb.setDirectoryQuota(dirname, softQuotaBytes, hardQuotaBytes)
+}
+
+// SetAlias will create or move an alias to the specified image. If ownerGroup
+// is not empty the owner group of the alias is set. The move is recorded in
+// the alias history.
+func (imdb *ImageDataBase) SetAlias(aliasName, imageName, ownerGroup string,
+	authInfo *srpc.AuthInformation) error {
+	return imdb.setAlias(aliasName, imageName, ownerGroup, authInfo)
+}
+
 func (imdb *ImageDataBase) UnregisterAddNotifier(channel <-chan string) {
 	imdb.unregisterAddNotifier(channel)
 }
 
+func (imdb *ImageDataBase) UnregisterAliasNotifier(
+	channel <-chan image.Alias) {
+	imdb.unregisterAliasNotifier(channel)
+}
+
 func (imdb *ImageDataBase) UnregisterDeleteNotifier(channel <-chan string) {
 	imdb.unregisterDeleteNotifier(channel)
 }
@@ -171,10 +334,25 @@ func (imdb *ImageDataBase) UnregisterMakeDirectoryNotifier(
 	imdb.unregisterMakeDirectoryNotifier(channel)
 }
 
+// UpdateAlias will store a replicated alias, if it is newer than the local
+// alias. It returns true if the alias was changed.
+func (imdb *ImageDataBase) UpdateAlias(alias image.Alias) (bool, error) {
+	return imdb.updateAlias(alias)
+}
+
 func (imdb *ImageDataBase) UpdateDirectory(directory image.Directory) error {
 	return imdb.makeDirectory(directory, nil, false)
 }
 
+// VerifyImageObjects will check that all the objects for the specified images
+// (or all images if none are specified) are present in the object server. The
+// images with missing objects are returned, along with the number of images
+// and objects checked.
+func (imdb *ImageDataBase) VerifyImageObjects(names []string) (
+	[]imageserver.ImageMissingObjects, uint64, uint64, error) {
+	return imdb.verifyImageObjects(names)
+}
+
 func (imdb *ImageDataBase) WriteHtml(writer io.Writer) {
 	imdb.writeHtml(writer)
 }
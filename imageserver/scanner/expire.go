@@ -5,7 +5,9 @@ import (
 	"path"
 	"time"
 
+	"github.com/Symantec/Dominator/lib/errors"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/proto/imageserver"
 )
 
 func imageIsExpired(image *image.Image) bool {
@@ -27,11 +29,36 @@ func (imdb *ImageDataBase) expireImage(image *image.Image, name string) {
 	}
 	imdb.Lock()
 	defer imdb.Unlock()
+	if img, ok := imdb.imageMap[name]; ok && img != image {
+		return // Image was replaced.
+	}
 	imdb.logger.Printf("Auto expiring (deleting) image: %s\n", name)
-	if err := os.Remove(path.Join(imdb.baseDir, name)); err != nil {
+	err := os.Remove(path.Join(imdb.baseDir, name))
+	if err != nil {
 		imdb.logger.Println(err)
 	}
 	imdb.deleteImageAndUpdateUnreferencedObjectsList(name)
+	imdb.deleteAliasesForImage(name)
+	imdb.recordExpiration(name, image.ExpiresAt, err)
+}
+
+// recordExpiration will record the deletion of an expired image in the audit
+// log.
+func (imdb *ImageDataBase) recordExpiration(name string, expiresAt time.Time,
+	err error) {
+	if imdb.auditLog == nil {
+		return
+	}
+	err = imdb.auditLog.Record(imageserver.AuditEntry{
+		Operation: imageserver.AuditOperationExpireImage,
+		Name:      name,
+		Detail:    "expired at: " + expiresAt.Format(time.RFC3339),
+		Error:     errors.ErrorToString(err),
+	})
+	if err != nil {
+		imdb.logger.Printf("Error recording expiration of: %s: %s\n",
+			name, err)
+	}
 }
 
 // This may be called with the lock held.
@@ -47,3 +74,40 @@ func (imdb *ImageDataBase) scheduleExpiration(image *image.Image,
 	time.AfterFunc(duration, func() { imdb.expireImage(image, name) })
 	return
 }
+
+// This must not be called with the lock held.
+func (imdb *ImageDataBase) expireDeletedImage(name string,
+	deletedAt time.Time) {
+	imdb.Lock()
+	defer imdb.Unlock()
+	if t, ok := imdb.deletedImages[name]; !ok || !t.Equal(deletedAt) {
+		return // Image was added or deleted again.
+	}
+	delete(imdb.deletedImages, name)
+	err := os.Remove(path.Join(imdb.baseDir, name))
+	if err != nil && !os.IsNotExist(err) {
+		imdb.logger.Println(err)
+	}
+}
+
+// This is called while loading, before the database is shared.
+func (imdb *ImageDataBase) loadDeletedImage(name string, deletedAt time.Time) {
+	if time.Since(deletedAt) >= *imageServerDeletedImageLifetime {
+		err := os.Remove(path.Join(imdb.baseDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			imdb.logger.Println(err)
+		}
+		return
+	}
+	imdb.deletedImages[name] = deletedAt
+	imdb.scheduleDeletedImageExpiration(name, deletedAt)
+}
+
+// This may be called with the lock held.
+func (imdb *ImageDataBase) scheduleDeletedImageExpiration(name string,
+	deletedAt time.Time) {
+	duration := time.Until(deletedAt.Add(*imageServerDeletedImageLifetime))
+	time.AfterFunc(duration, func() {
+		imdb.expireDeletedImage(name, deletedAt)
+	})
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+)
+
+func TestExpireDeletedImage(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "scanner-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	deletedAt := time.Now().Add(-time.Hour)
+	var tests = []struct {
+		name        string
+		deletedAt   time.Time // Zero: not deleted.
+		expireAt    time.Time
+		wantRemoved bool
+	}{
+		{"expired", deletedAt, deletedAt, true},
+		{"deleted again", deletedAt.Add(time.Minute), deletedAt, false},
+		{"added again", time.Time{}, deletedAt, false},
+	}
+	for _, test := range tests {
+		filename := filepath.Join(dirname, test.name)
+		if err := ioutil.WriteFile(filename, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		imdb := &ImageDataBase{
+			baseDir:       dirname,
+			deletedImages: make(map[string]time.Time),
+			logger:        testlogger.New(t),
+		}
+		if !test.deletedAt.IsZero() {
+			imdb.deletedImages[test.name] = test.deletedAt
+		}
+		imdb.expireDeletedImage(test.name, test.expireAt)
+		_, err := os.Stat(filename)
+		if removed := os.IsNotExist(err); removed != test.wantRemoved {
+			t.Errorf("%s: removed: %t, want %t",
+				test.name, removed, test.wantRemoved)
+		}
+		_, ok := imdb.getImageDeletionTime(test.name)
+		wantOk := !test.deletedAt.IsZero() && !test.wantRemoved
+		if ok != wantOk {
+			t.Errorf("%s: still deleted: %t, want %t", test.name, ok, wantOk)
+		}
+	}
+}
+
+func TestLoadDeletedImage(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "scanner-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	lifetime := *imageServerDeletedImageLifetime
+	var tests = []struct {
+		name        string
+		age         time.Duration
+		wantRemoved bool
+	}{
+		{"recent", time.Hour, false},
+		{"old", lifetime + time.Hour, true},
+	}
+	imdb := &ImageDataBase{
+		baseDir:       dirname,
+		deletedImages: make(map[string]time.Time),
+		logger:        testlogger.New(t),
+	}
+	for _, test := range tests {
+		filename := filepath.Join(dirname, test.name)
+		if err := ioutil.WriteFile(filename, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		imdb.loadDeletedImage(test.name, time.Now().Add(-test.age))
+		_, err := os.Stat(filename)
+		if removed := os.IsNotExist(err); removed != test.wantRemoved {
+			t.Errorf("%s: removed: %t, want %t",
+				test.name, removed, test.wantRemoved)
+		}
+		_, ok := imdb.getImageDeletionTime(test.name)
+		if ok == test.wantRemoved {
+			t.Errorf("%s: remembered: %t, want %t",
+				test.name, ok, !test.wantRemoved)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package scanner
+
+import (
+	"sync"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+// fileSystemCache is a LRU cache of image file-systems, used when the
+// file-systems are not all kept in memory.
+type fileSystemCache struct {
+	lock      sync.Mutex // Protect everything below.
+	capacity  uint
+	entries   map[string]*fileSystemCacheEntry
+	newest    *fileSystemCacheEntry
+	oldest    *fileSystemCacheEntry
+	numHits   uint64
+	numMisses uint64
+}
+
+type fileSystemCacheEntry struct {
+	name       string
+	image      *image.Image // The metadata the file-system belongs to.
+	fileSystem *filesystem.FileSystem
+	newer      *fileSystemCacheEntry
+	older      *fileSystemCacheEntry
+}
+
+type fileSystemCacheStatistics struct {
+	capacity  uint
+	numHits   uint64
+	numMisses uint64
+	size      uint
+}
+
+func newFileSystemCache(capacity uint) *fileSystemCache {
+	return &fileSystemCache{
+		capacity: capacity,
+		entries:  make(map[string]*fileSystemCacheEntry),
+	}
+}
+
+func (cache *fileSystemCache) addNewestWithLock(
+	entry *fileSystemCacheEntry) {
+	entry.older = cache.newest
+	entry.newer = nil
+	if cache.newest == nil {
+		cache.oldest = entry
+	} else {
+		cache.newest.newer = entry
+	}
+	cache.newest = entry
+}
+
+// get will return the cached file-system for the image, or nil if not cached.
+func (cache *fileSystemCache) get(name string,
+	img *image.Image) *filesystem.FileSystem {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	entry := cache.entries[name]
+	if entry == nil || entry.image != img {
+		cache.numMisses++
+		return nil
+	}
+	cache.numHits++
+	cache.removeWithLock(entry)
+	cache.addNewestWithLock(entry)
+	return entry.fileSystem
+}
+
+func (cache *fileSystemCache) getStatistics() fileSystemCacheStatistics {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	return fileSystemCacheStatistics{
+		capacity:  cache.capacity,
+		numHits:   cache.numHits,
+		numMisses: cache.numMisses,
+		size:      uint(len(cache.entries)),
+	}
+}
+
+// put will add the file-system for the image to the cache, evicting the least
+// recently used file-systems if the cache is full.
+func (cache *fileSystemCache) put(name string, img *image.Image,
+	fs *filesystem.FileSystem) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if entry := cache.entries[name]; entry != nil {
+		cache.removeWithLock(entry)
+		delete(cache.entries, name)
+	}
+	entry := &fileSystemCacheEntry{name: name, image: img, fileSystem: fs}
+	cache.entries[name] = entry
+	cache.addNewestWithLock(entry)
+	for uint(len(cache.entries)) > cache.capacity {
+		oldest := cache.oldest
+		cache.removeWithLock(oldest)
+		delete(cache.entries, oldest.name)
+	}
+}
+
+func (cache *fileSystemCache) remove(name string) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if entry := cache.entries[name]; entry != nil {
+		cache.removeWithLock(entry)
+		delete(cache.entries, name)
+	}
+}
+
+func (cache *fileSystemCache) removeWithLock(entry *fileSystemCacheEntry) {
+	if entry.older == nil {
+		cache.oldest = entry.newer
+	} else {
+		entry.older.newer = entry.newer
+	}
+	if entry.newer == nil {
+		cache.newest = entry.older
+	} else {
+		entry.newer.older = entry.older
+	}
+	entry.newer = nil
+	entry.older = nil
+}
+
+// getImageFileSystem will return the file-system for an image whose
+// file-system is not in memory, reading it from the image file if it is not
+// in the cache.
+func (imdb *ImageDataBase) getImageFileSystem(name string,
+	img *image.Image) (*filesystem.FileSystem, error) {
+	if fs := imdb.fsCache.get(name, img); fs != nil {
+		return fs, nil
+	}
+	fs, err := imdb.readImageFileSystem(name)
+	if err != nil {
+		return nil, err
+	}
+	imdb.fsCache.put(name, img, fs)
+	return fs, nil
+}
+
+// storeFileSystem will write the index for a newly added image and move its
+// file-system into the cache. If writing the index fails the file-system is
+// kept in memory. This must be called with the lock held.
+func (imdb *ImageDataBase) storeFileSystem(name string, img *image.Image) {
+	if imdb.fsCache == nil {
+		return
+	}
+	if err := imdb.writeImageIndex(name, img); err != nil {
+		imdb.logger.Printf("Error writing index for image: %s: %s\n",
+			name, err)
+		return
+	}
+	imdb.fsCache.put(name, img, img.FileSystem)
+	img.FileSystem = nil
+}
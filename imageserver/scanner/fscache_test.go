@@ -0,0 +1,173 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+type fileSystemCacheOp struct {
+	op   string // "get", "put" or "remove".
+	name string
+}
+
+// listFileSystemCache will return the names of the cached images, oldest first,
+// checking that the list and the map are consistent.
+func listFileSystemCache(t *testing.T, cache *fileSystemCache) []string {
+	names := make([]string, 0)
+	var newer *fileSystemCacheEntry
+	for entry := cache.oldest; entry != nil; entry = entry.newer {
+		if entry.older != newer {
+			t.Errorf("%s: broken older link", entry.name)
+		}
+		if cache.entries[entry.name] != entry {
+			t.Errorf("%s: not in map", entry.name)
+		}
+		names = append(names, entry.name)
+		newer = entry
+	}
+	if cache.newest != newer {
+		t.Error("newest entry is not the end of the list")
+	}
+	if len(names) != len(cache.entries) {
+		t.Errorf("%d entries in list, %d in map",
+			len(names), len(cache.entries))
+	}
+	return names
+}
+
+func TestFileSystemCacheEviction(t *testing.T) {
+	var tests = []struct {
+		name       string
+		capacity   uint
+		ops        []fileSystemCacheOp
+		wantCached []string // Oldest first.
+		wantHits   uint64
+		wantMisses uint64
+	}{
+		{
+			name:       "empty",
+			capacity:   2,
+			wantCached: []string{},
+		},
+		{
+			name:     "fill",
+			capacity: 2,
+			ops: []fileSystemCacheOp{
+				{"put", "a"}, {"put", "b"},
+			},
+			wantCached: []string{"a", "b"},
+		},
+		{
+			name:     "evict oldest",
+			capacity: 2,
+			ops: []fileSystemCacheOp{
+				{"put", "a"}, {"put", "b"}, {"put", "c"},
+			},
+			wantCached: []string{"b", "c"},
+		},
+		{
+			name:     "get refreshes",
+			capacity: 2,
+			ops: []fileSystemCacheOp{
+				{"put", "a"}, {"put", "b"}, {"get", "a"}, {"put", "c"},
+			},
+			wantCached: []string{"a", "c"},
+			wantHits:   1,
+		},
+		{
+			name:     "put replaces",
+			capacity: 2,
+			ops: []fileSystemCacheOp{
+				{"put", "a"}, {"put", "b"}, {"put", "a"}, {"put", "c"},
+			},
+			wantCached: []string{"a", "c"},
+		},
+		{
+			name:     "get evicted",
+			capacity: 1,
+			ops: []fileSystemCacheOp{
+				{"put", "a"}, {"put", "b"}, {"get", "a"}, {"get", "b"},
+			},
+			wantCached: []string{"b"},
+			wantHits:   1,
+			wantMisses: 1,
+		},
+		{
+			name:     "remove middle",
+			capacity: 3,
+			ops: []fileSystemCacheOp{
+				{"put", "a"}, {"put", "b"}, {"put", "c"}, {"remove", "b"},
+			},
+			wantCached: []string{"a", "c"},
+		},
+		{
+			name:     "remove all",
+			capacity: 3,
+			ops: []fileSystemCacheOp{
+				{"put", "a"}, {"put", "b"}, {"remove", "a"}, {"remove", "b"},
+				{"remove", "missing"},
+			},
+			wantCached: []string{},
+		},
+		{
+			name:     "zero capacity",
+			capacity: 0,
+			ops: []fileSystemCacheOp{
+				{"put", "a"}, {"get", "a"},
+			},
+			wantCached: []string{},
+			wantMisses: 1,
+		},
+	}
+	for _, test := range tests {
+		cache := newFileSystemCache(test.capacity)
+		images := make(map[string]*image.Image)
+		for _, op := range test.ops {
+			img := images[op.name]
+			if img == nil {
+				img = &image.Image{}
+				images[op.name] = img
+			}
+			switch op.op {
+			case "get":
+				if fs := cache.get(op.name, img); fs != nil &&
+					fs != img.FileSystem {
+					t.Errorf("%s: get(%s): wrong file-system",
+						test.name, op.name)
+				}
+			case "put":
+				img.FileSystem = &filesystem.FileSystem{}
+				cache.put(op.name, img, img.FileSystem)
+			case "remove":
+				cache.remove(op.name)
+			}
+		}
+		names := listFileSystemCache(t, cache)
+		if !reflect.DeepEqual(names, test.wantCached) {
+			t.Errorf("%s: cached: %v, want %v",
+				test.name, names, test.wantCached)
+		}
+		stats := cache.getStatistics()
+		if stats.numHits != test.wantHits ||
+			stats.numMisses != test.wantMisses {
+			t.Errorf("%s: hits/misses: %d/%d, want %d/%d", test.name,
+				stats.numHits, stats.numMisses, test.wantHits,
+				test.wantMisses)
+		}
+	}
+}
+
+func TestFileSystemCacheReplacedImage(t *testing.T) {
+	cache := newFileSystemCache(2)
+	oldImage := &image.Image{}
+	cache.put("a", oldImage, &filesystem.FileSystem{})
+	if fs := cache.get("a", &image.Image{}); fs != nil {
+		t.Error("file-system returned for a different image")
+	}
+	if fs := cache.get("a", oldImage); fs == nil {
+		t.Error("file-system not returned for the cached image")
+	}
+}
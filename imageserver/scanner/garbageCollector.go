@@ -9,7 +9,6 @@ import (
 	"path"
 	"time"
 
-	"github.com/Symantec/Dominator/lib/filesystem"
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/hash"
@@ -146,19 +145,35 @@ func (list *unreferencedObjectsList) removeObject(hashVal hash.Hash) bool {
 	return true
 }
 
-func (imdb *ImageDataBase) maybeAddToUnreferencedObjectsList(
-	fs *filesystem.FileSystem) {
-	// First get a list of objects in the image being deleted.
-	objects := fs.GetObjects()
-	// Scan all remaining images and remove their objects from the list.
-	for _, image := range imdb.imageMap {
-		image.ForEachObject(func(hashVal hash.Hash) error {
-			delete(objects, hashVal)
-			return nil
-		})
+// This must be called with the lock held.
+func (imdb *ImageDataBase) addObjectReferences(objects map[hash.Hash]uint64) {
+	for hashVal := range objects {
+		imdb.objectRefCounts[hashVal]++
 	}
+}
+
+// This must be called with the lock held.
+func (imdb *ImageDataBase) removeObjectReferences(
+	objects map[hash.Hash]uint64) {
+	for hashVal := range objects {
+		if count := imdb.objectRefCounts[hashVal]; count > 1 {
+			imdb.objectRefCounts[hashVal] = count - 1
+		} else {
+			delete(imdb.objectRefCounts, hashVal)
+		}
+	}
+}
+
+// maybeAddToUnreferencedObjectsList will add the objects from an image being
+// deleted which are not referenced by the remaining images to the list.
+// This must be called with the lock held.
+func (imdb *ImageDataBase) maybeAddToUnreferencedObjectsList(
+	objects map[hash.Hash]uint64) {
 	changed := false
 	for object, size := range objects {
+		if size < 1 || imdb.objectRefCounts[object] > 0 {
+			continue
+		}
 		if imdb.unreferencedObjects.addObject(object, size) {
 			changed = true
 		}
@@ -234,7 +249,8 @@ func (imdb *ImageDataBase) garbageCollector(bytesToDelete uint64) (
 	return imdb.collectGarbage(bytesToDelete, time.Time{})
 }
 
-// This grabs and releases the lock.
+// This grabs and releases the lock. The lease lock is held while collecting,
+// so that objects pinned after collection starts are not deleted.
 func (imdb *ImageDataBase) collectGarbage(bytesToDelete uint64,
 	deleteBefore time.Time) (uint64, error) {
 	if bytesToDelete < 1 && deleteBefore.IsZero() {
@@ -247,45 +263,49 @@ func (imdb *ImageDataBase) collectGarbage(bytesToDelete uint64,
 		imdb.logger.Printf("Garbage collector deleting: %s or before: %s\n",
 			format.FormatBytes(bytesToDelete), deleteBefore.Format(timeFormat))
 	}
+	imdb.leaseLock.Lock()
+	defer imdb.leaseLock.Unlock()
+	imdb.expireUploadLeases()
 	imdb.Lock()
-	var firstEntryToDelete *unreferencedObjectsEntry
-	entry := imdb.unreferencedObjects.oldest
-	var nObjectsDeleted uint64
-	var nBytesDeleted uint64
-	for ; entry != nil && (nBytesDeleted < bytesToDelete ||
-		entry.object.Age.Before(deleteBefore)); entry = entry.next {
-		firstEntryToDelete = imdb.unreferencedObjects.oldest
-		delete(imdb.unreferencedObjects.hashToEntry, entry.object.Hash)
-		nObjectsDeleted++
-		nBytesDeleted += entry.object.Length
-	}
-	imdb.unreferencedObjects.totalBytes -= nBytesDeleted
-	imdb.unreferencedObjects.oldest = entry
-	if entry == nil {
-		imdb.unreferencedObjects.newest = nil
-	} else if entry.prev != nil {
-		entry.prev.next = nil
-		entry.prev = nil
+	var objectsToDelete []unreferencedObject
+	var nBytesToDelete uint64
+	var nProtected uint
+	for entry := imdb.unreferencedObjects.oldest; entry != nil &&
+		(nBytesToDelete < bytesToDelete ||
+			entry.object.Age.Before(deleteBefore)); {
+		object := entry.object
+		entry = entry.next
+		if imdb.isObjectProtected(object) {
+			nProtected++
+			continue
+		}
+		imdb.unreferencedObjects.removeObject(object.Hash)
+		objectsToDelete = append(objectsToDelete, object)
+		nBytesToDelete += object.Length
 	}
 	imdb.Unlock()
-	if firstEntryToDelete == nil {
+	if nProtected > 0 {
+		imdb.logger.Printf("Garbage collector: skipped %d protected objects\n",
+			nProtected)
+	}
+	if len(objectsToDelete) < 1 {
 		imdb.logger.Println("Garbage collector: nothing to delete")
 		return 0, nil
 	}
-	nBytesDeleted = 0
+	var nBytesDeleted uint64
 	var err error
-	for entry := firstEntryToDelete; entry != nil; entry = entry.next {
-		if e := imdb.objectServer.DeleteObject(entry.object.Hash); e != nil {
+	for _, object := range objectsToDelete {
+		if e := imdb.objectServer.DeleteObject(object.Hash); e != nil {
 			if err == nil {
 				err = e
 			}
 		} else {
-			nBytesDeleted += entry.object.Length
+			nBytesDeleted += object.Length
 		}
 	}
 	imdb.saveUnreferencedObjectsList(true)
 	imdb.logger.Printf("Garbage collector deleted: %s in: %d objects\n",
-		format.FormatBytes(nBytesDeleted), nObjectsDeleted)
+		format.FormatBytes(nBytesDeleted), len(objectsToDelete))
 	return nBytesDeleted, err
 }
 
@@ -307,11 +327,10 @@ func (imdb *ImageDataBase) regenerateUnreferencedObjectsList() {
 	objectsMap := imdb.objectServer.ListObjectSizes()
 	imdb.Lock()
 	defer imdb.Unlock()
-	for _, image := range imdb.imageMap {
-		image.ForEachObject(func(hashVal hash.Hash) error {
+	for hashVal := range objectsMap {
+		if imdb.objectRefCounts[hashVal] > 0 {
 			delete(objectsMap, hashVal)
-			return nil
-		})
+		}
 	}
 	changed := false
 	// Now add unused objects to cached list.
@@ -3,6 +3,8 @@ package scanner
 import (
 	"fmt"
 	"io"
+
+	"github.com/Symantec/Dominator/lib/format"
 )
 
 func (imdb *ImageDataBase) writeHtml(writer io.Writer) {
@@ -14,4 +16,32 @@ func (imdb *ImageDataBase) writeHtml(writer io.Writer) {
 		"Number of  <a href=\"listDirectories?output=text\">directories</a>: "+
 			"<a href=\"listDirectories\">%d</a><br>\n",
 		imdb.CountDirectories())
+	fmt.Fprintf(writer,
+		"Number of  <a href=\"listAliases?output=text\">aliases</a>: "+
+			"<a href=\"listAliases\">%d</a><br>\n",
+		imdb.CountAliases())
+	fmt.Fprintln(writer,
+		"<a href=\"showUsage\">Storage usage and quotas</a><br>")
+	leases := imdb.ListUploadLeases()
+	imdb.leaseLock.Lock()
+	numPinned := len(imdb.pinnedObjects)
+	imdb.leaseLock.Unlock()
+	fmt.Fprintf(writer, "Upload leases: %d, pinned objects: %d<br>\n",
+		len(leases), numPinned)
+	fmt.Fprintf(writer,
+		"Image load time: %s (%s user CPU time), heap after load: %s<br>\n",
+		format.Duration(imdb.loadStats.duration),
+		format.Duration(imdb.loadStats.userTime),
+		format.FormatBytes(imdb.loadStats.heapBytes))
+	if imdb.fsCache == nil {
+		fmt.Fprintln(writer, "All image file-systems in memory<br>")
+		return
+	}
+	stats := imdb.fsCache.getStatistics()
+	fmt.Fprintf(writer,
+		"Images loaded from index: %d, decoded: %d<br>\n",
+		imdb.loadStats.numFromIndex, imdb.loadStats.numDecoded)
+	fmt.Fprintf(writer,
+		"File-system cache: %d/%d, hits: %d, misses: %d<br>\n",
+		stats.size, stats.capacity, stats.numHits, stats.numMisses)
 }
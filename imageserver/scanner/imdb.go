@@ -32,6 +32,11 @@ var (
 
 func (imdb *ImageDataBase) addImage(image *image.Image, name string,
 	authInfo *srpc.AuthInformation) error {
+	return imdb.addOrReplaceImage(image, name, authInfo, false)
+}
+
+func (imdb *ImageDataBase) addOrReplaceImage(image *image.Image, name string,
+	authInfo *srpc.AuthInformation, replace bool) error {
 	if err := image.Verify(); err != nil {
 		return err
 	}
@@ -44,15 +49,17 @@ func (imdb *ImageDataBase) addImage(image *image.Image, name string,
 	imdb.deduperLock.Unlock()
 	imdb.Lock()
 	defer imdb.Unlock()
-	if _, ok := imdb.imageMap[name]; ok {
+	if _, ok := imdb.imageMap[name]; ok && !replace {
 		return errors.New("image: " + name + " already exists")
+	} else if _, ok := imdb.aliasMap[name]; ok {
+		return errors.New("image: " + name + " conflicts with alias")
 	} else {
 		if err := imdb.checkPermissions(name, authInfo); err != nil {
 			return err
 		}
 		filename := filepath.Join(imdb.baseDir, name)
 		flags := os.O_CREATE | os.O_RDWR
-		if imdb.replicationMaster != "" {
+		if imdb.replicationMaster != "" && !replace {
 			flags |= os.O_EXCL
 		} else {
 			flags |= os.O_TRUNC
@@ -67,22 +74,36 @@ func (imdb *ImageDataBase) addImage(image *image.Image, name string,
 		}
 		defer file.Close()
 		w := bufio.NewWriter(file)
-		defer w.Flush()
 		writer := fsutil.NewChecksumWriter(w)
-		defer writer.WriteChecksum()
 		encoder := gob.NewEncoder(writer)
 		if err := encoder.Encode(image); err != nil {
 			os.Remove(filename)
 			return err
 		}
+		if err := writer.WriteChecksum(); err != nil {
+			os.Remove(filename)
+			return err
+		}
 		if err := w.Flush(); err != nil {
 			os.Remove(filename)
 			return err
 		}
+		objects, err := imdb.listImageObjects(name, image)
+		if err != nil {
+			os.Remove(filename)
+			return err
+		}
+		imdb.addObjectReferences(objects)
+		if replace {
+			imdb.deleteImageAndUpdateUnreferencedObjectsList(name)
+		}
+		imdb.removeFromUnreferencedObjectsListAndSave(image)
+		imdb.storeFileSystem(name, image)
 		imdb.scheduleExpiration(image, name)
 		imdb.imageMap[name] = image
+		delete(imdb.deletedImages, name)
+		imdb.invalidateUsage()
 		imdb.addNotifiers.sendPlain(name, "add", imdb.logger)
-		imdb.removeFromUnreferencedObjectsListAndSave(image)
 		return nil
 	}
 }
@@ -211,6 +232,7 @@ func (imdb *ImageDataBase) updateDirectoryMetadata(
 		return err
 	}
 	imdb.directoryMap[directory.Name] = directory.Metadata
+	imdb.invalidateUsage()
 	imdb.mkdirNotifiers.sendMakeDirectory(directory, imdb.logger)
 	return nil
 }
@@ -274,6 +296,10 @@ func (imdb *ImageDataBase) deleteImage(name string,
 			return err
 		}
 		imdb.deleteImageAndUpdateUnreferencedObjectsList(name)
+		imdb.deleteAliasesForImage(name)
+		deletedAt := time.Now()
+		imdb.deletedImages[name] = deletedAt
+		imdb.scheduleDeletedImageExpiration(name, deletedAt)
 		imdb.deleteNotifiers.sendPlain(name, "delete", imdb.logger)
 		return nil
 	} else {
@@ -287,14 +313,40 @@ func (imdb *ImageDataBase) deleteImageAndUpdateUnreferencedObjectsList(
 	if img == nil { // May be nil if expiring an already deleted image.
 		return
 	}
+	objects, err := imdb.listImageObjects(name, img)
 	delete(imdb.imageMap, name)
+	delete(imdb.objectsDigests, name)
+	if imdb.fsCache != nil {
+		imdb.fsCache.remove(name)
+	}
+	imdb.removeImageIndex(name)
+	imdb.invalidateUsage()
 	imdb.rebuildDeDuper()
-	imdb.maybeAddToUnreferencedObjectsList(img.FileSystem)
+	if err != nil {
+		// The objects remain referenced until the next restart.
+		imdb.logger.Printf("Error listing objects for deleted image: %s: %s\n",
+			name, err)
+		return
+	}
+	imdb.removeObjectReferences(objects)
+	imdb.maybeAddToUnreferencedObjectsList(objects)
 }
 
 func (imdb *ImageDataBase) deleteUnreferencedObjects(percentage uint8,
 	bytesThreshold uint64) error {
-	objects := imdb.listUnreferencedObjects()
+	imdb.maybeRegenerateUnreferencedObjectsList()
+	imdb.leaseLock.Lock()
+	defer imdb.leaseLock.Unlock()
+	imdb.expireUploadLeases()
+	imdb.RLock()
+	objects := make(map[hash.Hash]uint64)
+	for entry := imdb.unreferencedObjects.oldest; entry != nil; {
+		if !imdb.isObjectProtected(entry.object) {
+			objects[entry.object.Hash] = entry.object.Length
+		}
+		entry = entry.next
+	}
+	imdb.RUnlock()
 	objectsThreshold := uint64(percentage) * uint64(len(objects)) / 100
 	var objectsCount, bytesCount uint64
 	for hashVal, size := range objects {
@@ -315,6 +367,7 @@ func (imdb *ImageDataBase) doWithPendingImage(image *image.Image,
 	doFunc func() error) error {
 	imdb.pendingImageLock.Lock()
 	defer imdb.pendingImageLock.Unlock()
+	defer imdb.pinImageObjects(image)()
 	imdb.Lock()
 	changed := imdb.removeFromUnreferencedObjectsList(image)
 	imdb.Unlock()
@@ -330,7 +383,9 @@ func (imdb *ImageDataBase) doWithPendingImage(image *image.Image,
 		}
 	}
 	// image was not added: "delete" it by maybe adding to unreferenced list.
-	imdb.maybeAddToUnreferencedObjectsList(image.FileSystem)
+	if image.FileSystem != nil {
+		imdb.maybeAddToUnreferencedObjectsList(image.FileSystem.GetObjects())
+	}
 	return err
 }
 
@@ -359,6 +414,30 @@ func (imdb *ImageDataBase) findLatestImage(dirname string,
 }
 
 func (imdb *ImageDataBase) getImage(name string) *image.Image {
+	img := imdb.getImageMetadata(name)
+	if img == nil || img.FileSystem != nil {
+		return img
+	}
+	fs, err := imdb.getImageFileSystem(name, img)
+	if err != nil {
+		imdb.logger.Printf("Error reading file-system for image: %s: %s\n",
+			name, err)
+		return nil
+	}
+	imgCopy := *img
+	imgCopy.FileSystem = fs
+	return &imgCopy
+}
+
+func (imdb *ImageDataBase) getImageDeletionTime(name string) (
+	time.Time, bool) {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	deletedAt, ok := imdb.deletedImages[name]
+	return deletedAt, ok
+}
+
+func (imdb *ImageDataBase) getImageMetadata(name string) *image.Image {
 	imdb.RLock()
 	defer imdb.RUnlock()
 	return imdb.imageMap[name]
@@ -383,6 +462,16 @@ func (imdb *ImageDataBase) listDirectories() []image.Directory {
 	return directories
 }
 
+func (imdb *ImageDataBase) listDeletedImages() map[string]time.Time {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	deletedImages := make(map[string]time.Time, len(imdb.deletedImages))
+	for name, deletedAt := range imdb.deletedImages {
+		deletedImages[name] = deletedAt
+	}
+	return deletedImages
+}
+
 func (imdb *ImageDataBase) listImages() []string {
 	imdb.RLock()
 	defer imdb.RUnlock()
@@ -406,6 +495,9 @@ func (imdb *ImageDataBase) makeDirectory(directory image.Directory,
 	pathname := filepath.Join(imdb.baseDir, directory.Name)
 	imdb.Lock()
 	defer imdb.Unlock()
+	if _, ok := imdb.aliasMap[directory.Name]; ok {
+		return fmt.Errorf("directory: %s conflicts with alias", directory.Name)
+	}
 	oldDirectoryMetadata, ok := imdb.directoryMap[directory.Name]
 	if userRpc {
 		if authInfo == nil {
@@ -498,6 +590,13 @@ func (imdb *ImageDataBase) writeNewExpiration(name string,
 	oldImage *image.Image, expiresAt time.Time) error {
 	img := *oldImage
 	img.ExpiresAt = expiresAt
+	if img.FileSystem == nil {
+		fs, err := imdb.getImageFileSystem(name, oldImage)
+		if err != nil {
+			return err
+		}
+		img.FileSystem = fs
+	}
 	filename := filepath.Join(imdb.baseDir, name)
 	tmpFilename := filename + "~"
 	file, err := os.OpenFile(tmpFilename, os.O_CREATE|os.O_RDWR|os.O_EXCL,
@@ -521,7 +620,16 @@ func (imdb *ImageDataBase) writeNewExpiration(name string,
 		return err
 	}
 	fsutil.FsyncFile(file)
-	return os.Rename(tmpFilename, filename)
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		return err
+	}
+	if oldImage.FileSystem == nil {
+		if err := imdb.writeImageIndex(name, &img); err != nil {
+			imdb.logger.Printf("Error writing index for image: %s: %s\n",
+				name, err)
+		}
+	}
+	return nil
 }
 
 func (n notifiers) sendPlain(name string, operation string,
@@ -0,0 +1,321 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha512"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+// An image index is a sidecar file which contains the image metadata (without
+// the file-system) and the list of objects referenced by the image. It allows
+// images to be loaded and their objects to be listed without decoding the
+// file-system. The format is:
+//
+//	indexHeader
+//	image.Image (FileSystem is nil)
+//	uint64 (number of objects)
+//	indexObject...
+//	checksum
+const (
+	indexDirectory = ".index"
+	indexVersion   = 1
+)
+
+var errStaleIndex = errors.New("stale image index")
+
+type indexHeader struct {
+	Version         uint
+	ImageSize       int64 // Used to detect a changed image file.
+	ImageModifyTime int64
+}
+
+type indexObject struct {
+	Hash hash.Hash
+	Size uint64 // Zero for annotation objects.
+}
+
+// forEachObject will call objectFunc once for each unique object in the
+// file-system and annotations of the image. Annotation objects have a size of
+// zero.
+func forEachObject(img *image.Image,
+	objectFunc func(hashVal hash.Hash, size uint64) error) error {
+	seen := make(map[hash.Hash]struct{})
+	for _, inode := range img.FileSystem.InodeTable {
+		if inode, ok := inode.(*filesystem.RegularInode); ok {
+			if inode.Size < 1 {
+				continue
+			}
+			if _, ok := seen[inode.Hash]; ok {
+				continue
+			}
+			seen[inode.Hash] = struct{}{}
+			if err := objectFunc(inode.Hash, inode.Size); err != nil {
+				return err
+			}
+		}
+	}
+	return img.ForEachObject(func(hashVal hash.Hash) error {
+		if _, ok := seen[hashVal]; ok {
+			return nil
+		}
+		seen[hashVal] = struct{}{}
+		return objectFunc(hashVal, 0)
+	})
+}
+
+// makeIndexHeader will make an index header for the specified image file.
+func makeIndexHeader(imageFilename string) (indexHeader, error) {
+	stat, err := os.Stat(imageFilename)
+	if err != nil {
+		return indexHeader{}, err
+	}
+	return indexHeader{
+		Version:         indexVersion,
+		ImageSize:       stat.Size(),
+		ImageModifyTime: stat.ModTime().UnixNano(),
+	}, nil
+}
+
+// forEachImageObject will call objectFunc once for each unique object in the
+// image. If the file-system for the image is not in memory the objects are
+// streamed from the image index.
+func (imdb *ImageDataBase) forEachImageObject(name string, img *image.Image,
+	objectFunc func(hashVal hash.Hash, size uint64) error) error {
+	if img.FileSystem != nil {
+		return forEachObject(img, objectFunc)
+	}
+	_, err := imdb.readImageIndex(name, false, objectFunc)
+	return err
+}
+
+func (imdb *ImageDataBase) indexFilename(name string) string {
+	return filepath.Join(imdb.baseDir, indexDirectory, name)
+}
+
+// listImageObjects will return the unique objects in the image and their
+// sizes.
+func (imdb *ImageDataBase) listImageObjects(name string, img *image.Image) (
+	map[hash.Hash]uint64, error) {
+	objects := make(map[hash.Hash]uint64)
+	err := imdb.forEachImageObject(name, img,
+		func(hashVal hash.Hash, size uint64) error {
+			objects[hashVal] = size
+			return nil
+		})
+	return objects, err
+}
+
+// readImageFileSystem will read the file-system for an image from the image
+// file.
+func (imdb *ImageDataBase) readImageFileSystem(name string) (
+	*filesystem.FileSystem, error) {
+	file, err := os.Open(filepath.Join(imdb.baseDir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := fsutil.NewChecksumReader(bufio.NewReader(file))
+	var img image.Image
+	if err := gob.NewDecoder(reader).Decode(&img); err != nil {
+		return nil, err
+	}
+	if err := reader.VerifyChecksum(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if img.FileSystem == nil {
+		return nil, errors.New("no file-system in image: " + name)
+	}
+	if err := img.FileSystem.RebuildInodePointers(); err != nil {
+		return nil, err
+	}
+	return img.FileSystem, nil
+}
+
+// readImageIndex will read the index for an image, calling objectFunc (if not
+// nil) for each object. The image metadata are returned. If checkCurrent is
+// true and the image file has changed since the index was written,
+// errStaleIndex is returned.
+func (imdb *ImageDataBase) readImageIndex(name string, checkCurrent bool,
+	objectFunc func(hashVal hash.Hash, size uint64) error) (
+	*image.Image, error) {
+	file, err := os.Open(imdb.indexFilename(name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := fsutil.NewChecksumReader(bufio.NewReader(file))
+	decoder := gob.NewDecoder(reader)
+	var header indexHeader
+	if err := decoder.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Version != indexVersion {
+		return nil, fmt.Errorf("unsupported index version: %d",
+			header.Version)
+	}
+	if checkCurrent {
+		imageHeader, err := makeIndexHeader(filepath.Join(imdb.baseDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if header != imageHeader {
+			return nil, errStaleIndex
+		}
+	}
+	var img image.Image
+	if err := decoder.Decode(&img); err != nil {
+		return nil, err
+	}
+	var numObjects uint64
+	if err := decoder.Decode(&numObjects); err != nil {
+		return nil, err
+	}
+	for count := uint64(0); count < numObjects; count++ {
+		var object indexObject
+		if err := decoder.Decode(&object); err != nil {
+			return nil, err
+		}
+		if objectFunc != nil {
+			if err := objectFunc(object.Hash, object.Size); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := reader.VerifyChecksum(); err != nil {
+		return nil, err
+	}
+	return &img, nil
+}
+
+func (imdb *ImageDataBase) removeImageIndex(name string) {
+	err := os.Remove(imdb.indexFilename(name))
+	if err != nil && !os.IsNotExist(err) {
+		imdb.logger.Printf("Error removing index for image: %s: %s\n",
+			name, err)
+	}
+}
+
+// writeImageIndex will write the index for an image. The image must include
+// the file-system and the image file must already be written.
+func (imdb *ImageDataBase) writeImageIndex(name string,
+	img *image.Image) error {
+	header, err := makeIndexHeader(filepath.Join(imdb.baseDir, name))
+	if err != nil {
+		return err
+	}
+	objects := make([]indexObject, 0)
+	err = forEachObject(img, func(hashVal hash.Hash, size uint64) error {
+		objects = append(objects, indexObject{Hash: hashVal, Size: size})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	metadata := *img
+	metadata.FileSystem = nil
+	filename := imdb.indexFilename(name)
+	if err := os.MkdirAll(filepath.Dir(filename), dirPerms); err != nil {
+		return err
+	}
+	file, err := fsutil.CreateRenamingWriter(filename, filePerms)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	err = writeIndex(w, header, &metadata, objects)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		file.Abort()
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeIndex(w io.Writer, header indexHeader, metadata *image.Image,
+	objects []indexObject) error {
+	writer := fsutil.NewChecksumWriter(w)
+	encoder := gob.NewEncoder(writer)
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+	if err := encoder.Encode(metadata); err != nil {
+		return err
+	}
+	if err := encoder.Encode(uint64(len(objects))); err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := encoder.Encode(object); err != nil {
+			return err
+		}
+	}
+	return writer.WriteChecksum()
+}
+
+func (imdb *ImageDataBase) forEachImageObjectByName(name string,
+	objectFunc func(hashVal hash.Hash, size uint64) error) error {
+	img := imdb.getImageMetadata(name)
+	if img == nil {
+		return errors.New("unknown image: " + name)
+	}
+	return imdb.forEachImageObject(name, img, objectFunc)
+}
+
+func (imdb *ImageDataBase) getObjectsDigest(name string) (hash.Hash, error) {
+	imdb.RLock()
+	digest, ok := imdb.objectsDigests[name]
+	img := imdb.imageMap[name]
+	imdb.RUnlock()
+	if ok {
+		return digest, nil
+	}
+	if img == nil {
+		return hash.Hash{}, errors.New("unknown image: " + name)
+	}
+	var hashes []hash.Hash
+	err := imdb.forEachImageObject(name, img,
+		func(hashVal hash.Hash, size uint64) error {
+			hashes = append(hashes, hashVal)
+			return nil
+		})
+	if err != nil {
+		return hash.Hash{}, err
+	}
+	digest = makeObjectsDigest(hashes)
+	imdb.Lock()
+	defer imdb.Unlock()
+	if imdb.imageMap[name] == img { // Do not cache if replaced meanwhile.
+		imdb.objectsDigests[name] = digest
+	}
+	return digest, nil
+}
+
+// makeObjectsDigest will return the SHA-512 digest of the sorted object
+// hashes. The hashes are sorted in place.
+func makeObjectsDigest(hashes []hash.Hash) hash.Hash {
+	sort.Slice(hashes, func(left, right int) bool {
+		return bytes.Compare(hashes[left][:], hashes[right][:]) < 0
+	})
+	hasher := sha512.New()
+	for _, hashVal := range hashes {
+		hasher.Write(hashVal[:])
+	}
+	var digest hash.Hash
+	copy(digest[:], hasher.Sum(nil))
+	return digest
+}
@@ -0,0 +1,211 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+)
+
+func makeTestImage(hashes ...hash.Hash) *image.Image {
+	fs := &filesystem.FileSystem{
+		InodeTable: make(filesystem.InodeTable),
+	}
+	for index, hashVal := range hashes {
+		fs.InodeTable[uint64(index+1)] = &filesystem.RegularInode{
+			Size: 1,
+			Hash: hashVal,
+		}
+	}
+	return &image.Image{FileSystem: fs}
+}
+
+func TestMakeObjectsDigest(t *testing.T) {
+	var tests = []struct {
+		left, right []hash.Hash
+		wantEqual   bool
+	}{
+		{nil, nil, true},
+		{[]hash.Hash{{1}, {2}}, []hash.Hash{{2}, {1}}, true},
+		{[]hash.Hash{{1}, {2}}, []hash.Hash{{1}, {3}}, false},
+		{[]hash.Hash{{1}}, []hash.Hash{{1}, {2}}, false},
+	}
+	for _, test := range tests {
+		left := makeObjectsDigest(append([]hash.Hash(nil), test.left...))
+		right := makeObjectsDigest(append([]hash.Hash(nil), test.right...))
+		if (left == right) != test.wantEqual {
+			t.Errorf("%v vs. %v: equal: %t, want %t",
+				test.left, test.right, left == right, test.wantEqual)
+		}
+	}
+}
+
+func TestGetObjectsDigest(t *testing.T) {
+	imdb := &ImageDataBase{
+		imageMap:       make(map[string]*image.Image),
+		objectsDigests: make(map[string]hash.Hash),
+		logger:         testlogger.New(t),
+	}
+	if _, err := imdb.getObjectsDigest("missing"); err == nil {
+		t.Error("no error for missing image")
+	}
+	imdb.imageMap["app/v1"] = makeTestImage(hash.Hash{1}, hash.Hash{2})
+	digest, err := imdb.getObjectsDigest("app/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := makeObjectsDigest([]hash.Hash{{1}, {2}}); digest != want {
+		t.Errorf("digest: %x, want %x", digest, want)
+	}
+	if cached, ok := imdb.objectsDigests["app/v1"]; !ok || cached != digest {
+		t.Error("digest not cached")
+	}
+	// The cached digest is used until it is invalidated.
+	imdb.objectsDigests["app/v1"] = hash.Hash{4}
+	if digest, _ := imdb.getObjectsDigest("app/v1"); digest != (hash.Hash{4}) {
+		t.Errorf("cached digest not used: %x", digest)
+	}
+}
+
+// writeTestImageFile will write an image file the same way addImage does.
+func writeTestImageFile(t *testing.T, imdb *ImageDataBase, name string,
+	img *image.Image) {
+	file, err := os.Create(filepath.Join(imdb.baseDir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	writer := fsutil.NewChecksumWriter(w)
+	if err := gob.NewEncoder(writer).Encode(img); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteChecksum(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImageIndexRoundTrip(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "scanner-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	imdb := &ImageDataBase{baseDir: dirname, logger: testlogger.New(t)}
+	notes := hash.Hash{9}
+	var tests = []struct {
+		name         string
+		files        []indexObject // Regular files in the file-system.
+		releaseNotes *hash.Hash
+		wantObjects  map[hash.Hash]uint64
+	}{
+		{
+			name:        "empty",
+			wantObjects: map[hash.Hash]uint64{},
+		},
+		{
+			name:        "files",
+			files:       []indexObject{{hash.Hash{1}, 10}, {hash.Hash{2}, 20}},
+			wantObjects: map[hash.Hash]uint64{{1}: 10, {2}: 20},
+		},
+		{
+			name: "duplicates and empty files",
+			files: []indexObject{
+				{hash.Hash{1}, 10}, {hash.Hash{1}, 10}, {hash.Hash{}, 0},
+			},
+			wantObjects: map[hash.Hash]uint64{{1}: 10},
+		},
+		{
+			name:         "annotation",
+			files:        []indexObject{{hash.Hash{1}, 10}},
+			releaseNotes: &notes,
+			wantObjects:  map[hash.Hash]uint64{{1}: 10, notes: 0},
+		},
+		{
+			name:         "annotation shared with file",
+			files:        []indexObject{{notes, 30}},
+			releaseNotes: &notes,
+			wantObjects:  map[hash.Hash]uint64{notes: 30},
+		},
+	}
+	for _, test := range tests {
+		fs := &filesystem.FileSystem{
+			InodeTable: make(filesystem.InodeTable),
+		}
+		for index, file := range test.files {
+			fs.InodeTable[uint64(index+1)] = &filesystem.RegularInode{
+				Size: file.Size,
+				Hash: file.Hash,
+			}
+		}
+		img := &image.Image{
+			CreatedBy:  "user",
+			CreatedOn:  time.Unix(1000, 0),
+			FileSystem: fs,
+		}
+		if test.releaseNotes != nil {
+			img.ReleaseNotes = &image.Annotation{Object: test.releaseNotes}
+		}
+		writeTestImageFile(t, imdb, test.name, img)
+		if err := imdb.writeImageIndex(test.name, img); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		objects := make(map[hash.Hash]uint64)
+		metadata, err := imdb.readImageIndex(test.name, true,
+			func(hashVal hash.Hash, size uint64) error {
+				if _, ok := objects[hashVal]; ok {
+					t.Errorf("%s: duplicate object: %x", test.name, hashVal)
+				}
+				objects[hashVal] = size
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !reflect.DeepEqual(objects, test.wantObjects) {
+			t.Errorf("%s: objects: %v, want %v",
+				test.name, objects, test.wantObjects)
+		}
+		if metadata.FileSystem != nil {
+			t.Errorf("%s: file-system in index", test.name)
+		}
+		if metadata.CreatedBy != img.CreatedBy ||
+			!metadata.CreatedOn.Equal(img.CreatedOn) {
+			t.Errorf("%s: metadata not preserved", test.name)
+		}
+		if !reflect.DeepEqual(metadata.ReleaseNotes, img.ReleaseNotes) {
+			t.Errorf("%s: release notes not preserved", test.name)
+		}
+		readFs, err := imdb.readImageFileSystem(test.name)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if len(readFs.InodeTable) != len(test.files) {
+			t.Errorf("%s: %d inodes, want %d",
+				test.name, len(readFs.InodeTable), len(test.files))
+		}
+		// Changing the image file makes the index stale.
+		img.CreatedBy = "another user"
+		writeTestImageFile(t, imdb, test.name, img)
+		_, err = imdb.readImageIndex(test.name, true, nil)
+		if err != errStaleIndex {
+			t.Errorf("%s: stale index: error: %v", test.name, err)
+		}
+		if _, err := imdb.readImageIndex(test.name, false, nil); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package scanner
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+const (
+	defaultUploadLeaseTimeout = 15 * time.Minute
+	maxUploadLeaseTimeout     = 24 * time.Hour
+)
+
+// An uploadLease protects the objects for an image which is being uploaded.
+// All objects added or re-added to the object server after the lease was
+// created (its epoch) are protected, as well as explicitly listed objects.
+type uploadLease struct {
+	createdOn time.Time
+	expiresAt time.Time
+	objects   map[hash.Hash]struct{}
+	username  string
+}
+
+func computeLeaseExpiration(timeout time.Duration) (time.Time, error) {
+	if timeout < 0 {
+		return time.Time{}, errors.New("negative lease timeout")
+	}
+	if timeout == 0 {
+		timeout = defaultUploadLeaseTimeout
+	}
+	if timeout > maxUploadLeaseTimeout {
+		return time.Time{}, fmt.Errorf("lease timeout: %s exceeds maximum: %s",
+			timeout, maxUploadLeaseTimeout)
+	}
+	return time.Now().Add(timeout), nil
+}
+
+func (lease *uploadLease) addObjects(objects []hash.Hash) {
+	for _, hashVal := range objects {
+		lease.objects[hashVal] = struct{}{}
+	}
+}
+
+func (imdb *ImageDataBase) createUploadLease(objects []hash.Hash,
+	timeout time.Duration, username string) (string, time.Time, error) {
+	expiresAt, err := computeLeaseExpiration(timeout)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", time.Time{}, err
+	}
+	leaseId := fmt.Sprintf("%x", buffer)
+	lease := &uploadLease{
+		createdOn: time.Now(),
+		expiresAt: expiresAt,
+		objects:   make(map[hash.Hash]struct{}, len(objects)),
+		username:  username,
+	}
+	lease.addObjects(objects)
+	imdb.leaseLock.Lock()
+	defer imdb.leaseLock.Unlock()
+	imdb.expireUploadLeases()
+	imdb.leases[leaseId] = lease
+	return leaseId, expiresAt, nil
+}
+
+// This must be called with the lease lock held.
+func (imdb *ImageDataBase) expireUploadLeases() {
+	now := time.Now()
+	for leaseId, lease := range imdb.leases {
+		if now.After(lease.expiresAt) {
+			imdb.logger.Printf("Upload lease: %s for: %s expired\n",
+				leaseId, lease.username)
+			delete(imdb.leases, leaseId)
+		}
+	}
+}
+
+func (imdb *ImageDataBase) extendUploadLease(leaseId string,
+	objects []hash.Hash, timeout time.Duration) (time.Time, error) {
+	expiresAt, err := computeLeaseExpiration(timeout)
+	if err != nil {
+		return time.Time{}, err
+	}
+	imdb.leaseLock.Lock()
+	defer imdb.leaseLock.Unlock()
+	imdb.expireUploadLeases()
+	lease, ok := imdb.leases[leaseId]
+	if !ok {
+		return time.Time{}, errors.New("unknown upload lease: " + leaseId)
+	}
+	lease.addObjects(objects)
+	if expiresAt.After(lease.expiresAt) {
+		lease.expiresAt = expiresAt
+	}
+	return lease.expiresAt, nil
+}
+
+// This must be called with the lease lock held.
+func (imdb *ImageDataBase) isObjectProtected(object unreferencedObject) bool {
+	if imdb.pinnedObjects[object.Hash] > 0 {
+		return true
+	}
+	for _, lease := range imdb.leases {
+		if !object.Age.Before(lease.createdOn) {
+			return true
+		}
+		if _, ok := lease.objects[object.Hash]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (imdb *ImageDataBase) listUploadLeases() []imageserver.UploadLease {
+	imdb.leaseLock.Lock()
+	defer imdb.leaseLock.Unlock()
+	imdb.expireUploadLeases()
+	leases := make([]imageserver.UploadLease, 0, len(imdb.leases))
+	for _, lease := range imdb.leases {
+		leases = append(leases, imageserver.UploadLease{
+			CreatedOn:  lease.createdOn,
+			ExpiresAt:  lease.expiresAt,
+			NumObjects: uint64(len(lease.objects)),
+			Username:   lease.username,
+		})
+	}
+	sort.Slice(leases, func(left, right int) bool {
+		return leases[left].CreatedOn.Before(leases[right].CreatedOn)
+	})
+	return leases
+}
+
+// pinImageObjects will protect the objects for an image from the garbage
+// collector until the returned function is called. If a garbage collection is
+// in progress, it waits for it to complete, so that objects which are present
+// after pinImageObjects returns remain present.
+func (imdb *ImageDataBase) pinImageObjects(img *image.Image) func() {
+	objects := img.ListObjects()
+	imdb.leaseLock.Lock()
+	defer imdb.leaseLock.Unlock()
+	for _, hashVal := range objects {
+		imdb.pinnedObjects[hashVal]++
+	}
+	return func() {
+		imdb.leaseLock.Lock()
+		defer imdb.leaseLock.Unlock()
+		for _, hashVal := range objects {
+			if count := imdb.pinnedObjects[hashVal]; count > 1 {
+				imdb.pinnedObjects[hashVal] = count - 1
+			} else {
+				delete(imdb.pinnedObjects, hashVal)
+			}
+		}
+	}
+}
+
+func (imdb *ImageDataBase) releaseUploadLease(leaseId string) error {
+	imdb.leaseLock.Lock()
+	defer imdb.leaseLock.Unlock()
+	if _, ok := imdb.leases[leaseId]; !ok {
+		return errors.New("unknown upload lease: " + leaseId)
+	}
+	delete(imdb.leases, leaseId)
+	return nil
+}
+
+func (imdb *ImageDataBase) verifyImageObjects(names []string) (
+	[]imageserver.ImageMissingObjects, uint64, uint64, error) {
+	images := make(map[string]*image.Image)
+	imdb.RLock()
+	if len(names) < 1 {
+		for name, img := range imdb.imageMap {
+			images[name] = img
+		}
+	} else {
+		for _, name := range names {
+			img, ok := imdb.imageMap[name]
+			if !ok {
+				imdb.RUnlock()
+				return nil, 0, 0, errors.New("unknown image: " + name)
+			}
+			images[name] = img
+		}
+	}
+	imdb.RUnlock()
+	objectToImages := make(map[hash.Hash][]string)
+	var numImages uint64
+	for name, img := range images {
+		err := imdb.forEachImageObject(name, img,
+			func(hashVal hash.Hash, size uint64) error {
+				objectToImages[hashVal] = append(objectToImages[hashVal],
+					name)
+				return nil
+			})
+		if err != nil {
+			if !imdb.checkImage(name) {
+				continue // Deleted since the list was made.
+			}
+			return nil, 0, 0, fmt.Errorf("error listing objects for: %s: %s",
+				name, err)
+		}
+		numImages++
+	}
+	hashes := make([]hash.Hash, 0, len(objectToImages))
+	for hashVal := range objectToImages {
+		hashes = append(hashes, hashVal)
+	}
+	sizes, err := imdb.objectServer.CheckObjects(hashes)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	missingPerImage := make(map[string][]hash.Hash)
+	for index, size := range sizes {
+		if size > 0 {
+			continue
+		}
+		hashVal := hashes[index]
+		for _, name := range objectToImages[hashVal] {
+			missingPerImage[name] = append(missingPerImage[name], hashVal)
+		}
+	}
+	results := make([]imageserver.ImageMissingObjects, 0,
+		len(missingPerImage))
+	for name, objects := range missingPerImage {
+		results = append(results, imageserver.ImageMissingObjects{
+			ImageName:      name,
+			MissingObjects: objects,
+		})
+	}
+	sort.Slice(results, func(left, right int) bool {
+		return results[left].ImageName < results[right].ImageName
+	})
+	return results, numImages, uint64(len(hashes)), nil
+}
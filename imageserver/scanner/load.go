@@ -7,12 +7,15 @@ import (
 	"io"
 	"os"
 	"path"
+	"runtime"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/Symantec/Dominator/imageserver/audit"
 	"github.com/Symantec/Dominator/lib/concurrent"
 	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/log/logutil"
@@ -23,8 +26,17 @@ import (
 	"github.com/Symantec/Dominator/lib/stringutil"
 )
 
+type loadStatistics struct {
+	duration     time.Duration
+	heapBytes    uint64 // Heap in use after loading.
+	numDecoded   uint   // Images loaded by decoding the image file.
+	numFromIndex uint   // Images loaded from the index.
+	userTime     time.Duration
+}
+
 func loadImageDataBase(baseDir string, objSrv objectserver.FullObjectServer,
-	replicationMaster string, logger log.DebugLogger) (*ImageDataBase, error) {
+	replicationMaster string, auditLog *audit.Log,
+	logger log.DebugLogger) (*ImageDataBase, error) {
 	fi, err := os.Stat(baseDir)
 	if err != nil {
 		return nil, errors.New(
@@ -35,16 +47,30 @@ func loadImageDataBase(baseDir string, objSrv objectserver.FullObjectServer,
 	}
 	imdb := &ImageDataBase{
 		baseDir:           baseDir,
+		aliasMap:          make(map[string]*image.Alias),
+		deletedImages:     make(map[string]time.Time),
 		directoryMap:      make(map[string]image.DirectoryMetadata),
 		imageMap:          make(map[string]*image.Image),
 		addNotifiers:      make(notifiers),
+		aliasNotifiers:    make(aliasNotifiers),
 		deleteNotifiers:   make(notifiers),
 		mkdirNotifiers:    make(makeDirectoryNotifiers),
+		objectRefCounts:   make(map[hash.Hash]uint),
+		objectsDigests:    make(map[string]hash.Hash),
 		deduper:           stringutil.NewStringDeduplicator(false),
+		leases:            make(map[string]*uploadLease),
+		pinnedObjects:     make(map[hash.Hash]uint),
+		auditLog:          auditLog,
 		objectServer:      objSrv,
 		replicationMaster: replicationMaster,
 		logger:            logger,
 	}
+	if *imageServerFileSystemCacheSize > 0 {
+		imdb.fsCache = newFileSystemCache(*imageServerFileSystemCacheSize)
+	}
+	if err := imdb.loadAliases(); err != nil {
+		return nil, errors.New("error loading aliases: " + err.Error())
+	}
 	imdb.unreferencedObjects, err = loadUnreferencedObjects(
 		path.Join(baseDir, unreferencedObjectsFile))
 	if err != nil {
@@ -61,18 +87,27 @@ func loadImageDataBase(baseDir string, objSrv objectserver.FullObjectServer,
 	if err := state.Reap(); err != nil {
 		return nil, err
 	}
+	syscall.Getrusage(syscall.RUSAGE_SELF, &rusageStop)
+	imdb.loadStats.duration = time.Since(startTime)
+	imdb.loadStats.userTime = time.Duration(rusageStop.Utime.Sec)*time.Second +
+		time.Duration(rusageStop.Utime.Usec)*time.Microsecond -
+		time.Duration(rusageStart.Utime.Sec)*time.Second -
+		time.Duration(rusageStart.Utime.Usec)*time.Microsecond
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	imdb.loadStats.heapBytes = memStats.HeapAlloc
 	if logger != nil {
 		plural := ""
 		if imdb.CountImages() != 1 {
 			plural = "s"
 		}
-		syscall.Getrusage(syscall.RUSAGE_SELF, &rusageStop)
-		userTime := time.Duration(rusageStop.Utime.Sec)*time.Second +
-			time.Duration(rusageStop.Utime.Usec)*time.Microsecond -
-			time.Duration(rusageStart.Utime.Sec)*time.Second -
-			time.Duration(rusageStart.Utime.Usec)*time.Microsecond
 		logger.Printf("Loaded %d image%s in %s (%s user CPUtime)\n",
-			imdb.CountImages(), plural, time.Since(startTime), userTime)
+			imdb.CountImages(), plural, imdb.loadStats.duration,
+			imdb.loadStats.userTime)
+		if imdb.fsCache != nil {
+			logger.Printf("Loaded %d images from index, decoded %d images\n",
+				imdb.loadStats.numFromIndex, imdb.loadStats.numDecoded)
+		}
 		logutil.LogMemory(logger, 0, "after loading")
 	}
 	imdb.regenerateUnreferencedObjectsList()
@@ -121,6 +156,9 @@ func (imdb *ImageDataBase) scanDirectory(dirname string,
 			err = state.GoRun(func() error {
 				return imdb.loadFile(filename, logger)
 			})
+		} else if stat.Mode&syscall.S_IFMT == syscall.S_IFREG {
+			// Deleted images are truncated, keeping the deletion time.
+			imdb.loadDeletedImage(filename, time.Unix(stat.Mtim.Unix()))
 		}
 		if err != nil {
 			if err == syscall.ENOENT {
@@ -154,6 +192,13 @@ func (imdb *ImageDataBase) readDirectoryMetadata(dirname string) (
 
 func (imdb *ImageDataBase) loadFile(filename string,
 	logger log.DebugLogger) error {
+	if imdb.fsCache != nil {
+		if loaded, err := imdb.loadFileFromIndex(filename); err != nil {
+			return err
+		} else if loaded {
+			return nil
+		}
+	}
 	pathname := path.Join(imdb.baseDir, filename)
 	file, err := os.Open(pathname)
 	if err != nil {
@@ -177,7 +222,10 @@ func (imdb *ImageDataBase) loadFile(filename string,
 	}
 	if imageIsExpired(&img) {
 		imdb.logger.Printf("Deleting already expired image: %s\n", filename)
-		return os.Remove(pathname)
+		imdb.removeImageIndex(filename)
+		err := os.Remove(pathname)
+		imdb.recordExpiration(filename, img.ExpiresAt, err)
+		return err
 	}
 	if err := img.VerifyObjects(imdb.objectServer); err != nil {
 		if imdb.replicationMaster == "" ||
@@ -191,19 +239,76 @@ func (imdb *ImageDataBase) loadFile(filename string,
 		}
 	}
 	img.FileSystem.RebuildInodePointers()
-	imdb.deduperLock.Lock()
-	img.ReplaceStrings(imdb.deduper.DeDuplicate)
-	imdb.deduperLock.Unlock()
 	if err := img.Verify(); err != nil {
 		return err
 	}
+	objects, err := imdb.listImageObjects(filename, &img)
+	if err != nil {
+		return err
+	}
+	if imdb.fsCache != nil {
+		if err := imdb.writeImageIndex(filename, &img); err != nil {
+			return fmt.Errorf("error writing index for: %s: %s", filename, err)
+		}
+		img.FileSystem = nil
+	}
+	imdb.deduperLock.Lock()
+	img.ReplaceStrings(imdb.deduper.DeDuplicate)
+	imdb.deduperLock.Unlock()
 	imdb.scheduleExpiration(&img, filename)
 	imdb.Lock()
 	defer imdb.Unlock()
 	imdb.imageMap[filename] = &img
+	imdb.addObjectReferences(objects)
+	imdb.loadStats.numDecoded++
 	return nil
 }
 
+// loadFileFromIndex will load the metadata for an image from its index,
+// without decoding the file-system. If the index is missing or stale, or if
+// objects are missing, false is returned and the image must be fully loaded.
+func (imdb *ImageDataBase) loadFileFromIndex(filename string) (bool, error) {
+	objects := make(map[hash.Hash]uint64)
+	img, err := imdb.readImageIndex(filename, true,
+		func(hashVal hash.Hash, size uint64) error {
+			objects[hashVal] = size
+			return nil
+		})
+	if err != nil {
+		if !os.IsNotExist(err) {
+			imdb.logger.Printf("Ignoring index for image: %s: %s\n",
+				filename, err)
+		}
+		return false, nil
+	}
+	if imageIsExpired(img) {
+		return false, nil
+	}
+	hashes := make([]hash.Hash, 0, len(objects))
+	for hashVal := range objects {
+		hashes = append(hashes, hashVal)
+	}
+	sizes, err := imdb.objectServer.CheckObjects(hashes)
+	if err != nil {
+		return false, err
+	}
+	for _, size := range sizes {
+		if size < 1 {
+			return false, nil
+		}
+	}
+	imdb.deduperLock.Lock()
+	img.ReplaceStrings(imdb.deduper.DeDuplicate)
+	imdb.deduperLock.Unlock()
+	imdb.scheduleExpiration(img, filename)
+	imdb.Lock()
+	defer imdb.Unlock()
+	imdb.imageMap[filename] = img
+	imdb.addObjectReferences(objects)
+	imdb.loadStats.numFromIndex++
+	return true, nil
+}
+
 func (imdb *ImageDataBase) fetchMissingObjects(img *image.Image,
 	logger log.DebugLogger) error {
 	imdb.objectFetchLock.Lock()
@@ -0,0 +1,228 @@
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+type referrerType struct {
+	name      string
+	numImages uint
+}
+
+type objectUsageType struct {
+	size        uint64
+	numImages   uint
+	directories []referrerType
+	groups      []referrerType
+}
+
+// usageReport is the cached result of the usage accounting. It is discarded
+// whenever an image is added or deleted.
+type usageReport struct {
+	directories map[string]*imageserver.DirectoryUsage
+	groups      map[string]*imageserver.GroupUsage
+	objects     map[hash.Hash]*objectUsageType
+}
+
+func addReferrer(referrers []referrerType, name string) []referrerType {
+	for index := range referrers {
+		if referrers[index].name == name {
+			referrers[index].numImages++
+			return referrers
+		}
+	}
+	return append(referrers, referrerType{name: name, numImages: 1})
+}
+
+func isInDirectory(name, dirname string) bool {
+	return dirname == "." || name == dirname ||
+		strings.HasPrefix(name, dirname+"/")
+}
+
+// This must be called with the lock held.
+func (imdb *ImageDataBase) computeUsage() *usageReport {
+	report := &usageReport{
+		directories: make(map[string]*imageserver.DirectoryUsage),
+		groups:      make(map[string]*imageserver.GroupUsage),
+		objects:     make(map[hash.Hash]*objectUsageType),
+	}
+	for dirname, metadata := range imdb.directoryMap {
+		report.directories[dirname] = &imageserver.DirectoryUsage{
+			Name:           dirname,
+			HardQuotaBytes: metadata.HardQuotaBytes,
+			SoftQuotaBytes: metadata.SoftQuotaBytes,
+		}
+	}
+	for name, img := range imdb.imageMap {
+		dirname := filepath.Dir(name)
+		groupName := imdb.directoryMap[dirname].OwnerGroup
+		directoryUsage := report.directories[dirname]
+		if directoryUsage == nil {
+			directoryUsage = &imageserver.DirectoryUsage{Name: dirname}
+			report.directories[dirname] = directoryUsage
+		}
+		directoryUsage.NumImages++
+		groupUsage := report.groups[groupName]
+		if groupUsage == nil {
+			groupUsage = &imageserver.GroupUsage{Name: groupName}
+			report.groups[groupName] = groupUsage
+		}
+		groupUsage.NumImages++
+		err := imdb.forEachImageObject(name, img,
+			func(hashVal hash.Hash, size uint64) error {
+				if size < 1 {
+					return nil
+				}
+				object := report.objects[hashVal]
+				if object == nil {
+					object = &objectUsageType{size: size}
+					report.objects[hashVal] = object
+				}
+				object.numImages++
+				object.directories = addReferrer(object.directories,
+					dirname)
+				object.groups = addReferrer(object.groups, groupName)
+				return nil
+			})
+		if err != nil {
+			imdb.logger.Printf("Error computing usage for image: %s: %s\n",
+				name, err)
+		}
+	}
+	for _, object := range report.objects {
+		for _, referrer := range object.directories {
+			usage := &report.directories[referrer.name].Usage
+			accountObject(usage, object, referrer, len(object.directories))
+		}
+		for _, referrer := range object.groups {
+			usage := &report.groups[referrer.name].Usage
+			accountObject(usage, object, referrer, len(object.groups))
+		}
+	}
+	return report
+}
+
+func accountObject(usage *imageserver.Usage, object *objectUsageType,
+	referrer referrerType, numReferrers int) {
+	usage.FairShareBytes += object.size * uint64(referrer.numImages) /
+		uint64(object.numImages)
+	if numReferrers == 1 && referrer.numImages == object.numImages {
+		usage.UniqueBytes += object.size
+	} else {
+		usage.SharedBytes += object.size
+	}
+}
+
+// checkQuota will check if adding the image would exceed the quota for the
+// directory containing the image (or the nearest enclosing directory with a
+// quota). Exceeding the soft quota is logged.
+func (imdb *ImageDataBase) checkQuota(img *image.Image, name string) error {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	var quotaDirname string
+	var metadata image.DirectoryMetadata
+	for dirname := filepath.Dir(name); ; dirname = filepath.Dir(dirname) {
+		metadata = imdb.directoryMap[dirname]
+		if metadata.HardQuotaBytes > 0 || metadata.SoftQuotaBytes > 0 {
+			quotaDirname = dirname
+			break
+		}
+		if dirname == "." || dirname == "/" {
+			return nil
+		}
+	}
+	report := imdb.getUsageReport()
+	var usedBytes uint64
+	for dirname, usage := range report.directories {
+		if isInDirectory(dirname, quotaDirname) {
+			usedBytes += usage.FairShareBytes
+		}
+	}
+	forEachObject(img, func(hashVal hash.Hash, size uint64) error {
+		var numImages uint64
+		if object := report.objects[hashVal]; object != nil {
+			numImages = uint64(object.numImages)
+		}
+		usedBytes += size / (numImages + 1)
+		return nil
+	})
+	if metadata.HardQuotaBytes > 0 && usedBytes > metadata.HardQuotaBytes {
+		return fmt.Errorf("hard quota for: %s exceeded: %s > %s",
+			quotaDirname, format.FormatBytes(usedBytes),
+			format.FormatBytes(metadata.HardQuotaBytes))
+	}
+	if metadata.SoftQuotaBytes > 0 && usedBytes > metadata.SoftQuotaBytes {
+		imdb.logger.Printf("Soft quota for: %s exceeded by: %s: %s > %s\n",
+			quotaDirname, name, format.FormatBytes(usedBytes),
+			format.FormatBytes(metadata.SoftQuotaBytes))
+	}
+	return nil
+}
+
+// This must be called with the lock held (a read lock is sufficient).
+func (imdb *ImageDataBase) getUsageReport() *usageReport {
+	imdb.usageLock.Lock()
+	defer imdb.usageLock.Unlock()
+	if imdb.usage == nil {
+		imdb.usage = imdb.computeUsage()
+	}
+	return imdb.usage
+}
+
+func (imdb *ImageDataBase) getUsage() ([]imageserver.DirectoryUsage,
+	[]imageserver.GroupUsage) {
+	imdb.RLock()
+	defer imdb.RUnlock()
+	report := imdb.getUsageReport()
+	directories := make([]imageserver.DirectoryUsage, 0,
+		len(report.directories))
+	for _, usage := range report.directories {
+		directories = append(directories, *usage)
+	}
+	sort.Slice(directories, func(left, right int) bool {
+		return directories[left].Name < directories[right].Name
+	})
+	groups := make([]imageserver.GroupUsage, 0, len(report.groups))
+	for _, usage := range report.groups {
+		groups = append(groups, *usage)
+	}
+	sort.Slice(groups, func(left, right int) bool {
+		return groups[left].Name < groups[right].Name
+	})
+	return directories, groups
+}
+
+// This must be called with the lock held.
+func (imdb *ImageDataBase) invalidateUsage() {
+	imdb.usageLock.Lock()
+	defer imdb.usageLock.Unlock()
+	imdb.usage = nil
+}
+
+func (imdb *ImageDataBase) setDirectoryQuota(dirname string,
+	softQuotaBytes, hardQuotaBytes uint64) error {
+	dirname = filepath.Clean(dirname)
+	if hardQuotaBytes > 0 && softQuotaBytes > hardQuotaBytes {
+		return fmt.Errorf("soft quota: %s exceeds hard quota: %s",
+			format.FormatBytes(softQuotaBytes),
+			format.FormatBytes(hardQuotaBytes))
+	}
+	imdb.Lock()
+	defer imdb.Unlock()
+	directoryMetadata, ok := imdb.directoryMap[dirname]
+	if !ok {
+		return fmt.Errorf("no metadata for: \"%s\"", dirname)
+	}
+	directoryMetadata.HardQuotaBytes = hardQuotaBytes
+	directoryMetadata.SoftQuotaBytes = softQuotaBytes
+	return imdb.updateDirectoryMetadata(
+		image.Directory{Name: dirname, Metadata: directoryMetadata})
+}
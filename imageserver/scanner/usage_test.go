@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func makeSizedTestImage(objects map[hash.Hash]uint64) *image.Image {
+	fs := &filesystem.FileSystem{
+		InodeTable: make(filesystem.InodeTable),
+	}
+	inum := uint64(1)
+	for hashVal, size := range objects {
+		fs.InodeTable[inum] = &filesystem.RegularInode{
+			Size: size,
+			Hash: hashVal,
+		}
+		inum++
+	}
+	return &image.Image{FileSystem: fs}
+}
+
+// makeUsageTestDataBase will return a database where object 1 is used by two
+// images in directory "a", object 2 is used by an image in directory "a" and
+// an image in directory "b" and object 3 is used by one image.
+func makeUsageTestDataBase(t *testing.T,
+	directories map[string]image.DirectoryMetadata) *ImageDataBase {
+	imdb := &ImageDataBase{
+		directoryMap: directories,
+		imageMap: map[string]*image.Image{
+			"a/x": makeSizedTestImage(
+				map[hash.Hash]uint64{{1}: 100, {2}: 50}),
+			"a/y": makeSizedTestImage(
+				map[hash.Hash]uint64{{1}: 100, {3}: 30}),
+			"b/z": makeSizedTestImage(map[hash.Hash]uint64{{2}: 50}),
+		},
+		logger: testlogger.New(t),
+	}
+	return imdb
+}
+
+func TestComputeUsage(t *testing.T) {
+	imdb := makeUsageTestDataBase(t, map[string]image.DirectoryMetadata{
+		".": {},
+		"a": {OwnerGroup: "group-a"},
+		"b": {OwnerGroup: "group-b"},
+	})
+	report := imdb.computeUsage()
+	var tests = []struct {
+		name  string
+		usage *imageserver.Usage
+		want  imageserver.Usage
+	}{
+		{"directory: .", &report.directories["."].Usage,
+			imageserver.Usage{}},
+		{"directory: a", &report.directories["a"].Usage,
+			imageserver.Usage{FairShareBytes: 155, NumImages: 2,
+				SharedBytes: 50, UniqueBytes: 130}},
+		{"directory: b", &report.directories["b"].Usage,
+			imageserver.Usage{FairShareBytes: 25, NumImages: 1,
+				SharedBytes: 50}},
+		{"group: group-a", &report.groups["group-a"].Usage,
+			imageserver.Usage{FairShareBytes: 155, NumImages: 2,
+				SharedBytes: 50, UniqueBytes: 130}},
+		{"group: group-b", &report.groups["group-b"].Usage,
+			imageserver.Usage{FairShareBytes: 25, NumImages: 1,
+				SharedBytes: 50}},
+	}
+	for _, test := range tests {
+		if *test.usage != test.want {
+			t.Errorf("%s: %+v, want %+v", test.name, *test.usage, test.want)
+		}
+	}
+	if len(report.objects) != 3 {
+		t.Errorf("%d objects, want 3", len(report.objects))
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	// Directory "a" uses 155 bytes and directory "b" uses 25 bytes.
+	var tests = []struct {
+		name        string
+		directories map[string]image.DirectoryMetadata
+		imageName   string
+		objects     map[hash.Hash]uint64
+		wantErr     bool
+	}{
+		{"no quota", map[string]image.DirectoryMetadata{"a": {}},
+			"a/new", map[hash.Hash]uint64{{4}: 1000}, false},
+		{"within hard quota", map[string]image.DirectoryMetadata{
+			"a": {HardQuotaBytes: 195},
+		}, "a/new", map[hash.Hash]uint64{{4}: 40}, false},
+		{"exceeds hard quota", map[string]image.DirectoryMetadata{
+			"a": {HardQuotaBytes: 194},
+		}, "a/new", map[hash.Hash]uint64{{4}: 40}, true},
+		{"shared object", map[string]image.DirectoryMetadata{
+			// 155 + 100/3 for the third reference to object 1.
+			"a": {HardQuotaBytes: 188},
+		}, "a/new", map[hash.Hash]uint64{{1}: 100}, false},
+		{"shared object exceeds", map[string]image.DirectoryMetadata{
+			"a": {HardQuotaBytes: 187},
+		}, "a/new", map[hash.Hash]uint64{{1}: 100}, true},
+		{"enclosing directory", map[string]image.DirectoryMetadata{
+			"a":     {HardQuotaBytes: 194},
+			"a/sub": {OwnerGroup: "group"},
+		}, "a/sub/new", map[hash.Hash]uint64{{4}: 40}, true},
+		{"nearest quota only", map[string]image.DirectoryMetadata{
+			"a":     {HardQuotaBytes: 10},
+			"a/sub": {HardQuotaBytes: 1000},
+		}, "a/sub/new", map[hash.Hash]uint64{{4}: 40}, false},
+		{"root quota", map[string]image.DirectoryMetadata{
+			".": {HardQuotaBytes: 219},
+		}, "b/new", map[hash.Hash]uint64{{4}: 40}, true},
+		{"other directory", map[string]image.DirectoryMetadata{
+			"a": {HardQuotaBytes: 10},
+			"b": {HardQuotaBytes: 65},
+		}, "b/new", map[hash.Hash]uint64{{4}: 40}, false},
+		{"soft quota", map[string]image.DirectoryMetadata{
+			"a": {SoftQuotaBytes: 10},
+		}, "a/new", map[hash.Hash]uint64{{4}: 40}, false},
+	}
+	for _, test := range tests {
+		imdb := makeUsageTestDataBase(t, test.directories)
+		err := imdb.checkQuota(makeSizedTestImage(test.objects), test.imageName)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: %v, want error: %t", test.name, err, test.wantErr)
+		}
+	}
+}
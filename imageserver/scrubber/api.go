@@ -0,0 +1,78 @@
+package scrubber
+
+import (
+	"flag"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/audit"
+	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/lib/flagutil"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
+)
+
+var (
+	scrubberInterval = flag.Duration("scrubberInterval", 24*time.Hour,
+		"Minimum interval between starting object scrubbing passes")
+	scrubberSpeed = flagutil.Size(10 << 20)
+)
+
+func init() {
+	flag.Var(&scrubberSpeed, "scrubberSpeed",
+		"Maximum read speed (bytes/second) of the object scrubber (0: disable)")
+}
+
+// Corruption records a corrupt object found by the scrubber.
+type Corruption struct {
+	DetectedAt   time.Time
+	Error        string // Why the object is considered corrupt.
+	Hash         hash.Hash
+	Images       []string // Images which reference the object.
+	RepairError  string   `json:",omitempty"`
+	RepairedFrom string   `json:",omitempty"`
+}
+
+type Scrubber struct {
+	auditLog            *audit.Log
+	imdb                *scanner.ImageDataBase
+	objSrv              *filesystem.ObjectServer
+	sources             []string
+	logger              log.DebugLogger
+	lock                sync.Mutex // Protect everything below.
+	corruptions         []Corruption
+	lastPassCompletedAt time.Time
+	lastPassDuration    time.Duration
+	numBytesScanned     uint64
+	numCorrupt          uint64
+	numObjectsInPass    uint64
+	numObjectsScanned   uint64 // In the current pass.
+	numPasses           uint64 // Completed passes.
+	numReadErrors       uint64 // Objects which could not be read in a pass.
+	numRepaired         uint64
+	numRepairFailures   uint64
+}
+
+// New will create a scrubber which periodically re-reads and re-hashes all the
+// objects in objSrv, at a rate limited by the -scrubberSpeed flag. Objects
+// which cannot be read are retried later in the pass. Corrupt objects (those
+// with a hash mismatch) are quarantined and, if referenced by an image,
+// re-fetched from the first of the sources (replication master and peers) which
+// has them. Quarantined objects are recorded in auditLog. If the scrubber is
+// disabled nil is returned.
+func New(imdb *scanner.ImageDataBase, objSrv *filesystem.ObjectServer,
+	sources []string, auditLog *audit.Log, logger log.DebugLogger) *Scrubber {
+	return newScrubber(imdb, objSrv, sources, auditLog, logger)
+}
+
+// GetCorruptions will return the most recently found corrupt objects, newest
+// first.
+func (s *Scrubber) GetCorruptions() []Corruption {
+	return s.getCorruptions()
+}
+
+func (s *Scrubber) WriteHtml(writer io.Writer) {
+	s.writeHtml(writer)
+}
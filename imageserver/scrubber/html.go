@@ -0,0 +1,86 @@
+package scrubber
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+)
+
+const timeFormat = "02 Jan 2006 15:04:05 MST"
+
+func (s *Scrubber) writeHtml(writer io.Writer) {
+	s.lock.Lock()
+	numCorrupt := s.numCorrupt
+	numObjectsInPass := s.numObjectsInPass
+	numObjectsScanned := s.numObjectsScanned
+	numPasses := s.numPasses
+	lastPassCompletedAt := s.lastPassCompletedAt
+	lastPassDuration := s.lastPassDuration
+	s.lock.Unlock()
+	fmt.Fprintf(writer,
+		"Object scrubber (%s/s): scrubbed %d of %d objects in pass %d",
+		format.FormatBytes(uint64(scrubberSpeed)), numObjectsScanned,
+		numObjectsInPass, numPasses+1)
+	if !lastPassCompletedAt.IsZero() {
+		fmt.Fprintf(writer, ", last pass completed at: %s in %s",
+			lastPassCompletedAt.In(time.Local).Format(timeFormat),
+			format.Duration(lastPassDuration))
+	}
+	fmt.Fprintf(writer,
+		", <a href=\"showScrubberCorruptions\">%d corrupt objects</a><br>\n",
+		numCorrupt)
+}
+
+func (s *Scrubber) showCorruptionsHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	corruptions := s.GetCorruptions()
+	fmt.Fprintln(writer, "<title>imageserver scrubber corruptions</title>")
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	fmt.Fprintln(writer, "Corrupt objects found by the scrubber (newest first)")
+	fmt.Fprintln(writer, "</h3>")
+	if len(corruptions) < 1 {
+		fmt.Fprintln(writer, "No corrupt objects<br>")
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Detected At</th>")
+	fmt.Fprintln(writer, "    <th>Object</th>")
+	fmt.Fprintln(writer, "    <th>Error</th>")
+	fmt.Fprintln(writer, "    <th>Affected Images</th>")
+	fmt.Fprintln(writer, "    <th>Repair</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, corruption := range corruptions {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			corruption.DetectedAt.In(time.Local).Format(timeFormat))
+		fmt.Fprintf(writer, "    <td>%x</td>\n", corruption.Hash)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", corruption.Error)
+		fmt.Fprint(writer, "    <td>")
+		for index, name := range corruption.Images {
+			if index > 0 {
+				fmt.Fprint(writer, "<br>")
+			}
+			fmt.Fprintf(writer, "<a href=\"showImage?%s\">%s</a>", name, name)
+		}
+		fmt.Fprintln(writer, "</td>")
+		if corruption.RepairedFrom != "" {
+			fmt.Fprintf(writer, "    <td>re-fetched from: %s</td>\n",
+				corruption.RepairedFrom)
+		} else {
+			fmt.Fprintf(writer, "    <td><font color=\"red\">%s</font></td>\n",
+				corruption.RepairError)
+		}
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
@@ -0,0 +1,79 @@
+package scrubber
+
+import (
+	"time"
+
+	"github.com/Symantec/tricorder/go/tricorder"
+	"github.com/Symantec/tricorder/go/tricorder/units"
+)
+
+func (s *Scrubber) getCounter(counter *uint64) func() uint64 {
+	return func() uint64 {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		return *counter
+	}
+}
+
+func (s *Scrubber) registerMetrics() {
+	dir, err := tricorder.RegisterDirectory("/scrubber")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("bytes-scanned", s.getCounter(&s.numBytesScanned),
+		units.Byte, "total number of bytes scrubbed")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("last-pass-duration",
+		func() time.Duration {
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			return s.lastPassDuration
+		},
+		units.Second, "duration of the last completed pass")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("num-corrupt-objects",
+		s.getCounter(&s.numCorrupt), units.None,
+		"number of corrupt objects found")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("num-objects-in-pass",
+		s.getCounter(&s.numObjectsInPass), units.None,
+		"number of objects in the current pass")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("num-objects-scanned-in-pass",
+		s.getCounter(&s.numObjectsScanned), units.None,
+		"number of objects scrubbed in the current pass")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("num-passes", s.getCounter(&s.numPasses),
+		units.None, "number of completed passes")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("num-read-errors",
+		s.getCounter(&s.numReadErrors), units.None,
+		"number of objects which could not be read after retrying")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("num-repair-failures",
+		s.getCounter(&s.numRepairFailures), units.None,
+		"number of corrupt objects which could not be re-fetched")
+	if err != nil {
+		panic(err)
+	}
+	err = dir.RegisterMetric("num-repaired-objects",
+		s.getCounter(&s.numRepaired), units.None,
+		"number of corrupt objects which were re-fetched")
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,279 @@
+package scrubber
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/audit"
+	"github.com/Symantec/Dominator/imageserver/scanner"
+	liberrors "github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/html"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/lib/objectserver"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+const (
+	maxCorruptions    = 1000
+	maxReadAttempts   = 3
+	readRetryInterval = time.Minute
+)
+
+func newScrubber(imdb *scanner.ImageDataBase, objSrv *filesystem.ObjectServer,
+	sources []string, auditLog *audit.Log, logger log.DebugLogger) *Scrubber {
+	if scrubberSpeed < 1 {
+		return nil
+	}
+	s := &Scrubber{
+		auditLog: auditLog,
+		imdb:     imdb,
+		objSrv:   objSrv,
+		sources:  sources,
+		logger:   logger,
+	}
+	s.registerMetrics()
+	html.HandleFunc("/showScrubberCorruptions", s.showCorruptionsHandler)
+	go s.loop()
+	return s
+}
+
+func (s *Scrubber) addCorruption(corruption Corruption) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.numCorrupt++
+	if corruption.RepairedFrom != "" {
+		s.numRepaired++
+	} else if corruption.RepairError != "" {
+		s.numRepairFailures++
+	}
+	s.corruptions = append(s.corruptions, corruption)
+	if len(s.corruptions) > maxCorruptions {
+		s.corruptions = s.corruptions[len(s.corruptions)-maxCorruptions:]
+	}
+}
+
+// fetchObject will fetch an object from the imageserver at address and add it
+// to the local object server. The object hash is verified.
+func (s *Scrubber) fetchObject(address string, hashVal hash.Hash) error {
+	objClient := objectclient.NewObjectClient(address)
+	defer objClient.Close()
+	size, reader, err := objClient.GetObject(hashVal)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, _, err = s.objSrv.AddObject(reader, size, &hashVal)
+	return err
+}
+
+// findImages will return the names of the images which reference the object.
+func (s *Scrubber) findImages(hashVal hash.Hash) []string {
+	var names []string
+	for _, name := range s.imdb.ListImages() {
+		s.imdb.ForEachImageObject(name,
+			func(objectHash hash.Hash, size uint64) error {
+				if objectHash == hashVal {
+					names = append(names, name)
+					return errors.New("found")
+				}
+				return nil
+			})
+	}
+	return names
+}
+
+func (s *Scrubber) getCorruptions() []Corruption {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	corruptions := make([]Corruption, 0, len(s.corruptions))
+	for index := len(s.corruptions) - 1; index >= 0; index-- {
+		corruptions = append(corruptions, s.corruptions[index])
+	}
+	return corruptions
+}
+
+// handleCorruption will quarantine a corrupt object, report the images which
+// reference it and re-fetch it if it is referenced.
+func (s *Scrubber) handleCorruption(hashVal hash.Hash, scrubErr error) {
+	corruption := Corruption{
+		DetectedAt: time.Now(),
+		Error:      scrubErr.Error(),
+		Hash:       hashVal,
+		Images:     s.findImages(hashVal),
+	}
+	s.logger.Printf("Scrubber: object: %x is corrupt: %s, used by %d images\n",
+		hashVal, scrubErr, len(corruption.Images))
+	for _, name := range corruption.Images {
+		s.logger.Printf("Scrubber: image: %s has corrupt object: %x\n",
+			name, hashVal)
+	}
+	err := s.objSrv.QuarantineObject(hashVal)
+	s.recordQuarantine(corruption, err)
+	if err != nil {
+		corruption.RepairError = "error quarantining: " + err.Error()
+	} else if len(corruption.Images) < 1 {
+		corruption.RepairError = "unreferenced: not re-fetched"
+	} else if source, err := s.repair(hashVal); err != nil {
+		corruption.RepairError = err.Error()
+	} else {
+		corruption.RepairedFrom = source
+	}
+	if corruption.RepairedFrom != "" {
+		s.logger.Printf("Scrubber: re-fetched object: %x from: %s\n",
+			hashVal, corruption.RepairedFrom)
+	} else {
+		s.logger.Printf("Scrubber: object: %x not repaired: %s\n",
+			hashVal, corruption.RepairError)
+	}
+	s.addCorruption(corruption)
+}
+
+// recordQuarantine will record the removal of a corrupt object from the object
+// store in the audit log.
+func (s *Scrubber) recordQuarantine(corruption Corruption, err error) {
+	detail := corruption.Error
+	if len(corruption.Images) > 0 {
+		detail += ", used by: " + strings.Join(corruption.Images, ",")
+	}
+	err = s.auditLog.Record(imageserver.AuditEntry{
+		Operation: imageserver.AuditOperationQuarantineObject,
+		Name:      fmt.Sprintf("%x", corruption.Hash),
+		Detail:    detail,
+		Error:     liberrors.ErrorToString(err),
+	})
+	if err != nil {
+		s.logger.Printf("Scrubber: error recording quarantine of: %x: %s\n",
+			corruption.Hash, err)
+	}
+}
+
+func (s *Scrubber) loop() {
+	for {
+		startTime := time.Now()
+		s.scrubPass()
+		time.Sleep(time.Until(startTime.Add(*scrubberInterval)))
+	}
+}
+
+// repair will re-fetch an object from the first source which has it. The
+// source is returned.
+func (s *Scrubber) repair(hashVal hash.Hash) (string, error) {
+	if len(s.sources) < 1 {
+		return "", errors.New("no replication master or peers to fetch from")
+	}
+	var lastErr error
+	for _, source := range s.sources {
+		if err := s.fetchObject(source, hashVal); err != nil {
+			lastErr = fmt.Errorf("error fetching from: %s: %s", source, err)
+			continue
+		}
+		return source, nil
+	}
+	return "", lastErr
+}
+
+// scrubObject will re-read and re-hash an object. If the object no longer
+// exists, false is returned. If the computed hash does not match, the object is
+// corrupt and a non-nil corruption error is returned. Other errors (e.g.
+// failing to open or read the object) are returned as err: these may be
+// transient and do not indicate corruption.
+func (s *Scrubber) scrubObject(hashVal hash.Hash) (bool, error, error) {
+	length, reader, err := objectserver.GetObject(s.objSrv, hashVal)
+	if err != nil {
+		sizes, _ := s.objSrv.CheckObjects([]hash.Hash{hashVal})
+		if len(sizes) == 1 && sizes[0] < 1 {
+			return false, nil, nil // Deleted since the pass started.
+		}
+		return true, nil, err
+	}
+	defer reader.Close()
+	computedHash, _, err := objectcache.ReadObject(reader, length, nil)
+	if err != nil {
+		return true, nil, err
+	}
+	if computedHash != hashVal {
+		return true, fmt.Errorf("hash mismatch, computed: %x", computedHash),
+			nil
+	}
+	return true, nil, nil
+}
+
+// scrubObjects will scrub the specified objects, pacing the reads so that the
+// average speed since startTime does not exceed the limit. Corrupt objects are
+// handled. The objects which could not be read are returned, so that they may
+// be retried.
+func (s *Scrubber) scrubObjects(objects map[hash.Hash]uint64,
+	startTime time.Time, numBytesInPass *uint64) (
+	map[hash.Hash]uint64, uint) {
+	speed := float64(scrubberSpeed)
+	failedObjects := make(map[hash.Hash]uint64)
+	var numCorrupt uint
+	for hashVal, size := range objects {
+		exists, corruptErr, err := s.scrubObject(hashVal)
+		if corruptErr != nil {
+			numCorrupt++
+			s.handleCorruption(hashVal, corruptErr)
+		} else if err != nil {
+			s.logger.Printf("Scrubber: error reading object: %x: %s\n",
+				hashVal, err)
+			failedObjects[hashVal] = size
+		}
+		s.lock.Lock()
+		if err == nil {
+			s.numObjectsScanned++
+			if exists {
+				s.numBytesScanned += size
+			}
+		}
+		s.lock.Unlock()
+		*numBytesInPass += size
+		// Sleep so that the average speed does not exceed the limit.
+		time.Sleep(time.Until(startTime.Add(time.Duration(
+			float64(*numBytesInPass) / speed * float64(time.Second)))))
+	}
+	return failedObjects, numCorrupt
+}
+
+func (s *Scrubber) scrubPass() {
+	objects := s.objSrv.ListObjectSizes()
+	numObjects := len(objects)
+	startTime := time.Now()
+	s.lock.Lock()
+	s.numObjectsInPass = uint64(numObjects)
+	s.numObjectsScanned = 0
+	s.lock.Unlock()
+	s.logger.Debugf(0, "Scrubber: starting pass over %d objects\n", numObjects)
+	var numBytesInPass uint64
+	objects, numCorrupt := s.scrubObjects(objects, startTime, &numBytesInPass)
+	for attempt := 1; attempt < maxReadAttempts && len(objects) > 0; attempt++ {
+		time.Sleep(readRetryInterval)
+		s.logger.Printf("Scrubber: retrying %d objects which failed to read\n",
+			len(objects))
+		var numNewCorrupt uint
+		objects, numNewCorrupt = s.scrubObjects(objects, startTime,
+			&numBytesInPass)
+		numCorrupt += numNewCorrupt
+	}
+	for hashVal := range objects {
+		s.logger.Printf(
+			"Scrubber: giving up reading object: %x until the next pass\n",
+			hashVal)
+	}
+	s.lock.Lock()
+	s.numReadErrors += uint64(len(objects))
+	s.numPasses++
+	s.lastPassCompletedAt = time.Now()
+	s.lastPassDuration = s.lastPassCompletedAt.Sub(startTime)
+	s.lock.Unlock()
+	s.logger.Printf("Scrubber: completed pass over %d objects in %s, "+
+		"%d corrupt, %d unreadable\n",
+		numObjects, format.Duration(time.Since(startTime)), numCorrupt,
+		len(objects))
+}
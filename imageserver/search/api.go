@@ -0,0 +1,80 @@
+package search
+
+import (
+	"flag"
+	"io"
+	"sync"
+
+	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+var (
+	imageServerSearchIndex = flag.Bool("imageServerSearchIndex", true,
+		"If true, index the paths, objects and packages in images")
+)
+
+// The index is shared by all images, so each path name and object hash is
+// stored once. The entries refer to each other with pointers and indices,
+// which keeps the per-file cost small and allows images to be removed without
+// scanning other images.
+
+type fileEntry struct {
+	path      *pathEntry
+	pathIndex uint32 // Index of the image in path.images.
+}
+
+type imageEntry struct {
+	files   []fileEntry
+	image   *image.Image
+	name    string
+	objects []*objectEntry // Unique objects.
+}
+
+type objectEntry struct {
+	hash   hash.Hash
+	images map[*imageEntry]struct{}
+}
+
+type pathEntry struct {
+	name   string
+	images []pathImage
+}
+
+type pathImage struct {
+	image     *imageEntry
+	object    *objectEntry // nil if not a regular file with data.
+	fileIndex uint32       // Index of the path in image.files.
+}
+
+// Index is an index of the images in an ImageDataBase which may be searched by
+// path, object, package, creator and creation time. It is maintained
+// incrementally as images are added and deleted.
+type Index struct {
+	imdb    *scanner.ImageDataBase
+	logger  log.DebugLogger
+	lock    sync.RWMutex
+	images  map[string]*imageEntry
+	objects map[hash.Hash]*objectEntry
+	paths   map[string]*pathEntry
+	ready   bool
+}
+
+// New will create an Index and start indexing the images in imdb. The index is
+// built in the background. If indexing is disabled nil is returned.
+func New(imdb *scanner.ImageDataBase, logger log.DebugLogger) *Index {
+	return newIndex(imdb, logger)
+}
+
+// Find will return the images which match all the criteria in the request.
+func (idx *Index) Find(request imageserver.FindImagesRequest) (
+	[]imageserver.FoundImage, error) {
+	return idx.find(request)
+}
+
+func (idx *Index) WriteHtml(writer io.Writer) {
+	idx.writeHtml(writer)
+}
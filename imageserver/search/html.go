@@ -0,0 +1,169 @@
+package search
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+const timeFormat = "02 Jan 2006 15:04:05 MST"
+
+func parseTime(value string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func parseQuery(values url.Values) (imageserver.FindImagesRequest, error) {
+	request := imageserver.FindImagesRequest{
+		CreatedBy:      values.Get("createdBy"),
+		PackageName:    values.Get("package"),
+		PackageVersion: values.Get("version"),
+		PathPattern:    values.Get("path"),
+	}
+	if value := values.Get("after"); value != "" {
+		createdAfter, err := parseTime(value)
+		if err != nil {
+			return request, err
+		}
+		request.CreatedAfter = createdAfter
+	}
+	if value := values.Get("before"); value != "" {
+		createdBefore, err := parseTime(value)
+		if err != nil {
+			return request, err
+		}
+		request.CreatedBefore = createdBefore
+	}
+	if value := values.Get("hash"); value != "" {
+		hashVal, err := objectcache.FilenameToHash(value)
+		if err != nil {
+			return request, err
+		}
+		request.ObjectHash = &hashVal
+	}
+	return request, nil
+}
+
+func (idx *Index) writeHtml(writer io.Writer) {
+	idx.lock.RLock()
+	numImages := len(idx.images)
+	numObjects := len(idx.objects)
+	numPaths := len(idx.paths)
+	ready := idx.ready
+	idx.lock.RUnlock()
+	fmt.Fprintf(writer,
+		"<a href=\"findImages\">Search index</a>: %d images, %d paths, "+
+			"%d objects", numImages, numPaths, numObjects)
+	if !ready {
+		fmt.Fprint(writer, " (building)")
+	}
+	fmt.Fprintln(writer, "<br>")
+}
+
+func (idx *Index) findImagesHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	values := req.URL.Query()
+	request, err := parseQuery(values)
+	if values.Get("output") == "text" {
+		if err == nil {
+			var images []imageserver.FoundImage
+			if images, err = idx.find(request); err == nil {
+				for _, image := range images {
+					fmt.Fprintln(writer, image.Name)
+				}
+				return
+			}
+		}
+		fmt.Fprintln(writer, err)
+		return
+	}
+	fmt.Fprintln(writer, "<title>imageserver image search</title>")
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>Find images</h3>")
+	fmt.Fprintln(writer, `<form action="findImages" method="get">`)
+	fmt.Fprintln(writer, "<table>")
+	writeInput(writer, values, "path", "Path (pattern)")
+	writeInput(writer, values, "hash", "Object hash")
+	writeInput(writer, values, "package", "Package name (pattern)")
+	writeInput(writer, values, "version", "Package version (e.g. <1.1.1w)")
+	writeInput(writer, values, "createdBy", "Created by")
+	writeInput(writer, values, "after", "Created after (YYYY-MM-DD)")
+	writeInput(writer, values, "before", "Created before (YYYY-MM-DD)")
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, `<input type="submit" value="Search">`)
+	fmt.Fprintln(writer, "</form>")
+	if len(values) < 1 {
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	var images []imageserver.FoundImage
+	if err == nil {
+		images, err = idx.find(request)
+	}
+	if err != nil {
+		fmt.Fprintf(writer, "<font color=\"red\">%s</font><br>\n",
+			html.EscapeString(err.Error()))
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	fmt.Fprintf(writer, "<h3>%d matching images</h3>\n", len(images))
+	if len(images) < 1 {
+		fmt.Fprintln(writer, "</body>")
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Image</th>")
+	fmt.Fprintln(writer, "    <th>Created By</th>")
+	fmt.Fprintln(writer, "    <th>Created On</th>")
+	fmt.Fprintln(writer, "    <th>Packages</th>")
+	fmt.Fprintln(writer, "    <th>Paths</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, image := range images {
+		packages := make([]string, 0, len(image.Packages))
+		for _, pkg := range image.Packages {
+			packages = append(packages,
+				html.EscapeString(pkg.Name+" "+pkg.Version))
+		}
+		paths := make([]string, 0, len(image.Paths))
+		for _, path := range image.Paths {
+			paths = append(paths, html.EscapeString(path))
+		}
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td><a href=\"showImage?%s\">%s</a></td>\n",
+			image.Name, image.Name)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", image.CreatedBy)
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			image.CreatedOn.In(time.Local).Format(timeFormat))
+		fmt.Fprintf(writer, "    <td>%s</td>\n", strings.Join(packages, "<br>"))
+		fmt.Fprintf(writer, "    <td>%s</td>\n", strings.Join(paths, "<br>"))
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
+
+func writeInput(writer io.Writer, values url.Values, name, label string) {
+	fmt.Fprintf(writer,
+		"<tr><td>%s</td><td><input type=\"text\" name=\"%s\" value=\"%s\">"+
+			"</td></tr>\n",
+		html.EscapeString(label), name, html.EscapeString(values.Get(name)))
+}
@@ -0,0 +1,159 @@
+package search
+
+import (
+	"time"
+
+	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/html"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func newIndex(imdb *scanner.ImageDataBase, logger log.DebugLogger) *Index {
+	if !*imageServerSearchIndex {
+		return nil
+	}
+	idx := makeIndex(imdb, logger)
+	// Register before listing images so that no changes are missed.
+	addChannel := imdb.RegisterAddNotifier()
+	deleteChannel := imdb.RegisterDeleteNotifier()
+	html.HandleFunc("/findImages", idx.findImagesHandler)
+	go idx.indexer(addChannel, deleteChannel)
+	return idx
+}
+
+func makeIndex(imdb *scanner.ImageDataBase, logger log.DebugLogger) *Index {
+	return &Index{
+		imdb:    imdb,
+		logger:  logger,
+		images:  make(map[string]*imageEntry),
+		objects: make(map[hash.Hash]*objectEntry),
+		paths:   make(map[string]*pathEntry),
+	}
+}
+
+func (idx *Index) indexer(addChannel, deleteChannel <-chan string) {
+	startTime := time.Now()
+	names := idx.imdb.ListImages()
+	for _, name := range names {
+		idx.update(name)
+	}
+	idx.lock.Lock()
+	idx.ready = true
+	idx.lock.Unlock()
+	idx.logger.Printf("Indexed %d images in %s\n",
+		len(names), time.Since(startTime))
+	// Notifications may arrive out of order, so always check the current
+	// state of the image.
+	for {
+		select {
+		case name := <-addChannel:
+			idx.update(name)
+		case name := <-deleteChannel:
+			idx.update(name)
+		}
+	}
+}
+
+// update will (re)index the named image, or remove it from the index if it no
+// longer exists.
+func (idx *Index) update(name string) {
+	metadata := idx.imdb.GetImageMetadata(name)
+	idx.lock.Lock()
+	if entry, ok := idx.images[name]; ok {
+		if entry.image == metadata {
+			idx.lock.Unlock()
+			return
+		}
+		idx.remove(entry)
+	}
+	idx.lock.Unlock()
+	if metadata == nil {
+		return
+	}
+	img := idx.imdb.GetImage(name)
+	if img == nil {
+		return
+	}
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.add(name, metadata, img.FileSystem)
+}
+
+// add will add an image to the index. This must be called with the lock held.
+func (idx *Index) add(name string, metadata *image.Image,
+	fs *filesystem.FileSystem) {
+	entry := &imageEntry{image: metadata, name: name}
+	fs.ForEachFile(
+		func(path string, inodeNumber uint64,
+			inode filesystem.GenericInode) error {
+			pEntry := idx.paths[path]
+			if pEntry == nil {
+				pEntry = &pathEntry{name: path}
+				idx.paths[path] = pEntry
+			}
+			var object *objectEntry
+			regInode, ok := inode.(*filesystem.RegularInode)
+			if ok && regInode.Size > 0 {
+				object = idx.getObject(regInode.Hash)
+				if _, ok := object.images[entry]; !ok {
+					object.images[entry] = struct{}{}
+					entry.objects = append(entry.objects, object)
+				}
+			}
+			entry.files = append(entry.files, fileEntry{
+				path:      pEntry,
+				pathIndex: uint32(len(pEntry.images)),
+			})
+			pEntry.images = append(pEntry.images, pathImage{
+				image:     entry,
+				object:    object,
+				fileIndex: uint32(len(entry.files) - 1),
+			})
+			return nil
+		})
+	entry.files = append([]fileEntry(nil), entry.files...) // Trim capacity.
+	idx.images[name] = entry
+}
+
+// This must be called with the lock held.
+func (idx *Index) getObject(hashVal hash.Hash) *objectEntry {
+	object := idx.objects[hashVal]
+	if object == nil {
+		object = &objectEntry{
+			hash:   hashVal,
+			images: make(map[*imageEntry]struct{}, 1),
+		}
+		idx.objects[hashVal] = object
+	}
+	return object
+}
+
+// remove will remove an image from the index. The last image for each path is
+// moved into the slot of the removed image, so no other images are scanned.
+// This must be called with the lock held.
+func (idx *Index) remove(entry *imageEntry) {
+	for _, file := range entry.files {
+		pEntry := file.path
+		lastIndex := uint32(len(pEntry.images) - 1)
+		if file.pathIndex != lastIndex {
+			moved := pEntry.images[lastIndex]
+			pEntry.images[file.pathIndex] = moved
+			moved.image.files[moved.fileIndex].pathIndex = file.pathIndex
+		}
+		pEntry.images[lastIndex] = pathImage{}
+		pEntry.images = pEntry.images[:lastIndex]
+		if len(pEntry.images) < 1 {
+			delete(idx.paths, pEntry.name)
+		}
+	}
+	for _, object := range entry.objects {
+		delete(object.images, entry)
+		if len(object.images) < 1 {
+			delete(idx.objects, object.hash)
+		}
+	}
+	delete(idx.images, entry.name)
+}
@@ -0,0 +1,173 @@
+package search
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+)
+
+// makeTestFileSystem will make a file-system with the specified regular files
+// and the directories containing them. Files with a zero hash are empty.
+func makeTestFileSystem(t *testing.T,
+	files map[string]hash.Hash) *filesystem.FileSystem {
+	fs := &filesystem.FileSystem{InodeTable: make(filesystem.InodeTable)}
+	directories := map[string]*filesystem.DirectoryInode{
+		"/": &fs.DirectoryInode,
+	}
+	var getDirectory func(dirname string) *filesystem.DirectoryInode
+	getDirectory = func(dirname string) *filesystem.DirectoryInode {
+		if directory := directories[dirname]; directory != nil {
+			return directory
+		}
+		directory := &filesystem.DirectoryInode{}
+		directories[dirname] = directory
+		inodeNumber := uint64(len(fs.InodeTable) + 1)
+		fs.InodeTable[inodeNumber] = directory
+		parent := getDirectory(filepath.Dir(dirname))
+		parent.EntryList = append(parent.EntryList, &filesystem.DirectoryEntry{
+			Name:        filepath.Base(dirname),
+			InodeNumber: inodeNumber,
+		})
+		return directory
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		inode := &filesystem.RegularInode{Hash: files[name]}
+		if files[name] != (hash.Hash{}) {
+			inode.Size = 1
+		}
+		inodeNumber := uint64(len(fs.InodeTable) + 1)
+		fs.InodeTable[inodeNumber] = inode
+		directory := getDirectory(filepath.Dir(name))
+		directory.EntryList = append(directory.EntryList,
+			&filesystem.DirectoryEntry{
+				Name:        filepath.Base(name),
+				InodeNumber: inodeNumber,
+			})
+	}
+	for _, directory := range directories {
+		sort.Slice(directory.EntryList, func(left, right int) bool {
+			return directory.EntryList[left].Name <
+				directory.EntryList[right].Name
+		})
+	}
+	if err := fs.RebuildInodePointers(); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+// checkIndex will check that the entries in the index refer to each other
+// consistently and that nothing is left over from removed images.
+func checkIndex(t *testing.T, idx *Index) {
+	numFiles := 0
+	for name, entry := range idx.images {
+		if entry.name != name {
+			t.Errorf("image: %s has name: %s", name, entry.name)
+		}
+		numFiles += len(entry.files)
+		for fileIndex, file := range entry.files {
+			if idx.paths[file.path.name] != file.path {
+				t.Errorf("%s: %s: path not indexed", name, file.path.name)
+				continue
+			}
+			pImage := file.path.images[file.pathIndex]
+			if pImage.image != entry || pImage.fileIndex != uint32(fileIndex) {
+				t.Errorf("%s: %s: inconsistent path entry", name,
+					file.path.name)
+			}
+		}
+		for _, object := range entry.objects {
+			if _, ok := object.images[entry]; !ok {
+				t.Errorf("%s: object: %x missing image", name, object.hash)
+			}
+		}
+	}
+	numPathImages := 0
+	for path, pEntry := range idx.paths {
+		if len(pEntry.images) < 1 {
+			t.Errorf("path: %s has no images", path)
+		}
+		numPathImages += len(pEntry.images)
+		for _, pImage := range pEntry.images {
+			if idx.images[pImage.image.name] != pImage.image {
+				t.Errorf("path: %s refers to removed image: %s",
+					path, pImage.image.name)
+			}
+		}
+	}
+	if numPathImages != numFiles {
+		t.Errorf("%d path references, want %d", numPathImages, numFiles)
+	}
+	for hashVal, object := range idx.objects {
+		if len(object.images) < 1 {
+			t.Errorf("object: %x has no images", hashVal)
+		}
+		for entry := range object.images {
+			if idx.images[entry.name] != entry {
+				t.Errorf("object: %x refers to removed image: %s",
+					hashVal, entry.name)
+			}
+		}
+	}
+}
+
+func TestIndexAddRemove(t *testing.T) {
+	images := map[string]map[string]hash.Hash{
+		"app/v1": {"/bin/app": {1}, "/etc/app.conf": {2}, "/etc/empty": {}},
+		"app/v2": {"/bin/app": {3}, "/etc/app.conf": {2}, "/etc/empty": {}},
+		"app/v3": {"/bin/app": {3}, "/etc/app.conf": {4}},
+		"db/v1":  {"/bin/db": {5}, "/etc/app.conf": {2}},
+	}
+	var tests = []struct {
+		remove      []string // Images to remove, in order.
+		wantPaths   int
+		wantObjects int
+	}{
+		{nil, 7, 5},
+		{[]string{"app/v2"}, 7, 5},
+		{[]string{"app/v1"}, 7, 4},
+		{[]string{"app/v1", "app/v2", "app/v3"}, 5, 2},
+		{[]string{"db/v1", "app/v3", "app/v1", "app/v2"}, 0, 0},
+	}
+	for _, test := range tests {
+		testName := "remove: " + strings.Join(test.remove, ",")
+		idx := makeIndex(nil, testlogger.New(t))
+		names := make([]string, 0, len(images))
+		for name := range images {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			idx.add(name, &image.Image{},
+				makeTestFileSystem(t, images[name]))
+		}
+		checkIndex(t, idx)
+		for _, name := range test.remove {
+			idx.remove(idx.images[name])
+			checkIndex(t, idx)
+		}
+		if len(idx.images) != len(images)-len(test.remove) {
+			t.Errorf("%s: %d images", testName, len(idx.images))
+		}
+		// The paths include the directories.
+		if len(idx.paths) != test.wantPaths {
+			t.Errorf("%s: %d paths, want %d",
+				testName, len(idx.paths), test.wantPaths)
+		}
+		if len(idx.objects) != test.wantObjects {
+			t.Errorf("%s: %d objects, want %d",
+				testName, len(idx.objects), test.wantObjects)
+		}
+	}
+}
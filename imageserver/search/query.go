@@ -0,0 +1,201 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/verstr"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+type versionConstraint struct {
+	operator string
+	version  string
+}
+
+var versionOperators = []string{"<=", ">=", "!=", "==", "<", ">", "="}
+
+func parseVersionConstraint(constraint string) (*versionConstraint, error) {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" {
+		return nil, nil
+	}
+	operator := "="
+	for _, op := range versionOperators {
+		if strings.HasPrefix(constraint, op) {
+			operator = op
+			constraint = strings.TrimSpace(constraint[len(op):])
+			break
+		}
+	}
+	if operator == "==" {
+		operator = "="
+	}
+	if constraint == "" {
+		return nil, errors.New("missing version in constraint")
+	}
+	return &versionConstraint{operator: operator, version: constraint}, nil
+}
+
+func (vc *versionConstraint) match(version string) bool {
+	if vc == nil {
+		return true
+	}
+	switch vc.operator {
+	case "<":
+		return verstr.Less(version, vc.version)
+	case "<=":
+		return version == vc.version || verstr.Less(version, vc.version)
+	case ">":
+		return verstr.Less(vc.version, version)
+	case ">=":
+		return version == vc.version || verstr.Less(vc.version, version)
+	case "!=":
+		return version != vc.version
+	}
+	return version == vc.version
+}
+
+func hasMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
+
+func (idx *Index) find(request imageserver.FindImagesRequest) (
+	[]imageserver.FoundImage, error) {
+	if request.PathPattern != "" {
+		if _, err := filepath.Match(request.PathPattern, ""); err != nil {
+			return nil, fmt.Errorf("bad path pattern: %s", err)
+		}
+	}
+	if request.PackageName != "" {
+		if _, err := filepath.Match(request.PackageName, ""); err != nil {
+			return nil, fmt.Errorf("bad package name pattern: %s", err)
+		}
+	} else if request.PackageVersion != "" {
+		return nil, errors.New("package version requires package name")
+	}
+	constraint, err := parseVersionConstraint(request.PackageVersion)
+	if err != nil {
+		return nil, err
+	}
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	if !idx.ready {
+		return nil, errors.New("search index is being built")
+	}
+	var candidates map[string][]string // Key: image name, value: paths.
+	if request.PathPattern != "" {
+		candidates = idx.findPaths(request)
+	} else if request.ObjectHash != nil {
+		candidates = idx.findObject(*request.ObjectHash)
+	}
+	var found []imageserver.FoundImage
+	matchImage := func(name string, paths []string) {
+		entry := idx.images[name]
+		if entry == nil {
+			return
+		}
+		img := entry.image
+		if request.CreatedBy != "" && img.CreatedBy != request.CreatedBy {
+			return
+		}
+		if !request.CreatedAfter.IsZero() &&
+			!img.CreatedOn.After(request.CreatedAfter) {
+			return
+		}
+		if !request.CreatedBefore.IsZero() &&
+			!img.CreatedOn.Before(request.CreatedBefore) {
+			return
+		}
+		var packages []image.Package
+		if request.PackageName != "" {
+			for _, pkg := range img.Packages {
+				matched, _ := filepath.Match(request.PackageName, pkg.Name)
+				if matched && constraint.match(pkg.Version) {
+					packages = append(packages, pkg)
+				}
+			}
+			if len(packages) < 1 {
+				return
+			}
+		}
+		paths = append([]string(nil), paths...)
+		sort.Strings(paths)
+		found = append(found, imageserver.FoundImage{
+			Name:      name,
+			CreatedBy: img.CreatedBy,
+			CreatedOn: img.CreatedOn,
+			Packages:  packages,
+			Paths:     paths,
+		})
+	}
+	if request.PathPattern != "" || request.ObjectHash != nil {
+		for name, paths := range candidates {
+			matchImage(name, paths)
+		}
+	} else {
+		for name := range idx.images {
+			matchImage(name, nil)
+		}
+	}
+	sort.Slice(found, func(left, right int) bool {
+		return verstr.Less(found[left].Name, found[right].Name)
+	})
+	return found, nil
+}
+
+// findPaths will return the images with paths matching the path pattern (and
+// the object hash, if specified). This must be called with the lock held.
+func (idx *Index) findPaths(
+	request imageserver.FindImagesRequest) map[string][]string {
+	var pEntries []*pathEntry
+	if !hasMeta(request.PathPattern) {
+		pEntry := idx.paths[filepath.Clean(request.PathPattern)]
+		if pEntry != nil {
+			pEntries = append(pEntries, pEntry)
+		}
+	} else {
+		for path, pEntry := range idx.paths {
+			matched, _ := filepath.Match(request.PathPattern, path)
+			if matched {
+				pEntries = append(pEntries, pEntry)
+			}
+		}
+	}
+	images := make(map[string][]string)
+	for _, pEntry := range pEntries {
+		for _, pImage := range pEntry.images {
+			if request.ObjectHash != nil && (pImage.object == nil ||
+				pImage.object.hash != *request.ObjectHash) {
+				continue
+			}
+			name := pImage.image.name
+			images[name] = append(images[name], pEntry.name)
+		}
+	}
+	return images
+}
+
+// findObject will return the images which contain the object and the paths
+// of the object in each image. This must be called with the lock held.
+func (idx *Index) findObject(hashVal hash.Hash) map[string][]string {
+	object := idx.objects[hashVal]
+	if object == nil {
+		return nil
+	}
+	images := make(map[string][]string, len(object.images))
+	for entry := range object.images {
+		for _, file := range entry.files {
+			if file.path.images[file.pathIndex].object == object {
+				images[entry.name] = append(images[entry.name],
+					file.path.name)
+			}
+		}
+	}
+	return images
+}
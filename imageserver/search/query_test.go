@@ -0,0 +1,302 @@
+package search
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func TestParseVersionConstraint(t *testing.T) {
+	var tests = []struct {
+		constraint string
+		want       *versionConstraint
+		wantErr    bool
+	}{
+		{"", nil, false},
+		{"  ", nil, false},
+		{"1.2", &versionConstraint{"=", "1.2"}, false},
+		{"=1.2", &versionConstraint{"=", "1.2"}, false},
+		{"==1.2", &versionConstraint{"=", "1.2"}, false},
+		{"!=1.2", &versionConstraint{"!=", "1.2"}, false},
+		{"< 1.1.1w", &versionConstraint{"<", "1.1.1w"}, false},
+		{"<=1.2", &versionConstraint{"<=", "1.2"}, false},
+		{">1.2", &versionConstraint{">", "1.2"}, false},
+		{" >= 1.2 ", &versionConstraint{">=", "1.2"}, false},
+		{"<", nil, true},
+		{">= ", nil, true},
+	}
+	for _, test := range tests {
+		constraint, err := parseVersionConstraint(test.constraint)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: %v, want error: %t", test.constraint, err,
+				test.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(constraint, test.want) {
+			t.Errorf("%q: %v, want %v", test.constraint, constraint,
+				test.want)
+		}
+	}
+}
+
+func TestVersionConstraintMatch(t *testing.T) {
+	var tests = []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "1.0", true},
+		{"1.1.1w", "1.1.1w", true},
+		{"1.1.1w", "1.1.1v", false},
+		{"<1.1.1w", "1.1.1v", true},
+		{"<1.1.1w", "1.1.1w", false},
+		{"<1.10", "1.9", true},
+		{"<=1.2", "1.2", true},
+		{"<=1.2", "1.3", false},
+		{">1.2", "1.10", true},
+		{">1.2", "1.2", false},
+		{">=1.2", "1.2", true},
+		{">=1.2", "1.1", false},
+		{"!=1.2", "1.2", false},
+		{"!=1.2", "1.3", true},
+	}
+	for _, test := range tests {
+		constraint, err := parseVersionConstraint(test.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := constraint.match(test.version); got != test.want {
+			t.Errorf("%q match %q: %t, want %t",
+				test.constraint, test.version, got, test.want)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	hashVal := hash.Hash{1, 2, 3}
+	var tests = []struct {
+		query   string
+		want    imageserver.FindImagesRequest
+		wantErr bool
+	}{
+		{"", imageserver.FindImagesRequest{}, false},
+		{"path=/etc/*&package=openssl&version=<1.1.1w&createdBy=bob",
+			imageserver.FindImagesRequest{
+				CreatedBy:      "bob",
+				PackageName:    "openssl",
+				PackageVersion: "<1.1.1w",
+				PathPattern:    "/etc/*",
+			}, false},
+		{"after=2026-01-02&before=2026-02-03T04:05:06Z",
+			imageserver.FindImagesRequest{
+				CreatedAfter: time.Date(2026, 1, 2, 0, 0, 0, 0,
+					time.Local),
+				CreatedBefore: time.Date(2026, 2, 3, 4, 5, 6, 0, time.UTC),
+			}, false},
+		{"hash=" + objectcache.HashToFilename(hashVal),
+			imageserver.FindImagesRequest{ObjectHash: &hashVal}, false},
+		{"after=yesterday", imageserver.FindImagesRequest{}, true},
+		{"before=2026-13-01", imageserver.FindImagesRequest{}, true},
+		{"hash=" + strings.Repeat("ab", len(hashVal)+1),
+			imageserver.FindImagesRequest{}, true},
+	}
+	for _, test := range tests {
+		values, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request, err := parseQuery(values)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: %v, want error: %t", test.query, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !request.CreatedAfter.Equal(test.want.CreatedAfter) ||
+			!request.CreatedBefore.Equal(test.want.CreatedBefore) {
+			t.Errorf("%q: times: %s, %s", test.query, request.CreatedAfter,
+				request.CreatedBefore)
+		}
+		request.CreatedAfter = test.want.CreatedAfter
+		request.CreatedBefore = test.want.CreatedBefore
+		if !reflect.DeepEqual(request, test.want) {
+			t.Errorf("%q: %+v, want %+v", test.query, request, test.want)
+		}
+	}
+}
+
+func makeTestIndex(t *testing.T) *Index {
+	day := func(day int) time.Time {
+		return time.Date(2026, 1, day, 0, 0, 0, 0, time.UTC)
+	}
+	images := []struct {
+		name  string
+		image *image.Image
+		files map[string]hash.Hash
+	}{
+		{"web/v1", &image.Image{
+			CreatedBy: "alice",
+			CreatedOn: day(1),
+			Packages: []image.Package{
+				{Name: "nginx", Version: "1.18"},
+				{Name: "openssl", Version: "1.1.1v"},
+			},
+		}, map[string]hash.Hash{
+			"/etc/nginx.conf": {1},
+			"/usr/lib/ssl.so": {2},
+		}},
+		{"web/v10", &image.Image{
+			CreatedBy: "bob",
+			CreatedOn: day(10),
+			Packages: []image.Package{
+				{Name: "nginx", Version: "1.20"},
+				{Name: "openssl", Version: "1.1.1w"},
+			},
+		}, map[string]hash.Hash{
+			"/etc/nginx.conf": {1},
+			"/usr/lib/ssl.so": {3},
+			"/etc/empty":      {},
+		}},
+		{"web/v2", &image.Image{
+			CreatedBy: "alice",
+			CreatedOn: day(2),
+			Packages: []image.Package{
+				{Name: "nginx", Version: "1.18"},
+				{Name: "openssl-libs", Version: "1.1.1v"},
+			},
+		}, map[string]hash.Hash{
+			"/etc/nginx.conf":     {4},
+			"/usr/lib/ssl.so":     {2},
+			"/usr/lib/ssl-old.so": {2},
+		}},
+	}
+	idx := makeIndex(nil, testlogger.New(t))
+	for _, img := range images {
+		idx.add(img.name, img.image, makeTestFileSystem(t, img.files))
+	}
+	idx.ready = true
+	return idx
+}
+
+func TestFind(t *testing.T) {
+	idx := makeTestIndex(t)
+	hash1 := hash.Hash{1}
+	hash2 := hash.Hash{2}
+	hash9 := hash.Hash{9}
+	type found struct {
+		name     string
+		paths    []string
+		packages []string
+	}
+	var tests = []struct {
+		name    string
+		request imageserver.FindImagesRequest
+		want    []found
+		wantErr bool
+	}{
+		{"all", imageserver.FindImagesRequest{}, []found{
+			{name: "web/v1"}, {name: "web/v2"}, {name: "web/v10"},
+		}, false},
+		{"path", imageserver.FindImagesRequest{PathPattern: "/etc/empty"},
+			[]found{{name: "web/v10", paths: []string{"/etc/empty"}}},
+			false},
+		{"unclean path",
+			imageserver.FindImagesRequest{PathPattern: "/etc//empty/"},
+			[]found{{name: "web/v10", paths: []string{"/etc/empty"}}},
+			false},
+		{"missing path", imageserver.FindImagesRequest{PathPattern: "/x"},
+			nil, false},
+		{"path pattern",
+			imageserver.FindImagesRequest{PathPattern: "/usr/lib/ssl*"},
+			[]found{
+				{name: "web/v1", paths: []string{"/usr/lib/ssl.so"}},
+				{name: "web/v2", paths: []string{"/usr/lib/ssl-old.so",
+					"/usr/lib/ssl.so"}},
+				{name: "web/v10", paths: []string{"/usr/lib/ssl.so"}},
+			}, false},
+		{"object", imageserver.FindImagesRequest{ObjectHash: &hash2},
+			[]found{
+				{name: "web/v1", paths: []string{"/usr/lib/ssl.so"}},
+				{name: "web/v2", paths: []string{"/usr/lib/ssl-old.so",
+					"/usr/lib/ssl.so"}},
+			}, false},
+		{"missing object", imageserver.FindImagesRequest{ObjectHash: &hash9},
+			nil, false},
+		{"object at path", imageserver.FindImagesRequest{
+			ObjectHash:  &hash2,
+			PathPattern: "/usr/lib/ssl.so",
+		}, []found{
+			{name: "web/v1", paths: []string{"/usr/lib/ssl.so"}},
+			{name: "web/v2", paths: []string{"/usr/lib/ssl.so"}},
+		}, false},
+		{"object and creator", imageserver.FindImagesRequest{
+			CreatedBy:  "bob",
+			ObjectHash: &hash1,
+		}, []found{
+			{name: "web/v10", paths: []string{"/etc/nginx.conf"}},
+		}, false},
+		{"package", imageserver.FindImagesRequest{PackageName: "openssl"},
+			[]found{
+				{name: "web/v1", packages: []string{"openssl 1.1.1v"}},
+				{name: "web/v10", packages: []string{"openssl 1.1.1w"}},
+			}, false},
+		{"package pattern and version", imageserver.FindImagesRequest{
+			PackageName:    "openssl*",
+			PackageVersion: "<1.1.1w",
+		}, []found{
+			{name: "web/v1", packages: []string{"openssl 1.1.1v"}},
+			{name: "web/v2", packages: []string{"openssl-libs 1.1.1v"}},
+		}, false},
+		{"creator", imageserver.FindImagesRequest{CreatedBy: "alice"},
+			[]found{{name: "web/v1"}, {name: "web/v2"}}, false},
+		{"created range", imageserver.FindImagesRequest{
+			CreatedAfter:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			CreatedBefore: time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC),
+		}, []found{{name: "web/v2"}}, false},
+		{"bad path pattern", imageserver.FindImagesRequest{PathPattern: "["},
+			nil, true},
+		{"bad package pattern",
+			imageserver.FindImagesRequest{PackageName: "["}, nil, true},
+		{"version without package",
+			imageserver.FindImagesRequest{PackageVersion: "1.0"}, nil, true},
+		{"bad version", imageserver.FindImagesRequest{
+			PackageName:    "nginx",
+			PackageVersion: ">=",
+		}, nil, true},
+	}
+	for _, test := range tests {
+		images, err := idx.find(test.request)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: %v, want error: %t", test.name, err, test.wantErr)
+			continue
+		}
+		var got []found
+		for _, img := range images {
+			var packages []string
+			for _, pkg := range img.Packages {
+				packages = append(packages, pkg.Name+" "+pkg.Version)
+			}
+			got = append(got, found{
+				name:     img.Name,
+				paths:    img.Paths,
+				packages: packages,
+			})
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %+v, want %+v", test.name, got, test.want)
+		}
+	}
+	idx.ready = false
+	if _, err := idx.find(imageserver.FindImagesRequest{}); err == nil {
+		t.Error("no error when index not ready")
+	}
+}
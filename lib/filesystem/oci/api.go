@@ -0,0 +1,30 @@
+package oci
+
+import (
+	"io"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/filesystem/untar"
+	"github.com/Symantec/Dominator/lib/filter"
+	"github.com/Symantec/Dominator/lib/objectserver"
+)
+
+// Decode will read an OCI image layout or a Docker archive (as produced by
+// "docker save") tarfile from reader and will return the file-system obtained
+// by flattening the image layers. Whiteout files are mapped to deletions. The
+// data for regular files are passed to hasher. Layers which are gzip
+// compressed are decompressed. Scratch files are written to tmpDir.
+func Decode(reader io.Reader, hasher untar.Hasher, filter *filter.Filter,
+	tmpDir string) (*filesystem.FileSystem, error) {
+	return decode(reader, hasher, filter, tmpDir)
+}
+
+// Encode will write fileSystem to writer as a single-layer OCI image layout
+// tarfile. The tarfile also contains a Docker manifest so that it may be
+// loaded with "docker load". If reference is not empty, the image is tagged
+// with it. Scratch files are written to tmpDir.
+func Encode(writer io.Writer, fileSystem *filesystem.FileSystem,
+	objectsGetter objectserver.ObjectsGetter, reference string,
+	tmpDir string) error {
+	return encode(writer, fileSystem, objectsGetter, reference, tmpDir)
+}
@@ -0,0 +1,444 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/filesystem/untar"
+	"github.com/Symantec/Dominator/lib/filter"
+)
+
+const (
+	opaqueWhiteout = ".wh..wh..opq"
+	whiteoutPrefix = ".wh."
+)
+
+type dockerManifestType struct {
+	Config   string
+	Layers   []string
+	RepoTags []string
+}
+
+type configType struct {
+	Created time.Time `json:"created"`
+}
+
+type descriptorType struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType,omitempty"`
+	Size      int64  `json:"size"`
+}
+
+type indexType struct {
+	Manifests     []descriptorType `json:"manifests"`
+	SchemaVersion int              `json:"schemaVersion"`
+}
+
+type manifestType struct {
+	Config        descriptorType   `json:"config"`
+	Layers        []descriptorType `json:"layers"`
+	SchemaVersion int              `json:"schemaVersion"`
+}
+
+type layerEntry struct {
+	header   *tar.Header
+	dataFile string // Scratch file containing the data for regular files.
+	layer    int
+}
+
+type flattener struct {
+	entries    map[string]*layerEntry // Key: normalised pathname.
+	modTime    time.Time              // For synthesised directories.
+	scratchDir string
+	nextFile   uint64
+}
+
+func decode(reader io.Reader, hasher untar.Hasher, filter *filter.Filter,
+	tmpDir string) (*filesystem.FileSystem, error) {
+	archiveDir, err := ioutil.TempDir(tmpDir, "oci-archive")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(archiveDir)
+	if err := extractArchive(reader, archiveDir); err != nil {
+		return nil, fmt.Errorf("error extracting archive: %s", err)
+	}
+	layers, configFilename, err := getLayerFilenames(archiveDir)
+	if err != nil {
+		return nil, err
+	}
+	var config configType
+	if configFilename != "" {
+		if err := readJson(configFilename, &config); err != nil {
+			return nil, fmt.Errorf("error reading image config: %s", err)
+		}
+	}
+	scratchDir, err := ioutil.TempDir(tmpDir, "oci-layers")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratchDir)
+	f := &flattener{
+		entries:    make(map[string]*layerEntry),
+		modTime:    config.Created,
+		scratchDir: scratchDir,
+	}
+	for index, layer := range layers {
+		if err := f.applyLayerFile(layer, index); err != nil {
+			return nil, fmt.Errorf("error applying layer: %s: %s",
+				filepath.Base(layer), err)
+		}
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		pipeWriter.CloseWithError(f.write(pipeWriter))
+	}()
+	fs, err := untar.Decode(tar.NewReader(pipeReader), hasher, filter)
+	pipeReader.Close()
+	return fs, err
+}
+
+func normaliseName(name string) string {
+	return path.Clean("/" + name)
+}
+
+// extractArchive will extract the regular files in the archive into dirname.
+// Symbolic links and hard links are extracted as symbolic links to the target
+// within dirname, so that they cannot refer to files outside the archive.
+func extractArchive(reader io.Reader, dirname string) error {
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := normaliseName(header.Name)
+		var target string
+		switch header.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+		case tar.TypeSymlink:
+			if path.IsAbs(header.Linkname) {
+				target = normaliseName(header.Linkname)
+			} else {
+				target = normaliseName(
+					path.Join(path.Dir(name), header.Linkname))
+			}
+		case tar.TypeLink:
+			target = normaliseName(header.Linkname)
+		default:
+			continue
+		}
+		filename := filepath.Join(dirname, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			return err
+		}
+		// Replace earlier entries, rather than writing through a link.
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if target != "" {
+			err := os.Symlink(filepath.Join(dirname, target), filename)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, tarReader)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func digestToFilename(archiveDir, digest string) (string, error) {
+	split := strings.SplitN(digest, ":", 2)
+	if len(split) != 2 || strings.Contains(split[1], "/") {
+		return "", errors.New("malformed digest: " + digest)
+	}
+	return filepath.Join(archiveDir, "blobs", split[0], split[1]), nil
+}
+
+// getLayerFilenames will return the filenames of the layers in the extracted
+// archive, lowest layer first, and the filename of the image configuration (or
+// the empty string if there is none).
+func getLayerFilenames(archiveDir string) ([]string, string, error) {
+	var dockerManifests []dockerManifestType
+	err := readJson(filepath.Join(archiveDir, "manifest.json"),
+		&dockerManifests)
+	if err == nil {
+		if len(dockerManifests) != 1 {
+			return nil, "", fmt.Errorf("archive has %d images, expected 1",
+				len(dockerManifests))
+		}
+		filenames := make([]string, 0, len(dockerManifests[0].Layers))
+		for _, layer := range dockerManifests[0].Layers {
+			filenames = append(filenames,
+				filepath.Join(archiveDir, normaliseName(layer)))
+		}
+		var configFilename string
+		if config := dockerManifests[0].Config; config != "" {
+			configFilename = filepath.Join(archiveDir, normaliseName(config))
+		}
+		return filenames, configFilename, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, "", err
+	}
+	var index indexType
+	if err := readJson(filepath.Join(archiveDir, "index.json"),
+		&index); err != nil {
+		if os.IsNotExist(err) {
+			return nil, "",
+				errors.New("not an OCI image layout or Docker archive")
+		}
+		return nil, "", err
+	}
+	if len(index.Manifests) != 1 {
+		return nil, "", fmt.Errorf("image index has %d manifests, expected 1",
+			len(index.Manifests))
+	}
+	manifestFilename, err := digestToFilename(archiveDir,
+		index.Manifests[0].Digest)
+	if err != nil {
+		return nil, "", err
+	}
+	var manifest manifestType
+	if err := readJson(manifestFilename, &manifest); err != nil {
+		return nil, "", err
+	}
+	filenames := make([]string, 0, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		filename, err := digestToFilename(archiveDir, layer.Digest)
+		if err != nil {
+			return nil, "", err
+		}
+		filenames = append(filenames, filename)
+	}
+	var configFilename string
+	if manifest.Config.Digest != "" {
+		configFilename, err = digestToFilename(archiveDir,
+			manifest.Config.Digest)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return filenames, configFilename, nil
+}
+
+func readJson(filename string, value interface{}) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewDecoder(bufio.NewReader(file)).Decode(value)
+}
+
+func (f *flattener) applyLayerFile(filename string, layer int) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	if magic, err := reader.Peek(2); err == nil &&
+		magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		return f.applyLayer(tar.NewReader(gzipReader), layer)
+	}
+	return f.applyLayer(tar.NewReader(reader), layer)
+}
+
+func (f *flattener) applyLayer(tarReader *tar.Reader, layer int) error {
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := normaliseName(header.Name)
+		dirname, leafName := path.Split(name)
+		if leafName == opaqueWhiteout {
+			f.deleteChildren(path.Clean(dirname), layer)
+			continue
+		}
+		if strings.HasPrefix(leafName, whiteoutPrefix) {
+			deletedName := path.Join(dirname, leafName[len(whiteoutPrefix):])
+			if entry, ok := f.entries[deletedName]; ok && entry.layer < layer {
+				f.delete(deletedName)
+			}
+			f.deleteChildren(deletedName, layer)
+			continue
+		}
+		if err := f.addEntry(tarReader, header, name, layer); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *flattener) addEntry(tarReader *tar.Reader, header *tar.Header,
+	name string, layer int) error {
+	header.Name = name
+	if header.Typeflag == tar.TypeLink {
+		header.Linkname = normaliseName(header.Linkname)
+	}
+	if oldEntry, ok := f.entries[name]; ok {
+		if oldEntry.header.Typeflag != tar.TypeDir ||
+			header.Typeflag != tar.TypeDir {
+			f.deleteChildren(name, layer)
+		}
+		f.delete(name)
+	}
+	f.makeParents(name, layer, header.ModTime)
+	entry := &layerEntry{header: header, layer: layer}
+	if header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA {
+		entry.dataFile = filepath.Join(f.scratchDir,
+			strconv.FormatUint(f.nextFile, 10))
+		f.nextFile++
+		file, err := os.Create(entry.dataFile)
+		if err != nil {
+			return err
+		}
+		_, err = io.CopyN(file, tarReader, header.Size)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	f.entries[name] = entry
+	return nil
+}
+
+// makeParents will create any missing parent directories for name. They are
+// given the creation time of the image, or if that is not known, the
+// modification time of the entry, so that decoding is reproducible.
+func (f *flattener) makeParents(name string, layer int, modTime time.Time) {
+	if !f.modTime.IsZero() {
+		modTime = f.modTime
+	}
+	for dirname := path.Dir(name); name != "/"; dirname = path.Dir(dirname) {
+		if _, ok := f.entries[dirname]; !ok {
+			f.entries[dirname] = &layerEntry{
+				header: &tar.Header{
+					Name:     dirname,
+					Mode:     0755,
+					ModTime:  modTime,
+					Typeflag: tar.TypeDir,
+				},
+				layer: layer,
+			}
+		}
+		if dirname == "/" {
+			break
+		}
+	}
+}
+
+func (f *flattener) delete(name string) {
+	if entry, ok := f.entries[name]; ok {
+		if entry.dataFile != "" {
+			os.Remove(entry.dataFile)
+		}
+		delete(f.entries, name)
+	}
+}
+
+// deleteChildren will delete all entries below dirname which were added in
+// lower layers.
+func (f *flattener) deleteChildren(dirname string, layer int) {
+	prefix := dirname + "/"
+	if dirname == "/" {
+		prefix = "/"
+	}
+	for name, entry := range f.entries {
+		if entry.layer < layer && name != "/" &&
+			strings.HasPrefix(name, prefix) {
+			f.delete(name)
+		}
+	}
+}
+
+// write will write the flattened file-system as a tar stream. Hardlinks are
+// written after their targets.
+func (f *flattener) write(writer io.Writer) error {
+	names := make([]string, 0, len(f.entries))
+	for name := range f.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tarWriter := tar.NewWriter(writer)
+	var hardlinks []*tar.Header
+	for _, name := range names {
+		entry := f.entries[name]
+		if entry.header.Typeflag == tar.TypeLink {
+			if _, ok := f.entries[entry.header.Linkname]; !ok {
+				continue // Target was deleted by a later layer.
+			}
+			hardlinks = append(hardlinks, entry.header)
+			continue
+		}
+		if err := writeEntry(tarWriter, entry); err != nil {
+			return err
+		}
+	}
+	for _, header := range hardlinks {
+		header.Name = "." + header.Name
+		header.Linkname = "." + header.Linkname
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+func writeEntry(tarWriter *tar.Writer, entry *layerEntry) error {
+	header := *entry.header
+	if header.Name == "/" {
+		header.Name = "./"
+	} else if header.Typeflag == tar.TypeDir {
+		header.Name = "." + header.Name + "/"
+	} else {
+		header.Name = "." + header.Name
+	}
+	if err := tarWriter.WriteHeader(&header); err != nil {
+		return err
+	}
+	if entry.dataFile == "" {
+		return nil
+	}
+	file, err := os.Open(entry.dataFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
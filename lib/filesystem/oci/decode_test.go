@@ -0,0 +1,201 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+)
+
+var testModTime = time.Unix(1500000000, 0)
+
+type testHasher struct{}
+
+type testFile struct {
+	name     string
+	typeflag byte
+	data     string // Link target for links.
+}
+
+func (testHasher) Hash(reader io.Reader, length uint64) (hash.Hash, error) {
+	var hashVal hash.Hash
+	_, err := io.CopyN(ioutil.Discard, reader, int64(length))
+	return hashVal, err
+}
+
+func makeTar(t *testing.T, files []testFile) []byte {
+	buffer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buffer)
+	for _, file := range files {
+		header := &tar.Header{
+			Name:     file.name,
+			Mode:     0755,
+			ModTime:  testModTime,
+			Size:     int64(len(file.data)),
+			Typeflag: file.typeflag,
+		}
+		if file.typeflag == tar.TypeSymlink || file.typeflag == tar.TypeLink {
+			header.Linkname = file.data
+			header.Size = 0
+			file.data = ""
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(file.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func listNames(fs *filesystem.FileSystem) map[string]struct{} {
+	names := make(map[string]struct{})
+	var walk func(dirname string, directory *filesystem.DirectoryInode)
+	walk = func(dirname string, directory *filesystem.DirectoryInode) {
+		for _, entry := range directory.EntryList {
+			name := dirname + "/" + entry.Name
+			names[name] = struct{}{}
+			if inode, ok := entry.Inode().(*filesystem.DirectoryInode); ok {
+				walk(name, inode)
+			}
+		}
+	}
+	walk("", &fs.DirectoryInode)
+	return names
+}
+
+func TestDecodeWhiteouts(t *testing.T) {
+	layer0 := makeTar(t, []testFile{
+		{"etc/", tar.TypeDir, ""},
+		{"etc/passwd", tar.TypeReg, "root"},
+		{"etc/hosts", tar.TypeReg, "localhost"},
+		{"opt/", tar.TypeDir, ""},
+		{"opt/app/", tar.TypeDir, ""},
+		{"opt/app/old", tar.TypeReg, "old"},
+		{"var/lib/data", tar.TypeReg, "data"},
+	})
+	layer1 := makeTar(t, []testFile{
+		{"etc/.wh.hosts", tar.TypeReg, ""},
+		{"opt/app/.wh..wh..opq", tar.TypeReg, ""},
+		{"opt/app/new", tar.TypeReg, "new"},
+		{"var/.wh.lib", tar.TypeReg, ""},
+	})
+	manifest, err := json.Marshal([]dockerManifestType{{
+		Layers: []string{"layer0/layer.tar", "layer1/layer.tar"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := makeTar(t, []testFile{
+		{"manifest.json", tar.TypeReg, string(manifest)},
+		{"layer0/layer.tar", tar.TypeReg, string(layer0)},
+		{"layer1/layer.tar", tar.TypeReg, string(layer1)},
+	})
+	fs, err := Decode(bytes.NewReader(archive), testHasher{}, nil,
+		os.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := listNames(fs)
+	for _, name := range []string{"/etc/passwd", "/opt/app/new", "/var"} {
+		if _, ok := names[name]; !ok {
+			t.Errorf("%s missing", name)
+		}
+	}
+	for _, name := range []string{"/etc/hosts", "/opt/app/old", "/var/lib"} {
+		if _, ok := names[name]; ok {
+			t.Errorf("%s should have been deleted", name)
+		}
+	}
+}
+
+func TestDecodeLinkedLayers(t *testing.T) {
+	layer := makeTar(t, []testFile{{"etc/passwd", tar.TypeReg, "root"}})
+	var tests = []struct {
+		name  string
+		files []testFile
+	}{
+		{"relative symlink", []testFile{
+			{"a/layer.tar", tar.TypeReg, string(layer)},
+			{"b/layer.tar", tar.TypeSymlink, "../a/layer.tar"},
+		}},
+		{"absolute symlink", []testFile{
+			{"b/layer.tar", tar.TypeSymlink, "/a/layer.tar"},
+			{"a/layer.tar", tar.TypeReg, string(layer)},
+		}},
+		{"hard link", []testFile{
+			{"a/layer.tar", tar.TypeReg, string(layer)},
+			{"b/layer.tar", tar.TypeLink, "a/layer.tar"},
+		}},
+		{"escaping symlink", []testFile{
+			{"a/layer.tar", tar.TypeReg, string(layer)},
+			{"b/layer.tar", tar.TypeSymlink, "../../../a/layer.tar"},
+		}},
+	}
+	manifest, err := json.Marshal([]dockerManifestType{{
+		Layers: []string{"b/layer.tar"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		files := append([]testFile{
+			{"manifest.json", tar.TypeReg, string(manifest)},
+		}, test.files...)
+		fs, err := Decode(bytes.NewReader(makeTar(t, files)), testHasher{},
+			nil, os.TempDir())
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if _, ok := listNames(fs)["/etc/passwd"]; !ok {
+			t.Errorf("%s: /etc/passwd missing", test.name)
+		}
+	}
+}
+
+func TestMakeParentsModTime(t *testing.T) {
+	configTime := time.Unix(1600000000, 0)
+	var tests = []struct {
+		name        string
+		configTime  time.Time
+		wantModTime time.Time
+	}{
+		{"config time", configTime, configTime},
+		{"entry time", time.Time{}, testModTime},
+	}
+	layer := makeTar(t, []testFile{{"a/b/c", tar.TypeReg, "data"}})
+	for _, test := range tests {
+		f := &flattener{
+			entries:    make(map[string]*layerEntry),
+			modTime:    test.configTime,
+			scratchDir: t.TempDir(),
+		}
+		err := f.applyLayer(tar.NewReader(bytes.NewReader(layer)), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"/", "/a", "/a/b"} {
+			entry, ok := f.entries[name]
+			if !ok {
+				t.Errorf("%s: %s not created", test.name, name)
+				continue
+			}
+			if !entry.header.ModTime.Equal(test.wantModTime) {
+				t.Errorf("%s: %s ModTime = %s, want %s", test.name, name,
+					entry.header.ModTime, test.wantModTime)
+			}
+		}
+	}
+}
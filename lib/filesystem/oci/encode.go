@@ -0,0 +1,198 @@
+package oci
+
+import (
+	"archive/tar"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	fstar "github.com/Symantec/Dominator/lib/filesystem/tar"
+	"github.com/Symantec/Dominator/lib/objectserver"
+)
+
+const (
+	mediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	mediaTypeLayer    = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	refNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+type imageConfigType struct {
+	Architecture string     `json:"architecture"`
+	Created      time.Time  `json:"created"`
+	OS           string     `json:"os"`
+	RootFS       rootFSType `json:"rootfs"`
+}
+
+type indexManifestType struct {
+	Annotations map[string]string `json:"annotations,omitempty"`
+	descriptorType
+}
+
+type outputIndexType struct {
+	Manifests     []indexManifestType `json:"manifests"`
+	SchemaVersion int                 `json:"schemaVersion"`
+}
+
+type rootFSType struct {
+	DiffIds []string `json:"diff_ids"`
+	Type    string   `json:"type"`
+}
+
+type archiveWriter struct {
+	tarWriter *tar.Writer
+	modTime   time.Time
+}
+
+func encode(writer io.Writer, fileSystem *filesystem.FileSystem,
+	objectsGetter objectserver.ObjectsGetter, reference string,
+	tmpDir string) error {
+	layerFile, err := ioutil.TempFile(tmpDir, "oci-layer")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(layerFile.Name())
+	defer layerFile.Close()
+	hasher := sha256.New()
+	bufferedWriter := bufio.NewWriter(io.MultiWriter(layerFile, hasher))
+	if err := fstar.Write(bufferedWriter, fileSystem,
+		objectsGetter); err != nil {
+		return err
+	}
+	if err := bufferedWriter.Flush(); err != nil {
+		return err
+	}
+	layerSize, err := layerFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := layerFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	layerDigest := "sha256:" + hex.EncodeToString(hasher.Sum(nil))
+	aw := &archiveWriter{tarWriter: tar.NewWriter(writer), modTime: time.Now()}
+	err = aw.writeFile("oci-layout", []byte(`{"imageLayoutVersion":"1.0.0"}`))
+	if err != nil {
+		return err
+	}
+	layerFilename := digestPath(layerDigest)
+	if err := aw.writeReader(layerFilename, layerFile, layerSize); err != nil {
+		return err
+	}
+	config, err := json.Marshal(imageConfigType{
+		Architecture: runtime.GOARCH,
+		Created:      aw.modTime.UTC(),
+		OS:           "linux",
+		RootFS: rootFSType{
+			DiffIds: []string{layerDigest},
+			Type:    "layers",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	configDescriptor, err := aw.writeBlob(config, mediaTypeConfig)
+	if err != nil {
+		return err
+	}
+	manifest, err := json.Marshal(manifestType{
+		Config: configDescriptor,
+		Layers: []descriptorType{{
+			Digest:    layerDigest,
+			MediaType: mediaTypeLayer,
+			Size:      layerSize,
+		}},
+		SchemaVersion: 2,
+	})
+	if err != nil {
+		return err
+	}
+	manifestDescriptor, err := aw.writeBlob(manifest, mediaTypeManifest)
+	if err != nil {
+		return err
+	}
+	index := outputIndexType{
+		Manifests:     []indexManifestType{{descriptorType: manifestDescriptor}},
+		SchemaVersion: 2,
+	}
+	var repoTags []string
+	if reference != "" {
+		index.Manifests[0].Annotations = map[string]string{
+			refNameAnnotation: reference,
+		}
+		repoTags = []string{reference}
+	}
+	if err := aw.writeJson("index.json", index); err != nil {
+		return err
+	}
+	dockerManifests := []dockerManifestType{{
+		Config:   digestPath(configDescriptor.Digest),
+		Layers:   []string{layerFilename},
+		RepoTags: repoTags,
+	}}
+	if err := aw.writeJson("manifest.json", dockerManifests); err != nil {
+		return err
+	}
+	return aw.tarWriter.Close()
+}
+
+func digestPath(digest string) string {
+	return "blobs/sha256/" + digest[len("sha256:"):]
+}
+
+func (aw *archiveWriter) writeBlob(data []byte, mediaType string) (
+	descriptorType, error) {
+	checksum := sha256.Sum256(data)
+	descriptor := descriptorType{
+		Digest:    "sha256:" + hex.EncodeToString(checksum[:]),
+		MediaType: mediaType,
+		Size:      int64(len(data)),
+	}
+	return descriptor, aw.writeFile(digestPath(descriptor.Digest), data)
+}
+
+func (aw *archiveWriter) writeFile(filename string, data []byte) error {
+	header := &tar.Header{
+		Name:     filename,
+		Mode:     0644,
+		ModTime:  aw.modTime,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+	}
+	if err := aw.tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := aw.tarWriter.Write(data)
+	return err
+}
+
+func (aw *archiveWriter) writeJson(filename string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return aw.writeFile(filename, data)
+}
+
+func (aw *archiveWriter) writeReader(filename string, reader io.Reader,
+	size int64) error {
+	header := &tar.Header{
+		Name:     filename,
+		Mode:     0644,
+		ModTime:  aw.modTime,
+		Size:     size,
+		Typeflag: tar.TypeReg,
+	}
+	if err := aw.tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := io.CopyN(aw.tarWriter, bufio.NewReader(reader), size)
+	return err
+}
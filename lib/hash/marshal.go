@@ -1,5 +1,9 @@
 package hash
 
+import (
+	"errors"
+)
+
 func (h Hash) marshalText() ([]byte, error) {
 	retval := make([]byte, 0, 2*len(h))
 	for _, byteVal := range h {
@@ -15,3 +19,31 @@ func formatNibble(nibble byte) byte {
 	}
 	return 'a' + nibble - 10
 }
+
+func (h *Hash) unmarshalText(text []byte) error {
+	if len(text) != 2*len(h) {
+		return errors.New("bad hash length")
+	}
+	for index := range h {
+		high, err := parseNibble(text[2*index])
+		if err != nil {
+			return err
+		}
+		low, err := parseNibble(text[2*index+1])
+		if err != nil {
+			return err
+		}
+		h[index] = high<<4 | low
+	}
+	return nil
+}
+
+func parseNibble(char byte) (byte, error) {
+	if char >= '0' && char <= '9' {
+		return char - '0', nil
+	}
+	if char >= 'a' && char <= 'f' {
+		return char - 'a' + 10, nil
+	}
+	return 0, errors.New("bad hex character")
+}
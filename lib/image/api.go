@@ -11,13 +11,30 @@ import (
 	"github.com/Symantec/Dominator/lib/triggers"
 )
 
+// Alias is a named, movable reference to an image. Only members of the owner
+// group may move an alias.
+type Alias struct {
+	Name       string
+	ImageName  string
+	OwnerGroup string
+	History    []AliasChange // Oldest first. The last entry is the current.
+}
+
+type AliasChange struct {
+	ImageName string
+	MovedAt   time.Time
+	MovedBy   string // Username. Empty: unauthenticated.
+}
+
 type Annotation struct {
 	Object *hash.Hash // These are mutually exclusive.
 	URL    string
 }
 
 type DirectoryMetadata struct {
-	OwnerGroup string
+	OwnerGroup     string
+	HardQuotaBytes uint64 // Zero: no quota.
+	SoftQuotaBytes uint64 // Zero: no quota.
 }
 
 type Directory struct {
@@ -32,6 +49,7 @@ type Image struct {
 	Triggers     *triggers.Triggers
 	ReleaseNotes *Annotation
 	BuildLog     *Annotation
+	TestResults  *Annotation
 	CreatedOn    time.Time
 	ExpiresAt    time.Time
 	Packages     []Package
@@ -0,0 +1,70 @@
+package diff
+
+import (
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/triggers"
+)
+
+// AttributeChange records the left and right values of an inode attribute.
+type AttributeChange struct {
+	Attribute string
+	Left      string
+	Right     string
+}
+
+// Diff is a structured difference between a left (old) and right (new) image.
+type Diff struct {
+	Added          []Path          `json:",omitempty"` // Only in right image.
+	Removed        []Path          `json:",omitempty"` // Only in left image.
+	Changed        []PathChange    `json:",omitempty"`
+	Packages       []PackageChange `json:",omitempty"`
+	Filter         FilterChange
+	Triggers       []TriggerChange `json:",omitempty"`
+	AddedBytes     uint64          // Data in added and changed files.
+	RemovedBytes   uint64          // Data in removed and changed files.
+	NewObjectBytes uint64          // Data in objects not in the left image.
+}
+
+type FilterChange struct {
+	AddedLines   []string `json:",omitempty"`
+	RemovedLines []string `json:",omitempty"`
+	LeftIsNil    bool     `json:",omitempty"` // Left image is sparse.
+	RightIsNil   bool     `json:",omitempty"` // Right image is sparse.
+}
+
+// PackageChange records a package version change. An empty version means the
+// package is not present in that image.
+type PackageChange struct {
+	Name         string
+	LeftVersion  string `json:",omitempty"`
+	RightVersion string `json:",omitempty"`
+}
+
+type Path struct {
+	Name string
+	Type string
+	Size uint64 `json:",omitempty"` // Regular files only.
+}
+
+type PathChange struct {
+	Name    string
+	Changes []AttributeChange
+}
+
+// TriggerChange records a changed trigger. A nil trigger means the trigger is
+// not present in that image.
+type TriggerChange struct {
+	Service string
+	Left    *triggers.Trigger `json:",omitempty"`
+	Right   *triggers.Trigger `json:",omitempty"`
+}
+
+// Compute will compute the differences between the left and right images.
+func Compute(left, right *image.Image) *Diff {
+	return compute(left, right)
+}
+
+// IsEmpty returns true if there are no differences.
+func (diff *Diff) IsEmpty() bool {
+	return diff.isEmpty()
+}
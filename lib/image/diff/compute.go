@@ -0,0 +1,315 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/filter"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/triggers"
+)
+
+func compute(left, right *image.Image) *Diff {
+	diff := &Diff{}
+	diff.compareFileSystems(left.FileSystem, right.FileSystem)
+	diff.comparePackages(left.Packages, right.Packages)
+	diff.compareFilters(left.Filter, right.Filter)
+	diff.compareTriggers(left.Triggers, right.Triggers)
+	return diff
+}
+
+func (diff *Diff) isEmpty() bool {
+	return len(diff.Added) < 1 &&
+		len(diff.Removed) < 1 &&
+		len(diff.Changed) < 1 &&
+		len(diff.Packages) < 1 &&
+		len(diff.Filter.AddedLines) < 1 &&
+		len(diff.Filter.RemovedLines) < 1 &&
+		diff.Filter.LeftIsNil == diff.Filter.RightIsNil &&
+		len(diff.Triggers) < 1
+}
+
+func buildPathTable(fs *filesystem.FileSystem) map[string]filesystem.GenericInode {
+	table := make(map[string]filesystem.GenericInode)
+	if fs == nil {
+		return table
+	}
+	fs.ForEachFile(func(name string, inodeNumber uint64,
+		inode filesystem.GenericInode) error {
+		table[name] = inode
+		return nil
+	})
+	return table
+}
+
+func sortedKeys(table map[string]filesystem.GenericInode) []string {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func inodeType(inode filesystem.GenericInode) string {
+	switch inode.(type) {
+	case *filesystem.DirectoryInode:
+		return "directory"
+	case *filesystem.RegularInode:
+		return "file"
+	case *filesystem.ComputedRegularInode:
+		return "computed"
+	case *filesystem.SymlinkInode:
+		return "symlink"
+	case *filesystem.SpecialInode:
+		return "special"
+	}
+	return "unknown"
+}
+
+func makePath(name string, inode filesystem.GenericInode) Path {
+	path := Path{Name: name, Type: inodeType(inode)}
+	if inode, ok := inode.(*filesystem.RegularInode); ok {
+		path.Size = inode.Size
+	}
+	return path
+}
+
+func formatMtime(seconds int64, nanoSeconds int32) string {
+	return time.Unix(seconds, int64(nanoSeconds)).UTC().Format(
+		time.RFC3339Nano)
+}
+
+type attributeComparer []AttributeChange
+
+func (changes *attributeComparer) compare(attribute, left, right string) {
+	if left != right {
+		*changes = append(*changes, AttributeChange{
+			Attribute: attribute,
+			Left:      left,
+			Right:     right,
+		})
+	}
+}
+
+func (changes *attributeComparer) compareUint(attribute string,
+	left, right uint64) {
+	if left != right {
+		changes.compare(attribute, strconv.FormatUint(left, 10),
+			strconv.FormatUint(right, 10))
+	}
+}
+
+func compareInodes(left, right filesystem.GenericInode) []AttributeChange {
+	var changes attributeComparer
+	leftType := inodeType(left)
+	rightType := inodeType(right)
+	if leftType != rightType {
+		changes.compare("type", leftType, rightType)
+		return changes
+	}
+	changes.compareUint("uid", uint64(left.GetUid()), uint64(right.GetUid()))
+	changes.compareUint("gid", uint64(left.GetGid()), uint64(right.GetGid()))
+	switch left := left.(type) {
+	case *filesystem.DirectoryInode:
+		right := right.(*filesystem.DirectoryInode)
+		changes.compare("mode", left.Mode.String(), right.Mode.String())
+	case *filesystem.RegularInode:
+		right := right.(*filesystem.RegularInode)
+		changes.compare("mode", left.Mode.String(), right.Mode.String())
+		changes.compare("mtime",
+			formatMtime(left.MtimeSeconds, left.MtimeNanoSeconds),
+			formatMtime(right.MtimeSeconds, right.MtimeNanoSeconds))
+		changes.compareUint("size", left.Size, right.Size)
+		changes.compare("hash", fmt.Sprintf("%x", left.Hash),
+			fmt.Sprintf("%x", right.Hash))
+	case *filesystem.ComputedRegularInode:
+		right := right.(*filesystem.ComputedRegularInode)
+		changes.compare("mode", left.Mode.String(), right.Mode.String())
+		changes.compare("source", left.Source, right.Source)
+	case *filesystem.SymlinkInode:
+		right := right.(*filesystem.SymlinkInode)
+		changes.compare("symlink", left.Symlink, right.Symlink)
+	case *filesystem.SpecialInode:
+		right := right.(*filesystem.SpecialInode)
+		changes.compare("mode", left.Mode.String(), right.Mode.String())
+		changes.compare("mtime",
+			formatMtime(left.MtimeSeconds, left.MtimeNanoSeconds),
+			formatMtime(right.MtimeSeconds, right.MtimeNanoSeconds))
+		changes.compare("rdev", fmt.Sprintf("%#x", left.Rdev),
+			fmt.Sprintf("%#x", right.Rdev))
+	}
+	return changes
+}
+
+func regularSize(inode filesystem.GenericInode) uint64 {
+	if inode, ok := inode.(*filesystem.RegularInode); ok {
+		return inode.Size
+	}
+	return 0
+}
+
+func (diff *Diff) compareFileSystems(left, right *filesystem.FileSystem) {
+	leftTable := buildPathTable(left)
+	rightTable := buildPathTable(right)
+	for _, name := range sortedKeys(leftTable) {
+		leftInode := leftTable[name]
+		rightInode, ok := rightTable[name]
+		if !ok {
+			diff.Removed = append(diff.Removed, makePath(name, leftInode))
+			diff.RemovedBytes += regularSize(leftInode)
+			continue
+		}
+		changes := compareInodes(leftInode, rightInode)
+		if len(changes) < 1 {
+			continue
+		}
+		diff.Changed = append(diff.Changed,
+			PathChange{Name: name, Changes: changes})
+		leftReg, leftOk := leftInode.(*filesystem.RegularInode)
+		rightReg, rightOk := rightInode.(*filesystem.RegularInode)
+		if leftOk && rightOk && leftReg.Hash == rightReg.Hash {
+			continue // Metadata change only.
+		}
+		diff.RemovedBytes += regularSize(leftInode)
+		diff.AddedBytes += regularSize(rightInode)
+	}
+	for _, name := range sortedKeys(rightTable) {
+		if _, ok := leftTable[name]; !ok {
+			rightInode := rightTable[name]
+			diff.Added = append(diff.Added, makePath(name, rightInode))
+			diff.AddedBytes += regularSize(rightInode)
+		}
+	}
+	if right != nil {
+		var leftObjects map[hash.Hash]uint64
+		if left != nil {
+			leftObjects = left.GetObjects()
+		}
+		for hashVal, size := range right.GetObjects() {
+			if _, ok := leftObjects[hashVal]; !ok {
+				diff.NewObjectBytes += size
+			}
+		}
+	}
+}
+
+func (diff *Diff) comparePackages(left, right []image.Package) {
+	leftVersions := make(map[string]string, len(left))
+	for _, pkg := range left {
+		leftVersions[pkg.Name] = pkg.Version
+	}
+	rightVersions := make(map[string]string, len(right))
+	for _, pkg := range right {
+		rightVersions[pkg.Name] = pkg.Version
+	}
+	for name, leftVersion := range leftVersions {
+		rightVersion, ok := rightVersions[name]
+		if !ok || rightVersion != leftVersion {
+			diff.Packages = append(diff.Packages, PackageChange{
+				Name:         name,
+				LeftVersion:  leftVersion,
+				RightVersion: rightVersion,
+			})
+		}
+	}
+	for name, rightVersion := range rightVersions {
+		if _, ok := leftVersions[name]; !ok {
+			diff.Packages = append(diff.Packages, PackageChange{
+				Name:         name,
+				RightVersion: rightVersion,
+			})
+		}
+	}
+	sort.Slice(diff.Packages, func(i, j int) bool {
+		return diff.Packages[i].Name < diff.Packages[j].Name
+	})
+}
+
+func compareLines(left, right []string) ([]string, []string) {
+	leftLines := make(map[string]struct{}, len(left))
+	for _, line := range left {
+		leftLines[line] = struct{}{}
+	}
+	rightLines := make(map[string]struct{}, len(right))
+	for _, line := range right {
+		rightLines[line] = struct{}{}
+	}
+	var added, removed []string
+	for _, line := range right {
+		if _, ok := leftLines[line]; !ok {
+			added = append(added, line)
+		}
+	}
+	for _, line := range left {
+		if _, ok := rightLines[line]; !ok {
+			removed = append(removed, line)
+		}
+	}
+	return added, removed
+}
+
+func (diff *Diff) compareFilters(left, right *filter.Filter) {
+	var leftLines, rightLines []string
+	if left == nil {
+		diff.Filter.LeftIsNil = true
+	} else {
+		leftLines = left.FilterLines
+	}
+	if right == nil {
+		diff.Filter.RightIsNil = true
+	} else {
+		rightLines = right.FilterLines
+	}
+	diff.Filter.AddedLines, diff.Filter.RemovedLines = compareLines(
+		leftLines, rightLines)
+}
+
+func makeTriggerTable(trigs *triggers.Triggers) map[string]*triggers.Trigger {
+	table := make(map[string]*triggers.Trigger)
+	if trigs == nil {
+		return table
+	}
+	for _, trigger := range trigs.Triggers {
+		table[trigger.Service] = trigger
+	}
+	return table
+}
+
+func compareTriggers(left, right *triggers.Trigger) bool {
+	if left.DoReboot != right.DoReboot || left.HighImpact != right.HighImpact {
+		return false
+	}
+	added, removed := compareLines(left.MatchLines, right.MatchLines)
+	return len(added) < 1 && len(removed) < 1
+}
+
+func (diff *Diff) compareTriggers(left, right *triggers.Triggers) {
+	leftTable := makeTriggerTable(left)
+	rightTable := makeTriggerTable(right)
+	for service, leftTrigger := range leftTable {
+		rightTrigger := rightTable[service]
+		if rightTrigger == nil || !compareTriggers(leftTrigger, rightTrigger) {
+			diff.Triggers = append(diff.Triggers, TriggerChange{
+				Service: service,
+				Left:    leftTrigger,
+				Right:   rightTrigger,
+			})
+		}
+	}
+	for service, rightTrigger := range rightTable {
+		if _, ok := leftTable[service]; !ok {
+			diff.Triggers = append(diff.Triggers, TriggerChange{
+				Service: service,
+				Right:   rightTrigger,
+			})
+		}
+	}
+	sort.Slice(diff.Triggers, func(i, j int) bool {
+		return diff.Triggers[i].Service < diff.Triggers[j].Service
+	})
+}
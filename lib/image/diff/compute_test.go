@@ -0,0 +1,118 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/filter"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/triggers"
+)
+
+func makeFileSystem(t *testing.T,
+	inodes map[string]filesystem.GenericInode) *filesystem.FileSystem {
+	fs := &filesystem.FileSystem{
+		InodeTable:     make(filesystem.InodeTable),
+		DirectoryInode: filesystem.DirectoryInode{Mode: 0755},
+	}
+	var inodeNumber uint64
+	for name, inode := range inodes {
+		inodeNumber++
+		fs.InodeTable[inodeNumber] = inode
+		fs.EntryList = append(fs.EntryList, &filesystem.DirectoryEntry{
+			Name:        name,
+			InodeNumber: inodeNumber,
+		})
+	}
+	if err := fs.RebuildInodePointers(); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func makeHash(value byte) hash.Hash {
+	var hashVal hash.Hash
+	hashVal[0] = value
+	return hashVal
+}
+
+func TestCompute(t *testing.T) {
+	left := &image.Image{
+		FileSystem: makeFileSystem(t, map[string]filesystem.GenericInode{
+			"changed": &filesystem.RegularInode{
+				Mode: 0644, Size: 100, Hash: makeHash(1)},
+			"chmod": &filesystem.RegularInode{
+				Mode: 0644, Size: 10, Hash: makeHash(2)},
+			"removed": &filesystem.RegularInode{
+				Mode: 0644, Size: 1000, Hash: makeHash(3)},
+			"link": &filesystem.SymlinkInode{Symlink: "old"},
+		}),
+		Filter:   &filter.Filter{FilterLines: []string{"/tmp/.*", "/var/.*"}},
+		Packages: []image.Package{{Name: "openssl", Version: "1.1.1k"}},
+		Triggers: &triggers.Triggers{Triggers: []*triggers.Trigger{
+			{Service: "sshd", MatchLines: []string{"/etc/ssh/.*"}},
+		}},
+	}
+	right := &image.Image{
+		FileSystem: makeFileSystem(t, map[string]filesystem.GenericInode{
+			"added": &filesystem.RegularInode{
+				Mode: 0644, Size: 7, Hash: makeHash(4)},
+			"changed": &filesystem.RegularInode{
+				Mode: 0644, Size: 200, Hash: makeHash(5)},
+			"chmod": &filesystem.RegularInode{
+				Mode: 0600, Size: 10, Hash: makeHash(2)},
+			"link": &filesystem.SymlinkInode{Symlink: "new"},
+		}),
+		Filter: &filter.Filter{FilterLines: []string{"/tmp/.*", "/run/.*"}},
+		Packages: []image.Package{
+			{Name: "openssl", Version: "1.1.1w"},
+			{Name: "curl", Version: "7.0"},
+		},
+		Triggers: &triggers.Triggers{Triggers: []*triggers.Trigger{
+			{Service: "sshd", MatchLines: []string{"/etc/ssh/.*"}},
+			{Service: "nginx", MatchLines: []string{"/etc/nginx/.*"}},
+		}},
+	}
+	diff := Compute(left, right)
+	if len(diff.Added) != 1 || diff.Added[0].Name != "/added" {
+		t.Errorf("added: %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "/removed" {
+		t.Errorf("removed: %v", diff.Removed)
+	}
+	if len(diff.Changed) != 3 {
+		t.Fatalf("changed: %v", diff.Changed)
+	}
+	if change := diff.Changed[1]; change.Name != "/chmod" ||
+		len(change.Changes) != 1 || change.Changes[0].Attribute != "mode" {
+		t.Errorf("chmod: %v", change)
+	}
+	if diff.AddedBytes != 207 {
+		t.Errorf("added bytes: %d != 207", diff.AddedBytes)
+	}
+	if diff.RemovedBytes != 1100 {
+		t.Errorf("removed bytes: %d != 1100", diff.RemovedBytes)
+	}
+	if diff.NewObjectBytes != 207 {
+		t.Errorf("new object bytes: %d != 207", diff.NewObjectBytes)
+	}
+	if len(diff.Packages) != 2 || diff.Packages[0].Name != "curl" ||
+		diff.Packages[1].LeftVersion != "1.1.1k" ||
+		diff.Packages[1].RightVersion != "1.1.1w" {
+		t.Errorf("packages: %v", diff.Packages)
+	}
+	if len(diff.Filter.AddedLines) != 1 || len(diff.Filter.RemovedLines) != 1 {
+		t.Errorf("filter: %v", diff.Filter)
+	}
+	if len(diff.Triggers) != 1 || diff.Triggers[0].Service != "nginx" ||
+		diff.Triggers[0].Left != nil {
+		t.Errorf("triggers: %v", diff.Triggers)
+	}
+	if diff.IsEmpty() {
+		t.Error("diff is empty")
+	}
+	if !Compute(left, left).IsEmpty() {
+		t.Error("diff of same image is not empty")
+	}
+}
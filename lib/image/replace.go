@@ -9,10 +9,13 @@ func (annotation *Annotation) replaceStrings(replaceFunc func(string) string) {
 func (image *Image) replaceStrings(replaceFunc func(string) string) {
 	image.CreatedBy = replaceFunc(image.CreatedBy)
 	image.Filter.ReplaceStrings(replaceFunc)
-	image.FileSystem.ReplaceStrings(replaceFunc)
+	if image.FileSystem != nil {
+		image.FileSystem.ReplaceStrings(replaceFunc)
+	}
 	image.Triggers.ReplaceStrings(replaceFunc)
 	image.ReleaseNotes.replaceStrings(replaceFunc)
 	image.BuildLog.replaceStrings(replaceFunc)
+	image.TestResults.replaceStrings(replaceFunc)
 	for index := range image.Packages {
 		pkg := &image.Packages[index]
 		pkg.replaceStrings(replaceFunc)
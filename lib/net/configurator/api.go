@@ -10,15 +10,17 @@ import (
 )
 
 type bondedInterfaceType struct {
-	name   string // Physical interface name.
-	ipAddr net.IP
-	subnet *hyper_proto.Subnet
+	name     string // Physical interface name.
+	ipAddr   net.IP
+	ipv6Addr net.IP
+	subnet   *hyper_proto.Subnet
 }
 
 type normalInterfaceType struct {
-	name   string // Physical interface name.
-	ipAddr net.IP
-	subnet *hyper_proto.Subnet
+	name     string // Physical interface name.
+	ipAddr   net.IP
+	ipv6Addr net.IP
+	subnet   *hyper_proto.Subnet
 }
 
 type NetworkConfig struct {
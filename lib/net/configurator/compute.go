@@ -33,23 +33,25 @@ func getNetworkEntries(
 	return networkEntries
 }
 
-func (netconf *NetworkConfig) addBondedInterface(name string, ipAddr net.IP,
-	subnet *hyper_proto.Subnet) {
+func (netconf *NetworkConfig) addBondedInterface(name string,
+	ipAddr, ipv6Addr net.IP, subnet *hyper_proto.Subnet) {
 	netconf.bondedInterfaces = append(netconf.bondedInterfaces,
 		bondedInterfaceType{
-			name:   name,
-			ipAddr: ipAddr,
-			subnet: subnet,
+			name:     name,
+			ipAddr:   ipAddr,
+			ipv6Addr: ipv6Addr,
+			subnet:   subnet,
 		})
 }
 
-func (netconf *NetworkConfig) addNormalInterface(name string, ipAddr net.IP,
-	subnet *hyper_proto.Subnet) {
+func (netconf *NetworkConfig) addNormalInterface(name string,
+	ipAddr, ipv6Addr net.IP, subnet *hyper_proto.Subnet) {
 	netconf.normalInterfaces = append(netconf.normalInterfaces,
 		normalInterfaceType{
-			name:   name,
-			ipAddr: ipAddr,
-			subnet: subnet,
+			name:     name,
+			ipAddr:   ipAddr,
+			ipv6Addr: ipv6Addr,
+			subnet:   subnet,
 		})
 }
 
@@ -96,7 +98,7 @@ func compute(machineInfo fm_proto.GetMachineInfoResponse,
 		usedSubnets[subnet] = struct{}{}
 		normalInterfaceIndex++
 		netconf.addNormalInterface(iface.Name, networkEntry.HostIpAddress,
-			subnet)
+			networkEntry.HostIpv6Address, subnet)
 		delete(interfaces, iface.Name)
 		if subnet == preferredSubnet {
 			netconf.DefaultSubnet = subnet
@@ -120,7 +122,7 @@ func compute(machineInfo fm_proto.GetMachineInfoResponse,
 			usedSubnets[subnet] = struct{}{}
 			entryName := fmt.Sprintf("bond0.%d", subnet.VlanId)
 			netconf.addBondedInterface(entryName, networkEntry.HostIpAddress,
-				subnet)
+				networkEntry.HostIpv6Address, subnet)
 			if subnet == preferredSubnet {
 				netconf.DefaultSubnet = subnet
 			} else if netconf.DefaultSubnet == nil {
@@ -5,12 +5,14 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
 	"github.com/Symantec/Dominator/lib/fsutil"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
 
 func (netconf *NetworkConfig) printDebian(writer io.Writer) error {
@@ -28,6 +30,8 @@ func (netconf *NetworkConfig) printDebian(writer io.Writer) error {
 		if iface.subnet.IpGateway.Equal(netconf.DefaultSubnet.IpGateway) {
 			fmt.Fprintf(writer, "\tgateway %s\n", iface.subnet.IpGateway)
 		}
+		netconf.printDebianInet6(writer, iface.name, iface.ipv6Addr,
+			iface.subnet)
 	}
 	if len(netconf.bondSlaves) > 0 {
 		fmt.Fprintln(writer)
@@ -52,6 +56,8 @@ func (netconf *NetworkConfig) printDebian(writer io.Writer) error {
 		if iface.subnet.IpGateway.Equal(netconf.DefaultSubnet.IpGateway) {
 			fmt.Fprintf(writer, "\tgateway %s\n", iface.subnet.IpGateway)
 		}
+		netconf.printDebianInet6(writer, iface.name, iface.ipv6Addr,
+			iface.subnet)
 	}
 	for _, vlanId := range netconf.bridges {
 		fmt.Fprintln(writer)
@@ -66,6 +72,26 @@ func (netconf *NetworkConfig) printDebian(writer io.Writer) error {
 	return nil
 }
 
+// printDebianInet6 will write the IPv6 configuration for an interface. A
+// static address is used if the machine has one, else the address is
+// configured with SLAAC if the subnet has IPv6.
+func (netconf *NetworkConfig) printDebianInet6(writer io.Writer, name string,
+	ipv6Addr net.IP, subnet *hyper_proto.Subnet) {
+	if len(subnet.Ipv6Gateway) < 1 {
+		return
+	}
+	if len(ipv6Addr) < 1 {
+		fmt.Fprintf(writer, "iface %s inet6 auto\n", name)
+		return
+	}
+	fmt.Fprintf(writer, "iface %s inet6 static\n", name)
+	fmt.Fprintf(writer, "\taddress %s\n", ipv6Addr)
+	fmt.Fprintf(writer, "\tnetmask %d\n", subnet.Ipv6PrefixLength)
+	if subnet.Ipv6Gateway.Equal(netconf.DefaultSubnet.Ipv6Gateway) {
+		fmt.Fprintf(writer, "\tgateway %s\n", subnet.Ipv6Gateway)
+	}
+}
+
 func (netconf *NetworkConfig) updateDebian(rootDir string) (bool, error) {
 	buffer := &bytes.Buffer{}
 	if err := netconf.printDebian(buffer); err != nil {
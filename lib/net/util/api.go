@@ -16,6 +16,20 @@ type ResolverConfiguration struct {
 	SearchDomains []string
 }
 
+// GetEui64Address returns the IPv6 address in the /64 network of prefix with
+// the modified EUI-64 interface identifier derived from a MAC address. This is
+// the address a host configures with Stateless Address Autoconfiguration.
+func GetEui64Address(prefix net.IP, macAddr net.HardwareAddr) (net.IP, error) {
+	return getEui64Address(prefix, macAddr)
+}
+
+// GetEui64MacAddress returns the MAC address from which the interface
+// identifier of an IPv6 address was derived. If the interface identifier is
+// not a modified EUI-64 identifier, nil is returned.
+func GetEui64MacAddress(ipAddr net.IP) net.HardwareAddr {
+	return getEui64MacAddress(ipAddr)
+}
+
 func GetDefaultRoute() (*DefaultRouteInfo, error) {
 	return getDefaultRoute()
 }
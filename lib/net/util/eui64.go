@@ -0,0 +1,37 @@
+package util
+
+import (
+	"errors"
+	"net"
+)
+
+func getEui64Address(prefix net.IP, macAddr net.HardwareAddr) (net.IP, error) {
+	if len(macAddr) != 6 {
+		return nil, errors.New("MAC address must be 6 bytes")
+	}
+	if prefix.To4() != nil || len(prefix) != net.IPv6len {
+		return nil, errors.New("prefix is not an IPv6 address")
+	}
+	ipAddr := make(net.IP, net.IPv6len)
+	copy(ipAddr, prefix[:8])
+	ipAddr[8] = macAddr[0] ^ 0x02 // Flip the universal/local bit.
+	ipAddr[9] = macAddr[1]
+	ipAddr[10] = macAddr[2]
+	ipAddr[11] = 0xff
+	ipAddr[12] = 0xfe
+	ipAddr[13] = macAddr[3]
+	ipAddr[14] = macAddr[4]
+	ipAddr[15] = macAddr[5]
+	return ipAddr, nil
+}
+
+func getEui64MacAddress(ipAddr net.IP) net.HardwareAddr {
+	if ipAddr.To4() != nil || len(ipAddr) != net.IPv6len {
+		return nil
+	}
+	if ipAddr[11] != 0xff || ipAddr[12] != 0xfe {
+		return nil
+	}
+	return net.HardwareAddr{ipAddr[8] ^ 0x02, ipAddr[9], ipAddr[10],
+		ipAddr[13], ipAddr[14], ipAddr[15]}
+}
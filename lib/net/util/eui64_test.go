@@ -0,0 +1,41 @@
+package util
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEui64(t *testing.T) {
+	var tests = []struct {
+		prefix, macAddr, want string
+	}{
+		{"2001:db8::", "52:54:00:12:34:56", "2001:db8::5054:ff:fe12:3456"},
+		{"2001:db8:1:2::1", "02:00:00:00:00:01", "2001:db8:1:2::ff:fe00:1"},
+		{"fe80::", "00:16:3e:aa:bb:cc", "fe80::216:3eff:feaa:bbcc"},
+	}
+	for _, test := range tests {
+		macAddr, err := net.ParseMAC(test.macAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := GetEui64Address(net.ParseIP(test.prefix), macAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(net.ParseIP(test.want)) {
+			t.Errorf("GetEui64Address(%s, %s) = %s, want %s",
+				test.prefix, test.macAddr, got, test.want)
+		}
+		if gotMac := GetEui64MacAddress(got); gotMac.String() != test.macAddr {
+			t.Errorf("GetEui64MacAddress(%s) = %s, want %s",
+				got, gotMac, test.macAddr)
+		}
+	}
+	if _, err := GetEui64Address(net.ParseIP("10.0.0.1"),
+		net.HardwareAddr{0, 1, 2, 3, 4, 5}); err == nil {
+		t.Error("GetEui64Address accepted an IPv4 prefix")
+	}
+	if mac := GetEui64MacAddress(net.ParseIP("2001:db8::1")); mac != nil {
+		t.Errorf("GetEui64MacAddress(2001:db8::1) = %s, want nil", mac)
+	}
+}
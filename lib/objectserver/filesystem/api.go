@@ -101,6 +101,13 @@ func (objSrv *ObjectServer) NumObjects() uint64 {
 	return uint64(len(objSrv.sizesMap))
 }
 
+// QuarantineObject will move an object (typically one which is corrupt) out of
+// the object store and into the quarantine directory, so that it may be
+// replaced. Any previously quarantined copy is overwritten.
+func (objSrv *ObjectServer) QuarantineObject(hashVal hash.Hash) error {
+	return objSrv.quarantineObject(hashVal)
+}
+
 // StashOrVerifyObject will stash an object if it is new or it will verify if it
 // already exists. Object data are read from reader (length bytes are read). The
 // object hash is computed and compared with expectedHash if not nil.
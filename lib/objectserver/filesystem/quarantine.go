@@ -0,0 +1,32 @@
+package filesystem
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+)
+
+var quarantineDirectory string = ".quarantine"
+
+func (objSrv *ObjectServer) quarantineObject(hashVal hash.Hash) error {
+	hashName := objectcache.HashToFilename(hashVal)
+	filename := path.Join(objSrv.baseDir, hashName)
+	quarantineFilename := path.Join(objSrv.baseDir, quarantineDirectory,
+		hashName)
+	err := os.MkdirAll(path.Dir(quarantineFilename), syscall.S_IRWXU)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(filename, quarantineFilename); err != nil {
+		return err
+	}
+	objSrv.rwLock.Lock()
+	delete(objSrv.sizesMap, hashVal)
+	objSrv.lastMutationTime = time.Now()
+	objSrv.rwLock.Unlock()
+	return nil
+}
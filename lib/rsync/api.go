@@ -23,6 +23,12 @@ type Stats struct {
 	NumWritten uint64
 }
 
+// ComputeBlockOrder returns the block order (log2 of the block size) used when
+// comparing blocks of data of the specified length.
+func ComputeBlockOrder(totalBytes uint64) uint8 {
+	return computeBlockOrder(totalBytes)
+}
+
 func GetBlocks(conn Conn, decoder Decoder, encoder Encoder, reader io.Reader,
 	writer io.WriteSeeker, totalBytes, readerBytes uint64) (Stats, error) {
 	return getBlocks(conn, decoder, encoder, reader, writer, totalBytes,
@@ -17,12 +17,7 @@ type measuringConn struct {
 
 func getBlocks(rawConn Conn, decoder Decoder, encoder Encoder, reader io.Reader,
 	writer io.WriteSeeker, totalBytes, readerBytes uint64) (Stats, error) {
-	blockOrder := sizeToOrder(totalBytes) >> 1
-	if blockOrder < 9 {
-		blockOrder = 9
-	} else if blockOrder > 32 {
-		blockOrder = 32
-	}
+	blockOrder := computeBlockOrder(totalBytes)
 	blockSize := uint64(1 << blockOrder)
 	if reader == nil {
 		readerBytes = 0
@@ -74,6 +69,16 @@ func getBlocks(rawConn Conn, decoder Decoder, encoder Encoder, reader io.Reader,
 	return conn.stats, nil
 }
 
+func computeBlockOrder(totalBytes uint64) uint8 {
+	blockOrder := sizeToOrder(totalBytes) >> 1
+	if blockOrder < 9 {
+		blockOrder = 9
+	} else if blockOrder > 32 {
+		blockOrder = 32
+	}
+	return blockOrder
+}
+
 func readBlocks(writer io.WriteSeeker, decoder Decoder, reader io.Reader,
 	blockOrder uint8) error {
 	var numBytesReceived uint64
@@ -56,6 +56,14 @@ func (driver *SlaveDriver) GetSlave() (*Slave, error) {
 	return driver.getSlave()
 }
 
+// GetSlaveWithAffinity will return an idle slave, preferring the slave for
+// which affinityFunc returns the highest score. If there are no idle slaves, a
+// new slave is created.
+func (driver *SlaveDriver) GetSlaveWithAffinity(
+	affinityFunc func(*Slave) uint) (*Slave, error) {
+	return driver.getSlaveWithAffinity(affinityFunc)
+}
+
 func (driver *SlaveDriver) WriteHtml(writer io.Writer) {
 	driver.writeHtml(writer)
 }
@@ -99,20 +99,37 @@ func (driver *SlaveDriver) createSlave() (*Slave, error) {
 	}
 }
 
-func (driver *SlaveDriver) getIdleSlave() *Slave {
+func (driver *SlaveDriver) getIdleSlave(affinityFunc func(*Slave) uint) *Slave {
 	driver.mutex.Lock()
 	defer driver.mutex.Unlock()
+	var bestSlave *Slave
+	var bestScore uint
 	for slave := range driver.idleSlaves {
-		driver.busySlaves[slave] = struct{}{}
-		delete(driver.idleSlaves, slave)
-		driver.scheduleRollCall()
-		return slave
+		if affinityFunc == nil {
+			bestSlave = slave
+			break
+		}
+		if score := affinityFunc(slave); bestSlave == nil || score > bestScore {
+			bestSlave = slave
+			bestScore = score
+		}
 	}
-	return nil
+	if bestSlave == nil {
+		return nil
+	}
+	driver.busySlaves[bestSlave] = struct{}{}
+	delete(driver.idleSlaves, bestSlave)
+	driver.scheduleRollCall()
+	return bestSlave
 }
 
 func (driver *SlaveDriver) getSlave() (*Slave, error) {
-	if slave := driver.getIdleSlave(); slave != nil {
+	return driver.getSlaveWithAffinity(nil)
+}
+
+func (driver *SlaveDriver) getSlaveWithAffinity(
+	affinityFunc func(*Slave) uint) (*Slave, error) {
+	if slave := driver.getIdleSlave(affinityFunc); slave != nil {
 		return slave, nil
 	}
 	driver.logger.Debugln(0, "creating slave")
@@ -0,0 +1,52 @@
+/*
+Package vmidentity signs and verifies VM identity documents.
+
+A Hypervisor signs the identity document for each of its VMs with its own
+Ed25519 key and the Fleet Manager publishes the public keys of the
+Hypervisors. A VM may fetch a signed identity document from the metadata
+server and present it to a third party, which can verify it using the
+published keys without any shared secrets.
+*/
+package vmidentity
+
+import (
+	"crypto/ed25519"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// GetTrustedKeys will get the public keys of the Hypervisors from the Fleet
+// Manager. The keys are keyed by key ID.
+func GetTrustedKeys(client *srpc.Client) (map[string]ed25519.PublicKey,
+	error) {
+	return getTrustedKeys(client)
+}
+
+// KeyId returns the ID of a public key.
+func KeyId(publicKey ed25519.PublicKey) string {
+	return keyId(publicKey)
+}
+
+// LoadOrGenerateKey will load a private key from a file. If the file does not
+// exist a new key is generated and written to the file.
+func LoadOrGenerateKey(filename string) (ed25519.PrivateKey, error) {
+	return loadOrGenerateKey(filename)
+}
+
+// Sign will sign an identity document. If the document time is zero, the
+// current time is used.
+func Sign(document proto.IdentityDocument,
+	privateKey ed25519.PrivateKey) (*proto.SignedIdentityDocument, error) {
+	return sign(document, privateKey)
+}
+
+// Verify will verify that a signed identity document was signed by one of the
+// trusted keys (keyed by key ID) and, if maxAge is non-zero, that it was
+// signed no more than maxAge ago. The identity document is returned.
+func Verify(signed proto.SignedIdentityDocument,
+	trustedKeys map[string]ed25519.PublicKey,
+	maxAge time.Duration) (*proto.IdentityDocument, error) {
+	return verify(signed, trustedKeys, maxAge)
+}
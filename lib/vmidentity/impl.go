@@ -0,0 +1,120 @@
+package vmidentity
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/srpc"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	pemType   = "ED25519 PRIVATE KEY"
+	filePerms = 0600
+)
+
+func getTrustedKeys(client *srpc.Client) (map[string]ed25519.PublicKey,
+	error) {
+	var reply fm_proto.GetIdentityKeysResponse
+	err := client.RequestReply("FleetManager.GetIdentityKeys",
+		fm_proto.GetIdentityKeysRequest{}, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Error != "" {
+		return nil, errors.New(reply.Error)
+	}
+	keys := make(map[string]ed25519.PublicKey, len(reply.Keys))
+	for _, key := range reply.Keys {
+		if len(key.PublicKey) != ed25519.PublicKeySize {
+			continue
+		}
+		publicKey := ed25519.PublicKey(key.PublicKey)
+		keys[keyId(publicKey)] = publicKey
+	}
+	return keys, nil
+}
+
+func keyId(publicKey ed25519.PublicKey) string {
+	checksum := sha256.Sum256(publicKey)
+	return fmt.Sprintf("%x", checksum[:16])
+}
+
+func loadOrGenerateKey(filename string) (ed25519.PrivateKey, error) {
+	if data, err := ioutil.ReadFile(filename); err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != pemType {
+			return nil, errors.New("no private key in: " + filename)
+		}
+		if len(block.Bytes) != ed25519.SeedSize {
+			return nil, errors.New("bad private key in: " + filename)
+		}
+		return ed25519.NewKeyFromSeed(block.Bytes), nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{
+		Type:  pemType,
+		Bytes: privateKey.Seed(),
+	})
+	err = fsutil.CopyToFile(filename, filePerms, bytes.NewReader(data), 0)
+	if err != nil {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+func sign(document proto.IdentityDocument,
+	privateKey ed25519.PrivateKey) (*proto.SignedIdentityDocument, error) {
+	if document.Time.IsZero() {
+		document.Time = time.Now()
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.SignedIdentityDocument{
+		Document:  data,
+		KeyId:     keyId(privateKey.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(privateKey, data),
+	}, nil
+}
+
+func verify(signed proto.SignedIdentityDocument,
+	trustedKeys map[string]ed25519.PublicKey,
+	maxAge time.Duration) (*proto.IdentityDocument, error) {
+	publicKey, ok := trustedKeys[signed.KeyId]
+	if !ok {
+		return nil, errors.New("unknown key: " + signed.KeyId)
+	}
+	if !ed25519.Verify(publicKey, signed.Document, signed.Signature) {
+		return nil, errors.New("bad signature")
+	}
+	var document proto.IdentityDocument
+	if err := json.Unmarshal(signed.Document, &document); err != nil {
+		return nil, err
+	}
+	if maxAge > 0 {
+		if age := time.Since(document.Time); age > maxAge {
+			return nil, fmt.Errorf("document is too old: %s", age)
+		} else if age < -maxAge {
+			return nil, fmt.Errorf("document is in the future: %s", -age)
+		}
+	}
+	return &document, nil
+}
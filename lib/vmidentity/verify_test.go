@@ -0,0 +1,66 @@
+package vmidentity
+
+import (
+	"crypto/ed25519"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestLoadOrGenerateKey(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "key")
+	key, err := LoadOrGenerateKey(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedKey, err := LoadOrGenerateKey(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Equal(loadedKey) {
+		t.Fatal("loaded key differs from generated key")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := Sign(proto.IdentityDocument{
+		Hypervisor: "hypervisor0",
+		IpAddress:  net.ParseIP("10.0.0.1"),
+	}, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedKeys := map[string]ed25519.PublicKey{KeyId(publicKey): publicKey}
+	document, err := Verify(*signed, trustedKeys, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !document.IpAddress.Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("wrong IP address: %s", document.IpAddress)
+	}
+	tampered := *signed
+	tampered.Document = append([]byte(nil), signed.Document...)
+	tampered.Document[len(tampered.Document)-2] ^= 1
+	if _, err := Verify(tampered, trustedKeys, 0); err == nil {
+		t.Fatal("tampered document verified")
+	}
+	if _, err := Verify(*signed, nil, 0); err == nil {
+		t.Fatal("document verified with no trusted keys")
+	}
+	oldSigned, err := Sign(proto.IdentityDocument{
+		Time: time.Now().Add(-time.Hour),
+	}, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(*oldSigned, trustedKeys, time.Minute); err == nil {
+		t.Fatal("old document verified")
+	}
+}
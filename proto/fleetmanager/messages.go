@@ -16,6 +16,37 @@ type ChangeMachineTagsResponse struct {
 	Error string
 }
 
+// CheckVMPlacementRequest is used to check if placing a VM on a specific
+// Hypervisor would violate the placement groups of the VM. The PlacementGroups
+// field of VmInfo is used. If VmInfo.Address.IpAddress is set, the VM is
+// ignored when checking placement groups.
+type CheckVMPlacementRequest struct {
+	HypervisorHostname string
+	VmInfo             proto.VmInfo
+}
+
+type CheckVMPlacementResponse struct {
+	Error    string   // Set if a strict placement group is violated.
+	Warnings []string `json:",omitempty"` // Other violations.
+}
+
+// The DrainHypervisor() RPC is fully streamed.
+// The client sends a single DrainHypervisorRequest message.
+// The server sends a stream of DrainHypervisorResponse messages until Final or
+// Error is set.
+
+type DrainHypervisorRequest struct {
+	Hostname string
+	Location string // Where to migrate VMs to. Empty means anywhere.
+}
+
+type DrainHypervisorResponse struct {
+	Error           string            `json:",omitempty"`
+	Final           bool              `json:",omitempty"`
+	ProgressMessage string            `json:",omitempty"`
+	VmFailures      map[string]string `json:",omitempty"` // Key: IPaddr.
+}
+
 type GetHypervisorForVMRequest struct {
 	IpAddress net.IP
 }
@@ -25,6 +56,13 @@ type GetHypervisorForVMResponse struct {
 	Error             string
 }
 
+type GetIdentityKeysRequest struct{}
+
+type GetIdentityKeysResponse struct {
+	Error string
+	Keys  []IdentityKey
+}
+
 type GetMachineInfoRequest struct {
 	Hostname string
 }
@@ -55,6 +93,13 @@ type Update struct {
 
 type HardwareAddr net.HardwareAddr
 
+// IdentityKey is the public key a Hypervisor signs VM identity documents with.
+type IdentityKey struct {
+	Hypervisor string // Hostname.
+	KeyId      string
+	PublicKey  []byte // Ed25519.
+}
+
 type ListHypervisorLocationsRequest struct {
 	TopLocation string
 }
@@ -105,7 +150,35 @@ type MoveIpAddressesResponse struct {
 }
 
 type NetworkEntry struct {
-	Hostname       string       `json:",omitempty"`
-	HostIpAddress  net.IP       `json:",omitempty"`
-	HostMacAddress HardwareAddr `json:",omitempty"`
+	Hostname        string       `json:",omitempty"`
+	HostIpAddress   net.IP       `json:",omitempty"`
+	HostIpv6Address net.IP       `json:",omitempty"`
+	HostMacAddress  HardwareAddr `json:",omitempty"`
+}
+
+// SelectHypervisorsForVMRequest is used to find Hypervisors on which a VM
+// may be placed. The MemoryInMiB, MilliCPUs, PlacementGroups, SubnetId,
+// SecondarySubnetIDs, Volumes, SpreadVolumes and Tags fields of VmInfo are
+// used. If VmInfo.Address.IpAddress is set, the Hypervisor with that VM is
+// rejected and the VM is ignored when checking placement groups.
+type SelectHypervisorsForVMRequest struct {
+	AntiAffinityTags []string // Reject Hypervisors with VMs with same values.
+	Location         string
+	SpreadTags       []string // Prefer Hypervisors with fewer matching VMs.
+	VmInfo           proto.VmInfo
+}
+
+type SelectHypervisorsForVMResponse struct {
+	Error               string            `json:",omitempty"`
+	HypervisorAddresses []string          `json:",omitempty"` // Best first.
+	Rejected            map[string]string `json:",omitempty"` // Key: address.
+	Warnings            map[string]string `json:",omitempty"` // Key: address.
+}
+
+type UndrainHypervisorRequest struct {
+	Hostname string
+}
+
+type UndrainHypervisorResponse struct {
+	Error string
 }
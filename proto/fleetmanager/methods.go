@@ -81,6 +81,9 @@ func (left *NetworkEntry) Equal(right *NetworkEntry) bool {
 	if !left.HostIpAddress.Equal(right.HostIpAddress) {
 		return false
 	}
+	if !left.HostIpv6Address.Equal(right.HostIpv6Address) {
+		return false
+	}
 	if left.HostMacAddress.String() != right.HostMacAddress.String() {
 		return false
 	}
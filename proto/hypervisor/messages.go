@@ -4,14 +4,23 @@ import (
 	"net"
 	"time"
 
+	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/tags"
 )
 
 const (
+	CloudInitDatasourceNone        = 0
+	CloudInitDatasourceNoCloud     = 1
+	CloudInitDatasourceConfigDrive = 2
+
 	ConsoleNone  = 0
 	ConsoleDummy = 1
 	ConsoleVNC   = 2
 
+	PlacementPolicySpreadHypervisors = 0
+	PlacementPolicySpreadRacks       = 1
+	PlacementPolicyPack              = 2
+
 	StateStarting      = 0
 	StateRunning       = 1
 	StateFailedToStart = 2
@@ -34,8 +43,32 @@ type AcknowledgeVmResponse struct {
 }
 
 type Address struct {
-	IpAddress  net.IP `json:",omitempty"`
-	MacAddress string
+	IpAddress   net.IP `json:",omitempty"`
+	Ipv6Address net.IP `json:",omitempty"` // Derived from MacAddress.
+	MacAddress  string
+}
+
+type BackupStatus struct {
+	Error        string    `json:",omitempty"` // From the last attempt.
+	Hash         hash.Hash // Of the VmBackup object of the last backup.
+	ImageName    string    `json:",omitempty"`
+	InProgress   bool      `json:",omitempty"`
+	ObjectServer string    `json:",omitempty"`
+	SnapshotName string    `json:",omitempty"`
+	Time         time.Time // Of the snapshot in the last successful backup.
+}
+
+type BackupVmRequest struct {
+	ForceIfNotStopped   bool
+	ImageName           string // If not empty, add backup to an imageserver.
+	IpAddress           net.IP
+	ObjectServerAddress string // If empty, the default backup server is used.
+	SnapshotName        string // If empty, a "backup" snapshot is taken.
+}
+
+type BackupVmResponse struct {
+	Error string
+	Hash  hash.Hash // Of the VmBackup object.
 }
 
 type BecomePrimaryVmOwnerRequest struct {
@@ -83,6 +116,17 @@ type ChangeVmDestroyProtectionResponse struct {
 	Error string
 }
 
+type ChangeVmFirewallPolicyRequest struct {
+	DryRun         bool // If true, only compute the effective ruleset.
+	FirewallPolicy FirewallPolicy
+	IpAddress      net.IP
+}
+
+type ChangeVmFirewallPolicyResponse struct {
+	Error   string
+	Ruleset string // Effective nftables ruleset.
+}
+
 type ChangeVmOwnerUsersRequest struct {
 	IpAddress  net.IP
 	OwnerUsers []string
@@ -92,6 +136,25 @@ type ChangeVmOwnerUsersResponse struct {
 	Error string
 }
 
+type ChangeVmSizeRequest struct {
+	IpAddress   net.IP
+	MemoryInMiB uint64 // Zero means no change.
+	MilliCPUs   uint   // Zero means no change.
+}
+
+type ChangeVmSizeResponse struct {
+	Error string
+}
+
+type ChangeVmSnapshotScheduleRequest struct {
+	IpAddress        net.IP
+	SnapshotSchedule SnapshotSchedule
+}
+
+type ChangeVmSnapshotScheduleResponse struct {
+	Error string
+}
+
 type ChangeVmTagsRequest struct {
 	IpAddress net.IP
 	Tags      tags.Tags
@@ -101,6 +164,10 @@ type ChangeVmTagsResponse struct {
 	Error string
 }
 
+// CloudInitDatasource specifies the cloud-init seed volume (if any) that is
+// generated for a VM when it is started.
+type CloudInitDatasource uint
+
 type CommitImportedVmRequest struct {
 	IpAddress net.IP
 }
@@ -120,6 +187,20 @@ type ConnectToVmConsoleResponse struct {
 	Error string
 }
 
+// The ConnectToVmMigrationStream RPC is fully streamed. It is used by a
+// destination hypervisor to connect one of the data streams of a live
+// migration to the source hypervisor. After the request/response, the
+// connection/client is hijacked and carries the stream data.
+type ConnectToVmMigrationStreamRequest struct {
+	AccessToken []byte
+	IpAddress   net.IP
+	StreamName  string
+}
+
+type ConnectToVmMigrationStreamResponse struct {
+	Error string
+}
+
 // The ConnectToVmSerialPort RPC is fully streamed. After the request/response,
 // the connection/client is hijacked and each side of the connection will send
 // a stream of bytes.
@@ -214,7 +295,8 @@ type DiscardVmOldUserDataResponse struct {
 }
 
 type DiscardVmSnapshotRequest struct {
-	IpAddress net.IP
+	IpAddress    net.IP
+	SnapshotName string
 }
 
 type DiscardVmSnapshotResponse struct {
@@ -236,6 +318,27 @@ type ExportLocalVmResponse struct {
 	VmInfo ExportLocalVmInfo
 }
 
+// FirewallPolicy specifies the traffic which may pass to (ingress) and from
+// (egress) a VM. If filtering is enabled for a direction, only traffic which
+// matches one of the rules for that direction (or which is a reply to permitted
+// traffic) may pass. Traffic between a VM and its Hypervisor is not filtered.
+type FirewallPolicy struct {
+	EgressRules   []FirewallRule `json:",omitempty"`
+	FilterEgress  bool           `json:",omitempty"`
+	FilterIngress bool           `json:",omitempty"`
+	IngressRules  []FirewallRule `json:",omitempty"`
+}
+
+// FirewallRule permits traffic for a protocol (all protocols if empty) to a
+// range of destination ports (all ports if FromPort is zero) from (ingress) or
+// to (egress) a remote network (anywhere if empty).
+type FirewallRule struct {
+	FromPort      uint16 `json:",omitempty"`
+	Protocol      string `json:",omitempty"` // "icmp", "tcp" or "udp".
+	RemoteNetwork string `json:",omitempty"` // CIDR notation.
+	ToPort        uint16 `json:",omitempty"` // Zero: same as FromPort.
+}
+
 // The GetUpdates() RPC is fully streamed.
 // The client may or may not send GetUpdateRequest messages to the server.
 // The server sends a stream of Update messages.
@@ -243,16 +346,18 @@ type ExportLocalVmResponse struct {
 type GetUpdateRequest struct{}
 
 type Update struct {
-	HaveAddressPool  bool               `json:",omitempty"`
-	AddressPool      []Address          `json:",omitempty"` // Used & free.
-	NumFreeAddresses map[string]uint    `json:",omitempty"` // Key: subnet ID.
-	HealthStatus     string             `json:",omitempty"`
-	HaveSerialNumber bool               `json:",omitempty"`
-	SerialNumber     string             `json:",omitempty"`
-	HaveSubnets      bool               `json:",omitempty"`
-	Subnets          []Subnet           `json:",omitempty"`
-	HaveVMs          bool               `json:",omitempty"`
-	VMs              map[string]*VmInfo `json:",omitempty"` // Key: IP address.
+	HaveAddressPool   bool               `json:",omitempty"`
+	AddressPool       []Address          `json:",omitempty"` // Used & free.
+	NumFreeAddresses  map[string]uint    `json:",omitempty"` // Key: subnet ID.
+	HealthStatus      string             `json:",omitempty"`
+	IdentityPublicKey []byte             `json:",omitempty"` // Ed25519.
+	HaveSerialNumber  bool               `json:",omitempty"`
+	SerialNumber      string             `json:",omitempty"`
+	HaveSubnets       bool               `json:",omitempty"`
+	Subnets           []Subnet           `json:",omitempty"`
+	HaveVMs           bool               `json:",omitempty"`
+	VMs               map[string]*VmInfo `json:",omitempty"` // Key: IP address.
+	Resources         *Resources         `json:",omitempty"`
 }
 
 type GetVmAccessTokenRequest struct {
@@ -265,6 +370,15 @@ type GetVmAccessTokenResponse struct {
 	Error string
 }
 
+type GetVmFirewallRulesetRequest struct {
+	IpAddress net.IP
+}
+
+type GetVmFirewallRulesetResponse struct {
+	Error   string
+	Ruleset string // Effective nftables ruleset.
+}
+
 type GetVmInfoRequest struct {
 	IpAddress net.IP
 }
@@ -296,6 +410,29 @@ type GetVmVolumeResponse struct {
 	Error string
 }
 
+type GrowVmVolumeRequest struct {
+	IpAddress   net.IP
+	Size        uint64 // New size in bytes.
+	VolumeIndex uint
+}
+
+type GrowVmVolumeResponse struct {
+	Error string
+}
+
+// IdentityDocument describes a VM. It is signed by the Hypervisor hosting the
+// VM so that the VM can prove its identity to third parties.
+type IdentityDocument struct {
+	Hostname    string    `json:",omitempty"`
+	Hypervisor  string    // Hostname of the Hypervisor which signed it.
+	ImageName   string    `json:",omitempty"`
+	IpAddress   net.IP    // Primary address.
+	OwnerGroups []string  `json:",omitempty"`
+	OwnerUsers  []string  `json:",omitempty"`
+	Tags        tags.Tags `json:",omitempty"`
+	Time        time.Time // When it was signed.
+}
+
 type ImportLocalVmRequest struct {
 	VerificationCookie []byte `json:",omitempty"`
 	VmInfo
@@ -336,6 +473,9 @@ type MigrateVmRequest struct {
 	AccessToken      []byte
 	DhcpTimeout      time.Duration
 	IpAddress        net.IP
+	MaximumDowntime  time.Duration // Live migration. Default: 300ms.
+	MigrationTimeout time.Duration // Live migration. Default: 10m.
+	Offline          bool          // If true, stop VM while migrating.
 	SourceHypervisor string
 }
 
@@ -377,6 +517,21 @@ type PatchVmImageResponse struct { // Multiple responses are sent.
 	Error           string
 }
 
+// PlacementGroup is a named group of VMs which the Fleet Manager places
+// according to the policy. VMs are in the same group if they have the same
+// group name. If Strict is true, placements which violate the policy are
+// refused, otherwise they are permitted with a warning.
+type PlacementGroup struct {
+	Name   string
+	Policy PlacementPolicy
+	Strict bool `json:",omitempty"`
+}
+
+// PlacementPolicy specifies how the VMs in a PlacementGroup are placed:
+// no two VMs on the same Hypervisor, no two VMs in the same rack (the
+// location of the Hypervisor in the topology), or all VMs on one Hypervisor.
+type PlacementPolicy uint
+
 type PrepareVmForMigrationRequest struct {
 	AccessToken []byte
 	Enable      bool
@@ -426,9 +581,34 @@ type ReplaceVmUserDataResponse struct {
 	Error string
 }
 
+// Resources describes the capacity of a Hypervisor and how much of it is
+// available for new VMs.
+type Resources struct {
+	AvailableMemoryInMiB uint64
+	AvailableMilliCPUs   uint
+	FreeVolumeSpace      []uint64 // Bytes, for each volume directory.
+	TotalMemoryInMiB     uint64
+	TotalMilliCPUs       uint
+}
+
+type RestoreVmFromBackupRequest struct {
+	Hash                hash.Hash // Of the VmBackup object, if no ImageName.
+	ImageName           string
+	ObjectServerAddress string // If empty, the default backup server is used.
+	VmInfo
+} // The volumes are taken from the backup.
+
+type RestoreVmFromBackupResponse struct { // Multiple responses are sent.
+	Error           string
+	Final           bool // If true, this is the final response.
+	IpAddress       net.IP
+	ProgressMessage string
+}
+
 type RestoreVmFromSnapshotRequest struct {
 	IpAddress         net.IP
 	ForceIfNotStopped bool
+	SnapshotName      string
 }
 
 type RestoreVmFromSnapshotResponse struct {
@@ -451,10 +631,50 @@ type RestoreVmUserDataResponse struct {
 	Error string
 }
 
+// The SendVmLiveMigration RPC is used by a destination hypervisor to make
+// the source hypervisor send the memory and volumes of a running VM.
+type SendVmLiveMigrationRequest struct {
+	AccessToken      []byte
+	IpAddress        net.IP
+	MaximumDowntime  time.Duration
+	MigrationTimeout time.Duration
+}
+
+type SendVmLiveMigrationResponse struct { // Multiple responses are sent.
+	Error           string
+	Final           bool // If true, this is the final response.
+	Paused          bool // If true, all data are sent: awaiting commit.
+	ProgressMessage string
+}
+
+type SendVmLiveMigrationResponseResponse struct {
+	Commit bool
+}
+
+type SignedIdentityDocument struct {
+	Document  []byte // JSON encoding of an IdentityDocument.
+	KeyId     string // Of the public key to verify the signature with.
+	Signature []byte // Ed25519 signature of Document.
+}
+
+type Snapshot struct {
+	Name     string
+	RootOnly bool `json:",omitempty"`
+	Time     time.Time
+}
+
+type SnapshotSchedule struct {
+	Backup               bool          `json:",omitempty"`
+	BackupImageDirectory string        `json:",omitempty"`
+	Interval             time.Duration `json:",omitempty"` // Zero: disabled.
+	NumToRetain          uint          `json:",omitempty"`
+}
+
 type SnapshotVmRequest struct {
 	IpAddress         net.IP
 	ForceIfNotStopped bool
 	RootOnly          bool
+	SnapshotName      string
 }
 
 type SnapshotVmResponse struct {
@@ -462,9 +682,10 @@ type SnapshotVmResponse struct {
 }
 
 type StartVmRequest struct {
-	AccessToken []byte
-	DhcpTimeout time.Duration
-	IpAddress   net.IP
+	AccessToken         []byte
+	DhcpTimeout         time.Duration
+	EnableMemoryBalloon bool // Add the balloon device if the VM lacks it.
+	IpAddress           net.IP
 }
 
 type StartVmResponse struct {
@@ -489,10 +710,13 @@ type Subnet struct {
 	IpMask            net.IP // net.IPMask can't be JSON {en,de}coded.
 	DomainName        string `json:",omitempty"`
 	DomainNameServers []net.IP
-	Manage            bool     `json:",omitempty"`
-	VlanId            uint     `json:",omitempty"`
-	AllowedGroups     []string `json:",omitempty"`
-	AllowedUsers      []string `json:",omitempty"`
+	FirewallPolicy    *FirewallPolicy `json:",omitempty"`
+	Ipv6Gateway       net.IP          `json:",omitempty"`
+	Ipv6PrefixLength  uint            `json:",omitempty"` // At most 64.
+	Manage            bool            `json:",omitempty"`
+	VlanId            uint            `json:",omitempty"`
+	AllowedGroups     []string        `json:",omitempty"`
+	AllowedUsers      []string        `json:",omitempty"`
 }
 
 type TraceVmMetadataRequest struct {
@@ -514,25 +738,40 @@ type UpdateSubnetsResponse struct {
 }
 
 type VmInfo struct {
-	Address            Address
-	ConsoleType        ConsoleType `json:",omitempty"`
-	DestroyProtection  bool        `json:",omitempty"`
-	DisableVirtIO      bool        `json:",omitempty"`
-	Hostname           string      `json:",omitempty"`
-	ImageName          string      `json:",omitempty"`
-	ImageURL           string      `json:",omitempty"`
-	MemoryInMiB        uint64
-	MilliCPUs          uint
-	OwnerGroups        []string `json:",omitempty"`
-	OwnerUsers         []string `json:",omitempty"`
-	SpreadVolumes      bool     `json:",omitempty"`
-	State              State
-	Tags               tags.Tags `json:",omitempty"`
-	SecondaryAddresses []Address `json:",omitempty"`
-	SecondarySubnetIDs []string  `json:",omitempty"`
-	SubnetId           string    `json:",omitempty"`
-	Uncommitted        bool      `json:",omitempty"`
-	Volumes            []Volume  `json:",omitempty"`
+	Address             Address
+	Backup              *BackupStatus       `json:",omitempty"`
+	CloudInitDatasource CloudInitDatasource `json:",omitempty"`
+	ConsoleType         ConsoleType         `json:",omitempty"`
+	DestroyProtection   bool                `json:",omitempty"`
+	DisableVirtIO       bool                `json:",omitempty"`
+	FirewallPolicy      *FirewallPolicy     `json:",omitempty"`
+	Hostname            string              `json:",omitempty"`
+	ImageName           string              `json:",omitempty"`
+	ImageURL            string              `json:",omitempty"`
+	MemoryBalloon       bool                `json:",omitempty"`
+	MemoryInMiB         uint64
+	MilliCPUs           uint
+	OwnerGroups         []string         `json:",omitempty"`
+	OwnerUsers          []string         `json:",omitempty"`
+	PlacementGroups     []PlacementGroup `json:",omitempty"`
+	SpreadVolumes       bool             `json:",omitempty"`
+	State               State
+	Tags                tags.Tags         `json:",omitempty"`
+	SecondaryAddresses  []Address         `json:",omitempty"`
+	SecondarySubnetIDs  []string          `json:",omitempty"`
+	SnapshotSchedule    *SnapshotSchedule `json:",omitempty"`
+	Snapshots           []Snapshot        `json:",omitempty"`
+	SshPublicKeys       []string          `json:",omitempty"`
+	SubnetId            string            `json:",omitempty"`
+	Uncommitted         bool              `json:",omitempty"`
+	Volumes             []Volume          `json:",omitempty"`
+}
+
+type VmBackup struct { // Stored in the object server.
+	Time     time.Time
+	UserData *hash.Hash // If nil, there is no user data.
+	VmInfo   VmInfo
+	Volumes  []VolumeBackup
 }
 
 type Volume struct {
@@ -540,4 +779,10 @@ type Volume struct {
 	Format VolumeFormat
 }
 
+type VolumeBackup struct {
+	BlockOrder uint8 // Log2 of the block size.
+	Blocks     []hash.Hash
+	Size       uint64
+}
+
 type VolumeFormat uint
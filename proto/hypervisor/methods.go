@@ -2,14 +2,24 @@ package hypervisor
 
 import (
 	"errors"
+	"fmt"
 	"net"
 )
 
+const cloudInitDatasourceUnknown = "UNKNOWN CloudInitDatasource"
 const consoleTypeUnknown = "UNKNOWN ConsoleType"
+const placementPolicyUnknown = "UNKNOWN PlacementPolicy"
 const stateUnknown = "UNKNOWN State"
 const volumeFormatUnknown = "UNKNOWN VolumeFormat"
 
 var (
+	cloudInitDatasourceToText = map[CloudInitDatasource]string{
+		CloudInitDatasourceNone:        "none",
+		CloudInitDatasourceNoCloud:     "NoCloud",
+		CloudInitDatasourceConfigDrive: "ConfigDrive",
+	}
+	textToCloudInitDatasource map[string]CloudInitDatasource
+
 	consoleTypeToText = map[ConsoleType]string{
 		ConsoleNone:  "none",
 		ConsoleDummy: "dummy",
@@ -17,6 +27,13 @@ var (
 	}
 	textToConsoleType map[string]ConsoleType
 
+	placementPolicyToText = map[PlacementPolicy]string{
+		PlacementPolicySpreadHypervisors: "spreadHypervisors",
+		PlacementPolicySpreadRacks:       "spreadRacks",
+		PlacementPolicyPack:              "pack",
+	}
+	textToPlacementPolicy map[string]PlacementPolicy
+
 	stateToText = map[State]string{
 		StateStarting:      "starting",
 		StateRunning:       "running",
@@ -37,10 +54,20 @@ var (
 )
 
 func init() {
+	textToCloudInitDatasource = make(map[string]CloudInitDatasource,
+		len(cloudInitDatasourceToText))
+	for datasource, text := range cloudInitDatasourceToText {
+		textToCloudInitDatasource[text] = datasource
+	}
 	textToConsoleType = make(map[string]ConsoleType, len(consoleTypeToText))
 	for consoleType, text := range consoleTypeToText {
 		textToConsoleType[text] = consoleType
 	}
+	textToPlacementPolicy = make(map[string]PlacementPolicy,
+		len(placementPolicyToText))
+	for policy, text := range placementPolicyToText {
+		textToPlacementPolicy[text] = policy
+	}
 	textToState = make(map[string]State, len(stateToText))
 	for state, text := range stateToText {
 		textToState[text] = state
@@ -70,6 +97,9 @@ func (left *Address) Equal(right *Address) bool {
 	if !left.IpAddress.Equal(right.IpAddress) {
 		return false
 	}
+	if !left.Ipv6Address.Equal(right.Ipv6Address) {
+		return false
+	}
 	if left.MacAddress != right.MacAddress {
 		return false
 	}
@@ -92,6 +122,74 @@ func stringSlicesEqual(left, right []string) bool {
 	return true
 }
 
+func (left *BackupStatus) Equal(right *BackupStatus) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+	if left.Error != right.Error {
+		return false
+	}
+	if left.Hash != right.Hash {
+		return false
+	}
+	if left.ImageName != right.ImageName {
+		return false
+	}
+	if left.InProgress != right.InProgress {
+		return false
+	}
+	if left.ObjectServer != right.ObjectServer {
+		return false
+	}
+	if left.SnapshotName != right.SnapshotName {
+		return false
+	}
+	return left.Time.Equal(right.Time)
+}
+
+func (datasource *CloudInitDatasource) CheckValid() error {
+	if _, ok := cloudInitDatasourceToText[*datasource]; !ok {
+		return errors.New(cloudInitDatasourceUnknown)
+	} else {
+		return nil
+	}
+}
+
+func (datasource CloudInitDatasource) MarshalText() ([]byte, error) {
+	if text := datasource.String(); text == cloudInitDatasourceUnknown {
+		return nil, errors.New(text)
+	} else {
+		return []byte(text), nil
+	}
+}
+
+func (datasource *CloudInitDatasource) Set(value string) error {
+	if val, ok := textToCloudInitDatasource[value]; !ok {
+		return errors.New(cloudInitDatasourceUnknown)
+	} else {
+		*datasource = val
+		return nil
+	}
+}
+
+func (datasource CloudInitDatasource) String() string {
+	if str, ok := cloudInitDatasourceToText[datasource]; !ok {
+		return cloudInitDatasourceUnknown
+	} else {
+		return str
+	}
+}
+
+func (datasource *CloudInitDatasource) UnmarshalText(text []byte) error {
+	txt := string(text)
+	if val, ok := textToCloudInitDatasource[txt]; ok {
+		*datasource = val
+		return nil
+	} else {
+		return errors.New("unknown CloudInitDatasource: " + txt)
+	}
+}
+
 func (consoleType *ConsoleType) CheckValid() error {
 	if _, ok := consoleTypeToText[*consoleType]; !ok {
 		return errors.New(consoleTypeUnknown)
@@ -135,6 +233,143 @@ func (consoleType *ConsoleType) UnmarshalText(text []byte) error {
 	}
 }
 
+func (policy *FirewallPolicy) CheckValid() error {
+	for _, rule := range policy.EgressRules {
+		if err := rule.CheckValid(); err != nil {
+			return err
+		}
+	}
+	for _, rule := range policy.IngressRules {
+		if err := rule.CheckValid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (left *FirewallPolicy) Equal(right *FirewallPolicy) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+	if !firewallRulesEqual(left.EgressRules, right.EgressRules) {
+		return false
+	}
+	if left.FilterEgress != right.FilterEgress {
+		return false
+	}
+	if left.FilterIngress != right.FilterIngress {
+		return false
+	}
+	return firewallRulesEqual(left.IngressRules, right.IngressRules)
+}
+
+func (rule *FirewallRule) CheckValid() error {
+	switch rule.Protocol {
+	case "", "icmp":
+		if rule.FromPort != 0 || rule.ToPort != 0 {
+			return errors.New("ports require the tcp or udp protocol")
+		}
+	case "tcp", "udp":
+	default:
+		return errors.New("unknown protocol: " + rule.Protocol)
+	}
+	if rule.ToPort != 0 && (rule.FromPort == 0 || rule.ToPort < rule.FromPort) {
+		return fmt.Errorf("invalid port range: %d-%d",
+			rule.FromPort, rule.ToPort)
+	}
+	if rule.RemoteNetwork != "" {
+		if _, _, err := net.ParseCIDR(rule.RemoteNetwork); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func firewallRulesEqual(left, right []FirewallRule) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index, leftRule := range left {
+		if leftRule != right[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func placementGroupsEqual(left, right []PlacementGroup) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index, leftGroup := range left {
+		if leftGroup != right[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func (policy PlacementPolicy) MarshalText() ([]byte, error) {
+	if text := policy.String(); text == placementPolicyUnknown {
+		return nil, errors.New(text)
+	} else {
+		return []byte(text), nil
+	}
+}
+
+func (policy *PlacementPolicy) Set(value string) error {
+	if val, ok := textToPlacementPolicy[value]; !ok {
+		return errors.New(placementPolicyUnknown)
+	} else {
+		*policy = val
+		return nil
+	}
+}
+
+func (policy PlacementPolicy) String() string {
+	if text, ok := placementPolicyToText[policy]; ok {
+		return text
+	} else {
+		return placementPolicyUnknown
+	}
+}
+
+func (policy *PlacementPolicy) UnmarshalText(text []byte) error {
+	txt := string(text)
+	if val, ok := textToPlacementPolicy[txt]; ok {
+		*policy = val
+		return nil
+	} else {
+		return errors.New("unknown PlacementPolicy: " + txt)
+	}
+}
+
+func (left *SnapshotSchedule) Equal(right *SnapshotSchedule) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+	return *left == *right
+}
+
+func snapshotsEqual(left, right []Snapshot) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index, leftSnapshot := range left {
+		rightSnapshot := right[index]
+		if leftSnapshot.Name != rightSnapshot.Name {
+			return false
+		}
+		if leftSnapshot.RootOnly != rightSnapshot.RootOnly {
+			return false
+		}
+		if !leftSnapshot.Time.Equal(rightSnapshot.Time) {
+			return false
+		}
+	}
+	return true
+}
+
 func (state State) MarshalText() ([]byte, error) {
 	if text := state.String(); text == stateUnknown {
 		return nil, errors.New(text)
@@ -177,6 +412,15 @@ func (left *Subnet) Equal(right *Subnet) bool {
 	if !IpListsEqual(left.DomainNameServers, right.DomainNameServers) {
 		return false
 	}
+	if !left.FirewallPolicy.Equal(right.FirewallPolicy) {
+		return false
+	}
+	if !left.Ipv6Gateway.Equal(right.Ipv6Gateway) {
+		return false
+	}
+	if left.Ipv6PrefixLength != right.Ipv6PrefixLength {
+		return false
+	}
 	if left.Manage != right.Manage {
 		return false
 	}
@@ -192,6 +436,24 @@ func (left *Subnet) Equal(right *Subnet) bool {
 	return true
 }
 
+// GetIpv6Network returns the IPv6 network of the subnet. If the subnet does not
+// have IPv6, nil is returned.
+func (subnet *Subnet) GetIpv6Network() (*net.IPNet, error) {
+	if len(subnet.Ipv6Gateway) < 1 {
+		return nil, nil
+	}
+	if subnet.Ipv6Gateway.To4() != nil {
+		return nil, fmt.Errorf("subnet: %s: IPv6 gateway: %s is not IPv6",
+			subnet.Id, subnet.Ipv6Gateway)
+	}
+	if subnet.Ipv6PrefixLength < 1 || subnet.Ipv6PrefixLength > 64 {
+		return nil, fmt.Errorf("subnet: %s: invalid IPv6 prefix length: %d",
+			subnet.Id, subnet.Ipv6PrefixLength)
+	}
+	mask := net.CIDRMask(int(subnet.Ipv6PrefixLength), 128)
+	return &net.IPNet{IP: subnet.Ipv6Gateway.Mask(mask), Mask: mask}, nil
+}
+
 func IpListsEqual(left, right []net.IP) bool {
 	if len(left) != len(right) {
 		return false
@@ -216,6 +478,12 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if !left.Address.Equal(&right.Address) {
 		return false
 	}
+	if !left.Backup.Equal(right.Backup) {
+		return false
+	}
+	if left.CloudInitDatasource != right.CloudInitDatasource {
+		return false
+	}
 	if left.ConsoleType != right.ConsoleType {
 		return false
 	}
@@ -225,6 +493,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if left.DisableVirtIO != right.DisableVirtIO {
 		return false
 	}
+	if !left.FirewallPolicy.Equal(right.FirewallPolicy) {
+		return false
+	}
 	if left.Hostname != right.Hostname {
 		return false
 	}
@@ -234,6 +505,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if left.ImageURL != right.ImageURL {
 		return false
 	}
+	if left.MemoryBalloon != right.MemoryBalloon {
+		return false
+	}
 	if left.MemoryInMiB != right.MemoryInMiB {
 		return false
 	}
@@ -246,6 +520,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if !stringSlicesEqual(left.OwnerUsers, right.OwnerUsers) {
 		return false
 	}
+	if !placementGroupsEqual(left.PlacementGroups, right.PlacementGroups) {
+		return false
+	}
 	if left.SpreadVolumes != right.SpreadVolumes {
 		return false
 	}
@@ -266,6 +543,15 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if !stringSlicesEqual(left.SecondarySubnetIDs, right.SecondarySubnetIDs) {
 		return false
 	}
+	if !left.SnapshotSchedule.Equal(right.SnapshotSchedule) {
+		return false
+	}
+	if !snapshotsEqual(left.Snapshots, right.Snapshots) {
+		return false
+	}
+	if !stringSlicesEqual(left.SshPublicKeys, right.SshPublicKeys) {
+		return false
+	}
 	if left.SubnetId != right.SubnetId {
 		return false
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/diff"
 )
 
 type AddImageRequest struct {
@@ -14,6 +15,29 @@ type AddImageRequest struct {
 
 type AddImageResponse struct{}
 
+// Operations recorded in the audit log for mutations which are not made by an
+// RPC. These entries have no username or address.
+const (
+	AuditOperationExpireImage      = "ExpireImage"
+	AuditOperationQuarantineObject = "QuarantineObject"
+	AuditOperationRetentionDelete  = "RetentionDeleteImage"
+)
+
+// AuditEntry records a mutation. Each entry contains the hash of the previous
+// entry, so that changes to the log can be detected.
+type AuditEntry struct {
+	Sequence     uint64 // Starts at 1.
+	Time         time.Time
+	Username     string
+	Address      string // Source address.
+	Operation    string // Name of the RPC or an AuditOperation* constant.
+	Name         string // Image, directory, alias name or object hash.
+	Detail       string `json:",omitempty"`
+	Error        string `json:",omitempty"` // Empty on success.
+	PreviousHash string
+	Hash         string
+}
+
 type ChangeImageExpirationRequest struct {
 	ExpiresAt time.Time
 	ImageName string
@@ -46,6 +70,20 @@ type CheckImageResponse struct {
 	ImageExists bool
 }
 
+// CreateUploadLease protects objects from the garbage collector while an image
+// is being uploaded. Objects added after the lease is created are protected,
+// as are the listed objects (which may already be present).
+type CreateUploadLeaseRequest struct {
+	Objects []hash.Hash
+	Timeout time.Duration // Zero means the default timeout.
+}
+
+type CreateUploadLeaseResponse struct {
+	Error     string
+	ExpiresAt time.Time
+	LeaseId   string
+}
+
 type DeleteImageRequest struct {
 	ImageName string
 }
@@ -59,6 +97,52 @@ type DeleteUnreferencedObjectsRequest struct {
 
 type DeleteUnreferencedObjectsResponse struct{}
 
+// Image names may also be alias names.
+type DiffImagesRequest struct {
+	LeftImageName  string
+	RightImageName string
+}
+
+type DiffImagesResponse struct {
+	Diff  *diff.Diff
+	Error string
+}
+
+type DirectoryUsage struct {
+	Name           string
+	HardQuotaBytes uint64 // Applies to the directory and subdirectories.
+	SoftQuotaBytes uint64 // Applies to the directory and subdirectories.
+	Usage
+}
+
+// All the specified criteria must match. Shell patterns may be used for
+// PathPattern and PackageName.
+type ExtendUploadLeaseRequest struct {
+	LeaseId string
+	Objects []hash.Hash // Additional objects to protect.
+	Timeout time.Duration
+}
+
+type ExtendUploadLeaseResponse struct {
+	Error     string
+	ExpiresAt time.Time
+}
+
+type FindImagesRequest struct {
+	CreatedAfter   time.Time // Zero: no limit.
+	CreatedBefore  time.Time // Zero: no limit.
+	CreatedBy      string
+	ObjectHash     *hash.Hash
+	PackageName    string
+	PackageVersion string // Constraint, e.g. "<1.1.1w". Requires PackageName.
+	PathPattern    string
+}
+
+type FindImagesResponse struct {
+	Error  string
+	Images []FoundImage
+}
+
 type FindLatestImageRequest struct {
 	DirectoryName        string
 	IgnoreExpiringImages bool
@@ -69,6 +153,27 @@ type FindLatestImageResponse struct {
 	Error     string
 }
 
+type FoundImage struct {
+	Name      string
+	CreatedBy string
+	CreatedOn time.Time
+	Packages  []image.Package // Matching packages.
+	Paths     []string        // Matching paths.
+}
+
+// The GetAuditLog() RPC is fully streamed.
+// The client sends a GetAuditLogRequest message.
+// The server sends a stream of AuditEntry messages with a zero value for the
+// Sequence field signifying the end of the matching entries. If Follow is true
+// new matching entries are then sent as they are recorded.
+type GetAuditLogRequest struct {
+	Follow        bool
+	Name          string // If not empty, only entries for this name.
+	Operation     string // If not empty, only entries for this operation.
+	StartSequence uint64
+	Username      string // If not empty, only entries for this user.
+}
+
 type GetImageExpirationRequest struct {
 	ImageName string
 }
@@ -89,10 +194,29 @@ type GetImageResponse struct {
 	Image *image.Image
 }
 
+type GetUsageRequest struct{}
+
+type GetUsageResponse struct {
+	Directories []DirectoryUsage
+	Groups      []GroupUsage
+}
+
+type GroupUsage struct {
+	Name string // Owner group of the image directories.
+	Usage
+}
+
+type ImageMissingObjects struct {
+	ImageName      string
+	MissingObjects []hash.Hash
+}
+
 const (
 	OperationAddImage = iota
 	OperationDeleteImage
 	OperationMakeDirectory
+	OperationSetAlias
+	OperationDeletedImage // Sent in the initial list by multi-master servers.
 )
 
 // The GetImageUpdates() RPC is fully streamed.
@@ -100,11 +224,20 @@ const (
 // The server sends a stream of ImageUpdate messages.
 
 type ImageUpdate struct {
-	Name      string // "" signifies initial list is sent, changes to follow.
-	Directory *image.Directory
-	Operation uint
+	Name          string    // "": initial list is sent, changes follow.
+	CreatedOn     time.Time // Only for OperationAddImage.
+	DeletedOn     time.Time // Only for delete operations.
+	ObjectsDigest hash.Hash // Only for OperationAddImage from multi-master.
+	Alias         *image.Alias
+	Directory     *image.Directory
+	Operation     uint
 }
 
+// The ListAliases() RPC is fully streamed.
+// The client sends no information to the server.
+// The server sends a stream of image.Alias values with an empty string for the
+// Name field signifying the end of the list.
+
 // The ListDirectories() RPC is fully streamed.
 // The client sends no information to the server.
 // The server sends a stream of image.Directory values with an empty string
@@ -130,3 +263,66 @@ type MakeDirectoryRequest struct {
 }
 
 type MakeDirectoryResponse struct{}
+
+type ReleaseUploadLeaseRequest struct {
+	LeaseId string
+}
+
+type ReleaseUploadLeaseResponse struct {
+	Error string
+}
+
+type ResolveImageAliasRequest struct {
+	AliasName string
+}
+
+type ResolveImageAliasResponse struct {
+	Alias *image.Alias // nil if the alias does not exist.
+}
+
+type SetDirectoryQuotaRequest struct {
+	DirectoryName  string
+	HardQuotaBytes uint64
+	SoftQuotaBytes uint64
+}
+
+type SetDirectoryQuotaResponse struct {
+	Error string
+}
+
+type SetImageAliasRequest struct {
+	AliasName  string
+	ImageName  string
+	OwnerGroup string // If empty, the owner group is not changed.
+}
+
+type SetImageAliasResponse struct {
+	Error string
+}
+
+type UploadLease struct {
+	CreatedOn  time.Time
+	ExpiresAt  time.Time
+	NumObjects uint64 // Explicitly listed objects.
+	Username   string
+}
+
+// Usage accounts for the data in the objects referenced by images. The size of
+// each object is shared equally by the images which reference it.
+type Usage struct {
+	FairShareBytes uint64
+	NumImages      uint
+	SharedBytes    uint64 // Objects also referenced from elsewhere.
+	UniqueBytes    uint64 // Objects referenced only from here.
+}
+
+type VerifyImageObjectsRequest struct {
+	ImageNames []string // If empty, all images are verified.
+}
+
+type VerifyImageObjectsResponse struct {
+	Error      string
+	Images     []ImageMissingObjects // Only images with missing objects.
+	NumImages  uint64
+	NumObjects uint64
+}
@@ -11,6 +11,7 @@ type BuildImageRequest struct {
 	ExpiresIn             time.Duration
 	GitBranch             string
 	MaxSourceAge          time.Duration
+	Priority              int // Higher runs sooner. Positive: admin only.
 	ReturnImage           bool
 	StreamBuildLog        bool
 	StreamName            string
@@ -23,3 +24,28 @@ type BuildImageResponse struct {
 	BuildLog    []byte
 	ErrorString string
 }
+
+type CancelBuildRequest struct {
+	StreamName string
+}
+
+type CancelBuildResponse struct {
+	NumCancelled uint
+	Error        string
+}
+
+type TestResult struct {
+	Advisory bool `json:",omitempty"`
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+	Name     string
+	Output   string `json:",omitempty"`
+	TimedOut bool   `json:",omitempty"`
+	VM       bool   `json:",omitempty"` // If true, run against a test VM.
+}
+
+// TestResults is stored (JSON encoded) in the TestResults annotation of built
+// images.
+type TestResults struct {
+	Tests []TestResult
+}